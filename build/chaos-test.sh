@@ -0,0 +1,70 @@
+#!/bin/bash
+# Copyright (c) 2018 VMware Inc. All Rights Reserved.
+# SPDX-License-Identifier: Apache-2.0
+
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+
+#    http://www.apache.org/licenses/LICENSE-2.0
+
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Runs the chaos tests of test/chaos: creates a kind cluster and a Dgraph container, starts the controller built from
+# the sources against them and runs the tests, which restart Dgraph and pause the api server while pods are created
+# and deleted. Requires docker, kind and go, run it from the root of the repository after `make deps`.
+
+set -o errexit
+set -o nounset
+
+CLUSTER=${CLUSTER:-purser-chaos}
+DGRAPH_CONTAINER=${DGRAPH_CONTAINER:-purser-chaos-dgraph}
+DGRAPH_IMAGE=${DGRAPH_IMAGE:-dgraph/dgraph:v1.0.9}
+DGRAPH_PORT=${DGRAPH_PORT:-9080}
+KEEP=${KEEP:-false}
+
+WORKDIR=$(mktemp -d)
+KUBECONFIG_FILE=${WORKDIR}/kubeconfig
+CONTROLLER_PID=""
+
+cleanup() {
+    if [ -n "${CONTROLLER_PID}" ]; then
+        kill "${CONTROLLER_PID}" 2>/dev/null || true
+        wait "${CONTROLLER_PID}" 2>/dev/null || true
+    fi
+    if [ "${KEEP}" != "true" ]; then
+        docker rm -f "${DGRAPH_CONTAINER}" >/dev/null 2>&1 || true
+        kind delete cluster --name "${CLUSTER}" >/dev/null 2>&1 || true
+        rm -rf "${WORKDIR}"
+    else
+        echo "keeping cluster ${CLUSTER}, Dgraph ${DGRAPH_CONTAINER} and logs in ${WORKDIR}"
+    fi
+}
+trap cleanup EXIT
+
+echo "creating kind cluster ${CLUSTER}..."
+kind create cluster --name "${CLUSTER}" --wait 120s
+kind get kubeconfig --name "${CLUSTER}" > "${KUBECONFIG_FILE}"
+
+echo "starting Dgraph ${DGRAPH_IMAGE}..."
+docker run -d --name "${DGRAPH_CONTAINER}" -p "${DGRAPH_PORT}:9080" "${DGRAPH_IMAGE}" \
+    bash -c "dgraph zero --my=localhost:5080 & dgraph server --my=localhost:7080 --lru_mb 1024 --zero localhost:5080"
+until docker exec "${DGRAPH_CONTAINER}" curl -s localhost:8080/health >/dev/null 2>&1; do
+    sleep 2
+done
+
+echo "starting controller, logs in ${WORKDIR}/controller.log..."
+go build -o "${WORKDIR}/controller" github.com/vmware/purser/cmd/controller
+"${WORKDIR}/controller" --log=debug --kubeconfig="${KUBECONFIG_FILE}" --dgraphURL=localhost \
+    --dgraphPort="${DGRAPH_PORT}" > "${WORKDIR}/controller.log" 2>&1 &
+CONTROLLER_PID=$!
+
+KUBECONFIG=${KUBECONFIG_FILE} \
+PURSER_CHAOS_DGRAPH=localhost:${DGRAPH_PORT} \
+PURSER_CHAOS_DGRAPH_CONTAINER=${DGRAPH_CONTAINER} \
+PURSER_CHAOS_APISERVER_CONTAINER=${CLUSTER}-control-plane \
+    go test -tags chaos -v -timeout 30m github.com/vmware/purser/test/chaos/...
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"crypto/subtle"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+)
+
+const defaultQueryTimeout = 30 * time.Second
+
+// Settings of the api server.
+type Settings struct {
+	// AdminTokenFile is the path of the file holding the bearer token of the admin endpoints (/admin/*), which give
+	// raw access to the graph and start or inspect maintenance jobs, and of the changes of the external costs. These
+	// endpoints are disabled when it is empty.
+	AdminTokenFile string `json:"adminTokenFile,omitempty"`
+	// QueryTimeout is the max duration of a raw query (ex: 10s), 30s by default.
+	QueryTimeout string `json:"queryTimeout,omitempty"`
+	// QueryCostLimit is the max estimated cost of a query with a window, in pod-days: the days of the window times
+	// the pods alive in the namespace of the query (or in the cluster). Queries are not limited when it is 0.
+	QueryCostLimit float64 `json:"queryCostLimit,omitempty"`
+	// NarrowWindows makes the queries exceeding QueryCostLimit be served on a window shortened to fit the limit
+	// (from is moved forward) instead of being rejected.
+	NarrowWindows bool `json:"narrowWindows,omitempty"`
+	// SSO scopes the requests of the users logged in with an OIDC provider to the namespaces and groups of their
+	// teams.
+	SSO SSOSettings `json:"sso,omitempty"`
+}
+
+var settings = Settings{}
+
+var queryTimeout = defaultQueryTimeout
+
+// Setup sets the settings of the api server.
+func Setup(apiSettings Settings) {
+	settings = apiSettings
+	queryTimeout = defaultQueryTimeout
+	if settings.QueryTimeout == "" {
+		return
+	}
+	timeout, err := time.ParseDuration(settings.QueryTimeout)
+	if err != nil || timeout <= 0 {
+		logrus.Errorf("invalid query timeout: %q, using %v", settings.QueryTimeout, defaultQueryTimeout)
+		return
+	}
+	queryTimeout = timeout
+}
+
+// AdminOnly wraps the handler of an admin endpoint so that it is served only to requests having the admin token
+// in the Authorization header (Bearer scheme). The token file is read on every request so that it can be rotated.
+func AdminOnly(inner http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if settings.AdminTokenFile == "" {
+			writeError(&w, r, apierrors.New(apierrors.Forbidden, "admin endpoint is disabled, no admin token file is configured"))
+			return
+		}
+		token, err := ioutil.ReadFile(settings.AdminTokenFile)
+		if err != nil {
+			writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to read admin token: (%v)", err))
+			return
+		}
+		expected := strings.TrimSpace(string(token))
+		given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+			writeError(&w, r, apierrors.New(apierrors.Unauthorized, "missing or invalid admin token"))
+			return
+		}
+		inner(w, r)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// isAdminRoute returns true if the route must only be served with the admin token
+func isAdminRoute(route Route) bool {
+	return strings.HasPrefix(route.Pattern, "/admin/") ||
+		(route.Pattern == "/externalcosts" && route.Method != "GET")
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purser-admin")
+	utils.Ok(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	utils.Ok(t, ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+	defer func() { settings = Settings{} }()
+
+	adminRoutes := 0
+	for _, route := range routes {
+		if !isAdminRoute(route) {
+			continue
+		}
+		adminRoutes++
+		settings = Settings{}
+		w := httptest.NewRecorder()
+		route.HandlerFunc(w, httptest.NewRequest(route.Method, route.Pattern, nil))
+		utils.Assert(t, w.Code == http.StatusForbidden, "%s without admin token file: status %d", route.Name, w.Code)
+
+		settings = Settings{AdminTokenFile: tokenFile}
+		for _, header := range []string{"", "Bearer wrong"} {
+			r := httptest.NewRequest(route.Method, route.Pattern, nil)
+			if header != "" {
+				r.Header.Set("Authorization", header)
+			}
+			w = httptest.NewRecorder()
+			route.HandlerFunc(w, r)
+			utils.Assert(t, w.Code == http.StatusUnauthorized, "%s with token %q: status %d", route.Name, header, w.Code)
+		}
+	}
+	utils.Equals(t, 10, adminRoutes)
+}
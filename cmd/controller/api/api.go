@@ -18,17 +18,54 @@
 package api
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/handlers"
 )
 
-// StartServer starts api server
-func StartServer() {
+// StartServer starts api server. Once ctx is done, the server stops accepting connections and it returns when the
+// requests in progress are completed or the grace period is over.
+func StartServer(ctx context.Context, gracePeriod time.Duration) {
 	allowedOrigins := handlers.AllowedOrigins([]string{"*"})
 	allowedCredentials := handlers.AllowCredentials()
+	allowedMethods := handlers.AllowedMethods([]string{"GET", "POST", "DELETE"})
+	// the dashboards send the ID token of the user when SSO is enabled
+	allowedHeaders := handlers.AllowedHeaders([]string{"Authorization", "Content-Type", TenantHeader})
 	router := NewRouter()
+	server := &http.Server{Handler: handlers.CORS(allowedOrigins, allowedCredentials, allowedMethods, allowedHeaders)(router)}
+
+	listener, err := net.Listen("tcp", ":3030")
+	if err != nil {
+		logrus.Fatal(err)
+	}
 	logrus.Info("Purser server started on port `localhost:3030`")
-	logrus.Fatal(http.ListenAndServe(":3030", handlers.CORS(allowedOrigins, allowedCredentials)(router)))
+	if err = serve(ctx, server, listener, gracePeriod); err != nil {
+		logrus.Fatal(err)
+	}
+	logrus.Info("Purser server stopped")
+}
+
+// serve serves the connections of the listener until ctx is done, then it waits for the requests in progress to
+// complete during the grace period
+func serve(ctx context.Context, server *http.Server, listener net.Listener, gracePeriod time.Duration) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("Purser server did not stop gracefully: (%v)", err)
+		}
+	}()
+
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	<-stopped
+	return nil
 }
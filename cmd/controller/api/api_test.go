@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestServeCompletesRequestsInProgress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.Ok(t, err)
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})}
+
+	ctx, stop := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, server, listener, 5*time.Second)
+	}()
+
+	url := "http://" + listener.Addr().String()
+	responded := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responded <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		responded <- string(body)
+	}()
+	<-started
+	stop()
+
+	utils.Equals(t, "done", <-responded)
+	select {
+	case err = <-served:
+		utils.Ok(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop after the request in progress completed")
+	}
+	_, err = http.Get(url)
+	utils.Assert(t, err != nil, "connection accepted after shutdown")
+}
@@ -22,11 +22,33 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/ghodss/yaml"
+	"github.com/gorilla/mux"
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/aggregation"
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/pkg/controller/capacity"
+	"github.com/vmware/purser/pkg/controller/chargeback"
+	"github.com/vmware/purser/pkg/controller/costcenter"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/dgraph/models"
 	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
 	"github.com/vmware/purser/pkg/controller/discovery/generator"
+	"github.com/vmware/purser/pkg/controller/export"
+	"github.com/vmware/purser/pkg/controller/grafana"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/prometheus"
+	"github.com/vmware/purser/pkg/controller/schedule"
+	"github.com/vmware/purser/pkg/controller/supervisor"
+	"github.com/vmware/purser/pkg/controller/sustainability"
+	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // GetHomePage is the default api home page
@@ -56,6 +78,22 @@ func GetPodInteractions(w http.ResponseWriter, r *http.Request) {
 	writeBytes(w, jsonResp)
 }
 
+// GetServiceDependencyGraph listens on /interactions/services endpoint and returns the graph of the interactions
+// between the services and workloads owning the interacting pods, for the namespace given by query param namespace
+// (all namespaces if it is missing)
+func GetServiceDependencyGraph(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	graph, err := query.RetrieveServiceDependencyGraph(queryParams.Get(query.Namespace))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get service dependency graph: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, graph)
+}
+
 // GetClusterHierarchy listens on /hierarchy endpoint and returns all namespaces(or nodes and PV) in the cluster
 func GetClusterHierarchy(w http.ResponseWriter, r *http.Request) {
 	addHeaders(&w, r)
@@ -231,7 +269,7 @@ func GetJobHierarchy(w http.ResponseWriter, r *http.Request) {
 	encodeAndWrite(w, jsonData)
 }
 
-// GetClusterMetrics listens on /metrics endpoint with option for view(physical or logical)
+// GetClusterMetrics listens on /metrics endpoint with option for view(physical, logical, environment or application)
 func GetClusterMetrics(w http.ResponseWriter, r *http.Request) {
 	addHeaders(&w, r)
 	queryParams := r.URL.Query()
@@ -240,12 +278,25 @@ func GetClusterMetrics(w http.ResponseWriter, r *http.Request) {
 	var jsonData query.JSONDataWrapper
 	if view, isView := queryParams[query.View]; isView && view[0] == query.Physical {
 		jsonData = query.RetrieveClusterMetrics(query.Physical)
+	} else if isView && view[0] == query.Environment {
+		jsonData = query.RetrieveClusterMetrics(query.Environment)
+	} else if isView && view[0] == query.Application {
+		jsonData = query.RetrieveClusterMetrics(query.Application)
 	} else {
 		jsonData = query.RetrieveClusterMetrics(query.Logical)
 	}
 	encodeAndWrite(w, jsonData)
 }
 
+// GetPrometheusMetrics listens on /prometheus/metrics endpoint and returns the cost and health metrics of the
+// controller in the text exposition format of prometheus. It is served without the middleware of the api routes.
+func GetPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", prometheus.ContentType)
+	if err := prometheus.Write(w); err != nil {
+		logrus.Errorf("Unable to write prometheus metrics: (%v)", err)
+	}
+}
+
 // GetNamespaceMetrics listens on /metrics/namespace
 func GetNamespaceMetrics(w http.ResponseWriter, r *http.Request) {
 	addHeaders(&w, r)
@@ -442,15 +493,1409 @@ func GetPodDiscoveryEdges(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func addHeaders(w *http.ResponseWriter, r *http.Request) {
-	if origin := r.Header.Get("Origin"); origin == "https://app.swaggerhub.com" {
-		(*w).Header().Set("Access-Control-Allow-Origin", origin)
-	} else {
-		(*w).Header().Set("Access-Control-Allow-Origin", "*")
+// GetExternalCosts listens on /externalcosts endpoint and returns external costs of a namespace or group
+func GetExternalCosts(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	namespace, group := query.All, query.All
+	if name, isName := queryParams[query.Namespace]; isName {
+		namespace = name[0]
+	} else if name, isName := queryParams[query.Group]; isName {
+		group = name[0]
 	}
-	(*w).Header().Set("Content-Type", "application/json; charset=UTF-8")
-	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
-	(*w).WriteHeader(http.StatusOK)
+	externalCosts, err := query.RetrieveExternalCosts(namespace, group)
+	if err != nil {
+		logrus.Errorf("Unable to get external costs: (%v)", err)
+	}
+	encodeAndWrite(w, externalCosts)
+}
+
+// PostExternalCost listens on /externalcosts endpoint and registers (or updates) an external cost item
+func PostExternalCost(w http.ResponseWriter, r *http.Request) {
+	var item models.ExternalCostItem
+	err := json.NewDecoder(r.Body).Decode(&item)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode external cost: (%v)", err))
+		return
+	}
+	if invalid := validateExternalCostItem(item); invalid != nil {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, invalid.Error()).WithDetails(invalid))
+		return
+	}
+
+	_, err = models.StoreExternalCost(item)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to store external cost: (%v)", err))
+		return
+	}
+	addHeadersWithStatus(&w, r, http.StatusCreated)
+}
+
+// DeleteExternalCost listens on /externalcosts endpoint and removes the external cost with given name
+func DeleteExternalCost(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	name, isName := queryParams[query.Name]
+	if !isName {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for external cost, no name is given"))
+		return
+	}
+	err := models.DeleteExternalCost(name[0])
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "Unable to delete external cost: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+}
+
+// GetPricingCatalog listens on /pricing/catalog endpoint and returns the pricing catalog in use
+func GetPricingCatalog(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, pricing.GetCatalog())
+}
+
+// GetPriceHistory listens on /pricing/history endpoint and returns the price periods with their effective dates
+func GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, pricing.GetPriceHistory())
+}
+
+// GetInstancePrices listens on /pricing/instances endpoint and returns the on-demand prices of the instance types of
+// the nodes, cached from the pricing apis of the cloud providers
+func GetInstancePrices(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	prices, err := models.RetrieveInstancePrices()
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to retrieve instance prices: (%v)", err))
+		return
+	}
+	encodeAndWrite(w, prices)
+}
+
+// PostRecompute listens on /admin/recompute endpoint and starts recomputation of daily cost summaries
+// for the days in query params from and to (format: 2006-01-02). Query param amendment is the reason of the
+// recomputation recorded in the amendments of the seals, it is required when a day of the window is sealed.
+func PostRecompute(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, fromErr := time.ParseInLocation(query.DateFormat, queryParams.Get(query.From), time.Local)
+	to, toErr := time.ParseInLocation(query.DateFormat, queryParams.Get(query.To), time.Local)
+	if fromErr != nil || toErr != nil {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for recompute, from and to dates are required"))
+		return
+	}
+
+	job, err := aggregation.StartRecompute(from, to, queryParams.Get(query.Amendment))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to start recompute: (%v)", err))
+		return
+	}
+	addHeadersWithStatus(&w, r, http.StatusAccepted)
+	encodeAndWrite(w, job)
+}
+
+// GetRecompute listens on /admin/recompute endpoint and returns the progress of recompute job with given id.
+// If no id is given all recompute jobs are returned.
+func GetRecompute(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	id, isID := queryParams[query.ID]
+	if !isID {
+		addHeaders(&w, r)
+		encodeAndWrite(w, aggregation.GetJobs())
+		return
+	}
+	job, isPresent := aggregation.GetJob(id[0])
+	if !isPresent {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "recompute job: %s not found", id[0]))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, job)
+}
+
+// GetDailyCosts listens on /costs/daily endpoint and returns the daily cost of a namespace or group for the days
+// in query params from and to (format: 2006-01-02). Default window is from the current month start to today.
+func GetDailyCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	ownerType, owner := models.NamespaceOwner, queryParams.Get(query.Namespace)
+	if group, isGroup := queryParams[query.Group]; isGroup {
+		ownerType, owner = models.GroupOwner, group[0]
+	}
+	if owner == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for daily costs, no namespace or group is given"))
+		return
+	}
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for daily costs: (%v)", err))
+		return
+	}
+
+	// window end is exclusive, so the last day is the day of the last second in the window
+	dailyCosts, err := aggregation.RetrieveDailyCosts(ownerType, owner, from, to.Add(-time.Second))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get daily costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, dailyCosts)
+}
+
+// GetTopSpenders listens on /top endpoint and returns the namespaces or pods (query param type) with the highest cost
+// in the window given by query params from and to (format: 2006-01-02). Default window is month to date.
+// Every result has its data quality, which tells whether its cost is measured or estimated.
+func GetTopSpenders(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	limit := query.DefaultLimit
+	if limitParam := queryParams.Get(query.Limit); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 || parsedLimit > query.MaxLimit {
+			writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for top spenders, invalid limit: %s", limitParam))
+			return
+		}
+		limit = parsedLimit
+	}
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for top spenders: (%v)", err))
+		return
+	}
+
+	var topSpenders []query.ResourceCost
+	addQuality := query.AddNamespaceQuality
+	switch queryParams.Get(query.Type) {
+	case "", query.Namespace:
+		topSpenders, err = query.RetrieveTopNamespaces(limit, from, to)
+	case "pod":
+		topSpenders, err = query.RetrieveTopPods(limit, from, to)
+		addQuality = query.AddPodQuality
+	default:
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for top spenders, unknown type: %s", queryParams.Get(query.Type)))
+		return
+	}
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get top spenders: (%v)", err))
+		return
+	}
+	if err = addQuality(topSpenders, from, to); err != nil {
+		logrus.Errorf("unable to get data quality of top spenders: (%v)", err)
+	}
+	query.RoundCosts(topSpenders)
+	addHeaders(&w, r)
+	encodeAndWrite(w, topSpenders)
+}
+
+// parseWindow returns the time window given by query params from and to (format: 2006-01-02).
+// Default window is from the current month start to now. Day given by to is included in the window.
+func parseWindow(queryParams url.Values) (time.Time, time.Time, error) {
+	from, to := utils.GetCurrentMonthStartTime(), time.Now()
+	var err error
+	if fromParam := queryParams.Get(query.From); fromParam != "" {
+		if from, err = time.ParseInLocation(query.DateFormat, fromParam, time.Local); err != nil {
+			return from, to, err
+		}
+	}
+	if toParam := queryParams.Get(query.To); toParam != "" {
+		if to, err = time.ParseInLocation(query.DateFormat, toParam, time.Local); err != nil {
+			return from, to, err
+		}
+		to = to.AddDate(0, 0, 1)
+		if to.After(time.Now()) {
+			to = time.Now()
+		}
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("invalid window, from: %s is not before to: %s", from, to)
+	}
+	return from, to, nil
+}
+
+// GetInventory listens on /inventory endpoint and returns the counts and capacities of nodes, pods, pvcs and services
+// per namespace as of the time given by query param time (RFC3339 format, default: now)
+func GetInventory(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	at := time.Now()
+	if timeParam := queryParams.Get(query.Time); timeParam != "" {
+		parsedTime, err := time.Parse(time.RFC3339, timeParam)
+		if err != nil || parsedTime.After(at) {
+			writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for inventory, invalid time: %s", timeParam))
+			return
+		}
+		at = parsedTime
+	}
+
+	inventory, err := query.RetrieveInventory(at)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get inventory: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, inventory)
+}
+
+// PostWhatIf listens on /capacity/whatif endpoint and returns the projected node requirements and monthly cost
+// of the cluster after the hypothetical changes given in the request body
+func PostWhatIf(w http.ResponseWriter, r *http.Request) {
+	var scenario capacity.Scenario
+	err := json.NewDecoder(r.Body).Decode(&scenario)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode what-if scenario: (%v)", err))
+		return
+	}
+
+	projection, err := capacity.Project(scenario)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to project what-if scenario: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, projection)
+}
+
+// GetInstanceTypeRecommendations listens on /capacity/recommendations endpoint and returns the instance types
+// of the pricing catalog which best fit the cpu:memory shapes of the pods, with projected monthly savings
+func GetInstanceTypeRecommendations(w http.ResponseWriter, r *http.Request) {
+	recommendation, err := capacity.Recommend()
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to recommend instance types: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, recommendation)
+}
+
+// GetDrainCandidates listens on /capacity/drain endpoint and returns the nodes which can be scaled down by
+// evicting their pods, with the node each pod fits on. It is meant to drive descheduler policies or custom automation.
+func GetDrainCandidates(w http.ResponseWriter, r *http.Request) {
+	candidates, err := capacity.RetrieveDrainCandidates()
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get drain candidates: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, candidates)
+}
+
+// GetSavings listens on /savings endpoint and returns the cost saving opportunities of the cluster
+func GetSavings(w http.ResponseWriter, r *http.Request) {
+	savings, err := capacity.RetrieveSavings()
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get savings: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, savings)
+}
+
+// GetCarbonFootprint listens on /carbon endpoint and returns the estimated energy and carbon footprint along with
+// the cost of namespaces or groups (query param view) in the window given by query params from and to
+func GetCarbonFootprint(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	view := queryParams.Get(query.View)
+	if view != "" && view != query.Namespace && view != query.Group {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for carbon footprint, unknown view: %s", view))
+		return
+	}
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for carbon footprint: (%v)", err))
+		return
+	}
+
+	footprints, err := sustainability.RetrieveFootprints(view, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get carbon footprint: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, footprints)
+}
+
+// GetInvoice listens on /invoices endpoint and returns the invoice of the group (query param group) for the month
+// given by query param month (format: 2006-01, default: current month) in json or html (query param format)
+func GetInvoice(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	group := queryParams.Get(query.Group)
+	if group == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for invoice, no group is given"))
+		return
+	}
+	monthStart := invoice.GetMonthStart(time.Now())
+	if monthParam := queryParams.Get(query.Month); monthParam != "" {
+		parsedMonth, err := time.ParseInLocation(query.MonthFormat, monthParam, time.Local)
+		if err != nil || parsedMonth.After(time.Now()) {
+			writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for invoice, invalid month: %s", monthParam))
+			return
+		}
+		monthStart = parsedMonth
+	}
+
+	groupInvoice, err := invoice.Generate(group, monthStart)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "Unable to generate invoice: (%v)", err))
+		return
+	}
+	if queryParams.Get(query.Format) == query.HTML {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+group+"-"+monthStart.Format(query.MonthFormat)+`.html"`)
+		if err = invoice.RenderHTML(w, groupInvoice); err != nil {
+			logrus.Errorf("Unable to render invoice: (%v)", err)
+		}
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, groupInvoice)
+}
+
+// GetGrafanaHealth listens on /grafana endpoint, it is used by grafana to test the datasource connection
+func GetGrafanaHealth(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+}
+
+// PostGrafanaSearch listens on /grafana/search endpoint and returns the cost series targets of namespaces and groups
+func PostGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var request grafana.SearchRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode grafana search: (%v)", err))
+		return
+	}
+
+	targets, err := grafana.Search(request.Target)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to search grafana targets: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, targets)
+}
+
+// PostGrafanaQuery listens on /grafana/query endpoint and returns the daily cost series of the targets in the
+// time range of the request
+func PostGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var request grafana.QueryRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode grafana query: (%v)", err))
+		return
+	}
+
+	results, err := grafana.Query(request)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to query grafana targets: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, results)
+}
+
+// GetSubsystems listens on /admin/subsystems endpoint and returns the supervised subsystems and jobs with
+// their crash counters
+func GetSubsystems(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, supervisor.Statuses())
+}
+
+// GetUIDCacheStats listens on /admin/uidcache endpoint and returns the hit and miss counters of the cache of the
+// node uids, for debugging
+func GetUIDCacheStats(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, dgraph.GetUIDCacheStats())
+}
+
+// GetClusterEvents listens on /events endpoint and returns the kubernetes events relevant to cost (query param reason)
+// of a namespace in the window given by query params from and to (format: 2006-01-02). Default window is month to date.
+func GetClusterEvents(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for events: (%v)", err))
+		return
+	}
+
+	events, err := query.RetrieveClusterEvents(queryParams.Get(query.Reason), queryParams.Get(query.Namespace), from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get events: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, events)
+}
+
+// GetNodePressures listens on /nodes/pressure endpoint and returns the intervals during which nodes were under memory
+// or disk pressure in the window given by query params from and to (format: 2006-01-02). Default window is month to date.
+func GetNodePressures(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for node pressures: (%v)", err))
+		return
+	}
+
+	pressures, err := query.RetrieveNodePressures(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get node pressures: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, pressures)
+}
+
+// GetNamespaceArchives listens on /namespaces/archived endpoint and returns the frozen lifetime costs of namespaces
+// deleted in the window given by query params from and to (format: 2006-01-02). Default window is month to date.
+// Query param namespace restricts the result to the archives of that namespace.
+func GetNamespaceArchives(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for archived namespaces: (%v)", err))
+		return
+	}
+	name := queryParams.Get(query.Namespace)
+	if name == "" {
+		name = query.All
+	}
+
+	archives, err := query.RetrieveNamespaceArchives(name, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get archived namespaces: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, archives)
+}
+
+// GetApplicationCosts listens on /applications endpoint and returns the cost of applications identified by the
+// app.kubernetes.io labels of pods, across namespaces, in the window given by query params from and to
+// (format: 2006-01-02). Default window is month to date. Query param groupBy (instance, name or partOf,
+// default: instance) rolls up the application instances.
+func GetApplicationCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for applications: (%v)", err))
+		return
+	}
+	groupBy := queryParams.Get(query.GroupBy)
+	if groupBy == "" {
+		groupBy = query.Instance
+	} else if groupBy != query.Instance && groupBy != query.Name && groupBy != query.PartOf {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "applications can not be grouped by: (%s)", groupBy))
+		return
+	}
+
+	costs, err := query.RetrieveApplicationCostsInWindow(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get application costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, query.RollupApplicationCosts(costs, groupBy))
+}
+
+// GetHelmCosts listens on /helm endpoint and returns the cost of helm releases, with the charts they were deployed
+// with over time, in the window given by query params from and to (format: 2006-01-02). Default window is month
+// to date. Query param groupBy (release or chart, default: release) sums the cost per release or per chart.
+func GetHelmCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for helm releases: (%v)", err))
+		return
+	}
+	groupBy := queryParams.Get(query.GroupBy)
+	if groupBy == "" {
+		groupBy = query.Release
+	} else if groupBy != query.Release && groupBy != query.Chart {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "helm costs can not be grouped by: (%s)", groupBy))
+		return
+	}
+
+	costs, err := query.RetrieveHelmCostsInWindow(groupBy, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get helm costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, costs)
+}
+
+// GetOperatorCosts listens on /operators endpoint and returns the cost of the operators installed by OLM, with their
+// subscription, in the window given by query params from and to (format: 2006-01-02). Default window is month to date.
+func GetOperatorCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for operators: (%v)", err))
+		return
+	}
+
+	costs, err := query.RetrieveOperatorCostsInWindow(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get operator costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, costs)
+}
+
+// GetNamespaceCosts listens on /costs/namespaces endpoint and returns the cost of the namespace given by query param
+// namespace (every namespace if not given) in the window given by query params from and to (format: 2006-01-02).
+// Default window is month to date. Compute, persistent volume claims, volume snapshots and load balancers are
+// reported as separate line items, along with the data quality of the cost. Upfront costs of the pricing config are
+// amortized in their own line item, so is the idle capacity of the node pools split by burst usage. With query param overhead=distribute, the cost of the cluster overhead is shared
+// by the other namespaces. Namespaces with a markup also report their marked up cost. The sealed whole days of the
+// window are read from the daily cost summaries.
+func GetNamespaceCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for namespace costs: (%v)", err))
+		return
+	}
+
+	name := queryParams.Get(query.Namespace)
+	distribute := queryParams.Get(query.Overhead) == query.Distribute
+	amortize := len(pricing.GetUpfrontCosts()) > 0 || allocation.IsIdleSplit()
+	if distribute || amortize {
+		// the overhead, the idle capacity and the upfront costs are shared among all namespaces
+		name = query.All
+	}
+	costs, err := query.RetrieveNamespaceCosts(name, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get namespace costs: (%v)", err))
+		return
+	}
+	if err = query.DistributeIdleCosts(costs, from, to); err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to distribute idle costs: (%v)", err))
+		return
+	}
+	query.DistributeAmortizedCosts(costs, from, to)
+	if distribute {
+		if err = query.DistributeClusterOverhead(costs, from, to); err != nil {
+			writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to distribute cluster overhead: (%v)", err))
+			return
+		}
+	}
+	if distribute || amortize {
+		costs = filterNamespaceCosts(costs, queryParams.Get(query.Namespace))
+	}
+	if err = query.AddNamespaceQuality(costs, from, to); err != nil {
+		logrus.Errorf("unable to get data quality of namespace costs: (%v)", err)
+	}
+	invoice.MarkUpNamespaceCosts(costs)
+	query.RoundCosts(costs)
+	addHeaders(&w, r)
+	encodeAndWrite(w, costs)
+}
+
+// GetCostRates listens on /costs/rate endpoint and returns the cost per hour of the resources allocated right now
+// to the cluster and to its namespaces, or to the pods with the highest rate (query param type: namespace or pod,
+// default: namespace). Query param limit (default: 10) limits the number of pods.
+func GetCostRates(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	limit := query.DefaultLimit
+	if limitParam := queryParams.Get(query.Limit); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 || parsedLimit > query.MaxLimit {
+			writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for cost rates, invalid limit: %s", limitParam))
+			return
+		}
+		limit = parsedLimit
+	}
+	scope := queryParams.Get(query.Type)
+	if scope == "" {
+		scope = query.Namespace
+	}
+
+	rates, err := query.RetrieveCostRates(scope, limit)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get cost rates: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, rates)
+}
+
+// GetOrphanPods listens on /orphans endpoint and returns the pods whose cost can not be attributed (no recognized
+// owner, no team or group label and no cost center) in the window given by query params from and to
+// (format: 2006-01-02), highest cost first. Default window is month to date.
+func GetOrphanPods(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for orphan pods: (%v)", err))
+		return
+	}
+
+	report, err := query.RetrieveOrphanPodsInWindow(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get orphan pods: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// AdminQuery is the body of a raw query request
+type AdminQuery struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// PostAdminQuery listens on /admin/query endpoint and runs the read only GraphQL+- query given in the request body
+// against the graph. It returns the raw json result of Dgraph.
+func PostAdminQuery(w http.ResponseWriter, r *http.Request) {
+	var request AdminQuery
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode query: (%v)", err))
+		return
+	}
+	if err = dgraph.ValidateReadOnlyQuery(request.Query); err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "invalid query: %v", err))
+		return
+	}
+
+	logrus.Infof("admin query from %s: (%v)", r.RemoteAddr, request.Query)
+	result, err := dgraph.ExecuteReadOnlyQuery(request.Query, request.Variables, queryTimeout)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to run query (timeout %v): (%v)", queryTimeout, err))
+		return
+	}
+	addHeaders(&w, r)
+	writeBytes(w, result)
+}
+
+// GetGraphSchema listens on /schema endpoint and returns the current schema of the graph: the migration version,
+// the types of nodes and the predicates with their indices.
+func GetGraphSchema(w http.ResponseWriter, r *http.Request) {
+	schema, err := query.RetrieveGraphSchema()
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to retrieve graph schema: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, schema)
+}
+
+// GetClusterOverhead listens on /overhead endpoint and returns the namespaces and node agent daemonsets classified as
+// cluster overhead with their cost in the window given by query params from and to. Default window is month to date.
+func GetClusterOverhead(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for cluster overhead: (%v)", err))
+		return
+	}
+
+	overhead, err := query.RetrieveClusterOverheadInWindow(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get cluster overhead: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, overhead)
+}
+
+// filterNamespaceCosts returns the cost of the namespace with the given xid, or all costs if xid is empty
+func filterNamespaceCosts(costs []query.ResourceCost, xid string) []query.ResourceCost {
+	if xid == query.All {
+		return costs
+	}
+	filtered := []query.ResourceCost{}
+	for _, cost := range costs {
+		if cost.Xid == xid {
+			filtered = append(filtered, cost)
+		}
+	}
+	return filtered
+}
+
+// GetInactiveDeployments listens on /deployments/inactive endpoint and returns the running deployments whose pods
+// used almost no cpu and received no calls from other pods during the configured number of days, with their cost
+// in this period.
+func GetInactiveDeployments(w http.ResponseWriter, r *http.Request) {
+	inactive, err := query.RetrieveInactiveDeployments(controller.GetInactiveDays(), time.Now())
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get inactive deployments: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, inactive)
+}
+
+// GetKEDASavings listens on /keda endpoint and returns the savings of the KEDA ScaledObjects obtained by scaling
+// their workloads to zero in the time window given by query params from and to, and the deployments which are
+// candidates for KEDA adoption
+func GetKEDASavings(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for keda savings: (%v)", err))
+		return
+	}
+	objects, err := controller.ListScaledObjects()
+	if err != nil {
+		// KEDA is not installed
+		logrus.Debugf("unable to list keda scaled objects: (%v)", err)
+		objects = nil
+	}
+	report, err := query.RetrieveKEDAReport(objects, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get keda savings: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetUsagePatterns listens on /deployments/usage-patterns endpoint and returns the cpu usage heatmaps (day of week x
+// hour of day) of the running deployments with the hours they could be shut down or the advice to run them off-peak,
+// ordered by estimated savings.
+func GetUsagePatterns(w http.ResponseWriter, r *http.Request) {
+	patterns, err := query.RetrieveUsagePatterns(controller.GetActivityCPUThreshold(), time.Now())
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get usage patterns: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, patterns)
+}
+
+// GetSchedules listens on /schedules endpoint and returns the compliance of the workloads of every schedule policy
+// with its office hours in the time window given by query params from and to, with the cost spent outside of the
+// office hours and the estimated savings.
+func GetSchedules(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for schedules: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, schedule.RetrieveReports(from, to))
+}
+
+// PostScalingPreview listens on /capacity/scaling-preview endpoint and returns the projected cost delta of a workload
+// if the HPA bounds and/or the VPA requests given in the request body had been applied during the last days
+func PostScalingPreview(w http.ResponseWriter, r *http.Request) {
+	var change capacity.ScalingChange
+	err := json.NewDecoder(r.Body).Decode(&change)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to decode scaling change: (%v)", err))
+		return
+	}
+
+	preview, err := capacity.PreviewScaling(change)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to preview scaling change: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, preview)
+}
+
+// GetNodePoolCosts listens on /nodepools/costs endpoint and returns the cost of the pods by node pool and zone in the
+// time window given by query params from and to, following the pods across the nodes on which they were rescheduled
+func GetNodePoolCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for node pool costs: (%v)", err))
+		return
+	}
+	costs, err := query.RetrieveNodePoolCostsInWindow(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get node pool costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, costs)
+}
+
+// GetPodPlacements listens on /pods/placements endpoint and returns the nodes on which the pod given by query param
+// name (namespace:pod) ran, oldest first
+func GetPodPlacements(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get(query.Name)
+	if name == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "query param name (namespace:pod) is required"))
+		return
+	}
+	placements, err := query.RetrievePodPlacements(name)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get pod placements: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, placements)
+}
+
+// GetUpgradeRollouts listens on /upgrades endpoint and returns the kubelet and OS version rollouts seen across
+// the cluster nodes, oldest first
+func GetUpgradeRollouts(w http.ResponseWriter, r *http.Request) {
+	rollouts, err := query.RetrieveUpgradeRollouts(time.Now())
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get upgrade rollouts: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, rollouts)
+}
+
+// GetUpgradeImpact listens on /upgrades/impact endpoint and compares the cluster cost and efficiency over the
+// given number of days (query param days) before and after the rollout of the given version (query param version)
+// of the given component (query param component: kubelet or os)
+func GetUpgradeImpact(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	version := queryParams.Get(query.Version)
+	if version == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "query param version is required"))
+		return
+	}
+	component := query.KubeletComponent
+	if c := queryParams.Get(query.Component); c != "" {
+		component = c
+	}
+	days := query.DefaultDays
+	if d := queryParams.Get(query.Days); d != "" {
+		days, _ = strconv.Atoi(d)
+	}
+
+	impact, err := query.RetrieveUpgradeImpact(component, version, days, time.Now())
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "Unable to get upgrade impact: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, impact)
+}
+
+// GetConsumerUsage listens on /admin/consumers endpoint and returns the requests, errors, durations and response sizes
+// of every api consumer (tenant, bearer token or address) by route, with its slowest requests
+func GetConsumerUsage(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, ConsumerUsages())
+}
+
+// PostReportJob listens on /jobs endpoint and starts a job building the cost allocation report of the days from
+// query param from to query param to (both inclusive) in the given format (csv, jsonl or parquet). The report is
+// pushed to the object store sinks if query param push is true. The job is polled on /jobs/{id}.
+func PostReportJob(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, fromErr := time.ParseInLocation(query.DateFormat, queryParams.Get(query.From), time.Local)
+	to, toErr := time.ParseInLocation(query.DateFormat, queryParams.Get(query.To), time.Local)
+	if fromErr != nil || toErr != nil {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for report job, from and to dates are required"))
+		return
+	}
+
+	format := queryParams.Get(query.Format)
+	if format == "" {
+		format = export.CSV
+	}
+	job, err := export.StartReport(from, to, format, queryParams.Get(query.Push) == "true")
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to start report job: (%v)", err))
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	addHeadersWithStatus(&w, r, http.StatusAccepted)
+	encodeAndWrite(w, job)
+}
+
+// GetReportJobs listens on /jobs endpoint and returns all report jobs which did not expire
+func GetReportJobs(w http.ResponseWriter, r *http.Request) {
+	addHeaders(&w, r)
+	encodeAndWrite(w, export.GetReports())
+}
+
+// GetReportJob listens on /jobs/{id} endpoint and returns the progress of the report job
+func GetReportJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)[query.ID]
+	job, isPresent := export.GetReport(id)
+	if !isPresent {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "report job: %s not found", id))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, job)
+}
+
+// GetReportArtifact listens on /jobs/{id}/artifact endpoint and downloads the report of a completed job
+func GetReportArtifact(w http.ResponseWriter, r *http.Request) {
+	artifact, err := export.GetReportArtifact(mux.Vars(r)[query.ID])
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "Unable to get report: (%v)", err))
+		return
+	}
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename="+artifact.Name)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(artifact.Data); err != nil {
+		logrus.Errorf("Unable to write report: (%v)", err)
+	}
+}
+
+// DeleteReportJob listens on /jobs/{id} endpoint, it cancels the report job if it is running, otherwise the job and
+// its report are deleted
+func DeleteReportJob(w http.ResponseWriter, r *http.Request) {
+	job, err := export.CancelReport(mux.Vars(r)[query.ID])
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.NotFound, "Unable to cancel report job: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, job)
+}
+
+// GetSealVerification listens on /audit/verify endpoint and verifies that the daily cost summaries of the days from
+// query param from to query param to (both inclusive, default: month to date) are the ones which were sealed
+func GetSealVerification(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for seal verification: (%v)", err))
+		return
+	}
+	verification, err := aggregation.VerifySeals(from, to.Add(-time.Nanosecond))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to verify seals: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, verification)
+}
+
+// GetArchivedResources listens on /admin/archive endpoint and returns the resources soft deleted by the retention
+// pruning which were archived at or after query param since (RFC3339 format, default: all)
+func GetArchivedResources(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSince(r.URL.Query())
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for archived resources: (%v)", err))
+		return
+	}
+	resources, err := dgraph.RetrieveArchivedResources(since)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get archived resources: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, resources)
+}
+
+// PostArchiveRestore listens on /admin/archive/restore endpoint and restores the archived resource with the xid
+// given by query param xid, or all resources archived at or after query param since (RFC3339 format). Restored
+// resources are not pruned again, resources recreated since they were archived are reported as conflicts.
+func PostArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	xid := queryParams.Get(query.Xid)
+	if xid == "" && queryParams.Get(query.Since) == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "query param xid or since is required"))
+		return
+	}
+	since, err := parseSince(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for restore: (%v)", err))
+		return
+	}
+
+	result, err := dgraph.RestoreArchivedResources(xid, since)
+	if err != nil {
+		kind := apierrors.NotFound
+		if len(result.Conflicts) > 0 {
+			kind = apierrors.InvalidRequest
+		}
+		writeError(&w, r, apierrors.Newf(kind, "Unable to restore archived resources: (%v)", err))
+		return
+	}
+	logrus.Infof("%d archived resources restored by %s", result.Restored, r.RemoteAddr)
+	addHeaders(&w, r)
+	encodeAndWrite(w, result)
+}
+
+// parseSince returns the time given by query param since, the zero time if it is missing
+func parseSince(queryParams url.Values) (time.Time, error) {
+	sinceParam := queryParams.Get(query.Since)
+	if sinceParam == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, sinceParam)
+}
+
+// GetPVCStorageUsage listens on /pvcs/usage endpoint and returns the provisioned and used storage of the pvcs of the
+// namespace given by query param namespace (default: all) with their wasted storage cost in the window given by query
+// params from and to. Default window is month to date.
+func GetPVCStorageUsage(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for pvc storage usage: (%v)", err))
+		return
+	}
+	report, err := query.RetrievePVCStorageUsage(queryParams.Get(query.Namespace), from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get pvc storage usage: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetNamespaceObjectGrowth listens on /namespaces/objects endpoint and returns the counts of config maps, secrets and
+// custom resources of the namespace given by query param namespace (default: all) sampled in the window given by query
+// params from and to, flagging the namespaces with runaway object growth. Default window is month to date.
+func GetNamespaceObjectGrowth(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for namespace objects: (%v)", err))
+		return
+	}
+	report, err := query.RetrieveObjectGrowth(queryParams.Get(query.Namespace), from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get namespace objects: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetNetworkPolicies listens on /networkpolicies endpoint and returns the network policies of the namespace given by
+// query param namespace (default: all)
+func GetNetworkPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := query.RetrieveNetworkPolicies(r.URL.Query().Get(query.Namespace))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get network policies: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, policies)
+}
+
+// GetSuggestedNetworkPolicies listens on /networkpolicies/suggested endpoint and returns least privilege ingress
+// policies for the workloads of the namespace given by query param namespace (default: all) built from their observed
+// interactions. With query param format=yaml the policies are downloaded as a yaml manifest.
+func GetSuggestedNetworkPolicies(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	namespace := queryParams.Get(query.Namespace)
+	policies, err := query.SuggestNetworkPolicies(namespace)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to suggest network policies: (%v)", err))
+		return
+	}
+	if queryParams.Get(query.Format) != query.YAML {
+		addHeaders(&w, r)
+		encodeAndWrite(w, policies)
+		return
+	}
+
+	manifest, err := yaml.Marshal(policies)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to encode network policies: (%v)", err))
+		return
+	}
+	filename := "suggested-network-policies.yaml"
+	if namespace != query.All {
+		filename = namespace + "-" + filename
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(manifest); err != nil {
+		logrus.Errorf("Unable to write network policies: (%v)", err)
+	}
+}
+
+// GetNetworkTraffic listens on /network/traffic endpoint and returns the traffic of the pods of the namespace given by
+// query param namespace (default: all) ingested from VPC flow logs in the window given by query params from and to,
+// priced with the network prices of the cluster. Default window is month to date.
+func GetNetworkTraffic(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for network traffic: (%v)", err))
+		return
+	}
+	report, err := query.RetrieveNetworkTraffic(queryParams.Get(query.Namespace), from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get network traffic: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetNATAttribution listens on /network/nat endpoint and returns the NAT gateway processing charges attributed to the
+// namespaces by their traffic with the internet in the window given by query params from and to, top contributors
+// first. Default window is month to date.
+func GetNATAttribution(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for nat attribution: (%v)", err))
+		return
+	}
+	report, err := query.RetrieveNATAttribution(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get nat attribution: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// maxCostCenterFileSize bounds the size of an imported cost center csv file
+const maxCostCenterFileSize = 10 << 20
+
+// PostCostCenterImport listens on /costcenters/import endpoint and replaces the cost centers imported from csv by
+// the hierarchy of the csv file in the request body (columns: name, parent, namespaces and labels)
+func PostCostCenterImport(w http.ResponseWriter, r *http.Request) {
+	items, err := costcenter.ImportCSV(http.MaxBytesReader(w, r.Body, maxCostCenterFileSize))
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidRequest, "Unable to import cost centers: (%v)", err))
+		return
+	}
+	logrus.Infof("%d cost centers imported by %s", len(items), r.RemoteAddr)
+	addHeadersWithStatus(&w, r, http.StatusCreated)
+	encodeAndWrite(w, items)
+}
+
+// GetCostCenterCosts listens on /costs/costcenters endpoint and returns the cost center hierarchy with the cost of
+// every cost center in the window given by query params from and to (format: 2006-01-02), default: month to date
+func GetCostCenterCosts(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for cost center costs: (%v)", err))
+		return
+	}
+	report, err := query.RetrieveCostCenterCosts(from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get cost center costs: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetChargeback listens on /costs/chargeback endpoint and returns the chargeback report of the window given by query
+// params from and to (format: 2006-01-02), default: month to date. Query param groupBy (namespace or label, default:
+// namespace) groups the costs per namespace or per value of the label key given by query param label, ex: team.
+// With query param format=csv the report is returned as a csv file for finance imports.
+func GetChargeback(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for chargeback: (%v)", err))
+		return
+	}
+	label := queryParams.Get(query.Label)
+	switch queryParams.Get(query.GroupBy) {
+	case "", chargeback.ByNamespace:
+		if label != "" {
+			writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for chargeback, label is given with groupBy namespace"))
+			return
+		}
+	case chargeback.ByLabel:
+		if label == "" {
+			writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for chargeback, no label is given"))
+			return
+		}
+	default:
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "chargeback can not be grouped by: (%s)", queryParams.Get(query.GroupBy)))
+		return
+	}
+
+	report, err := chargeback.Generate(label, from, to)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to generate chargeback: (%v)", err))
+		return
+	}
+	if queryParams.Get(query.Format) == export.CSV {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="chargeback-`+from.Format(query.DateFormat)+"-"+to.Format(query.DateFormat)+`.csv"`)
+		if err = chargeback.WriteCSV(w, report); err != nil {
+			logrus.Errorf("Unable to write chargeback: (%v)", err)
+		}
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, report)
+}
+
+// GetCostTrend listens on /costs/trend endpoint and returns the cost of the namespace given by query param namespace
+// (the cluster if none of namespace, label and workload is given), of the pods having the label given by query params
+// label (key) and value, or of the pods of the workload given by query param workload (kind/namespace:name) for every
+// day, week or month (query param period, default: daily) of the window given by query params from and to (format:
+// 2006-01-02), default: month to date. The costs are the sums of the cost snapshots of the pods.
+func GetCostTrend(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for cost trend: (%v)", err))
+		return
+	}
+	period := queryParams.Get(query.Period)
+	if period == "" {
+		period = query.Daily
+	}
+	namespace, label, workload := queryParams.Get(query.Namespace), queryParams.Get(query.Label), queryParams.Get(query.Workload)
+	owners := 0
+	for _, owner := range []string{namespace, label, workload} {
+		if owner != "" {
+			owners++
+		}
+	}
+	if owners > 1 {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for cost trend, only one of namespace, label and workload can be given"))
+		return
+	}
+	if label != "" && queryParams.Get(query.Value) == "" {
+		writeError(&w, r, apierrors.New(apierrors.InvalidParameter, "wrong type of query for cost trend, no value of the label is given"))
+		return
+	}
+
+	var trend query.CostTrend
+	switch {
+	case label != "":
+		trend, err = query.RetrieveLabelCostTrend(label, queryParams.Get(query.Value), period, from, to)
+	case workload != "":
+		trend, err = query.RetrieveWorkloadCostTrend(workload, period, from, to)
+	default:
+		trend, err = query.RetrieveNamespaceCostTrend(namespace, period, from, to)
+	}
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get cost trend: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, trend)
+}
+
+// GetAllocationRecords listens on /costs/allocations endpoint and returns the allocation records of the pods of the
+// namespace given by query param namespace (every namespace if not given) for every hour of their life in the window
+// given by query params from and to (format: 2006-01-02), default: month to date. Records are paginated: query param
+// limit (default and max: 1000) is the size of a page and query param after the cursor returned as next by the
+// previous page.
+func GetAllocationRecords(w http.ResponseWriter, r *http.Request) {
+	queryParams := r.URL.Query()
+	logrus.Debugf("Query params: (%v)", queryParams)
+
+	from, to, err := parseWindow(queryParams)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for allocation records: (%v)", err))
+		return
+	}
+	limit := query.MaxLimit
+	if limitParam := queryParams.Get(query.Limit); limitParam != "" {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit <= 0 || parsedLimit > query.MaxLimit {
+			writeError(&w, r, apierrors.Newf(apierrors.InvalidParameter, "wrong type of query for allocation records, invalid limit: %s", limitParam))
+			return
+		}
+		limit = parsedLimit
+	}
+
+	records, err := query.RetrieveAllocationRecords(queryParams.Get(query.Namespace), from, to, queryParams.Get(query.After), limit)
+	if err != nil {
+		writeError(&w, r, apierrors.Newf(apierrors.Internal, "Unable to get allocation records: (%v)", err))
+		return
+	}
+	addHeaders(&w, r)
+	encodeAndWrite(w, records)
+}
+
+func addHeaders(w *http.ResponseWriter, r *http.Request) {
+	addHeadersWithStatus(w, r, http.StatusOK)
+}
+
+func addHeadersWithStatus(w *http.ResponseWriter, r *http.Request, status int) {
+	if origin := r.Header.Get("Origin"); origin == "https://app.swaggerhub.com" {
+		(*w).Header().Set("Access-Control-Allow-Origin", origin)
+	} else {
+		(*w).Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	(*w).Header().Set("Content-Type", "application/json; charset=UTF-8")
+	(*w).Header().Set("Access-Control-Allow-Credentials", "true")
+	// blackbox clusters have no metrics source, costs are estimated from requests and usage based reports are empty
+	(*w).Header().Set("X-Purser-Metrics-Mode", models.GetMetricsMode())
+	(*w).WriteHeader(status)
+}
+
+// writeError writes the error as response body with the http status of its code
+func writeError(w *http.ResponseWriter, r *http.Request, apiErr *apierrors.Error) {
+	logrus.Errorf("%s %s: (%v)", r.Method, r.URL.Path, apiErr)
+	addHeadersWithStatus(w, r, apierrors.HTTPStatus(apiErr.Code))
+	encodeAndWrite(*w, apiErr)
 }
 
 func writeBytes(w io.Writer, data []byte) {
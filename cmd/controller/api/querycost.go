@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// NarrowedWindowHeader is set on the responses of the queries whose window was narrowed to fit the cost limit,
+// its value is the from used (format: 2006-01-02)
+const NarrowedWindowHeader = "X-Purser-Narrowed-From"
+
+const podCountsTTL = 5 * time.Minute
+
+var (
+	podCountsMu        sync.Mutex
+	podCountsUpdatedAt time.Time
+	clusterPods        int
+	namespacePods      = map[string]int{}
+)
+
+// QueryCost is the estimation of the cost of a query given with the error of a rejected query
+type QueryCost struct {
+	Days    int     `json:"days"`
+	Pods    int     `json:"pods"`
+	Cost    float64 `json:"cost"`
+	Limit   float64 `json:"limit"`
+	MaxDays int     `json:"maxDays"`
+}
+
+// CostLimiter estimates the cost of the GET queries having a window (query params from or to) before they reach the
+// inner handler. The queries exceeding the QueryCostLimit are rejected, or narrowed when NarrowWindows is set.
+// Background jobs (recompute, reports) are started with POST and are not limited.
+func CostLimiter(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queryParams := r.URL.Query()
+		if settings.QueryCostLimit <= 0 || r.Method != http.MethodGet || (queryParams.Get(query.From) == "" && queryParams.Get(query.To) == "") {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		from, to, err := parseWindow(queryParams)
+		if err != nil {
+			// invalid windows are reported by the handlers
+			inner.ServeHTTP(w, r)
+			return
+		}
+		pods, err := podsInScope(queryParams.Get(query.Namespace))
+		if err != nil {
+			logrus.Warnf("unable to estimate query cost, serving it unlimited: %v", err)
+			inner.ServeHTTP(w, r)
+			return
+		}
+
+		cost := estimateQueryCost(from, to, pods, settings.QueryCostLimit)
+		if cost.Cost <= cost.Limit {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		if !settings.NarrowWindows {
+			writeError(&w, r, apierrors.Newf(apierrors.QueryTooExpensive,
+				"query over %d days of %d pods is estimated at %.0f pod-days, above the limit of %.0f: use a window of at most %d days or a narrower scope (namespace)",
+				cost.Days, cost.Pods, cost.Cost, cost.Limit, cost.MaxDays).WithDetails(cost))
+			return
+		}
+
+		narrowedFrom := narrowedWindowStart(to, cost.MaxDays).Format(query.DateFormat)
+		logrus.Infof("narrowing query %s to start at %s, estimated cost: %.0f pod-days", r.URL.RequestURI(), narrowedFrom, cost.Cost)
+		queryParams.Set(query.From, narrowedFrom)
+		r.URL.RawQuery = queryParams.Encode()
+		w.Header().Set(NarrowedWindowHeader, narrowedFrom)
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// estimateQueryCost returns the cost in pod-days of a query on the given pods over the window, days are counted
+// started so that the shortest window costs as much as a day
+func estimateQueryCost(from, to time.Time, pods int, limit float64) QueryCost {
+	if pods < 1 {
+		pods = 1
+	}
+	days := int(math.Ceil(to.Sub(from).Hours() / 24))
+	maxDays := int(limit / float64(pods))
+	if maxDays < 1 {
+		maxDays = 1
+	}
+	return QueryCost{
+		Days:    days,
+		Pods:    pods,
+		Cost:    float64(days * pods),
+		Limit:   limit,
+		MaxDays: maxDays,
+	}
+}
+
+// narrowedWindowStart returns the day from which a window ending at to lasts maxDays. The window served by the
+// handlers includes the day of to.
+func narrowedWindowStart(to time.Time, maxDays int) time.Time {
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location())
+	if to.Equal(last) {
+		last = last.AddDate(0, 0, -1)
+	}
+	return last.AddDate(0, 0, 1-maxDays)
+}
+
+// podsInScope returns the pods alive in the namespace, or in the cluster when the namespace is empty. The counts
+// are refreshed from the inventory every podCountsTTL.
+func podsInScope(namespace string) (int, error) {
+	podCountsMu.Lock()
+	defer podCountsMu.Unlock()
+
+	if time.Since(podCountsUpdatedAt) > podCountsTTL {
+		inventory, err := query.RetrieveInventory(time.Now())
+		if err != nil {
+			return 0, fmt.Errorf("unable to count pods: %v", err)
+		}
+		clusterPods = 0
+		namespacePods = map[string]int{}
+		for _, ns := range inventory.Namespaces {
+			namespacePods[ns.Name] = ns.Pods
+			clusterPods += ns.Pods
+		}
+		podCountsUpdatedAt = time.Now()
+	}
+
+	if namespace != "" {
+		return namespacePods[namespace], nil
+	}
+	return clusterPods, nil
+}
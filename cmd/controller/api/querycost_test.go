@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	utils.Equals(t, QueryCost{Days: 30, Pods: 10, Cost: 300, Limit: 100, MaxDays: 10},
+		estimateQueryCost(from, from.AddDate(0, 0, 30), 10, 100))
+	// a started day costs a whole day, an empty scope costs as much as a pod
+	utils.Equals(t, QueryCost{Days: 1, Pods: 1, Cost: 1, Limit: 100, MaxDays: 100},
+		estimateQueryCost(from, from.Add(time.Hour), 0, 100))
+	utils.Equals(t, 1, estimateQueryCost(from, from.AddDate(0, 0, 1), 500, 100).MaxDays)
+}
+
+func TestNarrowedWindowStart(t *testing.T) {
+	day := func(d, h int) time.Time {
+		return time.Date(2018, 11, d, h, 0, 0, 0, time.UTC)
+	}
+	utils.Equals(t, day(21, 0), narrowedWindowStart(day(30, 12), 10))
+	// the window ending at midnight does not include the next day
+	utils.Equals(t, day(20, 0), narrowedWindowStart(day(30, 0), 10))
+	utils.Equals(t, day(30, 0), narrowedWindowStart(day(30, 12), 1))
+}
+
+func TestCostLimiter(t *testing.T) {
+	podCountsMu.Lock()
+	podCountsUpdatedAt, clusterPods, namespacePods = time.Now(), 11, map[string]int{"shop": 10, "ci": 1}
+	podCountsMu.Unlock()
+	defer func() {
+		settings = Settings{}
+		podCountsUpdatedAt = time.Time{}
+	}()
+	settings = Settings{QueryCostLimit: 100}
+
+	// the windows of the queries do not cross a daylight saving time change, their days are whole
+
+	var servedFrom string
+	handler := CostLimiter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		servedFrom = r.URL.Query().Get("from")
+	}))
+	serve := func(method, url string) *httptest.ResponseRecorder {
+		servedFrom = ""
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+		return w
+	}
+
+	w := serve("GET", "/namespaces?from=2018-06-01&to=2018-06-30&namespace=shop")
+	utils.Equals(t, http.StatusUnprocessableEntity, w.Code)
+	utils.Equals(t, "", servedFrom)
+	body := struct {
+		Code    string    `json:"code"`
+		Details QueryCost `json:"details"`
+	}{}
+	utils.Ok(t, json.Unmarshal(w.Body.Bytes(), &body))
+	utils.Equals(t, apierrors.QueryTooExpensive, body.Code)
+	utils.Equals(t, QueryCost{Days: 30, Pods: 10, Cost: 300, Limit: 100, MaxDays: 10}, body.Details)
+
+	// queries within the limit, without window and background jobs are served
+	utils.Equals(t, http.StatusOK, serve("GET", "/namespaces?from=2018-06-01&to=2018-06-30&namespace=ci").Code)
+	utils.Equals(t, "2018-06-01", servedFrom)
+	serve("GET", "/namespaces?namespace=shop")
+	utils.Equals(t, "", servedFrom)
+	utils.Equals(t, http.StatusOK, serve("POST", "/admin/recompute?from=2018-06-01&to=2018-06-30").Code)
+	utils.Equals(t, "2018-06-01", servedFrom)
+
+	settings.NarrowWindows = true
+	w = serve("GET", "/namespaces?from=2018-06-01&to=2018-06-30&namespace=shop")
+	utils.Equals(t, http.StatusOK, w.Code)
+	utils.Equals(t, "2018-06-21", servedFrom)
+	utils.Equals(t, "2018-06-21", w.Header().Get(NarrowedWindowHeader))
+}
@@ -18,15 +18,20 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gorilla/mux"
 )
 
+// PrometheusMetricsPath is the path scraped by prometheus, the json metrics of the dashboards are at /metrics
+const PrometheusMetricsPath = "/prometheus/metrics"
+
 // NewRouter returns a new instance of the router
 func NewRouter() *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 	for _, route := range routes {
 		handlerFunc := route.HandlerFunc
-		handler := Logger(handlerFunc, route.Name)
+		handler := Logger(Accounting(SSOScoper(Validator(CostLimiter(handlerFunc)), route.Name), route.Name), route.Name)
 
 		router.
 			Methods(route.Method).
@@ -34,5 +39,11 @@ func NewRouter() *mux.Router {
 			Name(route.Name).
 			Handler(handler)
 	}
+	// scrapes are neither authenticated, rate limited nor accounted like the api requests
+	router.
+		Methods(http.MethodGet).
+		Path(PrometheusMetricsPath).
+		Name("GetPrometheusMetrics").
+		HandlerFunc(GetPrometheusMetrics)
 	return router
 }
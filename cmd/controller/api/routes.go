@@ -45,6 +45,12 @@ var routes = Routes{
 		"/interactions/pod",
 		GetPodInteractions,
 	},
+	Route{
+		"GetServiceDependencyGraph",
+		"GET",
+		"/interactions/services",
+		GetServiceDependencyGraph,
+	},
 	Route{
 		"GetClusterHierarchy",
 		"GET",
@@ -207,4 +213,382 @@ var routes = Routes{
 		"/edges",
 		GetPodDiscoveryEdges,
 	},
+	Route{
+		"GetExternalCosts",
+		"GET",
+		"/externalcosts",
+		GetExternalCosts,
+	},
+	Route{
+		"PostExternalCost",
+		"POST",
+		"/externalcosts",
+		AdminOnly(PostExternalCost),
+	},
+	Route{
+		"DeleteExternalCost",
+		"DELETE",
+		"/externalcosts",
+		AdminOnly(DeleteExternalCost),
+	},
+	Route{
+		"GetPricingCatalog",
+		"GET",
+		"/pricing/catalog",
+		GetPricingCatalog,
+	},
+	Route{
+		"GetPriceHistory",
+		"GET",
+		"/pricing/history",
+		GetPriceHistory,
+	},
+	Route{
+		"GetInstancePrices",
+		"GET",
+		"/pricing/instances",
+		GetInstancePrices,
+	},
+	Route{
+		"PostRecompute",
+		"POST",
+		"/admin/recompute",
+		AdminOnly(PostRecompute),
+	},
+	Route{
+		"GetRecompute",
+		"GET",
+		"/admin/recompute",
+		AdminOnly(GetRecompute),
+	},
+	Route{
+		"GetDailyCosts",
+		"GET",
+		"/costs/daily",
+		GetDailyCosts,
+	},
+	Route{
+		"GetTopSpenders",
+		"GET",
+		"/top",
+		GetTopSpenders,
+	},
+	Route{
+		"GetInventory",
+		"GET",
+		"/inventory",
+		GetInventory,
+	},
+	Route{
+		"PostWhatIf",
+		"POST",
+		"/capacity/whatif",
+		PostWhatIf,
+	},
+	Route{
+		"GetInstanceTypeRecommendations",
+		"GET",
+		"/capacity/recommendations",
+		GetInstanceTypeRecommendations,
+	},
+	Route{
+		"GetDrainCandidates",
+		"GET",
+		"/capacity/drain",
+		GetDrainCandidates,
+	},
+	Route{
+		"GetSavings",
+		"GET",
+		"/savings",
+		GetSavings,
+	},
+	Route{
+		"GetCarbonFootprint",
+		"GET",
+		"/carbon",
+		GetCarbonFootprint,
+	},
+	Route{
+		"GetInvoice",
+		"GET",
+		"/invoices",
+		GetInvoice,
+	},
+	Route{
+		"GetGrafanaHealth",
+		"GET",
+		"/grafana",
+		GetGrafanaHealth,
+	},
+	Route{
+		"PostGrafanaSearch",
+		"POST",
+		"/grafana/search",
+		PostGrafanaSearch,
+	},
+	Route{
+		"PostGrafanaQuery",
+		"POST",
+		"/grafana/query",
+		PostGrafanaQuery,
+	},
+	Route{
+		"GetSubsystems",
+		"GET",
+		"/admin/subsystems",
+		AdminOnly(GetSubsystems),
+	},
+	Route{
+		"GetUIDCacheStats",
+		"GET",
+		"/admin/uidcache",
+		AdminOnly(GetUIDCacheStats),
+	},
+	Route{
+		"GetClusterEvents",
+		"GET",
+		"/events",
+		GetClusterEvents,
+	},
+	Route{
+		"GetNodePressures",
+		"GET",
+		"/nodes/pressure",
+		GetNodePressures,
+	},
+	Route{
+		"GetNamespaceArchives",
+		"GET",
+		"/namespaces/archived",
+		GetNamespaceArchives,
+	},
+	Route{
+		"GetApplicationCosts",
+		"GET",
+		"/applications",
+		GetApplicationCosts,
+	},
+	Route{
+		"GetHelmCosts",
+		"GET",
+		"/helm",
+		GetHelmCosts,
+	},
+	Route{
+		"GetOperatorCosts",
+		"GET",
+		"/operators",
+		GetOperatorCosts,
+	},
+	Route{
+		"GetNamespaceCosts",
+		"GET",
+		"/costs/namespaces",
+		GetNamespaceCosts,
+	},
+	Route{
+		"GetCostRates",
+		"GET",
+		"/costs/rate",
+		GetCostRates,
+	},
+	Route{
+		"GetOrphanPods",
+		"GET",
+		"/orphans",
+		GetOrphanPods,
+	},
+	Route{
+		"PostAdminQuery",
+		"POST",
+		"/admin/query",
+		AdminOnly(PostAdminQuery),
+	},
+	Route{
+		"GetGraphSchema",
+		"GET",
+		"/schema",
+		GetGraphSchema,
+	},
+	Route{
+		"GetClusterOverhead",
+		"GET",
+		"/overhead",
+		GetClusterOverhead,
+	},
+	Route{
+		"GetInactiveDeployments",
+		"GET",
+		"/deployments/inactive",
+		GetInactiveDeployments,
+	},
+	Route{
+		"GetKEDASavings",
+		"GET",
+		"/keda",
+		GetKEDASavings,
+	},
+	Route{
+		"GetUsagePatterns",
+		"GET",
+		"/deployments/usage-patterns",
+		GetUsagePatterns,
+	},
+	Route{
+		"GetSchedules",
+		"GET",
+		"/schedules",
+		GetSchedules,
+	},
+	Route{
+		"PostScalingPreview",
+		"POST",
+		"/capacity/scaling-preview",
+		PostScalingPreview,
+	},
+	Route{
+		"GetNodePoolCosts",
+		"GET",
+		"/nodepools/costs",
+		GetNodePoolCosts,
+	},
+	Route{
+		"GetPodPlacements",
+		"GET",
+		"/pods/placements",
+		GetPodPlacements,
+	},
+	Route{
+		"GetUpgradeRollouts",
+		"GET",
+		"/upgrades",
+		GetUpgradeRollouts,
+	},
+	Route{
+		"GetUpgradeImpact",
+		"GET",
+		"/upgrades/impact",
+		GetUpgradeImpact,
+	},
+	Route{
+		"GetConsumerUsage",
+		"GET",
+		"/admin/consumers",
+		AdminOnly(GetConsumerUsage),
+	},
+	Route{
+		"PostReportJob",
+		"POST",
+		"/jobs",
+		PostReportJob,
+	},
+	Route{
+		"GetReportJobs",
+		"GET",
+		"/jobs",
+		GetReportJobs,
+	},
+	Route{
+		"GetReportJob",
+		"GET",
+		"/jobs/{id}",
+		GetReportJob,
+	},
+	Route{
+		"GetReportArtifact",
+		"GET",
+		"/jobs/{id}/artifact",
+		GetReportArtifact,
+	},
+	Route{
+		"DeleteReportJob",
+		"DELETE",
+		"/jobs/{id}",
+		DeleteReportJob,
+	},
+	Route{
+		"GetSealVerification",
+		"GET",
+		"/audit/verify",
+		GetSealVerification,
+	},
+	Route{
+		"GetArchivedResources",
+		"GET",
+		"/admin/archive",
+		AdminOnly(GetArchivedResources),
+	},
+	Route{
+		"PostArchiveRestore",
+		"POST",
+		"/admin/archive/restore",
+		AdminOnly(PostArchiveRestore),
+	},
+	Route{
+		"GetPVCStorageUsage",
+		"GET",
+		"/pvcs/usage",
+		GetPVCStorageUsage,
+	},
+	Route{
+		"GetNamespaceObjectGrowth",
+		"GET",
+		"/namespaces/objects",
+		GetNamespaceObjectGrowth,
+	},
+	Route{
+		"GetNetworkPolicies",
+		"GET",
+		"/networkpolicies",
+		GetNetworkPolicies,
+	},
+	Route{
+		"GetSuggestedNetworkPolicies",
+		"GET",
+		"/networkpolicies/suggested",
+		GetSuggestedNetworkPolicies,
+	},
+	Route{
+		"GetNetworkTraffic",
+		"GET",
+		"/network/traffic",
+		GetNetworkTraffic,
+	},
+	Route{
+		"GetNATAttribution",
+		"GET",
+		"/network/nat",
+		GetNATAttribution,
+	},
+	Route{
+		"PostCostCenterImport",
+		"POST",
+		"/costcenters/import",
+		AdminOnly(PostCostCenterImport),
+	},
+	Route{
+		"GetCostCenterCosts",
+		"GET",
+		"/costs/costcenters",
+		GetCostCenterCosts,
+	},
+	Route{
+		"GetChargeback",
+		"GET",
+		"/costs/chargeback",
+		GetChargeback,
+	},
+	Route{
+		"GetAllocationRecords",
+		"GET",
+		"/costs/allocations",
+		GetAllocationRecords,
+	},
+	Route{
+		"GetCostTrend",
+		"GET",
+		"/costs/trend",
+		GetCostTrend,
+	},
 }
@@ -0,0 +1,282 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+const defaultGroupsClaim = "groups"
+
+// SSOSettings map the groups of the users logged in with an OIDC provider to the namespaces and purser groups
+// they see. Requests must then carry the ID token of the user in the Authorization header (Bearer scheme).
+type SSOSettings struct {
+	// Issuer is the url of the OIDC provider (ex: https://accounts.example.com), it must match the issuer of the
+	// tokens exactly. SSO is disabled when it is empty.
+	Issuer string `json:"issuer,omitempty"`
+	// ClientID is the audience expected in the ID tokens
+	ClientID string `json:"clientID,omitempty"`
+	// GroupsClaim is the claim of the ID tokens listing the groups of the user, groups by default
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// AdminGroups are the SSO groups whose users see the whole cluster
+	AdminGroups []string `json:"adminGroups,omitempty"`
+	// Teams map SSO groups to the namespaces and purser groups their users see
+	Teams []TeamMapping `json:"teams,omitempty"`
+}
+
+// TeamMapping gives the namespaces and purser groups seen by the users of an SSO group
+type TeamMapping struct {
+	SSOGroup   string   `json:"ssoGroup"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+}
+
+// teamScope is what a user sees, the whole cluster when all is set
+type teamScope struct {
+	all        bool
+	namespaces []string
+	groups     []string
+}
+
+// teamRoutes are the routes served to the users scoped to their teams, with the query params (namespace and/or
+// group) by which they are scoped. Cluster wide routes are reserved to the admin groups.
+var teamRoutes = map[string][]string{
+	"GetExternalCosts":            {query.Namespace, query.Group},
+	"GetDailyCosts":               {query.Namespace, query.Group},
+	"GetInvoice":                  {query.Group},
+	"GetNamespaceCosts":           {query.Namespace},
+	"GetNamespaceArchives":        {query.Namespace},
+	"GetClusterEvents":            {query.Namespace},
+	"GetPVCStorageUsage":          {query.Namespace},
+	"GetNamespaceObjectGrowth":    {query.Namespace},
+	"GetNetworkPolicies":          {query.Namespace},
+	"GetSuggestedNetworkPolicies": {query.Namespace},
+	"GetNetworkTraffic":           {query.Namespace},
+}
+
+var (
+	verifierMu sync.Mutex
+	verifier   *oidc.IDTokenVerifier
+	ssoClient  = &http.Client{Timeout: 10 * time.Second}
+)
+
+// SSOScoper restricts the requests of the users logged in with the OIDC provider to the namespaces and groups of
+// their teams. The namespace or group of a request is checked against the scope of the user and the first one of
+// the scope is used when none is given. Requests with the admin token are not scoped.
+func SSOScoper(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if settings.SSO.Issuer == "" || name == "GetHomePage" || hasAdminToken(r) {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || token == r.Header.Get("Authorization") {
+			writeError(&w, r, apierrors.New(apierrors.Unauthorized, "missing ID token"))
+			return
+		}
+		claims, err := verifyIDToken(r.Context(), token)
+		if err != nil {
+			writeError(&w, r, apierrors.Newf(apierrors.Unauthorized, "invalid ID token: (%v)", err))
+			return
+		}
+		markAuthenticated(r)
+		scope := scopeOf(ssoGroupsOf(claims))
+		if scope.all {
+			inner.ServeHTTP(w, r)
+			return
+		}
+		if apiErr := applyScope(r, name, scope); apiErr != nil {
+			writeError(&w, r, apiErr)
+			return
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// hasAdminToken tells if the request has the admin token in the Authorization header
+func hasAdminToken(r *http.Request) bool {
+	if settings.AdminTokenFile == "" {
+		return false
+	}
+	token, err := ioutil.ReadFile(settings.AdminTokenFile)
+	if err != nil {
+		return false
+	}
+	expected := strings.TrimSpace(string(token))
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(given)) == 1
+}
+
+// scopeOf returns the union of the scopes of the SSO groups
+func scopeOf(ssoGroups []string) teamScope {
+	scope := teamScope{}
+	namespaces, groups := map[string]bool{}, map[string]bool{}
+	for _, ssoGroup := range ssoGroups {
+		for _, admin := range settings.SSO.AdminGroups {
+			if ssoGroup == admin {
+				return teamScope{all: true}
+			}
+		}
+		for _, team := range settings.SSO.Teams {
+			if team.SSOGroup != ssoGroup {
+				continue
+			}
+			for _, namespace := range team.Namespaces {
+				namespaces[namespace] = true
+			}
+			for _, group := range team.Groups {
+				groups[group] = true
+			}
+		}
+	}
+	for namespace := range namespaces {
+		scope.namespaces = append(scope.namespaces, namespace)
+	}
+	for group := range groups {
+		scope.groups = append(scope.groups, group)
+	}
+	sort.Strings(scope.namespaces)
+	sort.Strings(scope.groups)
+	return scope
+}
+
+// applyScope checks every namespace and group of the request against the scope, the request is forbidden when any of
+// them is out of it. The first namespace (or group) of the scope is set on the request when it gives none.
+func applyScope(r *http.Request, name string, scope teamScope) *apierrors.Error {
+	params, isTeamRoute := teamRoutes[name]
+	if !isTeamRoute {
+		return apierrors.Newf(apierrors.Forbidden, "%s is not available to team scoped users", r.URL.Path)
+	}
+	if len(scope.namespaces) == 0 && len(scope.groups) == 0 {
+		return apierrors.New(apierrors.Forbidden, "no team is mapped to the groups of the user")
+	}
+
+	queryParams := r.URL.Query()
+	isScoped := false
+	for _, param := range params {
+		values, isGiven := queryParams[param]
+		if !isGiven {
+			continue
+		}
+		for _, value := range values {
+			if !contains(scopeValues(scope, param), value) {
+				return apierrors.Newf(apierrors.Forbidden, "%s %q is not in the scope of the user", param, value)
+			}
+		}
+		isScoped = true
+	}
+	if isScoped {
+		return nil
+	}
+	for _, param := range params {
+		if values := scopeValues(scope, param); len(values) > 0 {
+			queryParams.Set(param, values[0])
+			r.URL.RawQuery = queryParams.Encode()
+			return nil
+		}
+	}
+	return apierrors.Newf(apierrors.Forbidden, "no %s is in the scope of the user", strings.Join(params, " or "))
+}
+
+func scopeValues(scope teamScope, param string) []string {
+	if param == query.Group {
+		return scope.groups
+	}
+	return scope.namespaces
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ssoGroupsOf returns the groups of the configured claim, a single group may be given as a string
+func ssoGroupsOf(claims map[string]interface{}) []string {
+	claim := settings.SSO.GroupsClaim
+	if claim == "" {
+		claim = defaultGroupsClaim
+	}
+	switch groups := claims[claim].(type) {
+	case string:
+		return []string{groups}
+	case []interface{}:
+		var names []string
+		for _, group := range groups {
+			if name, isString := group.(string); isString {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	return nil
+}
+
+// verifyIDToken checks the signature, the issuer, the audience and the expiry of the token with the keys published by
+// the OIDC provider and returns its claims. RS256 and ES256 signatures are supported.
+func verifyIDToken(ctx context.Context, token string) (map[string]interface{}, error) {
+	v, err := idTokenVerifier()
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := v.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	claims := map[string]interface{}{}
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %v", err)
+	}
+	return claims, nil
+}
+
+// idTokenVerifier returns the verifier of the ID tokens, the discovery document of the issuer is fetched the first
+// time and again after a failure. The keys of the issuer are fetched again when a token is signed with a new key.
+func idTokenVerifier() (*oidc.IDTokenVerifier, error) {
+	verifierMu.Lock()
+	defer verifierMu.Unlock()
+
+	if verifier != nil {
+		return verifier, nil
+	}
+	// the context is kept by the provider to fetch the keys of the issuer, it must outlive the request
+	ctx := oidc.ClientContext(context.Background(), ssoClient)
+	provider, err := oidc.NewProvider(ctx, settings.SSO.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to discover issuer: %v", err)
+	}
+	verifier = provider.Verifier(&oidc.Config{ClientID: settings.SSO.ClientID,
+		SupportedSigningAlgs: []string{oidc.RS256, oidc.ES256}})
+	return verifier, nil
+}
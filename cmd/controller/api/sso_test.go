@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/test/utils"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	utils.Ok(t, err)
+	payload, err := json.Marshal(claims)
+	utils.Ok(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	utils.Ok(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// serveIssuer serves the discovery document and the signing key of an OIDC provider
+func serveIssuer(key *rsa.PrivateKey, kid string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/.well-known/openid-configuration" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys",
+				"authorization_endpoint": server.URL + "/auth", "token_endpoint": server.URL + "/token"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{"kty": "RSA", "kid": kid,
+			"alg": "RS256", "use": "sig", "n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())}}})
+	}))
+	return server
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	utils.Ok(t, err)
+	issuer := serveIssuer(key, "k1")
+	defer issuer.Close()
+	settings = Settings{SSO: SSOSettings{Issuer: issuer.URL, ClientID: "purser"}}
+	verifier = nil
+	defer func() {
+		settings = Settings{}
+		verifier = nil
+	}()
+
+	ctx := context.Background()
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":    issuer.URL,
+		"aud":    []string{"purser", "other"},
+		"exp":    now.Add(time.Hour).Unix(),
+		"groups": []string{"team-a"},
+	}
+	verified, err := verifyIDToken(ctx, signToken(t, key, "k1", claims))
+	utils.Ok(t, err)
+	utils.Equals(t, []string{"team-a"}, ssoGroupsOf(verified))
+
+	claims["exp"] = now.Add(-2 * time.Hour).Unix()
+	_, err = verifyIDToken(ctx, signToken(t, key, "k1", claims))
+	utils.Assert(t, err != nil, "expired token must be rejected")
+
+	claims["exp"] = now.Add(time.Hour).Unix()
+	claims["iss"] = "https://sso.example.com"
+	_, err = verifyIDToken(ctx, signToken(t, key, "k1", claims))
+	utils.Assert(t, err != nil, "token of another issuer must be rejected")
+
+	claims["iss"] = issuer.URL
+	claims["aud"] = "other"
+	_, err = verifyIDToken(ctx, signToken(t, key, "k1", claims))
+	utils.Assert(t, err != nil, "token of another client must be rejected")
+
+	claims["aud"] = "purser"
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	utils.Ok(t, err)
+	_, err = verifyIDToken(ctx, signToken(t, other, "k2", claims))
+	utils.Assert(t, err != nil, "token signed with an unknown key must be rejected")
+}
+
+func TestApplyScope(t *testing.T) {
+	settings = Settings{SSO: SSOSettings{
+		AdminGroups: []string{"finops"},
+		Teams: []TeamMapping{
+			{SSOGroup: "team-a", Namespaces: []string{"shop", "cart"}},
+			{SSOGroup: "team-b", Groups: []string{"payments"}},
+		},
+	}}
+	defer func() { settings = Settings{} }()
+
+	utils.Assert(t, scopeOf([]string{"team-a", "finops"}).all, "admin groups must see the cluster")
+	scope := scopeOf([]string{"team-a", "team-b"})
+	utils.Equals(t, []string{"cart", "shop"}, scope.namespaces)
+
+	r := httptest.NewRequest("GET", "/costs/namespaces", nil)
+	utils.Assert(t, applyScope(r, "GetNamespaceCosts", scope) == nil, "namespace must default to the scope")
+	utils.Equals(t, "cart", r.URL.Query().Get("namespace"))
+
+	r = httptest.NewRequest("GET", "/costs/daily?group=payments", nil)
+	utils.Assert(t, applyScope(r, "GetDailyCosts", scope) == nil, "group of the scope must be served")
+
+	r = httptest.NewRequest("GET", "/costs/daily?namespace=billing", nil)
+	apiErr := applyScope(r, "GetDailyCosts", scope)
+	utils.Assert(t, apiErr != nil && apiErr.Code == apierrors.Forbidden, "namespace out of scope must be forbidden")
+
+	// every scoped param is checked, the group of another team is not served with a namespace of the scope
+	r = httptest.NewRequest("GET", "/costs/daily?namespace=shop&group=billing", nil)
+	apiErr = applyScope(r, "GetDailyCosts", scope)
+	utils.Assert(t, apiErr != nil && apiErr.Code == apierrors.Forbidden, "group out of scope must be forbidden")
+
+	r = httptest.NewRequest("GET", "/externalcosts?group=payments&namespace=billing", nil)
+	apiErr = applyScope(r, "GetExternalCosts", scope)
+	utils.Assert(t, apiErr != nil && apiErr.Code == apierrors.Forbidden, "namespace out of scope must be forbidden")
+
+	r = httptest.NewRequest("GET", "/costs/namespaces?namespace=shop&namespace=billing", nil)
+	apiErr = applyScope(r, "GetNamespaceCosts", scope)
+	utils.Assert(t, apiErr != nil && apiErr.Code == apierrors.Forbidden, "repeated namespace out of scope must be forbidden")
+
+	r = httptest.NewRequest("GET", "/costs/daily?namespace=shop&group=payments", nil)
+	utils.Assert(t, applyScope(r, "GetDailyCosts", scope) == nil, "namespace and group of the scope must be served")
+	utils.Equals(t, "namespace=shop&group=payments", r.URL.RawQuery)
+
+	r = httptest.NewRequest("GET", "/hierarchy", nil)
+	apiErr = applyScope(r, "GetClusterHierarchy", scope)
+	utils.Assert(t, apiErr != nil && apiErr.Code == apierrors.Forbidden, "cluster wide routes must be forbidden")
+}
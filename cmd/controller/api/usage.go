@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TenantHeader is the header with which a client (ex: a dashboard) names itself for the usage accounting
+const TenantHeader = "X-Purser-Tenant"
+
+// slowestRequestsKept is the number of slowest requests kept per consumer
+const slowestRequestsKept = 5
+
+// maxConsumers bounds the number of consumers tracked: beyond it the consumer seen least recently is forgotten, so
+// that clients giving a new tenant or token on every request cannot grow the accounting without bound.
+var maxConsumers = 1000
+
+// ConsumerUsage is the usage of the api by one consumer since the start of the controller. Durations are in
+// seconds and the cost of a request is measured by its duration and the size of its response.
+type ConsumerUsage struct {
+	Consumer        string          `json:"consumer"`
+	Requests        int             `json:"requests"`
+	Errors          int             `json:"errors"`
+	TotalDuration   float64         `json:"totalDuration"`
+	ResponseBytes   int64           `json:"responseBytes"`
+	FirstSeen       string          `json:"firstSeen"`
+	LastSeen        string          `json:"lastSeen"`
+	Routes          []RouteUsage    `json:"routes"`
+	SlowestRequests []RequestRecord `json:"slowestRequests"`
+}
+
+// RouteUsage is the usage of one route by a consumer
+type RouteUsage struct {
+	Route           string  `json:"route"`
+	Requests        int     `json:"requests"`
+	Errors          int     `json:"errors"`
+	TotalDuration   float64 `json:"totalDuration"`
+	AverageDuration float64 `json:"averageDuration"`
+	MaxDuration     float64 `json:"maxDuration"`
+	ResponseBytes   int64   `json:"responseBytes"`
+}
+
+// RequestRecord is a single request of a consumer
+type RequestRecord struct {
+	Route         string  `json:"route"`
+	URI           string  `json:"uri"`
+	Status        int     `json:"status"`
+	Duration      float64 `json:"duration"`
+	ResponseBytes int64   `json:"responseBytes"`
+	Time          string  `json:"time"`
+}
+
+type consumerStats struct {
+	firstSeen, lastSeen time.Time
+	routes              map[string]*RouteUsage
+	slowest             []RequestRecord
+}
+
+var (
+	usageMu   sync.Mutex
+	consumers = map[string]*consumerStats{}
+)
+
+type authenticationKey struct{}
+
+// authentication is marked by SSOScoper once the ID token of a request is verified
+type authentication struct {
+	verified bool
+}
+
+// markAuthenticated records that the caller of a request has been authenticated
+func markAuthenticated(r *http.Request) {
+	if auth, isPresent := r.Context().Value(authenticationKey{}).(*authentication); isPresent {
+		auth.verified = true
+	}
+}
+
+// isAuthenticated tells if the caller of a request has a verified ID token or the admin token
+func isAuthenticated(r *http.Request) bool {
+	if auth, isPresent := r.Context().Value(authenticationKey{}).(*authentication); isPresent && auth.verified {
+		return true
+	}
+	return hasAdminToken(r)
+}
+
+// usageRecorder captures the status and the size of a response
+type usageRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (u *usageRecorder) WriteHeader(status int) {
+	u.status = status
+	u.ResponseWriter.WriteHeader(status)
+}
+
+func (u *usageRecorder) Write(b []byte) (int, error) {
+	if u.status == 0 {
+		u.status = http.StatusOK
+	}
+	n, err := u.ResponseWriter.Write(b)
+	u.bytes += int64(n)
+	return n, err
+}
+
+// Accounting records the requests of every consumer of the api so that the consumers issuing expensive queries
+// can be found with GetConsumerUsage.
+func Accounting(inner http.Handler, name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &usageRecorder{ResponseWriter: w}
+		r = r.WithContext(context.WithValue(r.Context(), authenticationKey{}, &authentication{}))
+		inner.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		recordUsage(consumerOf(r), RequestRecord{
+			Route:         name,
+			URI:           r.URL.RequestURI(),
+			Status:        recorder.status,
+			Duration:      time.Since(start).Seconds(),
+			ResponseBytes: recorder.bytes,
+			Time:          start.UTC().Format(time.RFC3339),
+		}, start)
+	})
+}
+
+// consumerOf identifies the client of a request: by the tenant it gives if it is authenticated, else by a digest of
+// its bearer token (the token itself is never kept), else by its address. The tenant of an unauthenticated caller is
+// ignored as anyone could claim it.
+func consumerOf(r *http.Request) string {
+	if tenant := strings.TrimSpace(r.Header.Get(TenantHeader)); tenant != "" && isAuthenticated(r) {
+		return "tenant:" + tenant
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		digest := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+		return "token:" + hex.EncodeToString(digest[:])[:12]
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "address:" + host
+}
+
+func recordUsage(consumer string, request RequestRecord, at time.Time) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	stats, isPresent := consumers[consumer]
+	if !isPresent {
+		if len(consumers) >= maxConsumers {
+			evictLeastRecentConsumer()
+		}
+		stats = &consumerStats{firstSeen: at, routes: map[string]*RouteUsage{}}
+		consumers[consumer] = stats
+	}
+	stats.lastSeen = at
+
+	route, isPresent := stats.routes[request.Route]
+	if !isPresent {
+		route = &RouteUsage{Route: request.Route}
+		stats.routes[request.Route] = route
+	}
+	route.Requests++
+	if request.Status >= http.StatusBadRequest {
+		route.Errors++
+	}
+	route.TotalDuration += request.Duration
+	route.AverageDuration = route.TotalDuration / float64(route.Requests)
+	if request.Duration > route.MaxDuration {
+		route.MaxDuration = request.Duration
+	}
+	route.ResponseBytes += request.ResponseBytes
+
+	stats.slowest = append(stats.slowest, request)
+	sort.SliceStable(stats.slowest, func(i, j int) bool {
+		return stats.slowest[i].Duration > stats.slowest[j].Duration
+	})
+	if len(stats.slowest) > slowestRequestsKept {
+		stats.slowest = stats.slowest[:slowestRequestsKept]
+	}
+}
+
+// evictLeastRecentConsumer forgets the consumer seen least recently, usageMu must be held
+func evictLeastRecentConsumer() {
+	var oldest string
+	var oldestSeen time.Time
+	for consumer, stats := range consumers {
+		if oldest == "" || stats.lastSeen.Before(oldestSeen) {
+			oldest, oldestSeen = consumer, stats.lastSeen
+		}
+	}
+	delete(consumers, oldest)
+}
+
+// ConsumerUsages returns the usage of every consumer, the most expensive (total duration) first
+func ConsumerUsages() []ConsumerUsage {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+
+	result := []ConsumerUsage{}
+	for consumer, stats := range consumers {
+		usage := ConsumerUsage{
+			Consumer:        consumer,
+			FirstSeen:       stats.firstSeen.UTC().Format(time.RFC3339),
+			LastSeen:        stats.lastSeen.UTC().Format(time.RFC3339),
+			Routes:          []RouteUsage{},
+			SlowestRequests: append([]RequestRecord{}, stats.slowest...),
+		}
+		for _, route := range stats.routes {
+			usage.Requests += route.Requests
+			usage.Errors += route.Errors
+			usage.TotalDuration += route.TotalDuration
+			usage.ResponseBytes += route.ResponseBytes
+			usage.Routes = append(usage.Routes, *route)
+		}
+		sort.Slice(usage.Routes, func(i, j int) bool {
+			return usage.Routes[i].TotalDuration > usage.Routes[j].TotalDuration
+		})
+		result = append(result, usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].TotalDuration > result[j].TotalDuration
+	})
+	return result
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package api
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func resetUsage() {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	consumers = map[string]*consumerStats{}
+}
+
+func usageOf(consumer string) (ConsumerUsage, bool) {
+	for _, usage := range ConsumerUsages() {
+		if usage.Consumer == consumer {
+			return usage, true
+		}
+	}
+	return ConsumerUsage{}, false
+}
+
+func TestAccounting(t *testing.T) {
+	resetUsage()
+	defer resetUsage()
+
+	handler := Accounting(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		_, _ = w.Write([]byte("hello"))
+	}), "GetClusterHierarchy")
+	for _, uri := range []string{"/hierarchy", "/hierarchy?fail=1", "/hierarchy"} {
+		r := httptest.NewRequest(http.MethodGet, uri, nil)
+		r.RemoteAddr = "10.0.0.1:4242"
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	usage, isPresent := usageOf("address:10.0.0.1")
+	utils.Assert(t, isPresent, "consumer not accounted: %+v", ConsumerUsages())
+	utils.Equals(t, 3, usage.Requests)
+	utils.Equals(t, 1, usage.Errors)
+	utils.Equals(t, int64(15), usage.ResponseBytes)
+	utils.Equals(t, 1, len(usage.Routes))
+	utils.Equals(t, "GetClusterHierarchy", usage.Routes[0].Route)
+	utils.Equals(t, usage.Routes[0].TotalDuration/3, usage.Routes[0].AverageDuration)
+	utils.Equals(t, 3, len(usage.SlowestRequests))
+}
+
+func TestSlowestRequestsKept(t *testing.T) {
+	resetUsage()
+	defer resetUsage()
+
+	at := time.Now()
+	for i := 1; i <= 8; i++ {
+		recordUsage("token:abc", RequestRecord{Route: "GetPodCost", Status: http.StatusOK, Duration: float64(i)}, at)
+	}
+	usage, _ := usageOf("token:abc")
+	utils.Equals(t, 8, usage.Requests)
+	utils.Equals(t, slowestRequestsKept, len(usage.SlowestRequests))
+	utils.Equals(t, 8.0, usage.SlowestRequests[0].Duration)
+	utils.Equals(t, 4.0, usage.SlowestRequests[slowestRequestsKept-1].Duration)
+}
+
+func TestConsumersAreCapped(t *testing.T) {
+	resetUsage()
+	defer resetUsage()
+	defer func(max int) { maxConsumers = max }(maxConsumers)
+	maxConsumers = 2
+
+	at := time.Now()
+	recordUsage("tenant:a", RequestRecord{Route: "GetPodCost"}, at)
+	recordUsage("tenant:b", RequestRecord{Route: "GetPodCost"}, at.Add(time.Second))
+	// a is seen again so b becomes the least recent consumer
+	recordUsage("tenant:a", RequestRecord{Route: "GetPodCost"}, at.Add(2*time.Second))
+	recordUsage("tenant:c", RequestRecord{Route: "GetPodCost"}, at.Add(3*time.Second))
+
+	utils.Equals(t, 2, len(ConsumerUsages()))
+	_, isPresent := usageOf("tenant:b")
+	utils.Assert(t, !isPresent, "least recent consumer not evicted")
+	usage, isPresent := usageOf("tenant:a")
+	utils.Assert(t, isPresent, "recent consumer evicted")
+	utils.Equals(t, 2, usage.Requests)
+	_, isPresent = usageOf("tenant:c")
+	utils.Assert(t, isPresent, "new consumer not accounted")
+}
+
+func TestTenantOnlyForAuthenticatedCallers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage")
+	utils.Ok(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	utils.Ok(t, ioutil.WriteFile(tokenFile, []byte("secret\n"), 0600))
+	settings = Settings{AdminTokenFile: tokenFile}
+	defer func() { settings = Settings{} }()
+
+	request := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/hierarchy", nil)
+		r.RemoteAddr = "10.0.0.2:4242"
+		r.Header.Set(TenantHeader, "billing-dashboard")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	// verified marks the request as SSOScoper does once the ID token is verified
+	verified := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { markAuthenticated(r) })
+
+	tests := []struct {
+		name     string
+		handler  http.Handler
+		token    string
+		consumer string
+	}{
+		{"anonymous", inner, "", "address:10.0.0.2"},
+		{"unverified token", inner, "forged", "token:"},
+		{"admin token", inner, "secret", "tenant:billing-dashboard"},
+		{"verified ID token", verified, "id-token", "tenant:billing-dashboard"},
+	}
+	for _, test := range tests {
+		resetUsage()
+		Accounting(test.handler, "GetClusterHierarchy").ServeHTTP(httptest.NewRecorder(), request(test.token))
+		usages := ConsumerUsages()
+		utils.Equals(t, 1, len(usages))
+		consumer := usages[0].Consumer
+		if test.consumer == "token:" {
+			consumer = consumer[:len("token:")]
+		}
+		utils.Assert(t, consumer == test.consumer, "%s: consumer %q, want %q", test.name, usages[0].Consumer, test.consumer)
+	}
+	resetUsage()
+}
@@ -0,0 +1,238 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/export"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// maxWindowDays is the longest time window (query params from and to) accepted by the api
+const maxWindowDays = 366
+
+// resourceNameRegex matches the names of kubernetes resources and external costs
+var resourceNameRegex = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._:-]*[A-Za-z0-9])?$`)
+
+// InvalidParameter is the details of the error returned for a request having an invalid query param
+type InvalidParameter struct {
+	Parameter string `json:"parameter"`
+	Value     string `json:"value"`
+	Message   string `json:"message"`
+}
+
+func (e *InvalidParameter) Error() string {
+	return fmt.Sprintf("invalid %s: %q, %s", e.Parameter, e.Value, e.Message)
+}
+
+// paramValidators validate the values of the query params shared by the api endpoints
+var paramValidators = map[string]func(value string) error{
+	query.Name:      validateResourceName,
+	query.Namespace: validateNamespace,
+	query.Group:     validateGroupName,
+	query.From:      validateDate,
+	query.To:        validateDate,
+	query.Time:      validateTime,
+	query.Since:     validateTime,
+	query.Month:     validateMonth,
+	query.Limit:     validateLimit,
+	query.Orphan:    oneOf("true", query.False),
+	query.View:      oneOf(query.Physical, query.Logical, query.Environment, query.Application, query.Namespace, query.Group),
+	query.Type:      oneOf(query.Namespace, "pod"),
+	query.Format:    oneOf("json", query.HTML, query.YAML, export.CSV, export.JSONLines, export.Parquet),
+	query.Push:      oneOf("true", query.False),
+	query.Reason:    oneOf(models.FailedScheduling, models.Evicted, models.NodeNotReady, models.BackOff),
+	query.GroupBy:   oneOf(query.Instance, query.Name, query.PartOf, query.Release, query.Chart, query.Namespace, query.Label),
+	query.Label:     validateLabelKey,
+	query.Overhead:  oneOf(query.Distribute),
+	query.Component: oneOf(query.KubeletComponent, query.OSComponent),
+	query.Days:      validateDays,
+	query.After:     validateRecordCursor,
+	query.Period:    oneOf(query.Daily, query.Weekly, query.Monthly),
+	query.Workload:  validateWorkload,
+	query.Value:     validateLabelValue,
+}
+
+// Validator rejects the requests having invalid query params with status 400 before they reach the inner handler
+func Validator(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if invalid := validateParams(r.URL.Query()); invalid != nil {
+			writeError(&w, r, apierrors.New(apierrors.InvalidParameter, invalid.Error()).WithDetails(invalid))
+			return
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+func validateParams(queryParams url.Values) *InvalidParameter {
+	for param, values := range queryParams {
+		validate, isKnown := paramValidators[param]
+		if !isKnown {
+			continue
+		}
+		for _, value := range values {
+			// empty values are handled as missing params by the handlers
+			if value == "" {
+				continue
+			}
+			if err := validate(value); err != nil {
+				return &InvalidParameter{Parameter: param, Value: value, Message: err.Error()}
+			}
+		}
+	}
+	return validateWindow(queryParams)
+}
+
+// validateWindow checks that the time window given by from and to is not empty and not longer than maxWindowDays
+func validateWindow(queryParams url.Values) *InvalidParameter {
+	fromParam, toParam := queryParams.Get(query.From), queryParams.Get(query.To)
+	if fromParam == "" || toParam == "" {
+		return nil
+	}
+	from, _ := time.ParseInLocation(query.DateFormat, fromParam, time.Local)
+	to, _ := time.ParseInLocation(query.DateFormat, toParam, time.Local)
+	if to.Before(from) {
+		return &InvalidParameter{Parameter: query.To, Value: toParam, Message: "must not be before from: " + fromParam}
+	}
+	if to.Sub(from) > maxWindowDays*24*time.Hour {
+		return &InvalidParameter{Parameter: query.To, Value: toParam, Message: fmt.Sprintf("window is longer than %d days", maxWindowDays)}
+	}
+	return nil
+}
+
+// validateExternalCostItem checks the name of the external cost along with the namespace or group it is attributed to
+func validateExternalCostItem(item models.ExternalCostItem) *InvalidParameter {
+	fields := []struct {
+		param, value string
+		validate     func(value string) error
+	}{
+		{query.Name, item.Name, validateResourceName},
+		{query.Namespace, item.Namespace, validateNamespace},
+		{query.Group, item.Group, validateGroupName},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		if err := field.validate(field.value); err != nil {
+			return &InvalidParameter{Parameter: field.param, Value: field.value, Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+func validateResourceName(value string) error {
+	if len(value) > validation.DNS1123SubdomainMaxLength || !resourceNameRegex.MatchString(value) {
+		return fmt.Errorf("must be at most %d alphanumeric characters, '-', '_', '.' or ':', starting and ending with an alphanumeric character",
+			validation.DNS1123SubdomainMaxLength)
+	}
+	return nil
+}
+
+func validateNamespace(value string) error {
+	return validationErrors(validation.IsDNS1123Label(value))
+}
+
+func validateLabelKey(value string) error {
+	return validationErrors(validation.IsQualifiedName(value))
+}
+
+func validateLabelValue(value string) error {
+	return validationErrors(validation.IsValidLabelValue(value))
+}
+
+// validateWorkload checks the workload of a cost trend: <kind>/<namespace>:<name>
+func validateWorkload(value string) error {
+	_, _, err := query.ParseWorkload(value)
+	return err
+}
+
+// validateRecordCursor checks the cursor of a page of allocation records: <pod uid>/<unix hour>
+func validateRecordCursor(value string) error {
+	_, _, err := query.ParseRecordCursor(value)
+	return err
+}
+
+func validateGroupName(value string) error {
+	return validationErrors(validation.IsDNS1123Subdomain(value))
+}
+
+func validationErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, ", "))
+}
+
+func validateDate(value string) error {
+	if _, err := time.ParseInLocation(query.DateFormat, value, time.Local); err != nil {
+		return fmt.Errorf("must be a date in the format %s", query.DateFormat)
+	}
+	return nil
+}
+
+func validateTime(value string) error {
+	if _, err := time.Parse(time.RFC3339, value); err != nil {
+		return fmt.Errorf("must be a time in RFC3339 format")
+	}
+	return nil
+}
+
+func validateMonth(value string) error {
+	if _, err := time.ParseInLocation(query.MonthFormat, value, time.Local); err != nil {
+		return fmt.Errorf("must be a month in the format %s", query.MonthFormat)
+	}
+	return nil
+}
+
+func validateLimit(value string) error {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > query.MaxLimit {
+		return fmt.Errorf("must be an integer between 1 and %d", query.MaxLimit)
+	}
+	return nil
+}
+
+func validateDays(value string) error {
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 || days > query.MaxDays {
+		return fmt.Errorf("must be an integer between 1 and %d", query.MaxDays)
+	}
+	return nil
+}
+
+func oneOf(allowed ...string) func(value string) error {
+	return func(value string) error {
+		for _, a := range allowed {
+			if value == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/apierrors"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestValidateParams(t *testing.T) {
+	tests := []struct {
+		query     string
+		parameter string
+	}{
+		{"namespace=kube-system&from=2018-11-01&to=2018-11-30&limit=20&format=csv", ""},
+		{"namespace=", ""},
+		{"unknown=%7B%7D", ""},
+		{"label=app.kubernetes.io/name&value=web", ""},
+		{"namespace=Kube_System", "namespace"},
+		{"namespace=shop%22)%20%7B%20uid%20%7D", "namespace"},
+		{"name=-web", "name"},
+		{"group=team.a&group=team%20b", "group"},
+		{"label=app%20name", "label"},
+		{"from=2018-11-31", "from"},
+		{"time=2018-11-01", "time"},
+		{"month=2018-13", "month"},
+		{"limit=0", "limit"},
+		{"limit=1001", "limit"},
+		{"days=91", "days"},
+		{"format=xml", "format"},
+		{"from=2018-11-30&to=2018-11-01", "to"},
+		{"from=2017-11-01&to=2018-11-03", "to"},
+	}
+	for _, test := range tests {
+		queryParams, err := url.ParseQuery(test.query)
+		utils.Ok(t, err)
+		invalid := validateParams(queryParams)
+		if test.parameter == "" {
+			utils.Assert(t, invalid == nil, "%s: valid params rejected: %v", test.query, invalid)
+			continue
+		}
+		utils.Assert(t, invalid != nil && invalid.Parameter == test.parameter, "%s: invalid %s accepted: %v",
+			test.query, test.parameter, invalid)
+	}
+}
+
+func TestValidateExternalCostItem(t *testing.T) {
+	utils.Assert(t, validateExternalCostItem(models.ExternalCostItem{Name: "datadog", Namespace: "shop"}) == nil,
+		"valid external cost rejected")
+	invalid := validateExternalCostItem(models.ExternalCostItem{Name: "datadog", Group: "Team A"})
+	utils.Assert(t, invalid != nil && invalid.Parameter == "group" && invalid.Value == "Team A", "invalid group accepted: %v", invalid)
+}
+
+func TestValidator(t *testing.T) {
+	handled := false
+	handler := Validator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces?limit=abc", nil))
+	utils.Equals(t, http.StatusBadRequest, w.Code)
+	utils.Assert(t, !handled, "request with invalid params handled")
+	body := struct {
+		Code    string           `json:"code"`
+		Details InvalidParameter `json:"details"`
+	}{}
+	utils.Ok(t, json.Unmarshal(w.Body.Bytes(), &body))
+	utils.Equals(t, apierrors.InvalidParameter, body.Code)
+	utils.Equals(t, InvalidParameter{Parameter: "limit", Value: "abc", Message: "must be an integer between 1 and 1000"},
+		body.Details)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/namespaces?limit=5", nil))
+	utils.Assert(t, handled, "request with valid params not handled")
+}
@@ -51,6 +51,7 @@ func Setup(conf *controller.Config, kubeconfig string) {
 		Job:                   true,
 		Service:               true,
 		Namespace:             true,
+		Event:                 true,
 		Group:                 true,
 		Subscriber:            true,
 	}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/vmware/purser/cmd/controller/api"
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/aggregation"
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/budget"
+	"github.com/vmware/purser/pkg/controller/capacity"
+	"github.com/vmware/purser/pkg/controller/costcenter"
+	"github.com/vmware/purser/pkg/controller/costhistory"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/discovery/hubble"
+	"github.com/vmware/purser/pkg/controller/eventprocessor"
+	"github.com/vmware/purser/pkg/controller/export"
+	"github.com/vmware/purser/pkg/controller/flowlogs"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/schedule"
+	"github.com/vmware/purser/pkg/controller/sharding"
+	"github.com/vmware/purser/pkg/controller/sustainability"
+	"github.com/vmware/purser/pkg/controller/ticket"
+	"github.com/vmware/purser/pkg/controller/tsdb"
+)
+
+// Settings are the controller settings which are read from the yaml/json settings file.
+type Settings struct {
+	Environments    []models.EnvironmentRule       `json:"environments,omitempty"`
+	Attribution     models.AttributionSettings     `json:"attribution,omitempty"`
+	ClusterOverhead models.ClusterOverheadSettings `json:"clusterOverhead,omitempty"`
+	Pricing         pricing.Settings               `json:"pricing,omitempty"`
+	Allocation      allocation.Settings            `json:"allocation,omitempty"`
+
+	// MetricsChangeThreshold is the relative change (ex: 0.05 for 5%) of the requests or limits of a pod
+	// beyond which they are rewritten on the update of the pod, and of a container beyond which a new metrics
+	// sample is stored.
+	MetricsChangeThreshold *float64 `json:"metricsChangeThreshold,omitempty"`
+
+	ShortLivedPods eventprocessor.ShortLivedPodSettings `json:"shortLivedPods,omitempty"`
+	Capacity       capacity.Settings                    `json:"capacity,omitempty"`
+	Sustainability sustainability.Settings              `json:"sustainability,omitempty"`
+	Invoices       invoice.Settings                     `json:"invoices,omitempty"`
+	Export         export.Settings                      `json:"export,omitempty"`
+	FlowLogs       flowlogs.Settings                    `json:"flowLogs,omitempty"`
+	Hubble         hubble.Settings                      `json:"hubble,omitempty"`
+	CostCenters    costcenter.Settings                  `json:"costCenters,omitempty"`
+	TSDB           tsdb.Settings                        `json:"tsdb,omitempty"`
+	CostHistory    costhistory.Settings                 `json:"costHistory,omitempty"`
+	Notifiers      notifier.Settings                    `json:"notifiers,omitempty"`
+	Budgets        []budget.Budget                      `json:"budgets,omitempty"`
+	Tickets        ticket.Settings                      `json:"tickets,omitempty"`
+	Sharding       sharding.Settings                    `json:"sharding,omitempty"`
+	API            api.Settings                         `json:"api,omitempty"`
+
+	CostAnnotations    controller.CostAnnotationSettings     `json:"costAnnotations,omitempty"`
+	NamespaceFinalizer controller.NamespaceFinalizerSettings `json:"namespaceFinalizer,omitempty"`
+	InactiveWorkloads  controller.InactiveWorkloadSettings   `json:"inactiveWorkloads,omitempty"`
+	Schedules          []schedule.Policy                     `json:"schedules,omitempty"`
+	Audit              aggregation.AuditSettings             `json:"audit,omitempty"`
+	Retention          dgraph.RetentionSettings              `json:"retention,omitempty"`
+	Batching           dgraph.BatchSettings                  `json:"batching,omitempty"`
+	Retries            dgraph.RetrySettings                  `json:"retries,omitempty"`
+}
+
+// LoadSettings reads the settings file from the given path. Empty path gives default settings.
+func LoadSettings(path string) (*Settings, error) {
+	settings := &Settings{}
+	if path == "" {
+		return settings, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	err = yaml.Unmarshal(data, settings)
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -18,7 +18,12 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -27,9 +32,32 @@ import (
 	"github.com/vmware/purser/cmd/controller/api"
 	"github.com/vmware/purser/cmd/controller/config"
 	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/aggregation"
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/budget"
+	"github.com/vmware/purser/pkg/controller/buffering"
+	"github.com/vmware/purser/pkg/controller/capacity"
+	"github.com/vmware/purser/pkg/controller/costcenter"
+	"github.com/vmware/purser/pkg/controller/costhistory"
 	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/discovery/hubble"
 	"github.com/vmware/purser/pkg/controller/discovery/processor"
 	"github.com/vmware/purser/pkg/controller/eventprocessor"
+	"github.com/vmware/purser/pkg/controller/export"
+	"github.com/vmware/purser/pkg/controller/flowlogs"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/memory"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/prometheus"
+	"github.com/vmware/purser/pkg/controller/schedule"
+	"github.com/vmware/purser/pkg/controller/sharding"
+	"github.com/vmware/purser/pkg/controller/supervisor"
+	"github.com/vmware/purser/pkg/controller/sustainability"
+	"github.com/vmware/purser/pkg/controller/ticket"
+	"github.com/vmware/purser/pkg/controller/tsdb"
 	"github.com/vmware/purser/pkg/utils"
 )
 
@@ -40,48 +68,309 @@ const InClusterConfigPath = ""
 
 var interactions *string
 
+var pricingSyncInterval string
+
+var tsdbPushInterval string
+
+var costSnapshotInterval string
+
+var gracePeriod *time.Duration
+
 func init() {
 	logLevel := flag.String("log", "info", "set log level as info or debug")
 	dgraphURL := flag.String("dgraphURL", "purser-db", "dgraph zero url")
 	dgraphPort := flag.String("dgraphPort", "9080", "dgraph zero port")
+	dgraphPrefix := flag.String("dgraphPrefix", "", "prefix of the predicates of purser (ex: purser) to share a dgraph cluster with other applications")
+	dgraphReplicas := flag.String("dgraphReplicas", "", "comma separated host:port of dgraph alpha replicas serving the api queries (ex: purser-db-1:9080)")
 	interactions = flag.String("interactions", "disable", "enable discovery of interactions")
 	kubeconfig := flag.String("kubeconfig", InClusterConfigPath, "path to the kubeconfig file")
+	settingsFile := flag.String("config", "", "path to the yaml/json settings file")
+	inheritLabels := flag.String("inheritLabels", "", "comma separated namespace label/annotation keys inherited by pods (ex: team,env)")
+	gracePeriod = flag.Duration("gracePeriod", 30*time.Second, "time given to drain queued events and requests in progress on shutdown")
+	maxMemory := flag.Uint64("maxMemory", 0, "memory limit in MB, caches are pruned and non essential work is skipped near the limit (0 for no limit)")
 	flag.Parse()
 
 	utils.InitializeLogger(*logLevel)
 	config.Setup(&conf, *kubeconfig)
+	memory.Setup(*maxMemory)
+	conf.RingBuffer.Size = memory.BufferSize(buffering.BufferSize)
+	prometheus.RegisterQueue("events", func() int {
+		return int(conf.RingBuffer.Len())
+	})
+	prometheus.RegisterQueue("dgraph-writes", dgraph.BufferedWrites)
+	if err := dgraph.SetPredicatePrefix(*dgraphPrefix); err != nil {
+		log.Fatal(err)
+	}
 	dgraph.Start(*dgraphURL, *dgraphPort)
+	dgraph.OpenReplicas(strings.Split(*dgraphReplicas, ","))
+	models.SetInheritedLabelKeys(strings.Split(*inheritLabels, ","))
+
+	settings, err := config.LoadSettings(*settingsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	models.SetEnvironmentRules(settings.Environments)
+	models.SetAttributionSettings(settings.Attribution)
+	models.SetClusterOverheadSettings(settings.ClusterOverhead)
+	pricingSyncInterval = pricing.Setup(settings.Pricing)
+	pricing.SetHistoryStore(models.PriceHistoryStore{})
+	allocation.Setup(settings.Allocation)
+	if settings.MetricsChangeThreshold != nil {
+		models.SetMetricsChangeThreshold(*settings.MetricsChangeThreshold)
+	}
+	eventprocessor.SetupShortLivedPods(settings.ShortLivedPods)
+	capacity.Setup(settings.Capacity)
+	sustainability.Setup(settings.Sustainability)
+	invoice.Setup(settings.Invoices)
+	export.Setup(settings.Export)
+	flowlogs.Setup(settings.FlowLogs, conf.Kubeclient)
+	hubble.Setup(settings.Hubble)
+	costcenter.Setup(settings.CostCenters)
+	tsdbPushInterval = tsdb.Setup(settings.TSDB)
+	costSnapshotInterval = costhistory.Setup(settings.CostHistory)
+	controller.SetupCostAnnotations(settings.CostAnnotations)
+	controller.SetupNamespaceFinalizer(settings.NamespaceFinalizer)
+	controller.SetupInactiveWorkloads(settings.InactiveWorkloads)
+	notifier.Setup(settings.Notifiers)
+	budget.Setup(settings.Budgets)
+	ticket.Setup(settings.Tickets)
+	sharding.Setup(settings.Sharding, conf.Kubeclient)
+	schedule.Setup(settings.Schedules, conf.Kubeclient)
+	aggregation.SetupAudit(settings.Audit)
+	dgraph.SetupRetention(settings.Retention, query.NodeMarkers())
+	dgraph.SetupBatching(settings.Batching)
+	dgraph.SetupRetries(settings.Retries)
+	api.Setup(settings.API)
 }
 
 func main() {
-	go api.StartServer()
-	go eventprocessor.ProcessEvents(&conf)
+	ctx, stop := context.WithCancel(context.Background())
+	processingCtx, stopProcessing := context.WithCancel(context.Background())
+	dgraphCtx, abortDgraph := context.WithCancel(context.Background())
+	dgraph.SetContext(dgraphCtx)
+	batchingCtx, stopBatching := context.WithCancel(context.Background())
+	batchingStopped := make(chan struct{})
+	go func() {
+		dgraph.RunBatching(batchingCtx)
+		close(batchingStopped)
+	}()
+	retriesCtx, stopRetries := context.WithCancel(context.Background())
+	retriesStopped := make(chan struct{})
+	go func() {
+		dgraph.RunRetries(retriesCtx)
+		close(retriesStopped)
+	}()
+
+	sharding.Start(ctx)
+	memory.Start(ctx)
+
+	serverStopped := make(chan struct{})
+	go func() {
+		api.StartServer(ctx, *gracePeriod)
+		close(serverStopped)
+	}()
+	processingStopped := make(chan struct{})
+	go func() {
+		supervisor.Run(processingCtx, "event-processor", func(ctx context.Context) {
+			eventprocessor.ProcessEvents(ctx, &conf)
+		})
+		close(processingStopped)
+	}()
+	go startPeriodicJobs(ctx)
 
 	if *interactions == "enable" {
-		go startInteractionsDiscovery()
+		go supervisor.Run(ctx, "hubble-flows", hubble.Watch)
+		go startInteractionsDiscovery(ctx)
 	}
 
-	controller.Start(&conf)
+	go func() {
+		sigterm := make(chan os.Signal, 1)
+		signal.Notify(sigterm, syscall.SIGTERM, syscall.SIGINT)
+		<-sigterm
+		log.Infof("shutting down, grace period: %v", *gracePeriod)
+		stop()
+		// dgraph queries and mutations still running after the grace period are aborted
+		time.AfterFunc(*gracePeriod, abortDgraph)
+	}()
+
+	controller.Start(ctx, &conf)
+	sharding.Release()
+	// the controllers handed over their queued events, the event buffer and pending pods can be flushed
+	stopProcessing()
+	<-processingStopped
+	<-serverStopped
+	// the mutations queued by the event processor are flushed before the dgraph connection is closed
+	stopBatching()
+	<-batchingStopped
+	// the writes buffered while dgraph was unreachable are tried once more
+	stopRetries()
+	<-retriesStopped
+	abortDgraph()
+	dgraph.Close()
+	log.Info("purser controller stopped")
 }
 
-// starts first discovery after 5 min of controller starting. Next runs will occur in every 59 min
-func startInteractionsDiscovery() {
-	time.Sleep(time.Minute * 5)
-	runDiscovery()
+// starts first discovery after 5 min of controller starting. Next runs will occur in every 59 min. Inactive resources
+// are cleaned up and unreferenced labels are collected daily.
+func startInteractionsDiscovery(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(time.Minute * 5):
+	}
+	leaderOnly("interactions-discovery", runDiscovery)()
 
 	c := cron.New()
-	err := c.AddFunc("@every 0h59m", runDiscovery)
+	err := c.AddFunc("@every 0h59m", leaderOnly("interactions-discovery", runDiscovery))
 	if err != nil {
 		log.Fatal(err)
 	}
-	err = c.AddFunc("@daily", dgraph.RemoveResourcesInactiveInCurrentMonth)
+	err = c.AddFunc("@daily", leaderOnly("inactive-resources-cleanup", dgraph.RemoveResourcesInactiveInCurrentMonth))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("@daily", leaderOnly("labels-collection", models.CollectLabels))
 	if err != nil {
 		log.Error(err)
 	}
 	c.Start()
+	<-ctx.Done()
+	c.Stop()
+}
+
+// refreshes the pricing catalog and probes the metrics api on controller start, then runs the periodic jobs until ctx
+// is done. Cluster wide jobs run on the first shard only, the others cover the namespaces of the shard.
+func startPeriodicJobs(ctx context.Context) {
+	pricing.Sync()
+	controller.ProbeMetricsSource()
+
+	c := cron.New()
+	// every replica refreshes the pricing catalog it computes costs with
+	err := c.AddFunc("@every "+pricingSyncInterval, supervisor.Recover("pricing-sync", pricing.Sync))
+	if err != nil {
+		log.Error(err)
+	}
+	// daily cost summaries of the previous day are computed and sealed
+	err = c.AddFunc("@daily", leaderOnly("nightly-aggregation", aggregation.RunNightlyAggregation))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("@daily", leaderOnly("metrics-compaction", models.CompactMetricSamples))
+	if err != nil {
+		log.Error(err)
+	}
+	// the cost allocation of the previous day is exported to warehouses once the summaries are computed
+	err = c.AddFunc("0 30 0 * * *", leaderOnly("daily-export", export.RunDailyExport))
+	if err != nil {
+		log.Error(err)
+	}
+	// invoices of the previous month are generated on the first day of the month
+	err = c.AddFunc("@monthly", leaderOnly("monthly-invoices", invoice.RunMonthlyInvoices))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("@hourly", leaderOnly("budget-check", budget.CheckBudgets))
+	if err != nil {
+		log.Error(err)
+	}
+	// tickets are filed for the new savings opportunities
+	err = c.AddFunc("@daily", leaderOnly("savings-tickets", ticket.FileSavingsTickets))
+	if err != nil {
+		log.Error(err)
+	}
+	// pod overhead and ephemeral containers are read from the raw pods of the shard
+	err = c.AddFunc("@every 5m", supervisor.Recover("raw-pods-scan", controller.ScanRawPods))
+	if err != nil {
+		log.Error(err)
+	}
+	// operators installed by OLM
+	err = c.AddFunc("@every 15m", leaderOnly("operators-sync", controller.SyncOperators))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("@every 15m", supervisor.Recover("volume-snapshots-scan", controller.ScanVolumeSnapshots))
+	if err != nil {
+		log.Error(err)
+	}
+	// the storage used by the pvcs of the shard is read from the kubelets
+	err = c.AddFunc("@every 15m", supervisor.Recover("volume-usage-scan", controller.ScanVolumeUsage))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("@every 15m", supervisor.Recover("network-policies-scan", controller.ScanNetworkPolicies))
+	if err != nil {
+		log.Error(err)
+	}
+	// config maps, secrets and custom resources of the namespaces of the shard are counted
+	err = c.AddFunc("@hourly", supervisor.Recover("object-counts-scan", controller.ScanObjectCounts))
+	if err != nil {
+		log.Error(err)
+	}
+	// new VPC flow log files are ingested
+	err = c.AddFunc("@every 10m", leaderOnly("flow-logs-ingest", flowlogs.Ingest))
+	if err != nil {
+		log.Error(err)
+	}
+	// the cost center hierarchy is synced from the configured directory
+	err = c.AddFunc("@hourly", leaderOnly("cost-centers-ldap-sync", costcenter.SyncLDAP))
+	if err != nil {
+		log.Error(err)
+	}
+	// cost rates are pushed to the configured time series databases
+	err = c.AddFunc("@every "+tsdbPushInterval, leaderOnly("tsdb-push", tsdb.Push))
+	if err != nil {
+		log.Error(err)
+	}
+	// the cost of the pods is snapshotted every period of the cost history (hourly by default)
+	err = c.AddFunc("@every "+costSnapshotInterval, leaderOnly("cost-snapshots", costhistory.Snapshot))
+	if err != nil {
+		log.Error(err)
+	}
+	// the trailing 30 days cost annotations of the workloads of the shard are updated
+	err = c.AddFunc("@hourly", supervisor.Recover("cost-annotations", controller.ReconcileCostAnnotations))
+	if err != nil {
+		log.Error(err)
+	}
+	// the cpu activity and usage heatmaps of the deployments of the shard are sampled
+	err = c.AddFunc("@every 15m", supervisor.Recover("workload-activity", controller.SampleWorkloadActivity))
+	if err != nil {
+		log.Error(err)
+	}
+	err = c.AddFunc("0 0 9 * * *", leaderOnly("inactive-deployments", controller.ReportInactiveDeployments))
+	if err != nil {
+		log.Error(err)
+	}
+	// schedule policies are enforced on the workloads of the shard
+	err = c.AddFunc("@every 5m", supervisor.Recover("schedule-enforcement", schedule.Enforce))
+	if err != nil {
+		log.Error(err)
+	}
+	// every replica deletes the expired report jobs it serves
+	err = c.AddFunc("@hourly", supervisor.Recover("report-jobs-prune", export.PruneReports))
+	if err != nil {
+		log.Error(err)
+	}
+	c.Start()
+	<-ctx.Done()
+	c.Stop()
 }
 
 func runDiscovery() {
+	if memory.IsDegraded() {
+		log.Warn("skipping discovery of interactions, controller memory is close to the limit")
+		return
+	}
 	processor.ProcessPodInteractions(conf)
 	processor.ProcessServiceInteractions(conf)
 }
+
+// leaderOnly wraps a cluster wide job so that it runs once per cluster when the controller is sharded.
+// A panic of the job is recovered and counted in the status of the job.
+func leaderOnly(name string, job func()) func() {
+	supervised := supervisor.Recover(name, job)
+	return func() {
+		if sharding.IsLeader() {
+			supervised()
+		}
+	}
+}
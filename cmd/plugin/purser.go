@@ -45,7 +45,7 @@ var (
 
 	description   = fmt.Sprintf("Purser gives cost insights of kubernetes deployments.\n\n")
 	usage         = fmt.Sprintf("Usage:\n  kubectl plugin purser [options] <command> <args>\n\n")
-	supportedCmds = fmt.Sprintf("The supported commands are:\n  get  Get resource information.\n  set  Set resource information.\n\n")
+	supportedCmds = fmt.Sprintf("The supported commands are:\n  get  Get resource information.\n  set  Set resource information.\n  recompute  Recompute cost information.\n\n")
 
 	optionHelp       = fmt.Sprintf("\n  --info            Show more details about the plugin.")
 	optionKubeConfig = fmt.Sprintf("\n  --kubeconfig      Absolute path for the kube config file.")
@@ -116,6 +116,10 @@ func main() {
 	inputs := os.Args[2:] // index 1 is empty
 	if len(inputs) == 4 && inputs[0] == Get {
 		computeMetricInsight(inputs)
+	} else if len(inputs) == 4 && inputs[0] == Recompute && inputs[1] == Cost {
+		plugin.RecomputeCosts(inputs[2], inputs[3])
+	} else if len(inputs) >= 4 && inputs[0] == Chargeback {
+		computeChargeback(inputs[1:])
 	} else if len(inputs) == 2 {
 		computeStats(inputs)
 	} else {
@@ -123,6 +127,30 @@ func main() {
 	}
 }
 
+// computeChargeback prints the chargeback report of the inputs: namespace or label <key>, the window from and to
+// (format: 2006-01-02) and the format json or csv (default: csv)
+func computeChargeback(inputs []string) {
+	label := ""
+	switch inputs[0] {
+	case Namespace:
+		inputs = inputs[1:]
+	case Label:
+		label, inputs = inputs[1], inputs[2:]
+	default:
+		printHelp()
+		return
+	}
+	if len(inputs) < 2 || len(inputs) > 3 {
+		printHelp()
+		return
+	}
+	format := "csv"
+	if len(inputs) == 3 {
+		format = inputs[2]
+	}
+	plugin.GetChargeback(label, inputs[0], inputs[1], format)
+}
+
 func computeMetricInsight(inputs []string) {
 	switch inputs[1] {
 	case Cost:
@@ -249,6 +277,9 @@ func printHelp() {
 	fmt.Println(pluginExt + "set user-costs")
 	fmt.Println(pluginExt + "get user-costs")
 	fmt.Println(pluginExt + "get savings")
+	fmt.Println(pluginExt + "recompute cost <from yyyy-mm-dd> <to yyyy-mm-dd>")
+	fmt.Println(pluginExt + "chargeback namespace <from yyyy-mm-dd> <to yyyy-mm-dd> [csv|json]")
+	fmt.Println(pluginExt + "chargeback label <key> <from yyyy-mm-dd> <to yyyy-mm-dd> [csv|json]")
 }
 
 func logError(err error) {
@@ -19,8 +19,10 @@ package main
 
 // These are possible actions for resources
 const (
-	Get = "get"
-	Set = "set"
+	Get        = "get"
+	Set        = "set"
+	Recompute  = "recompute"
+	Chargeback = "chargeback"
 )
 
 // These are kubernetes components
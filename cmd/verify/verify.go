@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command verify allocates the costs of the canned cluster states of the fixtures package (and of the cluster states
+// given with --clusters) with the pricing, allocation and policy settings of the controller, and compares them with the expected
+// allocations. Record the expected allocations with --update on a known good version, then run it after an upgrade
+// or a change of the settings:
+//
+//	verify --config settings.yaml --expected ./expected --update
+//	verify --config settings.yaml --expected ./expected
+//
+// Without --dgraph only the cost model of the fixtures package is verified, not the Dgraph queries of the api. With
+// --dgraph (ex: localhost:9080) the clusters are stored in that Dgraph, which should be a scratch one, and allocated
+// by the queries of the namespace costs api.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/cmd/controller/config"
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/fixtures"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+func main() {
+	settingsFile := flag.String("config", "", "path to the yaml/json settings file of the controller")
+	expectedDir := flag.String("expected", "expected", "directory of the expected allocations")
+	clustersDir := flag.String("clusters", "", "directory of additional cluster states in json format")
+	update := flag.Bool("update", false, "write the allocations as the expected ones instead of comparing them")
+	dgraphURL := flag.String("dgraph", "", "host:port of a scratch Dgraph to allocate the clusters with the api queries")
+	flag.Parse()
+
+	settings, err := config.LoadSettings(*settingsFile)
+	if err != nil {
+		log.Fatalf("unable to load settings file: (%s), error: (%v)", *settingsFile, err)
+	}
+	pricing.Setup(settings.Pricing)
+	allocation.Setup(settings.Allocation)
+	invoice.Setup(settings.Invoices)
+
+	clusters := fixtures.Clusters()
+	if *clustersDir != "" {
+		loaded, err := fixtures.LoadClusters(*clustersDir)
+		if err != nil {
+			log.Fatalf("unable to load cluster states, error: (%v)", err)
+		}
+		clusters = append(clusters, loaded...)
+	}
+
+	allocate := fixtures.Allocate
+	if *dgraphURL != "" {
+		if err = dgraph.Open(*dgraphURL); err != nil {
+			log.Fatalf("unable to connect to Dgraph: (%s), error: (%v)", *dgraphURL, err)
+		}
+		allocate = fixtures.StoredAllocator(fmt.Sprintf("verify%d", time.Now().Unix()))
+	}
+
+	mismatches, err := fixtures.Verify(clusters, *expectedDir, *update, allocate)
+	if err != nil {
+		log.Fatalf("unable to verify allocations, error: (%v)", err)
+	}
+	if *update {
+		fmt.Printf("expected allocations of %d clusters written to %s\n", len(clusters), *expectedDir)
+		return
+	}
+	for _, mismatch := range mismatches {
+		fmt.Println(mismatch)
+	}
+	if len(mismatches) > 0 {
+		fmt.Printf("%d mismatches in the allocations of %d clusters\n", len(mismatches), len(clusters))
+		os.Exit(1)
+	}
+	fmt.Printf("allocations of %d clusters are as expected\n", len(clusters))
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// GetDayStart returns the start of the day of the given time
+func GetDayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// RunNightlyAggregation computes the cost summaries of the previous day and seals them. It is scheduled to run
+// every night.
+func RunNightlyAggregation() {
+	yesterday := GetDayStart(time.Now()).AddDate(0, 0, -1)
+	if err := ComputeDailyCosts(yesterday); err != nil {
+		log.Errorf("unable to compute daily costs for day: (%s), error: (%v)", yesterday.Format(dateFormat), err)
+		return
+	}
+	if err := SealDay(yesterday); err != nil {
+		log.Errorf("unable to seal daily costs of day: (%s), error: (%v)", yesterday.Format(dateFormat), err)
+	}
+}
+
+// ComputeDailyCosts computes the cost of every namespace and group for the day of the given time
+// and stores them as cost summary nodes. Existing summaries of the day are overwritten.
+func ComputeDailyCosts(day time.Time) error {
+	from := GetDayStart(day)
+	to := from.AddDate(0, 0, 1)
+	priceVersion := pricing.GetCatalog().Version
+
+	namespaceCosts, err := query.RetrieveNamespaceCostsInWindow(query.All, from, to)
+	if err != nil {
+		return err
+	}
+	for _, namespaceCost := range namespaceCosts {
+		if namespaceCost.Xid == "" {
+			continue
+		}
+		summary := newCostSummary(models.NamespaceOwner, namespaceCost.Xid, from, namespaceCost, priceVersion)
+		namespaceUID := models.CreateOrGetNamespaceByID(namespaceCost.Xid)
+		if namespaceUID != "" {
+			summary.Namespace = &models.Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: namespaceCost.Xid}}
+		}
+		if _, err = models.StoreCostSummary(summary); err != nil {
+			return err
+		}
+	}
+
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		groupCost, err := query.RetrieveLabelsCostInWindow(group.GetLabelsMap(), from, to)
+		if err != nil {
+			return err
+		}
+		summary := newCostSummary(models.GroupOwner, group.Xid, from, groupCost, priceVersion)
+		summary.Group = &models.GroupCRD{ID: dgraph.ID{UID: group.UID, Xid: group.Xid}}
+		if _, err = models.StoreCostSummary(summary); err != nil {
+			return err
+		}
+	}
+	log.Debugf("computed daily costs of %d namespaces and %d groups for day: (%s)", len(namespaceCosts), len(groups), from.Format(dateFormat))
+	return nil
+}
+
+// RetrieveDailyCosts returns the daily cost of the given namespace or group for all days from `from` to `to`
+// (both inclusive). Precomputed summaries are used when present, otherwise the cost is computed on demand.
+func RetrieveDailyCosts(ownerType, owner string, from, to time.Time) ([]models.CostSummary, error) {
+	from, to = GetDayStart(from), GetDayStart(to)
+	if today := GetDayStart(time.Now()); to.After(today) {
+		to = today
+	}
+	summaries, err := query.RetrieveCostSummaries(ownerType, owner, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	precomputed := map[string]models.CostSummary{}
+	for _, summary := range summaries {
+		precomputed[summary.Xid] = summary
+	}
+
+	var labels map[string]string
+	dailyCosts := []models.CostSummary{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		xid := models.GetCostSummaryXID(ownerType, owner, day)
+		if summary, isPresent := precomputed[xid]; isPresent {
+			dailyCosts = append(dailyCosts, summary)
+			continue
+		}
+
+		if ownerType == models.GroupOwner && labels == nil {
+			labels, err = getGroupLabels(owner)
+			if err != nil {
+				return nil, err
+			}
+		}
+		summary, err := computeCostSummary(ownerType, owner, labels, day)
+		if err != nil {
+			return nil, err
+		}
+		dailyCosts = append(dailyCosts, summary)
+	}
+	return dailyCosts, nil
+}
+
+// computeCostSummary computes the cost of the namespace or group for the given day on demand
+func computeCostSummary(ownerType, owner string, labels map[string]string, day time.Time) (models.CostSummary, error) {
+	from, to := day, day.AddDate(0, 0, 1)
+	if to.After(time.Now()) {
+		to = time.Now()
+	}
+
+	var cost query.ResourceCost
+	if ownerType == models.GroupOwner {
+		groupCost, err := query.RetrieveLabelsCostInWindow(labels, from, to)
+		if err != nil {
+			return models.CostSummary{}, err
+		}
+		cost = groupCost
+	} else {
+		namespaceCosts, err := query.RetrieveNamespaceCostsInWindow(owner, from, to)
+		if err != nil {
+			return models.CostSummary{}, err
+		}
+		if len(namespaceCosts) > 0 {
+			cost = namespaceCosts[0]
+		}
+	}
+	summary := newCostSummary(ownerType, owner, day, cost, pricing.GetCatalog().Version)
+	summary.ComputedAt = time.Now().Format(time.RFC3339)
+	return summary, nil
+}
+
+// newCostSummary returns the summary of the cost of the namespace or group for the day, the summary of a namespace also
+// records its claims, snapshots and load balancers so that its line items can be read from the summary
+func newCostSummary(ownerType, owner string, day time.Time, cost query.ResourceCost, priceVersion string) models.CostSummary {
+	summary := models.CostSummary{
+		ID:             dgraph.ID{Xid: models.GetCostSummaryXID(ownerType, owner, day)},
+		Date:           day.Format(time.RFC3339),
+		CPUHours:       cost.CPU,
+		MemoryGBHours:  cost.Memory,
+		StorageGBHours: cost.Storage,
+		CPUCost:        cost.CPUCost,
+		MemoryCost:     cost.MemoryCost,
+		StorageCost:    cost.StorageCost,
+		GPUHours:       cost.GPU,
+		GPUCost:        cost.GPUCost,
+		TotalCost:      cost.CPUCost + cost.MemoryCost + cost.StorageCost + cost.GPUCost,
+		PriceVersion:   priceVersion,
+	}
+	if ownerType != models.NamespaceOwner {
+		return summary
+	}
+	for _, item := range cost.LineItems {
+		switch item.Category {
+		case query.PersistentVolumeLineItem:
+			summary.ClaimGBHours, summary.ClaimCost = item.Quantity, item.Cost
+		case query.SnapshotLineItem:
+			summary.SnapshotGBHours, summary.SnapshotCost = item.Quantity, item.Cost
+		case query.LoadBalancerLineItem:
+			summary.LoadBalancerHours, summary.LoadBalancerCost = item.Quantity, item.Cost
+		}
+	}
+	summary.HasLineItems = true
+	return summary
+}
+
+func getGroupLabels(name string) (map[string]string, error) {
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		if group.Xid == name {
+			return group.GetLabelsMap(), nil
+		}
+	}
+	return map[string]string{}, nil
+}
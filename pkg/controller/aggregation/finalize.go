@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// FinalizeNamespace is called when a namespace is deleted. It closes all resources of the namespace which are
+// still open at the deletion time and freezes the cost of the namespace from `start` to `end` as an archive,
+// so that the cost stays available after the resources are purged.
+func FinalizeNamespace(name string, start, end time.Time) error {
+	closed, err := models.CloseNamespaceResources(name, end)
+	if err != nil {
+		return err
+	}
+
+	namespaceCosts, err := query.RetrieveNamespaceCosts(name, start, end)
+	if err != nil {
+		return err
+	}
+	var cost query.ResourceCost
+	if len(namespaceCosts) > 0 {
+		cost = namespaceCosts[0]
+	}
+
+	archive := newNamespaceArchive(name, start, end, cost, pricing.GetCatalog().Version)
+	if _, err = models.StoreNamespaceArchive(archive); err != nil {
+		return err
+	}
+	log.Infof("finalized namespace: (%s), closed %d resources, lifetime cost: %f", name, closed, archive.TotalCost)
+	return nil
+}
+
+// newNamespaceArchive freezes the cost of the namespace over its lifetime from start to end
+func newNamespaceArchive(name string, start, end time.Time, cost query.ResourceCost,
+	priceVersion string) models.NamespaceArchive {
+	return models.NamespaceArchive{
+		ID:             dgraph.ID{Xid: models.GetNamespaceArchiveXID(name, end)},
+		Name:           name,
+		LifetimeStart:  start.Format(time.RFC3339),
+		LifetimeEnd:    end.Format(time.RFC3339),
+		CPUHours:       cost.CPU,
+		MemoryGBHours:  cost.Memory,
+		StorageGBHours: cost.Storage,
+		CPUCost:        cost.CPUCost,
+		MemoryCost:     cost.MemoryCost,
+		StorageCost:    cost.StorageCost,
+		GPUHours:       cost.GPU,
+		GPUCost:        cost.GPUCost,
+		TotalCost:      cost.CPUCost + cost.MemoryCost + cost.StorageCost + cost.GPUCost,
+		PriceVersion:   priceVersion,
+	}
+}
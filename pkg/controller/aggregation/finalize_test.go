@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package aggregation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestNewNamespaceArchive(t *testing.T) {
+	start := time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2018, 11, 15, 12, 0, 0, 0, time.UTC)
+	cost := query.ResourceCost{CPU: 240, Memory: 960, Storage: 48, GPU: 2, CPUCost: 5.5, MemoryCost: 2.25,
+		StorageCost: 0.25, GPUCost: 1.8}
+	archive := newNamespaceArchive("shop", start, end, cost, "v3")
+
+	utils.Equals(t, "archive-shop-2018-11-15T12:00:00Z", archive.Xid)
+	utils.Equals(t, "shop", archive.Name)
+	utils.Equals(t, "2018-10-01T00:00:00Z", archive.LifetimeStart)
+	utils.Equals(t, "2018-11-15T12:00:00Z", archive.LifetimeEnd)
+	utils.Equals(t, cost.CPU, archive.CPUHours)
+	utils.Equals(t, cost.Memory, archive.MemoryGBHours)
+	utils.Equals(t, cost.Storage, archive.StorageGBHours)
+	utils.Equals(t, 9.8, archive.TotalCost)
+	utils.Equals(t, "v3", archive.PriceVersion)
+
+	// the archive reads back as it was written, which is how the archived cost is queried
+	encoded, err := json.Marshal(archive)
+	utils.Ok(t, err)
+	var decoded models.NamespaceArchive
+	utils.Ok(t, json.Unmarshal(encoded, &decoded))
+	utils.Equals(t, archive, decoded)
+}
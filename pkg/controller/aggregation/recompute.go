@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// Recompute job states
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+
+	dateFormat = "2006-01-02"
+)
+
+// Job is a cost recomputation job over a window of days.
+type Job struct {
+	ID            string `json:"id"`
+	From          string `json:"from"`
+	To            string `json:"to"`
+	TotalDays     int    `json:"totalDays"`
+	CompletedDays int    `json:"completedDays"`
+	Reason        string `json:"reason,omitempty"`
+	AmendedDays   int    `json:"amendedDays"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+	StartTime     string `json:"startTime"`
+	EndTime       string `json:"endTime,omitempty"`
+}
+
+var (
+	jobsMutex sync.Mutex
+	jobs      = map[string]*Job{}
+)
+
+// StartRecompute starts a job which recomputes the daily cost summaries for all days from `from` to `to`
+// (both inclusive). The seals of the sealed days are amended with the reason of the recomputation, which is required
+// when a day of the window is sealed. Only one recompute job runs at a time.
+func StartRecompute(from, to time.Time, reason string) (Job, error) {
+	from, to = GetDayStart(from), GetDayStart(to)
+	if to.Before(from) {
+		return Job{}, fmt.Errorf("invalid window, from: %s is after to: %s", from.Format(dateFormat), to.Format(dateFormat))
+	}
+	if to.After(time.Now()) {
+		return Job{}, fmt.Errorf("invalid window, to: %s is in the future", to.Format(dateFormat))
+	}
+	seals, err := query.RetrieveDailySeals(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return Job{}, err
+	}
+	sealed := map[string]bool{}
+	for _, seal := range seals {
+		sealed[seal.Xid] = true
+	}
+	if len(sealed) > 0 && reason == "" {
+		return Job{}, fmt.Errorf("%d days of the window are sealed, a reason is required to amend their seals", len(sealed))
+	}
+
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	for _, job := range jobs {
+		if job.Status == JobRunning {
+			return Job{}, fmt.Errorf("recompute job: %s is already running", job.ID)
+		}
+	}
+
+	job := &Job{
+		ID:        fmt.Sprintf("recompute-%d", time.Now().UnixNano()),
+		From:      from.Format(dateFormat),
+		To:        to.Format(dateFormat),
+		TotalDays: countDays(from, to),
+		Reason:    reason,
+		Status:    JobRunning,
+		StartTime: time.Now().Format(time.RFC3339),
+	}
+	jobs[job.ID] = job
+	go runRecompute(job, from, to, sealed)
+	return *job, nil
+}
+
+// countDays returns the number of days from the start of the day from to the start of the day to (both inclusive),
+// days lasting 23 or 25 hours on daylight saving time changes count as one day
+func countDays(from, to time.Time) int {
+	return int((to.Sub(from).Hours()+12)/24) + 1
+}
+
+// GetJob returns the recompute job with the given id
+func GetJob(id string) (Job, bool) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	job, isPresent := jobs[id]
+	if !isPresent {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// GetJobs returns all recompute jobs sorted by start time
+func GetJobs() []Job {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	allJobs := []Job{}
+	for _, job := range jobs {
+		allJobs = append(allJobs, *job)
+	}
+	sort.Slice(allJobs, func(i, j int) bool {
+		return allJobs[i].StartTime < allJobs[j].StartTime
+	})
+	return allJobs
+}
+
+// runRecompute recomputes the summaries of the days of the window and amends the seals of the sealed days
+func runRecompute(job *Job, from, to time.Time, sealed map[string]bool) {
+	log.Infof("recompute job: (%s) started for window: (%s, %s)", job.ID, job.From, job.To)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := ComputeDailyCosts(day); err != nil {
+			log.Errorf("recompute job: (%s) failed for day: (%s), error: (%v)", job.ID, day.Format(dateFormat), err)
+			finishJob(job, err)
+			return
+		}
+		isSealed := sealed[models.GetDailySealXID(day)]
+		if isSealed {
+			if err := AmendSeal(day, job.Reason); err != nil {
+				log.Errorf("recompute job: (%s) failed to amend seal of day: (%s), error: (%v)", job.ID, day.Format(dateFormat), err)
+				finishJob(job, err)
+				return
+			}
+		}
+		jobsMutex.Lock()
+		job.CompletedDays++
+		if isSealed {
+			job.AmendedDays++
+		}
+		jobsMutex.Unlock()
+	}
+	log.Infof("recompute job: (%s) completed", job.ID)
+	finishJob(job, nil)
+}
+
+func finishJob(job *Job, err error) {
+	jobsMutex.Lock()
+	defer jobsMutex.Unlock()
+	job.Status = JobCompleted
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+	}
+	job.EndTime = time.Now().Format(time.RFC3339)
+}
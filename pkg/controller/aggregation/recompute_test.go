@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package aggregation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestStartRecomputeInvalidWindow(t *testing.T) {
+	today := GetDayStart(time.Now())
+	_, err := StartRecompute(today, today.AddDate(0, 0, -1), "")
+	utils.Assert(t, err != nil, "recompute job started with from after to")
+	_, err = StartRecompute(today.AddDate(0, 0, -1), today.AddDate(0, 0, 1), "")
+	utils.Assert(t, err != nil, "recompute job started for a window ending in the future")
+	utils.Equals(t, 0, len(GetJobs()))
+}
+
+func TestCountDays(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2018, month, d, 0, 0, 0, 0, time.Local)
+	}
+	utils.Equals(t, 1, countDays(day(11, 5), day(11, 5)))
+	utils.Equals(t, 30, countDays(day(11, 1), day(11, 30)))
+	// the window spans the end of the daylight saving time in most time zones
+	utils.Equals(t, 61, countDays(day(10, 1), day(11, 30)))
+}
+
+func TestRecomputeJobs(t *testing.T) {
+	defer func() {
+		jobs = map[string]*Job{}
+	}()
+	completed := &Job{ID: "recompute-2", Status: JobRunning, StartTime: "2018-11-06T10:00:00Z"}
+	failed := &Job{ID: "recompute-1", Status: JobRunning, StartTime: "2018-11-05T10:00:00Z"}
+	jobs = map[string]*Job{completed.ID: completed, failed.ID: failed}
+
+	finishJob(completed, nil)
+	finishJob(failed, errors.New("dgraph is unreachable"))
+	job, isPresent := GetJob("recompute-2")
+	utils.Assert(t, isPresent, "job recompute-2 is missing")
+	utils.Equals(t, JobCompleted, job.Status)
+	utils.Assert(t, job.EndTime != "" && job.Error == "", "completed job: %v", job)
+	_, isPresent = GetJob("recompute-3")
+	utils.Assert(t, !isPresent, "unknown job is found")
+
+	// jobs are listed by start time
+	all := GetJobs()
+	utils.Equals(t, 2, len(all))
+	utils.Equals(t, "recompute-1", all[0].ID)
+	utils.Equals(t, JobFailed, all[0].Status)
+	utils.Equals(t, "dgraph is unreachable", all[0].Error)
+}
@@ -0,0 +1,451 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// AuditSettings of the seals of the daily cost summaries
+type AuditSettings struct {
+	// SigningKeyFile is the path of the PEM file of the ECDSA private key signing the seals (ex: generated with
+	// `openssl ecparam -name prime256v1 -genkey -noout`). Without it the seals are only hash chained.
+	SigningKeyFile string `json:"signingKeyFile,omitempty"`
+}
+
+// DayVerification is the result of the verification of the seal of a day
+type DayVerification struct {
+	Date        string   `json:"date"`
+	Sealed      bool     `json:"sealed"`
+	Valid       bool     `json:"valid"`
+	Summaries   int      `json:"summaries"`
+	ChainDigest string   `json:"chainDigest,omitempty"`
+	Signed      bool     `json:"signed"`
+	Problems    []string `json:"problems,omitempty"`
+
+	Amendments []DayAmendment `json:"amendments,omitempty"`
+}
+
+// DayAmendment is an amendment of the seal of a day, recorded when its summaries were recomputed
+type DayAmendment struct {
+	Sequence  int    `json:"sequence"`
+	Reason    string `json:"reason"`
+	Summaries int    `json:"summaries"`
+	AmendedAt string `json:"amendedAt"`
+	Signed    bool   `json:"signed"`
+}
+
+// Verification is the result of the verification of the seals of a window of days. It is valid when the seal of
+// every sealed day is valid, the days which were not sealed are listed but they are not verified.
+type Verification struct {
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Valid     bool              `json:"valid"`
+	Sealed    int               `json:"sealed"`
+	Unsealed  int               `json:"unsealed"`
+	PublicKey string            `json:"publicKey,omitempty"`
+	Days      []DayVerification `json:"days"`
+}
+
+// ecdsaSignature is the ASN.1 encoding of an ECDSA signature
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+var (
+	signingKeyMu sync.RWMutex
+	signingKey   *ecdsa.PrivateKey
+)
+
+// SetupAudit loads the key signing the seals of the daily cost summaries
+func SetupAudit(settings AuditSettings) {
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	signingKey = nil
+	if settings.SigningKeyFile == "" {
+		return
+	}
+	key, err := loadSigningKey(settings.SigningKeyFile)
+	if err != nil {
+		log.Errorf("seals of daily costs will not be signed: %v", err)
+		return
+	}
+	signingKey = key
+}
+
+func loadSigningKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			if ecKey, isEC := key.(*ecdsa.PrivateKey); isEC {
+				return ecKey, nil
+			}
+			return nil, fmt.Errorf("signing key of %s is not an ECDSA key", path)
+		}
+	}
+	return nil, fmt.Errorf("no ECDSA private key found in %s", path)
+}
+
+// SealDay finalizes the cost summaries of the given day: their digest is chained to the seal of the previous sealed
+// day and signed if a signing key is configured. A day is sealed once, its summaries are recomputed later only with an
+// amendment of its seal (see AmendSeal), otherwise its verification fails.
+func SealDay(day time.Time) error {
+	from := GetDayStart(day)
+	summaries, err := query.RetrieveAllCostSummaries(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	previous, err := query.RetrieveLastDailySeal(from)
+	if err != nil {
+		return err
+	}
+	previousChainDigest := ""
+	if previous != nil {
+		previousChainDigest = previous.ChainDigest
+	}
+
+	xid := models.GetDailySealXID(from)
+	digest := summariesDigest(summaries)
+	seal := models.DailySeal{
+		ID:                  dgraph.ID{Xid: xid},
+		Date:                from.Format(time.RFC3339),
+		Summaries:           len(summaries),
+		Digest:              digest,
+		PreviousChainDigest: previousChainDigest,
+		ChainDigest:         chainDigest(previousChainDigest, xid, digest),
+	}
+
+	if seal.Signature, seal.KeyFingerprint, err = sign(seal.ChainDigest); err != nil {
+		return err
+	}
+	_, err = models.StoreDailySeal(seal)
+	return err
+}
+
+// AmendSeal records that the cost summaries of the sealed day were recomputed for the given reason. The digest of the
+// current summaries is chained with the reason to the seal of the day, or to its last amendment, and signed like the
+// seals. The chain of the seals of the days is unchanged.
+func AmendSeal(day time.Time, reason string) error {
+	from := GetDayStart(day)
+	seals, err := query.RetrieveDailySeals(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if len(seals) == 0 {
+		return fmt.Errorf("day: %s is not sealed", from.Format(dateFormat))
+	}
+	amendments, err := query.RetrieveSealAmendments(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	sortAmendments(amendments)
+	summaries, err := query.RetrieveAllCostSummaries(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	previousDigest, previousChainDigest := seals[0].Digest, seals[0].ChainDigest
+	if last := len(amendments) - 1; last >= 0 {
+		previousDigest, previousChainDigest = amendments[last].Digest, amendments[last].ChainDigest
+	}
+	xid := models.GetSealAmendmentXID(from, len(amendments)+1)
+	digest := summariesDigest(summaries)
+	amendment := models.SealAmendment{
+		ID:                  dgraph.ID{Xid: xid},
+		Date:                from.Format(time.RFC3339),
+		Sequence:            len(amendments) + 1,
+		Reason:              reason,
+		Summaries:           len(summaries),
+		Digest:              digest,
+		PreviousDigest:      previousDigest,
+		PreviousChainDigest: previousChainDigest,
+		ChainDigest:         amendmentChainDigest(previousChainDigest, xid, digest, reason),
+	}
+	if amendment.Signature, amendment.KeyFingerprint, err = sign(amendment.ChainDigest); err != nil {
+		return err
+	}
+	_, err = models.StoreSealAmendment(amendment)
+	return err
+}
+
+// sign returns the signature of the digest with the signing key and the fingerprint of the key, they are empty when
+// no signing key is configured
+func sign(digest string) (string, string, error) {
+	signingKeyMu.RLock()
+	key := signingKey
+	signingKeyMu.RUnlock()
+	if key == nil {
+		return "", "", nil
+	}
+	signature, err := signDigest(key, digest)
+	if err != nil {
+		return "", "", err
+	}
+	return signature, keyFingerprint(&key.PublicKey), nil
+}
+
+func sortAmendments(amendments []models.SealAmendment) {
+	sort.SliceStable(amendments, func(i, j int) bool {
+		return amendments[i].Sequence < amendments[j].Sequence
+	})
+}
+
+// VerifySeals checks that the cost summaries of the days from `from` to `to` (both inclusive) are the ones which
+// were sealed or, for the amended days, the ones of their last amendment, that the chain of seals and amendments is
+// unbroken and that their signatures are valid.
+func VerifySeals(from, to time.Time) (Verification, error) {
+	from, to = GetDayStart(from), GetDayStart(to)
+	verification := Verification{From: from.Format(dateFormat), To: to.Format(dateFormat), Valid: true, Days: []DayVerification{}}
+
+	seals, err := query.RetrieveDailySeals(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return verification, err
+	}
+	sealsByDate := map[string]models.DailySeal{}
+	for _, seal := range seals {
+		sealsByDate[seal.Xid] = seal
+	}
+	amendments, err := query.RetrieveSealAmendments(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return verification, err
+	}
+	amendmentsByDate := map[string][]models.SealAmendment{}
+	for _, amendment := range amendments {
+		if day, err := time.Parse(time.RFC3339, amendment.Date); err == nil {
+			xid := models.GetDailySealXID(day)
+			amendmentsByDate[xid] = append(amendmentsByDate[xid], amendment)
+		}
+	}
+	previous, err := query.RetrieveLastDailySeal(from)
+	if err != nil {
+		return verification, err
+	}
+	previousChainDigest := ""
+	if previous != nil {
+		previousChainDigest = previous.ChainDigest
+	}
+
+	signingKeyMu.RLock()
+	var publicKey *ecdsa.PublicKey
+	if signingKey != nil {
+		publicKey = &signingKey.PublicKey
+		verification.PublicKey = publicKeyPEM(publicKey)
+	}
+	signingKeyMu.RUnlock()
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		seal, isSealed := sealsByDate[models.GetDailySealXID(day)]
+		if !isSealed {
+			verification.Unsealed++
+			verification.Days = append(verification.Days, DayVerification{Date: day.Format(dateFormat)})
+			continue
+		}
+		summaries, err := query.RetrieveAllCostSummaries(day, day.AddDate(0, 0, 1))
+		if err != nil {
+			return verification, err
+		}
+		dayAmendments := amendmentsByDate[seal.Xid]
+		sortAmendments(dayAmendments)
+		problems := verifySeal(seal, dayAmendments, summaries, previousChainDigest, publicKey)
+		verification.Sealed++
+		verification.Valid = verification.Valid && len(problems) == 0
+		dayVerification := DayVerification{
+			Date:        day.Format(dateFormat),
+			Sealed:      true,
+			Valid:       len(problems) == 0,
+			Summaries:   len(summaries),
+			ChainDigest: seal.ChainDigest,
+			Signed:      seal.Signature != "",
+			Problems:    problems,
+		}
+		for _, amendment := range dayAmendments {
+			dayVerification.Amendments = append(dayVerification.Amendments, DayAmendment{Sequence: amendment.Sequence,
+				Reason: amendment.Reason, Summaries: amendment.Summaries, AmendedAt: amendment.AmendedAt,
+				Signed: amendment.Signature != ""})
+		}
+		verification.Days = append(verification.Days, dayVerification)
+		previousChainDigest = seal.ChainDigest
+	}
+	return verification, nil
+}
+
+// verifySeal returns the problems found by checking the seal and the amendments of a day, in sequence, against the
+// current summaries of the day and the chain digest of the previous seal. The summaries must be the ones of the last
+// amendment, or of the seal if the day was not amended. The signatures are checked when the public key is given.
+func verifySeal(seal models.DailySeal, amendments []models.SealAmendment, summaries []models.CostSummary,
+	previousChainDigest string, publicKey *ecdsa.PublicKey) []string {
+	var amendmentProblems []string
+	sealedSummaries, sealedDigest, sealedChainDigest := seal.Summaries, seal.Digest, seal.ChainDigest
+	for _, amendment := range amendments {
+		if amendment.PreviousDigest != sealedDigest || amendment.PreviousChainDigest != sealedChainDigest {
+			amendmentProblems = append(amendmentProblems, fmt.Sprintf("amendment %d does not follow the seal or the previous amendment", amendment.Sequence))
+		}
+		if amendmentChainDigest(amendment.PreviousChainDigest, amendment.Xid, amendment.Digest, amendment.Reason) != amendment.ChainDigest {
+			amendmentProblems = append(amendmentProblems, fmt.Sprintf("amendment %d was modified", amendment.Sequence))
+		}
+		if problem := signatureProblem(amendment.ChainDigest, amendment.Signature, amendment.KeyFingerprint, publicKey); problem != "" {
+			amendmentProblems = append(amendmentProblems, fmt.Sprintf("amendment %d: %s", amendment.Sequence, problem))
+		}
+		sealedSummaries, sealedDigest, sealedChainDigest = amendment.Summaries, amendment.Digest, amendment.ChainDigest
+	}
+
+	var problems []string
+	if len(summaries) != sealedSummaries {
+		problems = append(problems, fmt.Sprintf("%d summaries were sealed, %d are stored", sealedSummaries, len(summaries)))
+	}
+	if summariesDigest(summaries) != sealedDigest {
+		problems = append(problems, "summaries were modified after the day was sealed")
+	}
+	if seal.PreviousChainDigest != previousChainDigest {
+		problems = append(problems, "chain is broken, the seal of a previous day was modified or removed")
+	}
+	if chainDigest(seal.PreviousChainDigest, seal.Xid, seal.Digest) != seal.ChainDigest {
+		problems = append(problems, "seal was modified")
+	}
+	if problem := signatureProblem(seal.ChainDigest, seal.Signature, seal.KeyFingerprint, publicKey); problem != "" {
+		problems = append(problems, problem)
+	}
+	return append(problems, amendmentProblems...)
+}
+
+// signatureProblem returns the problem of the signature of the digest, empty if it is valid or if the digest is not
+// signed or the public key is not given
+func signatureProblem(digest, signature, fingerprint string, publicKey *ecdsa.PublicKey) string {
+	if signature == "" || publicKey == nil {
+		return ""
+	}
+	if configured := keyFingerprint(publicKey); fingerprint != configured {
+		return fmt.Sprintf("seal is signed by key: %s, configured key is: %s", fingerprint, configured)
+	}
+	if !verifySignature(publicKey, digest, signature) {
+		return "signature is invalid"
+	}
+	return ""
+}
+
+// summariesDigest returns the hex SHA-256 digest of the summaries, it does not depend on their order nor on the
+// time they were computed at
+func summariesDigest(summaries []models.CostSummary) string {
+	lines := make([]string, 0, len(summaries))
+	for _, summary := range summaries {
+		fields := []string{summary.Xid, summary.Date}
+		for _, value := range []float64{float64(summary.CPUHours), float64(summary.MemoryGBHours),
+			float64(summary.StorageGBHours), summary.GPUHours, summary.CPUCost, summary.MemoryCost, summary.StorageCost, summary.GPUCost, summary.TotalCost} {
+			fields = append(fields, strconv.FormatFloat(value, 'g', -1, 64))
+		}
+		fields = append(fields, summary.PriceVersion)
+		// the line items were added to the summaries after days were sealed, the digests of those days do not have them
+		if summary.HasLineItems {
+			for _, value := range []float64{summary.ClaimGBHours, summary.ClaimCost, summary.SnapshotGBHours,
+				summary.SnapshotCost, summary.LoadBalancerHours, summary.LoadBalancerCost} {
+				fields = append(fields, strconv.FormatFloat(value, 'g', -1, 64))
+			}
+		}
+		lines = append(lines, strings.Join(fields, "|"))
+	}
+	sort.Strings(lines)
+	digest := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(digest[:])
+}
+
+// chainDigest links the digest of the summaries of a day, identified by the xid of its seal, to the chain digest
+// of the previous sealed day
+func chainDigest(previousChainDigest, xid, digest string) string {
+	chained := sha256.Sum256([]byte(previousChainDigest + "\n" + xid + "\n" + digest))
+	return hex.EncodeToString(chained[:])
+}
+
+// amendmentChainDigest links the digest of the recomputed summaries of a day and the reason of their recomputation,
+// identified by the xid of the amendment, to the chain digest of the seal of the day or of its previous amendment
+func amendmentChainDigest(previousChainDigest, xid, digest, reason string) string {
+	return chainDigest(previousChainDigest, xid, digest+"\n"+reason)
+}
+
+func signDigest(key *ecdsa.PrivateKey, digest string) (string, error) {
+	hashed := sha256.Sum256([]byte(digest))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	signature, err := asn1.Marshal(ecdsaSignature{R: r, S: s})
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
+
+func verifySignature(key *ecdsa.PublicKey, digest, signature string) bool {
+	der, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	parsed := ecdsaSignature{}
+	if _, err = asn1.Unmarshal(der, &parsed); err != nil || parsed.R == nil || parsed.S == nil {
+		return false
+	}
+	hashed := sha256.Sum256([]byte(digest))
+	return ecdsa.Verify(key, hashed[:], parsed.R, parsed.S)
+}
+
+// keyFingerprint returns the first 16 hex digits of the SHA-256 digest of the DER encoded public key
+func keyFingerprint(key *ecdsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return ""
+	}
+	digest := sha256.Sum256(der)
+	return hex.EncodeToString(digest[:])[:16]
+}
+
+func publicKeyPEM(key *ecdsa.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return ""
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newSealedDay(t *testing.T, key *ecdsa.PrivateKey, previousChainDigest string, summaries []models.CostSummary) models.DailySeal {
+	xid := "dailyseal-2018-11-05"
+	digest := summariesDigest(summaries)
+	seal := models.DailySeal{
+		ID:                  dgraph.ID{Xid: xid},
+		Summaries:           len(summaries),
+		Digest:              digest,
+		PreviousChainDigest: previousChainDigest,
+		ChainDigest:         chainDigest(previousChainDigest, xid, digest),
+		KeyFingerprint:      keyFingerprint(&key.PublicKey),
+	}
+	signature, err := signDigest(key, seal.ChainDigest)
+	utils.Ok(t, err)
+	seal.Signature = signature
+	return seal
+}
+
+func TestVerifySeal(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	utils.Ok(t, err)
+	summaries := []models.CostSummary{
+		{ID: dgraph.ID{Xid: "costsummary-namespace-shop-2018-11-05"}, CPUCost: 1.5, TotalCost: 2},
+		{ID: dgraph.ID{Xid: "costsummary-group-web-2018-11-05"}, CPUCost: 0.25, TotalCost: 0.5},
+	}
+	seal := newSealedDay(t, key, "previous", summaries)
+
+	// order of the summaries and their computation time do not matter
+	reordered := []models.CostSummary{summaries[1], summaries[0]}
+	reordered[0].ComputedAt = "2018-12-01T00:00:00Z"
+	utils.Equals(t, 0, len(verifySeal(seal, nil, reordered, "previous", &key.PublicKey)))
+
+	modified := []models.CostSummary{summaries[0], summaries[1]}
+	modified[0].TotalCost = 1
+	utils.Equals(t, []string{"summaries were modified after the day was sealed"}, verifySeal(seal, nil, modified, "previous", &key.PublicKey))
+
+	utils.Equals(t, []string{"chain is broken, the seal of a previous day was modified or removed"},
+		verifySeal(seal, nil, summaries, "other", &key.PublicKey))
+
+	forged := seal
+	forged.Digest = summariesDigest(modified)
+	forged.ChainDigest = chainDigest(forged.PreviousChainDigest, forged.Xid, forged.Digest)
+	utils.Equals(t, []string{"signature is invalid"}, verifySeal(forged, nil, modified, "previous", &key.PublicKey))
+	// a forged chain can't be told from a genuine one without the signature
+	utils.Equals(t, 0, len(verifySeal(forged, nil, modified, "previous", nil)))
+}
+
+func TestVerifyAmendedSeal(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	utils.Ok(t, err)
+	summaries := []models.CostSummary{{ID: dgraph.ID{Xid: "costsummary-namespace-shop-2018-11-05"}, CPUCost: 1.5, TotalCost: 2}}
+	seal := newSealedDay(t, key, "previous", summaries)
+
+	recomputed := []models.CostSummary{summaries[0], {ID: dgraph.ID{Xid: "costsummary-group-web-2018-11-05"}, TotalCost: 0.5}}
+	recomputed[0].CPUCost, recomputed[0].TotalCost = 1.25, 1.75
+	utils.Equals(t, []string{"1 summaries were sealed, 2 are stored", "summaries were modified after the day was sealed"},
+		verifySeal(seal, nil, recomputed, "previous", &key.PublicKey))
+
+	xid := "sealamendment-2018-11-05-1"
+	amendment := models.SealAmendment{
+		ID:                  dgraph.ID{Xid: xid},
+		Sequence:            1,
+		Reason:              "cpu prices corrected",
+		Summaries:           len(recomputed),
+		Digest:              summariesDigest(recomputed),
+		PreviousDigest:      seal.Digest,
+		PreviousChainDigest: seal.ChainDigest,
+	}
+	amendment.ChainDigest = amendmentChainDigest(seal.ChainDigest, xid, amendment.Digest, amendment.Reason)
+	amendment.Signature, err = signDigest(key, amendment.ChainDigest)
+	utils.Ok(t, err)
+	amendment.KeyFingerprint = keyFingerprint(&key.PublicKey)
+	utils.Equals(t, 0, len(verifySeal(seal, []models.SealAmendment{amendment}, recomputed, "previous", &key.PublicKey)))
+	// the summaries of the seal are replaced by the ones of the amendment
+	utils.Equals(t, []string{"2 summaries were sealed, 1 are stored", "summaries were modified after the day was sealed"},
+		verifySeal(seal, []models.SealAmendment{amendment}, summaries, "previous", &key.PublicKey))
+
+	reworded := amendment
+	reworded.Reason = "no reason"
+	utils.Equals(t, []string{"amendment 1 was modified"},
+		verifySeal(seal, []models.SealAmendment{reworded}, recomputed, "previous", &key.PublicKey))
+
+	forged := amendment
+	forged.PreviousDigest = summariesDigest(recomputed)
+	utils.Equals(t, []string{"amendment 1 does not follow the seal or the previous amendment"},
+		verifySeal(seal, []models.SealAmendment{forged}, recomputed, "previous", &key.PublicKey))
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package allocation
+
+import (
+	log "github.com/Sirupsen/logrus"
+)
+
+// Charging of the idle capacity of the nodes of a pool: the cost of the capacity not requested by pods is left to the
+// cluster (default) or split among the burstable pods in proportion to their burst usage
+const (
+	IdleToCluster = "cluster"
+	IdleByBurst   = "burst"
+)
+
+// AllNodePools matches every node pool without settings of its own
+const AllNodePools = "*"
+
+// NodePoolSettings of the charging of the idle capacity of the nodes of a pool (its name, or * for every pool). The
+// pods of a pool are burstable when their limits are above their requests or unset, their burst usage is the part of
+// their average usage above their requests.
+type NodePoolSettings struct {
+	NodePool string `json:"nodePool"`
+	IdleCost string `json:"idleCost,omitempty"`
+}
+
+// BurstUsage is the cost of the usage of the pods of an owner (ex: a namespace) above their requests
+type BurstUsage struct {
+	Owner  string
+	Weight float64
+}
+
+// setupNodePools sets the idle cost charging of the pools. mu must be held by the caller.
+func setupNodePools(pools []NodePoolSettings) {
+	idleCosts = map[string]string{}
+	for _, pool := range pools {
+		switch pool.IdleCost {
+		case "", IdleToCluster:
+			idleCosts[pool.NodePool] = IdleToCluster
+		case IdleByBurst:
+			idleCosts[pool.NodePool] = IdleByBurst
+		default:
+			log.Errorf("unknown idle cost of node pool: (%s): (%s), using %s", pool.NodePool, pool.IdleCost, IdleToCluster)
+			idleCosts[pool.NodePool] = IdleToCluster
+		}
+	}
+}
+
+// IdleCostOf returns how the idle capacity of the nodes of the pool is charged, nodes without pool have an empty one
+func IdleCostOf(nodePool string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if idleCost, isSet := idleCosts[nodePool]; isSet {
+		return idleCost
+	}
+	if idleCost, isSet := idleCosts[AllNodePools]; isSet {
+		return idleCost
+	}
+	return IdleToCluster
+}
+
+// IsIdleSplit tells whether the idle capacity of any node pool is split among its burstable pods
+func IsIdleSplit() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, idleCost := range idleCosts {
+		if idleCost == IdleByBurst {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBurstable tells whether a pod may use more than its request of a resource: its limit is above it or unset (0)
+func IsBurstable(request, limit float64) bool {
+	return limit == 0 || limit > request
+}
+
+// BurstWeight returns the cost per hour of the usage of a pod above its requests for the resources it can burst
+func BurstWeight(cpuRequest, cpuLimit, cpuUsage, memoryRequest, memoryLimit, memoryUsage, cpuPrice, memoryPrice float64) float64 {
+	weight := 0.0
+	if IsBurstable(cpuRequest, cpuLimit) && cpuUsage > cpuRequest {
+		weight += (cpuUsage - cpuRequest) * cpuPrice
+	}
+	if IsBurstable(memoryRequest, memoryLimit) && memoryUsage > memoryRequest {
+		weight += (memoryUsage - memoryRequest) * memoryPrice
+	}
+	return weight
+}
+
+// SplitIdleCost returns the shares of the idle cost of the owners in proportion to their burst usage, it returns nil
+// when no owner burst so that the idle cost is left to the cluster
+func SplitIdleCost(idle float64, usages []BurstUsage) map[string]float64 {
+	total := 0.0
+	for _, usage := range usages {
+		total += usage.Weight
+	}
+	if idle <= 0 || total <= 0 {
+		return nil
+	}
+	shares := map[string]float64{}
+	for _, usage := range usages {
+		if usage.Weight > 0 {
+			shares[usage.Owner] += idle * usage.Weight / total
+		}
+	}
+	return shares
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package allocation
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIdleCostOf(t *testing.T) {
+	defer Setup(Settings{})
+	utils.Assert(t, !IsIdleSplit(), "idle cost is left to the cluster by default")
+	Setup(Settings{NodePools: []NodePoolSettings{
+		{NodePool: "batch", IdleCost: IdleByBurst},
+		{NodePool: "gpu", IdleCost: "unknown"},
+		{NodePool: AllNodePools, IdleCost: IdleByBurst},
+		{NodePool: "system"},
+	}})
+	utils.Assert(t, IsIdleSplit(), "idle cost of batch is split")
+	utils.Equals(t, IdleByBurst, IdleCostOf("batch"))
+	utils.Equals(t, IdleToCluster, IdleCostOf("gpu"))
+	utils.Equals(t, IdleToCluster, IdleCostOf("system"))
+	utils.Equals(t, IdleByBurst, IdleCostOf("web"))
+}
+
+func TestBurstWeight(t *testing.T) {
+	// unset limits, only the usage above the request counts
+	utils.Equals(t, 0.5*2+2*0.5, BurstWeight(1, 0, 1.5, 4, 0, 6, 2, 0.5))
+	// the cpu limit is the request, the memory usage is below the request
+	utils.Equals(t, 0.0, BurstWeight(1, 1, 1.5, 4, 8, 3, 2, 0.5))
+}
+
+func TestSplitIdleCost(t *testing.T) {
+	usages := []BurstUsage{{Owner: "web", Weight: 1}, {Owner: "jobs", Weight: 2}, {Owner: "web", Weight: 1}, {Owner: "api"}}
+	utils.Equals(t, map[string]float64{"web": 5, "jobs": 5}, SplitIdleCost(10, usages))
+	utils.Equals(t, map[string]float64(nil), SplitIdleCost(0, usages))
+	utils.Equals(t, map[string]float64(nil), SplitIdleCost(10, []BurstUsage{{Owner: "api"}}))
+}
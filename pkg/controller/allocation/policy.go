@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package allocation holds the policies splitting the cost of the nodes across their pods. A policy decides the cpus
+// and memory charged to a pod from its requests and its average usage, the storage and gpus of pods are always
+// charged by request.
+package allocation
+
+import (
+	"sort"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Names of the built-in policies
+const (
+	ByRequest       = "request"
+	ByUsage         = "usage"
+	ByMaxRequestUse = "max"
+)
+
+// Policy decides the amount of a resource (cpus or GB of memory) charged to a pod. Allocate computes it from the
+// request and average usage of the pod, Expression builds the same computation as a dgraph math expression of the
+// value variables holding them, so that costs in a time window are computed in the queries.
+type Policy interface {
+	Allocate(request, usage float64) float64
+	Expression(request, usage string) string
+}
+
+// Settings selects the allocation policy by name, default: request, and how the idle capacity of the nodes of the
+// node pools is charged
+type Settings struct {
+	Policy    string             `json:"policy,omitempty"`
+	NodePools []NodePoolSettings `json:"nodePools,omitempty"`
+}
+
+var (
+	mu         sync.RWMutex
+	policies   = map[string]Policy{}
+	policyName = ByRequest
+	idleCosts  = map[string]string{}
+)
+
+func init() {
+	Register(ByRequest, requestPolicy{})
+	Register(ByUsage, usagePolicy{})
+	Register(ByMaxRequestUse, maxPolicy{})
+}
+
+// Register adds a policy which can be selected by its name in the settings, it replaces a policy of the same name
+func Register(name string, policy Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policies[name] = policy
+}
+
+// Setup selects the policy of the settings, an unknown policy falls back to the default one
+func Setup(settings Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	setupNodePools(settings.NodePools)
+	policyName = ByRequest
+	if settings.Policy == "" {
+		return
+	}
+	if _, isRegistered := policies[settings.Policy]; !isRegistered {
+		log.Errorf("unknown allocation policy: (%s), using %s, policies: %v", settings.Policy, ByRequest, policyNames())
+		return
+	}
+	policyName = settings.Policy
+}
+
+// GetPolicy returns the selected policy and its name
+func GetPolicy() (string, Policy) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return policyName, policies[policyName]
+}
+
+func policyNames() []string {
+	names := []string{}
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// requestPolicy charges the requests of pods, whatever they use
+type requestPolicy struct{}
+
+func (requestPolicy) Allocate(request, usage float64) float64 {
+	return request
+}
+
+func (requestPolicy) Expression(request, usage string) string {
+	return request
+}
+
+// usagePolicy charges the average usage of pods, the idle requested capacity is not charged
+type usagePolicy struct{}
+
+func (usagePolicy) Allocate(request, usage float64) float64 {
+	return usage
+}
+
+func (usagePolicy) Expression(request, usage string) string {
+	return usage
+}
+
+// maxPolicy charges the requests of pods, or their usage when they use more than they requested
+type maxPolicy struct{}
+
+func (maxPolicy) Allocate(request, usage float64) float64 {
+	if usage > request {
+		return usage
+	}
+	return request
+}
+
+func (maxPolicy) Expression(request, usage string) string {
+	return "cond(" + usage + " > " + request + ", " + usage + ", " + request + ")"
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package allocation
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPolicies(t *testing.T) {
+	for name, expected := range map[string][2]float64{
+		ByRequest:       {1, 1},
+		ByUsage:         {0.25, 1.5},
+		ByMaxRequestUse: {1, 1.5},
+	} {
+		policy := policies[name]
+		utils.Equals(t, expected[0], policy.Allocate(1, 0.25))
+		utils.Equals(t, expected[1], policy.Allocate(1, 1.5))
+	}
+	utils.Equals(t, "req", requestPolicy{}.Expression("req", "use"))
+	utils.Equals(t, "use", usagePolicy{}.Expression("req", "use"))
+	utils.Equals(t, "cond(use > req, use, req)", maxPolicy{}.Expression("req", "use"))
+}
+
+type halfPolicy struct{}
+
+func (halfPolicy) Allocate(request, usage float64) float64 {
+	return (request + usage) / 2
+}
+
+func (halfPolicy) Expression(request, usage string) string {
+	return "(" + request + " + " + usage + ") / 2"
+}
+
+func TestSetup(t *testing.T) {
+	defer Setup(Settings{})
+
+	Setup(Settings{Policy: "unknown"})
+	name, _ := GetPolicy()
+	utils.Equals(t, ByRequest, name)
+
+	Register("half", halfPolicy{})
+	Setup(Settings{Policy: "half"})
+	name, policy := GetPolicy()
+	utils.Equals(t, "half", name)
+	utils.Equals(t, 0.75, policy.Allocate(1, 0.5))
+}
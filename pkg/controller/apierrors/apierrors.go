@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apierrors
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Error codes of the api, each code is returned with a single http status
+const (
+	// InvalidParameter is returned when a query param or a field of the request body is not valid
+	InvalidParameter = "INVALID_PARAMETER"
+	// InvalidRequest is returned when the request body can't be decoded or can't be applied
+	InvalidRequest = "INVALID_REQUEST"
+	// NotFound is returned when the requested resource doesn't exist
+	NotFound = "NOT_FOUND"
+	// Unauthorized is returned when the request of an admin endpoint has no valid token, or when the request has
+	// no valid ID token while SSO is enabled
+	Unauthorized = "UNAUTHORIZED"
+	// Forbidden is returned when the requested endpoint is disabled by the settings of the controller, or when it is
+	// out of the team scope of the user
+	Forbidden = "FORBIDDEN"
+	// QueryTooExpensive is returned when the estimated cost of a query exceeds the limit set in the settings
+	QueryTooExpensive = "QUERY_TOO_EXPENSIVE"
+	// Internal is returned when the request failed on the server side (ex: Dgraph is unreachable)
+	Internal = "INTERNAL"
+)
+
+// Error is the body of every api response with a non 2xx status
+type Error struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+	// Retryable tells if the same request may succeed later
+	Retryable bool `json:"retryable"`
+}
+
+// New returns an error with the given code and message
+func New(code, message string) *Error {
+	return &Error{
+		Code:      code,
+		Message:   message,
+		Retryable: code == Internal,
+	}
+}
+
+// Newf returns an error with the given code and a message formatted according to format
+func Newf(code, format string, args ...interface{}) *Error {
+	return New(code, fmt.Sprintf(format, args...))
+}
+
+// WithDetails sets the details of the error, they must be encodable in json
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// HTTPStatus returns the http status of the response having the given error code
+func HTTPStatus(code string) int {
+	switch code {
+	case InvalidParameter, InvalidRequest:
+		return http.StatusBadRequest
+	case NotFound:
+		return http.StatusNotFound
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case Forbidden:
+		return http.StatusForbidden
+	case QueryTooExpensive:
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// FromResponse returns the error sent by the api in the body of a response with a non 2xx status,
+// it is meant for the clients of the api. Nil is returned if the status is 2xx.
+func FromResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read response with status %d: %v", resp.StatusCode, err)
+	}
+
+	apiErr := &Error{}
+	if err = json.Unmarshal(body, apiErr); err != nil || apiErr.Code == "" {
+		// responses not sent by the api handlers (ex: from a proxy)
+		return &Error{
+			Code:      codeOfStatus(resp.StatusCode),
+			Message:   fmt.Sprintf("%s: %s", resp.Status, body),
+			Retryable: resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests,
+		}
+	}
+	return apiErr
+}
+
+func codeOfStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidRequest
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusUnprocessableEntity:
+		return QueryTooExpensive
+	default:
+		return Internal
+	}
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apierrors
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func newResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// TestFromResponse ...
+func TestFromResponse(t *testing.T) {
+	utils.Assert(t, FromResponse(newResponse(http.StatusOK, "{}")) == nil, "expected no error for status 200")
+
+	sent := Newf(InvalidParameter, "invalid namespace: %q", "Default").WithDetails(map[string]string{"parameter": "namespace"})
+	body, err := json.Marshal(sent)
+	utils.Ok(t, err)
+	got := FromResponse(newResponse(HTTPStatus(sent.Code), string(body)))
+	utils.Equals(t, &Error{
+		Code:      InvalidParameter,
+		Message:   `invalid namespace: "Default"`,
+		Details:   map[string]interface{}{"parameter": "namespace"},
+		Retryable: false,
+	}, got)
+
+	got = FromResponse(newResponse(http.StatusBadGateway, "bad gateway"))
+	apiErr, isAPIError := got.(*Error)
+	utils.Assert(t, isAPIError, "expected an api error")
+	utils.Equals(t, Internal, apiErr.Code)
+	utils.Assert(t, apiErr.Retryable, "expected a retryable error for status 502")
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package budget
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// warningRatio is the share of the monthly limit spent beyond which a warning is sent
+const warningRatio = 0.8
+
+// Budget is the monthly spend limit of a namespace or a group
+type Budget struct {
+	Namespace    string  `json:"namespace,omitempty"`
+	Group        string  `json:"group,omitempty"`
+	MonthlyLimit float64 `json:"monthlyLimit"`
+}
+
+var (
+	mu      sync.Mutex
+	budgets []Budget
+	// notified holds the highest severity already notified for every budget of the current month
+	notified = make(map[string]string)
+)
+
+// Setup sets the budgets to check
+func Setup(b []Budget) {
+	mu.Lock()
+	defer mu.Unlock()
+	budgets = b
+}
+
+// CheckBudgets compares the month to date cost of every budget scope with its limit. A warning is sent once
+// 80% of the limit is spent and a critical notification, which pages the responsible team, once the limit
+// is exceeded. Every severity is notified once per budget and month.
+func CheckBudgets() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	monthStart := utils.GetCurrentMonthStartTime()
+	for _, b := range budgets {
+		ownerType, owner := b.scope()
+		if owner == "" || b.MonthlyLimit <= 0 {
+			continue
+		}
+		cost, err := monthToDateCost(ownerType, owner, monthStart)
+		if err != nil {
+			log.Errorf("unable to check budget of %s: (%s), error: %v", ownerType, owner, err)
+			continue
+		}
+
+		notification, isBreached := b.breachNotification(cost, monthStart)
+		if !isBreached {
+			continue
+		}
+		if err = notifier.Notify(notification); err != nil {
+			log.Errorf("unable to notify budget breach of %s: (%s), error: %v", ownerType, owner, err)
+			continue
+		}
+		notified[notification.DedupKey] = notification.Severity
+	}
+}
+
+// breachNotification returns the notification of the breach of the budget by the month to date cost. It is false if
+// the budget is not breached or if the severity of the breach was already notified this month.
+func (b Budget) breachNotification(cost float64, monthStart time.Time) (notifier.Notification, bool) {
+	ownerType, owner := b.scope()
+	severity := ""
+	if cost > b.MonthlyLimit {
+		severity = notifier.Critical
+	} else if cost > warningRatio*b.MonthlyLimit {
+		severity = notifier.Warning
+	}
+	key := "budget-" + ownerType + "-" + owner + "-" + monthStart.Format("2006-01")
+	if severity == "" || notified[key] == severity || notified[key] == notifier.Critical {
+		return notifier.Notification{}, false
+	}
+	return notifier.Notification{
+		Title:    fmt.Sprintf("Budget of %s %s is %.0f%% spent", ownerType, owner, 100*cost/b.MonthlyLimit),
+		Summary:  fmt.Sprintf("Month to date cost of %s %s is %.2f, the monthly limit is %.2f.", ownerType, owner, cost, b.MonthlyLimit),
+		Kind:     notifier.BudgetKind,
+		Severity: severity,
+		Scope:    owner,
+		DedupKey: key,
+		EndsAt:   monthStart.AddDate(0, 1, 0),
+	}, true
+}
+
+func (b Budget) scope() (string, string) {
+	if b.Group != "" {
+		return models.GroupOwner, b.Group
+	}
+	return models.NamespaceOwner, b.Namespace
+}
+
+func monthToDateCost(ownerType, owner string, monthStart time.Time) (float64, error) {
+	var cost query.ResourceCost
+	if ownerType == models.GroupOwner {
+		groups, err := query.RetrieveGroupsWithLabels()
+		if err != nil {
+			return 0, err
+		}
+		for _, group := range groups {
+			if group.Xid == owner {
+				if cost, err = query.RetrieveGroupCost(owner, group.GetLabelsMap(), monthStart, time.Now()); err != nil {
+					return 0, err
+				}
+			}
+		}
+	} else {
+		namespaceCosts, err := query.RetrieveNamespaceCosts(owner, monthStart, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		if len(namespaceCosts) > 0 {
+			cost = namespaceCosts[0]
+		}
+	}
+	return cost.CPUCost + cost.MemoryCost + cost.StorageCost + cost.GPUCost, nil
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package budget
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestBreachNotification(t *testing.T) {
+	defer func() {
+		notified = make(map[string]string)
+	}()
+	monthStart := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	b := Budget{Namespace: "shop", MonthlyLimit: 100}
+
+	_, isBreached := b.breachNotification(80, monthStart)
+	utils.Assert(t, !isBreached, "budget breached at 80%% of its limit")
+
+	notification, isBreached := b.breachNotification(85, monthStart)
+	utils.Assert(t, isBreached, "budget not breached at 85%% of its limit")
+	utils.Equals(t, notifier.Warning, notification.Severity)
+	utils.Equals(t, "Budget of namespace shop is 85% spent", notification.Title)
+	utils.Equals(t, "budget-namespace-shop-2018-11", notification.DedupKey)
+	utils.Equals(t, "shop", notification.Scope)
+	utils.Assert(t, notification.EndsAt.Equal(time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)), "ends at %v", notification.EndsAt)
+	notified[notification.DedupKey] = notification.Severity
+
+	// every severity is notified once per month
+	_, isBreached = b.breachNotification(90, monthStart)
+	utils.Assert(t, !isBreached, "warning notified twice")
+	notification, isBreached = b.breachNotification(120, monthStart)
+	utils.Assert(t, isBreached && notification.Severity == notifier.Critical, "critical breach not notified: %v", notification)
+	notified[notification.DedupKey] = notification.Severity
+	_, isBreached = b.breachNotification(130, monthStart)
+	utils.Assert(t, !isBreached, "critical breach notified twice")
+	_, isBreached = b.breachNotification(85, monthStart)
+	utils.Assert(t, !isBreached, "warning notified after the critical breach")
+
+	// the breaches of the next month are notified again
+	_, isBreached = b.breachNotification(120, monthStart.AddDate(0, 1, 0))
+	utils.Assert(t, isBreached, "critical breach of the next month not notified")
+
+	notification, _ = Budget{Namespace: "shop", Group: "team-a", MonthlyLimit: 10}.breachNotification(12, monthStart)
+	utils.Equals(t, "budget-"+models.GroupOwner+"-team-a-2018-11", notification.DedupKey)
+}
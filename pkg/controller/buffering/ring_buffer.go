@@ -100,6 +100,14 @@ func (r *RingBuffer) RemoveN(n uint32) {
 	}
 }
 
+// Len returns the number of items in the buffer
+func (r *RingBuffer) Len() uint32 {
+	r.Mutex.Lock()
+	defer r.Mutex.Unlock()
+
+	return (r.end + r.Size - r.start) % r.Size
+}
+
 func (r *RingBuffer) isEmpty() bool {
 	return r.start == r.end
 }
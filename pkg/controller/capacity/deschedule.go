@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// nodeUsage is the capacity left on a node after placing its pods
+type nodeUsage struct {
+	node       models.Node
+	freeCPU    utils.Cores
+	freeMemory utils.GB
+}
+
+// RetrieveDrainCandidates returns the nodes which can be scaled down by migrating their pods to the other
+// nodes, least utilized nodes first. A node is a candidate only if the requests of all its pods (except
+// daemonset pods) fit on the capacity left on the nodes which are not drained. Nodes under memory or disk
+// pressure are neither drained nor used as migration targets.
+func RetrieveDrainCandidates() ([]DrainCandidate, error) {
+	nodes, err := query.RetrieveAliveNodesWithPods()
+	if err != nil {
+		return nil, err
+	}
+	catalog := pricing.GetCatalog()
+	pressures, _, _, err := retrieveMonthPressures()
+	if err != nil {
+		return nil, err
+	}
+	// pods moved to a node under pressure would be evicted again
+	underPressure := nodesUnderPressure(pressures)
+
+	usages := make([]*nodeUsage, len(nodes))
+	for i, node := range nodes {
+		usage := &nodeUsage{node: node, freeCPU: node.CPUCapity, freeMemory: node.MemoryCapacity}
+		for _, pod := range node.Pods {
+			usage.freeCPU -= pod.CPURequest
+			usage.freeMemory -= pod.MemoryRequest
+		}
+		usages[i] = usage
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].utilization() < usages[j].utilization()
+	})
+
+	var candidates []DrainCandidate
+	drained := make(map[string]bool)
+	for xid := range underPressure {
+		drained[xid] = true
+	}
+	for _, usage := range usages {
+		if underPressure[usage.node.Xid] {
+			continue
+		}
+		migrations, isDrainable := planMigrations(usage, usages, drained)
+		if !isDrainable {
+			continue
+		}
+		drained[usage.node.Xid] = true
+		candidates = append(candidates, DrainCandidate{
+			Node:           usage.node.Xid,
+			MonthlySavings: catalog.ListPrice(usage.node.CPUCapity, usage.node.MemoryCapacity) * hoursInMonth,
+			Pods:           migrations,
+		})
+	}
+	return candidates, nil
+}
+
+// planMigrations places the pods of the given node on the other nodes (first fit decreasing). The capacity
+// of target nodes is only reserved if every pod fits.
+func planMigrations(source *nodeUsage, usages []*nodeUsage, drained map[string]bool) ([]PodMigration, bool) {
+	var pods []*models.Pod
+	for _, pod := range source.node.Pods {
+		if pod.Daemonset == nil {
+			pods = append(pods, pod)
+		}
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		return pods[i].CPURequest > pods[j].CPURequest
+	})
+
+	freeCPU := make(map[string]utils.Cores)
+	freeMemory := make(map[string]utils.GB)
+	var migrations []PodMigration
+	for _, pod := range pods {
+		placed := false
+		for _, target := range usages {
+			xid := target.node.Xid
+			if xid == source.node.Xid || drained[xid] {
+				continue
+			}
+			if _, isPresent := freeCPU[xid]; !isPresent {
+				freeCPU[xid], freeMemory[xid] = target.freeCPU, target.freeMemory
+			}
+			if pod.CPURequest <= freeCPU[xid] && pod.MemoryRequest <= freeMemory[xid] {
+				freeCPU[xid] -= pod.CPURequest
+				freeMemory[xid] -= pod.MemoryRequest
+				migrations = append(migrations, PodMigration{
+					Namespace:  namespaceOf(*pod),
+					Pod:        podName(*pod),
+					TargetNode: xid,
+				})
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			return nil, false
+		}
+	}
+
+	for _, target := range usages {
+		if cpu, isPresent := freeCPU[target.node.Xid]; isPresent {
+			target.freeCPU, target.freeMemory = cpu, freeMemory[target.node.Xid]
+		}
+	}
+	return migrations, true
+}
+
+// utilization is the largest share of the node's cpu or memory which is requested
+func (usage *nodeUsage) utilization() float64 {
+	cpu, memory := 1.0, 1.0
+	if usage.node.CPUCapity > 0 {
+		cpu = 1 - float64(usage.freeCPU/usage.node.CPUCapity)
+	}
+	if usage.node.MemoryCapacity > 0 {
+		memory = 1 - float64(usage.freeMemory/usage.node.MemoryCapacity)
+	}
+	if cpu > memory {
+		return cpu
+	}
+	return memory
+}
+
+// podName strips the namespace from the pod's xid (namespace:name)
+func podName(pod models.Pod) string {
+	return strings.TrimPrefix(pod.Xid, namespaceOf(pod)+":")
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package capacity
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	units "github.com/vmware/purser/pkg/controller/utils"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newNodeUsage(xid string, cpu units.Cores, memory units.GB, freeCPU units.Cores, freeMemory units.GB,
+	pods ...*models.Pod) *nodeUsage {
+	node := models.Node{ID: dgraph.ID{Xid: xid}, CPUCapity: cpu, MemoryCapacity: memory, Pods: pods}
+	return &nodeUsage{node: node, freeCPU: freeCPU, freeMemory: freeMemory}
+}
+
+func newPod(xid string, cpu units.Cores, memory units.GB) *models.Pod {
+	return &models.Pod{ID: dgraph.ID{Xid: "shop:" + xid}, Namespace: &models.Namespace{ID: dgraph.ID{Xid: "shop"}},
+		CPURequest: cpu, MemoryRequest: memory}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	logs := newPod("logs", 0.5, 0.5)
+	logs.Daemonset = &models.Daemonset{ID: dgraph.ID{Xid: "kube-system:logs"}}
+	source := newNodeUsage("node-1", 4, 16, 1.5, 11, newPod("web", 1, 2), newPod("api", 1, 2.5), logs)
+	small := newNodeUsage("node-2", 4, 16, 1, 8)
+	large := newNodeUsage("node-3", 4, 16, 2, 4)
+	drainedNode := newNodeUsage("node-4", 4, 16, 4, 16)
+	usages := []*nodeUsage{source, small, large, drainedNode}
+
+	migrations, isDrainable := planMigrations(source, usages, map[string]bool{"node-4": true})
+	utils.Assert(t, isDrainable, "node-1 is not drainable")
+	// daemonset pods are not migrated, pods are placed on the first node they fit on
+	utils.Equals(t, []PodMigration{
+		{Namespace: "shop", Pod: "web", TargetNode: "node-2"},
+		{Namespace: "shop", Pod: "api", TargetNode: "node-3"},
+	}, migrations)
+	utils.Equals(t, 0.0, float64(small.freeCPU))
+	utils.Equals(t, 6.0, float64(small.freeMemory))
+	utils.Equals(t, 1.0, float64(large.freeCPU))
+	utils.Equals(t, 1.5, float64(large.freeMemory))
+	utils.Equals(t, 4.0, float64(drainedNode.freeCPU))
+
+	// the capacity of the targets is not reserved if a pod does not fit
+	other := newNodeUsage("node-5", 4, 16, 1, 4, newPod("db", 1, 1), newPod("cache", 0.5, 3))
+	_, isDrainable = planMigrations(other, append(usages, other), map[string]bool{"node-1": true, "node-4": true})
+	utils.Assert(t, !isDrainable, "node-5 is drainable")
+	utils.Equals(t, 1.0, float64(large.freeCPU))
+	utils.Equals(t, 1.5, float64(large.freeMemory))
+}
+
+func TestNodeUtilization(t *testing.T) {
+	utils.Equals(t, 0.75, newNodeUsage("node-1", 4, 16, 1, 12).utilization())
+	utils.Equals(t, 0.5, newNodeUsage("node-1", 4, 16, 3, 8).utilization())
+	// nodes without capacity are fully utilized
+	utils.Equals(t, 1.0, newNodeUsage("node-1", 0, 0, 0, 0).utilization())
+}
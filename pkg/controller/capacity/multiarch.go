@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	registryTimeout   = 10 * time.Second
+
+	manifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	imageIndexMediaType   = "application/vnd.oci.image.index.v1+json"
+	manifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+var (
+	multiArchMu sync.Mutex
+	// multiArchImages caches whether an image has an arm64 variant
+	multiArchImages = make(map[string]bool)
+	// multiArchPatterns are the images (path.Match patterns) known to have an arm64 variant, they are
+	// not looked up in the registry (ex: images of private registries)
+	multiArchPatterns []string
+)
+
+// Settings for the capacity analysis
+type Settings struct {
+	MultiArchImages []string `json:"multiArchImages,omitempty"`
+}
+
+// Setup configures the capacity analysis
+func Setup(settings Settings) {
+	multiArchMu.Lock()
+	defer multiArchMu.Unlock()
+	multiArchPatterns = settings.MultiArchImages
+}
+
+// isArm64Image returns true if the image matches a configured multi-arch pattern or if its manifest in the
+// registry is a manifest list (or OCI index) with a linux/arm64 variant. Lookups are cached per image.
+func isArm64Image(image string) bool {
+	multiArchMu.Lock()
+	defer multiArchMu.Unlock()
+	for _, pattern := range multiArchPatterns {
+		if isMatch, _ := path.Match(pattern, image); isMatch {
+			return true
+		}
+	}
+	if isArm64, isCached := multiArchImages[image]; isCached {
+		return isArm64
+	}
+
+	isArm64, err := lookupArm64Variant(image)
+	if err != nil {
+		log.Debugf("unable to look up platforms of image: (%s), error: (%v)", image, err)
+		return false
+	}
+	multiArchImages[image] = isArm64
+	return isArm64
+}
+
+func lookupArm64Variant(image string) (bool, error) {
+	registry, repository, reference := parseImage(image)
+	manifestURL := "https://" + registry + "/v2/" + repository + "/manifests/" + reference
+	client := http.Client{Timeout: registryTimeout}
+
+	resp, err := getManifest(client, manifestURL, "")
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, tokenErr := getAnonymousToken(client, resp.Header.Get("Www-Authenticate"))
+		closeBody(resp)
+		if tokenErr != nil {
+			return false, tokenErr
+		}
+		if resp, err = getManifest(client, manifestURL, token); err != nil {
+			return false, err
+		}
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("manifest request failed with status: %s", resp.Status)
+	}
+
+	mediaType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	if mediaType != manifestListMediaType && mediaType != imageIndexMediaType {
+		return false, nil
+	}
+	var index struct {
+		Manifests []struct {
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return false, err
+	}
+	for _, manifest := range index.Manifests {
+		if manifest.Platform.Architecture == "arm64" && manifest.Platform.OS == "linux" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func getManifest(client http.Client, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join([]string{manifestListMediaType, imageIndexMediaType, manifestMediaType}, ","))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.Do(req)
+}
+
+// getAnonymousToken requests a pull token from the auth server given in the registry's bearer challenge,
+// ex: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func getAnonymousToken(client http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry auth challenge: %s", challenge)
+	}
+	params := make(map[string]string)
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		keyValue := strings.SplitN(param, "=", 2)
+		if len(keyValue) == 2 {
+			params[strings.TrimSpace(keyValue[0])] = strings.Trim(keyValue[1], `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid registry auth realm: %s", params["realm"])
+	}
+	query := realm.Query()
+	query.Set("service", params["service"])
+	query.Set("scope", params["scope"])
+	realm.RawQuery = query.Encode()
+
+	resp, err := client.Get(realm.String())
+	if err != nil {
+		return "", err
+	}
+	defer closeBody(resp)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token request failed with status: %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	return token.AccessToken, nil
+}
+
+// parseImage splits an image reference (ex: nginx:1.15, gcr.io/project/app@sha256:...) into
+// registry, repository and tag or digest.
+func parseImage(image string) (string, string, string) {
+	registry, repository := dockerHubRegistry, image
+	if parts := strings.SplitN(image, "/", 2); len(parts) == 2 &&
+		(strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		registry, repository = parts[0], parts[1]
+	}
+
+	reference := "latest"
+	if at := strings.Index(repository, "@"); at >= 0 {
+		repository, reference = repository[:at], repository[at+1:]
+	} else if colon := strings.LastIndex(repository, ":"); colon >= 0 {
+		repository, reference = repository[:colon], repository[colon+1:]
+	}
+	if registry == dockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository, reference
+}
+
+func closeBody(resp *http.Response) {
+	if err := resp.Body.Close(); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package capacity
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image, registry, repository, reference string
+	}{
+		{"nginx", dockerHubRegistry, "library/nginx", "latest"},
+		{"nginx:1.15", dockerHubRegistry, "library/nginx", "1.15"},
+		{"bitnami/redis:5.0", dockerHubRegistry, "bitnami/redis", "5.0"},
+		{"gcr.io/project/app@sha256:abc", "gcr.io", "project/app", "sha256:abc"},
+		{"localhost:5000/app:v1", "localhost:5000", "app", "v1"},
+		{"localhost/app", "localhost", "app", "latest"},
+	}
+	for _, test := range tests {
+		registry, repository, reference := parseImage(test.image)
+		utils.Equals(t, []string{test.registry, test.repository, test.reference}, []string{registry, repository, reference})
+	}
+}
+
+func TestGetAnonymousToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:library/nginx:pull" || r.URL.Query().Get("service") != "registry.docker.io" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, err := w.Write([]byte(`{"access_token": "pull-token"}`))
+		utils.Ok(t, err)
+	}))
+	defer server.Close()
+	client := http.Client{}
+
+	token, err := getAnonymousToken(client, `Bearer realm="`+server.URL+`/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	utils.Ok(t, err)
+	utils.Equals(t, "pull-token", token)
+	_, err = getAnonymousToken(client, `Bearer realm="`+server.URL+`/token",service="registry.docker.io",scope="repository:library/redis:push"`)
+	utils.Assert(t, err != nil, "token granted for another scope")
+	_, err = getAnonymousToken(client, `Basic realm="registry"`)
+	utils.Assert(t, err != nil, "basic auth challenge is answered")
+}
+
+func TestComputeArmSavings(t *testing.T) {
+	Setup(Settings{MultiArchImages: []string{"nginx:*"}})
+	multiArchImages["private/app:1"] = false
+	defer func() {
+		Setup(Settings{})
+		multiArchImages = make(map[string]bool)
+	}()
+
+	deployment := &models.Replicaset{ID: dgraph.ID{Xid: "shop:web-5d9c"},
+		Deployment: &models.Deployment{ID: dgraph.ID{Xid: "shop:web"}}}
+	namespace := &models.Namespace{ID: dgraph.ID{Xid: "shop"}}
+	nginx := &models.Container{Image: "nginx:1.15"}
+	pods := []models.Pod{
+		{ID: dgraph.ID{Xid: "shop:web-5d9c-1"}, Namespace: namespace, Replicaset: deployment, CPURequest: 1, MemoryRequest: 2,
+			Containers: []*models.Container{nginx}},
+		{ID: dgraph.ID{Xid: "shop:web-5d9c-2"}, Namespace: namespace, Replicaset: deployment, CPURequest: 1, MemoryRequest: 2,
+			Containers: []*models.Container{nginx}},
+		// a workload with an image without arm64 variant can't move
+		{ID: dgraph.ID{Xid: "shop:worker"}, Namespace: namespace, CPURequest: 1, MemoryRequest: 2,
+			Containers: []*models.Container{nginx, {Image: "private/app:1"}}},
+	}
+	catalog := pricing.Catalog{CPU: 0.04, Memory: 0.005, InstanceTypes: []pricing.InstanceType{
+		{Name: "m5.large", CPU: 2, Memory: 8, PricePerHour: 0.096},
+		{Name: "c6g.large", CPU: 2, Memory: 4, PricePerHour: 0.068, Architecture: arm64},
+		{Name: "m6g.large", CPU: 2, Memory: 8, PricePerHour: 0.077, Architecture: arm64},
+	}}
+
+	savings, err := computeArmSavings(pods, catalog)
+	utils.Ok(t, err)
+	utils.Equals(t, "m6g.large", savings.NodeType)
+	utils.Equals(t, 1, len(savings.Workloads))
+	web := savings.Workloads[0]
+	utils.Equals(t, "shop:web", web.Workload)
+	utils.Equals(t, []string{"nginx:1.15"}, web.Images)
+	isClose := func(expected, actual float64) bool {
+		return math.Abs(expected-actual) < 1e-9
+	}
+	utils.Assert(t, isClose(73, web.MonthlyCost), "monthly cost: %f", web.MonthlyCost)
+	utils.Assert(t, isClose(73*0.077/0.12, web.NewMonthlyCost), "new monthly cost: %f", web.NewMonthlyCost)
+	utils.Assert(t, isClose(web.MonthlySavings, savings.MonthlySavings), "monthly savings: %f", savings.MonthlySavings)
+
+	_, err = computeArmSavings(pods, pricing.Catalog{CPU: 0.04, Memory: 0.005, InstanceTypes: catalog.InstanceTypes[:1]})
+	utils.Assert(t, err != nil, "arm savings computed without arm64 instance type")
+}
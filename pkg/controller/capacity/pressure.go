@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// pressureHours returns the hours spent by the nodes under each pressure condition in the window [from, to).
+// Open intervals last until to.
+func pressureHours(pressures []models.NodePressure, from, to time.Time) map[string]float64 {
+	hours := make(map[string]float64)
+	for _, pressure := range pressures {
+		start, err := time.Parse(time.RFC3339, pressure.StartTime)
+		if err != nil {
+			continue
+		}
+		end := to
+		if pressure.EndTime != "" {
+			if end, err = time.Parse(time.RFC3339, pressure.EndTime); err != nil {
+				continue
+			}
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			hours[pressure.Condition] += end.Sub(start).Hours()
+		}
+	}
+	return hours
+}
+
+// nodesUnderPressure returns the xids of the nodes having an open pressure interval
+func nodesUnderPressure(pressures []models.NodePressure) map[string]bool {
+	nodes := make(map[string]bool)
+	for _, pressure := range pressures {
+		if pressure.EndTime == "" && pressure.Node != nil {
+			nodes[pressure.Node.Xid] = true
+		}
+	}
+	return nodes
+}
+
+// retrieveMonthPressures returns the node pressure intervals of the current month
+func retrieveMonthPressures() ([]models.NodePressure, time.Time, time.Time, error) {
+	from, to := utils.GetCurrentMonthStartTime(), time.Now()
+	pressures, err := query.RetrieveNodePressures(from, to)
+	return pressures, from, to, err
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestPressureHours ...
+func TestPressureHours(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 11, 2, 0, 0, 0, 0, time.UTC)
+	node := &models.Node{ID: dgraph.ID{Xid: "node-1"}}
+	pressures := []models.NodePressure{
+		// started before the window
+		{Condition: "MemoryPressure", StartTime: "2018-10-31T22:00:00Z", EndTime: "2018-11-01T02:00:00Z", Node: node},
+		{Condition: "MemoryPressure", StartTime: "2018-11-01T10:00:00Z", EndTime: "2018-11-01T11:30:00Z", Node: node},
+		// still open
+		{Condition: "DiskPressure", StartTime: "2018-11-01T20:00:00Z", Node: node},
+	}
+
+	hours := pressureHours(pressures, from, to)
+	utils.Equals(t, map[string]float64{"MemoryPressure": 3.5, "DiskPressure": 4}, hours)
+	utils.Equals(t, map[string]bool{"node-1": true}, nodesUnderPressure(pressures))
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// shapeBucketBounds are the upper bounds (GB of memory per cpu) of the pod shape buckets. They roughly match
+// compute optimized (2), general purpose (4) and memory optimized (8) instance families.
+var shapeBucketBounds = []float64{1, 2, 4, 8}
+
+// Recommend packs the pods currently alive on every instance type of the pricing catalog and returns
+// the instance types sorted by projected monthly cost, cheapest first.
+func Recommend() (Recommendation, error) {
+	catalog := pricing.GetCatalog()
+	shapes, err := nodeShapes(catalog)
+	if err != nil {
+		return Recommendation{}, err
+	}
+	pods, err := query.RetrieveAlivePodRequests()
+	if err != nil {
+		return Recommendation{}, err
+	}
+	current := podShapes(pods)
+
+	recommendation := Recommendation{Shapes: shapeBuckets(current)}
+	recommendation.Current, err = fitInstanceType(current, DefaultPool, shapes[DefaultPool])
+	if err != nil {
+		return Recommendation{}, err
+	}
+	for _, instanceType := range catalog.InstanceTypes {
+		fit, err := fitInstanceType(current, instanceType.Name, shapes[instanceType.Name])
+		if err != nil {
+			log.Debugf("instance type: %s is not recommended, %v", instanceType.Name, err)
+			continue
+		}
+		fit.MonthlySavings = recommendation.Current.MonthlyCost - fit.MonthlyCost
+		recommendation.InstanceTypes = append(recommendation.InstanceTypes, fit)
+	}
+	sort.SliceStable(recommendation.InstanceTypes, func(i, j int) bool {
+		return recommendation.InstanceTypes[i].MonthlyCost < recommendation.InstanceTypes[j].MonthlyCost
+	})
+
+	pressures, from, to, err := retrieveMonthPressures()
+	if err != nil {
+		log.Errorf("unable to retrieve node pressures: %v", err)
+	} else {
+		recommendation.PressureHours = pressureHours(pressures, from, to)
+	}
+	return recommendation, nil
+}
+
+func fitInstanceType(pods []podShape, nodeType string, shape nodeShape) (InstanceTypeFit, error) {
+	moved := make([]podShape, len(pods))
+	for i, pod := range pods {
+		pod.pool = nodeType
+		moved[i] = pod
+	}
+	pools, err := binPack(moved, map[string]nodeShape{nodeType: shape})
+	if err != nil {
+		return InstanceTypeFit{}, err
+	}
+
+	fit := InstanceTypeFit{NodeType: nodeType}
+	for _, pool := range pools {
+		fit.Nodes = pool.Nodes
+		fit.MonthlyCost = pool.MonthlyCost
+		if pool.Nodes > 0 {
+			fit.CPUEfficiency = pool.CPURequest / (float64(pool.Nodes) * shape.cpu)
+			fit.MemoryEfficiency = pool.MemoryRequest / (float64(pool.Nodes) * shape.memory)
+		}
+	}
+	return fit, nil
+}
+
+// shapeBuckets returns the distribution of the memory per cpu ratio of the pods' requests.
+// Pods without a cpu request are counted in the unbounded bucket.
+func shapeBuckets(pods []podShape) []ShapeBucket {
+	buckets := make([]ShapeBucket, len(shapeBucketBounds)+1)
+	for i, bound := range shapeBucketBounds {
+		buckets[i].MaxMemoryPerCPU = bound
+	}
+	for _, pod := range pods {
+		index := len(shapeBucketBounds)
+		if pod.cpu > 0 {
+			index = sort.SearchFloat64s(shapeBucketBounds, pod.memory/pod.cpu)
+		}
+		buckets[index].Pods++
+		buckets[index].CPURequest += pod.cpu
+		buckets[index].MemoryRequest += pod.memory
+	}
+	return buckets
+}
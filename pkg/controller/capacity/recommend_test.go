@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package capacity
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestShapeBuckets(t *testing.T) {
+	pods := []podShape{
+		{cpu: 2, memory: 1},
+		{cpu: 1, memory: 2},
+		{cpu: 1, memory: 3},
+		{cpu: 0.5, memory: 8},
+		{memory: 1},
+	}
+	buckets := shapeBuckets(pods)
+	utils.Equals(t, len(shapeBucketBounds)+1, len(buckets))
+	utils.Equals(t, ShapeBucket{MaxMemoryPerCPU: 1, Pods: 1, CPURequest: 2, MemoryRequest: 1}, buckets[0])
+	// the bounds of the buckets are inclusive
+	utils.Equals(t, ShapeBucket{MaxMemoryPerCPU: 2, Pods: 1, CPURequest: 1, MemoryRequest: 2}, buckets[1])
+	utils.Equals(t, ShapeBucket{MaxMemoryPerCPU: 4, Pods: 1, CPURequest: 1, MemoryRequest: 3}, buckets[2])
+	utils.Equals(t, 0, buckets[3].Pods)
+	// pods with more than 8 GB per cpu and pods without cpu request are in the unbounded bucket
+	utils.Equals(t, ShapeBucket{Pods: 2, CPURequest: 0.5, MemoryRequest: 9}, buckets[4])
+}
+
+func TestFitInstanceType(t *testing.T) {
+	pods := []podShape{
+		{workload: "shop:web", cpu: 1, memory: 2, pool: DefaultPool},
+		{workload: "shop:web", cpu: 1, memory: 2, pool: DefaultPool},
+		{workload: "shop:api", cpu: 1, memory: 2, pool: DefaultPool},
+		{workload: "shop:db", cpu: 1, memory: 2, pool: "db"},
+	}
+	fit, err := fitInstanceType(pods, "m5.large", nodeShape{cpu: 2, memory: 8, pricePerHour: 0.1})
+	utils.Ok(t, err)
+	// the pods of every pool are moved to the instance type
+	utils.Equals(t, "m5.large", fit.NodeType)
+	utils.Equals(t, 2, fit.Nodes)
+	utils.Equals(t, 2*0.1*hoursInMonth, fit.MonthlyCost)
+	utils.Equals(t, 1.0, fit.CPUEfficiency)
+	utils.Equals(t, 0.5, fit.MemoryEfficiency)
+	// the pods of the cluster are not modified
+	utils.Equals(t, "db", pods[3].pool)
+
+	_, err = fitInstanceType(pods, "t3.small", nodeShape{cpu: 2, memory: 1.5, pricePerHour: 0.02})
+	utils.Assert(t, err != nil, "pods requesting more memory than the instance type are fitted")
+}
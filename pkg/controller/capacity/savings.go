@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+const arm64 = "arm64"
+
+// RetrieveSavings returns the cost saving opportunities of the cluster. Analyses which can not be done
+// (ex: no arm64 instance type in the pricing catalog) are left out.
+func RetrieveSavings() (Savings, error) {
+	pods, err := query.RetrieveAlivePodRequests()
+	if err != nil {
+		return Savings{}, err
+	}
+
+	catalog := pricing.GetCatalog()
+	savings := Savings{}
+	armSavings, err := computeArmSavings(pods, catalog)
+	if err != nil {
+		log.Infof("arm savings are not computed: %v", err)
+	} else {
+		savings.Arm = armSavings
+	}
+
+	pvcs, err := query.RetrieveIdlePvcs()
+	if err != nil {
+		return Savings{}, err
+	}
+	for _, pvc := range pvcs {
+		savings.IdlePvcs = append(savings.IdlePvcs, IdlePvc{
+			Pvc:             strings.TrimPrefix(pvc.Xid, namespaceOfPvc(pvc)+":"),
+			Namespace:       namespaceOfPvc(pvc),
+			StorageCapacity: pvc.StorageCapacity,
+			MonthlySavings:  pvc.StorageCapacity.Over(hoursInMonth).Cost(catalog.Storage),
+		})
+	}
+	sort.Slice(savings.IdlePvcs, func(i, j int) bool {
+		return savings.IdlePvcs[i].MonthlySavings > savings.IdlePvcs[j].MonthlySavings
+	})
+	return savings, nil
+}
+
+func namespaceOfPvc(pvc models.PersistentVolumeClaim) string {
+	if pvc.Namespace == nil {
+		return ""
+	}
+	return pvc.Namespace.Xid
+}
+
+// computeArmSavings flags the workloads whose images all have an arm64 variant. Their requests are priced
+// at the ratio between the price of the arm64 instance type and the catalog price of the same cpu and memory.
+func computeArmSavings(pods []models.Pod, catalog pricing.Catalog) (*ArmSavings, error) {
+	nodeType, priceRatio := cheapestArm64InstanceType(catalog)
+	if nodeType == "" {
+		return nil, fmt.Errorf("no arm64 instance type in the pricing catalog")
+	}
+
+	armSavings := &ArmSavings{NodeType: nodeType}
+	workloads := make(map[string]*WorkloadSaving)
+	isArmReady := make(map[string]bool)
+	for _, pod := range pods {
+		name := workloadOf(pod)
+		workload, isPresent := workloads[name]
+		if !isPresent {
+			workload = &WorkloadSaving{Workload: name, Namespace: namespaceOf(pod)}
+			workloads[name] = workload
+			isArmReady[name] = true
+		}
+		workload.MonthlyCost += catalog.ListPrice(pod.CPURequest, pod.MemoryRequest) * hoursInMonth
+		for _, container := range pod.Containers {
+			if !containsString(workload.Images, container.Image) {
+				workload.Images = append(workload.Images, container.Image)
+				isArmReady[name] = isArmReady[name] && container.Image != "" && isArm64Image(container.Image)
+			}
+		}
+	}
+
+	for name, workload := range workloads {
+		if !isArmReady[name] || len(workload.Images) == 0 || workload.MonthlyCost == 0 {
+			continue
+		}
+		workload.NewMonthlyCost = workload.MonthlyCost * priceRatio
+		workload.MonthlySavings = workload.MonthlyCost - workload.NewMonthlyCost
+		armSavings.MonthlySavings += workload.MonthlySavings
+		armSavings.Workloads = append(armSavings.Workloads, *workload)
+	}
+	sort.Slice(armSavings.Workloads, func(i, j int) bool {
+		return armSavings.Workloads[i].MonthlySavings > armSavings.Workloads[j].MonthlySavings
+	})
+	return armSavings, nil
+}
+
+// cheapestArm64InstanceType returns the arm64 instance type with the lowest price relative to the catalog
+// price of its cpu and memory, along with that ratio
+func cheapestArm64InstanceType(catalog pricing.Catalog) (string, float64) {
+	nodeType, priceRatio := "", 0.0
+	for _, instanceType := range catalog.InstanceTypes {
+		listPrice := instanceType.CPU*catalog.CPU + instanceType.Memory*catalog.Memory
+		if instanceType.Architecture != arm64 || listPrice == 0 {
+			continue
+		}
+		ratio := instanceType.PricePerHour / listPrice
+		if nodeType == "" || ratio < priceRatio {
+			nodeType, priceRatio = instanceType.Name, ratio
+		}
+	}
+	return nodeType, priceRatio
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+const (
+	defaultScalingDays = 7
+	maxScalingDays     = 90
+)
+
+// PreviewScaling replays the replicas and requests of the workload during the last days with the proposed HPA bounds
+// and VPA requests, and returns the projected cost delta
+func PreviewScaling(change ScalingChange) (ScalingPreview, error) {
+	if err := validateScalingChange(&change); err != nil {
+		return ScalingPreview{Change: change}, err
+	}
+	to := time.Now()
+	from := to.AddDate(0, 0, -change.Days)
+	workload, err := query.RetrieveWorkloadPodsByXid(change.Kind, change.Namespace+":"+change.Name, from, to)
+	if err != nil {
+		return ScalingPreview{Change: change}, err
+	}
+	if workload == nil {
+		return ScalingPreview{Change: change}, fmt.Errorf("%s not found: %s:%s", change.Kind, change.Namespace, change.Name)
+	}
+	preview := projectScaling(change, workload.Pods, from, to)
+	preview.From, preview.To = from.Format(time.RFC3339), to.Format(time.RFC3339)
+	return preview, nil
+}
+
+func validateScalingChange(change *ScalingChange) error {
+	if change.Kind == "" {
+		change.Kind = query.DeploymentKind
+	}
+	if change.Kind != query.DeploymentKind && change.Kind != query.StatefulSetKind {
+		return fmt.Errorf("kind must be %s or %s", query.DeploymentKind, query.StatefulSetKind)
+	}
+	if change.Namespace == "" || change.Name == "" {
+		return fmt.Errorf("namespace and name of the workload are required")
+	}
+	if change.MinReplicas != nil && *change.MinReplicas < 1 {
+		return fmt.Errorf("minReplicas must be at least 1")
+	}
+	if change.MaxReplicas != nil && *change.MaxReplicas < 1 {
+		return fmt.Errorf("maxReplicas must be at least 1")
+	}
+	if change.MinReplicas != nil && change.MaxReplicas != nil && *change.MinReplicas > *change.MaxReplicas {
+		return fmt.Errorf("minReplicas is greater than maxReplicas")
+	}
+	if (change.CPURequest != nil && *change.CPURequest <= 0) || (change.MemoryRequest != nil && *change.MemoryRequest <= 0) {
+		return fmt.Errorf("requests must be positive")
+	}
+	if change.Days <= 0 {
+		change.Days = defaultScalingDays
+	}
+	if change.Days > maxScalingDays {
+		return fmt.Errorf("days must be at most %d", maxScalingDays)
+	}
+	return nil
+}
+
+// replicaStep is the number of replicas running from a time until the next step
+type replicaStep struct {
+	at       time.Time
+	replicas int
+}
+
+// projectScaling clamps the replicas which ran during [from, to) to the proposed HPA bounds and prices the projected
+// replica hours at the historical cost of a replica hour, with cpu and memory costs scaled by the proposed requests.
+// Pods are already clipped to the window. Periods without replicas are not scaled up since the HPA is disabled then.
+func projectScaling(change ScalingChange, pods []query.PodRun, from, to time.Time) ScalingPreview {
+	preview := ScalingPreview{Change: change}
+	var steps []replicaStep
+	cpuHours, memoryHours, cpuCost, memoryCost := 0.0, 0.0, 0.0, 0.0
+	for _, pod := range pods {
+		preview.Cost += pod.Cost
+		cpuCost += pod.CPUCost
+		memoryCost += pod.MemoryCost
+		hours := pod.End.Sub(pod.Start).Hours()
+		if hours <= 0 {
+			continue
+		}
+		cpuHours += pod.CPURequest * hours
+		memoryHours += pod.MemoryRequest * hours
+		steps = append(steps, replicaStep{at: pod.Start, replicas: 1}, replicaStep{at: pod.End, replicas: -1})
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].at.Before(steps[j].at)
+	})
+
+	replicas := 0
+	for i, step := range steps {
+		replicas += step.replicas
+		if i+1 == len(steps) || replicas <= 0 {
+			continue
+		}
+		hours := steps[i+1].at.Sub(step.at).Hours()
+		preview.ReplicaHours += float64(replicas) * hours
+		preview.ProjectedReplicaHours += float64(clampReplicas(replicas, change)) * hours
+	}
+	if windowHours := to.Sub(from).Hours(); windowHours > 0 {
+		preview.AverageReplicas = preview.ReplicaHours / windowHours
+		preview.ProjectedReplicas = preview.ProjectedReplicaHours / windowHours
+	}
+	if preview.ReplicaHours == 0 {
+		return preview
+	}
+
+	preview.CPURequest = cpuHours / preview.ReplicaHours
+	preview.MemoryRequest = memoryHours / preview.ReplicaHours
+	cpuFactor, memoryFactor := 1.0, 1.0
+	if change.CPURequest != nil && preview.CPURequest > 0 {
+		cpuFactor = *change.CPURequest / preview.CPURequest
+	}
+	if change.MemoryRequest != nil && preview.MemoryRequest > 0 {
+		memoryFactor = *change.MemoryRequest / preview.MemoryRequest
+	}
+	replicaHourCost := (preview.Cost + cpuCost*(cpuFactor-1) + memoryCost*(memoryFactor-1)) / preview.ReplicaHours
+	preview.ProjectedCost = preview.ProjectedReplicaHours * replicaHourCost
+	preview.CostDelta = preview.ProjectedCost - preview.Cost
+	preview.MonthlyCostDelta = preview.CostDelta * 30 * 24 / to.Sub(from).Hours()
+	return preview
+}
+
+func clampReplicas(replicas int, change ScalingChange) int {
+	if change.MinReplicas != nil && replicas < *change.MinReplicas {
+		replicas = *change.MinReplicas
+	}
+	if change.MaxReplicas != nil && replicas > *change.MaxReplicas {
+		replicas = *change.MaxReplicas
+	}
+	return replicas
+}
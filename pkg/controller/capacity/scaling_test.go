@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestProjectScaling ...
+func TestProjectScaling(t *testing.T) {
+	from := time.Date(2018, 11, 5, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	pods := []query.PodRun{
+		{Start: from, End: from.Add(10 * time.Hour), CPURequest: 0.5, MemoryRequest: 1, CPUCost: 6, MemoryCost: 4, Cost: 10},
+		{Start: from.Add(5 * time.Hour), End: from.Add(15 * time.Hour), CPURequest: 0.5, MemoryRequest: 1, CPUCost: 6, MemoryCost: 4, Cost: 10},
+	}
+	maxReplicas, cpuRequest := 1, 0.25
+
+	preview := projectScaling(ScalingChange{MaxReplicas: &maxReplicas, CPURequest: &cpuRequest}, pods, from, to)
+	utils.Equals(t, 20.0, preview.ReplicaHours)
+	utils.Equals(t, 15.0, preview.ProjectedReplicaHours)
+	utils.Equals(t, 0.5, preview.CPURequest)
+	utils.Equals(t, 20.0, preview.Cost)
+	// a replica hour costs 0.7 with half the cpu request
+	utils.Equals(t, 10.5, preview.ProjectedCost)
+	utils.Equals(t, -9.5, preview.CostDelta)
+	utils.Equals(t, -285.0, preview.MonthlyCostDelta)
+
+	minReplicas := 2
+	preview = projectScaling(ScalingChange{MinReplicas: &minReplicas}, pods, from, to)
+	utils.Equals(t, 30.0, preview.ProjectedReplicaHours)
+	utils.Equals(t, 10.0, preview.CostDelta)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import "github.com/vmware/purser/pkg/controller/utils"
+
+// DefaultPool is the node pool made of nodes like the ones currently in the cluster
+const DefaultPool = "default"
+
+// Scenario is a list of hypothetical changes to the workloads of the cluster.
+type Scenario struct {
+	Changes []Change `json:"changes"`
+}
+
+// Change scales the replicas of the workloads selected by Namespace and/or Workload by ReplicaFactor
+// (ex: 1.2 adds 20% replicas) and/or moves them to the node pool made of NodeType instances.
+// Workload is the xid of a deployment, statefulset, daemonset or job (ex: default:frontend).
+type Change struct {
+	Namespace     string  `json:"namespace,omitempty"`
+	Workload      string  `json:"workload,omitempty"`
+	ReplicaFactor float64 `json:"replicaFactor,omitempty"`
+	NodeType      string  `json:"nodeType,omitempty"`
+}
+
+// Projection compares the node requirements and monthly cost of the cluster before and after a scenario.
+type Projection struct {
+	Current   []Pool  `json:"current"`
+	Projected []Pool  `json:"projected"`
+	Cost      float64 `json:"currentMonthlyCost"`
+	NewCost   float64 `json:"projectedMonthlyCost"`
+}
+
+// Pool gives the number of nodes of a type needed to fit the requests of the pods placed on it.
+// Memory is in GB.
+type Pool struct {
+	NodeType      string  `json:"nodeType"`
+	Nodes         int     `json:"nodes"`
+	Pods          int     `json:"pods"`
+	CPURequest    float64 `json:"cpuRequest"`
+	MemoryRequest float64 `json:"memoryRequest"`
+	MonthlyCost   float64 `json:"monthlyCost"`
+}
+
+// Recommendation gives the cpu:memory shapes of the pods of the cluster and how well they would fit on
+// every instance type of the pricing catalog compared to the current nodes.
+type Recommendation struct {
+	Current       InstanceTypeFit   `json:"current"`
+	Shapes        []ShapeBucket     `json:"shapes"`
+	InstanceTypes []InstanceTypeFit `json:"instanceTypes"`
+	// PressureHours are the hours spent by the nodes under memory or disk pressure this month, by condition.
+	// Pods use more than they request on nodes under pressure, so the efficiencies are optimistic.
+	PressureHours map[string]float64 `json:"pressureHours,omitempty"`
+}
+
+// ShapeBucket counts the pods requesting at most MaxMemoryPerCPU GB of memory per cpu.
+// The last bucket has no upper bound and MaxMemoryPerCPU is 0.
+type ShapeBucket struct {
+	MaxMemoryPerCPU float64 `json:"maxMemoryPerCPU"`
+	Pods            int     `json:"pods"`
+	CPURequest      float64 `json:"cpuRequest"`
+	MemoryRequest   float64 `json:"memoryRequest"`
+}
+
+// InstanceTypeFit is the result of packing all the pods of the cluster on nodes of an instance type.
+// Efficiencies are the fraction of the nodes' cpu and memory which is requested by the pods.
+type InstanceTypeFit struct {
+	NodeType         string  `json:"nodeType"`
+	Nodes            int     `json:"nodes"`
+	MonthlyCost      float64 `json:"monthlyCost"`
+	MonthlySavings   float64 `json:"monthlySavings"`
+	CPUEfficiency    float64 `json:"cpuEfficiency"`
+	MemoryEfficiency float64 `json:"memoryEfficiency"`
+}
+
+// DrainCandidate is a node which can be removed if its pods are migrated to the other nodes.
+// Daemonset pods are not listed since they are removed along with the node.
+type DrainCandidate struct {
+	Node           string         `json:"node"`
+	MonthlySavings float64        `json:"monthlySavings"`
+	Pods           []PodMigration `json:"pods"`
+}
+
+// PodMigration is a pod to evict from a drain candidate and the node its requests fit on.
+type PodMigration struct {
+	Namespace  string `json:"namespace"`
+	Pod        string `json:"pod"`
+	TargetNode string `json:"targetNode"`
+}
+
+// Savings lists the opportunities to reduce the cost of the cluster.
+type Savings struct {
+	Arm      *ArmSavings `json:"arm,omitempty"`
+	IdlePvcs []IdlePvc   `json:"idlePvcs,omitempty"`
+}
+
+// IdlePvc is a persistent volume claim which is not mounted by any pod, its storage cost can be saved by deleting it.
+// Storage capacity is in GB.
+type IdlePvc struct {
+	Pvc             string   `json:"pvc"`
+	Namespace       string   `json:"namespace"`
+	StorageCapacity utils.GB `json:"storageCapacity"`
+	MonthlySavings  float64  `json:"monthlySavings"`
+}
+
+// ArmSavings estimates the savings of moving the workloads having multi-arch images to nodes of NodeType,
+// the arm64 instance type of the pricing catalog with the cheapest compute.
+type ArmSavings struct {
+	NodeType       string           `json:"nodeType"`
+	MonthlySavings float64          `json:"monthlySavings"`
+	Workloads      []WorkloadSaving `json:"workloads"`
+}
+
+// WorkloadSaving is the monthly cost of a workload's requests before and after a change.
+type WorkloadSaving struct {
+	Workload       string   `json:"workload"`
+	Namespace      string   `json:"namespace"`
+	Images         []string `json:"images"`
+	MonthlyCost    float64  `json:"monthlyCost"`
+	NewMonthlyCost float64  `json:"newMonthlyCost"`
+	MonthlySavings float64  `json:"monthlySavings"`
+}
+
+// nodeShape is the capacity and price of a node of a pool
+type nodeShape struct {
+	cpu          float64
+	memory       float64
+	pricePerHour float64
+}
+
+// podShape is the requests of a pod and the pool it is placed on
+type podShape struct {
+	workload string
+	cpu      float64
+	memory   float64
+	pool     string
+}
+
+// ScalingChange is a proposed change of the HPA bounds (MinReplicas, MaxReplicas) and/or of the VPA target requests
+// per pod (CPURequest in cores, MemoryRequest in GB) of a deployment or statefulset. Days is the number of past days
+// whose scaling behavior is replayed, 7 by default.
+type ScalingChange struct {
+	Kind          string   `json:"kind,omitempty"`
+	Namespace     string   `json:"namespace"`
+	Name          string   `json:"name"`
+	MinReplicas   *int     `json:"minReplicas,omitempty"`
+	MaxReplicas   *int     `json:"maxReplicas,omitempty"`
+	CPURequest    *float64 `json:"cpuRequest,omitempty"`
+	MemoryRequest *float64 `json:"memoryRequest,omitempty"`
+	Days          int      `json:"days,omitempty"`
+}
+
+// ScalingPreview compares the cost of a workload in a past time window with its cost if the scaling change had been
+// applied. Requests are the average requests per pod. MonthlyCostDelta is the cost delta over 30 days.
+type ScalingPreview struct {
+	Change                ScalingChange `json:"change"`
+	From                  string        `json:"from"`
+	To                    string        `json:"to"`
+	ReplicaHours          float64       `json:"replicaHours"`
+	ProjectedReplicaHours float64       `json:"projectedReplicaHours"`
+	AverageReplicas       float64       `json:"averageReplicas"`
+	ProjectedReplicas     float64       `json:"projectedAverageReplicas"`
+	CPURequest            float64       `json:"cpuRequest"`
+	MemoryRequest         float64       `json:"memoryRequest"`
+	Cost                  float64       `json:"cost"`
+	ProjectedCost         float64       `json:"projectedCost"`
+	CostDelta             float64       `json:"costDelta"`
+	MonthlyCostDelta      float64       `json:"monthlyCostDelta"`
+}
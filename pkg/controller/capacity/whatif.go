@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+const hoursInMonth = 730
+
+// Project computes the node requirements and monthly cost of the cluster with and without the changes of
+// the scenario. Pods are bin-packed (first fit decreasing) on their pool using their current requests and
+// nodes are priced with the pricing catalog in use.
+func Project(scenario Scenario) (Projection, error) {
+	catalog := pricing.GetCatalog()
+	shapes, err := nodeShapes(catalog)
+	if err != nil {
+		return Projection{}, err
+	}
+	for _, change := range scenario.Changes {
+		if change.ReplicaFactor < 0 {
+			return Projection{}, fmt.Errorf("replica factor: %f is negative", change.ReplicaFactor)
+		}
+		if _, isKnown := shapes[poolOf(change.NodeType)]; !isKnown {
+			return Projection{}, fmt.Errorf("node type: %s is not in the pricing catalog", change.NodeType)
+		}
+	}
+
+	pods, err := query.RetrieveAlivePodRequests()
+	if err != nil {
+		return Projection{}, err
+	}
+	current := podShapes(pods)
+
+	projection := Projection{}
+	projection.Current, err = binPack(current, shapes)
+	if err != nil {
+		return Projection{}, err
+	}
+	projection.Projected, err = binPack(applyChanges(pods, current, scenario.Changes), shapes)
+	if err != nil {
+		return Projection{}, err
+	}
+	projection.Cost = totalCost(projection.Current)
+	projection.NewCost = totalCost(projection.Projected)
+	return projection, nil
+}
+
+// nodeShapes returns the node shape of every pool. The default pool is made of nodes of the average
+// size of the nodes currently in the cluster.
+func nodeShapes(catalog pricing.Catalog) (map[string]nodeShape, error) {
+	inventory, err := query.RetrieveInventory(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if inventory.Nodes == 0 {
+		return nil, fmt.Errorf("no nodes are alive in the cluster")
+	}
+
+	averageCPU := inventory.CPUCapacity / float64(inventory.Nodes)
+	averageMemory := inventory.MemoryCapacity / float64(inventory.Nodes)
+	shapes := map[string]nodeShape{
+		DefaultPool: {
+			cpu:          averageCPU,
+			memory:       averageMemory,
+			pricePerHour: averageCPU*catalog.CPU + averageMemory*catalog.Memory,
+		},
+	}
+	for _, instanceType := range catalog.InstanceTypes {
+		shapes[instanceType.Name] = nodeShape{
+			cpu:          instanceType.CPU,
+			memory:       instanceType.Memory,
+			pricePerHour: instanceType.PricePerHour,
+		}
+	}
+	return shapes, nil
+}
+
+func podShapes(pods []models.Pod) []podShape {
+	shapes := make([]podShape, len(pods))
+	for i, pod := range pods {
+		shapes[i] = podShape{
+			workload: workloadOf(pod),
+			cpu:      float64(pod.CPURequest),
+			memory:   float64(pod.MemoryRequest),
+			pool:     DefaultPool,
+		}
+	}
+	return shapes
+}
+
+// applyChanges returns the pods of the cluster after scaling and moving the workloads selected by the changes.
+// Changes are applied in order, so replica factors of several changes selecting the same workload are multiplied.
+func applyChanges(pods []models.Pod, shapes []podShape, changes []Change) []podShape {
+	type workload struct {
+		namespace string
+		pods      []podShape
+	}
+	var workloadOrder []string
+	workloads := make(map[string]*workload)
+	for i, pod := range pods {
+		name := shapes[i].workload
+		if _, isPresent := workloads[name]; !isPresent {
+			workloads[name] = &workload{namespace: namespaceOf(pod)}
+			workloadOrder = append(workloadOrder, name)
+		}
+		workloads[name].pods = append(workloads[name].pods, shapes[i])
+	}
+
+	var projected []podShape
+	for _, name := range workloadOrder {
+		w := workloads[name]
+		factor, pool := 1.0, DefaultPool
+		for _, change := range changes {
+			if !isSelected(change, w.namespace, name) {
+				continue
+			}
+			if change.ReplicaFactor != 0 {
+				factor *= change.ReplicaFactor
+			}
+			if change.NodeType != "" {
+				pool = change.NodeType
+			}
+		}
+
+		replicas := int(math.Floor(float64(len(w.pods))*factor + 0.5))
+		for i := 0; i < replicas; i++ {
+			// new replicas get the requests of the existing ones in turn
+			replica := w.pods[i%len(w.pods)]
+			replica.pool = pool
+			projected = append(projected, replica)
+		}
+	}
+	return projected
+}
+
+func isSelected(change Change, namespace, workload string) bool {
+	return (change.Namespace == "" || change.Namespace == namespace) &&
+		(change.Workload == "" || change.Workload == workload)
+}
+
+// binPack places the pods of every pool on as few nodes as it can with the first fit decreasing heuristic.
+func binPack(pods []podShape, shapes map[string]nodeShape) ([]Pool, error) {
+	podsByPool := make(map[string][]podShape)
+	for _, pod := range pods {
+		podsByPool[pod.pool] = append(podsByPool[pod.pool], pod)
+	}
+
+	var pools []Pool
+	for name, poolPods := range podsByPool {
+		shape := shapes[name]
+		sort.SliceStable(poolPods, func(i, j int) bool {
+			return shape.fraction(poolPods[i]) > shape.fraction(poolPods[j])
+		})
+
+		pool := Pool{NodeType: name, Pods: len(poolPods)}
+		var freeCPU, freeMemory []float64
+		for _, pod := range poolPods {
+			if pod.cpu > shape.cpu || pod.memory > shape.memory {
+				return nil, fmt.Errorf("requests of a pod of %s do not fit in a node of type: %s", pod.workload, name)
+			}
+			pool.CPURequest += pod.cpu
+			pool.MemoryRequest += pod.memory
+
+			placed := false
+			for i := range freeCPU {
+				if pod.cpu <= freeCPU[i] && pod.memory <= freeMemory[i] {
+					freeCPU[i] -= pod.cpu
+					freeMemory[i] -= pod.memory
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				freeCPU = append(freeCPU, shape.cpu-pod.cpu)
+				freeMemory = append(freeMemory, shape.memory-pod.memory)
+			}
+		}
+		pool.Nodes = len(freeCPU)
+		pool.MonthlyCost = float64(pool.Nodes) * shape.pricePerHour * hoursInMonth
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].NodeType < pools[j].NodeType
+	})
+	return pools, nil
+}
+
+// fraction is the largest share of the node's cpu or memory requested by the pod
+func (shape nodeShape) fraction(pod podShape) float64 {
+	return math.Max(pod.cpu/shape.cpu, pod.memory/shape.memory)
+}
+
+func totalCost(pools []Pool) float64 {
+	cost := 0.0
+	for _, pool := range pools {
+		cost += pool.MonthlyCost
+	}
+	return cost
+}
+
+func poolOf(nodeType string) string {
+	if nodeType == "" {
+		return DefaultPool
+	}
+	return nodeType
+}
+
+// workloadOf returns the xid of the controller owning the pod, pod's xid is returned for bare pods
+func workloadOf(pod models.Pod) string {
+	switch {
+	case pod.Replicaset != nil && pod.Replicaset.Deployment != nil:
+		return pod.Replicaset.Deployment.Xid
+	case pod.Replicaset != nil:
+		return pod.Replicaset.Xid
+	case pod.Statefulset != nil:
+		return pod.Statefulset.Xid
+	case pod.Daemonset != nil:
+		return pod.Daemonset.Xid
+	case pod.Job != nil:
+		return pod.Job.Xid
+	}
+	return pod.Xid
+}
+
+func namespaceOf(pod models.Pod) string {
+	if pod.Namespace == nil {
+		return ""
+	}
+	return pod.Namespace.Xid
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package capacity
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestBinPack ...
+func TestBinPack(t *testing.T) {
+	shapes := map[string]nodeShape{DefaultPool: {cpu: 4, memory: 16, pricePerHour: 0.2}}
+	pods := []podShape{
+		{cpu: 1, memory: 2, pool: DefaultPool},
+		{cpu: 3, memory: 4, pool: DefaultPool},
+		{cpu: 2, memory: 12, pool: DefaultPool},
+		{cpu: 2, memory: 2, pool: DefaultPool},
+	}
+
+	pools, err := binPack(pods, shapes)
+	utils.Ok(t, err)
+	utils.Equals(t, 1, len(pools))
+	utils.Equals(t, 2, pools[0].Nodes)
+	utils.Equals(t, 4, pools[0].Pods)
+	utils.Equals(t, float64(2)*shapes[DefaultPool].pricePerHour*hoursInMonth, pools[0].MonthlyCost)
+
+	_, err = binPack([]podShape{{cpu: 5, pool: DefaultPool}}, shapes)
+	utils.Assert(t, err != nil, "pod bigger than a node is not reported")
+}
+
+// TestApplyChanges ...
+func TestApplyChanges(t *testing.T) {
+	frontend := models.Pod{
+		ID:         dgraph.ID{Xid: "default:frontend-1"},
+		Namespace:  &models.Namespace{ID: dgraph.ID{Xid: "default"}},
+		Replicaset: &models.Replicaset{Deployment: &models.Deployment{ID: dgraph.ID{Xid: "default:frontend"}}},
+	}
+	bare := models.Pod{
+		ID:        dgraph.ID{Xid: "tools:debug"},
+		Namespace: &models.Namespace{ID: dgraph.ID{Xid: "tools"}},
+	}
+	pods := []models.Pod{frontend, frontend, frontend, frontend, frontend, bare}
+
+	projected := applyChanges(pods, podShapes(pods), []Change{
+		{Namespace: "default", ReplicaFactor: 1.2},
+		{Workload: "tools:debug", NodeType: "m5.large"},
+	})
+	utils.Equals(t, 7, len(projected))
+	utils.Equals(t, "default:frontend", projected[5].workload)
+	utils.Equals(t, "m5.large", projected[6].pool)
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chargeback
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Groupings of the chargeback report
+const (
+	ByNamespace = "namespace"
+	ByLabel     = "label"
+)
+
+// Unallocated is the owner of the cost of the pods without the label key of a report grouped by label
+const Unallocated = "unallocated"
+
+// Report is the chargeback of a time window: the cost of every namespace, or of every value of a label key
+type Report struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	GroupBy     string  `json:"groupBy"`
+	Label       string  `json:"label,omitempty"`
+	Rows        []Row   `json:"rows"`
+	TotalCost   float64 `json:"totalCost"`
+	ChargedCost float64 `json:"chargedCost"`
+}
+
+// Row is the usage (in unit hours) and cost of an owner (namespace or label value) of the report. ChargedCost is the
+// cost with the markup of the owner, if any.
+type Row struct {
+	Owner            string  `json:"owner"`
+	CPUHours         float64 `json:"cpuHours"`
+	MemoryGBHours    float64 `json:"memoryGBHours"`
+	StorageGBHours   float64 `json:"storageGBHours"`
+	GPUHours         float64 `json:"gpuHours"`
+	CPUCost          float64 `json:"cpuCost"`
+	MemoryCost       float64 `json:"memoryCost"`
+	GPUCost          float64 `json:"gpuCost"`
+	StorageCost      float64 `json:"storageCost"`
+	SnapshotCost     float64 `json:"snapshotCost"`
+	LoadBalancerCost float64 `json:"loadBalancerCost"`
+	IdleCost         float64 `json:"idleCost"`
+	AmortizedCost    float64 `json:"amortizedCost"`
+	NetworkCost      float64 `json:"networkCost"`
+	ExternalCost     float64 `json:"externalCost"`
+	TotalCost        float64 `json:"totalCost"`
+	MarkupPercent    float64 `json:"markupPercent"`
+	ChargedCost      float64 `json:"chargedCost"`
+}
+
+// Generate returns the chargeback report of the window [from, to) per namespace, or per value of the label key when
+// it is given. Namespaces are charged their persistent volume claims, snapshots, load balancers, share of the idle
+// capacity split by burst usage and of the upfront costs, the traffic sent by their pods and the external costs
+// attributed to them, with their markup. Label values are charged the cost of their pods.
+func Generate(label string, from, to time.Time) (Report, error) {
+	report := Report{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), GroupBy: ByNamespace, Label: label}
+	var costs []query.ResourceCost
+	var err error
+	if label == "" {
+		if costs, err = query.RetrieveNamespaceCosts(query.All, from, to); err != nil {
+			return report, err
+		}
+		if err = query.DistributeIdleCosts(costs, from, to); err != nil {
+			return report, err
+		}
+		query.DistributeAmortizedCosts(costs, from, to)
+		traffic, err := query.RetrieveNetworkTraffic(query.All, from, to)
+		if err != nil {
+			return report, err
+		}
+		costs = query.AddNetworkCosts(costs, traffic.Pods)
+		externalCosts, err := query.RetrieveExternalCostsInWindow(from, to)
+		if err != nil {
+			return report, err
+		}
+		costs = query.AddExternalCosts(costs, externalCosts)
+		invoice.MarkUpNamespaceCosts(costs)
+	} else {
+		report.GroupBy = ByLabel
+		if costs, err = query.RetrieveLabelValueCostsInWindow(label, from, to); err != nil {
+			return report, err
+		}
+	}
+	fillReport(&report, costs)
+	return report, nil
+}
+
+// fillReport sets the rows of the report from the costs, with the highest charged cost first
+func fillReport(report *Report, costs []query.ResourceCost) {
+	report.Rows = make([]Row, 0, len(costs))
+	for _, cost := range costs {
+		row := newRow(cost)
+		report.TotalCost += row.TotalCost
+		report.ChargedCost += row.ChargedCost
+		report.Rows = append(report.Rows, roundRow(row))
+	}
+	report.TotalCost = utils.Round(report.TotalCost, utils.CostPrecision)
+	report.ChargedCost = utils.Round(report.ChargedCost, utils.CostPrecision)
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		if report.Rows[i].ChargedCost != report.Rows[j].ChargedCost {
+			return report.Rows[i].ChargedCost > report.Rows[j].ChargedCost
+		}
+		return report.Rows[i].Owner < report.Rows[j].Owner
+	})
+}
+
+func newRow(cost query.ResourceCost) Row {
+	row := Row{
+		Owner:          cost.Xid,
+		CPUHours:       float64(cost.CPU),
+		MemoryGBHours:  float64(cost.Memory),
+		StorageGBHours: float64(cost.Storage),
+		GPUHours:       cost.GPU,
+		CPUCost:        cost.CPUCost,
+		MemoryCost:     cost.MemoryCost,
+		GPUCost:        cost.GPUCost,
+		TotalCost:      cost.TotalCost,
+		MarkupPercent:  cost.MarkupPercent,
+		ChargedCost:    cost.TotalCost,
+	}
+	if row.Owner == "" {
+		row.Owner = Unallocated
+	}
+	for _, item := range cost.LineItems {
+		switch item.Category {
+		case query.PersistentVolumeLineItem:
+			row.StorageCost += item.Cost
+		case query.SnapshotLineItem:
+			row.SnapshotCost += item.Cost
+		case query.LoadBalancerLineItem:
+			row.LoadBalancerCost += item.Cost
+		case query.IdleLineItem:
+			row.IdleCost += item.Cost
+		case query.AmortizationLineItem:
+			row.AmortizedCost += item.Cost
+		case query.NetworkLineItem:
+			row.NetworkCost += item.Cost
+		case query.ExternalLineItem:
+			row.ExternalCost += item.Cost
+		}
+	}
+	if cost.MarkupPercent != 0 {
+		row.ChargedCost = cost.MarkedUpCost
+	}
+	return row
+}
+
+func roundRow(row Row) Row {
+	for _, value := range []*float64{&row.CPUHours, &row.MemoryGBHours, &row.StorageGBHours, &row.GPUHours} {
+		*value = utils.Round(*value, utils.QuantityPrecision)
+	}
+	for _, value := range []*float64{&row.CPUCost, &row.MemoryCost, &row.GPUCost, &row.StorageCost, &row.SnapshotCost,
+		&row.LoadBalancerCost, &row.IdleCost, &row.AmortizedCost, &row.NetworkCost, &row.ExternalCost, &row.TotalCost,
+		&row.ChargedCost} {
+		*value = utils.Round(*value, utils.CostPrecision)
+	}
+	return row
+}
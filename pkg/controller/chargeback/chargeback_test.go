@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chargeback
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestFillReport(t *testing.T) {
+	costs := []query.ResourceCost{
+		{Xid: "web", CPU: 24, CPUCost: 0.5, MemoryCost: 0.25, TotalCost: 1.75, LineItems: []query.LineItem{
+			{Category: query.ComputeLineItem, Cost: 0.75},
+			{Category: query.PersistentVolumeLineItem, Cost: 0.5},
+			{Category: query.LoadBalancerLineItem, Cost: 0.5},
+		}},
+		{Xid: "shop", CPU: 48, CPUCost: 1, TotalCost: 1.3, MarkupPercent: 50, MarkedUpCost: 1.95, LineItems: []query.LineItem{
+			{Category: query.ComputeLineItem, Cost: 1},
+			{Category: query.IdleLineItem, Cost: 0.1},
+			{Category: query.AmortizationLineItem, Cost: 0.2},
+		}},
+		{CPU: 1.0000001, CPUCost: 0.1, TotalCost: 0.1},
+		{Xid: "search", TotalCost: 0.6, LineItems: []query.LineItem{
+			{Category: query.NetworkLineItem, Cost: 0.2},
+			{Category: query.ExternalLineItem, Cost: 0.4},
+		}},
+	}
+	report := Report{GroupBy: ByLabel, Label: "team"}
+	fillReport(&report, costs)
+
+	utils.Equals(t, []Row{
+		{Owner: "shop", CPUHours: 48, CPUCost: 1, IdleCost: 0.1, AmortizedCost: 0.2, TotalCost: 1.3, MarkupPercent: 50, ChargedCost: 1.95},
+		{Owner: "web", CPUHours: 24, CPUCost: 0.5, MemoryCost: 0.25, StorageCost: 0.5, LoadBalancerCost: 0.5, TotalCost: 1.75, ChargedCost: 1.75},
+		{Owner: "search", NetworkCost: 0.2, ExternalCost: 0.4, TotalCost: 0.6, ChargedCost: 0.6},
+		{Owner: Unallocated, CPUHours: 1, CPUCost: 0.1, TotalCost: 0.1, ChargedCost: 0.1},
+	}, report.Rows)
+	utils.Equals(t, 3.75, report.TotalCost)
+	utils.Equals(t, 4.4, report.ChargedCost)
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := Report{
+		From:    "2018-11-01T00:00:00Z",
+		To:      "2018-12-01T00:00:00Z",
+		GroupBy: ByNamespace,
+		Rows:    []Row{{Owner: "web", CPUHours: 24, CPUCost: 0.5, TotalCost: 0.5, MarkupPercent: 10, ChargedCost: 0.55}},
+	}
+	var buffer bytes.Buffer
+	utils.Ok(t, WriteCSV(&buffer, report))
+	utils.Equals(t, "from,to,group_by,label,owner,cpu_hours,memory_gb_hours,storage_gb_hours,gpu_hours,cpu_cost,memory_cost,"+
+		"gpu_cost,storage_cost,snapshot_cost,load_balancer_cost,idle_cost,amortized_cost,network_cost,external_cost,total_cost,"+
+		"markup_percent,charged_cost\n"+
+		"2018-11-01T00:00:00Z,2018-12-01T00:00:00Z,namespace,,web,24,0,0,0,0.5,0,0,0,0,0,0,0,0,0,0.5,10,0.55\n", buffer.String())
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chargeback
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// csvHeader names the columns of the csv report, every row repeats the window and the grouping so that the rows of
+// several reports can be imported in the same table
+var csvHeader = []string{"from", "to", "group_by", "label", "owner", "cpu_hours", "memory_gb_hours",
+	"storage_gb_hours", "gpu_hours", "cpu_cost", "memory_cost", "gpu_cost", "storage_cost", "snapshot_cost",
+	"load_balancer_cost", "idle_cost", "amortized_cost", "network_cost", "external_cost", "total_cost", "markup_percent",
+	"charged_cost"}
+
+// WriteCSV writes the rows of the report in csv format with a header
+func WriteCSV(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		record := []string{report.From, report.To, report.GroupBy, report.Label, row.Owner}
+		for _, value := range []float64{row.CPUHours, row.MemoryGBHours, row.StorageGBHours, row.GPUHours, row.CPUCost,
+			row.MemoryCost, row.GPUCost, row.StorageCost, row.SnapshotCost, row.LoadBalancerCost, row.IdleCost, row.AmortizedCost,
+			row.NetworkCost, row.ExternalCost, row.TotalCost, row.MarkupPercent, row.ChargedCost} {
+			record = append(record, strconv.FormatFloat(value, 'f', -1, 64))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
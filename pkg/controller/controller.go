@@ -18,17 +18,20 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
+	"sync"
 
 	log "github.com/Sirupsen/logrus"
 
 	groups_v1 "github.com/vmware/purser/pkg/apis/groups/v1"
 	subscriber_v1 "github.com/vmware/purser/pkg/apis/subscriber/v1"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/memory"
+	"github.com/vmware/purser/pkg/controller/sharding"
+	"github.com/vmware/purser/pkg/controller/supervisor"
 
 	apps_v1beta1 "k8s.io/api/apps/v1beta1"
 	batch_v1 "k8s.io/api/batch/v1"
@@ -53,6 +56,8 @@ type Controller struct {
 	queue     workqueue.RateLimitingInterface
 	informer  cache.SharedIndexInformer
 	conf      *Config
+
+	resourceType string
 }
 
 // Event indicate the informerEvent
@@ -64,10 +69,12 @@ type Event struct {
 	captureTime  meta_v1.Time
 }
 
-// Start runs the controller goroutine.
+// Start runs the controller goroutines. It returns once ctx is done and the events queued by the controllers
+// are handed over to the event buffer.
 // nolint: gocyclo, interfacer
-func Start(conf *Config) {
+func Start(ctx context.Context, conf *Config) {
 	Kubeclient = conf.Kubeclient
+	var wg sync.WaitGroup
 
 	if conf.Resource.Pod {
 		informer := cache.NewSharedIndexInformer(
@@ -86,10 +93,10 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Pod")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
+		go wait.Until(func() { prunePods(informer, false) }, podPruneInterval, ctx.Done())
+		memory.OnPressure(func(aggressive bool) { prunePods(informer, aggressive) })
 	}
 
 	if conf.Resource.Node {
@@ -109,10 +116,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Node")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.PersistentVolume {
@@ -132,10 +137,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "PersistentVolume")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.PersistentVolumeClaim {
@@ -155,10 +158,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "PersistentVolumeClaim")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Service {
@@ -178,10 +179,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Service")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.ReplicaSet {
@@ -201,10 +200,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "ReplicaSet")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.DaemonSet {
@@ -224,10 +221,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "DaemonSet")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Deployment {
@@ -247,10 +242,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Deployment")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.StatefulSet {
@@ -270,10 +263,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "StatefulSet")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Job {
@@ -293,10 +284,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Job")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Namespace {
@@ -316,10 +305,29 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Namespace")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
+	}
 
-		go c.Run(stopCh)
+	if conf.Resource.Event {
+		informer := cache.NewSharedIndexInformer(
+			&cache.ListWatch{
+				ListFunc: func(options meta_v1.ListOptions) (runtime.Object, error) {
+					return Kubeclient.CoreV1().Events(meta_v1.NamespaceAll).List(options)
+				},
+				WatchFunc: func(options meta_v1.ListOptions) (watch.Interface, error) {
+					return Kubeclient.CoreV1().Events(meta_v1.NamespaceAll).Watch(options)
+				},
+			},
+			&api_v1.Event{},
+			0,
+			cache.Indexers{},
+		)
+
+		c := newResourceController(Kubeclient, informer, "Event")
+		c.conf = conf
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Group {
@@ -339,10 +347,8 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Group")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
 	if conf.Resource.Subscriber {
@@ -362,16 +368,13 @@ func Start(conf *Config) {
 
 		c := newResourceController(Kubeclient, informer, "Subscriber")
 		c.conf = conf
-		stopCh := make(chan struct{})
-		defer close(stopCh)
-
-		go c.Run(stopCh)
+		wg.Add(1)
+		go c.Run(ctx, &wg)
 	}
 
-	sigterm := make(chan os.Signal, 1)
-	signal.Notify(sigterm, syscall.SIGTERM)
-	signal.Notify(sigterm, syscall.SIGINT)
-	<-sigterm
+	<-ctx.Done()
+	wg.Wait()
+	log.Info("controller queues are drained")
 }
 
 func newResourceController(client kubernetes.Interface, informer cache.SharedIndexInformer, resourceType string) *Controller {
@@ -380,17 +383,27 @@ func newResourceController(client kubernetes.Interface, informer cache.SharedInd
 	var err error
 	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
+			if !isRelevant(obj) {
+				return
+			}
 			newEvent.key, err = cache.MetaNamespaceKeyFunc(obj)
 			newEvent.eventType = Create
 			newEvent.resourceType = resourceType
 			newEvent.captureTime = meta_v1.Now()
-			log.Printf("Processing add to %v: %s", resourceType, newEvent.key)
-			if err == nil {
+			if err == nil && isInShard(resourceType, newEvent.key) {
+				log.Printf("Processing add to %v: %s", resourceType, newEvent.key)
 				queue.Add(newEvent)
 			}
 		},
 		// TODO: Fixme
 		UpdateFunc: func(old, new interface{}) {
+			if isUpdateRelevant(old, new) {
+				key, keyErr := cache.MetaNamespaceKeyFunc(new)
+				if keyErr == nil && isInShard(resourceType, key) {
+					queue.Add(Event{key: key, eventType: Create, resourceType: resourceType, captureTime: meta_v1.Now()})
+				}
+				return
+			}
 			/*newEvent.key, err = cache.MetaNamespaceKeyFunc(old)
 			newEvent.eventType = "update"
 			newEvent.resourceType = resourceType
@@ -400,39 +413,107 @@ func newResourceController(client kubernetes.Interface, informer cache.SharedInd
 			}*/
 		},
 		DeleteFunc: func(obj interface{}) {
+			// kubernetes events expire after a while, the persisted events are kept
+			if resourceType == "Event" {
+				return
+			}
 			newEvent.key, err = cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 			newEvent.eventType = Delete
 			newEvent.resourceType = resourceType
 			newEvent.data = obj
 			newEvent.captureTime = meta_v1.Now()
-			log.Printf("Processing delete to %v: %s", resourceType, newEvent.key)
-			if err == nil {
+			if err == nil && isInShard(resourceType, newEvent.key) {
+				log.Printf("Processing delete to %v: %s", resourceType, newEvent.key)
 				queue.Add(newEvent)
 			}
 		},
 	})
 
+	// objects of namespaces moved to this replica are processed again, storing an object is idempotent. So are the
+	// objects once dgraph is reachable again after an outage, their events may have been processed while it was not.
+	requeueAll := func() {
+		for _, key := range informer.GetStore().ListKeys() {
+			if isInShard(resourceType, key) {
+				queue.Add(Event{key: key, eventType: Create, resourceType: resourceType, captureTime: meta_v1.Now()})
+			}
+		}
+	}
+	sharding.OnRebalance(requeueAll)
+	dgraph.OnReconnect(requeueAll)
+
 	return &Controller{
-		clientset: client,
-		informer:  informer,
-		queue:     queue,
+		clientset:    client,
+		informer:     informer,
+		queue:        queue,
+		resourceType: resourceType,
 	}
 }
 
-// Run initiates the controller
-func (c *Controller) Run(stopCh <-chan struct{}) {
+// isRelevant filters out the kubernetes events which are not related to cost, every other object is relevant
+func isRelevant(obj interface{}) bool {
+	if k8sEvent, isEvent := obj.(*api_v1.Event); isEvent {
+		return models.IsCostRelevantEvent(*k8sEvent)
+	}
+	return true
+}
+
+// isUpdateRelevant returns true for the updates which are stored again: a repeated kubernetes event updates
+// its count, a node entering or leaving memory or disk pressure changes its conditions and a namespace held by the
+// finalizer of purser is being deleted.
+func isUpdateRelevant(old, new interface{}) bool {
+	switch newObj := new.(type) {
+	case *api_v1.Namespace:
+		return newObj.DeletionTimestamp != nil && HasNamespaceFinalizer(*newObj)
+	case *api_v1.Event:
+		return models.IsCostRelevantEvent(*newObj)
+	case *api_v1.Node:
+		oldNode, isNode := old.(*api_v1.Node)
+		return isNode && models.HasPressureChanged(*oldNode, *newObj)
+	}
+	return false
+}
+
+// isInShard returns true if the object with the given key is processed by this controller replica. Namespaced
+// objects belong to the shard of their namespace, namespaces to the shard of their name and the other cluster
+// scoped objects to the first shard.
+func isInShard(resourceType, key string) bool {
+	ns, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return true
+	}
+	if resourceType == "Namespace" {
+		ns = name
+	}
+	if ns == "" {
+		return sharding.IsLeader()
+	}
+	return sharding.Owns(ns)
+}
+
+// Run initiates the controller. Once ctx is done, the events left in the queue are processed before it returns.
+// A panic while processing an event is recovered and the worker is restarted.
+func (c *Controller) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 	defer utilruntime.HandleCrash()
-	defer c.queue.ShutDown()
 
-	go c.informer.Run(stopCh)
+	go c.informer.Run(ctx.Done())
 
-	if !cache.WaitForCacheSync(stopCh, c.HasSynced) {
+	if !cache.WaitForCacheSync(ctx.Done(), c.HasSynced) {
 		utilruntime.HandleError(fmt.Errorf("Timed out waiting for caches to sync"))
+		c.queue.ShutDown()
 		return
 	}
 
 	log.Println("Purser controller synced and ready")
-	wait.Until(c.runWorker, time.Second, stopCh)
+	go func() {
+		<-ctx.Done()
+		// a shut down queue still hands out the queued events until it is empty
+		c.queue.ShutDown()
+	}()
+	// the worker is stopped by the shut down of the queue rather than by ctx, so that the queue is drained
+	supervisor.Run(ctx, "watcher-"+c.resourceType, func(context.Context) {
+		c.runWorker()
+	})
 }
 
 // HasSynced is required for the cache.Controller interface.
@@ -471,10 +552,18 @@ func (c *Controller) processNextItem() bool {
 }
 
 func (c *Controller) processItem(newEvent Event) error {
-	obj, _, err := c.informer.GetIndexer().GetByKey(newEvent.key)
+	obj, exists, err := c.informer.GetIndexer().GetByKey(newEvent.key)
 	if err != nil {
 		return fmt.Errorf("Error fetching object with key %s from store: %v", newEvent.key, err)
 	}
+	if !exists && newEvent.eventType == Create {
+		// the object is deleted or pruned from the cache before its creation is processed
+		return nil
+	}
+	if exists && !isRelevant(obj) {
+		// objects queued again after a shard rebalance are not filtered by the event handlers
+		return nil
+	}
 
 	// process events based on its type
 	switch newEvent.eventType {
@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+const (
+	// CostAnnotation holds the cost of the trailing 30 days of a deployment or statefulset
+	CostAnnotation = "purser.vmware.com/cost-30d"
+	// CostPriceVersionAnnotation holds the version of the pricing catalog with which the cost was computed
+	CostPriceVersionAnnotation = "purser.vmware.com/cost-price-version"
+
+	costAnnotationWindowDays = 30
+)
+
+// CostAnnotationSettings enable the reconciler which writes the cost of the trailing 30 days of every deployment
+// and statefulset as an annotation of the workload. It is disabled by default since it needs the patch permission
+// on deployments and statefulsets.
+type CostAnnotationSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+var (
+	costAnnotationsMu      sync.Mutex
+	costAnnotationsEnabled bool
+	// annotated holds the last annotation written on every workload so that unchanged costs are not patched
+	annotated = map[string]string{}
+	// patchWorkloadCost writes the cost annotations on a workload
+	patchWorkloadCost = patchCostAnnotations
+)
+
+// SetupCostAnnotations sets the settings of the cost annotations reconciler
+func SetupCostAnnotations(settings CostAnnotationSettings) {
+	costAnnotationsMu.Lock()
+	defer costAnnotationsMu.Unlock()
+	costAnnotationsEnabled = settings.Enabled
+}
+
+// ReconcileCostAnnotations writes the cost of the trailing 30 days of the deployments and statefulsets of the
+// namespaces processed by this controller replica as the purser.vmware.com/cost-30d annotation. The cost is rounded
+// to cents and workloads are patched only when it changes, so that the annotation doesn't churn GitOps diffs.
+func ReconcileCostAnnotations() {
+	costAnnotationsMu.Lock()
+	defer costAnnotationsMu.Unlock()
+	if !costAnnotationsEnabled || Kubeclient == nil {
+		return
+	}
+
+	to := time.Now()
+	costs, err := query.RetrieveWorkloadCostsInWindow(to.AddDate(0, 0, -costAnnotationWindowDays), to)
+	if err != nil {
+		log.Errorf("unable to retrieve workload costs to annotate, error: (%v)", err)
+		return
+	}
+	if patched := annotateWorkloadCosts(costs, pricing.GetCatalog().Version); patched > 0 {
+		log.Infof("updated cost annotations of %d workloads", patched)
+	}
+}
+
+// annotateWorkloadCosts patches the workloads owned by this replica whose cost changed since it was last written
+// and returns the number of patched workloads
+func annotateWorkloadCosts(costs []query.WorkloadCost, priceVersion string) int {
+	patched := 0
+	for _, cost := range costs {
+		namespace, name := splitWorkloadXid(cost.Xid)
+		if name == "" || !sharding.Owns(namespace) {
+			continue
+		}
+		value := costAnnotationValue(cost.TotalCost)
+		key := cost.Kind + "/" + cost.Xid
+		if annotated[key] == value {
+			continue
+		}
+		if err := patchWorkloadCost(cost.Kind, namespace, name, value, priceVersion); err != nil {
+			log.Errorf("unable to annotate cost of %s: (%s), error: (%v)", cost.Kind, cost.Xid, err)
+			continue
+		}
+		annotated[key] = value
+		patched++
+	}
+	return patched
+}
+
+// costAnnotationValue formats a cost rounded to cents, without currency nor exponent (ex: 1234.50)
+func costAnnotationValue(cost float64) string {
+	return strconv.FormatFloat(cost, 'f', 2, 64)
+}
+
+func patchCostAnnotations(kind, namespace, name, cost, priceVersion string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				CostAnnotation:             cost,
+				CostPriceVersionAnnotation: priceVersion,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if kind == query.StatefulSetKind {
+		_, err = Kubeclient.AppsV1beta1().StatefulSets(namespace).Patch(name, types.MergePatchType, patch)
+	} else {
+		_, err = Kubeclient.AppsV1beta1().Deployments(namespace).Patch(name, types.MergePatchType, patch)
+	}
+	return err
+}
+
+// splitWorkloadXid returns the namespace and name of a workload from its xid (namespace:name)
+func splitWorkloadXid(xid string) (string, string) {
+	parts := strings.SplitN(xid, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+	return parts[0], parts[1]
+}
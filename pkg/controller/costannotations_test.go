@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestCostAnnotationValue(t *testing.T) {
+	utils.Equals(t, "0.00", costAnnotationValue(0))
+	utils.Equals(t, "12.35", costAnnotationValue(12.345678))
+	utils.Equals(t, "1234.50", costAnnotationValue(1234.5))
+	utils.Equals(t, "123456789.00", costAnnotationValue(123456789))
+	utils.Equals(t, "0.00", costAnnotationValue(0.0049))
+}
+
+type costPatch struct {
+	kind, namespace, name, cost, priceVersion string
+}
+
+func stubCostPatches(fail bool) (*[]costPatch, func()) {
+	patches := []costPatch{}
+	original := patchWorkloadCost
+	annotated = map[string]string{}
+	patchWorkloadCost = func(kind, namespace, name, cost, priceVersion string) error {
+		if fail {
+			return fmt.Errorf("patch refused")
+		}
+		patches = append(patches, costPatch{kind, namespace, name, cost, priceVersion})
+		return nil
+	}
+	return &patches, func() {
+		patchWorkloadCost = original
+		annotated = map[string]string{}
+	}
+}
+
+func TestAnnotateWorkloadCosts(t *testing.T) {
+	patches, restore := stubCostPatches(false)
+	defer restore()
+
+	costs := []query.WorkloadCost{
+		{Kind: query.DeploymentKind, Xid: "shop:web", TotalCost: 12.346},
+		{Kind: query.StatefulSetKind, Xid: "shop:db", TotalCost: 40},
+		{Kind: query.DeploymentKind, Xid: "invalid", TotalCost: 1},
+	}
+	utils.Equals(t, 2, annotateWorkloadCosts(costs, "v1"))
+	utils.Equals(t, []costPatch{
+		{query.DeploymentKind, "shop", "web", "12.35", "v1"},
+		{query.StatefulSetKind, "shop", "db", "40.00", "v1"},
+	}, *patches)
+
+	// costs which round to the annotation already written are not patched again
+	costs[0].TotalCost = 12.3461
+	costs[1].TotalCost = 40.001
+	utils.Equals(t, 0, annotateWorkloadCosts(costs, "v1"))
+	utils.Equals(t, 2, len(*patches))
+
+	costs[1].TotalCost = 41
+	utils.Equals(t, 1, annotateWorkloadCosts(costs, "v1"))
+	utils.Equals(t, costPatch{query.StatefulSetKind, "shop", "db", "41.00", "v1"}, (*patches)[2])
+}
+
+func TestAnnotateWorkloadCostsRetriesFailedPatches(t *testing.T) {
+	_, restore := stubCostPatches(true)
+	defer restore()
+
+	costs := []query.WorkloadCost{{Kind: query.DeploymentKind, Xid: "shop:web", TotalCost: 12}}
+	utils.Equals(t, 0, annotateWorkloadCosts(costs, "v1"))
+	// a failed patch is not recorded so that it is retried by the next reconciliation
+	_, ok := annotated[query.DeploymentKind+"/shop:web"]
+	utils.Assert(t, !ok, "failed patch must not be recorded")
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package costcenter
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// Sources of the cost center hierarchy, an import replaces the cost centers of its source only
+const (
+	SourceCSV  = "csv"
+	SourceLDAP = "ldap"
+
+	defaultGroupClass    = "group"
+	defaultNameAttribute = "cn"
+	memberOfAttribute    = "memberof"
+)
+
+// LDAPSettings of the import of the cost center hierarchy from the groups of a directory (LDAP or Active Directory).
+// Every group of GroupClass under BaseDN is a cost center named by its NameAttribute, the parent of a group is the
+// first group it is a member of (memberOf). A cost center is mapped to the pods whose label LabelKey (default: the
+// first team label) has its name as value.
+type LDAPSettings struct {
+	URL              string `json:"url,omitempty"`
+	BindDN           string `json:"bindDN,omitempty"`
+	BindPasswordFile string `json:"bindPasswordFile,omitempty"`
+	BaseDN           string `json:"baseDN,omitempty"`
+	GroupClass       string `json:"groupClass,omitempty"`
+	NameAttribute    string `json:"nameAttribute,omitempty"`
+	LabelKey         string `json:"labelKey,omitempty"`
+}
+
+// Settings of the cost center hierarchy import, the csv import is always available through the api
+type Settings struct {
+	LDAP LDAPSettings `json:"ldap,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	settings Settings
+)
+
+// Setup sets the directory from which the cost center hierarchy is synced
+func Setup(s Settings) {
+	if s.LDAP.GroupClass == "" {
+		s.LDAP.GroupClass = defaultGroupClass
+	}
+	if s.LDAP.NameAttribute == "" {
+		s.LDAP.NameAttribute = defaultNameAttribute
+	}
+	if s.LDAP.LabelKey == "" {
+		s.LDAP.LabelKey = models.GetAttributionSettings().TeamLabels[0]
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	settings = s
+}
+
+// LDAPEnabled returns true if a directory is configured
+func LDAPEnabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return settings.LDAP.URL != ""
+}
+
+// ImportCSV replaces the cost centers imported from csv by the hierarchy of the csv file and returns them
+func ImportCSV(r io.Reader) ([]models.CostCenterItem, error) {
+	items, err := ParseCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = models.StoreCostCenters(items, SourceCSV); err != nil {
+		return nil, err
+	}
+	log.Infof("%d cost centers imported from csv", len(items))
+	return items, nil
+}
+
+// SyncLDAP replaces the cost centers imported from the directory by its current groups. It is scheduled to run
+// every hour when a directory is configured.
+func SyncLDAP() {
+	mu.RLock()
+	s := settings.LDAP
+	mu.RUnlock()
+	if s.URL == "" {
+		return
+	}
+
+	entries, err := searchGroups(s)
+	if err != nil {
+		log.Errorf("unable to read cost centers from ldap: (%s), error: %v", s.URL, err)
+		return
+	}
+	items := ldapCostCenters(entries, s.NameAttribute, s.LabelKey)
+	if err = models.ValidateCostCenters(items); err != nil {
+		log.Errorf("invalid cost center hierarchy in ldap: (%s), error: %v", s.URL, err)
+		return
+	}
+	if err = models.StoreCostCenters(items, SourceLDAP); err != nil {
+		log.Errorf("unable to store cost centers of ldap, error: %v", err)
+		return
+	}
+	log.Infof("%d cost centers synced from ldap", len(items))
+}
+
+func searchGroups(s LDAPSettings) ([]ldapEntry, error) {
+	password := ""
+	if s.BindPasswordFile != "" {
+		secret, err := ioutil.ReadFile(s.BindPasswordFile)
+		if err != nil {
+			return nil, err
+		}
+		password = strings.TrimSpace(string(secret))
+	}
+	conn, err := dialLDAP(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := conn.close(); closeErr != nil {
+			log.Debugf("unable to close ldap connection: %v", closeErr)
+		}
+	}()
+	if err = conn.bind(s.BindDN, password); err != nil {
+		return nil, err
+	}
+	return conn.search(s.BaseDN, s.GroupClass, []string{s.NameAttribute, memberOfAttribute})
+}
+
+// ldapCostCenters maps the groups to cost centers, groups without a name are skipped. The parent of a group is the
+// first group of the search it is a member of.
+func ldapCostCenters(entries []ldapEntry, nameAttribute, labelKey string) []models.CostCenterItem {
+	nameAttribute = strings.ToLower(nameAttribute)
+	names := map[string]string{}
+	for _, entry := range entries {
+		if values := entry.Attributes[nameAttribute]; len(values) > 0 {
+			names[strings.ToLower(entry.DN)] = values[0]
+		}
+	}
+
+	items := []models.CostCenterItem{}
+	for _, entry := range entries {
+		name, hasName := names[strings.ToLower(entry.DN)]
+		if !hasName {
+			continue
+		}
+		item := models.CostCenterItem{Name: name, Labels: map[string]string{labelKey: name}}
+		for _, group := range entry.Attributes[memberOfAttribute] {
+			if parent, isGroup := names[strings.ToLower(group)]; isGroup {
+				item.Parent = parent
+				break
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package costcenter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// Columns of the cost center csv file, the first row is the header. Namespaces and labels hold several values
+// separated by semicolons, ex: "web;shop" and "team=web;app=shop".
+const (
+	nameColumn       = "name"
+	parentColumn     = "parent"
+	namespacesColumn = "namespaces"
+	labelsColumn     = "labels"
+)
+
+// ParseCSV reads the cost center hierarchy from a csv file whose header names the columns: name (required),
+// parent, namespaces and labels, in any order
+func ParseCSV(r io.Reader) ([]models.CostCenterItem, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read csv header: %v", err)
+	}
+	columns := map[string]int{}
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, hasName := columns[nameColumn]; !hasName {
+		return nil, fmt.Errorf("csv header has no %s column", nameColumn)
+	}
+	reader.FieldsPerRecord = len(header)
+
+	items := []models.CostCenterItem{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(column string) string {
+			if i, isColumn := columns[column]; isColumn {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		item := models.CostCenterItem{Name: field(nameColumn), Parent: field(parentColumn), Namespaces: splitValues(field(namespacesColumn))}
+		if item.Name == "" {
+			return nil, fmt.Errorf("line %d: cost center name is empty", line)
+		}
+		for _, selector := range splitValues(field(labelsColumn)) {
+			keyValue := strings.SplitN(selector, "=", 2)
+			if len(keyValue) != 2 || keyValue[0] == "" {
+				return nil, fmt.Errorf("line %d: invalid label selector: %s, expected key=value", line, selector)
+			}
+			if item.Labels == nil {
+				item.Labels = map[string]string{}
+			}
+			item.Labels[keyValue[0]] = keyValue[1]
+		}
+		items = append(items, item)
+	}
+	return items, models.ValidateCostCenters(items)
+}
+
+func splitValues(field string) []string {
+	var values []string
+	for _, value := range strings.Split(field, ";") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
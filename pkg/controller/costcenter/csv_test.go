@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package costcenter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestParseCSV(t *testing.T) {
+	file := `Name,Parent,Namespaces,Labels
+engineering,,,
+platform,engineering,"kube-system;monitoring",
+shop,engineering,shop,"team=web;app=cart"
+`
+	items, err := ParseCSV(strings.NewReader(file))
+	utils.Ok(t, err)
+	utils.Equals(t, []models.CostCenterItem{
+		{Name: "engineering"},
+		{Name: "platform", Parent: "engineering", Namespaces: []string{"kube-system", "monitoring"}},
+		{Name: "shop", Parent: "engineering", Namespaces: []string{"shop"}, Labels: map[string]string{"team": "web", "app": "cart"}},
+	}, items)
+}
+
+func TestParseCSVInvalid(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("namespaces\nshop\n"))
+	utils.Assert(t, err != nil, "csv without name column is accepted")
+
+	_, err = ParseCSV(strings.NewReader("name,labels\nshop,team\n"))
+	utils.Assert(t, err != nil, "label selector without value is accepted")
+
+	_, err = ParseCSV(strings.NewReader("name,parent\nshop,sales\n"))
+	utils.Assert(t, err != nil, "undefined parent is accepted")
+
+	_, err = ParseCSV(strings.NewReader("name,parent\na,b\nb,a\n"))
+	utils.Assert(t, err != nil, "cycle is accepted")
+}
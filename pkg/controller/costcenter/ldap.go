@@ -0,0 +1,329 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package costcenter
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// BER tags of the LDAP v3 messages (RFC 4511) used by the client
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+
+	tagBindRequest       = 0x60
+	tagBindResponse      = 0x61
+	tagUnbindRequest     = 0x42
+	tagSearchRequest     = 0x63
+	tagSearchResultEntry = 0x64
+	tagSearchResultDone  = 0x65
+	tagSimpleAuth        = 0x80
+	tagEqualityFilter    = 0xa3
+
+	ldapVersion = 3
+	ldapTimeout = 30 * time.Second
+	// maxMessageSize bounds the length of a message read from the server
+	maxMessageSize = 16 << 20
+)
+
+// ldapConn is a minimal LDAP v3 client supporting a simple bind and a search by object class, enough to read the
+// groups of a directory. Referrals and paged results are not followed, a search returns at most the size limit of
+// the server (1000 entries on Active Directory by default).
+type ldapConn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	messageID int
+}
+
+// ldapEntry is an entry returned by a search with the values of its attributes, attribute names are lower case
+type ldapEntry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// berElement is a decoded BER tag-length-value
+type berElement struct {
+	tag   byte
+	value []byte
+}
+
+// dialLDAP connects to an ldap:// or ldaps:// url, the port defaults to 389 and 636
+func dialLDAP(rawURL string) (*ldapConn, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	host := parsed.Host
+	dialer := &net.Dialer{Timeout: ldapTimeout}
+	var conn net.Conn
+	switch parsed.Scheme {
+	case "ldap":
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), "389")
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "ldaps":
+		if parsed.Port() == "" {
+			host = net.JoinHostPort(parsed.Hostname(), "636")
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: parsed.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported ldap url scheme: %s", parsed.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ldapConn{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// bind authenticates with the dn and password, an empty dn binds anonymously
+func (c *ldapConn) bind(dn, password string) error {
+	request := berEncode(tagBindRequest, berInteger(tagInteger, ldapVersion), berEncode(tagOctetString, []byte(dn)),
+		berEncode(tagSimpleAuth, []byte(password)))
+	if err := c.send(request); err != nil {
+		return err
+	}
+	op, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if op.tag != tagBindResponse {
+		return fmt.Errorf("unexpected ldap bind response: 0x%x", op.tag)
+	}
+	return checkResult(op)
+}
+
+// search returns the entries of the object class under the base dn (whole subtree) with the given attributes
+func (c *ldapConn) search(baseDN, objectClass string, attributes []string) ([]ldapEntry, error) {
+	var attributeList []byte
+	for _, attribute := range attributes {
+		attributeList = append(attributeList, berEncode(tagOctetString, []byte(attribute))...)
+	}
+	request := berEncode(tagSearchRequest,
+		berEncode(tagOctetString, []byte(baseDN)),
+		berInteger(tagEnumerated, 2), // scope: whole subtree
+		berInteger(tagEnumerated, 0), // never dereference aliases
+		berInteger(tagInteger, 0),    // no size limit
+		berInteger(tagInteger, int(ldapTimeout/time.Second)),
+		berEncode(tagBoolean, []byte{0}), // types only: false
+		berEncode(tagEqualityFilter, berEncode(tagOctetString, []byte("objectClass")), berEncode(tagOctetString, []byte(objectClass))),
+		berEncode(tagSequence, attributeList))
+	if err := c.send(request); err != nil {
+		return nil, err
+	}
+
+	var entries []ldapEntry
+	for {
+		op, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		switch op.tag {
+		case tagSearchResultEntry:
+			entry, err := parseEntry(op.value)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case tagSearchResultDone:
+			return entries, checkResult(op)
+		}
+		// search result references (referrals) are not followed
+	}
+}
+
+// close unbinds and closes the connection
+func (c *ldapConn) close() error {
+	if err := c.send(berEncode(tagUnbindRequest)); err != nil {
+		log.Debugf("unable to unbind from ldap server: %v", err)
+	}
+	return c.conn.Close()
+}
+
+func (c *ldapConn) send(op []byte) error {
+	c.messageID++
+	message := berEncode(tagSequence, berInteger(tagInteger, c.messageID), op)
+	if err := c.conn.SetDeadline(time.Now().Add(ldapTimeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(message)
+	return err
+}
+
+// receive reads the next message of the server and returns its protocol operation
+func (c *ldapConn) receive() (berElement, error) {
+	message, err := readElement(c.reader)
+	if err != nil {
+		return berElement{}, err
+	}
+	elements, err := berDecode(message.value)
+	if err != nil {
+		return berElement{}, err
+	}
+	if message.tag != tagSequence || len(elements) < 2 {
+		return berElement{}, fmt.Errorf("malformed ldap message")
+	}
+	return elements[1], nil
+}
+
+// checkResult returns an error if the LDAPResult of the operation is not success
+func checkResult(op berElement) error {
+	elements, err := berDecode(op.value)
+	if err != nil {
+		return err
+	}
+	if len(elements) < 3 {
+		return fmt.Errorf("malformed ldap result")
+	}
+	if code := berToInt(elements[0].value); code != 0 {
+		return fmt.Errorf("ldap error %d: %s", code, elements[2].value)
+	}
+	return nil
+}
+
+// parseEntry decodes a SearchResultEntry: the dn followed by the sequence of attributes with their set of values
+func parseEntry(data []byte) (ldapEntry, error) {
+	elements, err := berDecode(data)
+	if err != nil || len(elements) < 2 {
+		return ldapEntry{}, fmt.Errorf("malformed ldap search entry")
+	}
+	entry := ldapEntry{DN: string(elements[0].value), Attributes: map[string][]string{}}
+	attributes, err := berDecode(elements[1].value)
+	if err != nil {
+		return entry, err
+	}
+	for _, attribute := range attributes {
+		parts, err := berDecode(attribute.value)
+		if err != nil || len(parts) < 2 {
+			return entry, fmt.Errorf("malformed ldap attribute of entry: %s", entry.DN)
+		}
+		values, err := berDecode(parts[1].value)
+		if err != nil {
+			return entry, err
+		}
+		name := strings.ToLower(string(parts[0].value))
+		for _, value := range values {
+			entry.Attributes[name] = append(entry.Attributes[name], string(value.value))
+		}
+	}
+	return entry, nil
+}
+
+// berEncode encodes the tag with the concatenated contents as value, using the definite length form
+func berEncode(tag byte, contents ...[]byte) []byte {
+	var value []byte
+	for _, content := range contents {
+		value = append(value, content...)
+	}
+	encoded := []byte{tag}
+	if length := len(value); length < 0x80 {
+		encoded = append(encoded, byte(length))
+	} else {
+		var lengthBytes []byte
+		for ; length > 0; length >>= 8 {
+			lengthBytes = append([]byte{byte(length)}, lengthBytes...)
+		}
+		encoded = append(encoded, 0x80|byte(len(lengthBytes)))
+		encoded = append(encoded, lengthBytes...)
+	}
+	return append(encoded, value...)
+}
+
+// berInteger encodes a non negative integer in the fewest two's complement bytes
+func berInteger(tag byte, n int) []byte {
+	value := []byte{byte(n)}
+	for n >>= 8; n > 0; n >>= 8 {
+		value = append([]byte{byte(n)}, value...)
+	}
+	if value[0]&0x80 != 0 {
+		value = append([]byte{0}, value...)
+	}
+	return berEncode(tag, value)
+}
+
+func berToInt(value []byte) int {
+	n := 0
+	for _, b := range value {
+		n = n<<8 | int(b)
+	}
+	return n
+}
+
+// berDecode decodes the consecutive elements of the value of a constructed element
+func berDecode(data []byte) ([]berElement, error) {
+	var elements []berElement
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		element, err := readElement(reader)
+		if err == io.EOF {
+			return elements, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+}
+
+// readElement reads one element, it returns io.EOF only if no byte of the element could be read
+func readElement(reader *bufio.Reader) (berElement, error) {
+	tag, err := reader.ReadByte()
+	if err != nil {
+		return berElement{}, err
+	}
+	first, err := reader.ReadByte()
+	if err != nil {
+		return berElement{}, io.ErrUnexpectedEOF
+	}
+	length := int(first)
+	if first&0x80 != 0 {
+		count := int(first & 0x7f)
+		if count == 0 || count > 4 {
+			return berElement{}, fmt.Errorf("unsupported ber length of %d bytes", count)
+		}
+		length = 0
+		for i := 0; i < count; i++ {
+			b, err := reader.ReadByte()
+			if err != nil {
+				return berElement{}, io.ErrUnexpectedEOF
+			}
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxMessageSize {
+		return berElement{}, fmt.Errorf("ber element of %d bytes is too large", length)
+	}
+	value := make([]byte, length)
+	if _, err = io.ReadFull(reader, value); err != nil {
+		return berElement{}, io.ErrUnexpectedEOF
+	}
+	return berElement{tag: tag, value: value}, nil
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package costcenter
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestParseEntry(t *testing.T) {
+	attribute := func(name string, values ...string) []byte {
+		var set []byte
+		for _, value := range values {
+			set = append(set, berEncode(tagOctetString, []byte(value))...)
+		}
+		return berEncode(tagSequence, berEncode(tagOctetString, []byte(name)), berEncode(0x31, set))
+	}
+	entry := berEncode(tagSearchResultEntry,
+		berEncode(tagOctetString, []byte("CN=shop,OU=Groups,DC=example,DC=com")),
+		berEncode(tagSequence, attribute("cn", "shop"), attribute("memberOf", "CN=engineering,OU=Groups,DC=example,DC=com", "CN=all,DC=example,DC=com")))
+
+	elements, err := berDecode(entry)
+	utils.Ok(t, err)
+	parsed, err := parseEntry(elements[0].value)
+	utils.Ok(t, err)
+	utils.Equals(t, ldapEntry{
+		DN: "CN=shop,OU=Groups,DC=example,DC=com",
+		Attributes: map[string][]string{
+			"cn":       {"shop"},
+			"memberof": {"CN=engineering,OU=Groups,DC=example,DC=com", "CN=all,DC=example,DC=com"},
+		},
+	}, parsed)
+}
+
+func TestBerLongLength(t *testing.T) {
+	value := make([]byte, 300)
+	elements, err := berDecode(append(berEncode(tagOctetString, value), berInteger(tagInteger, 128)...))
+	utils.Ok(t, err)
+	utils.Equals(t, 2, len(elements))
+	utils.Equals(t, 300, len(elements[0].value))
+	utils.Equals(t, 128, berToInt(elements[1].value))
+}
+
+func TestLDAPCostCenters(t *testing.T) {
+	entries := []ldapEntry{
+		{DN: "CN=engineering,DC=example,DC=com", Attributes: map[string][]string{"cn": {"engineering"}}},
+		{DN: "CN=shop,DC=example,DC=com", Attributes: map[string][]string{"cn": {"shop"}, "memberof": {"CN=vpn-users,DC=example,DC=com", "cn=Engineering,dc=example,dc=com"}}},
+		{DN: "CN=nameless,DC=example,DC=com", Attributes: map[string][]string{}},
+	}
+	utils.Equals(t, []models.CostCenterItem{
+		{Name: "engineering", Labels: map[string]string{"team": "engineering"}},
+		{Name: "shop", Parent: "engineering", Labels: map[string]string{"team": "shop"}},
+	}, ldapCostCenters(entries, "CN", "team"))
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package costhistory
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+const (
+	defaultGranularity = time.Hour
+	defaultRetention   = 90 * 24 * time.Hour
+	// maxBackfill is the number of periods missed (while the controller was down) which are snapshotted by a run
+	maxBackfill = 24
+	// maxPrunedSnapshots is the number of expired snapshots deleted by a run
+	maxPrunedSnapshots = 10000
+)
+
+// Settings of the snapshots of the cost of the pods the cost trends are computed from
+type Settings struct {
+	// Disabled stops the snapshots, the trends only cover the snapshots taken before
+	Disabled bool `json:"disabled,omitempty"`
+	// Granularity is the period of a snapshot (ex: 15m, 24h), 1h by default. A day must be a multiple of it.
+	Granularity string `json:"granularity,omitempty"`
+	// Retention is how long the snapshots are kept (ex: 8760h), 90 days by default
+	Retention string `json:"retention,omitempty"`
+}
+
+var (
+	mu          sync.Mutex
+	disabled    bool
+	granularity = defaultGranularity
+	retention   = defaultRetention
+	// lastSnapshot is the start of the last period snapshotted, it is read from dgraph by the first run
+	lastSnapshot time.Time
+)
+
+// Setup sets the snapshot settings and returns the interval of the snapshots
+func Setup(settings Settings) string {
+	mu.Lock()
+	defer mu.Unlock()
+	disabled = settings.Disabled
+	granularity, retention = defaultGranularity, defaultRetention
+	if settings.Granularity != "" {
+		parsed, err := time.ParseDuration(settings.Granularity)
+		if err != nil || parsed < time.Minute || (24*time.Hour)%parsed != 0 {
+			log.Errorf("invalid cost snapshot granularity: (%s), a day must be a multiple of it, using: %v", settings.Granularity, defaultGranularity)
+		} else {
+			granularity = parsed
+		}
+	}
+	if settings.Retention != "" {
+		parsed, err := time.ParseDuration(settings.Retention)
+		if err != nil || parsed <= 0 {
+			log.Errorf("invalid cost snapshot retention: (%s), using: %v", settings.Retention, defaultRetention)
+		} else {
+			retention = parsed
+		}
+	}
+	return granularity.String()
+}
+
+// Snapshot stores the cost of the pods in every period which ended since the last snapshot, at most the last
+// maxBackfill ones, and deletes the snapshots older than the retention. It is scheduled to run every period.
+func Snapshot() {
+	mu.Lock()
+	defer mu.Unlock()
+	if disabled {
+		return
+	}
+
+	if lastSnapshot.IsZero() {
+		latest, err := models.RetrieveLatestCostSnapshotTime()
+		if err != nil {
+			log.Errorf("unable to retrieve the latest cost snapshot: (%v)", err)
+			return
+		}
+		lastSnapshot = latest
+	}
+	now := time.Now()
+	for _, start := range pendingPeriods(lastSnapshot, now, granularity) {
+		if err := snapshotPeriod(start, start.Add(granularity)); err != nil {
+			log.Errorf("unable to snapshot pod costs of period: (%s), error: (%v)", start.Format(time.RFC3339), err)
+			return
+		}
+		lastSnapshot = start
+	}
+
+	pruned, err := models.DeleteCostSnapshotsBefore(now.Add(-retention), maxPrunedSnapshots)
+	if err != nil {
+		log.Errorf("unable to delete expired cost snapshots: (%v)", err)
+	} else if pruned > 0 {
+		log.Infof("deleted %d expired cost snapshots", pruned)
+	}
+}
+
+func snapshotPeriod(from, to time.Time) error {
+	snapshots, err := query.RetrievePodCostSnapshots(from, to, pricing.GetCatalog().Version)
+	if err != nil {
+		return err
+	}
+	isCreated, err := models.StoreCostSnapshots(from, snapshots)
+	if err != nil {
+		return err
+	}
+	if isCreated {
+		log.Debugf("snapshotted cost of %d pods for period: (%s)", len(snapshots), from.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// pendingPeriods returns the starts of the periods which ended after the period starting at last and before now,
+// oldest first. Only the last completed period is pending when nothing was snapshotted yet, and at most maxBackfill
+// periods are.
+func pendingPeriods(last, now time.Time, granularity time.Duration) []time.Time {
+	current := periodStart(now, granularity)
+	first := current.Add(-granularity)
+	if !last.IsZero() {
+		first = periodStart(last, granularity).Add(granularity)
+	}
+	if backfill := current.Add(-maxBackfill * granularity); first.Before(backfill) {
+		first = backfill
+	}
+	var periods []time.Time
+	for start := first; start.Before(current); start = start.Add(granularity) {
+		periods = append(periods, start)
+	}
+	return periods
+}
+
+// periodStart returns the start of the period of the time, the periods are aligned on the start of the local day
+func periodStart(t time.Time, granularity time.Duration) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	return day.Add(t.Sub(day) / granularity * granularity)
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package costhistory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPeriodStart(t *testing.T) {
+	at := time.Date(2018, 11, 5, 10, 47, 12, 0, time.Local)
+	utils.Equals(t, time.Date(2018, 11, 5, 10, 0, 0, 0, time.Local), periodStart(at, time.Hour))
+	utils.Equals(t, time.Date(2018, 11, 5, 10, 45, 0, 0, time.Local), periodStart(at, 15*time.Minute))
+	utils.Equals(t, time.Date(2018, 11, 5, 0, 0, 0, 0, time.Local), periodStart(at, 24*time.Hour))
+}
+
+func TestPendingPeriods(t *testing.T) {
+	now := time.Date(2018, 11, 5, 10, 47, 0, 0, time.Local)
+	hour := func(h int) time.Time {
+		return time.Date(2018, 11, 5, h, 0, 0, 0, time.Local)
+	}
+
+	// nothing snapshotted yet, only the last completed period
+	utils.Equals(t, []time.Time{hour(9)}, pendingPeriods(time.Time{}, now, time.Hour))
+	// the periods missed since the last snapshot
+	utils.Equals(t, []time.Time{hour(8), hour(9)}, pendingPeriods(hour(7), now, time.Hour))
+	// up to date
+	utils.Equals(t, 0, len(pendingPeriods(hour(9), now, time.Hour)))
+
+	// a long outage is backfilled up to maxBackfill periods
+	periods := pendingPeriods(hour(9).AddDate(0, 0, -7), now, time.Hour)
+	utils.Equals(t, maxBackfill, len(periods))
+	utils.Equals(t, hour(9), periods[len(periods)-1])
+}
+
+func TestSetup(t *testing.T) {
+	defer Setup(Settings{})
+
+	utils.Equals(t, "1h0m0s", Setup(Settings{}))
+	utils.Equals(t, "15m0s", Setup(Settings{Granularity: "15m", Retention: "8760h"}))
+	utils.Equals(t, 8760*time.Hour, retention)
+	// a day is not a multiple of 7h
+	utils.Equals(t, "1h0m0s", Setup(Settings{Granularity: "7h"}))
+	utils.Equals(t, defaultRetention, retention)
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const defaultArchiveGracePeriod = 30 * 24 * time.Hour
+
+// RetentionSettings of the resources pruned at the start of every month
+type RetentionSettings struct {
+	// SoftDelete archives the pruned resources instead of deleting them, they can be restored during the grace period
+	SoftDelete bool `json:"softDelete,omitempty"`
+	// GracePeriod is how long the archived resources are kept before being deleted (ex: 240h), 30 days by default
+	GracePeriod string `json:"gracePeriod,omitempty"`
+}
+
+// ArchivedResource is a pruned resource kept in the archive. It is hidden from the queries because its type marker
+// (ex: isPod) is moved to archivedMarker.
+type ArchivedResource struct {
+	ID
+	Marker     string `json:"archivedMarker"`
+	EndTime    string `json:"endTime,omitempty"`
+	ArchivedAt string `json:"archivedAt"`
+	PurgeAfter string `json:"purgeAfter,omitempty"`
+}
+
+// RestoreResult is the outcome of a restore of archived resources
+type RestoreResult struct {
+	Restored int `json:"restored"`
+	// Conflicts are the xids of the archived resources which were not restored because a resource of the same type
+	// and xid was created since they were archived
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+var (
+	retentionMu        sync.RWMutex
+	softDelete         bool
+	archiveGracePeriod = defaultArchiveGracePeriod
+	typeMarkers        = map[string]bool{}
+)
+
+// SetupRetention sets the retention settings. markers are the predicates marking the type of the nodes, the marker
+// of a node is moved to the archive when it is soft deleted.
+func SetupRetention(settings RetentionSettings, markers []string) {
+	retentionMu.Lock()
+	defer retentionMu.Unlock()
+	softDelete = settings.SoftDelete
+	typeMarkers = map[string]bool{}
+	for _, marker := range markers {
+		typeMarkers[marker] = true
+	}
+	archiveGracePeriod = defaultArchiveGracePeriod
+	if settings.GracePeriod == "" {
+		return
+	}
+	gracePeriod, err := time.ParseDuration(settings.GracePeriod)
+	if err != nil || gracePeriod <= 0 {
+		log.Errorf("invalid archive grace period: %q, using %v", settings.GracePeriod, defaultArchiveGracePeriod)
+		return
+	}
+	archiveGracePeriod = gracePeriod
+}
+
+func isSoftDelete() bool {
+	retentionMu.RLock()
+	defer retentionMu.RUnlock()
+	return softDelete
+}
+
+func getArchiveGracePeriod() time.Duration {
+	retentionMu.RLock()
+	defer retentionMu.RUnlock()
+	return archiveGracePeriod
+}
+
+// archiveResources moves the type marker of the resources to the archive. Resources without a known marker are
+// deleted as they would not be restorable.
+func archiveResources(resources []resource) error {
+	retentionMu.RLock()
+	markers := typeMarkers
+	retentionMu.RUnlock()
+
+	archived, removedMarkers, unknown := archiveMutations(resources, markers, time.Now())
+	if len(archived) > 0 {
+		if _, err := MutateNode(archived, UPDATE); err != nil {
+			return err
+		}
+		if _, err := MutateNode(removedMarkers, DELETE); err != nil {
+			return err
+		}
+		log.Infof("archived %d deleted resources", len(archived))
+	}
+	if len(unknown) > 0 {
+		if _, err := MutateNode(unknown, DELETE); err != nil {
+			return err
+		}
+		log.Infof("deleted %d resources of unknown type", len(unknown))
+	}
+	return nil
+}
+
+// archiveMutations returns the updates archiving the resources with a known type marker, the deletions of their
+// marker and the resources of unknown type
+func archiveMutations(resources []resource, markers map[string]bool,
+	now time.Time) (archived, removedMarkers []map[string]interface{}, unknown []resource) {
+	archivedAt := now.Format(time.RFC3339)
+	for _, r := range resources {
+		marker := ""
+		for _, predicate := range r.Predicates {
+			if markers[predicate] {
+				marker = predicate
+				break
+			}
+		}
+		if marker == "" {
+			unknown = append(unknown, resource{ID: ID{UID: r.UID}})
+			continue
+		}
+		archived = append(archived, map[string]interface{}{"uid": r.UID, "archivedMarker": marker, "archivedAt": archivedAt})
+		removedMarkers = append(removedMarkers, map[string]interface{}{"uid": r.UID, marker: nil})
+	}
+	return archived, removedMarkers, unknown
+}
+
+// purgeExpiredArchives deletes the archived resources whose grace period is over
+func purgeExpiredArchives() error {
+	expired := time.Now().Add(-getArchiveGracePeriod())
+	q := `query {
+		resources(func: le(archivedAt, "` + expired.Format(time.RFC3339) + `")) {
+			uid
+		}
+	}`
+	type root struct {
+		Resources []resource `json:"resources"`
+	}
+	newRoot := root{}
+	if err := ExecuteQuery(q, &newRoot); err != nil {
+		return err
+	}
+	if len(newRoot.Resources) == 0 {
+		return nil
+	}
+	if _, err := MutateNode(newRoot.Resources, DELETE); err != nil {
+		return err
+	}
+	log.Infof("purged %d archived resources", len(newRoot.Resources))
+	return nil
+}
+
+// RetrieveArchivedResources returns the archived resources which were archived at or after the given time, the
+// most recently archived first
+func RetrieveArchivedResources(since time.Time) ([]ArchivedResource, error) {
+	q := `query {
+		resources(func: ge(archivedAt, "` + since.Format(time.RFC3339) + `"), orderdesc: archivedAt) {
+			uid
+			xid
+			archivedMarker
+			endTime
+			archivedAt
+		}
+	}`
+	type root struct {
+		Resources []ArchivedResource `json:"resources"`
+	}
+	newRoot := root{}
+	if err := ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	gracePeriod := getArchiveGracePeriod()
+	for i, r := range newRoot.Resources {
+		if archivedAt, err := time.Parse(time.RFC3339, r.ArchivedAt); err == nil {
+			newRoot.Resources[i].PurgeAfter = archivedAt.Add(gracePeriod).Format(time.RFC3339)
+		}
+	}
+	return newRoot.Resources, nil
+}
+
+// RestoreArchivedResources restores the archived resources with the given xid, or all resources archived at or
+// after the given time when xid is empty. Restored resources are retained: they are not pruned again. A resource
+// recreated since it was archived (same type and xid) is not restored, its xid is reported in the conflicts.
+func RestoreArchivedResources(xid string, since time.Time) (RestoreResult, error) {
+	resources, err := RetrieveArchivedResources(since)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	var matching []ArchivedResource
+	for _, r := range resources {
+		if xid == "" || r.Xid == xid {
+			matching = append(matching, r)
+		}
+	}
+	if len(matching) == 0 {
+		return RestoreResult{}, fmt.Errorf("no archived resource matches xid: %q, archived since: %s", xid,
+			since.Format(time.RFC3339))
+	}
+
+	live, err := findLiveCounterparts(matching)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	restored, removedArchive, conflicts := restoreMutations(matching, live)
+	result := RestoreResult{Restored: len(restored), Conflicts: conflicts}
+	if len(conflicts) > 0 {
+		log.Warnf("archived resources: %v were recreated since they were archived, they are not restored", conflicts)
+	}
+	if len(restored) == 0 {
+		return result, fmt.Errorf("archived resources: %s were recreated since they were archived",
+			strings.Join(conflicts, ", "))
+	}
+
+	if _, err = MutateNode(restored, UPDATE); err != nil {
+		return RestoreResult{}, err
+	}
+	if _, err = MutateNode(removedArchive, DELETE); err != nil {
+		return RestoreResult{}, err
+	}
+	log.Infof("restored %d archived resources", len(restored))
+	return result, nil
+}
+
+// findLiveCounterparts tells for every archived resource if a live node of the same type and xid exists
+func findLiveCounterparts(resources []ArchivedResource) ([]bool, error) {
+	q, variables := liveCounterpartsQuery(resources)
+	newRoot := map[string][]ID{}
+	if err := ExecuteQueryWithVars(q, variables, &newRoot); err != nil {
+		return nil, err
+	}
+	live := make([]bool, len(resources))
+	for i := range resources {
+		live[i] = len(newRoot[fmt.Sprintf("r%d", i)]) > 0
+	}
+	return live, nil
+}
+
+// liveCounterpartsQuery returns the query of the live nodes with the type marker and the xid of every archived
+// resource, block r<i> answering for the resource i. Archived nodes have no type marker so they are not matched.
+func liveCounterpartsQuery(resources []ArchivedResource) (string, map[string]string) {
+	declarations := make([]string, 0, len(resources))
+	variables := make(map[string]string, len(resources))
+	var blocks strings.Builder
+	for i, r := range resources {
+		variable := fmt.Sprintf("$x%d", i)
+		declarations = append(declarations, variable+": string")
+		variables[variable] = r.Xid
+		fmt.Fprintf(&blocks, "\t\tr%d(func: eq(xid, %s)) @filter(has(%s)) {\n\t\t\tuid\n\t\t}\n", i, variable, r.Marker)
+	}
+	return "query Live(" + strings.Join(declarations, ", ") + ") {\n" + blocks.String() + "\t}", variables
+}
+
+// restoreMutations returns the updates restoring the type marker of the archived resources and the deletions of
+// their archive predicates, skipping the resources with a live counterpart whose xids are returned as conflicts
+func restoreMutations(resources []ArchivedResource,
+	live []bool) (restored, removedArchive []map[string]interface{}, conflicts []string) {
+	for i, r := range resources {
+		if live[i] {
+			conflicts = append(conflicts, r.Xid)
+			continue
+		}
+		restored = append(restored, map[string]interface{}{"uid": r.UID, r.Marker: true, "retained": true})
+		removedArchive = append(removedArchive, map[string]interface{}{"uid": r.UID, "archivedMarker": nil, "archivedAt": nil})
+	}
+	return restored, removedArchive, conflicts
+}
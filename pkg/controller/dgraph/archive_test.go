@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package dgraph
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestArchiveMutations(t *testing.T) {
+	markers := map[string]bool{"isPod": true, "isContainer": true}
+	now := time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)
+	resources := []resource{
+		{ID: ID{UID: "0x1", Xid: "default:web"}, Predicates: []string{"name", "isPod", "startTime"}},
+		{ID: ID{UID: "0x2", Xid: "default:web:nginx"}, Predicates: []string{"isContainer"}},
+		{ID: ID{UID: "0x3", Xid: "orphan"}, Predicates: []string{"name"}},
+	}
+	archived, removedMarkers, unknown := archiveMutations(resources, markers, now)
+	utils.Equals(t, []map[string]interface{}{
+		{"uid": "0x1", "archivedMarker": "isPod", "archivedAt": "2018-12-01T00:00:00Z"},
+		{"uid": "0x2", "archivedMarker": "isContainer", "archivedAt": "2018-12-01T00:00:00Z"},
+	}, archived)
+	utils.Equals(t, []map[string]interface{}{{"uid": "0x1", "isPod": nil}, {"uid": "0x2", "isContainer": nil}}, removedMarkers)
+	utils.Equals(t, []resource{{ID: ID{UID: "0x3"}}}, unknown)
+}
+
+func TestRestoreMutations(t *testing.T) {
+	resources := []ArchivedResource{
+		{ID: ID{UID: "0x1", Xid: "default:web"}, Marker: "isPod"},
+		{ID: ID{UID: "0x2", Xid: "default:db"}, Marker: "isPod"},
+	}
+	restored, removedArchive, conflicts := restoreMutations(resources, []bool{false, false})
+	utils.Equals(t, []map[string]interface{}{
+		{"uid": "0x1", "isPod": true, "retained": true},
+		{"uid": "0x2", "isPod": true, "retained": true},
+	}, restored)
+	utils.Equals(t, []map[string]interface{}{
+		{"uid": "0x1", "archivedMarker": nil, "archivedAt": nil},
+		{"uid": "0x2", "archivedMarker": nil, "archivedAt": nil},
+	}, removedArchive)
+	utils.Equals(t, 0, len(conflicts))
+}
+
+func TestRestoreRecreatedResource(t *testing.T) {
+	// default:web was deleted, archived and created again: restoring its archive would duplicate the pod
+	resources := []ArchivedResource{
+		{ID: ID{UID: "0x1", Xid: "default:web"}, Marker: "isPod"},
+		{ID: ID{UID: "0x2", Xid: "default:db"}, Marker: "isPod"},
+	}
+	restored, removedArchive, conflicts := restoreMutations(resources, []bool{true, false})
+	utils.Equals(t, []map[string]interface{}{{"uid": "0x2", "isPod": true, "retained": true}}, restored)
+	utils.Equals(t, []map[string]interface{}{{"uid": "0x2", "archivedMarker": nil, "archivedAt": nil}}, removedArchive)
+	utils.Equals(t, []string{"default:web"}, conflicts)
+
+	restored, _, conflicts = restoreMutations(resources[:1], []bool{true})
+	utils.Equals(t, 0, len(restored))
+	utils.Equals(t, []string{"default:web"}, conflicts)
+}
+
+func TestLiveCounterpartsQuery(t *testing.T) {
+	q, variables := liveCounterpartsQuery([]ArchivedResource{
+		{ID: ID{UID: "0x1", Xid: "default:web"}, Marker: "isPod"},
+		{ID: ID{UID: "0x2", Xid: "default:web:nginx"}, Marker: "isContainer"},
+	})
+	utils.Equals(t, map[string]string{"$x0": "default:web", "$x1": "default:web:nginx"}, variables)
+	utils.Assert(t, strings.HasPrefix(q, "query Live($x0: string, $x1: string) {"), "unexpected declarations: %s", q)
+	utils.Assert(t, strings.Contains(q, "r0(func: eq(xid, $x0)) @filter(has(isPod))"), "pod is not looked up: %s", q)
+	utils.Assert(t, strings.Contains(q, "r1(func: eq(xid, $x1)) @filter(has(isContainer))"),
+		"container is not looked up: %s", q)
+	// xids are passed as variables, not in the query
+	utils.Assert(t, !strings.Contains(q, "default:web"), "xid in the query: %s", q)
+}
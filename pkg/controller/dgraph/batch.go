@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/dgraph-io/dgo/protos/api"
+)
+
+const (
+	defaultBatchSize     = 100
+	defaultBatchInterval = time.Second
+)
+
+// BatchSettings of the batching of the writes of pods, containers and labels. Queued mutations are flushed in
+// transactions of at most Size nodes when Size mutations are queued and every Interval (ex: 500ms). Size 1
+// disables batching, every mutation is then written when it is queued.
+type BatchSettings struct {
+	Size     int    `json:"size,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+// queuedMutation is the json encoding of the nodes of a mutation, without the enclosing brackets if it is a list
+type queuedMutation struct {
+	delete bool
+	nodes  []byte
+	count  int
+	// written are called with the result of the write of the batch of the mutation
+	written []func(err error)
+}
+
+var (
+	batchMu       sync.Mutex
+	batchSize     = defaultBatchSize
+	batchInterval = defaultBatchInterval
+	pending       []queuedMutation
+	pendingNodes  int
+	// flushMu serializes the flushes so that the mutations are applied in the order they are queued
+	flushMu sync.Mutex
+)
+
+// SetupBatching sets the size and interval of the mutation batches, zero values keep the defaults
+func SetupBatching(settings BatchSettings) {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	if settings.Size > 0 {
+		batchSize = settings.Size
+	}
+	if settings.Interval != "" {
+		interval, err := time.ParseDuration(settings.Interval)
+		if err != nil || interval <= 0 {
+			log.Errorf("invalid dgraph batch interval: (%s), using: %v", settings.Interval, defaultBatchInterval)
+			return
+		}
+		batchInterval = interval
+	}
+}
+
+// QueueMutation queues the mutation of the nodes (a node or a list of nodes) to be written with the next batch.
+// The nodes must have their uid, blank nodes are not resolved across mutations. Errors of the batch are logged,
+// the nodes are written again by the next resync.
+func QueueMutation(data interface{}, mutateType string) error {
+	return QueueMutationWithCallback(data, mutateType, nil)
+}
+
+// QueueMutationWithCallback queues the mutation like QueueMutation, written is then called with the error of the
+// write of its batch. The error is nil once the batch is written or buffered while dgraph is unreachable.
+func QueueMutationWithCallback(data interface{}, mutateType string, written func(err error)) error {
+	buf, err := marshal(data)
+	defer releaseBuffer(buf)
+	if err != nil {
+		err = fmt.Errorf("Unable to marshal data: %v, error: %v", data, err)
+		if written != nil {
+			written(err)
+		}
+		return err
+	}
+	nodes, count := listItems(*buf)
+	if count == 0 {
+		if written != nil {
+			written(nil)
+		}
+		return nil
+	}
+
+	queued := queuedMutation{delete: mutateType == DELETE, nodes: append([]byte(nil), nodes...), count: count}
+	if written != nil {
+		queued.written = []func(err error){written}
+	}
+	batchMu.Lock()
+	pending = append(pending, queued)
+	pendingNodes += count
+	isFull := pendingNodes >= batchSize
+	batchMu.Unlock()
+
+	if isFull {
+		return Flush()
+	}
+	return nil
+}
+
+// PendingMutations returns the number of nodes queued to be written with the next batch
+func PendingMutations() int {
+	batchMu.Lock()
+	defer batchMu.Unlock()
+	return pendingNodes
+}
+
+// Flush writes the queued mutations, consecutive mutations of the same kind are written in one transaction
+func Flush() error {
+	flushMu.Lock()
+	defer flushMu.Unlock()
+
+	batchMu.Lock()
+	queued, size := pending, batchSize
+	pending, pendingNodes = nil, 0
+	batchMu.Unlock()
+
+	var firstErr error
+	for _, batch := range groupMutations(queued, size) {
+		err := writeBatch(batch)
+		if err != nil {
+			log.Errorf("unable to write batch of %d nodes to dgraph, error: %v", batch.count, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		for _, written := range batch.written {
+			written(err)
+		}
+	}
+	return firstErr
+}
+
+// writeBatch writes the nodes of the batch in one transaction, a buffered batch is written once dgraph is
+// reachable again
+func writeBatch(batch queuedMutation) error {
+	nodes, err := prefixMutation(batch.nodes)
+	if err != nil {
+		return err
+	}
+	mu := &api.Mutation{CommitNow: true}
+	if batch.delete {
+		mu.DeleteJson = nodes
+		forgetDeletedUIDs(batch.nodes)
+	} else {
+		mu.SetJson = nodes
+	}
+	if _, err = mutate(mu); err != nil && err != ErrWriteBuffered {
+		return err
+	}
+	return nil
+}
+
+// RunBatching flushes the queued mutations every batch interval until ctx is done, the mutations left are then
+// flushed
+func RunBatching(ctx context.Context) {
+	batchMu.Lock()
+	interval := batchInterval
+	batchMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := Flush(); err != nil {
+				log.Errorf("unable to flush queued dgraph mutations on shutdown, error: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := Flush(); err != nil {
+				log.Debugf("periodic flush of dgraph mutations failed: %v", err)
+			}
+		}
+	}
+}
+
+// groupMutations merges consecutive mutations of the same kind in json lists of at most size nodes, a mutation is
+// never split so a list can be larger when a single mutation is
+func groupMutations(queued []queuedMutation, size int) []queuedMutation {
+	var batches []queuedMutation
+	var current *queuedMutation
+	for _, m := range queued {
+		if current == nil || current.delete != m.delete || current.count+m.count > size {
+			if current != nil {
+				current.nodes = append(current.nodes, ']')
+				batches = append(batches, *current)
+			}
+			current = &queuedMutation{delete: m.delete, nodes: []byte{'['}}
+		} else {
+			current.nodes = append(current.nodes, ',')
+		}
+		current.nodes = append(current.nodes, m.nodes...)
+		current.count += m.count
+		current.written = append(current.written, m.written...)
+	}
+	if current != nil {
+		current.nodes = append(current.nodes, ']')
+		batches = append(batches, *current)
+	}
+	return batches
+}
+
+// listItems returns the encoding of a node, or of the nodes of a list without its brackets, with the number of nodes
+func listItems(encoded []byte) ([]byte, int) {
+	encoded = bytes.TrimSpace(encoded)
+	if len(encoded) == 0 || encoded[0] != '[' {
+		return encoded, 1
+	}
+	items := bytes.TrimSpace(encoded[1 : len(encoded)-1])
+	if len(items) == 0 {
+		return nil, 0
+	}
+	// nodes are counted by depth so that commas inside the nodes are not counted
+	count, depth, inString, escaped := 1, 0, false, false
+	for _, c := range items {
+		switch {
+		case escaped:
+			escaped = false
+		case inString:
+			escaped = c == '\\'
+			inString = c != '"'
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			count++
+		}
+	}
+	return items, count
+}
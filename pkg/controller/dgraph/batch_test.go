@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestListItems(t *testing.T) {
+	nodes, count := listItems([]byte(`{"uid":"0x1","name":"a,b"}`))
+	utils.Equals(t, `{"uid":"0x1","name":"a,b"}`, string(nodes))
+	utils.Equals(t, 1, count)
+
+	nodes, count = listItems([]byte(`[{"uid":"0x1","labels":[{"uid":"0x2"},{"uid":"0x3"}]},{"uid":"0x4","name":"x\",{"}]`))
+	utils.Equals(t, `{"uid":"0x1","labels":[{"uid":"0x2"},{"uid":"0x3"}]},{"uid":"0x4","name":"x\",{"}`, string(nodes))
+	utils.Equals(t, 2, count)
+
+	_, count = listItems([]byte(`[]`))
+	utils.Equals(t, 0, count)
+}
+
+func TestGroupMutations(t *testing.T) {
+	queued := []queuedMutation{
+		{nodes: []byte(`{"uid":"0x1"}`), count: 1},
+		{nodes: []byte(`{"uid":"0x2"},{"uid":"0x3"}`), count: 2},
+		{nodes: []byte(`{"uid":"0x4"}`), count: 1},
+		{delete: true, nodes: []byte(`{"uid":"0x5"}`), count: 1},
+		{nodes: []byte(`{"uid":"0x6"}`), count: 1},
+	}
+	batches := groupMutations(queued, 3)
+	utils.Equals(t, []queuedMutation{
+		{nodes: []byte(`[{"uid":"0x1"},{"uid":"0x2"},{"uid":"0x3"}]`), count: 3},
+		{nodes: []byte(`[{"uid":"0x4"}]`), count: 1},
+		{delete: true, nodes: []byte(`[{"uid":"0x5"}]`), count: 1},
+		{nodes: []byte(`[{"uid":"0x6"}]`), count: 1},
+	}, batches)
+}
+
+func TestFlushCallsCallbacks(t *testing.T) {
+	queue := retryQueue
+	defer func() {
+		retryQueue, offline, droppedWrites = queue, false, 0
+	}()
+	// dgraph is unreachable and a single write can be buffered
+	SetupRetries(RetrySettings{QueueSize: 1})
+	offline = true
+
+	var results []error
+	written := func(err error) {
+		results = append(results, err)
+	}
+	utils.Ok(t, QueueMutationWithCallback(map[string]string{"uid": "0x1", "name": "web"}, UPDATE, written))
+	utils.Ok(t, QueueMutationWithCallback(map[string]string{"uid": "0x2", "name": "db"}, UPDATE, written))
+	utils.Ok(t, QueueMutationWithCallback(map[string]string{"uid": "0x3"}, DELETE, written))
+	utils.Assert(t, Flush() != nil, "dropped batch must fail the flush")
+
+	// the two updates are written in the buffered batch, the deletion is dropped
+	utils.Equals(t, 3, len(results))
+	utils.Ok(t, results[0])
+	utils.Ok(t, results[1])
+	utils.Assert(t, results[2] != nil, "callback of the dropped batch must get its error")
+	utils.Equals(t, 0, PendingMutations())
+}
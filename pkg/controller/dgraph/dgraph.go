@@ -26,7 +26,6 @@ import (
 
 	"github.com/dgraph-io/dgo"
 	"github.com/dgraph-io/dgo/protos/api"
-	"github.com/vmware/purser/pkg/controller/utils"
 	"google.golang.org/grpc"
 )
 
@@ -41,6 +40,8 @@ const (
 var (
 	client     *dgo.Dgraph
 	connection *grpc.ClientConn
+	// baseContext is the context of all queries and mutations, it is cancelled at the end of the shutdown grace period
+	baseContext = context.Background()
 )
 
 // ID maps the external ID used in Dgraph to the UID
@@ -76,84 +77,79 @@ func Open(url string) error {
 	return nil
 }
 
+// SetContext sets the context of the queries and mutations, they are aborted once it is done
+func SetContext(ctx context.Context) {
+	baseContext = ctx
+}
+
 // Close terminates the Dgraph connection
 func Close() {
 	err := connection.Close()
 	if err != nil {
 		fmt.Println("Error closing connection to Dgraph ", err)
 	}
+	closeReplicas()
 }
 
-// CreateSchema sets the Dgraph schema
+// CreateSchema sets the Dgraph schema by applying the pending schema migrations
 func CreateSchema() error {
-	op := &api.Operation{}
-	op.Schema = `
-		name: string @index(term) .
-		xid:  string @index(term) .
-		startTime: dateTime @index(hour) .
-		endTime: dateTime @index(hour) .
-		isService: bool .
-		isPod: bool .
-		isContainer: bool .
-		isProc: bool .
-		pod: uid @reverse .
-		namespace: uid @reverse .
-		deployment: uid @reverse .
-		replicaset: uid @reverse .
-		statefulset: uid @reverse .
-		container: uid @reverse .
-		service: uid @reverse .
-		node: uid @reverse .
-		pv: uid @reverse .
-		daemonset: uid @reverse .
-		job: uid @reverse .
-		label: uid @reverse .
-		key: string @index(term) .
-		value: string @index(term) .
-	`
-	ctx := context.Background()
-	err := client.Alter(ctx, op)
-
-	return err
+	return Migrate()
 }
 
-// GetUID returns the UID of the node in the Dgraph
+// GetUID returns the UID of the node in the Dgraph, from the uid cache when it was already found
 // returns empty string if error has occurred
 func GetUID(id string, nodeType string) string {
+	if uid, isCached := cachedUID(id, nodeType); isCached {
+		return uid
+	}
+
 	query := `query Me($id:string, $nodeType:string) {
 		getUid(func: eq(xid, $id)) @filter(has(` + nodeType + `)) {
 			uid
 		}
 	}`
 
-	ctx := context.Background()
+	ctx := baseContext
 	variables := make(map[string]string)
 	variables["$nodeType"] = nodeType
 	variables["$id"] = id
 
-	resp, err := client.NewReadOnlyTxn().QueryWithVars(ctx, query, variables)
+	resp, err := queryJSON(ctx, client.NewReadOnlyTxn(), query, variables)
 	if err != nil {
 		log.Printf("failed to fetch UID from Dgraph %v", err)
 		return ""
 	}
-	return unmarshalDgraphResponse(resp, id)
+	uid := unmarshalDgraphResponse(resp, id)
+	cacheUID(id, nodeType, uid)
+	return uid
 }
 
 // ExecuteQueryRaw given a query and it fetches and writes result into interface
 func ExecuteQueryRaw(query string) ([]byte, error) {
-	log.Debugf("query: (%v)", query)
-	ctx := context.Background()
+	return ExecuteQueryRawWithVars(query, nil)
+}
+
+// ExecuteQueryRawWithVars runs a query declaring the given variables and returns the json result
+func ExecuteQueryRawWithVars(query string, variables map[string]string) ([]byte, error) {
+	log.Debugf("query: (%v), variables: (%v)", query, variables)
+	ctx := baseContext
 
-	resp, err := client.NewTxn().Query(ctx, query)
+	resp, err := queryJSON(ctx, client.NewTxn(), query, variables)
 	if err != nil {
 		log.Error(err)
+		return nil, err
 	}
-	return resp.Json, err
+	return resp, nil
 }
 
 // ExecuteQuery given a query and it fetches and writes result into interface
 func ExecuteQuery(query string, root interface{}) error {
-	respJSON, err := ExecuteQueryRaw(query)
+	return ExecuteQueryWithVars(query, nil, root)
+}
+
+// ExecuteQueryWithVars runs a query declaring the given variables and writes result into root
+func ExecuteQueryWithVars(query string, variables map[string]string, root interface{}) error {
+	respJSON, err := ExecuteQueryRawWithVars(query, variables)
 	if err != nil {
 		return err
 	}
@@ -166,12 +162,20 @@ func ExecuteQuery(query string, root interface{}) error {
 	return nil
 }
 
-// MutateNode mutates a Dgraph transaction
+// MutateNode mutates a Dgraph transaction. While dgraph is unreachable the mutation is buffered and
+// ErrWriteBuffered is returned, without assigned uids, except creations which fail with ErrUnreachable.
 func MutateNode(data interface{}, mutateType string) (*api.Assigned, error) {
-	bytes := utils.JSONMarshal(data)
-	if bytes == nil {
-		return nil, fmt.Errorf("Unable to marshal data: %v", data)
+	buf, err := marshal(data)
+	defer releaseBuffer(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to marshal data: %v, error: %v", data, err)
 	}
+	bytes, err := prefixMutation(*buf)
+	if err != nil {
+		return nil, err
+	}
+	// the mutation outlives the pooled buffer when it is buffered while dgraph is unreachable
+	bytes = append([]byte(nil), bytes...)
 
 	mu := &api.Mutation{
 		CommitNow: true,
@@ -179,22 +183,25 @@ func MutateNode(data interface{}, mutateType string) (*api.Assigned, error) {
 	switch mutateType {
 	case DELETE:
 		mu.DeleteJson = bytes
+		forgetDeletedUIDs(bytes)
+	case CREATE:
+		mu.SetJson = bytes
+		return createNodes(mu)
 	default:
 		mu.SetJson = bytes
 	}
 
-	ctx := context.Background()
-	return client.NewTxn().Mutate(ctx, mu)
+	return mutate(mu)
 }
 
 // unmarshalDgraphResponse returns empty string if error has occurred
-func unmarshalDgraphResponse(resp *api.Response, id string) string {
+func unmarshalDgraphResponse(resp []byte, id string) string {
 	type Root struct {
 		IDs []ID `json:"getUid"`
 	}
 
 	var r Root
-	err := json.Unmarshal(resp.Json, &r)
+	err := json.Unmarshal(resp, &r)
 	if err != nil {
 		log.Debugf("failed to marshal Dgraph response %v", err)
 		return ""
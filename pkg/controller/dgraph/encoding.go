@@ -0,0 +1,200 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+// JSONAppender is implemented by the models mutated the most, their json encoding is written by hand instead
+// of using reflection. The encoding must be the same as the one of encoding/json.
+type JSONAppender interface {
+	AppendJSON(dst []byte) ([]byte, error)
+}
+
+const (
+	initialBufferSize = 1024
+	// larger buffers are not pooled so that a few big mutations do not hold memory
+	maxPooledBufferSize = 64 * 1024
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, initialBufferSize)
+		return &buf
+	},
+}
+
+// marshal encodes the data in a pooled buffer, the buffer must be given back with releaseBuffer
+// once the encoding is not used anymore
+func marshal(data interface{}) (*[]byte, error) {
+	buf := bufferPool.Get().(*[]byte)
+	if appender, isAppender := data.(JSONAppender); isAppender {
+		encoded, err := appender.AppendJSON((*buf)[:0])
+		*buf = encoded
+		return buf, err
+	}
+
+	b := bytes.NewBuffer((*buf)[:0])
+	err := json.NewEncoder(b).Encode(data)
+	*buf = bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+	return buf, err
+}
+
+func releaseBuffer(buf *[]byte) {
+	if cap(*buf) <= maxPooledBufferSize {
+		bufferPool.Put(buf)
+	}
+}
+
+// JSONObject appends the fields of a json object to a buffer. OmitEmpty arguments follow the omitempty option
+// of json struct tags.
+type JSONObject struct {
+	Buf   []byte
+	err   error
+	empty bool
+}
+
+// NewJSONObject starts a json object at the end of dst
+func NewJSONObject(dst []byte) JSONObject {
+	return JSONObject{Buf: append(dst, '{'), empty: true}
+}
+
+// End closes the object and returns the buffer
+func (o *JSONObject) End() ([]byte, error) {
+	return append(o.Buf, '}'), o.err
+}
+
+// Key appends the key of the next field, the value must be appended to Buf by the caller
+func (o *JSONObject) Key(key string) {
+	if !o.empty {
+		o.Buf = append(o.Buf, ',')
+	}
+	o.empty = false
+	o.Buf = AppendJSONString(o.Buf, key)
+	o.Buf = append(o.Buf, ':')
+}
+
+// ID appends the fields of the embedded ID
+func (o *JSONObject) ID(id ID) {
+	o.String("xid", id.Xid, true)
+	o.String("uid", id.UID, true)
+}
+
+// String appends a string field
+func (o *JSONObject) String(key, value string, omitEmpty bool) {
+	if omitEmpty && value == "" {
+		return
+	}
+	o.Key(key)
+	o.Buf = AppendJSONString(o.Buf, value)
+}
+
+// Bool appends a bool field
+func (o *JSONObject) Bool(key string, value bool, omitEmpty bool) {
+	if omitEmpty && !value {
+		return
+	}
+	o.Key(key)
+	o.Buf = strconv.AppendBool(o.Buf, value)
+}
+
+// Int appends an int field
+func (o *JSONObject) Int(key string, value int, omitEmpty bool) {
+	if omitEmpty && value == 0 {
+		return
+	}
+	o.Key(key)
+	o.Buf = strconv.AppendInt(o.Buf, int64(value), 10)
+}
+
+// Float appends a float field
+func (o *JSONObject) Float(key string, value float64, omitEmpty bool) {
+	if omitEmpty && value == 0 {
+		return
+	}
+	o.Key(key)
+	o.Buf, o.err = appendJSONFloat(o.Buf, value, o.err)
+}
+
+// Value appends a field of any type, with its precomputed encoding if it has one
+func (o *JSONObject) Value(key string, value interface{}) {
+	o.Key(key)
+	o.Buf = o.AppendValue(o.Buf, value)
+}
+
+// AppendValue appends the encoding of the value to dst, errors are reported by End
+func (o *JSONObject) AppendValue(dst []byte, value interface{}) []byte {
+	if appender, isAppender := value.(JSONAppender); isAppender {
+		encoded, err := appender.AppendJSON(dst)
+		if err != nil && o.err == nil {
+			o.err = err
+		}
+		return encoded
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		if o.err == nil {
+			o.err = err
+		}
+		return append(dst, "null"...)
+	}
+	return append(dst, encoded...)
+}
+
+// AppendJSONString appends the json string of s, strings needing escaping are encoded by encoding/json
+func AppendJSONString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			encoded, _ := json.Marshal(s)
+			return append(dst, encoded...)
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
+// appendJSONFloat appends the float in the format of encoding/json, NaN and infinities are not valid json
+func appendJSONFloat(dst []byte, f float64, err error) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if err == nil {
+			err = fmt.Errorf("unsupported float value: %v", f)
+		}
+		return append(dst, '0'), err
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9 like encoding/json
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, err
+}
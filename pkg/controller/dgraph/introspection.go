@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/dgraph-io/dgo/protos/api"
+)
+
+// PredicateSchema is the schema of a predicate of the graph
+type PredicateSchema struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Indexed    bool     `json:"indexed"`
+	Tokenizers []string `json:"tokenizers,omitempty"`
+	Reverse    bool     `json:"reverse"`
+	List       bool     `json:"list"`
+	Count      bool     `json:"count"`
+}
+
+// RetrievePredicates returns the schema of all predicates of the graph sorted by name, including the predicates
+// which were created by mutations without being declared in a migration. With a predicate prefix, only the
+// predicates of purser are returned, without their prefix.
+func RetrievePredicates() ([]PredicateSchema, error) {
+	resp, err := client.NewReadOnlyTxn().Query(baseContext, `schema {}`)
+	if err != nil {
+		return nil, err
+	}
+	return predicateSchemas(resp.Schema), nil
+}
+
+// predicateSchemas returns the schemas of the predicates of purser sorted by name, without their prefix
+func predicateSchemas(nodes []*api.SchemaNode) []PredicateSchema {
+	predicates := make([]PredicateSchema, 0, len(nodes))
+	for _, node := range nodes {
+		name := node.Predicate
+		if predicatePrefix != "" {
+			if !strings.HasPrefix(name, predicatePrefix+".") {
+				continue
+			}
+			name = strings.TrimPrefix(name, predicatePrefix+".")
+		}
+		predicates = append(predicates, PredicateSchema{
+			Name:       name,
+			Type:       node.Type,
+			Indexed:    node.Index,
+			Tokenizers: node.Tokenizer,
+			Reverse:    node.Reverse,
+			List:       node.List,
+			Count:      node.Count,
+		})
+	}
+	sort.Slice(predicates, func(i, j int) bool {
+		return predicates[i].Name < predicates[j].Name
+	})
+	return predicates
+}
+
+// SchemaVersions returns the version of the latest migration applied to Dgraph and the version of the latest
+// migration known by this controller. They differ until the pending migrations are applied.
+func SchemaVersions() (current int, latest int) {
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].version
+	}
+	return getSchemaVersion().Version, latest
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPredicateSchemas(t *testing.T) {
+	nodes := []*api.SchemaNode{
+		{Predicate: "name", Type: "string", Index: true, Tokenizer: []string{"exact", "fulltext"}},
+		{Predicate: "cpuRequest", Type: "float"},
+		{Predicate: "namespace", Type: "uid", Reverse: true, Count: true},
+		{Predicate: "labels", Type: "uid", List: true},
+	}
+	utils.Equals(t, []PredicateSchema{
+		{Name: "cpuRequest", Type: "float"},
+		{Name: "labels", Type: "uid", List: true},
+		{Name: "name", Type: "string", Indexed: true, Tokenizers: []string{"exact", "fulltext"}},
+		{Name: "namespace", Type: "uid", Reverse: true, Count: true},
+	}, predicateSchemas(nodes))
+
+	utils.Equals(t, []PredicateSchema{}, predicateSchemas(nil))
+}
+
+func TestPredicateSchemasWithPrefix(t *testing.T) {
+	utils.Ok(t, SetPredicatePrefix("purser"))
+	defer func() { predicatePrefix = "" }()
+
+	nodes := []*api.SchemaNode{
+		{Predicate: "purser.name", Type: "string", Index: true, Tokenizer: []string{"exact"}},
+		{Predicate: "dgraph.type", Type: "string"},
+		{Predicate: "name", Type: "string"},
+		{Predicate: "purserx.name", Type: "string"},
+		{Predicate: "purser.cpuRequest", Type: "float"},
+	}
+	utils.Equals(t, []PredicateSchema{
+		{Name: "cpuRequest", Type: "float"},
+		{Name: "name", Type: "string", Indexed: true, Tokenizers: []string{"exact"}},
+	}, predicateSchemas(nodes))
+}
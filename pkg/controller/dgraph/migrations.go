@@ -0,0 +1,408 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/dgraph-io/dgo/protos/api"
+)
+
+const (
+	isSchemaVersion  = "isSchemaVersion"
+	schemaVersionXID = "purser-schema-version"
+)
+
+// migration is a versioned change of the Dgraph schema
+type migration struct {
+	version     int
+	description string
+	schema      string
+}
+
+// migrations are applied in order and each one only once. New schema changes must be appended as a new migration.
+var migrations = []migration{
+	{
+		version:     1,
+		description: "base schema",
+		schema: `
+			name: string @index(term) .
+			xid:  string @index(term) .
+			startTime: dateTime @index(hour) .
+			endTime: dateTime @index(hour) .
+			date: dateTime @index(day) .
+			isService: bool .
+			isPod: bool .
+			isContainer: bool .
+			isProc: bool .
+			pod: uid @reverse .
+			namespace: uid @reverse .
+			deployment: uid @reverse .
+			replicaset: uid @reverse .
+			statefulset: uid @reverse .
+			container: uid @reverse .
+			service: uid @reverse .
+			node: uid @reverse .
+			pv: uid @reverse .
+			daemonset: uid @reverse .
+			job: uid @reverse .
+			label: uid @reverse .
+			group: uid @reverse .
+			environment: uid @reverse .
+			key: string @index(term) .
+			value: string @index(term) .
+		`,
+	},
+	{
+		version:     2,
+		description: "indices for frequently filtered predicates",
+		schema: `
+			xid: string @index(hash) .
+			name: string @index(term) .
+			startTime: dateTime @index(hour) .
+			endTime: dateTime @index(hour) .
+			cpuCost: float @index(float) .
+			memoryCost: float @index(float) .
+			storageCost: float @index(float) .
+			totalCost: float @index(float) .
+			monthlyCost: float @index(float) .
+			schemaVersion: int .
+		`,
+	},
+	{
+		version:     3,
+		description: "kubernetes events linked to pods and nodes",
+		schema: `
+			reason: string @index(exact) .
+			involvedPod: uid @reverse .
+			involvedNode: uid @reverse .
+		`,
+	},
+	{
+		version:     4,
+		description: "node pressure intervals",
+		schema: `
+			condition: string @index(exact) .
+		`,
+	},
+	{
+		version:     5,
+		description: "archived namespace costs",
+		schema: `
+			namespaceName: string @index(exact) .
+			lifetimeEnd: dateTime @index(hour) .
+		`,
+	},
+	{
+		version:     6,
+		description: "applications from app.kubernetes.io labels",
+		schema: `
+			application: uid @reverse .
+			appName: string @index(exact) .
+			instance: string @index(exact) .
+			partOf: string @index(exact) .
+		`,
+	},
+	{
+		version:     7,
+		description: "helm releases",
+		schema: `
+			helmRelease: uid @reverse .
+			helmChart: string @index(exact) .
+		`,
+	},
+	{
+		version:     8,
+		description: "operators installed by OLM",
+		schema: `
+			operator: uid @reverse .
+			csvName: string @index(exact) .
+		`,
+	},
+	{
+		version:     9,
+		description: "service types and volume snapshots for namespace line items",
+		schema: `
+			serviceType: string @index(exact) .
+			restoreSize: float .
+		`,
+	},
+	{
+		version:     10,
+		description: "cost center of pods",
+		schema: `
+			costCenter: string @index(exact) .
+		`,
+	},
+	{
+		version:     11,
+		description: "cluster overhead namespaces and daemonsets",
+		schema: `
+			clusterOverhead: bool @index(bool) .
+		`,
+	},
+	{
+		version:     12,
+		description: "cpu activity of deployments",
+		schema: `
+			activityTrackedSince: dateTime @index(hour) .
+			lastActiveTime: dateTime @index(hour) .
+		`,
+	},
+	{
+		version:     13,
+		description: "usage heatmaps of deployments",
+		schema: `
+			usageHeatmap: string .
+		`,
+	},
+	{
+		version:     14,
+		description: "placement history of pods with node pools and zones",
+		schema: `
+			isPodPlacement: bool .
+			placement: uid @reverse .
+			nodePool: string @index(exact) .
+			zone: string @index(exact) .
+		`,
+	},
+	{
+		version:     15,
+		description: "kubelet versions and OS images of nodes",
+		schema: `
+			isNodeVersion: bool .
+			kubeletVersion: string @index(exact) .
+			osImage: string @index(exact) .
+		`,
+	},
+	{
+		version:     16,
+		description: "seals of the daily cost summaries",
+		schema: `
+			isDailySeal: bool .
+		`,
+	},
+	{
+		version:     17,
+		description: "archive of the soft deleted resources",
+		schema: `
+			archivedMarker: string .
+			archivedAt: dateTime @index(hour) .
+			retained: bool .
+		`,
+	},
+	{
+		version:     18,
+		description: "object counts of namespaces",
+		schema: `
+			isObjectCount: bool .
+			sampleTime: dateTime @index(hour) .
+		`,
+	},
+	{
+		version:     19,
+		description: "interactions of pods with services without pods",
+		schema: `
+			externalService: uid @reverse .
+			externalName: string @index(exact) .
+		`,
+	},
+	{
+		version:     20,
+		description: "network policies",
+		schema: `
+			isNetworkPolicy: bool .
+			podSelector: string .
+		`,
+	},
+	{
+		version:     21,
+		description: "network traffic ingested from flow logs",
+		schema: `
+			isNetworkTraffic: bool .
+			isFlowLogCursor: bool .
+			trafficPod: uid @reverse .
+			trafficSource: string @index(exact) .
+		`,
+	},
+	{
+		version:     22,
+		description: "cost center hierarchy",
+		schema: `
+			isCostCenter: bool .
+			parentCostCenter: string @index(exact) .
+			costCenterNamespaces: string .
+			costCenterLabels: string .
+			costCenterSource: string @index(exact) .
+		`,
+	},
+	{
+		version:     23,
+		description: "gpu requests, limits and capacities",
+		schema: `
+			gpuRequest: float .
+			gpuLimit: float .
+			gpuCapacity: float .
+		`,
+	},
+	{
+		version:     24,
+		description: "on-demand prices of the nodes from the cloud providers",
+		schema: `
+			isInstancePrice: bool .
+			pricePerHour: float .
+			nodePremium: float .
+		`,
+	},
+	{
+		version:     25,
+		description: "capacity type (on-demand or spot) of the nodes and of the pod placements",
+		schema: `
+			capacityType: string @index(exact) .
+		`,
+	},
+	{
+		version:     26,
+		description: "average cpu and memory usage of the pods for the usage based allocation policies",
+		schema: `
+			cpuUsage: float .
+			memoryUsage: float .
+			usageSamples: int .
+		`,
+	},
+	{
+		version:     27,
+		description: "durable interactions between the services and workloads owning the interacting pods",
+		schema: `
+			isWorkloadInteraction: bool .
+			sourceWorkload: string @index(exact) .
+			sourceKind: string .
+			sourceNamespace: string @index(exact) .
+			destinationWorkload: string @index(exact) .
+			destinationKind: string .
+			destinationNamespace: string @index(exact) .
+			interactionCount: float .
+			firstSeen: dateTime .
+			lastSeen: dateTime @index(hour) .
+		`,
+	},
+	{
+		version:     28,
+		description: "reference counts of the labels, stored by the collection of the labels",
+		schema: `
+			refCount: int .
+		`,
+	},
+	{
+		version:     29,
+		description: "snapshots of the cost of the pods for the cost trends",
+		schema: `
+			isCostSnapshot: bool .
+			snapshotHours: float .
+			snapshotPod: string .
+			snapshotNamespace: string @index(exact) .
+			workload: string @index(exact) .
+		`,
+	},
+	{
+		version:     30,
+		description: "price history of the pricing catalog",
+		schema: `
+			isPricePeriod: bool .
+		`,
+	},
+	{
+		version:     31,
+		description: "metric samples of the requests and limits of the containers",
+		schema: `
+			isMetricSample: bool .
+			sample: uid .
+		`,
+	},
+}
+
+// schemaVersion is the node which records the latest applied migration
+type schemaVersion struct {
+	ID
+	IsSchemaVersion bool `json:"isSchemaVersion,omitempty"`
+	Version         int  `json:"schemaVersion"`
+}
+
+// Migrate applies all migrations newer than the schema version recorded in Dgraph
+func Migrate() error {
+	current := getSchemaVersion()
+	for _, m := range pendingMigrations(current.Version) {
+		log.Infof("applying dgraph schema migration: (%d) %s", m.version, m.description)
+		err := client.Alter(baseContext, &api.Operation{Schema: prefixSchema(m.schema)})
+		if err != nil {
+			return err
+		}
+
+		current.Version = m.version
+		assigned, err := MutateNode(current, CREATE)
+		if err != nil {
+			return err
+		}
+		if current.UID == "" {
+			current.UID = assigned.Uids["blank-0"]
+		}
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations newer than the given schema version in the order they must be applied
+func pendingMigrations(version int) []migration {
+	var pending []migration
+	for _, m := range migrations {
+		if m.version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+func getSchemaVersion() schemaVersion {
+	current := schemaVersion{
+		ID:              ID{Xid: schemaVersionXID},
+		IsSchemaVersion: true,
+	}
+	query := `query {
+		version(func: has(` + isSchemaVersion + `)) {
+			uid
+			schemaVersion
+		}
+	}`
+	resp, err := queryJSON(baseContext, client.NewReadOnlyTxn(), query, nil)
+	if err != nil {
+		log.Debugf("unable to read dgraph schema version: %v", err)
+		return current
+	}
+
+	type root struct {
+		Version []schemaVersion `json:"version"`
+	}
+	r := root{}
+	if err = json.Unmarshal(resp, &r); err != nil || len(r.Version) == 0 {
+		return current
+	}
+	current.UID = r.Version[0].UID
+	current.Version = r.Version[0].Version
+	return current
+}
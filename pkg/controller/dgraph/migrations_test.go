@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package dgraph
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+var schemaLine = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.]*: +\[?(string|int|float|bool|dateTime|uid|geo|password)\]?( +@[a-zA-Z]+(\([a-z0-9, ]+\))?)* +\.$`)
+
+func TestMigrations(t *testing.T) {
+	for i, m := range migrations {
+		// versions follow each other so that a migration can't be inserted before the ones already applied
+		utils.Equals(t, i+1, m.version)
+		utils.Assert(t, m.description != "", "migration %d has no description", m.version)
+		lines := 0
+		for _, line := range strings.Split(m.schema, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			lines++
+			utils.Assert(t, schemaLine.MatchString(line), "migration %d has an invalid schema line: %s", m.version, line)
+		}
+		utils.Assert(t, lines > 0, "migration %d has no schema", m.version)
+	}
+}
+
+func TestPendingMigrations(t *testing.T) {
+	utils.Equals(t, len(migrations), len(pendingMigrations(0)))
+	pending := pendingMigrations(2)
+	utils.Equals(t, len(migrations)-2, len(pending))
+	utils.Equals(t, 3, pending[0].version)
+	utils.Equals(t, 0, len(pendingMigrations(migrations[len(migrations)-1].version)))
+}
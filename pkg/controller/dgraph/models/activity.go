@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// deploymentActivity records since when the cpu usage of the pods of a deployment is sampled, the last time
+// one of its pods used more cpu than the activity threshold and the usage heatmap of the deployment
+type deploymentActivity struct {
+	dgraph.ID
+	ActivityTrackedSince string `json:"activityTrackedSince,omitempty"`
+	LastActiveTime       string `json:"lastActiveTime,omitempty"`
+	UsageHeatmap         string `json:"usageHeatmap,omitempty"`
+}
+
+// RecordDeploymentActivity updates the activity of the deployments of the namespaces for which owns returns true from
+// the cpu usage (in cores) of their pods sampled at the given time. podCPU is keyed by pod xid (namespace:name).
+// A deployment is active when one of its pods uses at least threshold cores. The cpu usage of all pods of a deployment
+// is added to its usage heatmap. It returns the number of active deployments.
+func RecordDeploymentActivity(podCPU map[string]utils.Cores, threshold utils.Cores, owns func(namespace string) bool, at time.Time) (int, error) {
+	query := `{
+		deployments(func: has(isDeployment)) @filter(NOT has(endTime)) {
+			uid
+			xid
+			activityTrackedSince
+			usageHeatmap
+			~deployment @filter(has(isReplicaset)) {
+				~replicaset @filter(has(isPod) AND NOT has(endTime)) {
+					xid
+				}
+			}
+		}
+	}`
+	type pod struct {
+		Xid string `json:"xid"`
+	}
+	type replicaset struct {
+		Pods []pod `json:"~replicaset"`
+	}
+	type deployment struct {
+		deploymentActivity
+		Replicasets []replicaset `json:"~deployment"`
+	}
+	type root struct {
+		Deployments []deployment `json:"deployments"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(query, &newRoot); err != nil {
+		return 0, err
+	}
+
+	sampleTime := at.Format(time.RFC3339)
+	updates := []deploymentActivity{}
+	active := 0
+	for _, d := range newRoot.Deployments {
+		if !owns(strings.SplitN(d.Xid, ":", 2)[0]) {
+			continue
+		}
+		var pods []string
+		for _, rs := range d.Replicasets {
+			for _, p := range rs.Pods {
+				pods = append(pods, p.Xid)
+			}
+		}
+		isSampled, isActive := sampleActivity(pods, podCPU, threshold)
+		if !isSampled {
+			continue
+		}
+		update := deploymentActivity{ID: dgraph.ID{UID: d.UID, Xid: d.Xid}}
+		if d.ActivityTrackedSince == "" {
+			update.ActivityTrackedSince = sampleTime
+		}
+		if isActive {
+			update.LastActiveTime = sampleTime
+			active++
+		}
+		heatmap, err := DecodeUsageHeatmap(d.UsageHeatmap)
+		if err != nil {
+			log.Errorf("resetting invalid usage heatmap of deployment: (%s), error: (%v)", d.Xid, err)
+			heatmap = UsageHeatmap{}
+		}
+		heatmap.add(float64(workloadCPU(pods, podCPU)), at)
+		if update.UsageHeatmap, err = heatmap.encode(); err != nil {
+			return active, err
+		}
+		updates = append(updates, update)
+	}
+	if len(updates) == 0 {
+		return active, nil
+	}
+	_, err := dgraph.MutateNode(updates, dgraph.UPDATE)
+	return active, err
+}
+
+// sampleActivity tells whether the cpu usage of any of the pods was sampled and whether any of them used at least
+// threshold cores
+func sampleActivity(pods []string, podCPU map[string]utils.Cores, threshold utils.Cores) (isSampled, isActive bool) {
+	for _, xid := range pods {
+		cpu, hasSample := podCPU[xid]
+		if !hasSample {
+			continue
+		}
+		isSampled = true
+		if cpu >= threshold {
+			isActive = true
+		}
+	}
+	return isSampled, isActive
+}
+
+// workloadCPU returns the total cpu usage of the pods
+func workloadCPU(pods []string, podCPU map[string]utils.Cores) utils.Cores {
+	var total utils.Cores
+	for _, xid := range pods {
+		total += podCPU[xid]
+	}
+	return total
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	units "github.com/vmware/purser/pkg/controller/utils"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestSampleActivity(t *testing.T) {
+	podCPU := map[string]units.Cores{"shop:web-1": 0.002, "shop:web-2": 0.25}
+
+	isSampled, isActive := sampleActivity([]string{"shop:web-1"}, podCPU, 0.01)
+	utils.Assert(t, isSampled && !isActive, "pod below the threshold is sampled and idle")
+
+	isSampled, isActive = sampleActivity([]string{"shop:web-1", "shop:web-2"}, podCPU, 0.01)
+	utils.Assert(t, isSampled && isActive, "any pod above the threshold makes the deployment active")
+
+	isSampled, isActive = sampleActivity([]string{"shop:api-1"}, podCPU, 0.01)
+	utils.Assert(t, !isSampled && !isActive, "pods without metrics are not sampled")
+}
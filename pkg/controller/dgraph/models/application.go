@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsApplication = "isApplication"
+)
+
+// Recommended kubernetes labels which identify the application of a workload
+const (
+	AppNameLabel     = "app.kubernetes.io/name"
+	AppInstanceLabel = "app.kubernetes.io/instance"
+	AppPartOfLabel   = "app.kubernetes.io/part-of"
+)
+
+// Application schema in dgraph. It is an instance of an application (app.kubernetes.io/name and instance
+// labels) which can span namespaces. PartOf is the higher level application it belongs to.
+type Application struct {
+	dgraph.ID
+	IsApplication bool   `json:"isApplication,omitempty"`
+	Name          string `json:"name,omitempty"`
+	AppName       string `json:"appName,omitempty"`
+	Instance      string `json:"instance,omitempty"`
+	PartOf        string `json:"partOf,omitempty"`
+	Type          string `json:"type,omitempty"`
+}
+
+// GetApplicationXID returns the xid of the application instance, empty if the labels do not name an application
+func GetApplicationXID(labels map[string]string) string {
+	name := labels[AppNameLabel]
+	if name == "" {
+		return ""
+	}
+	if instance := labels[AppInstanceLabel]; instance != "" {
+		return name + ":" + instance
+	}
+	return name
+}
+
+// getApplication returns the application of the pod with the given labels, creating it if needed.
+// It returns nil if the pod has no app.kubernetes.io/name label.
+func getApplication(labels map[string]string) *Application {
+	xid := GetApplicationXID(labels)
+	if xid == "" {
+		return nil
+	}
+	uid := dgraph.GetUID(xid, IsApplication)
+	if uid == "" {
+		app := Application{
+			ID:            dgraph.ID{Xid: xid},
+			Name:          "application-" + xid,
+			IsApplication: true,
+			AppName:       labels[AppNameLabel],
+			Instance:      labels[AppInstanceLabel],
+			PartOf:        labels[AppPartOfLabel],
+			Type:          "application",
+		}
+		assigned, err := dgraph.MutateNode(app, dgraph.CREATE)
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+		log.Infof("Application with xid: (%s) persisted", xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return &Application{ID: dgraph.ID{UID: uid, Xid: xid}}
+}
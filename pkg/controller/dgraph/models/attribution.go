@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+// DefaultCostCenterAnnotation is the pod annotation giving the cost center charged for the pod
+const DefaultCostCenterAnnotation = "purser.vmware.com/cost-center"
+
+// DefaultTeamLabels are the label keys which attribute a pod to a team
+var DefaultTeamLabels = []string{"team"}
+
+// AttributionSettings are the labels and annotation used to attribute the cost of a pod to a team or cost center.
+type AttributionSettings struct {
+	TeamLabels           []string `json:"teamLabels,omitempty"`
+	CostCenterAnnotation string   `json:"costCenterAnnotation,omitempty"`
+}
+
+var attribution = AttributionSettings{TeamLabels: DefaultTeamLabels, CostCenterAnnotation: DefaultCostCenterAnnotation}
+
+// SetAttributionSettings sets the team labels and cost center annotation, empty values keep the defaults.
+func SetAttributionSettings(settings AttributionSettings) {
+	if len(settings.TeamLabels) == 0 {
+		settings.TeamLabels = DefaultTeamLabels
+	}
+	if settings.CostCenterAnnotation == "" {
+		settings.CostCenterAnnotation = DefaultCostCenterAnnotation
+	}
+	attribution = settings
+}
+
+// GetAttributionSettings returns the team labels and cost center annotation in use
+func GetAttributionSettings() AttributionSettings {
+	return attribution
+}
+
+// getCostCenter returns the cost center of a pod from its annotations, or from the labels it inherits from its
+// namespace when the cost center annotation is one of the inherited keys.
+func getCostCenter(annotations, labels map[string]string) string {
+	if costCenter := annotations[attribution.CostCenterAnnotation]; costCenter != "" {
+		return costCenter
+	}
+	return labels[attribution.CostCenterAnnotation]
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsClusterEvent = "isClusterEvent"
+)
+
+// Reasons of the kubernetes events which are persisted, they explain wasted or missing capacity
+const (
+	FailedScheduling = "FailedScheduling"
+	Evicted          = "Evicted"
+	NodeNotReady     = "NodeNotReady"
+	BackOff          = "BackOff"
+)
+
+// ClusterEvent schema in dgraph, it is a kubernetes event linked to the affected pod or node.
+// StartTime and EndTime are the first and the last occurrence of the event.
+type ClusterEvent struct {
+	dgraph.ID
+	IsClusterEvent bool       `json:"isClusterEvent,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	Message        string     `json:"message,omitempty"`
+	Severity       string     `json:"severity,omitempty"`
+	Count          int32      `json:"count,omitempty"`
+	StartTime      string     `json:"startTime,omitempty"`
+	EndTime        string     `json:"endTime,omitempty"`
+	Namespace      *Namespace `json:"namespace,omitempty"`
+	Pod            *Pod       `json:"involvedPod,omitempty"`
+	Node           *Node      `json:"involvedNode,omitempty"`
+	Type           string     `json:"type,omitempty"`
+}
+
+// IsCostRelevantEvent returns true if the event has one of the reasons which are persisted
+func IsCostRelevantEvent(event api_v1.Event) bool {
+	switch event.Reason {
+	case FailedScheduling, Evicted, NodeNotReady, BackOff:
+		return true
+	}
+	return false
+}
+
+func createClusterEventObject(event api_v1.Event) ClusterEvent {
+	newEvent := newClusterEvent(event)
+
+	// the pod or node is linked only if it is already persisted, an event must not create a resource which is charged
+	switch event.InvolvedObject.Kind {
+	case "Pod":
+		podXID := event.InvolvedObject.Namespace + ":" + event.InvolvedObject.Name
+		if podUID := dgraph.GetUID(podXID, IsPod); podUID != "" {
+			newEvent.Pod = &Pod{ID: dgraph.ID{UID: podUID, Xid: podXID}}
+		}
+	case "Node":
+		if nodeUID := dgraph.GetUID(event.InvolvedObject.Name, IsNode); nodeUID != "" {
+			newEvent.Node = &Node{ID: dgraph.ID{UID: nodeUID, Xid: event.InvolvedObject.Name}}
+		}
+	}
+	if event.InvolvedObject.Namespace != "" {
+		namespaceUID := CreateOrGetNamespaceByID(event.InvolvedObject.Namespace)
+		if namespaceUID != "" {
+			newEvent.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: event.InvolvedObject.Namespace}}
+		}
+	}
+	return newEvent
+}
+
+// newClusterEvent returns the event with its reason, message and occurrences, it is not linked to the involved objects
+func newClusterEvent(event api_v1.Event) ClusterEvent {
+	newEvent := ClusterEvent{
+		Name:           "event-" + event.Name,
+		IsClusterEvent: true,
+		Type:           "event",
+		ID:             dgraph.ID{Xid: event.Namespace + ":" + event.Name},
+		Reason:         event.Reason,
+		Message:        event.Message,
+		Severity:       event.Type,
+		Count:          event.Count,
+		StartTime:      eventTime(event.FirstTimestamp, event.CreationTimestamp).Format(time.RFC3339),
+		EndTime:        eventTime(event.LastTimestamp, event.CreationTimestamp).Format(time.RFC3339),
+	}
+	if newEvent.Count == 0 {
+		newEvent.Count = 1
+	}
+	return newEvent
+}
+
+// eventTime returns t, or fallback if t is not set
+func eventTime(t, fallback meta_v1.Time) time.Time {
+	if t.IsZero() {
+		return fallback.Time
+	}
+	return t.Time
+}
+
+// StoreClusterEvent creates a new event in the Dgraph and updates its count and last occurrence if already present.
+func StoreClusterEvent(event api_v1.Event) (string, error) {
+	xid := event.Namespace + ":" + event.Name
+	uid := dgraph.GetUID(xid, IsClusterEvent)
+
+	newEvent := createClusterEventObject(event)
+	if uid != "" {
+		newEvent.UID = uid
+	}
+	assigned, err := dgraph.MutateNode(newEvent, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	return assigned.Uids["blank-0"], nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestIsCostRelevantEvent ...
+func TestIsCostRelevantEvent(t *testing.T) {
+	for _, reason := range []string{FailedScheduling, Evicted, NodeNotReady, BackOff} {
+		utils.Assert(t, IsCostRelevantEvent(api_v1.Event{Reason: reason}), "%s events are not persisted", reason)
+	}
+	utils.Assert(t, !IsCostRelevantEvent(api_v1.Event{Reason: "Scheduled"}), "Scheduled events are persisted")
+}
+
+// TestNewClusterEvent ...
+func TestNewClusterEvent(t *testing.T) {
+	created := time.Date(2018, 11, 1, 10, 0, 0, 0, time.UTC)
+	event := api_v1.Event{
+		ObjectMeta:    meta_v1.ObjectMeta{Name: "web-1.156", Namespace: "shop", CreationTimestamp: meta_v1.NewTime(created)},
+		Reason:        FailedScheduling,
+		Message:       "0/3 nodes are available: 3 Insufficient cpu.",
+		Type:          "Warning",
+		Count:         4,
+		LastTimestamp: meta_v1.NewTime(created.Add(time.Hour)),
+	}
+	newEvent := newClusterEvent(event)
+	utils.Equals(t, "shop:web-1.156", newEvent.Xid)
+	utils.Equals(t, "event-web-1.156", newEvent.Name)
+	utils.Equals(t, "Warning", newEvent.Severity)
+	utils.Equals(t, int32(4), newEvent.Count)
+	// the first occurrence of an event without first timestamp is its creation
+	utils.Equals(t, "2018-11-01T10:00:00Z", newEvent.StartTime)
+	utils.Equals(t, "2018-11-01T11:00:00Z", newEvent.EndTime)
+	utils.Assert(t, newEvent.Pod == nil && newEvent.Node == nil && newEvent.Namespace == nil, "event linked: %v", newEvent)
+
+	// events recorded by the event series api have no count
+	event.Count = 0
+	utils.Equals(t, int32(1), newClusterEvent(event).Count)
+}
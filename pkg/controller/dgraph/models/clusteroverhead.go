@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"strings"
+)
+
+// OverheadLabel is the namespace label which overrides the heuristics: "true" or "false"
+const OverheadLabel = "purser.vmware.com/overhead"
+
+// Reasons for which a namespace or daemonset is classified as cluster overhead
+const (
+	OverheadFromSettings = "settings"
+	OverheadFromLabel    = "label"
+	OverheadSystemNS     = "system namespace"
+	OverheadNodeAgent    = "node agent"
+)
+
+// systemNamespaces are the namespaces of kubernetes and of the usual cluster add-ons
+var systemNamespaces = map[string]bool{
+	"kube-system":       true,
+	"kube-public":       true,
+	"kube-node-lease":   true,
+	"kube-flannel":      true,
+	"calico-system":     true,
+	"tigera-operator":   true,
+	"cilium":            true,
+	"istio-system":      true,
+	"linkerd":           true,
+	"ingress-nginx":     true,
+	"cert-manager":      true,
+	"monitoring":        true,
+	"logging":           true,
+	"gatekeeper-system": true,
+	"kyverno":           true,
+	"olm":               true,
+}
+
+// systemNamespacePrefixes are the prefixes of the namespaces created by kubernetes distributions
+var systemNamespacePrefixes = []string{"kube-", "openshift-"}
+
+// nodeAgents are name fragments of the daemonsets of CNI plugins, proxies, log shippers and monitoring agents
+var nodeAgents = []string{
+	"kube-proxy", "calico", "cilium", "flannel", "weave", "canal", "aws-node", "antrea", "azure-cni",
+	"node-exporter", "fluentd", "fluent-bit", "promtail", "datadog", "newrelic", "otel-agent",
+	"csi-", "nvidia-device-plugin", "node-local-dns", "konnectivity",
+}
+
+// ClusterOverheadSettings add namespaces and daemonsets (namespace:name) to the cluster overhead, or exclude them,
+// on top of the heuristics which recognize the system namespaces and the node agents.
+type ClusterOverheadSettings struct {
+	Namespaces        []string `json:"namespaces,omitempty"`
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	DaemonSets        []string `json:"daemonSets,omitempty"`
+	ExcludeDaemonSets []string `json:"excludeDaemonSets,omitempty"`
+}
+
+var overheadSettings = struct {
+	namespaces, excludedNamespaces, daemonsets, excludedDaemonsets map[string]bool
+}{}
+
+// SetClusterOverheadSettings sets the namespaces and daemonsets added to or excluded from the cluster overhead
+func SetClusterOverheadSettings(settings ClusterOverheadSettings) {
+	overheadSettings.namespaces = toSet(settings.Namespaces)
+	overheadSettings.excludedNamespaces = toSet(settings.ExcludeNamespaces)
+	overheadSettings.daemonsets = toSet(settings.DaemonSets)
+	overheadSettings.excludedDaemonsets = toSet(settings.ExcludeDaemonSets)
+}
+
+// classifyNamespace returns the reason for which the namespace is cluster overhead, empty if it is not.
+// Settings take precedence over the overhead label of the namespace, which takes precedence over the heuristics.
+func classifyNamespace(name string, labels map[string]string) string {
+	switch {
+	case overheadSettings.excludedNamespaces[name]:
+		return ""
+	case overheadSettings.namespaces[name]:
+		return OverheadFromSettings
+	}
+	if value, isSet := labels[OverheadLabel]; isSet {
+		if value == "false" {
+			return ""
+		}
+		return OverheadFromLabel
+	}
+	if systemNamespaces[name] {
+		return OverheadSystemNS
+	}
+	for _, prefix := range systemNamespacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return OverheadSystemNS
+		}
+	}
+	return ""
+}
+
+// classifyDaemonset returns the reason for which the daemonset is cluster overhead, empty if it is not.
+// Daemonsets of overhead namespaces are accounted with their namespace.
+func classifyDaemonset(namespace, name string) string {
+	xid := namespace + ":" + name
+	switch {
+	case overheadSettings.excludedDaemonsets[xid]:
+		return ""
+	case overheadSettings.daemonsets[xid]:
+		return OverheadFromSettings
+	}
+	for _, agent := range nodeAgents {
+		if strings.Contains(name, agent) {
+			return OverheadNodeAgent
+		}
+	}
+	return ""
+}
+
+// overheadFields returns the values of the clusterOverhead and overheadReason predicates for a reason
+func overheadFields(reason string) (*bool, string) {
+	isOverhead := reason != ""
+	return &isOverhead, reason
+}
+
+func toSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
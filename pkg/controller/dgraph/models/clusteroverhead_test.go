@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestClassifyClusterOverhead(t *testing.T) {
+	SetClusterOverheadSettings(ClusterOverheadSettings{
+		Namespaces:        []string{"platform"},
+		ExcludeNamespaces: []string{"kube-apps"},
+		ExcludeDaemonSets: []string{"search:fluent-bit"},
+	})
+	defer SetClusterOverheadSettings(ClusterOverheadSettings{})
+
+	utils.Equals(t, OverheadSystemNS, classifyNamespace("kube-system", nil))
+	utils.Equals(t, OverheadSystemNS, classifyNamespace("openshift-monitoring", nil))
+	utils.Equals(t, OverheadFromSettings, classifyNamespace("platform", nil))
+	utils.Equals(t, "", classifyNamespace("kube-apps", nil))
+	utils.Equals(t, OverheadFromLabel, classifyNamespace("shared", map[string]string{OverheadLabel: "true"}))
+	utils.Equals(t, "", classifyNamespace("monitoring", map[string]string{OverheadLabel: "false"}))
+	utils.Equals(t, "", classifyNamespace("payments", nil))
+
+	utils.Equals(t, OverheadNodeAgent, classifyDaemonset("search", "datadog-agent"))
+	utils.Equals(t, "", classifyDaemonset("search", "fluent-bit"))
+	utils.Equals(t, "", classifyDaemonset("search", "log-forwarder"))
+}
@@ -19,10 +19,11 @@ package models
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 	api_v1 "k8s.io/api/core/v1"
@@ -37,67 +38,93 @@ const (
 // Container schema in dgraph
 type Container struct {
 	dgraph.ID
-	IsContainer   bool       `json:"isContainer,omitempty"`
-	Name          string     `json:"name,omitempty"`
-	StartTime     string     `json:"startTime,omitempty"`
-	EndTime       string     `json:"endTime,omitempty"`
-	Pod           Pod        `json:"pod,omitempty"`
-	Procs         []*Proc    `json:"procs,omitempty"`
-	Namespace     *Namespace `json:"namespace,omitempty"`
-	CPURequest    float64    `json:"cpuRequest,omitempty"`
-	CPULimit      float64    `json:"cpuLimit,omitempty"`
-	MemoryRequest float64    `json:"memoryRequest,omitempty"`
-	MemoryLimit   float64    `json:"memoryLimit,omitempty"`
-	Type          string     `json:"type,omitempty"`
+	IsContainer   bool        `json:"isContainer,omitempty"`
+	Name          string      `json:"name,omitempty"`
+	Image         string      `json:"image,omitempty"`
+	StartTime     string      `json:"startTime,omitempty"`
+	EndTime       string      `json:"endTime,omitempty"`
+	Pod           Pod         `json:"pod,omitempty"`
+	Procs         []*Proc     `json:"procs,omitempty"`
+	Namespace     *Namespace  `json:"namespace,omitempty"`
+	CPURequest    utils.Cores `json:"cpuRequest,omitempty"`
+	CPULimit      utils.Cores `json:"cpuLimit,omitempty"`
+	MemoryRequest utils.GB    `json:"memoryRequest,omitempty"`
+	MemoryLimit   utils.GB    `json:"memoryLimit,omitempty"`
+	GPURequest    float64     `json:"gpuRequest,omitempty"`
+	GPULimit      float64     `json:"gpuLimit,omitempty"`
+	Type          string      `json:"type,omitempty"`
+	Ephemeral     bool        `json:"ephemeral,omitempty"`
+	TargetName    string      `json:"targetContainer,omitempty"`
 }
 
-func newContainer(container api_v1.Container, podUID, namespaceUID string, pod api_v1.Pod) (*api.Assigned, error) {
+// newContainer returns the container node to create, with the blank node name given as uid
+func newContainer(container api_v1.Container, podUID, namespaceUID string, pod api_v1.Pod, blank string) *Container {
 	containerXid := pod.Namespace + ":" + pod.Name + ":" + container.Name
 	requests := container.Resources.Requests
 	limits := container.Resources.Limits
 	c := &Container{
-		ID:            dgraph.ID{Xid: containerXid},
+		ID:            dgraph.ID{Xid: containerXid, UID: "_:" + blank},
 		Name:          "container-" + container.Name,
+		Image:         container.Image,
 		IsContainer:   true,
 		Type:          "container",
 		StartTime:     pod.GetCreationTimestamp().Time.Format(time.RFC3339),
 		Pod:           Pod{ID: dgraph.ID{UID: podUID, Xid: pod.Namespace + ":" + pod.Name}},
-		CPURequest:    utils.ConvertToFloat64CPU(requests.Cpu()),
-		CPULimit:      utils.ConvertToFloat64CPU(limits.Cpu()),
-		MemoryRequest: utils.ConvertToFloat64GB(requests.Memory()),
-		MemoryLimit:   utils.ConvertToFloat64GB(limits.Memory()),
+		CPURequest:    utils.CPUCores(*requests.Cpu()),
+		CPULimit:      utils.CPUCores(*limits.Cpu()),
+		MemoryRequest: utils.MemoryGB(*requests.Memory()),
+		MemoryLimit:   utils.MemoryGB(*limits.Memory()),
+		GPURequest:    getContainerGPUs(container, pod.Annotations),
+		GPULimit:      getContainerGPULimit(container, pod.Annotations),
 	}
 	if namespaceUID != "" {
 		c.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: pod.Namespace}}
 	}
-	return dgraph.MutateNode(c, dgraph.CREATE)
+	return c
 }
 
 // StoreAndRetrieveContainersAndMetrics fetchs the list of containers in given pod
 // Create a new container in dgraph if container is not in it.
+// A metrics sample is stored for the containers whose requests or limits changed.
 func StoreAndRetrieveContainersAndMetrics(pod api_v1.Pod, podUID, namespaceUID string) ([]*Container, Metrics) {
 	containers := []*Container{}
 	cpuRequest := &resource.Quantity{}
 	memoryRequest := &resource.Quantity{}
 	cpuLimit := &resource.Quantity{}
 	memoryLimit := &resource.Quantity{}
+	gpuRequest, gpuLimit := 0.0, 0.0
+	stored := storeContainersIfNotExist(pod, podUID, namespaceUID)
 	for _, c := range pod.Spec.Containers {
-		container, err := storeContainerIfNotExist(c, pod, podUID, namespaceUID)
-		if err == nil {
+		if container, isStored := stored[c.Name]; isStored {
 			containers = append(containers, container)
 			requests := c.Resources.Requests
 			limits := c.Resources.Limits
+			containerMetrics := Metrics{
+				CPURequest:    utils.CPUCores(*requests.Cpu()),
+				CPULimit:      utils.CPUCores(*limits.Cpu()),
+				MemoryRequest: utils.MemoryGB(*requests.Memory()),
+				MemoryLimit:   utils.MemoryGB(*limits.Memory()),
+				GPURequest:    getContainerGPUs(c, pod.Annotations),
+				GPULimit:      getContainerGPULimit(c, pod.Annotations),
+			}
+			if err := storeContainerMetrics(container.ID, containerMetrics, time.Now()); err != nil {
+				log.Errorf("unable to store metrics of container: (%s), error: (%v)", container.Xid, err)
+			}
 			utils.AddResourceAToResourceB(requests.Cpu(), cpuRequest)
 			utils.AddResourceAToResourceB(requests.Memory(), memoryRequest)
 			utils.AddResourceAToResourceB(limits.Cpu(), cpuLimit)
 			utils.AddResourceAToResourceB(limits.Memory(), memoryLimit)
+			gpuRequest += containerMetrics.GPURequest
+			gpuLimit += containerMetrics.GPULimit
 		}
 	}
 	return containers, Metrics{
-		CPURequest:    utils.ConvertToFloat64CPU(cpuRequest),
-		CPULimit:      utils.ConvertToFloat64CPU(cpuLimit),
-		MemoryRequest: utils.ConvertToFloat64GB(memoryRequest),
-		MemoryLimit:   utils.ConvertToFloat64GB(memoryLimit),
+		CPURequest:    utils.CPUCores(*cpuRequest),
+		CPULimit:      utils.CPUCores(*cpuLimit),
+		MemoryRequest: utils.MemoryGB(*memoryRequest),
+		MemoryLimit:   utils.MemoryGB(*memoryLimit),
+		GPURequest:    gpuRequest,
+		GPULimit:      gpuLimit,
 	}
 }
 
@@ -117,34 +144,46 @@ func StoreContainerProcessEdge(containerXID string, procsXIDs []string) error {
 	return err
 }
 
-func storeContainerIfNotExist(c api_v1.Container, pod api_v1.Pod, podUID, namespaceUID string) (*Container, error) {
+// storeContainersIfNotExist returns the containers of the pod by name. The containers which are not in dgraph are
+// created together in one mutation, containers which could not be created are left out.
+func storeContainersIfNotExist(pod api_v1.Pod, podUID, namespaceUID string) map[string]*Container {
 	podXid := pod.Namespace + ":" + pod.Name
-	containerXid := podXid + ":" + c.Name
-	containerUID := dgraph.GetUID(containerXid, IsContainer)
-
-	var container *Container
-	if containerUID == "" {
-		assigned, err := newContainer(c, podUID, namespaceUID, pod)
-		if err != nil {
-			log.Errorf("Unable to create container: %s", containerXid)
-			return container, err
+	containers := map[string]*Container{}
+	var missing []*Container
+	for _, c := range pod.Spec.Containers {
+		containerXid := podXid + ":" + c.Name
+		containerUID := dgraph.GetUID(containerXid, IsContainer)
+		if containerUID == "" {
+			missing = append(missing, newContainer(c, podUID, namespaceUID, pod, "container"+strconv.Itoa(len(missing))))
+			continue
 		}
-		log.Infof("Container with xid: (%s) persisted in dgraph", containerXid)
-		containerUID = assigned.Uids["blank-0"]
+		containers[c.Name] = &Container{ID: dgraph.ID{UID: containerUID, Xid: containerXid}}
+	}
+	if len(missing) == 0 {
+		return containers
 	}
 
-	container = &Container{
-		ID: dgraph.ID{UID: containerUID, Xid: containerXid},
+	assigned, err := dgraph.MutateNode(missing, dgraph.CREATE)
+	if err != nil {
+		log.Errorf("Unable to create containers of pod: %s, error: %v", podXid, err)
+		return containers
+	}
+	for _, container := range missing {
+		uid := assigned.Uids[strings.TrimPrefix(container.UID, "_:")]
+		if uid == "" {
+			continue
+		}
+		log.Infof("Container with xid: (%s) persisted in dgraph", container.Xid)
+		containers[strings.TrimPrefix(container.Name, "container-")] = &Container{ID: dgraph.ID{UID: uid, Xid: container.Xid}}
 	}
-	return container, nil
+	return containers
 }
 
 func deleteContainersInTerminatedPod(containers []*Container, endTime time.Time) {
 	for _, container := range containers {
 		container.EndTime = endTime.Format(time.RFC3339)
 	}
-	_, err := dgraph.MutateNode(containers, dgraph.UPDATE)
-	if err != nil {
+	if err := dgraph.QueueMutation(containers, dgraph.UPDATE); err != nil {
 		log.Error(err)
 	}
 	deleteProcessesInTerminatedContainers(containers)
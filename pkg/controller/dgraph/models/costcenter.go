@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsCostCenter = "isCostCenter"
+)
+
+// CostCenter schema in dgraph. It is a node of the cost center hierarchy of the organization, imported from a csv
+// file or from LDAP groups. Namespaces are a comma separated list of namespace names and labels a comma separated
+// list of key=value selectors, a cost center is charged the cost of its namespaces and of the pods matching one of
+// its selectors. Parent is the name of the parent cost center, empty for the top of the hierarchy.
+type CostCenter struct {
+	dgraph.ID
+	IsCostCenter bool   `json:"isCostCenter,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Parent       string `json:"parentCostCenter,omitempty"`
+	Namespaces   string `json:"costCenterNamespaces,omitempty"`
+	Labels       string `json:"costCenterLabels,omitempty"`
+	Source       string `json:"costCenterSource,omitempty"`
+}
+
+// CostCenterItem is an imported cost center with its mapping to namespaces and labels
+type CostCenterItem struct {
+	Name       string            `json:"name"`
+	Parent     string            `json:"parent,omitempty"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// GetNamespaces returns the namespaces mapped to the cost center
+func (c CostCenter) GetNamespaces() []string {
+	if c.Namespaces == "" {
+		return nil
+	}
+	return strings.Split(c.Namespaces, ",")
+}
+
+// GetLabels returns the label selectors mapped to the cost center
+func (c CostCenter) GetLabels() map[string]string {
+	labels := map[string]string{}
+	if c.Labels == "" {
+		return labels
+	}
+	for _, selector := range strings.Split(c.Labels, ",") {
+		keyValue := strings.SplitN(selector, "=", 2)
+		if len(keyValue) == 2 {
+			labels[keyValue[0]] = keyValue[1]
+		}
+	}
+	return labels
+}
+
+// ValidateCostCenters checks that the names of the cost centers are unique and that their parents are part of
+// the hierarchy without cycles
+func ValidateCostCenters(items []CostCenterItem) error {
+	parents := map[string]string{}
+	for _, item := range items {
+		if item.Name == "" {
+			return fmt.Errorf("cost center name is empty")
+		}
+		if _, isDuplicate := parents[item.Name]; isDuplicate {
+			return fmt.Errorf("cost center: %s is defined twice", item.Name)
+		}
+		parents[item.Name] = item.Parent
+	}
+	for _, item := range items {
+		seen := map[string]bool{item.Name: true}
+		for parent := item.Parent; parent != ""; parent = parents[parent] {
+			if _, isDefined := parents[parent]; !isDefined {
+				return fmt.Errorf("parent: %s of cost center: %s is not defined", parent, item.Name)
+			}
+			if seen[parent] {
+				return fmt.Errorf("cost center: %s is its own ancestor", item.Name)
+			}
+			seen[parent] = true
+		}
+	}
+	return nil
+}
+
+// StoreCostCenters replaces the cost centers imported from the source by the given ones, which must be valid
+func StoreCostCenters(items []CostCenterItem, source string) error {
+	existing, err := RetrieveCostCenters()
+	if err != nil {
+		return err
+	}
+	imported := map[string]bool{}
+	for _, item := range items {
+		imported[item.Name] = true
+		xid := "costcenter:" + item.Name
+		costCenter := CostCenter{
+			ID:           dgraph.ID{Xid: xid, UID: dgraph.GetUID(xid, IsCostCenter)},
+			IsCostCenter: true,
+			Name:         item.Name,
+			Parent:       item.Parent,
+			Namespaces:   strings.Join(item.Namespaces, ","),
+			Labels:       joinLabels(item.Labels),
+			Source:       source,
+		}
+		if _, err = dgraph.MutateNode(costCenter, dgraph.UPDATE); err != nil {
+			return err
+		}
+	}
+	for _, costCenter := range existing {
+		if costCenter.Source != source || imported[costCenter.Name] {
+			continue
+		}
+		if _, err = dgraph.MutateNode(dgraph.ID{UID: costCenter.UID}, dgraph.DELETE); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetrieveCostCenters returns the cost centers of every source
+func RetrieveCostCenters() ([]CostCenter, error) {
+	const q = `query {
+		costCenters(func: has(isCostCenter)) {
+			uid
+			xid
+			name
+			parentCostCenter
+			costCenterNamespaces
+			costCenterLabels
+			costCenterSource
+		}
+	}`
+
+	type root struct {
+		CostCenters []CostCenter `json:"costCenters"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	return newRoot.CostCenters, nil
+}
+
+func joinLabels(labels map[string]string) string {
+	selectors := make([]string, 0, len(labels))
+	for key, value := range labels {
+		selectors = append(selectors, key+"="+value)
+	}
+	sort.Strings(selectors)
+	return strings.Join(selectors, ",")
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Dgraph Model Constants
+const (
+	IsCostSnapshot = "isCostSnapshot"
+)
+
+// costSnapshotsPerMutation is the number of snapshots written by a mutation
+const costSnapshotsPerMutation = 500
+
+// CostSnapshot schema in dgraph, it is the allocation and cost of a pod in a period (an hour by default) as computed
+// at the end of the period. The pod is referred to by xid and its namespace, workload and labels are copied, so the
+// snapshots outlive the pods which are purged, and they are not recomputed when the prices change.
+type CostSnapshot struct {
+	dgraph.ID
+	IsCostSnapshot bool   `json:"isCostSnapshot,omitempty"`
+	StartTime      string `json:"startTime,omitempty"`
+	// Hours is the length of the period of the snapshot
+	Hours     float64 `json:"snapshotHours"`
+	Pod       string  `json:"snapshotPod,omitempty"`
+	Namespace string  `json:"snapshotNamespace,omitempty"`
+	// Workload is the owner of the pod as kind/namespace:name (ex: deployment/shop:cart), empty for bare pods
+	Workload       string          `json:"workload,omitempty"`
+	Labels         []*Label        `json:"label,omitempty"`
+	CPUHours       utils.CoreHours `json:"cpuHours"`
+	MemoryGBHours  utils.GBHours   `json:"memoryGBHours"`
+	StorageGBHours utils.GBHours   `json:"storageGBHours"`
+	GPUHours       float64         `json:"gpuHours"`
+	CPUCost        float64         `json:"cpuCost"`
+	MemoryCost     float64         `json:"memoryCost"`
+	StorageCost    float64         `json:"storageCost"`
+	GPUCost        float64         `json:"gpuCost"`
+	TotalCost      float64         `json:"totalCost"`
+	PriceVersion   string          `json:"priceVersion,omitempty"`
+}
+
+// StoreCostSnapshots creates the snapshots of a period, unless the period was snapshotted already (by a previous
+// leader for instance). It returns whether the snapshots were created.
+func StoreCostSnapshots(start time.Time, snapshots []CostSnapshot) (bool, error) {
+	isTaken, err := isPeriodSnapshotted(start)
+	if err != nil || isTaken {
+		return false, err
+	}
+	for i := 0; i < len(snapshots); i += costSnapshotsPerMutation {
+		end := i + costSnapshotsPerMutation
+		if end > len(snapshots) {
+			end = len(snapshots)
+		}
+		if _, err = dgraph.MutateNode(snapshots[i:end], dgraph.CREATE); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func isPeriodSnapshotted(start time.Time) (bool, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		snapshots(func: eq(startTime, ` + builder.Time(start) + `), first: 1) @filter(has(isCostSnapshot)) {
+			uid
+		}
+	}`
+
+	type root struct {
+		Snapshots []dgraph.ID `json:"snapshots"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return false, err
+	}
+	return len(newRoot.Snapshots) > 0, nil
+}
+
+// RetrieveLatestCostSnapshotTime returns the start of the latest period snapshotted, zero if there is none
+func RetrieveLatestCostSnapshotTime() (time.Time, error) {
+	const q = `query {
+		snapshots(func: has(isCostSnapshot), orderdesc: startTime, first: 1) {
+			startTime
+		}
+	}`
+
+	type root struct {
+		Snapshots []CostSnapshot `json:"snapshots"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return time.Time{}, err
+	}
+	if len(newRoot.Snapshots) == 0 {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, newRoot.Snapshots[0].StartTime)
+}
+
+// DeleteCostSnapshotsBefore deletes at most limit snapshots of the periods starting before the given time and
+// returns the number deleted
+func DeleteCostSnapshotsBefore(before time.Time, limit int) (int, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		snapshots(func: lt(startTime, ` + builder.Time(before) + `), first: ` + builder.Int(limit) + `) @filter(has(isCostSnapshot)) {
+			uid
+		}
+	}`
+
+	type root struct {
+		Snapshots []dgraph.ID `json:"snapshots"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return 0, err
+	}
+	if len(newRoot.Snapshots) == 0 {
+		return 0, nil
+	}
+	// only the uid is given so that all predicates of the snapshots are deleted
+	_, err := dgraph.MutateNode(newRoot.Snapshots, dgraph.DELETE)
+	return len(newRoot.Snapshots), err
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Dgraph Model Constants
+const (
+	IsCostSummary = "isCostSummary"
+)
+
+// Owners of cost summaries
+const (
+	NamespaceOwner = "namespace"
+	GroupOwner     = "group"
+)
+
+// CostSummary schema in dgraph. It is the precomputed cost of a namespace or a group for a day.
+type CostSummary struct {
+	dgraph.ID
+	IsCostSummary  bool            `json:"isCostSummary,omitempty"`
+	Name           string          `json:"name,omitempty"`
+	Date           string          `json:"date,omitempty"`
+	Namespace      *Namespace      `json:"namespace,omitempty"`
+	Group          *GroupCRD       `json:"group,omitempty"`
+	CPUHours       utils.CoreHours `json:"cpuHours"`
+	MemoryGBHours  utils.GBHours   `json:"memoryGBHours"`
+	StorageGBHours utils.GBHours   `json:"storageGBHours"`
+	CPUCost        float64         `json:"cpuCost"`
+	MemoryCost     float64         `json:"memoryCost"`
+	StorageCost    float64         `json:"storageCost"`
+	GPUHours       float64         `json:"gpuHours"`
+	GPUCost        float64         `json:"gpuCost"`
+	TotalCost      float64         `json:"totalCost"`
+	PriceVersion   string          `json:"priceVersion,omitempty"`
+	ComputedAt     string          `json:"computedAt,omitempty"`
+	Type           string          `json:"type,omitempty"`
+
+	// set on the summaries of namespaces, which are also charged their claims, snapshots and load balancers.
+	// Summaries computed before they were recorded do not have HasLineItems set.
+	ClaimGBHours      float64 `json:"claimGBHours"`
+	ClaimCost         float64 `json:"claimCost"`
+	SnapshotGBHours   float64 `json:"snapshotGBHours"`
+	SnapshotCost      float64 `json:"snapshotCost"`
+	LoadBalancerHours float64 `json:"loadBalancerHours"`
+	LoadBalancerCost  float64 `json:"loadBalancerCost"`
+	HasLineItems      bool    `json:"hasLineItems,omitempty"`
+}
+
+// GetCostSummaryXID returns the xid of the cost summary of the given owner for the given day.
+// ownerType is one of NamespaceOwner or GroupOwner.
+func GetCostSummaryXID(ownerType, owner string, day time.Time) string {
+	return "costsummary-" + ownerType + "-" + owner + "-" + day.Format("2006-01-02")
+}
+
+// StoreCostSummary creates the cost summary in the Dgraph or overwrites it if already present,
+// so recomputing the summary of a day is idempotent.
+func StoreCostSummary(summary CostSummary) (string, error) {
+	uid := dgraph.GetUID(summary.Xid, IsCostSummary)
+	summary.IsCostSummary = true
+	summary.Name = summary.Xid
+	summary.Type = "costsummary"
+	summary.ComputedAt = time.Now().Format(time.RFC3339)
+	if uid != "" {
+		summary.UID = uid
+	}
+
+	assigned, err := dgraph.MutateNode(summary, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	if uid == "" {
+		log.Debugf("Cost summary with xid: (%s) persisted", summary.Xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return uid, nil
+}
@@ -33,13 +33,18 @@ const (
 // Daemonset schema in dgraph
 type Daemonset struct {
 	dgraph.ID
-	IsDaemonset bool       `json:"isDaemonset,omitempty"`
-	Name        string     `json:"name,omitempty"`
-	StartTime   string     `json:"startTime,omitempty"`
-	EndTime     string     `json:"endTime,omitempty"`
-	Namespace   *Namespace `json:"namespace,omitempty"`
-	Pods        []*Pod     `json:"pod,omitempty"`
-	Type        string     `json:"type,omitempty"`
+	IsDaemonset bool         `json:"isDaemonset,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	StartTime   string       `json:"startTime,omitempty"`
+	EndTime     string       `json:"endTime,omitempty"`
+	Namespace   *Namespace   `json:"namespace,omitempty"`
+	Pods        []*Pod       `json:"pod,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Environment *Environment `json:"environment,omitempty"`
+
+	// ClusterOverhead is set when the daemonset is stored from the cluster, it is nil in references to the daemonset
+	ClusterOverhead *bool  `json:"clusterOverhead,omitempty"`
+	OverheadReason  string `json:"overheadReason,omitempty"`
 }
 
 func createDaemonsetObject(daemonset ext_v1beta1.DaemonSet) Daemonset {
@@ -50,10 +55,12 @@ func createDaemonsetObject(daemonset ext_v1beta1.DaemonSet) Daemonset {
 		ID:          dgraph.ID{Xid: daemonset.Namespace + ":" + daemonset.Name},
 		StartTime:   daemonset.GetCreationTimestamp().Time.Format(time.RFC3339),
 	}
+	newDaemonset.ClusterOverhead, newDaemonset.OverheadReason = overheadFields(classifyDaemonset(daemonset.Namespace, daemonset.Name))
 	namespaceUID := CreateOrGetNamespaceByID(daemonset.Namespace)
 	if namespaceUID != "" {
 		newDaemonset.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: daemonset.Namespace}}
 	}
+	newDaemonset.Environment = workloadEnvironment(daemonset.Namespace, namespaceUID, daemonset.Spec.Template.Labels)
 	daemonsetDeletionTimestamp := daemonset.GetDeletionTimestamp()
 	if !daemonsetDeletionTimestamp.IsZero() {
 		newDaemonset.EndTime = daemonsetDeletionTimestamp.Time.Format(time.RFC3339)
@@ -96,7 +103,7 @@ func CreateOrGetDaemonsetByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsDailySeal     = "isDailySeal"
+	IsSealAmendment = "isSealAmendment"
+)
+
+// DailySeal schema in dgraph. It finalizes the cost summaries of a day with their digest, chained to the seal of
+// the previous day and optionally signed, so that the summaries can be proven unmodified later.
+type DailySeal struct {
+	dgraph.ID
+	IsDailySeal         bool   `json:"isDailySeal,omitempty"`
+	Date                string `json:"date,omitempty"`
+	Summaries           int    `json:"sealedSummaries"`
+	Digest              string `json:"digest,omitempty"`
+	PreviousChainDigest string `json:"previousChainDigest,omitempty"`
+	ChainDigest         string `json:"chainDigest,omitempty"`
+	Signature           string `json:"signature,omitempty"`
+	KeyFingerprint      string `json:"keyFingerprint,omitempty"`
+	SealedAt            string `json:"sealedAt,omitempty"`
+	Type                string `json:"type,omitempty"`
+}
+
+// GetDailySealXID returns the xid of the seal of the given day
+func GetDailySealXID(day time.Time) string {
+	return "dailyseal-" + day.Format("2006-01-02")
+}
+
+// StoreDailySeal creates the seal in the Dgraph. A day is sealed once, an existing seal is never overwritten.
+func StoreDailySeal(seal DailySeal) (string, error) {
+	if uid := dgraph.GetUID(seal.Xid, IsDailySeal); uid != "" {
+		return uid, nil
+	}
+	seal.IsDailySeal = true
+	seal.Type = "dailyseal"
+	seal.SealedAt = time.Now().Format(time.RFC3339)
+
+	assigned, err := dgraph.MutateNode(seal, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("cost summaries of day: (%s) sealed, chain digest: %s", seal.Date, seal.ChainDigest)
+	return assigned.Uids["blank-0"], nil
+}
+
+// SealAmendment schema in dgraph. It records that the cost summaries of a sealed day were recomputed and why: the
+// digest of the new summaries is chained to the seal of the day, or to its previous amendment, with the reason.
+type SealAmendment struct {
+	dgraph.ID
+	IsSealAmendment     bool   `json:"isSealAmendment,omitempty"`
+	Date                string `json:"date,omitempty"`
+	Sequence            int    `json:"sequence"`
+	Reason              string `json:"reason,omitempty"`
+	Summaries           int    `json:"amendedSummaries"`
+	Digest              string `json:"digest,omitempty"`
+	PreviousDigest      string `json:"previousDigest,omitempty"`
+	PreviousChainDigest string `json:"previousChainDigest,omitempty"`
+	ChainDigest         string `json:"chainDigest,omitempty"`
+	Signature           string `json:"signature,omitempty"`
+	KeyFingerprint      string `json:"keyFingerprint,omitempty"`
+	AmendedAt           string `json:"amendedAt,omitempty"`
+	Type                string `json:"type,omitempty"`
+}
+
+// GetSealAmendmentXID returns the xid of the amendment with the given sequence (starting at 1) of the seal of the day
+func GetSealAmendmentXID(day time.Time, sequence int) string {
+	return fmt.Sprintf("sealamendment-%s-%d", day.Format("2006-01-02"), sequence)
+}
+
+// StoreSealAmendment creates the amendment in the Dgraph, an existing amendment is never overwritten
+func StoreSealAmendment(amendment SealAmendment) (string, error) {
+	if uid := dgraph.GetUID(amendment.Xid, IsSealAmendment); uid != "" {
+		return uid, nil
+	}
+	amendment.IsSealAmendment = true
+	amendment.Type = "sealamendment"
+	amendment.AmendedAt = time.Now().Format(time.RFC3339)
+
+	assigned, err := dgraph.MutateNode(amendment, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	log.Infof("seal of day: (%s) amended, reason: (%s), chain digest: %s", amendment.Date, amendment.Reason, amendment.ChainDigest)
+	return assigned.Uids["blank-0"], nil
+}
@@ -34,13 +34,14 @@ const (
 // Deployment schema in dgraph
 type Deployment struct {
 	dgraph.ID
-	IsDeployment bool       `json:"isDeployment,omitempty"`
-	Name         string     `json:"name,omitempty"`
-	StartTime    string     `json:"startTime,omitempty"`
-	EndTime      string     `json:"endTime,omitempty"`
-	Namespace    *Namespace `json:"namespace,omitempty"`
-	Pods         []*Pod     `json:"pod,omitempty"`
-	Type         string     `json:"type,omitempty"`
+	IsDeployment bool         `json:"isDeployment,omitempty"`
+	Name         string       `json:"name,omitempty"`
+	StartTime    string       `json:"startTime,omitempty"`
+	EndTime      string       `json:"endTime,omitempty"`
+	Namespace    *Namespace   `json:"namespace,omitempty"`
+	Pods         []*Pod       `json:"pod,omitempty"`
+	Type         string       `json:"type,omitempty"`
+	Environment  *Environment `json:"environment,omitempty"`
 }
 
 func createDeploymentObject(deployment apps_v1beta1.Deployment) Deployment {
@@ -55,6 +56,7 @@ func createDeploymentObject(deployment apps_v1beta1.Deployment) Deployment {
 	if namespaceUID != "" {
 		newDeployment.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: deployment.Namespace}}
 	}
+	newDeployment.Environment = workloadEnvironment(deployment.Namespace, namespaceUID, deployment.Spec.Template.Labels)
 	deploymentDeletionTimestamp := deployment.GetDeletionTimestamp()
 	if !deploymentDeletionTimestamp.IsZero() {
 		newDeployment.EndTime = deploymentDeletionTimestamp.Time.Format(time.RFC3339)
@@ -97,7 +99,7 @@ func CreateOrGetDeploymentByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import "github.com/vmware/purser/pkg/controller/dgraph"
+
+// AppendJSON appends the json encoding of the pod, pods are mutated on every pod event
+func (pod Pod) AppendJSON(dst []byte) ([]byte, error) {
+	o := dgraph.NewJSONObject(dst)
+	o.ID(pod.ID)
+	o.Bool("isPod", pod.IsPod, true)
+	o.String("name", pod.Name, true)
+	o.String("startTime", pod.StartTime, true)
+	o.String("endTime", pod.EndTime, true)
+	if len(pod.Containers) > 0 {
+		o.Key("containers")
+		o.Buf = append(o.Buf, '[')
+		for i, container := range pod.Containers {
+			if i > 0 {
+				o.Buf = append(o.Buf, ',')
+			}
+			o.Buf = appendPointer(&o, container == nil, container)
+		}
+		o.Buf = append(o.Buf, ']')
+	}
+	if len(pod.Pods) > 0 {
+		o.Key("pod")
+		o.Buf = append(o.Buf, '[')
+		for i, child := range pod.Pods {
+			if i > 0 {
+				o.Buf = append(o.Buf, ',')
+			}
+			o.Buf = appendPointer(&o, child == nil, child)
+		}
+		o.Buf = append(o.Buf, ']')
+	}
+	o.Float("pod|count", pod.Count, true)
+	if len(pod.Services) > 0 {
+		o.Value("externalService", pod.Services)
+	}
+	if pod.Node != nil {
+		o.Value("node", pod.Node)
+	}
+	if pod.Namespace != nil {
+		o.Value("namespace", pod.Namespace)
+	}
+	if pod.Deployment != nil {
+		o.Value("deployment", pod.Deployment)
+	}
+	if pod.Replicaset != nil {
+		o.Value("replicaset", pod.Replicaset)
+	}
+	if pod.Statefulset != nil {
+		o.Value("statefulset", pod.Statefulset)
+	}
+	if pod.Daemonset != nil {
+		o.Value("daemonset", pod.Daemonset)
+	}
+	if pod.Job != nil {
+		o.Value("job", pod.Job)
+	}
+	if len(pod.Pvcs) > 0 {
+		o.Value("pvc", pod.Pvcs)
+	}
+	o.Float("cpuRequest", float64(pod.CPURequest), true)
+	o.Float("cpuLimit", float64(pod.CPULimit), true)
+	o.Float("memoryRequest", float64(pod.MemoryRequest), true)
+	o.Float("memoryLimit", float64(pod.MemoryLimit), true)
+	o.Float("cpuOverhead", float64(pod.CPUOverhead), true)
+	o.Float("memoryOverhead", float64(pod.MemoryOverhead), true)
+	o.Float("gpuRequest", pod.GPURequest, true)
+	o.Float("gpuLimit", pod.GPULimit, true)
+	o.Float("storageRequest", float64(pod.StorageRequest), true)
+	o.String("type", pod.Type, true)
+	if len(pod.Cid) > 0 {
+		o.Value("cid", pod.Cid)
+	}
+	if len(pod.Labels) > 0 {
+		o.Value("label", pod.Labels)
+	}
+	if pod.Environment != nil {
+		o.Value("environment", pod.Environment)
+	}
+	if pod.Application != nil {
+		o.Value("application", pod.Application)
+	}
+	if pod.HelmRelease != nil {
+		o.Value("helmRelease", pod.HelmRelease)
+	}
+	o.String("helmChart", pod.HelmChart, true)
+	o.String("costCenter", pod.CostCenter, true)
+	if len(pod.Placements) > 0 {
+		o.Value("placement", pod.Placements)
+	}
+	o.Float("nodePremium", pod.NodePremium, true)
+	o.Bool("isSynthetic", pod.IsSynthetic, true)
+	o.Int("syntheticPodCount", pod.SyntheticPodCount, true)
+	o.Float("cpuHours", float64(pod.CPUHours), true)
+	o.Float("memoryGBHours", float64(pod.MemoryGBHours), true)
+	o.Float("gpuHours", pod.GPUHours, true)
+	return o.End()
+}
+
+// AppendJSON appends the json encoding of the container, containers are mutated with their pods
+func (container Container) AppendJSON(dst []byte) ([]byte, error) {
+	o := dgraph.NewJSONObject(dst)
+	o.ID(container.ID)
+	o.Bool("isContainer", container.IsContainer, true)
+	o.String("name", container.Name, true)
+	o.String("image", container.Image, true)
+	o.String("startTime", container.StartTime, true)
+	o.String("endTime", container.EndTime, true)
+	// omitempty has no effect on struct fields
+	o.Value("pod", container.Pod)
+	if len(container.Procs) > 0 {
+		o.Value("procs", container.Procs)
+	}
+	if container.Namespace != nil {
+		o.Value("namespace", container.Namespace)
+	}
+	o.Float("cpuRequest", float64(container.CPURequest), true)
+	o.Float("cpuLimit", float64(container.CPULimit), true)
+	o.Float("memoryRequest", float64(container.MemoryRequest), true)
+	o.Float("memoryLimit", float64(container.MemoryLimit), true)
+	o.Float("gpuRequest", container.GPURequest, true)
+	o.Float("gpuLimit", container.GPULimit, true)
+	o.String("type", container.Type, true)
+	o.Bool("ephemeral", container.Ephemeral, true)
+	o.String("targetContainer", container.TargetName, true)
+	return o.End()
+}
+
+// appendPointer appends an element of a slice of pointers, nil elements are encoded as null
+func appendPointer(o *dgraph.JSONObject, isNil bool, value dgraph.JSONAppender) []byte {
+	if isNil {
+		return append(o.Buf, "null"...)
+	}
+	return o.AppendValue(o.Buf, value)
+}
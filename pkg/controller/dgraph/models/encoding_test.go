@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newTestPod() Pod {
+	return Pod{
+		ID:        dgraph.ID{Xid: "default:web-0", UID: "0x2a"},
+		IsPod:     true,
+		Name:      "pod-web-0",
+		StartTime: "2019-01-02T10:00:00Z",
+		Containers: []*Container{
+			{
+				ID:          dgraph.ID{Xid: "default:web-0:nginx"},
+				IsContainer: true,
+				Name:        "container-nginx",
+				Image:       "nginx:1.15 <latest> & \"quoted\"",
+				Pod:         Pod{ID: dgraph.ID{UID: "0x2a"}},
+				CPURequest:  0.25,
+				MemoryLimit: 1e-7,
+				GPURequest:  0.5,
+				GPULimit:    0.5,
+			},
+			nil,
+		},
+		Node:          &Node{ID: dgraph.ID{UID: "0x10"}},
+		Namespace:     &Namespace{ID: dgraph.ID{UID: "0x11"}},
+		Pvcs:          []*PersistentVolumeClaim{{ID: dgraph.ID{UID: "0x12"}}},
+		CPURequest:    0.25,
+		MemoryRequest: 1.5e21,
+		GPURequest:    0.5,
+		GPULimit:      0.5,
+		Type:          "pod",
+		Labels:        []*Label{{ID: dgraph.ID{UID: "0x13"}}},
+		Application:   &Application{ID: dgraph.ID{UID: "0x14"}},
+		CostCenter:    "ml-platform",
+		NodePremium:   0.125,
+	}
+}
+
+// TestAppendJSON ...
+func TestAppendJSON(t *testing.T) {
+	pod := newTestPod()
+	for _, model := range []interface{}{pod, *pod.Containers[0], Pod{}, Container{}} {
+		expected, err := json.Marshal(model)
+		utils.Ok(t, err)
+		actual, err := model.(dgraph.JSONAppender).AppendJSON(nil)
+		utils.Ok(t, err)
+		utils.Equals(t, string(expected), string(actual))
+	}
+}
+
+// newBenchmarkPod returns a pod as stored on pod events, with one container and references to its owners
+func newBenchmarkPod() Pod {
+	return Pod{
+		ID:        dgraph.ID{Xid: "default:web-0"},
+		IsPod:     true,
+		Name:      "pod-web-0",
+		StartTime: "2019-01-02T10:00:00Z",
+		Containers: []*Container{
+			{ID: dgraph.ID{UID: "0x2b"}},
+		},
+		Node:          &Node{ID: dgraph.ID{UID: "0x10"}},
+		Namespace:     &Namespace{ID: dgraph.ID{UID: "0x11"}},
+		CPURequest:    0.25,
+		CPULimit:      0.5,
+		MemoryRequest: 0.5,
+		MemoryLimit:   1,
+		Type:          "pod",
+	}
+}
+
+// BenchmarkPodMarshal ...
+func BenchmarkPodMarshal(b *testing.B) {
+	pod := newBenchmarkPod()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(pod); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkPodAppendJSON ...
+func BenchmarkPodAppendJSON(b *testing.B) {
+	pod := newBenchmarkPod()
+	buf := make([]byte, 0, 1024)
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = pod.AppendJSON(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"path"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsEnvironment = "isEnvironment"
+)
+
+// UnclassifiedEnvironment is the environment of workloads which do not match any environment rule.
+const UnclassifiedEnvironment = "unclassified"
+
+// Environment schema in dgraph
+type Environment struct {
+	dgraph.ID
+	IsEnvironment bool   `json:"isEnvironment,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Type          string `json:"type,omitempty"`
+}
+
+// EnvironmentRule maps namespaces and labels to an environment (ex: prod, staging, dev).
+// Namespaces can be shell patterns (ex: payments-*). A workload belongs to the environment
+// if its namespace matches any of the Namespaces or its labels contain all of the Labels.
+type EnvironmentRule struct {
+	Name       string            `json:"name"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+var environmentRules []EnvironmentRule
+
+// SetEnvironmentRules sets the rules used to classify workloads into environments.
+// Rules are evaluated in order and the first matching rule wins.
+func SetEnvironmentRules(rules []EnvironmentRule) {
+	environmentRules = rules
+}
+
+// classifyEnvironment returns the environment of the workload with given namespace and labels.
+func classifyEnvironment(namespace string, labels map[string]string) string {
+	for _, rule := range environmentRules {
+		if rule.matches(namespace, labels) {
+			return rule.Name
+		}
+	}
+	return UnclassifiedEnvironment
+}
+
+func (rule EnvironmentRule) matches(namespace string, labels map[string]string) bool {
+	for _, pattern := range rule.Namespaces {
+		if isMatch, err := path.Match(pattern, namespace); err == nil && isMatch {
+			return true
+		}
+	}
+	if len(rule.Labels) == 0 {
+		return false
+	}
+	for key, value := range rule.Labels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateOrGetEnvironmentByID returns the uid of environment if exists,
+// otherwise creates the environment and returns uid.
+func CreateOrGetEnvironmentByID(xid string) string {
+	uid := dgraph.GetUID(xid, IsEnvironment)
+	if uid != "" {
+		return uid
+	}
+
+	env := Environment{
+		ID:            dgraph.ID{Xid: xid},
+		Name:          "environment-" + xid,
+		IsEnvironment: true,
+		Type:          "environment",
+	}
+	assigned, err := dgraph.MutateNode(env, dgraph.CREATE)
+	if err != nil {
+		log.Error(err)
+		return ""
+	}
+	log.Infof("Environment with xid: (%s) persisted", xid)
+	return assigned.Uids["blank-0"]
+}
+
+func getEnvironment(namespace string, labels map[string]string) *Environment {
+	xid := classifyEnvironment(namespace, labels)
+	uid := CreateOrGetEnvironmentByID(xid)
+	if uid == "" {
+		return nil
+	}
+	return &Environment{ID: dgraph.ID{UID: uid, Xid: xid}}
+}
+
+// workloadEnvironment returns the environment of the controller whose pods are created with the template labels. The
+// labels its pods inherit from the namespace are added so that the controller is classified like its pods.
+func workloadEnvironment(namespace, namespaceUID string, templateLabels map[string]string) *Environment {
+	return getEnvironment(namespace, mergeInheritedLabels(templateLabels, getInheritedLabels(namespaceUID)))
+}
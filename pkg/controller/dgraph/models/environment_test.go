@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestClassifyEnvironment ...
+func TestClassifyEnvironment(t *testing.T) {
+	SetEnvironmentRules([]EnvironmentRule{
+		{Name: "prod", Namespaces: []string{"prod", "payments-*"}},
+		{Name: "staging", Labels: map[string]string{"env": "staging"}},
+	})
+	defer SetEnvironmentRules(nil)
+
+	utils.Equals(t, "prod", classifyEnvironment("prod", nil))
+	utils.Equals(t, "prod", classifyEnvironment("payments-api", map[string]string{"env": "staging"}))
+	utils.Equals(t, "staging", classifyEnvironment("default", map[string]string{"env": "staging", "app": "web"}))
+	utils.Equals(t, UnclassifiedEnvironment, classifyEnvironment("default", map[string]string{"app": "web"}))
+}
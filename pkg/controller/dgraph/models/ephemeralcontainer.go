@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// EphemeralContainer is an ephemeral container of the pod spec
+type EphemeralContainer struct {
+	Name                string `json:"name"`
+	Image               string `json:"image"`
+	TargetContainerName string `json:"targetContainerName,omitempty"`
+}
+
+// StoreEphemeralContainers stores the ephemeral containers of the pod which have started, with the time they
+// started and the time they terminated if they are not running anymore.
+func StoreEphemeralContainers(pod RawPod) error {
+	if len(pod.Spec.EphemeralContainers) == 0 {
+		return nil
+	}
+	podXid := pod.Metadata.Namespace + ":" + pod.Metadata.Name
+	podUID := dgraph.GetUID(podXid, IsPod)
+	if podUID == "" {
+		return fmt.Errorf("pod: %s is not persisted yet", podXid)
+	}
+
+	states := map[string]api_v1.ContainerState{}
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		states[status.Name] = status.State
+	}
+	namespaceUID := CreateOrGetNamespaceByID(pod.Metadata.Namespace)
+	containers := []*Container{}
+	for _, ephemeral := range pod.Spec.EphemeralContainers {
+		startTime, endTime := getEphemeralContainerLifetime(states[ephemeral.Name])
+		if startTime.IsZero() {
+			continue
+		}
+		container, err := storeEphemeralContainer(ephemeral, pod.Metadata.Namespace, podXid, podUID, namespaceUID, startTime, endTime)
+		if err != nil {
+			return err
+		}
+		containers = append(containers, container)
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+
+	p := Pod{
+		ID:         dgraph.ID{UID: podUID, Xid: podXid},
+		Containers: containers,
+	}
+	_, err := dgraph.MutateNode(p, dgraph.UPDATE)
+	return err
+}
+
+func storeEphemeralContainer(ephemeral EphemeralContainer, namespace, podXid, podUID, namespaceUID string, startTime, endTime time.Time) (*Container, error) {
+	xid := podXid + ":" + ephemeral.Name
+	uid := dgraph.GetUID(xid, IsContainer)
+	c := newEphemeralContainer(ephemeral, namespace, podXid, podUID, namespaceUID, startTime, endTime)
+	c.UID = uid
+	assigned, err := dgraph.MutateNode(c, dgraph.CREATE)
+	if err != nil {
+		return nil, err
+	}
+	if uid == "" {
+		log.Infof("Ephemeral container with xid: (%s) persisted in dgraph", xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return &Container{ID: dgraph.ID{UID: uid, Xid: xid}}, nil
+}
+
+// newEphemeralContainer returns the container of a debugging session. It lives from the start to the end of the
+// session, not the lifetime of its pod, and it has no requests since ephemeral containers have no resources.
+func newEphemeralContainer(ephemeral EphemeralContainer, namespace, podXid, podUID, namespaceUID string, startTime, endTime time.Time) Container {
+	c := Container{
+		ID:          dgraph.ID{Xid: podXid + ":" + ephemeral.Name},
+		Name:        "container-" + ephemeral.Name,
+		Image:       ephemeral.Image,
+		IsContainer: true,
+		Ephemeral:   true,
+		TargetName:  ephemeral.TargetContainerName,
+		Type:        "container",
+		StartTime:   startTime.Format(time.RFC3339),
+		Pod:         Pod{ID: dgraph.ID{UID: podUID, Xid: podXid}},
+	}
+	if !endTime.IsZero() {
+		c.EndTime = endTime.Format(time.RFC3339)
+	}
+	if namespaceUID != "" {
+		c.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: namespace}}
+	}
+	return c
+}
+
+// getEphemeralContainerLifetime returns the start and end of the debugging session. Start is zero if the
+// container has not started yet and end is zero while it is running.
+func getEphemeralContainerLifetime(state api_v1.ContainerState) (time.Time, time.Time) {
+	if state.Running != nil {
+		return state.Running.StartedAt.Time, time.Time{}
+	}
+	if state.Terminated != nil {
+		return state.Terminated.StartedAt.Time, state.Terminated.FinishedAt.Time
+	}
+	return time.Time{}, time.Time{}
+}
+
+// closeEphemeralContainers sets the end time of the ephemeral containers of the pod which are still running
+func closeEphemeralContainers(podUID string, endTime time.Time) {
+	q := `query {
+		pod(func: uid(` + podUID + `)) {
+			containers @filter(has(ephemeral) AND NOT has(endTime)) {
+				uid
+			}
+		}
+	}`
+	type root struct {
+		Pod []Pod `json:"pod"`
+	}
+	newRoot := root{}
+	err := dgraph.ExecuteQuery(q, &newRoot)
+	if err != nil || len(newRoot.Pod) == 0 || len(newRoot.Pod[0].Containers) == 0 {
+		return
+	}
+	containers := newRoot.Pod[0].Containers
+	for _, container := range containers {
+		container.EndTime = endTime.Format(time.RFC3339)
+	}
+	if _, err = dgraph.MutateNode(containers, dgraph.UPDATE); err != nil {
+		log.Errorf("unable to close ephemeral containers of pod uid: (%s), error: (%v)", podUID, err)
+	}
+}
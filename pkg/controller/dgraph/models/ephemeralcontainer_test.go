@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestNewEphemeralContainer ...
+func TestNewEphemeralContainer(t *testing.T) {
+	// the pod runs for days, the debugging session for 30 minutes
+	podStart := time.Date(2018, 10, 28, 8, 0, 0, 0, time.UTC)
+	started := time.Date(2018, 11, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(30 * time.Minute)
+	debugger := EphemeralContainer{Name: "debugger", Image: "busybox", TargetContainerName: "web"}
+
+	container := newEphemeralContainer(debugger, "shop", "shop:web-1", "0x2a", "0x11", started, finished)
+	utils.Equals(t, "shop:web-1:debugger", container.Xid)
+	utils.Assert(t, container.Ephemeral && container.IsContainer, "debugging session is not an ephemeral container")
+	utils.Equals(t, "web", container.TargetName)
+	utils.Equals(t, "2018-11-01T10:00:00Z", container.StartTime)
+	utils.Equals(t, "2018-11-01T10:30:00Z", container.EndTime)
+	utils.Assert(t, container.StartTime != podStart.Format(time.RFC3339), "session starts with its pod")
+	utils.Equals(t, "0x2a", container.Pod.UID)
+	utils.Equals(t, "0x11", container.Namespace.UID)
+
+	// the cost of a container is its requests over its lifetime: a session has none so it is not billed
+	utils.Equals(t, 0.0, float64(container.CPURequest))
+	utils.Equals(t, 0.0, float64(container.CPULimit))
+	utils.Equals(t, 0.0, float64(container.MemoryRequest))
+	utils.Equals(t, 0.0, float64(container.MemoryLimit))
+	utils.Equals(t, 0.0, container.GPURequest)
+
+	running := newEphemeralContainer(debugger, "shop", "shop:web-1", "0x2a", "", started, time.Time{})
+	utils.Equals(t, "", running.EndTime)
+	utils.Assert(t, running.Namespace == nil, "namespace set without uid")
+}
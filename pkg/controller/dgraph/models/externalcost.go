@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsExternalCost = "isExternalCost"
+)
+
+// ExternalCost schema in dgraph. It represents spend outside of the kubernetes cluster
+// (managed databases, SaaS tools etc.) which is attributed to a namespace or a group.
+type ExternalCost struct {
+	dgraph.ID
+	IsExternalCost bool       `json:"isExternalCost,omitempty"`
+	Name           string     `json:"name,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	StartTime      string     `json:"startTime,omitempty"`
+	EndTime        string     `json:"endTime,omitempty"`
+	MonthlyCost    float64    `json:"monthlyCost,omitempty"`
+	Cost           float64    `json:"cost,omitempty"`
+	Namespace      *Namespace `json:"namespace,omitempty"`
+	Group          *GroupCRD  `json:"group,omitempty"`
+	Type           string     `json:"type,omitempty"`
+}
+
+// ExternalCostItem is the user input for registering an external cost.
+// StartTime and EndTime are in RFC3339 format, StartTime defaults to the registration time.
+type ExternalCostItem struct {
+	Name        string  `json:"name"`
+	Category    string  `json:"category,omitempty"`
+	Namespace   string  `json:"namespace,omitempty"`
+	Group       string  `json:"group,omitempty"`
+	MonthlyCost float64 `json:"monthlyCost"`
+	StartTime   string  `json:"startTime,omitempty"`
+	EndTime     string  `json:"endTime,omitempty"`
+}
+
+func createExternalCostObject(item ExternalCostItem) (ExternalCost, error) {
+	newExternalCost := ExternalCost{
+		Name:           "externalcost-" + item.Name,
+		IsExternalCost: true,
+		Type:           "externalcost",
+		ID:             dgraph.ID{Xid: item.Name},
+		Category:       item.Category,
+		MonthlyCost:    item.MonthlyCost,
+		StartTime:      item.StartTime,
+		EndTime:        item.EndTime,
+	}
+	if newExternalCost.StartTime == "" {
+		newExternalCost.StartTime = time.Now().Format(time.RFC3339)
+	}
+
+	if item.Namespace != "" {
+		namespaceUID := CreateOrGetNamespaceByID(item.Namespace)
+		if namespaceUID != "" {
+			newExternalCost.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: item.Namespace}}
+		}
+	}
+	if item.Group != "" {
+		groupUID := dgraph.GetUID(item.Group, IsPurserGroup)
+		if groupUID == "" {
+			return newExternalCost, fmt.Errorf("group: %s is not persisted in dgraph", item.Group)
+		}
+		newExternalCost.Group = &GroupCRD{ID: dgraph.ID{UID: groupUID, Xid: item.Group}}
+	}
+	return newExternalCost, nil
+}
+
+// validateExternalCostItem checks that the external cost has a name, is attributed to a namespace or a group and
+// has a monthly cost which is not negative, active from its start time (if given) until its end time (if given).
+func validateExternalCostItem(item ExternalCostItem) error {
+	if item.Name == "" {
+		return fmt.Errorf("external cost name is empty")
+	}
+	if item.Namespace == "" && item.Group == "" {
+		return fmt.Errorf("external cost: %s is not attributed to any namespace or group", item.Name)
+	}
+	if item.MonthlyCost < 0 {
+		return fmt.Errorf("monthly cost of external cost: %s is negative", item.Name)
+	}
+	var start, end time.Time
+	var err error
+	if item.StartTime != "" {
+		if start, err = time.Parse(time.RFC3339, item.StartTime); err != nil {
+			return fmt.Errorf("start time of external cost: %s is not in RFC3339 format", item.Name)
+		}
+	}
+	if item.EndTime != "" {
+		if end, err = time.Parse(time.RFC3339, item.EndTime); err != nil {
+			return fmt.Errorf("end time of external cost: %s is not in RFC3339 format", item.Name)
+		}
+		if item.StartTime != "" && !end.After(start) {
+			return fmt.Errorf("end time of external cost: %s is not after its start time", item.Name)
+		}
+	}
+	return nil
+}
+
+// StoreExternalCost creates a new external cost in the Dgraph and updates if already present.
+func StoreExternalCost(item ExternalCostItem) (string, error) {
+	if err := validateExternalCostItem(item); err != nil {
+		return "", err
+	}
+
+	xid := item.Name
+	uid := dgraph.GetUID(xid, IsExternalCost)
+
+	newExternalCost, err := createExternalCostObject(item)
+	if err != nil {
+		return "", err
+	}
+	if uid != "" {
+		newExternalCost.UID = uid
+	}
+	assigned, err := dgraph.MutateNode(newExternalCost, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+
+	if uid == "" {
+		log.Infof("External cost with xid: (%s) persisted", xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return uid, nil
+}
+
+// DeleteExternalCost removes the external cost with given name from the Dgraph.
+func DeleteExternalCost(name string) error {
+	uid := dgraph.GetUID(name, IsExternalCost)
+	if uid == "" {
+		return fmt.Errorf("external cost: %s is not persisted in dgraph", name)
+	}
+	_, err := dgraph.MutateNode(dgraph.ID{UID: uid}, dgraph.DELETE)
+	return err
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestValidateExternalCostItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    ExternalCostItem
+		isValid bool
+	}{
+		{"namespace cost", ExternalCostItem{Name: "shop-db", Namespace: "shop", MonthlyCost: 200}, true},
+		{"group cost with window", ExternalCostItem{Name: "ci-tool", Group: "team-a", MonthlyCost: 50,
+			StartTime: "2018-11-01T00:00:00Z", EndTime: "2018-12-01T00:00:00Z"}, true},
+		{"free cost ending", ExternalCostItem{Name: "trial", Group: "team-a", EndTime: "2018-12-01T00:00:00Z"}, true},
+		{"no name", ExternalCostItem{Namespace: "shop", MonthlyCost: 200}, false},
+		{"not attributed", ExternalCostItem{Name: "shop-db", MonthlyCost: 200}, false},
+		{"negative cost", ExternalCostItem{Name: "refund", Namespace: "shop", MonthlyCost: -10}, false},
+		{"invalid start time", ExternalCostItem{Name: "shop-db", Namespace: "shop", StartTime: "2018-11-01"}, false},
+		{"invalid end time", ExternalCostItem{Name: "shop-db", Namespace: "shop", EndTime: "tomorrow"}, false},
+		{"end before start", ExternalCostItem{Name: "shop-db", Namespace: "shop", StartTime: "2018-11-01T00:00:00Z",
+			EndTime: "2018-10-01T00:00:00Z"}, false},
+	}
+	for _, test := range tests {
+		err := validateExternalCostItem(test.item)
+		utils.Assert(t, (err == nil) == test.isValid, "%s: unexpected validation error: %v", test.name, err)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GPU resources and annotations
+const (
+	gpuResource       = "nvidia.com/gpu"
+	sharedGPUResource = "nvidia.com/gpu.shared"
+	migResourcePrefix = "nvidia.com/mig-"
+
+	// migComputeSlices is the number of compute slices of a MIG capable gpu (A100, H100)
+	migComputeSlices = 7
+
+	// GPUFractionAnnotation is the pod annotation giving the fraction of a gpu which is one time-sliced gpu
+	// replica (ex: 0.25 for 4 replicas per gpu). It is suffixed with "." and the container name to override it
+	// for a container.
+	GPUFractionAnnotation = "purser.vmware.com/gpu-fraction"
+)
+
+// getContainerGPUs returns the number of gpus, possibly fractional, allocated to the container. A MIG profile
+// (ex: nvidia.com/mig-3g.20gb) counts as its share of the compute slices of the gpu and a time-sliced gpu
+// replica counts as the fraction annotated on the pod (a whole gpu if not annotated).
+func getContainerGPUs(container api_v1.Container, annotations map[string]string) float64 {
+	resources := container.Resources.Limits
+	if len(resources) == 0 {
+		resources = container.Resources.Requests
+	}
+	return countGPUs(resources, true, func() float64 { return getGPUFraction(container.Name, annotations) })
+}
+
+// getContainerGPULimit returns the gpus of the limits of the container, counted like getContainerGPUs
+func getContainerGPULimit(container api_v1.Container, annotations map[string]string) float64 {
+	return countGPUs(container.Resources.Limits, true, func() float64 { return getGPUFraction(container.Name, annotations) })
+}
+
+// getNodeGPUCapacity returns the gpus of the node, its MIG devices count as their share of a gpu. Time-sliced
+// replicas advertised as nvidia.com/gpu.shared are not counted, they are slices of the gpus of the node.
+func getNodeGPUCapacity(node api_v1.Node) float64 {
+	return countGPUs(node.Status.Capacity, false, func() float64 { return 1 })
+}
+
+// countGPUs returns the gpus of the resources, a gpu (or shared gpu replica if withShared) counts as the fraction
+// of a gpu given by fraction
+func countGPUs(resources api_v1.ResourceList, withShared bool, fraction func() float64) float64 {
+	gpus := 0.0
+	for name, quantity := range resources {
+		resourceName := string(name)
+		switch {
+		case resourceName == gpuResource || (withShared && resourceName == sharedGPUResource):
+			gpus += quantityToFloat(quantity) * fraction()
+		case strings.HasPrefix(resourceName, migResourcePrefix):
+			gpus += quantityToFloat(quantity) * getMIGFraction(resourceName)
+		}
+	}
+	return gpus
+}
+
+// getGPUFraction returns the fraction of a gpu annotated for the container, 1 if none is annotated
+func getGPUFraction(containerName string, annotations map[string]string) float64 {
+	value, isPresent := annotations[GPUFractionAnnotation+"."+containerName]
+	if !isPresent {
+		value, isPresent = annotations[GPUFractionAnnotation]
+	}
+	if !isPresent {
+		return 1
+	}
+	fraction, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || fraction <= 0 || fraction > 1 {
+		log.Errorf("invalid gpu fraction: (%s) for container: (%s), using a whole gpu", value, containerName)
+		return 1
+	}
+	return fraction
+}
+
+// getMIGFraction returns the fraction of the gpu used by a MIG profile. For nvidia.com/mig-<g>g.<memory>gb
+// it is g out of the compute slices of the gpu.
+func getMIGFraction(resourceName string) float64 {
+	profile := strings.TrimPrefix(resourceName, migResourcePrefix)
+	end := strings.Index(profile, "g.")
+	if end <= 0 {
+		return 1
+	}
+	slices, err := strconv.Atoi(profile[:end])
+	if err != nil || slices <= 0 || slices > migComputeSlices {
+		return 1
+	}
+	return float64(slices) / migComputeSlices
+}
+
+func quantityToFloat(quantity resource.Quantity) float64 {
+	return float64(quantity.MilliValue()) / 1000
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestGetMIGFraction(t *testing.T) {
+	utils.Equals(t, 1.0/7, getMIGFraction("nvidia.com/mig-1g.5gb"))
+	utils.Equals(t, 3.0/7, getMIGFraction("nvidia.com/mig-3g.20gb"))
+	utils.Equals(t, 1.0, getMIGFraction("nvidia.com/mig-7g.80gb"))
+	utils.Equals(t, 1.0, getMIGFraction("nvidia.com/mig-unknown"))
+}
+
+func TestGetGPUFraction(t *testing.T) {
+	annotations := map[string]string{
+		GPUFractionAnnotation:              "0.25",
+		GPUFractionAnnotation + ".trainer": "0.5",
+		GPUFractionAnnotation + ".broken":  "2",
+	}
+	utils.Equals(t, 0.25, getGPUFraction("web", annotations))
+	utils.Equals(t, 0.5, getGPUFraction("trainer", annotations))
+	utils.Equals(t, 1.0, getGPUFraction("broken", annotations))
+	utils.Equals(t, 1.0, getGPUFraction("web", nil))
+}
+
+func TestGetContainerGPUs(t *testing.T) {
+	container := api_v1.Container{
+		Name: "trainer",
+		Resources: api_v1.ResourceRequirements{
+			Requests: api_v1.ResourceList{gpuResource: resource.MustParse("2")},
+		},
+	}
+	annotations := map[string]string{GPUFractionAnnotation: "0.5"}
+	utils.Equals(t, 1.0, getContainerGPUs(container, annotations))
+	utils.Equals(t, 0.0, getContainerGPULimit(container, annotations))
+
+	container.Resources.Limits = api_v1.ResourceList{gpuResource: resource.MustParse("2"), "nvidia.com/mig-1g.5gb": resource.MustParse("7")}
+	utils.Equals(t, 2.0, getContainerGPULimit(container, annotations))
+}
+
+func TestGetNodeGPUCapacity(t *testing.T) {
+	node := api_v1.Node{}
+	node.Status.Capacity = api_v1.ResourceList{
+		gpuResource:              resource.MustParse("4"),
+		sharedGPUResource:        resource.MustParse("16"),
+		"nvidia.com/mig-3g.20gb": resource.MustParse("2"),
+	}
+	utils.Equals(t, 4+6.0/7, getNodeGPUCapacity(node))
+}
@@ -32,11 +32,21 @@ const (
 // GroupCRD schema in dgraph
 type GroupCRD struct {
 	dgraph.ID
-	IsPurserGroup bool   `json:"isPurserGroup,omitempty"`
-	Name          string `json:"name,omitempty"`
-	StartTime     string `json:"startTime,omitempty"`
-	EndTime       string `json:"endTime,omitempty"`
-	Type          string `json:"type,omitempty"`
+	IsPurserGroup bool     `json:"isPurserGroup,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	StartTime     string   `json:"startTime,omitempty"`
+	EndTime       string   `json:"endTime,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	Labels        []*Label `json:"label,omitempty"`
+}
+
+// GetLabelsMap returns the labels of the group as a key value map
+func (group GroupCRD) GetLabelsMap() map[string]string {
+	labelsMap := map[string]string{}
+	for _, label := range group.Labels {
+		labelsMap[label.Key] = label.Value
+	}
+	return labelsMap
 }
 
 func createGroupCRDObject(group groups_v1.Group) GroupCRD {
@@ -52,6 +62,7 @@ func createGroupCRDObject(group groups_v1.Group) GroupCRD {
 	if !deletionTimestamp.IsZero() {
 		newGroup.EndTime = deletionTimestamp.Time.Format(time.RFC3339)
 	}
+	newGroup.Labels = GetLabels(group.Spec.Labels)
 	return newGroup
 }
 
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// heatmapSamplesPerCell is the number of samples after which a heatmap cell becomes a moving average of the latest
+// ones. With a sample every 15 minutes a cell gets 4 samples a week, so it reflects about the last 4 weeks.
+const heatmapSamplesPerCell = 16
+
+// UsageHeatmap is the average cpu usage (in cores) of a workload by day of week (time.Weekday, Sunday first) and hour
+// of day in the time zone of the controller
+type UsageHeatmap struct {
+	CPU     [7][24]float64 `json:"cpu"`
+	Samples [7][24]int     `json:"samples"`
+}
+
+// DecodeUsageHeatmap decodes a heatmap stored in Dgraph, an empty heatmap is returned for an empty value
+func DecodeUsageHeatmap(value string) (UsageHeatmap, error) {
+	heatmap := UsageHeatmap{}
+	if value == "" {
+		return heatmap, nil
+	}
+	err := json.Unmarshal([]byte(value), &heatmap)
+	return heatmap, err
+}
+
+// add records the cpu usage sampled at the given time in the cell of its day of week and hour
+func (h *UsageHeatmap) add(cpu float64, at time.Time) {
+	at = at.Local()
+	day, hour := int(at.Weekday()), at.Hour()
+	if h.Samples[day][hour] < heatmapSamplesPerCell {
+		h.Samples[day][hour]++
+	}
+	h.CPU[day][hour] += (cpu - h.CPU[day][hour]) / float64(h.Samples[day][hour])
+}
+
+// IsComplete tells whether every hour of the week has been sampled
+func (h UsageHeatmap) IsComplete() bool {
+	for day := range h.Samples {
+		for hour := range h.Samples[day] {
+			if h.Samples[day][hour] == 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (h UsageHeatmap) encode() (string, error) {
+	data, err := json.Marshal(h)
+	return string(data), err
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestUsageHeatmapAdd(t *testing.T) {
+	heatmap := UsageHeatmap{}
+	monday := time.Date(2018, 11, 5, 10, 0, 0, 0, time.Local)
+	heatmap.add(1, monday)
+	heatmap.add(3, monday.Add(15*time.Minute))
+	utils.Equals(t, 2.0, heatmap.CPU[time.Monday][10])
+	utils.Equals(t, 2, heatmap.Samples[time.Monday][10])
+
+	// a full cell becomes a moving average of the latest samples
+	for i := 0; i < 2*heatmapSamplesPerCell; i++ {
+		heatmap.add(0, monday.AddDate(0, 0, 7*i))
+	}
+	utils.Equals(t, heatmapSamplesPerCell, heatmap.Samples[time.Monday][10])
+	utils.Assert(t, heatmap.CPU[time.Monday][10] < 0.2, "old samples fade out, got: %v", heatmap.CPU[time.Monday][10])
+	utils.Assert(t, !heatmap.IsComplete(), "heatmap with unsampled hours is not complete")
+}
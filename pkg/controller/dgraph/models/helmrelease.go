@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsHelmRelease = "isHelmRelease"
+)
+
+// Helm labels and annotations. Helm 3 annotates the objects of a release with meta.helm.sh, charts following
+// the helm conventions label them with helm.sh/chart and app.kubernetes.io/managed-by. Helm 2 charts used
+// the release and chart labels.
+const (
+	helmReleaseNameAnnotation = "meta.helm.sh/release-name"
+	helmChartLabel            = "helm.sh/chart"
+	helmManagedByValue        = "Helm"
+	appManagedByLabel         = "app.kubernetes.io/managed-by"
+	legacyReleaseLabel        = "release"
+	legacyChartLabel          = "chart"
+	legacyHeritageLabel       = "heritage"
+)
+
+// HelmRelease schema in dgraph. Pods of the release link to it and keep the chart (name-version) they were
+// deployed with, so upgrades of the release show up as successive charts of its pods.
+type HelmRelease struct {
+	dgraph.ID
+	IsHelmRelease    bool   `json:"isHelmRelease,omitempty"`
+	Name             string `json:"name,omitempty"`
+	ReleaseName      string `json:"releaseName,omitempty"`
+	ReleaseNamespace string `json:"releaseNamespace,omitempty"`
+	Type             string `json:"type,omitempty"`
+}
+
+// getHelmRelease returns the helm release of the pod with the given labels and annotations, creating it if needed,
+// along with the chart of the pod. It returns nil if the pod is not managed by helm.
+func getHelmRelease(namespace string, labels, annotations map[string]string) (*HelmRelease, string) {
+	release, chart := detectHelmRelease(labels, annotations)
+	if release == "" {
+		return nil, ""
+	}
+
+	xid := namespace + ":" + release
+	uid := dgraph.GetUID(xid, IsHelmRelease)
+	if uid == "" {
+		helmRelease := HelmRelease{
+			ID:               dgraph.ID{Xid: xid},
+			Name:             "helmrelease-" + xid,
+			IsHelmRelease:    true,
+			ReleaseName:      release,
+			ReleaseNamespace: namespace,
+			Type:             "helmrelease",
+		}
+		assigned, err := dgraph.MutateNode(helmRelease, dgraph.CREATE)
+		if err != nil {
+			log.Error(err)
+			return nil, ""
+		}
+		log.Infof("Helm release with xid: (%s) persisted", xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return &HelmRelease{ID: dgraph.ID{UID: uid, Xid: xid}}, chart
+}
+
+// detectHelmRelease returns the release name and the chart from the helm annotations or labels
+func detectHelmRelease(labels, annotations map[string]string) (string, string) {
+	chart := labels[helmChartLabel]
+	if release := annotations[helmReleaseNameAnnotation]; release != "" {
+		return release, chart
+	}
+	if labels[appManagedByLabel] == helmManagedByValue && labels[AppInstanceLabel] != "" {
+		return labels[AppInstanceLabel], chart
+	}
+	if labels[legacyHeritageLabel] == "Tiller" && labels[legacyReleaseLabel] != "" {
+		return labels[legacyReleaseLabel], labels[legacyChartLabel]
+	}
+	return "", ""
+}
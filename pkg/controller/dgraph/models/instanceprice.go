@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsInstancePrice = "isInstancePrice"
+)
+
+var osLabels = []string{"kubernetes.io/os", "beta.kubernetes.io/os"}
+
+// InstancePrice schema in dgraph, it is the rate card entry of an instance type: its on-demand or spot price per hour
+// in a region (in a zone for the spot prices of AWS) for an OS, as fetched from the pricing api of the cloud provider
+// at FetchTime
+type InstancePrice struct {
+	dgraph.ID
+	IsInstancePrice bool    `json:"isInstancePrice,omitempty"`
+	Provider        string  `json:"provider,omitempty"`
+	Region          string  `json:"region,omitempty"`
+	Zone            string  `json:"zone,omitempty"`
+	InstanceType    string  `json:"instanceType,omitempty"`
+	OS              string  `json:"os,omitempty"`
+	CapacityType    string  `json:"capacityType,omitempty"`
+	PricePerHour    float64 `json:"pricePerHour,omitempty"`
+	FetchTime       string  `json:"fetchTime,omitempty"`
+}
+
+// getNodePrice returns the price per hour of the node. An on-demand node is priced with its on-demand price, 0 if
+// on-demand pricing is disabled or the node does not run on a supported cloud provider. A spot node is priced with
+// the current spot price of its instance type when live spot prices are enabled and available, otherwise with its
+// on-demand price (or the list price of its cpus and memory) less the spot discount.
+func getNodePrice(node api_v1.Node, spot bool) float64 {
+	instance := pricing.Instance{
+		Provider:     pricing.CloudProvider(node.Spec.ProviderID),
+		Region:       getNodeLabel(node, regionLabels),
+		InstanceType: getNodeLabel(node, instanceTypeLabels),
+		OS:           getNodeLabel(node, osLabels),
+		CPU:          utils.CPUCores(*node.Status.Capacity.Cpu()),
+		Memory:       utils.MemoryGB(*node.Status.Capacity.Memory()),
+	}
+	if instance.OS == "" {
+		instance.OS = "linux"
+	}
+	isPriced := instance.Provider != "" && instance.InstanceType != ""
+
+	onDemandPrice := 0.0
+	if isPriced && pricing.IsOnDemandEnabled() {
+		onDemandPrice = getRateCardPrice(instance, pricing.OnDemandRefreshInterval())
+	}
+	if !spot {
+		return onDemandPrice
+	}
+	if isPriced && pricing.IsSpotLivePricing() {
+		instance.Spot = true
+		instance.Zone = getNodeLabel(node, zoneLabels)
+		if spotPrice := getRateCardPrice(instance, pricing.SpotRefreshInterval()); spotPrice > 0 {
+			return spotPrice
+		}
+	}
+	return pricing.SpotPrice(onDemandPrice, instance.CPU, instance.Memory)
+}
+
+// getRateCardPrice returns the price per hour of the instance from the rate card cached in Dgraph, it is fetched from
+// the provider when it is not cached or older than the refresh interval. A stale price is used when the provider is
+// unreachable, 0 when there is none.
+func getRateCardPrice(instance pricing.Instance, refreshInterval time.Duration) float64 {
+	capacityType := pricing.OnDemandCapacity
+	xidParts := []string{instance.Provider, instance.Region, instance.InstanceType, instance.OS}
+	if instance.Spot {
+		capacityType = pricing.SpotCapacity
+		xidParts = append(xidParts, capacityType, instance.Zone)
+	}
+	xid := strings.Join(xidParts, ":")
+	cached, err := getInstancePrice(xid)
+	if err != nil {
+		log.Errorf("unable to retrieve cached price of instance type: (%s), error: (%v)", xid, err)
+	}
+	if cached != nil && !isRateCardExpired(cached.FetchTime, refreshInterval) {
+		return cached.PricePerHour
+	}
+
+	price, err := pricing.FetchInstancePrice(instance)
+	if err != nil {
+		log.Errorf("unable to fetch %s price of instance type: (%s), error: (%v)", capacityType, xid, err)
+		if cached != nil {
+			return cached.PricePerHour
+		}
+		return 0
+	}
+	rateCard := InstancePrice{
+		ID:              dgraph.ID{Xid: xid},
+		IsInstancePrice: true,
+		Provider:        instance.Provider,
+		Region:          instance.Region,
+		Zone:            instance.Zone,
+		InstanceType:    instance.InstanceType,
+		OS:              instance.OS,
+		CapacityType:    capacityType,
+		PricePerHour:    price,
+		FetchTime:       time.Now().Format(time.RFC3339),
+	}
+	if cached != nil {
+		rateCard.UID = cached.UID
+	}
+	if _, err = dgraph.MutateNode(rateCard, dgraph.CREATE); err != nil {
+		log.Errorf("unable to cache %s price of instance type: (%s), error: (%v)", capacityType, xid, err)
+	} else {
+		log.Infof("%s price of instance type: (%s) is %f per hour", capacityType, xid, price)
+	}
+	return price
+}
+
+func isRateCardExpired(fetchTime string, refreshInterval time.Duration) bool {
+	fetched, err := time.Parse(time.RFC3339, fetchTime)
+	return err != nil || time.Since(fetched) > refreshInterval
+}
+
+// getInstancePrice returns the cached rate card entry of the instance type, nil if it is not cached
+func getInstancePrice(xid string) (*InstancePrice, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		prices(func: ` + builder.Eq("xid", xid) + `) @filter(has(isInstancePrice)) {
+			uid
+			pricePerHour
+			fetchTime
+		}
+	}`
+	type root struct {
+		Prices []InstancePrice `json:"prices"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil || len(newRoot.Prices) == 0 {
+		return nil, err
+	}
+	return &newRoot.Prices[0], nil
+}
+
+// RetrieveInstancePrices returns the rate card of the instance types cached in Dgraph
+func RetrieveInstancePrices() ([]InstancePrice, error) {
+	query := `{
+		prices(func: has(isInstancePrice)) {
+			xid
+			provider
+			region
+			instanceType
+			os
+			pricePerHour
+			fetchTime
+		}
+	}`
+	type root struct {
+		Prices []InstancePrice `json:"prices"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteReplicaQuery(query, &newRoot); err != nil {
+		return nil, err
+	}
+	return newRoot.Prices, nil
+}
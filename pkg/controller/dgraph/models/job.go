@@ -33,13 +33,14 @@ const (
 // Job schema in dgraph
 type Job struct {
 	dgraph.ID
-	IsJob     bool       `json:"isJob,omitempty"`
-	Name      string     `json:"name,omitempty"`
-	StartTime string     `json:"startTime,omitempty"`
-	EndTime   string     `json:"endTime,omitempty"`
-	Namespace *Namespace `json:"namespace,omitempty"`
-	Pods      []*Pod     `json:"pod,omitempty"`
-	Type      string     `json:"type,omitempty"`
+	IsJob       bool         `json:"isJob,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	StartTime   string       `json:"startTime,omitempty"`
+	EndTime     string       `json:"endTime,omitempty"`
+	Namespace   *Namespace   `json:"namespace,omitempty"`
+	Pods        []*Pod       `json:"pod,omitempty"`
+	Type        string       `json:"type,omitempty"`
+	Environment *Environment `json:"environment,omitempty"`
 }
 
 func createJobObject(job batch_v1.Job) Job {
@@ -54,6 +55,7 @@ func createJobObject(job batch_v1.Job) Job {
 	if namespaceUID != "" {
 		newJob.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: job.Namespace}}
 	}
+	newJob.Environment = workloadEnvironment(job.Namespace, namespaceUID, job.Spec.Template.Labels)
 	jobDeletionTimestamp := job.GetDeletionTimestamp()
 	if !jobDeletionTimestamp.IsZero() {
 		newJob.EndTime = jobDeletionTimestamp.Time.Format(time.RFC3339)
@@ -96,7 +98,7 @@ func CreateOrGetJobByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
@@ -18,6 +18,10 @@
 package models
 
 import (
+	"strconv"
+	"strings"
+	"sync"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/vmware/purser/pkg/controller/dgraph"
 )
@@ -33,8 +37,22 @@ type Label struct {
 	IsLabel bool   `json:"isLabel,omitempty"`
 	Key     string `json:"key,omitempty"`
 	Value   string `json:"value,omitempty"`
+	// RefCount is the number of nodes having the label, as counted by the last collection of the labels
+	RefCount int `json:"refCount,omitempty"`
+}
+
+// labelKeyValue identifies a label, its xid can't since the key and the value are joined with a dash they may contain
+type labelKeyValue struct {
+	key   string
+	value string
 }
 
+// labelMu serializes the lookups and creations of labels so that a key value is stored in a single label node
+var (
+	labelMu   sync.Mutex
+	labelUIDs = make(map[labelKeyValue]string)
+)
+
 // GetLabel if label is not in dgraph it creates and returns Label object
 func GetLabel(key, value string) *Label {
 	xid := getXIDOfLabel(key, value)
@@ -44,17 +62,121 @@ func GetLabel(key, value string) *Label {
 	}
 }
 
+// GetLabels returns the labels of the key values. The labels which are not in dgraph are created together in one
+// mutation, labels which could not be created are left out.
+func GetLabels(keyValues map[string]string) []*Label {
+	labelMu.Lock()
+	defer labelMu.Unlock()
+
+	labels := make([]*Label, 0, len(keyValues))
+	var missing []*Label
+	for key, value := range keyValues {
+		xid := getXIDOfLabel(key, value)
+		label := &Label{ID: dgraph.ID{Xid: xid, UID: lookupLabel(key, value)}}
+		if label.UID == "" {
+			// blank node names are resolved by the mutation creating the missing labels
+			label.UID = "_:label" + strconv.Itoa(len(missing))
+			missing = append(missing, &Label{ID: label.ID, IsLabel: true, Key: key, Value: value})
+		}
+		labels = append(labels, label)
+	}
+	if len(missing) == 0 {
+		return labels
+	}
+
+	uids := map[string]string{}
+	assigned, err := dgraph.MutateNode(missing, dgraph.CREATE)
+	if err != nil {
+		logrus.Errorf("unable to create %d labels in dgraph, error: (%v)", len(missing), err)
+	} else {
+		uids = assigned.Uids
+		logrus.Debugf("created %d labels in dgraph", len(missing))
+	}
+	for i, label := range missing {
+		if uid := uids["label"+strconv.Itoa(i)]; uid != "" {
+			labelUIDs[labelKeyValue{key: label.Key, value: label.Value}] = uid
+		}
+	}
+	created := labels[:0]
+	for _, label := range labels {
+		if strings.HasPrefix(label.UID, "_:") {
+			if label.UID = uids[strings.TrimPrefix(label.UID, "_:")]; label.UID == "" {
+				continue
+			}
+		}
+		created = append(created, label)
+	}
+	return created
+}
+
 // CreateOrGetLabelByID if label is not in dgraph it creates and returns uid of label
 func CreateOrGetLabelByID(key, value string) string {
-	xid := getXIDOfLabel(key, value)
-	uid := dgraph.GetUID(xid, Islabel)
+	labelMu.Lock()
+	defer labelMu.Unlock()
+
+	uid := lookupLabel(key, value)
 	if uid == "" {
 		// create new label and get its uid
 		uid = createLabelObject(key, value)
+		if uid != "" {
+			labelUIDs[labelKeyValue{key: key, value: value}] = uid
+		}
 	}
 	return uid
 }
 
+// lookupLabel returns the uid of the label with the key and value, the most referenced one when the label was stored
+// more than once. It must be called with labelMu held.
+func lookupLabel(key, value string) string {
+	keyValue := labelKeyValue{key: key, value: value}
+	if uid, isCached := labelUIDs[keyValue]; isCached {
+		return uid
+	}
+
+	builder := dgraph.NewQueryBuilder()
+	// empty values are not stored
+	valueFilter := `NOT has(value)`
+	if value != "" {
+		valueFilter = builder.Eq("value", value)
+	}
+	query := `{
+		labels(func: ` + builder.Eq("key", key) + `) @filter(has(isLabel) AND ` + valueFilter + `) {
+			uid
+			key
+			value
+			refCount: count(~label)
+		}
+	}`
+
+	type root struct {
+		Labels []Label `json:"labels"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		logrus.Errorf("unable to look up label key: (%v), value: (%v), error: (%v)", key, value, err)
+		return ""
+	}
+	var matching []Label
+	for _, label := range newRoot.Labels {
+		// the key and value are matched by terms, so they are compared exactly here
+		if label.Key == key && label.Value == value {
+			matching = append(matching, label)
+		}
+	}
+	if len(matching) == 0 {
+		return ""
+	}
+	uid := canonicalLabel(matching).UID
+	labelUIDs[keyValue] = uid
+	return uid
+}
+
+// forgetLabels clears the uids of the labels looked up, after labels were merged or deleted. It must be called with
+// labelMu held.
+func forgetLabels() {
+	labelUIDs = make(map[labelKeyValue]string)
+}
+
 func getXIDOfLabel(key, value string) string {
 	return "label-" + key + "-" + value
 }
@@ -75,3 +197,210 @@ func createLabelObject(key, value string) string {
 	logrus.Debugf("created label in dgraph key: (%v), value: (%v)", newLabel.Key, newLabel.Value)
 	return assigned.Uids["blank-0"]
 }
+
+// labelCount is a label with the number of nodes having it and the count stored by the last collection
+type labelCount struct {
+	Label
+	Stored int `json:"stored"`
+}
+
+// labelReferrer is a node having labels, like a pod, a namespace or a group
+type labelReferrer struct {
+	dgraph.ID
+	Labels []*Label `json:"label,omitempty"`
+}
+
+// labelCollection is what a collection of the labels changes: the duplicates of every key value are merged into its
+// canonical label, the unreferenced labels are deleted and the reference counts which changed are stored
+type labelCollection struct {
+	merges       map[string][]string
+	unreferenced []string
+	recounts     []Label
+	// candidates are the labels found unreferenced, deleted if they are still unreferenced by the next collection
+	candidates map[string]bool
+}
+
+// unreferencedLabels are the labels found unreferenced by the last collection. A label is created before the node
+// having it is written, so only the labels unreferenced in two collections in a row are deleted.
+var unreferencedLabels = make(map[string]bool)
+
+// CollectLabels merges the labels stored more than once for a key value, deletes the labels no node has anymore (as
+// the pods having them were purged) and stores the reference counts of the labels.
+func CollectLabels() {
+	labels, err := retrieveLabelCounts()
+	if err != nil {
+		logrus.Errorf("unable to retrieve labels: (%v)", err)
+		return
+	}
+	collection := planLabelCollection(labels, unreferencedLabels)
+
+	labelMu.Lock()
+	defer labelMu.Unlock()
+	defer forgetLabels()
+	merged := 0
+	for canonical, duplicates := range collection.merges {
+		if err = mergeLabels(canonical, duplicates); err != nil {
+			logrus.Errorf("unable to merge duplicates of label: (%s), error: (%v)", canonical, err)
+			continue
+		}
+		merged += len(duplicates)
+	}
+	if len(collection.unreferenced) > 0 {
+		nodes := make([]dgraph.ID, len(collection.unreferenced))
+		for i, uid := range collection.unreferenced {
+			nodes[i] = dgraph.ID{UID: uid}
+		}
+		if _, err = dgraph.MutateNode(nodes, dgraph.DELETE); err != nil {
+			logrus.Errorf("unable to delete %d unreferenced labels: (%v)", len(nodes), err)
+			return
+		}
+	}
+	if len(collection.recounts) > 0 {
+		if _, err = dgraph.MutateNode(collection.recounts, dgraph.UPDATE); err != nil {
+			logrus.Errorf("unable to store reference counts of %d labels: (%v)", len(collection.recounts), err)
+		}
+	}
+	unreferencedLabels = collection.candidates
+	logrus.Infof("collected labels, merged: (%d), deleted: (%d), recounted: (%d)", merged, len(collection.unreferenced),
+		len(collection.recounts))
+}
+
+func retrieveLabelCounts() ([]labelCount, error) {
+	const q = `query {
+		labels(func: has(isLabel)) {
+			uid
+			key
+			value
+			stored: refCount
+			refCount: count(~label)
+		}
+	}`
+
+	type root struct {
+		Labels []labelCount `json:"labels"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	return newRoot.Labels, nil
+}
+
+// planLabelCollection groups the labels by key value. The labels of a key value no node has are candidates for
+// deletion, and deleted if they were candidates already. Otherwise the other labels of the key value are merged into
+// the most referenced one, unless they are unreferenced, and its reference count is the sum of theirs.
+func planLabelCollection(labels []labelCount, candidates map[string]bool) labelCollection {
+	collection := labelCollection{merges: map[string][]string{}, candidates: map[string]bool{}}
+	groups := map[labelKeyValue][]labelCount{}
+	var keyValues []labelKeyValue
+	for _, label := range labels {
+		keyValue := labelKeyValue{key: label.Key, value: label.Value}
+		if _, isSeen := groups[keyValue]; !isSeen {
+			keyValues = append(keyValues, keyValue)
+		}
+		groups[keyValue] = append(groups[keyValue], label)
+	}
+
+	unreferenced := func(uid string) {
+		if candidates[uid] {
+			collection.unreferenced = append(collection.unreferenced, uid)
+		} else {
+			collection.candidates[uid] = true
+		}
+	}
+	for _, keyValue := range keyValues {
+		group := groups[keyValue]
+		counted := make([]Label, len(group))
+		for i, label := range group {
+			counted[i] = label.Label
+		}
+		canonical := canonicalLabel(counted)
+		total := 0
+		stored := 0
+		for _, label := range group {
+			total += label.RefCount
+			if label.UID == canonical.UID {
+				stored = label.Stored
+				continue
+			}
+			if label.RefCount == 0 {
+				unreferenced(label.UID)
+				continue
+			}
+			collection.merges[canonical.UID] = append(collection.merges[canonical.UID], label.UID)
+		}
+		if total == 0 {
+			unreferenced(canonical.UID)
+			continue
+		}
+		if total != stored {
+			collection.recounts = append(collection.recounts, Label{ID: dgraph.ID{UID: canonical.UID}, RefCount: total})
+		}
+	}
+	return collection
+}
+
+// canonicalLabel returns the label a key value stored more than once is merged into, the most referenced one and
+// the oldest one (by uid) of the most referenced ones
+func canonicalLabel(labels []Label) Label {
+	canonical := labels[0]
+	for _, label := range labels[1:] {
+		if label.RefCount > canonical.RefCount ||
+			(label.RefCount == canonical.RefCount && isLowerUID(label.UID, canonical.UID)) {
+			canonical = label
+		}
+	}
+	return canonical
+}
+
+// isLowerUID compares the hexadecimal uids as numbers
+func isLowerUID(uid, other string) bool {
+	if len(uid) != len(other) {
+		return len(uid) < len(other)
+	}
+	return uid < other
+}
+
+// mergeLabels moves the edges of the nodes having the duplicate labels to the canonical label and deletes the
+// duplicates
+func mergeLabels(canonical string, duplicates []string) error {
+	q := `query {
+		duplicates(func: uid(` + strings.Join(duplicates, ", ") + `)) {
+			uid
+			referrers: ~label {
+				uid
+			}
+		}
+	}`
+
+	type root struct {
+		Duplicates []struct {
+			dgraph.ID
+			Referrers []dgraph.ID `json:"referrers"`
+		} `json:"duplicates"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return err
+	}
+
+	var added, removed []labelReferrer
+	nodes := make([]dgraph.ID, 0, len(newRoot.Duplicates))
+	for _, duplicate := range newRoot.Duplicates {
+		for _, referrer := range duplicate.Referrers {
+			added = append(added, labelReferrer{ID: dgraph.ID{UID: referrer.UID}, Labels: []*Label{{ID: dgraph.ID{UID: canonical}}}})
+			removed = append(removed, labelReferrer{ID: dgraph.ID{UID: referrer.UID}, Labels: []*Label{{ID: dgraph.ID{UID: duplicate.UID}}}})
+		}
+		nodes = append(nodes, dgraph.ID{UID: duplicate.UID})
+	}
+	if len(added) > 0 {
+		if _, err := dgraph.MutateNode(added, dgraph.UPDATE); err != nil {
+			return err
+		}
+		if _, err := dgraph.MutateNode(removed, dgraph.DELETE); err != nil {
+			return err
+		}
+	}
+	_, err := dgraph.MutateNode(nodes, dgraph.DELETE)
+	return err
+}
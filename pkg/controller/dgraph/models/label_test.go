@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newLabelCount(uid, key, value string, refCount, stored int) labelCount {
+	return labelCount{Label: Label{ID: dgraph.ID{UID: uid}, Key: key, Value: value, RefCount: refCount}, Stored: stored}
+}
+
+func TestCanonicalLabel(t *testing.T) {
+	labels := []Label{
+		{ID: dgraph.ID{UID: "0x1a"}, RefCount: 3},
+		{ID: dgraph.ID{UID: "0x9"}, RefCount: 3},
+		{ID: dgraph.ID{UID: "0x2"}, RefCount: 1},
+	}
+	// uids are compared as numbers
+	utils.Equals(t, "0x9", canonicalLabel(labels).UID)
+	labels[2].RefCount = 4
+	utils.Equals(t, "0x2", canonicalLabel(labels).UID)
+}
+
+func TestPlanLabelCollection(t *testing.T) {
+	labels := []labelCount{
+		newLabelCount("0x1", "app", "web", 5, 5),
+		newLabelCount("0x2", "app", "web", 2, 0),
+		newLabelCount("0x3", "app", "web", 0, 0),
+		newLabelCount("0x4", "app-web", "v1", 1, 0),
+		newLabelCount("0x5", "app", "web-v1", 0, 2),
+		newLabelCount("0x6", "tier", "db", 0, 0),
+	}
+	collection := planLabelCollection(labels, map[string]bool{"0x6": true})
+
+	// the referenced duplicate is merged, the unreferenced one waits for the next collection
+	utils.Equals(t, map[string][]string{"0x1": {"0x2"}}, collection.merges)
+	utils.Equals(t, []string{"0x6"}, collection.unreferenced)
+	utils.Equals(t, map[string]bool{"0x3": true, "0x5": true}, collection.candidates)
+	// labels whose xids are the same are different key values
+	utils.Equals(t, []Label{
+		{ID: dgraph.ID{UID: "0x1"}, RefCount: 7},
+		{ID: dgraph.ID{UID: "0x4"}, RefCount: 1},
+	}, collection.recounts)
+}
+
+func TestPlanLabelCollectionUnchanged(t *testing.T) {
+	collection := planLabelCollection([]labelCount{newLabelCount("0x1", "app", "web", 5, 5)}, map[string]bool{})
+	utils.Equals(t, 0, len(collection.merges))
+	utils.Equals(t, 0, len(collection.unreferenced))
+	utils.Equals(t, 0, len(collection.recounts))
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"sort"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+var (
+	// storedLabelsMu guards storedLabels, the uids of the labels last written to the pods and namespaces by their uid
+	storedLabelsMu sync.Mutex
+	storedLabels   = map[string][]string{}
+)
+
+// replaceLabelEdges queues the deletion of the label edges of the node which are not in its new labels, so that a
+// label removed from a pod or namespace (or inherited from its namespace with another value) does not stay on it.
+// The labels of a node not written since the controller started are read from the Dgraph. The deletion is queued
+// before the update setting the new labels so that the batches write them in order.
+func replaceLabelEdges(uid string, labels []*Label) {
+	storedLabelsMu.Lock()
+	stored, isStored := storedLabels[uid]
+	storedLabelsMu.Unlock()
+	if !isStored {
+		var err error
+		if stored, err = retrieveLabelUIDs(uid); err != nil {
+			log.Errorf("unable to retrieve labels of uid: (%s), error: (%v)", uid, err)
+			return
+		}
+	}
+
+	uids := make([]string, len(labels))
+	for i, label := range labels {
+		uids[i] = label.UID
+	}
+	if stale := staleLabels(stored, uids); len(stale) > 0 {
+		referrer := labelReferrer{ID: dgraph.ID{UID: uid}}
+		for _, labelUID := range stale {
+			referrer.Labels = append(referrer.Labels, &Label{ID: dgraph.ID{UID: labelUID}})
+		}
+		if err := dgraph.QueueMutation(referrer, dgraph.DELETE); err != nil {
+			log.Errorf("unable to delete %d stale labels of uid: (%s), error: (%v)", len(stale), uid, err)
+			return
+		}
+	}
+	storedLabelsMu.Lock()
+	storedLabels[uid] = uids
+	storedLabelsMu.Unlock()
+}
+
+// forgetLabelEdges removes the labels written to the deleted node, or to a node whose labels were changed without
+// replaceLabelEdges
+func forgetLabelEdges(uid string) {
+	storedLabelsMu.Lock()
+	defer storedLabelsMu.Unlock()
+	delete(storedLabels, uid)
+}
+
+// staleLabels returns the stored label uids which are not in the new ones
+func staleLabels(stored, uids []string) []string {
+	current := make(map[string]bool, len(uids))
+	for _, uid := range uids {
+		current[uid] = true
+	}
+	var stale []string
+	for _, uid := range stored {
+		if !current[uid] {
+			stale = append(stale, uid)
+		}
+	}
+	return stale
+}
+
+func retrieveLabelUIDs(uid string) ([]string, error) {
+	q := `query {
+		node(func: uid(` + uid + `)) {
+			label {
+				uid
+			}
+		}
+	}`
+	type root struct {
+		Node []labelReferrer `json:"node"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, node := range newRoot.Node {
+		for _, label := range node.Labels {
+			uids = append(uids, label.UID)
+		}
+	}
+	return uids, nil
+}
+
+// propagateInheritedLabels changes the labels the live pods of the namespace inherit from it when the inherited
+// labels of the namespace changed from old to new. A label the pod sets itself is left as it is, a pod setting a
+// label to the old inherited value gets the new one until the pod is stored again.
+func propagateInheritedLabels(namespaceUID string, old, new map[string]string) {
+	q := `query {
+		namespace(func: uid(` + namespaceUID + `)) {
+			pods: ~namespace @filter(has(isPod) AND NOT has(endTime)) {
+				uid
+				label {
+					uid
+					key
+					value
+				}
+			}
+		}
+	}`
+	type root struct {
+		Namespace []struct {
+			Pods []labelReferrer `json:"pods"`
+		} `json:"namespace"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil || len(newRoot.Namespace) == 0 {
+		log.Errorf("unable to retrieve pods of namespace uid: (%s), error: (%v)", namespaceUID, err)
+		return
+	}
+
+	var removed, added []labelReferrer
+	relabeled := 0
+	for _, pod := range newRoot.Namespace[0].Pods {
+		podLabels := map[string]*Label{}
+		for _, label := range pod.Labels {
+			podLabels[label.Key] = label
+		}
+		removedKeys, addedLabels := planInheritedLabelChange(podLabels, old, new)
+		if len(removedKeys) > 0 || len(addedLabels) > 0 {
+			relabeled++
+		}
+		if len(removedKeys) > 0 {
+			referrer := labelReferrer{ID: dgraph.ID{UID: pod.UID}}
+			for _, key := range removedKeys {
+				referrer.Labels = append(referrer.Labels, &Label{ID: dgraph.ID{UID: podLabels[key].UID}})
+			}
+			removed = append(removed, referrer)
+		}
+		if len(addedLabels) > 0 {
+			added = append(added, labelReferrer{ID: dgraph.ID{UID: pod.UID}, Labels: GetLabels(addedLabels)})
+		}
+		forgetLabelEdges(pod.UID)
+	}
+	if len(removed) > 0 {
+		if err := dgraph.QueueMutation(removed, dgraph.DELETE); err != nil {
+			log.Errorf("unable to remove inherited labels of namespace uid: (%s), error: (%v)", namespaceUID, err)
+			return
+		}
+	}
+	if len(added) > 0 {
+		if err := dgraph.QueueMutation(added, dgraph.UPDATE); err != nil {
+			log.Errorf("unable to add inherited labels of namespace uid: (%s), error: (%v)", namespaceUID, err)
+			return
+		}
+	}
+	log.Infof("inherited labels of namespace uid: (%s) changed, relabeled %d pods", namespaceUID, relabeled)
+}
+
+// planInheritedLabelChange returns the keys of the labels of the pod to remove and the labels to add to it when the
+// inherited labels of its namespace change from old to new. The labels the pod sets itself, with a value other than
+// the old inherited one, are kept.
+func planInheritedLabelChange(podLabels map[string]*Label, old, new map[string]string) ([]string, map[string]string) {
+	var removed []string
+	added := map[string]string{}
+	keys := map[string]bool{}
+	for key := range old {
+		keys[key] = true
+	}
+	for key := range new {
+		keys[key] = true
+	}
+	for key := range keys {
+		oldValue, isInherited := old[key]
+		newValue, isStillInherited := new[key]
+		if isInherited == isStillInherited && oldValue == newValue {
+			continue
+		}
+		podLabel, hasLabel := podLabels[key]
+		if hasLabel && (!isInherited || podLabel.Value != oldValue) {
+			continue
+		}
+		if hasLabel {
+			removed = append(removed, key)
+		}
+		if isStillInherited {
+			added[key] = newValue
+		}
+	}
+	sort.Strings(removed)
+	return removed, added
+}
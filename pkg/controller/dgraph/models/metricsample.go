@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Dgraph Model Constants
+const (
+	IsMetricSample = "isMetricSample"
+)
+
+// MetricSample schema in dgraph. A sample holds the requests and limits of a container from StartTime until
+// EndTime, the latest sample of a container has no EndTime.
+type MetricSample struct {
+	dgraph.ID
+	IsMetricSample bool        `json:"isMetricSample,omitempty"`
+	StartTime      string      `json:"startTime,omitempty"`
+	EndTime        string      `json:"endTime,omitempty"`
+	CPURequest     utils.Cores `json:"cpuRequest"`
+	CPULimit       utils.Cores `json:"cpuLimit"`
+	MemoryRequest  utils.GB    `json:"memoryRequest"`
+	MemoryLimit    utils.GB    `json:"memoryLimit"`
+	GPURequest     float64     `json:"gpuRequest"`
+	GPULimit       float64     `json:"gpuLimit"`
+}
+
+// containerSamples is a container with the edges to its metric samples
+type containerSamples struct {
+	dgraph.ID
+	Samples []*MetricSample `json:"sample,omitempty"`
+}
+
+var (
+	// latestSamplesMu guards latestSamples, the latest metric sample of the containers by xid. The latest sample of
+	// a container is read from the Dgraph the first time the container is seen, nil if it has none.
+	latestSamplesMu sync.Mutex
+	latestSamples   = map[string]*MetricSample{}
+)
+
+// storeContainerMetrics stores a new metrics sample for the container only if any metric changed beyond the
+// threshold since its latest sample, which is then ended. Otherwise the latest sample continues to hold.
+func storeContainerMetrics(container dgraph.ID, metrics Metrics, sampleTime time.Time) error {
+	latest, err := getLatestMetricSample(container)
+	if err != nil {
+		return err
+	}
+	if latest != nil && !isChangedBeyondThreshold(sampleToMetrics(*latest), metrics, getMetricsChangeThreshold()) {
+		return nil
+	}
+
+	sampleStart := sampleTime.Format(time.RFC3339)
+	sample := newMetricSample(container.Xid, metrics, sampleTime)
+	nodes := []interface{}{containerSamples{ID: dgraph.ID{UID: container.UID}, Samples: []*MetricSample{&sample}}}
+	if latest != nil {
+		nodes = append(nodes, map[string]interface{}{"uid": latest.UID, "endTime": sampleStart})
+	}
+	assigned, err := dgraph.MutateNode(nodes, dgraph.UPDATE)
+	if err != nil {
+		// the latest sample is read again, it may have been ended
+		forgetLatestSample(container.Xid)
+		return err
+	}
+	sample.UID = assigned.Uids["sample"]
+
+	latestSamplesMu.Lock()
+	defer latestSamplesMu.Unlock()
+	latestSamples[container.Xid] = &sample
+	return nil
+}
+
+func newMetricSample(containerXid string, metrics Metrics, startTime time.Time) MetricSample {
+	return MetricSample{
+		ID:             dgraph.ID{Xid: fmt.Sprintf("%s:%d", containerXid, startTime.Unix()), UID: "_:sample"},
+		IsMetricSample: true,
+		StartTime:      startTime.Format(time.RFC3339),
+		CPURequest:     metrics.CPURequest,
+		CPULimit:       metrics.CPULimit,
+		MemoryRequest:  metrics.MemoryRequest,
+		MemoryLimit:    metrics.MemoryLimit,
+		GPURequest:     metrics.GPURequest,
+		GPULimit:       metrics.GPULimit,
+	}
+}
+
+// getLatestMetricSample returns the latest sample of the container, it is queried only the first time the container
+// is seen
+func getLatestMetricSample(container dgraph.ID) (*MetricSample, error) {
+	latestSamplesMu.Lock()
+	latest, isKnown := latestSamples[container.Xid]
+	latestSamplesMu.Unlock()
+	if isKnown {
+		return latest, nil
+	}
+
+	q := `query {
+		containers(func: uid(` + container.UID + `)) {
+			sample(orderdesc: startTime, first: 1) @filter(NOT has(endTime)) {
+				uid
+				startTime
+				cpuRequest
+				cpuLimit
+				memoryRequest
+				memoryLimit
+				gpuRequest
+				gpuLimit
+			}
+		}
+	}`
+	type root struct {
+		Containers []containerSamples `json:"containers"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	if len(newRoot.Containers) > 0 && len(newRoot.Containers[0].Samples) > 0 {
+		latest = newRoot.Containers[0].Samples[0]
+	}
+
+	latestSamplesMu.Lock()
+	defer latestSamplesMu.Unlock()
+	latestSamples[container.Xid] = latest
+	return latest, nil
+}
+
+func forgetLatestSample(containerXid string) {
+	latestSamplesMu.Lock()
+	defer latestSamplesMu.Unlock()
+	delete(latestSamples, containerXid)
+}
+
+// endContainerSamples ends the latest metric samples of the containers of the terminated pod
+func endContainerSamples(podUID string, endTime time.Time) {
+	q := `query {
+		pod(func: uid(` + podUID + `)) {
+			containers {
+				xid
+				sample @filter(NOT has(endTime)) {
+					uid
+				}
+			}
+		}
+	}`
+	type root struct {
+		Pod []struct {
+			Containers []containerSamples `json:"containers"`
+		} `json:"pod"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil || len(newRoot.Pod) == 0 {
+		return
+	}
+	var ended []map[string]interface{}
+	for _, container := range newRoot.Pod[0].Containers {
+		forgetLatestSample(container.Xid)
+		for _, sample := range container.Samples {
+			ended = append(ended, map[string]interface{}{"uid": sample.UID, "endTime": endTime.Format(time.RFC3339)})
+		}
+	}
+	if len(ended) == 0 {
+		return
+	}
+	if err := dgraph.QueueMutation(ended, dgraph.UPDATE); err != nil {
+		log.Errorf("unable to end metric samples of pod uid: (%s), error: (%v)", podUID, err)
+	}
+}
+
+// CompactMetricSamples merges the adjacent ended samples of every container whose metrics are within the change
+// threshold of each other, ex: samples stored before the threshold was raised or by two replicas while a namespace
+// moved between them. The latest samples are left as they are.
+func CompactMetricSamples() {
+	containers, err := retrieveContainersWithEndedSamples()
+	if err != nil {
+		log.Errorf("unable to retrieve container metric samples: (%v)", err)
+		return
+	}
+
+	threshold := getMetricsChangeThreshold()
+	merged := 0
+	for _, container := range containers {
+		extended, removed := compactSamples(container.Samples, threshold)
+		if len(removed) == 0 {
+			continue
+		}
+		if err = writeCompactedSamples(container.UID, extended, removed); err != nil {
+			log.Errorf("unable to merge metric samples of container: (%s), error: (%v)", container.Xid, err)
+			continue
+		}
+		merged += len(removed)
+	}
+	log.Infof("compacted metric samples, merged: (%d)", merged)
+}
+
+// compactSamples merges the adjacent samples, ordered by start time, whose metrics are within the threshold of each
+// other: the earlier sample spans both and the later one is removed. It returns the samples whose end time is
+// extended and the samples removed.
+func compactSamples(samples []*MetricSample, threshold float64) ([]*MetricSample, []*MetricSample) {
+	var kept, extended, removed []*MetricSample
+	for _, sample := range samples {
+		last := len(kept) - 1
+		if last < 0 || isChangedBeyondThreshold(sampleToMetrics(*kept[last]), sampleToMetrics(*sample), threshold) {
+			kept = append(kept, sample)
+			continue
+		}
+		if len(extended) == 0 || extended[len(extended)-1] != kept[last] {
+			extended = append(extended, kept[last])
+		}
+		kept[last].EndTime = sample.EndTime
+		removed = append(removed, sample)
+	}
+	return extended, removed
+}
+
+// writeCompactedSamples writes the end times of the extended samples and deletes the removed ones with their edges
+func writeCompactedSamples(containerUID string, extended, removed []*MetricSample) error {
+	ended := make([]map[string]interface{}, 0, len(extended))
+	for _, sample := range extended {
+		ended = append(ended, map[string]interface{}{"uid": sample.UID, "endTime": sample.EndTime})
+	}
+	if _, err := dgraph.MutateNode(ended, dgraph.UPDATE); err != nil {
+		return err
+	}
+
+	edges := containerSamples{ID: dgraph.ID{UID: containerUID}}
+	deleted := []interface{}{&edges}
+	for _, sample := range removed {
+		edges.Samples = append(edges.Samples, &MetricSample{ID: dgraph.ID{UID: sample.UID}})
+		deleted = append(deleted, dgraph.ID{UID: sample.UID})
+	}
+	_, err := dgraph.MutateNode(deleted, dgraph.DELETE)
+	return err
+}
+
+func retrieveContainersWithEndedSamples() ([]containerSamples, error) {
+	q := `query {
+		containers(func: has(isContainer)) @filter(has(sample)) {
+			uid
+			xid
+			sample(orderasc: startTime) @filter(has(endTime)) {
+				uid
+				startTime
+				endTime
+				cpuRequest
+				cpuLimit
+				memoryRequest
+				memoryLimit
+				gpuRequest
+				gpuLimit
+			}
+		}
+	}`
+	type root struct {
+		Containers []containerSamples `json:"containers"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, err
+	}
+	return newRoot.Containers, nil
+}
+
+func sampleToMetrics(sample MetricSample) Metrics {
+	return Metrics{
+		CPURequest:    sample.CPURequest,
+		CPULimit:      sample.CPULimit,
+		MemoryRequest: sample.MemoryRequest,
+		MemoryLimit:   sample.MemoryLimit,
+		GPURequest:    sample.GPURequest,
+		GPULimit:      sample.GPULimit,
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestNewMetricSample(t *testing.T) {
+	start := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	sample := newMetricSample("default:web-0:nginx", Metrics{CPURequest: 0.25, MemoryLimit: 0.5, GPURequest: 1}, start)
+	utils.Equals(t, dgraph.ID{Xid: "default:web-0:nginx:1546423200", UID: "_:sample"}, sample.ID)
+	utils.Equals(t, "2019-01-02T10:00:00Z", sample.StartTime)
+	utils.Equals(t, Metrics{CPURequest: 0.25, MemoryLimit: 0.5, GPURequest: 1}, sampleToMetrics(sample))
+}
+
+func TestCompactSamples(t *testing.T) {
+	sample := func(uid string, cpu float64, start, end string) *MetricSample {
+		return &MetricSample{ID: dgraph.ID{UID: uid}, CPURequest: cpu, MemoryRequest: 1, StartTime: start, EndTime: end}
+	}
+	samples := []*MetricSample{
+		sample("0x1", 1, "2019-01-01T00:00:00Z", "2019-01-02T00:00:00Z"),
+		sample("0x2", 1, "2019-01-02T00:00:00Z", "2019-01-03T00:00:00Z"),
+		sample("0x3", 1.04, "2019-01-03T00:00:00Z", "2019-01-04T00:00:00Z"),
+		sample("0x4", 2, "2019-01-04T00:00:00Z", "2019-01-05T00:00:00Z"),
+		sample("0x5", 1, "2019-01-05T00:00:00Z", "2019-01-06T00:00:00Z"),
+		sample("0x6", 1, "2019-01-06T00:00:00Z", "2019-01-07T00:00:00Z"),
+	}
+	extended, removed := compactSamples(samples, 0.05)
+
+	utils.Equals(t, 2, len(extended))
+	utils.Equals(t, "0x1", extended[0].UID)
+	// the first sample spans the identical one and the one within the threshold
+	utils.Equals(t, "2019-01-04T00:00:00Z", extended[0].EndTime)
+	// samples separated by a change are not merged even when they are identical
+	utils.Equals(t, "0x5", extended[1].UID)
+	utils.Equals(t, "2019-01-07T00:00:00Z", extended[1].EndTime)
+	utils.Equals(t, 3, len(removed))
+	utils.Equals(t, []string{"0x2", "0x3", "0x6"}, []string{removed[0].UID, removed[1].UID, removed[2].UID})
+	utils.Equals(t, "2019-01-05T00:00:00Z", samples[3].EndTime)
+
+	extended, removed = compactSamples(samples[3:4], 0.05)
+	utils.Equals(t, 0, len(extended)+len(removed))
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"math"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// defaultChangeThreshold is the relative change of a metric below which the requests of a pod are not rewritten
+const defaultChangeThreshold = 0.05
+
+var (
+	metricsChangeThreshold = defaultChangeThreshold
+
+	// storedMetricsMu guards storedMetrics, the requests and limits of the pods last written to the Dgraph
+	storedMetricsMu sync.Mutex
+	storedMetrics   = map[string]Metrics{}
+)
+
+// SetMetricsChangeThreshold sets the relative change (ex: 0.05 for 5%) a metric must exceed for the requests and
+// limits of a pod to be rewritten on its update, and for a new metrics sample of a container to be stored.
+func SetMetricsChangeThreshold(threshold float64) {
+	if threshold < 0 {
+		log.Errorf("invalid metrics change threshold: (%v), using default", threshold)
+		threshold = defaultChangeThreshold
+	}
+	storedMetricsMu.Lock()
+	defer storedMetricsMu.Unlock()
+	metricsChangeThreshold = threshold
+}
+
+func getMetricsChangeThreshold() float64 {
+	storedMetricsMu.Lock()
+	defer storedMetricsMu.Unlock()
+	return metricsChangeThreshold
+}
+
+// isPodMetricsChanged returns true if any metric of the pod changed beyond the threshold since they were last
+// written, the metrics are then remembered as written until their write fails. The metrics of a pod not written
+// since the controller started are always changed.
+func isPodMetricsChanged(xid string, metrics Metrics) bool {
+	storedMetricsMu.Lock()
+	defer storedMetricsMu.Unlock()
+	stored, isStored := storedMetrics[xid]
+	if isStored && !isChangedBeyondThreshold(stored, metrics, metricsChangeThreshold) {
+		return false
+	}
+	storedMetrics[xid] = metrics
+	return true
+}
+
+// forgetPodMetrics removes the written metrics of the deleted pod
+func forgetPodMetrics(xid string) {
+	storedMetricsMu.Lock()
+	defer storedMetricsMu.Unlock()
+	delete(storedMetrics, xid)
+}
+
+// forgetPodMetricsOnError returns the callback of the write of the metrics of the pod, they are forgotten when the
+// write fails so that they are written again by the next update of the pod
+func forgetPodMetricsOnError(xid string) func(err error) {
+	return func(err error) {
+		if err != nil {
+			forgetPodMetrics(xid)
+		}
+	}
+}
+
+// isChangedBeyondThreshold returns true if any metric changed by more than threshold relative to its old value
+func isChangedBeyondThreshold(old, new Metrics, threshold float64) bool {
+	return isValueChanged(float64(old.CPURequest), float64(new.CPURequest), threshold) ||
+		isValueChanged(float64(old.CPULimit), float64(new.CPULimit), threshold) ||
+		isValueChanged(float64(old.MemoryRequest), float64(new.MemoryRequest), threshold) ||
+		isValueChanged(float64(old.MemoryLimit), float64(new.MemoryLimit), threshold) ||
+		isValueChanged(old.GPURequest, new.GPURequest, threshold) ||
+		isValueChanged(old.GPULimit, new.GPULimit, threshold)
+}
+
+func isValueChanged(old, new, threshold float64) bool {
+	if old == 0 {
+		return new != 0
+	}
+	return math.Abs(new-old)/math.Abs(old) > threshold
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIsChangedBeyondThreshold(t *testing.T) {
+	old := Metrics{CPURequest: 1, CPULimit: 2, MemoryRequest: 4, MemoryLimit: 8}
+	tests := []struct {
+		name      string
+		new       Metrics
+		isChanged bool
+	}{
+		{"same metrics", old, false},
+		{"change within threshold", Metrics{CPURequest: 1.04, CPULimit: 1.95, MemoryRequest: 4, MemoryLimit: 8}, false},
+		{"cpu request beyond threshold", Metrics{CPURequest: 1.06, CPULimit: 2, MemoryRequest: 4, MemoryLimit: 8}, true},
+		{"memory limit decreased beyond threshold", Metrics{CPURequest: 1, CPULimit: 2, MemoryRequest: 4, MemoryLimit: 7}, true},
+		{"gpu requested", Metrics{CPURequest: 1, CPULimit: 2, MemoryRequest: 4, MemoryLimit: 8, GPURequest: 1}, true},
+	}
+	for _, test := range tests {
+		utils.Assert(t, isChangedBeyondThreshold(old, test.new, 0.05) == test.isChanged, "%s: expected changed: %v", test.name, test.isChanged)
+	}
+	utils.Assert(t, !isChangedBeyondThreshold(Metrics{}, Metrics{}, 0), "zero metrics are not changed")
+}
+
+func TestIsPodMetricsChanged(t *testing.T) {
+	xid := "default:web-0"
+	defer forgetPodMetrics(xid)
+
+	utils.Assert(t, isPodMetricsChanged(xid, Metrics{CPURequest: 1, MemoryRequest: 2}), "metrics of a new pod are written")
+	utils.Assert(t, !isPodMetricsChanged(xid, Metrics{CPURequest: 1.04, MemoryRequest: 2}), "small change is not written")
+	// changes are compared with the metrics last written, so they can not drift by small steps
+	utils.Assert(t, isPodMetricsChanged(xid, Metrics{CPURequest: 1.08, MemoryRequest: 2}), "accumulated change is written")
+	utils.Assert(t, !isPodMetricsChanged(xid, Metrics{CPURequest: 1.08, MemoryRequest: 2}), "same metrics are not written")
+
+	forgetPodMetrics(xid)
+	utils.Assert(t, isPodMetricsChanged(xid, Metrics{CPURequest: 1.08, MemoryRequest: 2}), "metrics of a recreated pod are written")
+}
+
+func TestForgetPodMetricsOnError(t *testing.T) {
+	xid := "default:web-1"
+	defer forgetPodMetrics(xid)
+
+	utils.Assert(t, isPodMetricsChanged(xid, Metrics{CPURequest: 1}), "metrics of a new pod are written")
+	forgetPodMetricsOnError(xid)(nil)
+	utils.Assert(t, !isPodMetricsChanged(xid, Metrics{CPURequest: 1}), "written metrics are not written again")
+	// the batch of the pod failed, its metrics are written again by its next update
+	forgetPodMetricsOnError(xid)(fmt.Errorf("dgraph is unreachable and the write buffer is full"))
+	utils.Assert(t, isPodMetricsChanged(xid, Metrics{CPURequest: 1}), "metrics whose write failed are written again")
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import "sync"
+
+// Metrics modes of the controller. In blackbox mode no metrics source serves the usage of pods, costs are allocated
+// from the requests only and the reports built on usage are estimated.
+const (
+	MetricsModeMeasured = "measured"
+	MetricsModeBlackbox = "blackbox"
+)
+
+var (
+	metricsModeMu sync.RWMutex
+	metricsMode   = MetricsModeMeasured
+	metricsProbed bool
+)
+
+// SetMetricsAvailable records whether a metrics source serves the usage of pods and returns true if the metrics
+// mode changed (or was probed for the first time)
+func SetMetricsAvailable(available bool) bool {
+	mode := MetricsModeBlackbox
+	if available {
+		mode = MetricsModeMeasured
+	}
+	metricsModeMu.Lock()
+	defer metricsModeMu.Unlock()
+	changed := !metricsProbed || mode != metricsMode
+	metricsMode = mode
+	metricsProbed = true
+	return changed
+}
+
+// GetMetricsMode returns the metrics mode in use, measured until metrics sources are probed
+func GetMetricsMode() string {
+	metricsModeMu.RLock()
+	defer metricsModeMu.RUnlock()
+	return metricsMode
+}
+
+// IsBlackbox returns true if no metrics source serves the usage of pods
+func IsBlackbox() bool {
+	return GetMetricsMode() == MetricsModeBlackbox
+}
@@ -18,6 +18,8 @@
 package models
 
 import (
+	"strings"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -35,11 +37,36 @@ const (
 // Namespace schema in dgraph
 type Namespace struct {
 	dgraph.ID
-	IsNamespace bool   `json:"isNamespace,omitempty"`
-	Name        string `json:"name,omitempty"`
-	StartTime   string `json:"startTime,omitempty"`
-	EndTime     string `json:"endTime,omitempty"`
-	Type        string `json:"type,omitempty"`
+	IsNamespace bool     `json:"isNamespace,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	StartTime   string   `json:"startTime,omitempty"`
+	EndTime     string   `json:"endTime,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Labels      []*Label `json:"label,omitempty"`
+
+	// ClusterOverhead is set when the namespace is stored from the cluster, it is nil in references to the namespace
+	ClusterOverhead *bool  `json:"clusterOverhead,omitempty"`
+	OverheadReason  string `json:"overheadReason,omitempty"`
+}
+
+var (
+	// inheritedLabelKeys are the namespace label/annotation keys which are propagated to the pods of the namespace.
+	inheritedLabelKeys = map[string]bool{}
+
+	// inheritedLabelsMu guards inheritedLabels, the inherited labels of the namespaces by their uid
+	inheritedLabelsMu sync.Mutex
+	inheritedLabels   = map[string]map[string]string{}
+)
+
+// SetInheritedLabelKeys sets the namespace label/annotation keys (ex: team, env) which are inherited by pods.
+func SetInheritedLabelKeys(keys []string) {
+	inheritedLabelKeys = map[string]bool{}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			inheritedLabelKeys[key] = true
+		}
+	}
 }
 
 func newNamespace(namespace api_v1.Namespace) Namespace {
@@ -54,9 +81,112 @@ func newNamespace(namespace api_v1.Namespace) Namespace {
 	if !nsDeletionTimestamp.IsZero() {
 		ns.EndTime = nsDeletionTimestamp.Time.Format(time.RFC3339)
 	}
+	populateNamespaceLabels(&ns, namespace)
+	ns.ClusterOverhead, ns.OverheadReason = overheadFields(classifyNamespace(namespace.Name, namespace.Labels))
 	return ns
 }
 
+// populateNamespaceLabels stores all labels of the namespace and only those annotations
+// whose keys are configured for inheritance.
+func populateNamespaceLabels(ns *Namespace, namespace api_v1.Namespace) {
+	ns.Labels = GetLabels(namespaceLabels(namespace))
+}
+
+// namespaceLabels returns the labels of the namespace and its annotations whose keys are configured for inheritance
+func namespaceLabels(namespace api_v1.Namespace) map[string]string {
+	keyValues := map[string]string{}
+	for key, value := range namespace.Labels {
+		keyValues[key] = value
+	}
+	for key, value := range namespace.Annotations {
+		if _, isLabel := namespace.Labels[key]; !isLabel && inheritedLabelKeys[key] {
+			keyValues[key] = value
+		}
+	}
+	return keyValues
+}
+
+// filterInheritedLabels returns the labels whose keys are configured for inheritance
+func filterInheritedLabels(labels map[string]string) map[string]string {
+	inherited := map[string]string{}
+	for key, value := range labels {
+		if inheritedLabelKeys[key] {
+			inherited[key] = value
+		}
+	}
+	return inherited
+}
+
+// getInheritedLabels returns the labels of the namespace which are configured for inheritance. They are read from
+// the Dgraph the first time and kept up to date by StoreNamespace.
+func getInheritedLabels(namespaceUID string) map[string]string {
+	inherited := map[string]string{}
+	if len(inheritedLabelKeys) == 0 || namespaceUID == "" {
+		return inherited
+	}
+	inheritedLabelsMu.Lock()
+	cached, isCached := inheritedLabels[namespaceUID]
+	inheritedLabelsMu.Unlock()
+	if isCached {
+		return cached
+	}
+
+	q := `query {
+		namespace(func: uid(` + namespaceUID + `)) {
+			label {
+				key
+				value
+			}
+		}
+	}`
+	type root struct {
+		Namespace []Namespace `json:"namespace"`
+	}
+	newRoot := root{}
+	err := dgraph.ExecuteQuery(q, &newRoot)
+	if err != nil || len(newRoot.Namespace) == 0 {
+		log.Errorf("unable to retrieve labels of namespace uid: (%s), error: (%v)", namespaceUID, err)
+		return inherited
+	}
+	for _, label := range newRoot.Namespace[0].Labels {
+		if inheritedLabelKeys[label.Key] {
+			inherited[label.Key] = label.Value
+		}
+	}
+	inheritedLabelsMu.Lock()
+	inheritedLabels[namespaceUID] = inherited
+	inheritedLabelsMu.Unlock()
+	return inherited
+}
+
+// isSameLabels returns true if the labels have the same keys and values
+func isSameLabels(labels, other map[string]string) bool {
+	if len(labels) != len(other) {
+		return false
+	}
+	for key, value := range labels {
+		if otherValue, isPresent := other[key]; !isPresent || otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeInheritedLabels adds inherited namespace labels to pod labels. Labels set on the pod take precedence.
+func mergeInheritedLabels(podLabels, inherited map[string]string) map[string]string {
+	if len(inherited) == 0 {
+		return podLabels
+	}
+	merged := map[string]string{}
+	for key, value := range inherited {
+		merged[key] = value
+	}
+	for key, value := range podLabels {
+		merged[key] = value
+	}
+	return merged
+}
+
 // CreateOrGetNamespaceByID returns the uid of namespace if exists,
 // otherwise creates the namespace and returns uid.
 func CreateOrGetNamespaceByID(xid string) string {
@@ -84,14 +214,18 @@ func CreateOrGetNamespaceByID(xid string) string {
 	return assigned.Uids["blank-0"]
 }
 
-// StoreNamespace create a new namespace in the Dgraph  if it is not present.
+// StoreNamespace create a new namespace in the Dgraph  if it is not present. The labels removed from an existing
+// namespace are removed from it, and the live pods of the namespace inherit the changes of its inherited labels.
 func StoreNamespace(namespace api_v1.Namespace) (string, error) {
 	xid := namespace.Name
 	uid := dgraph.GetUID(xid, IsNamespace)
 
 	ns := newNamespace(namespace)
+	var oldInherited map[string]string
 	if uid != "" {
 		ns.UID = uid
+		oldInherited = getInheritedLabels(uid)
+		replaceLabelEdges(uid, ns.Labels)
 	}
 	assigned, err := dgraph.MutateNode(ns, dgraph.CREATE)
 	if err != nil {
@@ -100,6 +234,16 @@ func StoreNamespace(namespace api_v1.Namespace) (string, error) {
 
 	if uid == "" {
 		log.Infof("Namespace with xid: (%s) persisted", xid)
+		return assigned.Uids["blank-0"], nil
+	}
+	if len(inheritedLabelKeys) > 0 {
+		newInherited := filterInheritedLabels(namespaceLabels(namespace))
+		inheritedLabelsMu.Lock()
+		inheritedLabels[uid] = newInherited
+		inheritedLabelsMu.Unlock()
+		if !isSameLabels(oldInherited, newInherited) {
+			propagateInheritedLabels(uid, oldInherited, newInherited)
+		}
 	}
 	return assigned.Uids["blank-0"], nil
 }
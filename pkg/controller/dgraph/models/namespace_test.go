@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMergeInheritedLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		podLabels map[string]string
+		inherited map[string]string
+		expected  map[string]string
+	}{
+		{"nothing inherited", map[string]string{"app": "web"}, map[string]string{}, map[string]string{"app": "web"}},
+		{"inherited labels added", map[string]string{"app": "web"}, map[string]string{"team": "shop", "env": "prod"},
+			map[string]string{"app": "web", "team": "shop", "env": "prod"}},
+		{"pod labels take precedence", map[string]string{"app": "web", "team": "search"}, map[string]string{"team": "shop"},
+			map[string]string{"app": "web", "team": "search"}},
+		{"pod without labels", nil, map[string]string{"team": "shop"}, map[string]string{"team": "shop"}},
+	}
+	for _, test := range tests {
+		utils.Equals(t, test.expected, mergeInheritedLabels(test.podLabels, test.inherited))
+	}
+}
+
+func TestNamespaceInheritedLabels(t *testing.T) {
+	SetInheritedLabelKeys([]string{"team", " env", ""})
+	defer SetInheritedLabelKeys(nil)
+	namespace := api_v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "shop",
+		Labels:      map[string]string{"team": "shop", "tier": "gold"},
+		Annotations: map[string]string{"env": "prod", "team": "annotated", "owner": "alice"}}}
+	labels := namespaceLabels(namespace)
+	utils.Equals(t, map[string]string{"team": "shop", "tier": "gold", "env": "prod"}, labels)
+	utils.Equals(t, map[string]string{"team": "shop", "env": "prod"}, filterInheritedLabels(labels))
+	utils.Assert(t, isSameLabels(map[string]string{"team": "shop"}, map[string]string{"team": "shop"}), "same labels")
+	utils.Assert(t, !isSameLabels(map[string]string{"team": "shop"}, map[string]string{"team": "web"}), "other value")
+	utils.Assert(t, !isSameLabels(nil, map[string]string{"team": "shop"}), "added label")
+}
+
+func TestStaleLabels(t *testing.T) {
+	utils.Equals(t, []string{"0x1", "0x3"}, staleLabels([]string{"0x1", "0x2", "0x3"}, []string{"0x2", "0x4"}))
+	utils.Equals(t, 0, len(staleLabels([]string{"0x1"}, []string{"0x1", "0x2"})))
+	utils.Equals(t, 0, len(staleLabels(nil, []string{"0x1"})))
+}
+
+func TestPlanInheritedLabelChange(t *testing.T) {
+	podLabels := map[string]*Label{
+		"app":  {Key: "app", Value: "web"},
+		"team": {Key: "team", Value: "shop"},
+		"env":  {Key: "env", Value: "staging"},
+		"cost": {Key: "cost", Value: "cc-1"},
+	}
+	tests := []struct {
+		name    string
+		old     map[string]string
+		new     map[string]string
+		removed []string
+		added   map[string]string
+	}{
+		{"unchanged", map[string]string{"team": "shop"}, map[string]string{"team": "shop"}, nil, map[string]string{}},
+		{"changed value", map[string]string{"team": "shop"}, map[string]string{"team": "store"}, []string{"team"},
+			map[string]string{"team": "store"}},
+		{"removed label", map[string]string{"team": "shop", "cost": "cc-1"}, map[string]string{"team": "shop"},
+			[]string{"cost"}, map[string]string{}},
+		{"new label", map[string]string{}, map[string]string{"tier": "gold"}, nil, map[string]string{"tier": "gold"}},
+		{"label set by the pod is kept", map[string]string{"env": "prod"}, map[string]string{"env": "dev"}, nil,
+			map[string]string{}},
+		{"label not inherited before set by the pod is kept", map[string]string{}, map[string]string{"app": "api"}, nil,
+			map[string]string{}},
+	}
+	for _, test := range tests {
+		removed, added := planInheritedLabelChange(podLabels, test.old, test.new)
+		utils.Assert(t, len(removed) == len(test.removed), "%s: removed %v", test.name, removed)
+		for i := range removed {
+			utils.Equals(t, test.removed[i], removed[i])
+		}
+		utils.Equals(t, test.added, added)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Dgraph Model Constants
+const (
+	IsNamespaceArchive = "isNamespaceArchive"
+)
+
+// NamespaceArchive schema in dgraph. It freezes the cost of a deleted namespace over its whole lifetime.
+// It has no end time so that it is kept when the resources of the namespace are purged.
+type NamespaceArchive struct {
+	dgraph.ID
+	IsNamespaceArchive bool            `json:"isNamespaceArchive,omitempty"`
+	Name               string          `json:"namespaceName,omitempty"`
+	LifetimeStart      string          `json:"lifetimeStart,omitempty"`
+	LifetimeEnd        string          `json:"lifetimeEnd,omitempty"`
+	CPUHours           utils.CoreHours `json:"cpuHours"`
+	MemoryGBHours      utils.GBHours   `json:"memoryGBHours"`
+	StorageGBHours     utils.GBHours   `json:"storageGBHours"`
+	CPUCost            float64         `json:"cpuCost"`
+	MemoryCost         float64         `json:"memoryCost"`
+	StorageCost        float64         `json:"storageCost"`
+	GPUHours           float64         `json:"gpuHours"`
+	GPUCost            float64         `json:"gpuCost"`
+	TotalCost          float64         `json:"totalCost"`
+	PriceVersion       string          `json:"priceVersion,omitempty"`
+	ArchivedAt         string          `json:"archivedAt,omitempty"`
+	Type               string          `json:"type,omitempty"`
+}
+
+// GetNamespaceArchiveXID returns the xid of the archive of the namespace deleted at the given time. A namespace
+// created again with the same name gets another archive once it is deleted.
+func GetNamespaceArchiveXID(namespace string, endTime time.Time) string {
+	return "archive-" + namespace + "-" + endTime.Format(time.RFC3339)
+}
+
+// StoreNamespaceArchive creates the archive in the Dgraph or overwrites it if already present
+func StoreNamespaceArchive(archive NamespaceArchive) (string, error) {
+	uid := dgraph.GetUID(archive.Xid, IsNamespaceArchive)
+	archive.IsNamespaceArchive = true
+	archive.Type = "namespacearchive"
+	archive.ArchivedAt = time.Now().Format(time.RFC3339)
+	if uid != "" {
+		archive.UID = uid
+	}
+
+	assigned, err := dgraph.MutateNode(archive, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	if uid == "" {
+		log.Infof("Archive of namespace: (%s) persisted", archive.Name)
+		uid = assigned.Uids["blank-0"]
+	}
+	return uid, nil
+}
+
+// resourceEnd sets the end time of a resource
+type resourceEnd struct {
+	dgraph.ID
+	EndTime string `json:"endTime"`
+}
+
+// CloseNamespaceResources sets the end time of the resources of the namespace (and their containers)
+// which are still open. It returns the number of resources closed.
+func CloseNamespaceResources(namespace string, endTime time.Time) (int, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			resources as ~namespace @filter(has(startTime) AND NOT has(endTime)) {
+				containers as containers @filter(NOT has(endTime))
+			}
+		}
+		open(func: uid(resources, containers)) @filter(NOT has(endTime)) {
+			uid
+		}
+	}`
+
+	type root struct {
+		Open []resourceEnd `json:"open"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil || len(newRoot.Open) == 0 {
+		return 0, err
+	}
+	for i := range newRoot.Open {
+		newRoot.Open[i].EndTime = endTime.Format(time.RFC3339)
+	}
+	_, err = dgraph.MutateNode(newRoot.Open, dgraph.UPDATE)
+	if err != nil {
+		return 0, err
+	}
+	return len(newRoot.Open), nil
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsNetworkPolicy = "isNetworkPolicy"
+)
+
+// NetworkPolicy schema in dgraph. Spec is the json of the spec of the policy and PodSelector the selector of the
+// pods it applies to.
+type NetworkPolicy struct {
+	dgraph.ID
+	IsNetworkPolicy bool       `json:"isNetworkPolicy,omitempty"`
+	Name            string     `json:"name,omitempty"`
+	StartTime       string     `json:"startTime,omitempty"`
+	EndTime         string     `json:"endTime,omitempty"`
+	Namespace       *Namespace `json:"namespace,omitempty"`
+	Type            string     `json:"type,omitempty"`
+	PodSelector     string     `json:"podSelector,omitempty"`
+	PolicyTypes     string     `json:"policyTypes,omitempty"`
+	Spec            string     `json:"spec,omitempty"`
+}
+
+// StoreNetworkPolicy creates the network policy in the Dgraph or updates it if already present
+func StoreNetworkPolicy(policy networking_v1.NetworkPolicy) error {
+	xid := policy.Namespace + ":" + policy.Name
+	uid := dgraph.GetUID(xid, IsNetworkPolicy)
+
+	spec, err := json.Marshal(policy.Spec)
+	if err != nil {
+		return err
+	}
+	policyTypes := make([]string, 0, len(policy.Spec.PolicyTypes))
+	for _, policyType := range policy.Spec.PolicyTypes {
+		policyTypes = append(policyTypes, string(policyType))
+	}
+	newPolicy := NetworkPolicy{
+		ID:              dgraph.ID{Xid: xid, UID: uid},
+		IsNetworkPolicy: true,
+		Name:            "networkpolicy-" + policy.Name,
+		Type:            "networkpolicy",
+		StartTime:       policy.GetCreationTimestamp().Time.Format(time.RFC3339),
+		PodSelector:     meta_v1.FormatLabelSelector(&policy.Spec.PodSelector),
+		PolicyTypes:     strings.Join(policyTypes, ","),
+		Spec:            string(spec),
+	}
+	deletionTimestamp := policy.GetDeletionTimestamp()
+	if !deletionTimestamp.IsZero() {
+		newPolicy.EndTime = deletionTimestamp.Time.Format(time.RFC3339)
+	}
+	if uid == "" {
+		namespaceUID := CreateOrGetNamespaceByID(policy.Namespace)
+		if namespaceUID != "" {
+			newPolicy.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: policy.Namespace}}
+		}
+	}
+
+	assigned, err := dgraph.MutateNode(newPolicy, dgraph.CREATE)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		log.Infof("NetworkPolicy with xid: (%s) persisted, uid: (%s)", xid, assigned.Uids["blank-0"])
+	}
+	return nil
+}
+
+// CloseDeletedNetworkPolicies sets the end time of the network policies which are open in the Dgraph but no longer
+// present in the cluster. Only policies of namespaces for which owns returns true are closed.
+// It returns the number of policies closed.
+func CloseDeletedNetworkPolicies(present map[string]bool, owns func(namespace string) bool, endTime time.Time) (int, error) {
+	query := `{
+		policies(func: has(isNetworkPolicy)) @filter(NOT has(endTime)) {
+			uid
+			xid
+			namespace {
+				xid
+			}
+		}
+	}`
+	type root struct {
+		Policies []NetworkPolicy `json:"policies"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(query, &newRoot); err != nil {
+		return 0, err
+	}
+
+	closed := []NetworkPolicy{}
+	for _, policy := range newRoot.Policies {
+		if present[policy.Xid] || policy.Namespace == nil || !owns(policy.Namespace.Xid) {
+			continue
+		}
+		closed = append(closed, NetworkPolicy{
+			ID:      dgraph.ID{UID: policy.UID, Xid: policy.Xid},
+			EndTime: endTime.Format(time.RFC3339),
+		})
+	}
+	if len(closed) == 0 {
+		return 0, nil
+	}
+	if _, err := dgraph.MutateNode(closed, dgraph.UPDATE); err != nil {
+		return 0, err
+	}
+	return len(closed), nil
+}
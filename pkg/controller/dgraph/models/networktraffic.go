@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsNetworkTraffic = "isNetworkTraffic"
+	IsFlowLogCursor  = "isFlowLogCursor"
+)
+
+// NetworkTraffic schema in dgraph, it is the traffic of a pod in an hour as seen by a source outside the cluster
+// like cloud VPC flow logs. Sent bytes are split by where they went since the providers price them differently.
+type NetworkTraffic struct {
+	dgraph.ID
+	IsNetworkTraffic bool       `json:"isNetworkTraffic,omitempty"`
+	StartTime        string     `json:"startTime,omitempty"`
+	Pod              *Pod       `json:"trafficPod,omitempty"`
+	Namespace        *Namespace `json:"namespace,omitempty"`
+	Source           string     `json:"trafficSource,omitempty"`
+	BytesSameZone    float64    `json:"bytesSameZone"`
+	BytesCrossZone   float64    `json:"bytesCrossZone"`
+	BytesInterRegion float64    `json:"bytesInterRegion"`
+	BytesPrivate     float64    `json:"bytesPrivate"`
+	BytesInternet    float64    `json:"bytesInternet"`
+	BytesReceived    float64    `json:"bytesReceived"`
+	// BytesFromInternet are the bytes of BytesReceived which came from the internet
+	BytesFromInternet float64 `json:"bytesFromInternet"`
+}
+
+// FlowLogCursor schema in dgraph, it records the last flow log file which was ingested from a source
+type FlowLogCursor struct {
+	dgraph.ID
+	IsFlowLogCursor bool   `json:"isFlowLogCursor,omitempty"`
+	LastKey         string `json:"lastKey,omitempty"`
+}
+
+// AddNetworkTraffic adds the traffic to the traffic of the pod in the hour starting at the given time
+func AddNetworkTraffic(podXID string, hour time.Time, traffic NetworkTraffic) error {
+	podUID := dgraph.GetUID(podXID, IsPod)
+	if podUID == "" {
+		return fmt.Errorf("pod: %s is not persisted yet", podXID)
+	}
+
+	xid := fmt.Sprintf("%s:traffic:%d", podXID, hour.Unix())
+	stored, err := retrieveNetworkTraffic(xid)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		traffic.UID = stored.UID
+		traffic.BytesSameZone += stored.BytesSameZone
+		traffic.BytesCrossZone += stored.BytesCrossZone
+		traffic.BytesInterRegion += stored.BytesInterRegion
+		traffic.BytesPrivate += stored.BytesPrivate
+		traffic.BytesInternet += stored.BytesInternet
+		traffic.BytesReceived += stored.BytesReceived
+		traffic.BytesFromInternet += stored.BytesFromInternet
+	}
+	traffic.Xid = xid
+	traffic.IsNetworkTraffic = true
+	traffic.StartTime = hour.Format(time.RFC3339)
+	traffic.Pod = &Pod{ID: dgraph.ID{UID: podUID, Xid: podXID}}
+	if namespace := strings.SplitN(podXID, ":", 2)[0]; namespace != podXID {
+		if namespaceUID := CreateOrGetNamespaceByID(namespace); namespaceUID != "" {
+			traffic.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: namespace}}
+		}
+	}
+	_, err = dgraph.MutateNode(traffic, dgraph.CREATE)
+	return err
+}
+
+func retrieveNetworkTraffic(xid string) (*NetworkTraffic, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		traffic(func: ` + builder.Eq("xid", xid) + `) @filter(has(isNetworkTraffic)) {
+			uid
+			bytesSameZone
+			bytesCrossZone
+			bytesInterRegion
+			bytesPrivate
+			bytesInternet
+			bytesReceived
+			bytesFromInternet
+		}
+	}`
+
+	type root struct {
+		Traffic []NetworkTraffic `json:"traffic"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	if len(newRoot.Traffic) == 0 {
+		return nil, nil
+	}
+	return &newRoot.Traffic[0], nil
+}
+
+// RetrieveFlowLogCursor returns the key of the last flow log file ingested from the source, empty if none was
+func RetrieveFlowLogCursor(source string) (string, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		cursor(func: ` + builder.Eq("xid", source+":flowlogs") + `) @filter(has(isFlowLogCursor)) {
+			lastKey
+		}
+	}`
+
+	type root struct {
+		Cursor []FlowLogCursor `json:"cursor"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return "", err
+	}
+	if len(newRoot.Cursor) == 0 {
+		return "", nil
+	}
+	return newRoot.Cursor[0].LastKey, nil
+}
+
+// StoreFlowLogCursor records the key of the last flow log file ingested from the source
+func StoreFlowLogCursor(source, lastKey string) error {
+	xid := source + ":flowlogs"
+	cursor := FlowLogCursor{
+		ID:              dgraph.ID{Xid: xid, UID: dgraph.GetUID(xid, IsFlowLogCursor)},
+		IsFlowLogCursor: true,
+		LastKey:         lastKey,
+	}
+	_, err := dgraph.MutateNode(cursor, dgraph.UPDATE)
+	return err
+}
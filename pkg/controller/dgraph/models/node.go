@@ -24,6 +24,7 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
 	"github.com/vmware/purser/pkg/controller/utils"
 	api_v1 "k8s.io/api/core/v1"
 )
@@ -33,17 +34,44 @@ const (
 	IsNode = "isNode"
 )
 
+// Well known node labels, the stable label comes first
+var (
+	instanceTypeLabels = []string{"node.kubernetes.io/instance-type", "beta.kubernetes.io/instance-type"}
+	regionLabels       = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+	zoneLabels         = []string{"topology.kubernetes.io/zone", "failure-domain.beta.kubernetes.io/zone"}
+	nodePoolLabels     = []string{"cloud.google.com/gke-nodepool", "eks.amazonaws.com/nodegroup", "kubernetes.azure.com/agentpool",
+		"agentpool", "kops.k8s.io/instancegroup"}
+	// labels whose value tells whether the node is a spot (or preemptible) vm, with the values meaning spot
+	spotLabels = map[string][]string{
+		"eks.amazonaws.com/capacityType":        {"SPOT"},
+		"karpenter.sh/capacity-type":            {"spot"},
+		"cloud.google.com/gke-preemptible":      {"true"},
+		"cloud.google.com/gke-spot":             {"true"},
+		"kubernetes.azure.com/scalesetpriority": {"spot"},
+		"node.kubernetes.io/lifecycle":          {"spot"},
+		"lifecycle":                             {"Ec2Spot", "spot"},
+	}
+)
+
 // Node schema in dgraph
 type Node struct {
 	dgraph.ID
-	IsNode         bool    `json:"isNode,omitempty"`
-	Name           string  `json:"name,omitempty"`
-	StartTime      string  `json:"startTime,omitempty"`
-	EndTime        string  `json:"endTime,omitempty"`
-	Pods           []*Pod  `json:"pods,omitempty"`
-	CPUCapity      float64 `json:"cpuCapacity,omitempty"`
-	MemoryCapacity float64 `json:"memoryCapacity,omitempty"`
-	Type           string  `json:"type,omitempty"`
+	IsNode         bool        `json:"isNode,omitempty"`
+	Name           string      `json:"name,omitempty"`
+	StartTime      string      `json:"startTime,omitempty"`
+	EndTime        string      `json:"endTime,omitempty"`
+	Pods           []*Pod      `json:"pods,omitempty"`
+	CPUCapity      utils.Cores `json:"cpuCapacity,omitempty"`
+	MemoryCapacity utils.GB    `json:"memoryCapacity,omitempty"`
+	GPUCapacity    float64     `json:"gpuCapacity,omitempty"`
+	InstanceType   string      `json:"instanceType,omitempty"`
+	Region         string      `json:"region,omitempty"`
+	Zone           string      `json:"zone,omitempty"`
+	NodePool       string      `json:"nodePool,omitempty"`
+	Type           string      `json:"type,omitempty"`
+	CapacityType   string      `json:"capacityType,omitempty"`
+	PricePerHour   float64     `json:"pricePerHour,omitempty"`
+	NodePremium    float64     `json:"nodePremium,omitempty"`
 }
 
 func createNodeObject(node api_v1.Node) Node {
@@ -53,9 +81,18 @@ func createNodeObject(node api_v1.Node) Node {
 		Type:           "node",
 		ID:             dgraph.ID{Xid: node.Name},
 		StartTime:      node.GetCreationTimestamp().Time.Format(time.RFC3339),
-		CPUCapity:      utils.ConvertToFloat64CPU(node.Status.Capacity.Cpu()),
-		MemoryCapacity: utils.ConvertToFloat64GB(node.Status.Capacity.Memory()),
+		CPUCapity:      utils.CPUCores(*node.Status.Capacity.Cpu()),
+		MemoryCapacity: utils.MemoryGB(*node.Status.Capacity.Memory()),
+		GPUCapacity:    getNodeGPUCapacity(node),
+		InstanceType:   getNodeLabel(node, instanceTypeLabels),
+		Region:         getNodeLabel(node, regionLabels),
+		Zone:           getNodeLabel(node, zoneLabels),
+		NodePool:       getNodeLabel(node, nodePoolLabels),
+		CapacityType:   getNodeCapacityType(node),
 	}
+	newNode.PricePerHour = getNodePrice(node, newNode.CapacityType == pricing.SpotCapacity)
+	// the cpu and memory costs of the pods placed on the node follow its on-demand or spot price
+	newNode.NodePremium = pricing.PremiumOverList(newNode.PricePerHour, newNode.CPUCapity, newNode.MemoryCapacity)
 	nodeDeletionTimestamp := node.GetDeletionTimestamp()
 	if !nodeDeletionTimestamp.IsZero() {
 		newNode.EndTime = nodeDeletionTimestamp.Time.Format(time.RFC3339)
@@ -63,6 +100,36 @@ func createNodeObject(node api_v1.Node) Node {
 	return newNode
 }
 
+// getNodeLabel returns the value of the first well known label present on the node
+func getNodeLabel(node api_v1.Node, keys []string) string {
+	labels := node.GetLabels()
+	for _, key := range keys {
+		if value, isPresent := labels[key]; isPresent {
+			return value
+		}
+	}
+	return ""
+}
+
+// getNodeCapacityType returns spot if the node is a spot or preemptible vm according to its well known labels,
+// on-demand otherwise
+func getNodeCapacityType(node api_v1.Node) string {
+	labels := node.GetLabels()
+	for key, spotValues := range spotLabels {
+		for _, spotValue := range spotValues {
+			if labels[key] == spotValue {
+				return pricing.SpotCapacity
+			}
+		}
+	}
+	return pricing.OnDemandCapacity
+}
+
+// NodeZone returns the zone of the node from the well known zone labels, empty if it has none
+func NodeZone(node api_v1.Node) string {
+	return getNodeLabel(node, zoneLabels)
+}
+
 // createOrGetNodeByID create and returns the node if not present, otherwise simply returns node.
 func createOrGetNodeByID(xid string) (string, error) {
 	if xid == "" {
@@ -101,6 +168,9 @@ func StoreNode(node api_v1.Node) (string, error) {
 
 	if uid == "" {
 		log.Infof("Node with xid: (%s) persisted", xid)
+		uid = assigned.Uids["blank-0"]
 	}
+	storeNodePressures(node, uid)
+	storeNodeVersion(node, uid)
 	return assigned.Uids["blank-0"], nil
 }
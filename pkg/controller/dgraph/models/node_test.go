@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+func TestGetNodeCapacityType(t *testing.T) {
+	node := api_v1.Node{}
+	utils.Equals(t, pricing.OnDemandCapacity, getNodeCapacityType(node))
+
+	node.Labels = map[string]string{"eks.amazonaws.com/capacityType": "ON_DEMAND"}
+	utils.Equals(t, pricing.OnDemandCapacity, getNodeCapacityType(node))
+	node.Labels = map[string]string{"eks.amazonaws.com/capacityType": "SPOT"}
+	utils.Equals(t, pricing.SpotCapacity, getNodeCapacityType(node))
+	node.Labels = map[string]string{"cloud.google.com/gke-preemptible": "true"}
+	utils.Equals(t, pricing.SpotCapacity, getNodeCapacityType(node))
+	node.Labels = map[string]string{"kubernetes.azure.com/scalesetpriority": "spot"}
+	utils.Equals(t, pricing.SpotCapacity, getNodeCapacityType(node))
+}
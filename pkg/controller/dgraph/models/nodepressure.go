@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsNodePressure = "isNodePressure"
+)
+
+// PressureConditions are the node conditions which are tracked over time. Pods are evicted from a node under
+// pressure, so their cost moves to other nodes.
+var PressureConditions = []api_v1.NodeConditionType{api_v1.NodeMemoryPressure, api_v1.NodeDiskPressure}
+
+// NodePressure schema in dgraph, it is a time interval during which a pressure condition of the node was true.
+// EndTime is not set while the node is under pressure.
+type NodePressure struct {
+	dgraph.ID
+	IsNodePressure bool   `json:"isNodePressure,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Condition      string `json:"condition,omitempty"`
+	StartTime      string `json:"startTime,omitempty"`
+	EndTime        string `json:"endTime,omitempty"`
+	Node           *Node  `json:"node,omitempty"`
+	Type           string `json:"type,omitempty"`
+}
+
+// HasPressureChanged returns true if the status of a pressure condition differs between the two versions of a node
+func HasPressureChanged(old, new api_v1.Node) bool {
+	for _, conditionType := range PressureConditions {
+		if conditionStatus(old, conditionType) != conditionStatus(new, conditionType) {
+			return true
+		}
+	}
+	return false
+}
+
+func conditionStatus(node api_v1.Node, conditionType api_v1.NodeConditionType) api_v1.ConditionStatus {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return api_v1.ConditionUnknown
+}
+
+// storeNodePressures opens an interval for every pressure condition which became true and closes the open
+// interval of every pressure condition which is not true anymore.
+func storeNodePressures(node api_v1.Node, nodeUID string) {
+	for _, condition := range node.Status.Conditions {
+		if !isPressureCondition(condition.Type) {
+			continue
+		}
+		transitionTime := condition.LastTransitionTime.Time.Format(time.RFC3339)
+		var err error
+		if condition.Status == api_v1.ConditionTrue {
+			err = openNodePressure(node.Name, nodeUID, string(condition.Type), transitionTime)
+		} else {
+			err = closeNodePressures(node.Name, string(condition.Type), transitionTime)
+		}
+		if err != nil {
+			log.Errorf("unable to store %s of node: %s, err: %v", condition.Type, node.Name, err)
+		}
+	}
+	if !node.GetDeletionTimestamp().IsZero() {
+		for _, conditionType := range PressureConditions {
+			err := closeNodePressures(node.Name, string(conditionType), node.GetDeletionTimestamp().Time.Format(time.RFC3339))
+			if err != nil {
+				log.Errorf("unable to close %s of deleted node: %s, err: %v", conditionType, node.Name, err)
+			}
+		}
+	}
+}
+
+func isPressureCondition(conditionType api_v1.NodeConditionType) bool {
+	for _, pressureCondition := range PressureConditions {
+		if conditionType == pressureCondition {
+			return true
+		}
+	}
+	return false
+}
+
+// openNodePressure creates the interval starting at the transition time unless it is already present
+func openNodePressure(nodeName, nodeUID, condition, startTime string) error {
+	xid := nodeName + ":" + condition + ":" + startTime
+	if dgraph.GetUID(xid, IsNodePressure) != "" {
+		return nil
+	}
+	pressure := NodePressure{
+		ID:             dgraph.ID{Xid: xid},
+		IsNodePressure: true,
+		Name:           "pressure-" + nodeName,
+		Type:           "nodepressure",
+		Condition:      condition,
+		StartTime:      startTime,
+	}
+	if nodeUID != "" {
+		pressure.Node = &Node{ID: dgraph.ID{UID: nodeUID, Xid: nodeName}}
+	}
+	_, err := dgraph.MutateNode(pressure, dgraph.CREATE)
+	if err == nil {
+		log.Infof("Node: (%s) is under %s since %s", nodeName, condition, startTime)
+	}
+	return err
+}
+
+// closeNodePressures sets the end time of the open intervals of the condition of the node
+func closeNodePressures(nodeName, condition, endTime string) error {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		var(func: ` + builder.Eq("xid", nodeName) + `) @filter(has(isNode)) {
+			pressures as ~node @filter(has(isNodePressure) AND NOT has(endTime) AND ` + builder.Eq("condition", condition) + `)
+		}
+		pressures(func: uid(pressures)) {
+			uid
+		}
+	}`
+
+	type root struct {
+		Pressures []NodePressure `json:"pressures"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil || len(newRoot.Pressures) == 0 {
+		return err
+	}
+	for i := range newRoot.Pressures {
+		newRoot.Pressures[i].EndTime = endTime
+	}
+	_, err = dgraph.MutateNode(newRoot.Pressures, dgraph.UPDATE)
+	return err
+}
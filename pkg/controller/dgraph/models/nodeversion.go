@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsNodeVersion = "isNodeVersion"
+)
+
+// NodeVersion schema in dgraph, it is a time interval during which a node ran a kubelet version and an OS image.
+// The first interval of a node starts at its creation, the next ones when a change is observed. EndTime is not set
+// for the current versions of the node.
+type NodeVersion struct {
+	dgraph.ID
+	IsNodeVersion          bool   `json:"isNodeVersion,omitempty"`
+	KubeletVersion         string `json:"kubeletVersion,omitempty"`
+	OSImage                string `json:"osImage,omitempty"`
+	KernelVersion          string `json:"kernelVersion,omitempty"`
+	PreviousKubeletVersion string `json:"previousKubeletVersion,omitempty"`
+	PreviousOSImage        string `json:"previousOSImage,omitempty"`
+	StartTime              string `json:"startTime,omitempty"`
+	EndTime                string `json:"endTime,omitempty"`
+	Node                   *Node  `json:"node,omitempty"`
+}
+
+// storeNodeVersion starts a new version interval when the kubelet version or the OS image of the node changed and
+// ends the current interval of a deleted node
+func storeNodeVersion(node api_v1.Node, nodeUID string) {
+	info := node.Status.NodeInfo
+	if info.KubeletVersion == "" {
+		return
+	}
+	current, err := getCurrentNodeVersion(node.Name)
+	if err != nil {
+		log.Errorf("unable to retrieve version of node: %s, err: %v", node.Name, err)
+		return
+	}
+	if deletion := node.GetDeletionTimestamp(); !deletion.IsZero() {
+		if current != nil {
+			current.EndTime = deletion.Time.Format(time.RFC3339)
+			err = updateNodeVersion(current)
+		}
+	} else if current == nil || current.KubeletVersion != info.KubeletVersion || current.OSImage != info.OSImage {
+		err = openNodeVersion(node, nodeUID, current)
+	}
+	if err != nil {
+		log.Errorf("unable to store version of node: %s, err: %v", node.Name, err)
+	}
+}
+
+// openNodeVersion ends the current version interval of the node, if any, and starts the interval of its new versions
+func openNodeVersion(node api_v1.Node, nodeUID string, current *NodeVersion) error {
+	info := node.Status.NodeInfo
+	start := node.GetCreationTimestamp().Time
+	version := NodeVersion{
+		IsNodeVersion:  true,
+		KubeletVersion: info.KubeletVersion,
+		OSImage:        info.OSImage,
+		KernelVersion:  info.KernelVersion,
+	}
+	if current != nil {
+		start = time.Now()
+		current.EndTime = start.Format(time.RFC3339)
+		if err := updateNodeVersion(current); err != nil {
+			return err
+		}
+		version.PreviousKubeletVersion, version.PreviousOSImage = current.KubeletVersion, current.OSImage
+		log.Infof("Node: (%s) upgraded from %s (%s) to %s (%s)", node.Name, current.KubeletVersion, current.OSImage,
+			info.KubeletVersion, info.OSImage)
+	}
+	version.ID = dgraph.ID{Xid: fmt.Sprintf("%s:version:%d", node.Name, start.Unix())}
+	version.StartTime = start.Format(time.RFC3339)
+	if nodeUID != "" {
+		version.Node = &Node{ID: dgraph.ID{UID: nodeUID, Xid: node.Name}}
+	}
+	_, err := dgraph.MutateNode(version, dgraph.CREATE)
+	return err
+}
+
+func updateNodeVersion(version *NodeVersion) error {
+	_, err := dgraph.MutateNode(NodeVersion{ID: dgraph.ID{UID: version.UID}, EndTime: version.EndTime}, dgraph.UPDATE)
+	return err
+}
+
+// getCurrentNodeVersion returns the version interval of the node which is not ended, nil if there is none
+func getCurrentNodeVersion(nodeName string) (*NodeVersion, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		var(func: ` + builder.Eq("xid", nodeName) + `) @filter(has(isNode)) {
+			versions as ~node @filter(has(isNodeVersion) AND NOT has(endTime))
+		}
+		versions(func: uid(versions), orderdesc: startTime, first: 1) {
+			uid
+			kubeletVersion
+			osImage
+			startTime
+		}
+	}`
+
+	type root struct {
+		Versions []NodeVersion `json:"versions"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil || len(newRoot.Versions) == 0 {
+		return nil, err
+	}
+	return &newRoot.Versions[0], nil
+}
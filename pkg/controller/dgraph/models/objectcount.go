@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsObjectCount = "isObjectCount"
+)
+
+// objectCountRetention is the age after which the object counts of namespaces are deleted
+const objectCountRetention = 90 * 24 * time.Hour
+
+// ObjectCount schema in dgraph, it is a sample of the number of objects of a namespace which live only in the
+// control plane. ObjectBytes is the size of their serialized manifests, an estimate of their etcd footprint.
+type ObjectCount struct {
+	dgraph.ID
+	IsObjectCount   bool       `json:"isObjectCount,omitempty"`
+	SampleTime      string     `json:"sampleTime,omitempty"`
+	Namespace       *Namespace `json:"namespace,omitempty"`
+	ConfigMaps      int        `json:"configMaps"`
+	Secrets         int        `json:"secrets"`
+	CustomResources int        `json:"customResources"`
+	ObjectBytes     int        `json:"objectBytes"`
+}
+
+// StoreObjectCount stores the object counts of the namespace sampled at the given time
+func StoreObjectCount(namespace string, count ObjectCount, at time.Time) error {
+	count.ID = dgraph.ID{Xid: fmt.Sprintf("%s:objects:%d", namespace, at.Unix())}
+	count.IsObjectCount = true
+	count.SampleTime = at.Format(time.RFC3339)
+	if namespaceUID := CreateOrGetNamespaceByID(namespace); namespaceUID != "" {
+		count.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: namespace}}
+	}
+	_, err := dgraph.MutateNode(count, dgraph.CREATE)
+	return err
+}
+
+// DeleteExpiredObjectCounts deletes the object counts older than the retention of the namespaces accepted by owns
+// and returns how many were deleted
+func DeleteExpiredObjectCounts(owns func(namespace string) bool, now time.Time) (int, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		counts(func: le(sampleTime, ` + builder.Time(now.Add(-objectCountRetention)) + `)) @filter(has(isObjectCount)) {
+			uid
+			namespace {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Counts []ObjectCount `json:"counts"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return 0, err
+	}
+	var expired []dgraph.ID
+	for _, count := range newRoot.Counts {
+		if count.Namespace != nil && !owns(count.Namespace.Xid) {
+			continue
+		}
+		expired = append(expired, dgraph.ID{UID: count.UID})
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	_, err := dgraph.MutateNode(expired, dgraph.DELETE)
+	return len(expired), err
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsOperator = "isOperator"
+)
+
+// Operator schema in dgraph. It is an operator installed by OLM, identified by its ClusterServiceVersion.
+// Pods of the operator deployments and of the workloads created for its custom resources link to it.
+type Operator struct {
+	dgraph.ID
+	IsOperator   bool   `json:"isOperator,omitempty"`
+	Name         string `json:"name,omitempty"`
+	CSVName      string `json:"csvName,omitempty"`
+	DisplayName  string `json:"displayName,omitempty"`
+	Version      string `json:"version,omitempty"`
+	Package      string `json:"package,omitempty"`
+	Channel      string `json:"channel,omitempty"`
+	Subscription string `json:"subscription,omitempty"`
+	Namespace    string `json:"operatorNamespace,omitempty"`
+	Type         string `json:"type,omitempty"`
+}
+
+// Kinds of the workloads whose pods are attributed to an operator
+const (
+	DeploymentWorkload  = "Deployment"
+	StatefulSetWorkload = "StatefulSet"
+	DaemonSetWorkload   = "DaemonSet"
+)
+
+// Workload is a deployment, statefulset or daemonset, xid is namespace:name
+type Workload struct {
+	Kind string
+	Xid  string
+}
+
+// StoreOperator creates the operator in the Dgraph or updates it if already present, it returns its uid
+func StoreOperator(operator Operator) (string, error) {
+	uid := dgraph.GetUID(operator.Xid, IsOperator)
+	operator.IsOperator = true
+	operator.Name = "operator-" + operator.Xid
+	operator.Type = "operator"
+	if uid != "" {
+		operator.UID = uid
+	}
+
+	assigned, err := dgraph.MutateNode(operator, dgraph.CREATE)
+	if err != nil {
+		return "", err
+	}
+	if uid == "" {
+		log.Infof("Operator with xid: (%s) persisted", operator.Xid)
+		uid = assigned.Uids["blank-0"]
+	}
+	return uid, nil
+}
+
+// LinkOperatorPods links the pods of the given workloads which are not yet linked to an operator.
+// It returns the number of pods linked.
+func LinkOperatorPods(operatorUID string, workloads []Workload) (int, error) {
+	type operatorPod struct {
+		dgraph.ID
+		Operator *Operator `json:"operator,omitempty"`
+	}
+
+	linked := 0
+	for _, workload := range workloads {
+		uids, err := retrieveUnlinkedWorkloadPods(workload)
+		if err != nil {
+			return linked, err
+		}
+		if len(uids) == 0 {
+			continue
+		}
+		pods := []operatorPod{}
+		for _, uid := range uids {
+			pods = append(pods, operatorPod{ID: dgraph.ID{UID: uid}, Operator: &Operator{ID: dgraph.ID{UID: operatorUID}}})
+		}
+		if _, err = dgraph.MutateNode(pods, dgraph.UPDATE); err != nil {
+			return linked, err
+		}
+		linked += len(pods)
+	}
+	return linked, nil
+}
+
+func retrieveUnlinkedWorkloadPods(workload Workload) ([]string, error) {
+	builder := dgraph.NewQueryBuilder()
+	selector := builder.Eq("xid", workload.Xid)
+	var podsBlock string
+	switch workload.Kind {
+	case DeploymentWorkload:
+		podsBlock = `var(func: ` + selector + `) @filter(has(isDeployment)) {
+			~deployment @filter(has(isReplicaset)) {
+				workloadPods as ~replicaset @filter(has(isPod) AND NOT has(operator))
+			}
+		}`
+	case StatefulSetWorkload:
+		podsBlock = `var(func: ` + selector + `) @filter(has(isStatefulset)) {
+			workloadPods as ~statefulset @filter(has(isPod) AND NOT has(operator))
+		}`
+	default:
+		podsBlock = `var(func: ` + selector + `) @filter(has(isDaemonset)) {
+			workloadPods as ~daemonset @filter(has(isPod) AND NOT has(operator))
+		}`
+	}
+	query := `{
+		` + podsBlock + `
+		pods(func: uid(workloadPods)) {
+			uid
+		}
+	}`
+
+	type root struct {
+		Pods []dgraph.ID `json:"pods"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, pod := range newRoot.Pods {
+		uids = append(uids, pod.UID)
+	}
+	return uids, nil
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsPodPlacement = "isPodPlacement"
+)
+
+// PodPlacement schema in dgraph. A placement holds the node on which a pod ran from StartTime until EndTime, with
+// the pool, zone, instance type and capacity type (on-demand or spot) of the node at that time. The latest placement of a running pod has no EndTime.
+// Pods get a new placement when they are rescheduled under the same name, as statefulset pods are after a drain.
+type PodPlacement struct {
+	dgraph.ID
+	IsPodPlacement bool   `json:"isPodPlacement,omitempty"`
+	StartTime      string `json:"startTime,omitempty"`
+	EndTime        string `json:"endTime,omitempty"`
+	Node           *Node  `json:"node,omitempty"`
+	NodePool       string `json:"nodePool,omitempty"`
+	Zone           string `json:"zone,omitempty"`
+	InstanceType   string `json:"instanceType,omitempty"`
+	CapacityType   string `json:"capacityType,omitempty"`
+}
+
+// recordPodPlacement starts a new placement of the pod unless its latest placement is still open on the same node.
+// An open placement on another node is ended. The node edge of the pod is set on its first placement if the pod was pending when created.
+func recordPodPlacement(podUID, podXid, nodeName string, at time.Time) error {
+	latest, hasNode, err := getLatestPodPlacement(podUID)
+	if err != nil {
+		return err
+	}
+	isOpen := latest != nil && latest.EndTime == ""
+	if isOpen && latest.Node != nil && latest.Node.Xid == nodeName {
+		return nil
+	}
+	nodeUID, err := createOrGetNodeByID(nodeName)
+	if err != nil {
+		return err
+	}
+	node, err := getNodeTopology(nodeUID)
+	if err != nil {
+		return err
+	}
+
+	startTime := at.Format(time.RFC3339)
+	if isOpen {
+		if _, err = dgraph.MutateNode(PodPlacement{ID: dgraph.ID{UID: latest.UID}, EndTime: startTime}, dgraph.UPDATE); err != nil {
+			return err
+		}
+	}
+	placement := PodPlacement{
+		ID:             dgraph.ID{Xid: fmt.Sprintf("%s:%s:%d", podXid, nodeName, at.Unix())},
+		IsPodPlacement: true,
+		StartTime:      startTime,
+		Node:           &Node{ID: dgraph.ID{UID: nodeUID, Xid: nodeName}},
+		NodePool:       node.NodePool,
+		Zone:           node.Zone,
+		InstanceType:   node.InstanceType,
+		CapacityType:   node.CapacityType,
+	}
+	// the pod is charged the premium (or discount) of the on-demand or spot price of its node over the list price
+	pod := Pod{
+		ID:          dgraph.ID{UID: podUID, Xid: podXid},
+		Placements:  []*PodPlacement{&placement},
+		NodePremium: node.NodePremium,
+	}
+	if !hasNode {
+		pod.Node = placement.Node
+	}
+	_, err = dgraph.MutateNode(pod, dgraph.UPDATE)
+	return err
+}
+
+// endPodPlacement ends the latest placement of a terminated pod
+func endPodPlacement(podUID string, at time.Time) error {
+	latest, _, err := getLatestPodPlacement(podUID)
+	if err != nil || latest == nil || latest.EndTime != "" {
+		return err
+	}
+	_, err = dgraph.MutateNode(PodPlacement{ID: dgraph.ID{UID: latest.UID}, EndTime: at.Format(time.RFC3339)}, dgraph.UPDATE)
+	return err
+}
+
+// getLatestPodPlacement returns the latest placement of the pod, nil if it has none, and whether the pod has a node
+func getLatestPodPlacement(podUID string) (*PodPlacement, bool, error) {
+	q := `query {
+		pods(func: uid(` + podUID + `)) {
+			node {
+				uid
+			}
+			placement(orderdesc: startTime, first: 1) {
+				uid
+				startTime
+				endTime
+				node {
+					xid
+				}
+			}
+		}
+	}`
+
+	type root struct {
+		Pods []Pod `json:"pods"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil {
+		return nil, false, err
+	}
+	if len(newRoot.Pods) == 0 {
+		return nil, false, nil
+	}
+	pod := newRoot.Pods[0]
+	if len(pod.Placements) == 0 {
+		return nil, pod.Node != nil, nil
+	}
+	return pod.Placements[0], pod.Node != nil, nil
+}
+
+func getNodeTopology(nodeUID string) (Node, error) {
+	q := `query {
+		nodes(func: uid(` + nodeUID + `)) {
+			nodePool
+			zone
+			instanceType
+			capacityType
+			nodePremium
+		}
+	}`
+
+	type root struct {
+		Nodes []Node `json:"nodes"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(q, &newRoot); err != nil || len(newRoot.Nodes) == 0 {
+		return Node{}, err
+	}
+	return newRoot.Nodes[0], nil
+}
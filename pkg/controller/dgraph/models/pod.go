@@ -25,6 +25,7 @@ import (
 
 	"github.com/dgraph-io/dgo/protos/api"
 	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
 
 	api_v1 "k8s.io/api/core/v1"
 )
@@ -44,6 +45,7 @@ type Pod struct {
 	Containers     []*Container             `json:"containers,omitempty"`
 	Pods           []*Pod                   `json:"pod,omitempty"`
 	Count          float64                  `json:"pod|count,omitempty"`
+	Services       []*Service               `json:"externalService,omitempty"`
 	Node           *Node                    `json:"node,omitempty"`
 	Namespace      *Namespace               `json:"namespace,omitempty"`
 	Deployment     *Deployment              `json:"deployment,omitempty"`
@@ -52,22 +54,42 @@ type Pod struct {
 	Daemonset      *Daemonset               `json:"daemonset,omitempty"`
 	Job            *Job                     `json:"job,omitempty"`
 	Pvcs           []*PersistentVolumeClaim `json:"pvc,omitempty"`
-	CPURequest     float64                  `json:"cpuRequest,omitempty"`
-	CPULimit       float64                  `json:"cpuLimit,omitempty"`
-	MemoryRequest  float64                  `json:"memoryRequest,omitempty"`
-	MemoryLimit    float64                  `json:"memoryLimit,omitempty"`
-	StorageRequest float64                  `json:"storageRequest,omitempty"`
+	CPURequest     utils.Cores              `json:"cpuRequest,omitempty"`
+	CPULimit       utils.Cores              `json:"cpuLimit,omitempty"`
+	MemoryRequest  utils.GB                 `json:"memoryRequest,omitempty"`
+	MemoryLimit    utils.GB                 `json:"memoryLimit,omitempty"`
+	CPUOverhead    utils.Cores              `json:"cpuOverhead,omitempty"`
+	MemoryOverhead utils.GB                 `json:"memoryOverhead,omitempty"`
+	GPURequest     float64                  `json:"gpuRequest,omitempty"`
+	GPULimit       float64                  `json:"gpuLimit,omitempty"`
+	StorageRequest utils.GB                 `json:"storageRequest,omitempty"`
 	Type           string                   `json:"type,omitempty"`
 	Cid            []Service                `json:"cid,omitempty"`
 	Labels         []*Label                 `json:"label,omitempty"`
+	Environment    *Environment             `json:"environment,omitempty"`
+	Application    *Application             `json:"application,omitempty"`
+	HelmRelease    *HelmRelease             `json:"helmRelease,omitempty"`
+	HelmChart      string                   `json:"helmChart,omitempty"`
+	CostCenter     string                   `json:"costCenter,omitempty"`
+	Placements     []*PodPlacement          `json:"placement,omitempty"`
+	NodePremium    float64                  `json:"nodePremium,omitempty"`
+
+	// synthetic pods aggregate short lived pods of a namespace
+	IsSynthetic       bool            `json:"isSynthetic,omitempty"`
+	SyntheticPodCount int             `json:"syntheticPodCount,omitempty"`
+	CPUHours          utils.CoreHours `json:"cpuHours,omitempty"`
+	MemoryGBHours     utils.GBHours   `json:"memoryGBHours,omitempty"`
+	GPUHours          float64         `json:"gpuHours,omitempty"`
 }
 
 // Metrics ...
 type Metrics struct {
-	CPURequest    float64
-	CPULimit      float64
-	MemoryRequest float64
-	MemoryLimit   float64
+	CPURequest    utils.Cores
+	CPULimit      utils.Cores
+	MemoryRequest utils.GB
+	MemoryLimit   utils.GB
+	GPURequest    float64
+	GPULimit      float64
 }
 
 // newPod creates a new node for the pod in the Dgraph
@@ -99,6 +121,7 @@ func StorePod(k8sPod api_v1.Pod) error {
 	uid := dgraph.GetUID(xid, IsPod)
 
 	var pod Pod
+	isMetricsWritten := false
 	if uid == "" {
 		assigned, err := newPod(k8sPod)
 		if err != nil {
@@ -115,22 +138,55 @@ func StorePod(k8sPod api_v1.Pod) error {
 			EndTime: podDeletedTimestamp.Time.Format(time.RFC3339),
 		}
 		deleteContainersInTerminatedPod(pod.Containers, podDeletedTimestamp.Time)
+		forgetPodMetrics(xid)
+		endContainerSamples(uid, podDeletedTimestamp.Time)
+		forgetLabelEdges(uid)
+		closeEphemeralContainers(uid, podDeletedTimestamp.Time)
+		if err := endPodPlacement(uid, podDeletedTimestamp.Time); err != nil {
+			log.Errorf("unable to end placement of pod: (%s), error: (%v)", xid, err)
+		}
 	} else {
 		namespaceUID := CreateOrGetNamespaceByID(k8sPod.Namespace)
 		containers, metrics := StoreAndRetrieveContainersAndMetrics(k8sPod, uid, namespaceUID)
 		pod = Pod{
-			ID:            dgraph.ID{Xid: xid, UID: uid},
-			Containers:    containers,
-			CPURequest:    metrics.CPURequest,
-			CPULimit:      metrics.CPULimit,
-			MemoryRequest: metrics.MemoryRequest,
-			MemoryLimit:   metrics.MemoryLimit,
+			ID:         dgraph.ID{Xid: xid, UID: uid},
+			Containers: containers,
+		}
+		// requests and limits are rewritten only when they changed beyond the threshold
+		if isPodMetricsChanged(xid, metrics) {
+			isMetricsWritten = true
+			pod.CPURequest, pod.CPULimit = metrics.CPURequest, metrics.CPULimit
+			pod.MemoryRequest, pod.MemoryLimit = metrics.MemoryRequest, metrics.MemoryLimit
+			pod.GPURequest, pod.GPULimit = metrics.GPURequest, metrics.GPULimit
+			addPodOverhead(&pod, xid)
+		}
+		podLabels := mergeInheritedLabels(k8sPod.Labels, getInheritedLabels(namespaceUID))
+		populatePodLabels(&pod, podLabels)
+		replaceLabelEdges(uid, pod.Labels)
+		pod.Environment = getEnvironment(k8sPod.Namespace, podLabels)
+		pod.Application = getApplication(podLabels)
+		pod.HelmRelease, pod.HelmChart = getHelmRelease(k8sPod.Namespace, k8sPod.Labels, k8sPod.Annotations)
+		pod.CostCenter = getCostCenter(k8sPod.Annotations, podLabels)
+		if k8sPod.Spec.NodeName != "" {
+			if err := recordPodPlacement(uid, xid, k8sPod.Spec.NodeName, podPlacementTime(k8sPod)); err != nil {
+				log.Errorf("unable to record placement of pod: (%s), error: (%v)", xid, err)
+			}
 		}
-		populatePodLabels(&pod, k8sPod.Labels)
 	}
 
-	_, err := dgraph.MutateNode(pod, dgraph.UPDATE)
-	return err
+	// the pod has its uid, its update is written with the next batch
+	if isMetricsWritten {
+		return dgraph.QueueMutationWithCallback(pod, dgraph.UPDATE, forgetPodMetricsOnError(xid))
+	}
+	return dgraph.QueueMutation(pod, dgraph.UPDATE)
+}
+
+// podPlacementTime returns the time at which the pod started on its node, now if it is not started yet
+func podPlacementTime(k8sPod api_v1.Pod) time.Time {
+	if k8sPod.Status.StartTime != nil {
+		return k8sPod.Status.StartTime.Time
+	}
+	return time.Now()
 }
 
 // StorePodsInteraction store the pod interactions in Dgraph
@@ -150,6 +206,31 @@ func StorePodsInteraction(sourcePodXID string, destinationPodsXIDs []string, cou
 	return err
 }
 
+// StorePodServiceInteractions stores the interactions of the pod with services which have no pods to resolve the
+// interactions to, like ExternalName services
+func StorePodServiceInteractions(sourcePodXID string, servicesXIDs []string, counts []float64) error {
+	uid := dgraph.GetUID(sourcePodXID, IsPod)
+	if uid == "" {
+		return fmt.Errorf("source pod: %s is not persisted yet", sourcePodXID)
+	}
+
+	services := []*Service{}
+	for index, serviceXID := range servicesXIDs {
+		serviceUID := dgraph.GetUID(serviceXID, IsService)
+		if serviceUID == "" {
+			log.Debugf("Destination service: %s is not persisted yet", serviceXID)
+			continue
+		}
+		services = append(services, &Service{ID: dgraph.ID{UID: serviceUID, Xid: serviceXID}, Count: counts[index]})
+	}
+	source := Pod{
+		ID:       dgraph.ID{UID: uid, Xid: sourcePodXID},
+		Services: services,
+	}
+	_, err := dgraph.MutateNode(source, dgraph.UPDATE)
+	return err
+}
+
 func retrievePodsFromPodsXIDs(podsXIDs []string) []*Pod {
 	pods := []*Pod{}
 	for _, podXID := range podsXIDs {
@@ -227,9 +308,9 @@ func setPodOwners(pod *Pod, k8sPod api_v1.Pod) {
 	}
 }
 
-func getPodVolumes(k8sPod api_v1.Pod) ([]*PersistentVolumeClaim, float64) {
+func getPodVolumes(k8sPod api_v1.Pod) ([]*PersistentVolumeClaim, utils.GB) {
 	podVolumes := []*PersistentVolumeClaim{}
-	storage := 0.0
+	var storage utils.GB
 	for j := 0; j < len(k8sPod.Spec.Volumes); j++ {
 		vol := k8sPod.Spec.Volumes[j]
 		if vol.PersistentVolumeClaim != nil {
@@ -251,9 +332,5 @@ func getPodVolumes(k8sPod api_v1.Pod) ([]*PersistentVolumeClaim, float64) {
 
 func populatePodLabels(pod *Pod, podLabels map[string]string) {
 	log.Debugf("k8s pod: (%v), labels: (%v)", pod.Name, podLabels)
-	var labels []*Label
-	for key, value := range podLabels {
-		labels = append(labels, GetLabel(key, value))
-	}
-	pod.Labels = labels
+	pod.Labels = GetLabels(podLabels)
 }
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// podUsage is the average cpu (cores) and memory (GB) usage of a pod over the samples of the metrics api during its
+// life, charged by the usage based allocation policies
+type podUsage struct {
+	dgraph.ID
+	CPUUsage     utils.Cores `json:"cpuUsage"`
+	MemoryUsage  utils.GB    `json:"memoryUsage"`
+	UsageSamples int         `json:"usageSamples"`
+}
+
+// RecordPodUsage adds a sample of the cpu (cores) and memory (GB) usage, keyed by pod xid (namespace:name), to the
+// average usage of the running pods of the namespaces for which owns returns true. It returns the number of pods
+// sampled.
+func RecordPodUsage(podCPU map[string]utils.Cores, podMemory map[string]utils.GB, owns func(namespace string) bool) (int, error) {
+	query := `{
+		pods(func: has(isPod)) @filter(NOT has(endTime)) {
+			uid
+			xid
+			cpuUsage
+			memoryUsage
+			usageSamples
+		}
+	}`
+	type root struct {
+		Pods []podUsage `json:"pods"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(query, &newRoot); err != nil {
+		return 0, err
+	}
+
+	updates := []podUsage{}
+	for _, pod := range newRoot.Pods {
+		cpu, hasSample := podCPU[pod.Xid]
+		if !hasSample || !owns(strings.SplitN(pod.Xid, ":", 2)[0]) {
+			continue
+		}
+		updates = append(updates, addUsageSample(pod, cpu, podMemory[pod.Xid]))
+	}
+	if len(updates) == 0 {
+		return 0, nil
+	}
+	_, err := dgraph.MutateNode(updates, dgraph.UPDATE)
+	return len(updates), err
+}
+
+// addUsageSample returns the usage of the pod averaged with the sample
+func addUsageSample(pod podUsage, cpu utils.Cores, memory utils.GB) podUsage {
+	samples := float64(pod.UsageSamples)
+	pod.CPUUsage = (pod.CPUUsage.Over(samples) + cpu.Over(1)).Average(samples + 1)
+	pod.MemoryUsage = (pod.MemoryUsage.Over(samples) + memory.Over(1)).Average(samples + 1)
+	pod.UsageSamples++
+	return pod
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAddUsageSample(t *testing.T) {
+	pod := addUsageSample(podUsage{}, 0.5, 2)
+	utils.Equals(t, podUsage{CPUUsage: 0.5, MemoryUsage: 2, UsageSamples: 1}, pod)
+
+	pod = addUsageSample(pod, 0.25, 1)
+	pod = addUsageSample(pod, 0.75, 3)
+	utils.Equals(t, podUsage{CPUUsage: 0.5, MemoryUsage: 2, UsageSamples: 3}, pod)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"sort"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// Dgraph Model Constants
+const (
+	IsPricePeriod = "isPricePeriod"
+)
+
+// PricePeriod schema in dgraph, it is a price change of the pricing catalog. The history of the prices is kept in
+// Dgraph so that it survives the restarts of the controller and the costs of past windows keep their prices.
+type PricePeriod struct {
+	dgraph.ID
+	IsPricePeriod bool `json:"isPricePeriod,omitempty"`
+	pricing.PricePeriod
+	Type string `json:"type,omitempty"`
+}
+
+// PriceHistoryStore stores the price history of the pricing catalog in Dgraph
+type PriceHistoryStore struct{}
+
+// StorePricePeriod creates the price period in the Dgraph, an existing period is never overwritten
+func (PriceHistoryStore) StorePricePeriod(period pricing.PricePeriod) error {
+	xid := "priceperiod-" + period.EffectiveFrom + "-" + period.Version
+	if uid := dgraph.GetUID(xid, IsPricePeriod); uid != "" {
+		return nil
+	}
+	_, err := dgraph.MutateNode(PricePeriod{ID: dgraph.ID{Xid: xid}, IsPricePeriod: true, PricePeriod: period,
+		Type: "priceperiod"}, dgraph.CREATE)
+	if err != nil {
+		return err
+	}
+	log.Infof("price period effective from: (%s) stored, version: (%s)", period.EffectiveFrom, period.Version)
+	return nil
+}
+
+// LoadPriceHistory returns the price periods stored in Dgraph sorted by effective from time
+func (PriceHistoryStore) LoadPriceHistory() ([]pricing.PricePeriod, error) {
+	query := `{
+		periods(func: has(isPricePeriod)) {
+			effectiveFrom
+			version
+			cpuCostPerCPUPerHour
+			memCostPerGBPerHour
+			storageCostPerGBPerHour
+			gpuCostPerGPUPerHour
+			snapshotCostPerGBPerHour
+			loadBalancerCostPerHour
+			discountsRecorded
+			cpuDiscountFactor
+			memDiscountFactor
+			storageDiscountFactor
+			gpuDiscountFactor
+			snapshotDiscountFactor
+			loadBalancerDiscountFactor
+		}
+	}`
+	type root struct {
+		Periods []PricePeriod `json:"periods"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(query, &newRoot); err != nil {
+		return nil, err
+	}
+	periods := make([]pricing.PricePeriod, len(newRoot.Periods))
+	for i, period := range newRoot.Periods {
+		periods[i] = period.PricePeriod
+	}
+	sort.SliceStable(periods, func(i, j int) bool {
+		return periods[i].EffectiveFrom < periods[j].EffectiveFrom
+	})
+	return periods, nil
+}
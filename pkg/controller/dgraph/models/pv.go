@@ -35,12 +35,12 @@ const (
 // PersistentVolume schema in dgraph
 type PersistentVolume struct {
 	dgraph.ID
-	IsPersistentVolume bool    `json:"isPersistentVolume,omitempty"`
-	Name               string  `json:"name,omitempty"`
-	StartTime          string  `json:"startTime,omitempty"`
-	EndTime            string  `json:"endTime,omitempty"`
-	Type               string  `json:"type,omitempty"`
-	StorageCapacity    float64 `json:"storageCapacity,omitempty"`
+	IsPersistentVolume bool     `json:"isPersistentVolume,omitempty"`
+	Name               string   `json:"name,omitempty"`
+	StartTime          string   `json:"startTime,omitempty"`
+	EndTime            string   `json:"endTime,omitempty"`
+	Type               string   `json:"type,omitempty"`
+	StorageCapacity    utils.GB `json:"storageCapacity,omitempty"`
 }
 
 func createPersistentVolumeObject(pv api_v1.PersistentVolume) PersistentVolume {
@@ -52,7 +52,7 @@ func createPersistentVolumeObject(pv api_v1.PersistentVolume) PersistentVolume {
 		StartTime:          pv.GetCreationTimestamp().Time.Format(time.RFC3339),
 	}
 	capacity := pv.Spec.Capacity["storage"]
-	newPv.StorageCapacity = utils.ConvertToFloat64GB(&capacity)
+	newPv.StorageCapacity = utils.MemoryGB(capacity)
 
 	deletionTimestamp := pv.GetDeletionTimestamp()
 	if !deletionTimestamp.IsZero() {
@@ -96,7 +96,7 @@ func CreateOrGetPersistentVolumeByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
@@ -18,6 +18,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"log"
@@ -41,10 +42,18 @@ type PersistentVolumeClaim struct {
 	EndTime                 string            `json:"endTime,omitempty"`
 	Namespace               *Namespace        `json:"namespace,omitempty"`
 	Type                    string            `json:"type,omitempty"`
-	StorageCapacity         float64           `json:"storageCapacity,omitempty"`
+	StorageCapacity         utils.GB          `json:"storageCapacity,omitempty"`
 	PersistentVolume        *PersistentVolume `json:"pv,omitempty"`
+	StorageUsed             utils.GB          `json:"storageUsed,omitempty"`
+	AverageStorageUsed      utils.GB          `json:"averageStorageUsed,omitempty"`
+	StorageUsageSamples     int               `json:"storageUsageSamples,omitempty"`
+	StorageUsageTime        string            `json:"storageUsageTime,omitempty"`
 }
 
+// storageUsageSamplesAveraged is the number of samples after which the average storage usage of a pvc becomes a
+// moving average, so that it follows the growth of the volume (about 4 days of samples taken every 15 minutes)
+const storageUsageSamplesAveraged = 384
+
 func createPvcObject(pvc api_v1.PersistentVolumeClaim) PersistentVolumeClaim {
 	newPvc := PersistentVolumeClaim{
 		Name:                    "pvc-" + pvc.Name,
@@ -54,7 +63,7 @@ func createPvcObject(pvc api_v1.PersistentVolumeClaim) PersistentVolumeClaim {
 		StartTime:               pvc.GetCreationTimestamp().Time.Format(time.RFC3339),
 	}
 	capacity := pvc.Status.Capacity["storage"]
-	newPvc.StorageCapacity = utils.ConvertToFloat64GB(&capacity)
+	newPvc.StorageCapacity = utils.MemoryGB(capacity)
 
 	volume := pvc.Spec.VolumeName
 	pvUID := CreateOrGetPersistentVolumeByID(volume)
@@ -108,12 +117,54 @@ func CreateOrGetPersistentVolumeClaimByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
 }
 
+// StorePVCStorageUsage records the storage used by the pvc (in GB) as reported by the kubelet at the given time
+func StorePVCStorageUsage(xid string, used utils.GB, at time.Time) error {
+	builder := dgraph.NewQueryBuilder()
+	q := `{
+		pvcs(func: ` + builder.Eq("xid", xid) + `) @filter(has(isPersistentVolumeClaim)) {
+			uid
+			averageStorageUsed
+			storageUsageSamples
+		}
+	}`
+	type root struct {
+		Pvcs []PersistentVolumeClaim `json:"pvcs"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(q, &newRoot); err != nil {
+		return err
+	}
+	if len(newRoot.Pvcs) == 0 {
+		return fmt.Errorf("pvc: %s not found", xid)
+	}
+
+	current := newRoot.Pvcs[0]
+	average, samples := addStorageUsageSample(current.AverageStorageUsed, current.StorageUsageSamples, used)
+	usage := PersistentVolumeClaim{
+		ID:                  dgraph.ID{UID: current.UID},
+		StorageUsed:         used,
+		AverageStorageUsed:  average,
+		StorageUsageSamples: samples,
+		StorageUsageTime:    at.Format(time.RFC3339),
+	}
+	_, err := dgraph.MutateNode(usage, dgraph.UPDATE)
+	return err
+}
+
+// addStorageUsageSample returns the average storage usage and the sample count once the used storage is added
+func addStorageUsageSample(average utils.GB, samples int, used utils.GB) (utils.GB, int) {
+	if samples < storageUsageSamplesAveraged {
+		samples++
+	}
+	return average + (used-average)/utils.GB(samples), samples
+}
+
 func getPVCFromUID(uid string) (PersistentVolumeClaim, error) {
 	q := `query {
 		pvcs(func: uid(` + uid + `)) {
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// AllocationRecord is the allocation of a pod during an hour of a time window with the prices used to charge it.
+// Hours is the part of the hour the pod was alive. CPU, Memory (GB) and Storage (GB) are allocated by the allocation
+// policy, their costs include the premium (or spot discount) of the node for cpu and memory.
+type AllocationRecord struct {
+	Pod          string  `json:"pod"`
+	Namespace    string  `json:"namespace"`
+	Node         string  `json:"node,omitempty"`
+	Hour         string  `json:"hour"`
+	Hours        float64 `json:"hours"`
+	CPU          float64 `json:"cpu"`
+	Memory       float64 `json:"memory"`
+	Storage      float64 `json:"storage"`
+	CPUCost      float64 `json:"cpuCost"`
+	MemoryCost   float64 `json:"memoryCost"`
+	StorageCost  float64 `json:"storageCost"`
+	CPUPrice     float64 `json:"cpuPrice"`
+	MemoryPrice  float64 `json:"memoryPrice"`
+	StoragePrice float64 `json:"storagePrice"`
+	NodePremium  float64 `json:"nodePremium,omitempty"`
+	PriceVersion string  `json:"priceVersion,omitempty"`
+}
+
+// AllocationRecords is a page of allocation records, Next is the cursor of the next page (query param after), empty
+// on the last page
+type AllocationRecords struct {
+	Records []AllocationRecord `json:"records"`
+	Next    string             `json:"next,omitempty"`
+}
+
+// recordPod is a pod with the requests, usage and node premium its allocation records are computed from
+type recordPod struct {
+	UID            string        `json:"uid"`
+	Name           string        `json:"name"`
+	StartTime      string        `json:"startTime"`
+	EndTime        string        `json:"endTime"`
+	Namespace      *ResourceCost `json:"namespace"`
+	Node           *ResourceCost `json:"node"`
+	CPURequest     float64       `json:"cpuRequest"`
+	MemoryRequest  float64       `json:"memoryRequest"`
+	StorageRequest float64       `json:"storageRequest"`
+	CPUUsage       float64       `json:"cpuUsage"`
+	MemoryUsage    float64       `json:"memoryUsage"`
+	UsageSamples   int           `json:"usageSamples"`
+	NodePremium    float64       `json:"nodePremium"`
+}
+
+// ParseRecordCursor returns the pod uid and the hour of the first record of the page of the cursor: <uid>/<unix hour>
+func ParseRecordCursor(cursor string) (string, time.Time, error) {
+	parts := strings.Split(cursor, "/")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "0x") {
+		return "", time.Time{}, fmt.Errorf("cursor is not <pod uid>/<unix hour>")
+	}
+	if _, err := strconv.ParseUint(parts[0][2:], 16, 64); err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid pod uid of cursor: %v", err)
+	}
+	seconds, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid hour of cursor: %v", err)
+	}
+	return parts[0], time.Unix(seconds, 0).UTC(), nil
+}
+
+// RetrieveAllocationRecords returns a page of at most limit allocation records of the pods of the namespace (every
+// namespace if name is empty) for every hour of their life in the window [from, to), ordered by pod and hour. The page
+// starts at the cursor after returned by the previous page, at the first record if it is empty.
+func RetrieveAllocationRecords(name string, from, to time.Time, after string, limit int) (AllocationRecords, error) {
+	page := AllocationRecords{Records: []AllocationRecord{}}
+	afterUID, afterHour := "", time.Time{}
+	if after != "" {
+		var err error
+		if afterUID, afterHour, err = ParseRecordCursor(after); err != nil {
+			return page, err
+		}
+	}
+
+	builder := dgraph.NewReplicaQueryBuilder()
+	fields := `uid
+			name
+			startTime
+			endTime
+			namespace {
+				xid
+			}
+			node {
+				xid
+			}
+			cpuRequest
+			memoryRequest
+			storageRequest
+			cpuUsage
+			memoryUsage
+			usageSamples
+			nodePremium`
+	window := `has(isPod) AND ` + podsInWindowFilter(builder, from, to)
+	pods := `pods(func: has(isPod), first: ` + strconv.Itoa(limit)
+	if name != All {
+		pods = `var(func: ` + builder.Eq("xid", name) + `) @filter(has(isNamespace)) {
+			namespacePods as ~namespace
+		}
+		pods(func: uid(namespacePods), first: ` + strconv.Itoa(limit)
+	}
+	if afterUID != "" {
+		pods += `, after: ` + afterUID
+	}
+	query := `{
+		` + pods + `) @filter(` + window + `) {
+			` + fields + `
+		}`
+	if afterUID != "" {
+		// the pod of the cursor has records left
+		query += `
+		partial(func: uid(` + afterUID + `)) @filter(` + window + `) {
+			` + fields + `
+		}`
+	}
+	query += `
+	}`
+
+	type root struct {
+		Pods    []recordPod `json:"pods"`
+		Partial []recordPod `json:"partial"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return page, err
+	}
+
+	_, policy := allocation.GetPolicy()
+	periods := pricing.GetPriceHistory()
+	for i, pod := range append(newRoot.Partial, newRoot.Pods...) {
+		since := from
+		if i == 0 && len(newRoot.Partial) > 0 {
+			since = afterHour
+		}
+		records, next := podHourRecords(pod, from, to, since, limit-len(page.Records), policy, periods)
+		page.Records = append(page.Records, records...)
+		if !next.IsZero() {
+			page.Next = fmt.Sprintf("%s/%d", pod.UID, next.Unix())
+			return page, nil
+		}
+	}
+	// the next page starts after the last pod when the page could not hold every pod
+	if len(newRoot.Pods) == limit {
+		last := newRoot.Pods[limit-1]
+		page.Next = fmt.Sprintf("%s/%d", last.UID, to.Unix())
+	}
+	return page, nil
+}
+
+// podHourRecords returns at most limit allocation records of the pod for the hours of its life in the window
+// [from, to) starting at since, with the hour of the next record when the limit is reached
+func podHourRecords(pod recordPod, from, to, since time.Time, limit int, policy allocation.Policy,
+	periods []pricing.PricePeriod) ([]AllocationRecord, time.Time) {
+	start, err := time.Parse(time.RFC3339, pod.StartTime)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	end := to
+	if ended, err := time.Parse(time.RFC3339, pod.EndTime); err == nil && ended.Before(end) {
+		end = ended
+	}
+	if start.Before(from) {
+		start = from
+	}
+
+	cpuUsage, memoryUsage := pod.CPURequest, pod.MemoryRequest
+	if pod.UsageSamples > 0 {
+		cpuUsage, memoryUsage = pod.CPUUsage, pod.MemoryUsage
+	}
+	cpu, memory := policy.Allocate(pod.CPURequest, cpuUsage), policy.Allocate(pod.MemoryRequest, memoryUsage)
+	record := AllocationRecord{Pod: pod.Name, CPU: cpu, Memory: memory, Storage: pod.StorageRequest, NodePremium: pod.NodePremium}
+	if pod.Namespace != nil {
+		record.Namespace = pod.Namespace.Xid
+	}
+	if pod.Node != nil {
+		record.Node = pod.Node.Xid
+	}
+
+	var records []AllocationRecord
+	for hour := start.UTC().Truncate(time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		if hour.Before(since.Truncate(time.Hour)) {
+			continue
+		}
+		if len(records) == limit {
+			return records, hour
+		}
+		sliceStart, sliceEnd := hour, hour.Add(time.Hour)
+		if start.After(sliceStart) {
+			sliceStart = start
+		}
+		if end.Before(sliceEnd) {
+			sliceEnd = end
+		}
+		period := priceAt(periods, sliceStart)
+		hourRecord := record
+		hourRecord.Hour = hour.Format(time.RFC3339)
+		hourRecord.Hours = utils.Round(sliceEnd.Sub(sliceStart).Hours(), utils.QuantityPrecision)
+		hourRecord.CPUPrice, hourRecord.MemoryPrice, hourRecord.StoragePrice = period.CPU, period.Memory, period.Storage
+		hourRecord.PriceVersion = period.Version
+		hours := sliceEnd.Sub(sliceStart).Hours()
+		hourRecord.CPUCost = utils.Round(cpu*hours*period.CPU*(1+pod.NodePremium), utils.CostPrecision)
+		hourRecord.MemoryCost = utils.Round(memory*hours*period.Memory*(1+pod.NodePremium), utils.CostPrecision)
+		hourRecord.StorageCost = utils.Round(pod.StorageRequest*hours*period.Storage, utils.CostPrecision)
+		records = append(records, hourRecord)
+	}
+	return records, time.Time{}
+}
+
+// priceAt returns the price period in effect at the given time, the first period is in effect before its effective
+// from time
+func priceAt(periods []pricing.PricePeriod, at time.Time) pricing.PricePeriod {
+	if len(periods) == 0 {
+		return pricing.PricePeriod{}
+	}
+	current := periods[0]
+	for _, period := range periods[1:] {
+		effectiveFrom, err := time.Parse(time.RFC3339, period.EffectiveFrom)
+		if err != nil || effectiveFrom.After(at) {
+			break
+		}
+		current = period
+	}
+	return current
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPodHourRecords(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	periods := []pricing.PricePeriod{
+		{EffectiveFrom: "2018-10-01T00:00:00Z", Version: "v1", CPU: 0.02, Memory: 0.01, Storage: 0.001},
+		{EffectiveFrom: "2018-11-01T02:00:00Z", Version: "v2", CPU: 0.04, Memory: 0.01, Storage: 0.001},
+	}
+	_, policy := allocation.GetPolicy()
+	pod := recordPod{
+		UID:            "0x1a",
+		Name:           "web-1",
+		StartTime:      "2018-11-01T00:30:00Z",
+		EndTime:        "2018-11-01T03:00:00Z",
+		Namespace:      &ResourceCost{Xid: "shop"},
+		Node:           &ResourceCost{Xid: "node-1"},
+		CPURequest:     2,
+		MemoryRequest:  4,
+		StorageRequest: 10,
+		NodePremium:    0.5,
+	}
+
+	// the pod is alive for the second half of the first hour, then the price of cpu doubles
+	records, next := podHourRecords(pod, from, to, from, 10, policy, periods)
+	utils.Equals(t, time.Time{}, next)
+	utils.Equals(t, 3, len(records))
+	utils.Equals(t, AllocationRecord{Pod: "web-1", Namespace: "shop", Node: "node-1", Hour: "2018-11-01T00:00:00Z", Hours: 0.5,
+		CPU: 2, Memory: 4, Storage: 10, CPUCost: 0.03, MemoryCost: 0.03, StorageCost: 0.005, CPUPrice: 0.02, MemoryPrice: 0.01,
+		StoragePrice: 0.001, NodePremium: 0.5, PriceVersion: "v1"}, records[0])
+	utils.Equals(t, "v2", records[2].PriceVersion)
+	utils.Equals(t, 0.12, records[2].CPUCost)
+
+	// a page of 2 records continues at the third hour
+	records, next = podHourRecords(pod, from, to, from, 2, policy, periods)
+	utils.Equals(t, 2, len(records))
+	utils.Equals(t, from.Add(2*time.Hour), next)
+	records, _ = podHourRecords(pod, from, to, next, 2, policy, periods)
+	utils.Equals(t, []string{"2018-11-01T02:00:00Z"}, []string{records[0].Hour})
+	utils.Equals(t, 1, len(records))
+}
+
+func TestParseRecordCursor(t *testing.T) {
+	uid, hour, err := ParseRecordCursor("0x1a/1541037600")
+	utils.Ok(t, err)
+	utils.Equals(t, "0x1a", uid)
+	utils.Equals(t, time.Date(2018, 11, 1, 2, 0, 0, 0, time.UTC), hour)
+
+	for _, cursor := range []string{"0x1a", "1a/1541037600", "0xzz/1541037600", "0x1a/noon"} {
+		_, _, err = ParseRecordCursor(cursor)
+		utils.Assert(t, err != nil, "invalid cursor: "+cursor)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// AmortizationLineItem is the line item of the upfront costs amortized in a namespace cost
+const AmortizationLineItem = "amortized"
+
+// amortizedCost is the amortized part of an upfront cost in a time window, with the resource it is allocated by
+type amortizedCost struct {
+	Resource string
+	Cost     float64
+}
+
+// DistributeAmortizedCosts allocates the part of the configured upfront costs amortized in the window [from, to) to
+// the namespace costs, which must hold all namespaces of the cluster. Nothing is added when no upfront cost is
+// configured.
+func DistributeAmortizedCosts(costs []ResourceCost, from, to time.Time) {
+	upfrontCosts := pricing.GetUpfrontCosts()
+	if len(upfrontCosts) == 0 {
+		return
+	}
+	amortized := []amortizedCost{}
+	for _, u := range upfrontCosts {
+		amortized = append(amortized, amortizedCost{Resource: u.Resource, Cost: u.AmortizedCost(from, to)})
+	}
+	amortize(costs, amortized)
+}
+
+// amortize shares every amortized cost among the namespaces in proportion of their cost of its resource, and adds it
+// to their total cost as the amortized line item. A cost is not allocated when no namespace used its resource.
+func amortize(costs []ResourceCost, amortized []amortizedCost) {
+	shares := make([]float64, len(costs))
+	for _, a := range amortized {
+		weights := 0.0
+		for _, cost := range costs {
+			weights += resourceWeight(cost, a.Resource)
+		}
+		if weights <= 0 {
+			continue
+		}
+		for i, cost := range costs {
+			shares[i] += a.Cost * resourceWeight(cost, a.Resource) / weights
+		}
+	}
+	for i := range costs {
+		costs[i].LineItems = append(costs[i].LineItems, LineItem{Category: AmortizationLineItem, Description: "upfront payments amortized over their term", Cost: shares[i]})
+		costs[i].TotalCost += shares[i]
+	}
+}
+
+func resourceWeight(cost ResourceCost, resource string) float64 {
+	switch resource {
+	case pricing.AmortizeCPU:
+		return cost.CPUCost
+	case pricing.AmortizeMemory:
+		return cost.MemoryCost
+	case pricing.AmortizeGPU:
+		return cost.GPUCost
+	}
+	return cost.CPUCost + cost.MemoryCost + cost.GPUCost
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAmortize(t *testing.T) {
+	costs := []ResourceCost{
+		{Xid: "web", CPUCost: 30, MemoryCost: 10, TotalCost: 40},
+		{Xid: "ml", CPUCost: 10, GPUCost: 40, TotalCost: 50},
+		{Xid: "idle"},
+	}
+	amortize(costs, []amortizedCost{
+		{Resource: pricing.AmortizeCompute, Cost: 18},
+		{Resource: pricing.AmortizeGPU, Cost: 8},
+		{Resource: pricing.AmortizeMemory, Cost: 0},
+	})
+
+	utils.Equals(t, 48.0, costs[0].TotalCost)
+	utils.Equals(t, 68.0, costs[1].TotalCost)
+	utils.Equals(t, 0.0, costs[2].TotalCost)
+	utils.Equals(t, LineItem{Category: AmortizationLineItem, Description: "upfront payments amortized over their term", Cost: 18}, costs[1].LineItems[0])
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// ApplicationCost is the usage (in unit hours) and cost of an application in a time window, summed over
+// the namespaces its pods run in. Xid identifies the application instance, the application name or the
+// higher level application depending on the rollup.
+type ApplicationCost struct {
+	Xid         string          `json:"xid"`
+	AppName     string          `json:"appName,omitempty"`
+	Instance    string          `json:"instance,omitempty"`
+	PartOf      string          `json:"partOf,omitempty"`
+	Namespaces  []string        `json:"namespaces"`
+	CPU         utils.CoreHours `json:"cpu"`
+	Memory      utils.GBHours   `json:"memory"`
+	Storage     utils.GBHours   `json:"storage"`
+	GPU         float64         `json:"gpu"`
+	CPUCost     float64         `json:"cpuCost"`
+	MemoryCost  float64         `json:"memoryCost"`
+	StorageCost float64         `json:"storageCost"`
+	GPUCost     float64         `json:"gpuCost"`
+	TotalCost   float64         `json:"totalCost"`
+
+	LineItems []LineItem `json:"lineItems"`
+}
+
+// RetrieveApplicationCostsInWindow returns the usage and cost of every application instance for the time
+// window [from, to). Only the part of a pod's life inside the window is charged.
+func RetrieveApplicationCostsInWindow(from, to time.Time) ([]ApplicationCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		apps as var(func: has(isApplication)) {
+			~application @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+			appCpu as sum(val(podCpuHours))
+			appMem as sum(val(podMemHours))
+			appStorage as sum(val(podStorageHours))
+			appGpu as sum(val(podGpuHours))
+			appCpuCost as sum(val(podCpuCost))
+			appMemCost as sum(val(podMemCost))
+			appStorageCost as sum(val(podStorageCost))
+			appGpuCost as sum(val(podGpuCost))
+		}
+
+		applications(func: uid(apps)) @filter(gt(val(appCpu), 0) OR gt(val(appMem), 0) OR gt(val(appGpu), 0)) {
+			xid
+			appName
+			instance
+			partOf
+			cpu: val(appCpu)
+			memory: val(appMem)
+			storage: val(appStorage)
+			gpu: val(appGpu)
+			cpuCost: val(appCpuCost)
+			memoryCost: val(appMemCost)
+			storageCost: val(appStorageCost)
+			gpuCost: val(appGpuCost)
+			pods: ~application @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				namespace {
+					xid
+				}
+			}
+		}
+	}`
+
+	type pod struct {
+		Namespace struct {
+			Xid string `json:"xid"`
+		} `json:"namespace"`
+	}
+	type application struct {
+		ApplicationCost
+		Pods []pod `json:"pods"`
+	}
+	type root struct {
+		Applications []application `json:"applications"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := []ApplicationCost{}
+	for _, app := range newRoot.Applications {
+		cost := app.ApplicationCost
+		namespaces := map[string]bool{}
+		for _, p := range app.Pods {
+			namespaces[p.Namespace.Xid] = true
+		}
+		cost.Namespaces = sortedKeys(namespaces)
+		cost.LineItems = podLineItems(cost.CPUCost, cost.MemoryCost, cost.GPUCost, cost.Storage, cost.StorageCost)
+		cost.TotalCost = lineItemsTotal(cost.LineItems)
+		costs = append(costs, cost)
+	}
+	return costs, nil
+}
+
+// RollupApplicationCosts sums the costs of application instances by groupBy: Instance keeps the instances,
+// Name sums the instances of an application and PartOf sums the applications which are part of the same
+// higher level application. Instances without part-of label are kept under their application name.
+// The result is ordered by total cost, highest first.
+func RollupApplicationCosts(costs []ApplicationCost, groupBy string) []ApplicationCost {
+	rollups := map[string]*ApplicationCost{}
+	namespaces := map[string]map[string]bool{}
+	var keys []string
+	for _, cost := range costs {
+		key, rollup := cost.Xid, ApplicationCost{Xid: cost.Xid, AppName: cost.AppName, Instance: cost.Instance, PartOf: cost.PartOf}
+		if groupBy == Name || (groupBy == PartOf && cost.PartOf == "") {
+			key, rollup = cost.AppName, ApplicationCost{Xid: cost.AppName, AppName: cost.AppName, PartOf: cost.PartOf}
+		} else if groupBy == PartOf {
+			key, rollup = cost.PartOf, ApplicationCost{Xid: cost.PartOf, PartOf: cost.PartOf}
+		}
+		if _, isPresent := rollups[key]; !isPresent {
+			rollups[key] = &rollup
+			namespaces[key] = map[string]bool{}
+			keys = append(keys, key)
+		}
+		r := rollups[key]
+		r.CPU += cost.CPU
+		r.Memory += cost.Memory
+		r.Storage += cost.Storage
+		r.GPU += cost.GPU
+		r.CPUCost += cost.CPUCost
+		r.MemoryCost += cost.MemoryCost
+		r.StorageCost += cost.StorageCost
+		r.GPUCost += cost.GPUCost
+		r.TotalCost += cost.TotalCost
+		for _, ns := range cost.Namespaces {
+			namespaces[key][ns] = true
+		}
+	}
+
+	result := []ApplicationCost{}
+	for _, key := range keys {
+		rollup := *rollups[key]
+		rollup.Namespaces = sortedKeys(namespaces[key])
+		rollup.LineItems = podLineItems(rollup.CPUCost, rollup.MemoryCost, rollup.GPUCost, rollup.Storage, rollup.StorageCost)
+		result = append(result, rollup)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].TotalCost > result[j].TotalCost
+	})
+	return result
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestRollupApplicationCosts(t *testing.T) {
+	costs := []ApplicationCost{
+		{Xid: "web:blue", AppName: "web", Instance: "blue", PartOf: "shop", Namespaces: []string{"prod"}, CPUCost: 1, TotalCost: 1},
+		{Xid: "web:green", AppName: "web", Instance: "green", PartOf: "shop", Namespaces: []string{"staging"}, CPUCost: 2, TotalCost: 2},
+		{Xid: "cart", AppName: "cart", PartOf: "shop", Namespaces: []string{"prod"}, CPUCost: 4, TotalCost: 4},
+		{Xid: "batch", AppName: "batch", Namespaces: []string{"jobs"}, MemoryCost: 5, TotalCost: 5},
+	}
+
+	byInstance := RollupApplicationCosts(costs, Instance)
+	utils.Equals(t, 4, len(byInstance))
+	utils.Equals(t, "batch", byInstance[0].Xid)
+
+	byName := RollupApplicationCosts(costs, Name)
+	utils.Equals(t, 3, len(byName))
+	utils.Equals(t, "web", byName[2].Xid)
+	utils.Equals(t, 3.0, byName[2].TotalCost)
+	utils.Equals(t, []string{"prod", "staging"}, byName[2].Namespaces)
+
+	byPartOf := RollupApplicationCosts(costs, PartOf)
+	utils.Equals(t, 2, len(byPartOf))
+	utils.Equals(t, "shop", byPartOf[0].Xid)
+	utils.Equals(t, 7.0, byPartOf[0].TotalCost)
+	utils.Equals(t, []string{"prod", "staging"}, byPartOf[0].Namespaces)
+	utils.Equals(t, "batch", byPartOf[1].Xid)
+}
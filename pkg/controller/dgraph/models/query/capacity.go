@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// RetrieveAlivePodRequests returns the resource requests of the pods which are currently alive along with
+// their namespace, container images and the controllers owning them.
+func RetrieveAlivePodRequests() ([]models.Pod, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pods(func: has(isPod)) @filter(NOT has(endTime) AND NOT has(isSynthetic)) {
+			xid
+			cpuRequest
+			memoryRequest
+			namespace {
+				xid
+			}
+			containers {
+				image
+			}
+			replicaset {
+				xid
+				deployment {
+					xid
+				}
+			}
+			statefulset {
+				xid
+			}
+			daemonset {
+				xid
+			}
+			job {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Pods []models.Pod `json:"pods"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Pods, nil
+}
+
+// RetrieveAliveNodesWithPods returns the nodes which are currently alive along with their capacity and
+// the resource requests of the pods running on them.
+func RetrieveAliveNodesWithPods() ([]models.Node, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		nodes(func: has(isNode)) @filter(NOT has(endTime)) {
+			xid
+			name
+			cpuCapacity
+			memoryCapacity
+			pods: ~node @filter(has(isPod) AND NOT has(endTime)) {
+				xid
+				cpuRequest
+				memoryRequest
+				namespace {
+					xid
+				}
+				daemonset {
+					xid
+				}
+			}
+		}
+	}`
+
+	type root struct {
+		Nodes []models.Node `json:"nodes"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Nodes, nil
+}
+
+// RetrieveAliveNodes returns the capacity, instance type and region of the nodes which are currently alive.
+func RetrieveAliveNodes() ([]models.Node, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		nodes(func: has(isNode)) @filter(NOT has(endTime)) {
+			xid
+			cpuCapacity
+			memoryCapacity
+			instanceType
+			region
+		}
+	}`
+
+	type root struct {
+		Nodes []models.Node `json:"nodes"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Nodes, nil
+}
+
+// RetrieveIdlePvcs returns the persistent volume claims which are alive but not mounted by any alive pod
+func RetrieveIdlePvcs() ([]models.PersistentVolumeClaim, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pvcs(func: has(isPersistentVolumeClaim)) @filter(NOT has(endTime)) {
+			xid
+			storageCapacity
+			namespace {
+				xid
+			}
+		}
+		pods(func: has(isPod)) @filter(NOT has(endTime) AND has(pvc)) {
+			pvc {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Pvcs []models.PersistentVolumeClaim `json:"pvcs"`
+		Pods []models.Pod                   `json:"pods"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	mounted := make(map[string]bool)
+	for _, pod := range newRoot.Pods {
+		for _, pvc := range pod.Pvcs {
+			mounted[pvc.Xid] = true
+		}
+	}
+	var idle []models.PersistentVolumeClaim
+	for _, pvc := range newRoot.Pvcs {
+		if !mounted[pvc.Xid] {
+			idle = append(idle, pvc)
+		}
+	}
+	return idle, nil
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// RetrieveLabelValueCostsInWindow returns the usage (in unit hours) and cost of the pods having the label key for
+// every value of the key (Xid and Name) in the time window [from, to). The pods without the key are summed in the
+// cost with empty Xid, it is omitted when they cost nothing.
+func RetrieveLabelValueCostsInWindow(key string, from, to time.Time) ([]ResourceCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		labels as var(func: has(isLabel)) @filter(` + builder.Eq("key", key) + `) {
+			~label @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+			valueCpu as sum(val(podCpuHours))
+			valueMem as sum(val(podMemHours))
+			valueStorage as sum(val(podStorageHours))
+			valueGpu as sum(val(podGpuHours))
+			valueCpuCost as sum(val(podCpuCost))
+			valueMemCost as sum(val(podMemCost))
+			valueStorageCost as sum(val(podStorageCost))
+			valueGpuCost as sum(val(podGpuCost))
+		}
+		values(func: uid(labels)) {
+			xid: value
+			name: value
+			cpu: val(valueCpu)
+			memory: val(valueMem)
+			storage: val(valueStorage)
+			gpu: val(valueGpu)
+			cpuCost: val(valueCpuCost)
+			memoryCost: val(valueMemCost)
+			storageCost: val(valueStorageCost)
+			gpuCost: val(valueGpuCost)
+		}
+		var(func: has(isPod)) @filter(` + podsInWindowFilter(builder, from, to) + `) {
+			` + podCostInWindow(from, to) + `
+		}
+		total() {
+			cpu: sum(val(podCpuHours))
+			memory: sum(val(podMemHours))
+			storage: sum(val(podStorageHours))
+			gpu: sum(val(podGpuHours))
+			cpuCost: sum(val(podCpuCost))
+			memoryCost: sum(val(podMemCost))
+			storageCost: sum(val(podStorageCost))
+			gpuCost: sum(val(podGpuCost))
+		}
+	}`
+
+	type root struct {
+		Values []ResourceCost `json:"values"`
+		Total  []ResourceCost `json:"total"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+
+	// the pods without the key are the pods of the window less the pods of every value
+	unlabeled := ResourceCost{}
+	for _, aggregate := range newRoot.Total {
+		addResourceCost(&unlabeled, aggregate, 1)
+	}
+	// a value may be stored in several label nodes
+	values := map[string]int{}
+	costs := []ResourceCost{}
+	for _, cost := range newRoot.Values {
+		if cost.CPU == 0 && cost.Memory == 0 && cost.Storage == 0 && cost.GPU == 0 {
+			continue
+		}
+		addResourceCost(&unlabeled, cost, -1)
+		i, isSeen := values[cost.Xid]
+		if !isSeen {
+			i = len(costs)
+			values[cost.Xid] = i
+			costs = append(costs, ResourceCost{Xid: cost.Xid, Name: cost.Name})
+		}
+		addResourceCost(&costs[i], cost, 1)
+	}
+	for i := range costs {
+		costs[i].LineItems = podLineItems(costs[i].CPUCost, costs[i].MemoryCost, costs[i].GPUCost, costs[i].Storage, costs[i].StorageCost)
+		costs[i].TotalCost = lineItemsTotal(costs[i].LineItems)
+	}
+	unlabeled.LineItems = podLineItems(unlabeled.CPUCost, unlabeled.MemoryCost, unlabeled.GPUCost, unlabeled.Storage, unlabeled.StorageCost)
+	unlabeled.TotalCost = lineItemsTotal(unlabeled.LineItems)
+	if unlabeled.TotalCost > costEpsilon {
+		costs = append(costs, unlabeled)
+	}
+	return costs, nil
+}
+
+// costEpsilon is the cost below which a difference of sums is a rounding error
+const costEpsilon = 1e-9
+
+// addResourceCost adds the usage and cost of other times sign to cost
+func addResourceCost(cost *ResourceCost, other ResourceCost, sign float64) {
+	cost.CPU += utils.CoreHours(sign) * other.CPU
+	cost.Memory += utils.GBHours(sign) * other.Memory
+	cost.Storage += utils.GBHours(sign) * other.Storage
+	cost.GPU += sign * other.GPU
+	cost.CPUCost += sign * other.CPUCost
+	cost.MemoryCost += sign * other.MemoryCost
+	cost.StorageCost += sign * other.StorageCost
+	cost.GPUCost += sign * other.GPUCost
+}
@@ -27,16 +27,17 @@ import (
 
 // RetrieveClusterHierarchy returns all namespaces if view is logical and returns all nodes with disks if view is physical
 func RetrieveClusterHierarchy(view string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	var query string
 	if view == Physical {
-		query = `query {
+		query = `{
 			children(func: has(name)) @filter(has(isNode) OR has(isPersistentVolume)) {
 				name
 				type
 			}
 		}`
 	} else {
-		query = `query {
+		query = `{
 			children(func: has(isNamespace)) {
 				name
 				type
@@ -45,7 +46,7 @@ func RetrieveClusterHierarchy(view string) JSONDataWrapper {
 	}
 
 	parentRoot := ParentWrapper{}
-	err := dgraph.ExecuteQuery(query, &parentRoot)
+	err := builder.Execute(query, &parentRoot)
 	if err != nil {
 		logrus.Errorf("Unable to execute query for retrieving cluster hierarchy: (%v)", err)
 		return JSONDataWrapper{}
@@ -61,13 +62,16 @@ func RetrieveClusterHierarchy(view string) JSONDataWrapper {
 	return root
 }
 
-// RetrieveClusterMetrics returns all namespaces with metrics if view is logical and
+// RetrieveClusterMetrics returns all namespaces with metrics if view is logical,
+// returns all environments with metrics if view is environment,
+// returns all applications with metrics if view is application and
 // returns all nodes and disks with metrics if view is physical
 func RetrieveClusterMetrics(view string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	var query string
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
 	if view == Physical {
-		query = `query {
+		query = `{
 			children(func: has(name)) @filter(has(isNode) OR has(isPersistentVolume)) {
 				name
 				type
@@ -81,15 +85,23 @@ func RetrieveClusterMetrics(view string) JSONDataWrapper {
 				isTerminated as count(endTime)
 				secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 				durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-				cpuCost: math(cpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-				memoryCost: math(memory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-				storageCost: math(storage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+				cpuCost: math(cpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+				memoryCost: math(memory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+				storageCost: math(storage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 			}
 		}`
 	} else {
-		query = `query {
-			ns as var(func: has(isNamespace)) {
-				~namespace @filter(has(isPod)){
+		// logical view aggregates pods by namespace, environment view aggregates pods by environment
+		// and application view aggregates pods by application instance
+		parentType, parentEdge := "isNamespace", "~namespace"
+		if view == Environment {
+			parentType, parentEdge = "isEnvironment", "~environment"
+		} else if view == Application {
+			parentType, parentEdge = "isApplication", "~application"
+		}
+		query = `{
+			ns as var(func: has(` + parentType + `)) {
+				` + parentEdge + ` @filter(has(isPod)){
 					namespacePodCpu as cpuRequest
 					namespacePodMem as memoryRequest
 					namespacePvcStorage as storageRequest
@@ -100,9 +112,9 @@ func RetrieveClusterMetrics(view string) JSONDataWrapper {
 					isTerminated as count(endTime)
 					secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 					durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-					namespacePodCpuCost as math(namespacePodCpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					namespacePodMemoryCost as math(namespacePodMem * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-					namespacePodStorageCost as math(namespacePvcStorage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					namespacePodCpuCost as math(namespacePodCpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+					namespacePodMemoryCost as math(namespacePodMem * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+					namespacePodStorageCost as math(namespacePvcStorage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 				}
 				namespaceCpu as sum(val(namespacePodCpu))
 				namespaceMem as sum(val(namespacePodMem))
@@ -126,7 +138,7 @@ func RetrieveClusterMetrics(view string) JSONDataWrapper {
 	}
 
 	parentRoot := ParentWrapper{}
-	err := dgraph.ExecuteQuery(query, &parentRoot)
+	err := builder.Execute(query, &parentRoot)
 	calculateAggregateMetrics(&parentRoot)
 	if err != nil {
 		logrus.Errorf("Unable to execute query for retrieving cluster metrics: (%v)", err)
@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// OverheadItem is a namespace or node agent daemonset classified as cluster overhead, with its cost in a time window
+type OverheadItem struct {
+	Kind      string  `json:"kind"`
+	Xid       string  `json:"xid"`
+	Namespace string  `json:"namespace,omitempty"`
+	Reason    string  `json:"reason"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+// ClusterOverhead is the cost of the cluster overhead in a time window, which is shared by the other namespaces
+type ClusterOverhead struct {
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Items     []OverheadItem `json:"items"`
+	TotalCost float64        `json:"totalCost"`
+}
+
+// RetrieveClusterOverheadInWindow returns the namespaces and daemonsets classified as cluster overhead with their cost
+// for the time window [from, to), ordered by cost. Daemonsets of overhead namespaces are counted with their namespace.
+func RetrieveClusterOverheadInWindow(from, to time.Time) (ClusterOverhead, error) {
+	costs, err := RetrieveNamespaceCosts(All, from, to)
+	if err != nil {
+		return ClusterOverhead{}, err
+	}
+	return retrieveClusterOverhead(costs, from, to)
+}
+
+func retrieveClusterOverhead(namespaceCosts []ResourceCost, from, to time.Time) (ClusterOverhead, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		namespaces(func: eq(clusterOverhead, true)) @filter(has(isNamespace)) {
+			xid
+			overheadReason
+		}
+		ds as var(func: eq(clusterOverhead, true)) @filter(has(isDaemonset)) {
+			~daemonset @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+				podCost as math(podCpuCost + podMemCost + podStorageCost + podGpuCost)
+			}
+			daemonsetCost as sum(val(podCost))
+		}
+		daemonsets(func: uid(ds)) {
+			xid
+			overheadReason
+			totalCost: val(daemonsetCost)
+		}
+	}`
+
+	type item struct {
+		Xid       string  `json:"xid"`
+		Reason    string  `json:"overheadReason"`
+		TotalCost float64 `json:"totalCost"`
+	}
+	type root struct {
+		Namespaces []item `json:"namespaces"`
+		Daemonsets []item `json:"daemonsets"`
+	}
+	newRoot := root{}
+	overhead := ClusterOverhead{From: from.Format(DateFormat), To: to.Format(DateFormat), Items: []OverheadItem{}}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return overhead, err
+	}
+
+	namespaceCost := map[string]float64{}
+	for _, cost := range namespaceCosts {
+		namespaceCost[cost.Xid] = cost.TotalCost
+	}
+	overheadNamespaces := map[string]bool{}
+	for _, ns := range newRoot.Namespaces {
+		overheadNamespaces[ns.Xid] = true
+		overhead.Items = append(overhead.Items, OverheadItem{Kind: Namespace, Xid: ns.Xid, Reason: ns.Reason, TotalCost: namespaceCost[ns.Xid]})
+	}
+	for _, ds := range newRoot.Daemonsets {
+		namespace := strings.SplitN(ds.Xid, ":", 2)[0]
+		if overheadNamespaces[namespace] || ds.TotalCost == 0 {
+			continue
+		}
+		overhead.Items = append(overhead.Items, OverheadItem{Kind: "daemonset", Xid: ds.Xid, Namespace: namespace, Reason: ds.Reason, TotalCost: ds.TotalCost})
+	}
+	for _, overheadItem := range overhead.Items {
+		overhead.TotalCost += overheadItem.TotalCost
+	}
+	sort.SliceStable(overhead.Items, func(i, j int) bool {
+		return overhead.Items[i].TotalCost > overhead.Items[j].TotalCost
+	})
+	return overhead, nil
+}
+
+// DistributeClusterOverhead retrieves the cluster overhead of the window and shares it among the namespace costs,
+// which must hold all namespaces of the cluster.
+func DistributeClusterOverhead(costs []ResourceCost, from, to time.Time) error {
+	overhead, err := retrieveClusterOverhead(costs, from, to)
+	if err != nil {
+		return err
+	}
+	distributeOverhead(costs, overhead)
+	return nil
+}
+
+// distributeOverhead flags the overhead namespaces and sets the overhead cost of the namespaces running node agents.
+// The cost of the overhead is shared by the other namespaces in proportion of their cost without overhead.
+func distributeOverhead(costs []ResourceCost, overhead ClusterOverhead) {
+	byXid := map[string]*ResourceCost{}
+	for i := range costs {
+		byXid[costs[i].Xid] = &costs[i]
+	}
+	for _, item := range overhead.Items {
+		cost, isListed := byXid[item.Xid]
+		if item.Kind != Namespace {
+			cost, isListed = byXid[item.Namespace]
+		}
+		if !isListed {
+			continue
+		}
+		if item.Kind == Namespace {
+			cost.ClusterOverhead = true
+		} else {
+			cost.OverheadCost += item.TotalCost
+		}
+	}
+
+	weights := 0.0
+	for _, cost := range costs {
+		if !cost.ClusterOverhead {
+			weights += cost.TotalCost - cost.OverheadCost
+		}
+	}
+	if weights <= 0 {
+		return
+	}
+	for i := range costs {
+		if !costs[i].ClusterOverhead {
+			costs[i].SharedCost = overhead.TotalCost * (costs[i].TotalCost - costs[i].OverheadCost) / weights
+		}
+	}
+}
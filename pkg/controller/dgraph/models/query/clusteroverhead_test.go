@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestDistributeOverhead(t *testing.T) {
+	costs := []ResourceCost{
+		{Xid: "kube-system", TotalCost: 30},
+		{Xid: "payments", TotalCost: 50},
+		{Xid: "search", TotalCost: 110},
+	}
+	overhead := ClusterOverhead{
+		Items: []OverheadItem{
+			{Kind: Namespace, Xid: "kube-system", TotalCost: 30},
+			{Kind: "daemonset", Xid: "search:datadog-agent", Namespace: "search", TotalCost: 10},
+		},
+		TotalCost: 40,
+	}
+	distributeOverhead(costs, overhead)
+
+	utils.Assert(t, costs[0].ClusterOverhead, "kube-system is cluster overhead")
+	utils.Equals(t, 0.0, costs[0].SharedCost)
+	utils.Equals(t, 0.0, costs[1].OverheadCost)
+	utils.Equals(t, 10.0, costs[2].OverheadCost)
+	// payments and search cost 50 and 100 without overhead
+	utils.Equals(t, 40.0/3, costs[1].SharedCost)
+	utils.Equals(t, 80.0/3, costs[2].SharedCost)
+}
@@ -21,17 +21,19 @@ import (
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrieveContainerHierarchy returns hierarchy for a given pod
 func RetrieveContainerHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for container, empty name is given")
 		return JSONDataWrapper{}
 	}
-	query := `query {
-		parent(func: has(isContainer)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isContainer)) {
 			name
 			type
 			children: ~container @filter(has(isProc)) {
@@ -40,18 +42,19 @@ func RetrieveContainerHierarchy(name string) JSONDataWrapper {
 			}
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrieveContainerMetrics returns hierarchy for a given pod
 func RetrieveContainerMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for container, empty name is given")
 		return JSONDataWrapper{}
 	}
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		parent(func: has(isContainer)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isContainer)) {
 			name
 			type
 			cpu: cpu as cpuRequest
@@ -63,9 +66,11 @@ func RetrieveContainerMetrics(name string) JSONDataWrapper {
 			isTerminated as count(endTime)
 			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-			cpuCost: math(cpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-			memoryCost: math(memory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
+			cpuCost: math(cpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			memoryCost: math(memory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			gpu: gpu as gpuRequest
+			gpuCost: math(gpu * durationInHours * ` + gpuCostPerGPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
@@ -0,0 +1,125 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// CostCenterCost is the cost of a cost center in a time window. Cost is charged to the cost center through its
+// namespaces and labels, TotalCost adds the total cost of its children.
+type CostCenterCost struct {
+	Name       string            `json:"name"`
+	Source     string            `json:"source"`
+	Namespaces []string          `json:"namespaces,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Cost       float64           `json:"cost"`
+	TotalCost  float64           `json:"totalCost"`
+	Children   []*CostCenterCost `json:"children,omitempty"`
+}
+
+// CostCenterReport is the cost center hierarchy with the costs of the window [From, To)
+type CostCenterReport struct {
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	CostCenters []*CostCenterCost `json:"costCenters"`
+	TotalCost   float64           `json:"totalCost"`
+}
+
+// RetrieveCostCenterCosts returns the imported cost center hierarchy with the cost of every cost center in the time
+// window [from, to). Cost centers whose parent is not imported are at the top of the hierarchy.
+func RetrieveCostCenterCosts(from, to time.Time) (CostCenterReport, error) {
+	report := CostCenterReport{From: from.Format(DateFormat), To: to.Format(DateFormat), CostCenters: []*CostCenterCost{}}
+	costCenters, err := models.RetrieveCostCenters()
+	if err != nil || len(costCenters) == 0 {
+		return report, err
+	}
+	namespaceCosts, err := RetrieveNamespaceCosts(All, from, to)
+	if err != nil {
+		return report, err
+	}
+	costByNamespace := map[string]float64{}
+	for _, cost := range namespaceCosts {
+		costByNamespace[cost.Xid] = cost.TotalCost
+	}
+
+	costs := map[string]float64{}
+	for _, costCenter := range costCenters {
+		for _, namespace := range costCenter.GetNamespaces() {
+			costs[costCenter.Name] += costByNamespace[namespace]
+		}
+		labelsCost, err := RetrieveLabelsCostInWindow(costCenter.GetLabels(), from, to)
+		if err != nil {
+			return report, err
+		}
+		costs[costCenter.Name] += labelsCost.TotalCost
+	}
+	report.CostCenters = costCenterTree(costCenters, costs)
+	for _, root := range report.CostCenters {
+		report.TotalCost += root.TotalCost
+	}
+	return report, nil
+}
+
+// costCenterTree builds the hierarchy of the cost centers with their own costs and rolls the costs up to the top,
+// children are sorted by total cost
+func costCenterTree(costCenters []models.CostCenter, costs map[string]float64) []*CostCenterCost {
+	nodes := map[string]*CostCenterCost{}
+	for _, costCenter := range costCenters {
+		labels := costCenter.GetLabels()
+		if len(labels) == 0 {
+			labels = nil
+		}
+		nodes[costCenter.Name] = &CostCenterCost{Name: costCenter.Name, Source: costCenter.Source,
+			Namespaces: costCenter.GetNamespaces(), Labels: labels, Cost: costs[costCenter.Name]}
+	}
+	roots := []*CostCenterCost{}
+	for _, costCenter := range costCenters {
+		node := nodes[costCenter.Name]
+		if parent, isImported := nodes[costCenter.Parent]; isImported && costCenter.Parent != costCenter.Name {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	for _, root := range roots {
+		rollUp(root)
+	}
+	sortCostCenters(roots)
+	return roots
+}
+
+func rollUp(node *CostCenterCost) float64 {
+	node.TotalCost = node.Cost
+	for _, child := range node.Children {
+		node.TotalCost += rollUp(child)
+	}
+	return node.TotalCost
+}
+
+func sortCostCenters(nodes []*CostCenterCost) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].TotalCost > nodes[j].TotalCost
+	})
+	for _, node := range nodes {
+		sortCostCenters(node.Children)
+	}
+}
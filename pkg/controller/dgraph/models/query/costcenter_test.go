@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestCostCenterTree(t *testing.T) {
+	costCenters := []models.CostCenter{
+		{Name: "engineering", Source: "csv"},
+		{Name: "platform", Parent: "engineering", Namespaces: "kube-system,monitoring", Source: "csv"},
+		{Name: "shop", Parent: "engineering", Labels: "team=web", Source: "csv"},
+		{Name: "sales", Parent: "hr", Namespaces: "crm", Source: "ldap"},
+	}
+	costs := map[string]float64{"engineering": 1, "platform": 10, "shop": 20, "sales": 5}
+
+	roots := costCenterTree(costCenters, costs)
+	utils.Equals(t, 2, len(roots))
+	utils.Equals(t, "engineering", roots[0].Name)
+	utils.Equals(t, 31.0, roots[0].TotalCost)
+	utils.Equals(t, "shop", roots[0].Children[0].Name)
+	utils.Equals(t, map[string]string{"team": "web"}, roots[0].Children[0].Labels)
+	utils.Equals(t, []string{"kube-system", "monitoring"}, roots[0].Children[1].Namespaces)
+	utils.Equals(t, "sales", roots[1].Name)
+	utils.Equals(t, 5.0, roots[1].TotalCost)
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// RetrieveCostSummaries returns the precomputed daily cost summaries of the given namespace or group (every
+// namespace or group if owner is All) for the days in [from, to). ownerType is one of models.NamespaceOwner or
+// models.GroupOwner.
+func RetrieveCostSummaries(ownerType, owner string, from, to time.Time) ([]models.CostSummary, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	ownerFilter := "has(isNamespace)"
+	if ownerType == models.GroupOwner {
+		ownerFilter = "has(isPurserGroup)"
+	}
+	ownerSelector := ownerFilter
+	if owner != All {
+		ownerSelector = builder.Eq("xid", owner) + `) @filter(` + ownerFilter
+	}
+	query := `{
+		var(func: ` + ownerSelector + `) {
+			summaries as ~` + ownerType + ` @filter(has(isCostSummary) AND ge(date, ` + builder.Time(from) + `) AND lt(date, ` + builder.Time(to) + `))
+		}
+		summaries(func: uid(summaries), orderasc: date) {
+			` + costSummaryFields + `
+			` + ownerType + ` {
+				xid
+				name
+			}
+		}
+	}`
+	return retrieveCostSummaries(builder, query)
+}
+
+// RetrieveAllCostSummaries returns the precomputed daily cost summaries of all namespaces and groups
+// for the days in [from, to).
+func RetrieveAllCostSummaries(from, to time.Time) ([]models.CostSummary, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		summaries(func: ge(date, ` + builder.Time(from) + `), orderasc: date) @filter(has(isCostSummary) AND lt(date, ` + builder.Time(to) + `)) {
+			` + costSummaryFields + `
+			namespace {
+				xid
+			}
+			group {
+				xid
+			}
+		}
+	}`
+	return retrieveCostSummaries(builder, query)
+}
+
+const costSummaryFields = `xid
+			date
+			cpuHours
+			memoryGBHours
+			storageGBHours
+			cpuCost
+			memoryCost
+			storageCost
+			gpuHours
+			gpuCost
+			totalCost
+			claimGBHours
+			claimCost
+			snapshotGBHours
+			snapshotCost
+			loadBalancerHours
+			loadBalancerCost
+			hasLineItems
+			priceVersion
+			computedAt`
+
+func retrieveCostSummaries(builder *dgraph.QueryBuilder, query string) ([]models.CostSummary, error) {
+	type root struct {
+		Summaries []models.CostSummary `json:"summaries"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Summaries, nil
+}
+
+// RetrieveDailySeals returns the seals of the days in [from, to), oldest first
+func RetrieveDailySeals(from, to time.Time) ([]models.DailySeal, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		seals(func: ge(date, ` + builder.Time(from) + `), orderasc: date) @filter(has(isDailySeal) AND lt(date, ` + builder.Time(to) + `)) {
+			` + dailySealFields + `
+		}
+	}`
+	return retrieveDailySeals(builder, query)
+}
+
+// RetrieveLastDailySeal returns the seal of the latest sealed day before the given day, nil if no day is sealed
+func RetrieveLastDailySeal(before time.Time) (*models.DailySeal, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		seals(func: lt(date, ` + builder.Time(before) + `), orderdesc: date, first: 1) @filter(has(isDailySeal)) {
+			` + dailySealFields + `
+		}
+	}`
+	seals, err := retrieveDailySeals(builder, query)
+	if err != nil || len(seals) == 0 {
+		return nil, err
+	}
+	return &seals[0], nil
+}
+
+// RetrieveSealAmendments returns the amendments of the seals of the days in [from, to), oldest day first
+func RetrieveSealAmendments(from, to time.Time) ([]models.SealAmendment, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		amendments(func: ge(date, ` + builder.Time(from) + `), orderasc: date) @filter(has(isSealAmendment) AND lt(date, ` + builder.Time(to) + `)) {
+			xid
+			date
+			sequence
+			reason
+			amendedSummaries
+			digest
+			previousDigest
+			previousChainDigest
+			chainDigest
+			signature
+			keyFingerprint
+			amendedAt
+		}
+	}`
+
+	type root struct {
+		Amendments []models.SealAmendment `json:"amendments"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Amendments, nil
+}
+
+const dailySealFields = `xid
+			date
+			sealedSummaries
+			digest
+			previousChainDigest
+			chainDigest
+			signature
+			keyFingerprint
+			sealedAt`
+
+func retrieveDailySeals(builder *dgraph.QueryBuilder, query string) ([]models.DailySeal, error) {
+	type root struct {
+		Seals []models.DailySeal `json:"seals"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Seals, nil
+}
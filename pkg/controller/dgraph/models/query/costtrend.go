@@ -0,0 +1,326 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Periods of the points of a cost trend
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// Kinds of the workloads of the cost snapshots
+var workloadKinds = map[string]bool{
+	"deployment":  true,
+	"replicaset":  true,
+	"statefulset": true,
+	"daemonset":   true,
+	"job":         true,
+}
+
+// CostTrendPoint is the usage (in unit hours) and cost of the pods snapshotted in a day, week or month
+type CostTrendPoint struct {
+	Start       string          `json:"start"`
+	CPU         utils.CoreHours `json:"cpu"`
+	Memory      utils.GBHours   `json:"memory"`
+	Storage     utils.GBHours   `json:"storage"`
+	GPU         float64         `json:"gpu"`
+	CPUCost     float64         `json:"cpuCost"`
+	MemoryCost  float64         `json:"memoryCost"`
+	StorageCost float64         `json:"storageCost"`
+	GPUCost     float64         `json:"gpuCost"`
+	TotalCost   float64         `json:"totalCost"`
+}
+
+// CostTrend gives the cost of a namespace, label or workload for every day, week or month of a window, oldest first.
+// Pods are charged from the cost snapshots, the periods which were not snapshotted cost nothing.
+type CostTrend struct {
+	From   string           `json:"from"`
+	To     string           `json:"to"`
+	Period string           `json:"period"`
+	Points []CostTrendPoint `json:"points"`
+}
+
+// snapshotPod is a pod with its owners, labels and usage and cost in the period of a snapshot
+type snapshotPod struct {
+	ResourceCost
+	Namespace   *ResourceCost   `json:"namespace"`
+	Labels      []*models.Label `json:"label"`
+	Deployment  *ResourceCost   `json:"deployment"`
+	Replicaset  *ownedObject    `json:"replicaset"`
+	Statefulset *ResourceCost   `json:"statefulset"`
+	Daemonset   *ResourceCost   `json:"daemonset"`
+	Job         *ResourceCost   `json:"job"`
+}
+
+// ownedObject is an object with the deployment owning it
+type ownedObject struct {
+	Xid        string        `json:"xid"`
+	Deployment *ResourceCost `json:"deployment"`
+}
+
+// ParseWorkload returns the kind and xid (namespace:name) of a workload given as kind/namespace:name
+func ParseWorkload(workload string) (string, string, error) {
+	parts := strings.SplitN(workload, "/", 2)
+	if len(parts) != 2 || !workloadKinds[parts[0]] {
+		return "", "", fmt.Errorf("workload is not <kind>/<namespace>:<name> with kind deployment, replicaset, statefulset, daemonset or job")
+	}
+	if names := strings.SplitN(parts[1], ":", 2); len(names) != 2 || names[0] == "" || names[1] == "" {
+		return "", "", fmt.Errorf("workload is not <kind>/<namespace>:<name>")
+	}
+	return parts[0], parts[1], nil
+}
+
+// RetrievePodCostSnapshots returns the snapshots of the usage and cost of the pods alive in the period [from, to)
+func RetrievePodCostSnapshots(from, to time.Time, priceVersion string) ([]models.CostSnapshot, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pods as var(func: has(isPod)) @filter(` + podsInWindowFilter(builder, from, to) + `) {
+			` + podCostInWindow(from, to) + `
+		}
+		pods(func: uid(pods)) {
+			xid
+			namespace {
+				xid
+			}
+			label {
+				uid
+			}
+			deployment {
+				xid
+			}
+			replicaset {
+				xid
+				deployment {
+					xid
+				}
+			}
+			statefulset {
+				xid
+			}
+			daemonset {
+				xid
+			}
+			job {
+				xid
+			}
+			cpu: val(podCpuHours)
+			memory: val(podMemHours)
+			storage: val(podStorageHours)
+			gpu: val(podGpuHours)
+			cpuCost: val(podCpuCost)
+			memoryCost: val(podMemCost)
+			storageCost: val(podStorageCost)
+			gpuCost: val(podGpuCost)
+		}
+	}`
+
+	type root struct {
+		Pods []snapshotPod `json:"pods"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	start, hours := from.Format(time.RFC3339), to.Sub(from).Hours()
+	snapshots := make([]models.CostSnapshot, 0, len(newRoot.Pods))
+	for _, pod := range newRoot.Pods {
+		snapshot := newCostSnapshot(pod)
+		snapshot.StartTime, snapshot.Hours, snapshot.PriceVersion = start, hours, priceVersion
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// newCostSnapshot returns the snapshot of the pod, its workload is the deployment of its replicaset if it has one
+func newCostSnapshot(pod snapshotPod) models.CostSnapshot {
+	snapshot := models.CostSnapshot{
+		IsCostSnapshot: true,
+		Pod:            pod.Xid,
+		CPUHours:       pod.CPU.Round(),
+		MemoryGBHours:  pod.Memory.Round(),
+		StorageGBHours: pod.Storage.Round(),
+		GPUHours:       utils.Round(pod.GPU, utils.QuantityPrecision),
+		CPUCost:        utils.Round(pod.CPUCost, utils.CostPrecision),
+		MemoryCost:     utils.Round(pod.MemoryCost, utils.CostPrecision),
+		StorageCost:    utils.Round(pod.StorageCost, utils.CostPrecision),
+		GPUCost:        utils.Round(pod.GPUCost, utils.CostPrecision),
+	}
+	snapshot.TotalCost = utils.Round(pod.CPUCost+pod.MemoryCost+pod.StorageCost+pod.GPUCost, utils.CostPrecision)
+	if pod.Namespace != nil {
+		snapshot.Namespace = pod.Namespace.Xid
+	}
+	for _, label := range pod.Labels {
+		snapshot.Labels = append(snapshot.Labels, &models.Label{ID: dgraph.ID{UID: label.UID}})
+	}
+	switch {
+	case pod.Deployment != nil:
+		snapshot.Workload = "deployment/" + pod.Deployment.Xid
+	case pod.Replicaset != nil && pod.Replicaset.Deployment != nil:
+		snapshot.Workload = "deployment/" + pod.Replicaset.Deployment.Xid
+	case pod.Replicaset != nil:
+		snapshot.Workload = "replicaset/" + pod.Replicaset.Xid
+	case pod.Statefulset != nil:
+		snapshot.Workload = "statefulset/" + pod.Statefulset.Xid
+	case pod.Daemonset != nil:
+		snapshot.Workload = "daemonset/" + pod.Daemonset.Xid
+	case pod.Job != nil:
+		snapshot.Workload = "job/" + pod.Job.Xid
+	}
+	return snapshot
+}
+
+// RetrieveNamespaceCostTrend returns the cost trend of the namespace (of the cluster if it is empty) for the periods
+// starting in the window [from, to)
+func RetrieveNamespaceCostTrend(namespace, period string, from, to time.Time) (CostTrend, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	selector := `snapshots as var(func: has(isCostSnapshot)) @filter(` + snapshotsInWindowFilter(builder, from, to) + `)`
+	if namespace != All {
+		selector = `snapshots as var(func: ` + builder.Eq("snapshotNamespace", namespace) + `) @filter(has(isCostSnapshot) AND ` +
+			snapshotsInWindowFilter(builder, from, to) + `)`
+	}
+	return retrieveCostTrend(builder, selector, period, from, to)
+}
+
+// RetrieveLabelCostTrend returns the cost trend of the pods having the label for the periods starting in the window
+// [from, to)
+func RetrieveLabelCostTrend(key, value, period string, from, to time.Time) (CostTrend, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	selector := `var(func: has(isLabel)) @filter(` + createFilterFromLabel(builder, key, value) + `) {
+			snapshots as ~label @filter(has(isCostSnapshot) AND ` + snapshotsInWindowFilter(builder, from, to) + `)
+		}`
+	return retrieveCostTrend(builder, selector, period, from, to)
+}
+
+// RetrieveWorkloadCostTrend returns the cost trend of the pods of the workload (kind/namespace:name) for the periods
+// starting in the window [from, to)
+func RetrieveWorkloadCostTrend(workload, period string, from, to time.Time) (CostTrend, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	selector := `snapshots as var(func: ` + builder.Eq("workload", workload) + `) @filter(has(isCostSnapshot) AND ` +
+		snapshotsInWindowFilter(builder, from, to) + `)`
+	return retrieveCostTrend(builder, selector, period, from, to)
+}
+
+// snapshotsInWindowFilter selects the snapshots of the periods starting in the time window [from, to)
+func snapshotsInWindowFilter(builder *dgraph.QueryBuilder, from, to time.Time) string {
+	return `ge(startTime, ` + builder.Time(from) + `) AND lt(startTime, ` + builder.Time(to) + `)`
+}
+
+// retrieveCostTrend sums the snapshots of the uid variable snapshots defined by the selector by period
+func retrieveCostTrend(builder *dgraph.QueryBuilder, selector, period string, from, to time.Time) (CostTrend, error) {
+	trend := CostTrend{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), Period: period, Points: []CostTrendPoint{}}
+	query := `{
+		` + selector + `
+		snapshots(func: uid(snapshots)) {
+			startTime
+			cpuHours
+			memoryGBHours
+			storageGBHours
+			gpuHours
+			cpuCost
+			memoryCost
+			storageCost
+			gpuCost
+			totalCost
+		}
+	}`
+
+	type root struct {
+		Snapshots []models.CostSnapshot `json:"snapshots"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return trend, err
+	}
+	trend.Points = trendPoints(newRoot.Snapshots, period, from, to)
+	return trend, nil
+}
+
+// trendPoints sums the snapshots by the day, week or month their period starts in. There is a point for every day,
+// week or month of the window [from, to), the first one starts at the start of the day, week or month of from.
+func trendPoints(snapshots []models.CostSnapshot, period string, from, to time.Time) []CostTrendPoint {
+	points := []CostTrendPoint{}
+	indexes := map[time.Time]int{}
+	for start := trendPeriodStart(from, period); start.Before(to); start = nextTrendPeriod(start, period) {
+		indexes[start] = len(points)
+		points = append(points, CostTrendPoint{Start: start.Format(time.RFC3339)})
+	}
+	for _, snapshot := range snapshots {
+		startTime, err := time.Parse(time.RFC3339, snapshot.StartTime)
+		if err != nil {
+			continue
+		}
+		i, ok := indexes[trendPeriodStart(startTime, period)]
+		if !ok {
+			continue
+		}
+		point := &points[i]
+		point.CPU += snapshot.CPUHours
+		point.Memory += snapshot.MemoryGBHours
+		point.Storage += snapshot.StorageGBHours
+		point.GPU += snapshot.GPUHours
+		point.CPUCost += snapshot.CPUCost
+		point.MemoryCost += snapshot.MemoryCost
+		point.StorageCost += snapshot.StorageCost
+		point.GPUCost += snapshot.GPUCost
+		point.TotalCost += snapshot.TotalCost
+	}
+	for i := range points {
+		point := &points[i]
+		point.CPU, point.Memory = point.CPU.Round(), point.Memory.Round()
+		point.Storage, point.GPU = point.Storage.Round(), utils.Round(point.GPU, utils.QuantityPrecision)
+		point.CPUCost, point.MemoryCost = utils.Round(point.CPUCost, utils.CostPrecision), utils.Round(point.MemoryCost, utils.CostPrecision)
+		point.StorageCost, point.GPUCost = utils.Round(point.StorageCost, utils.CostPrecision), utils.Round(point.GPUCost, utils.CostPrecision)
+		point.TotalCost = utils.Round(point.TotalCost, utils.CostPrecision)
+	}
+	return points
+}
+
+// trendPeriodStart returns the start (in local time) of the day, week (starting on monday) or month of the time
+func trendPeriodStart(t time.Time, period string) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch period {
+	case Weekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	default:
+		return day
+	}
+}
+
+func nextTrendPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case Weekly:
+		return start.AddDate(0, 0, 7)
+	case Monthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
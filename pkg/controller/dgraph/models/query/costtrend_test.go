@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestParseWorkload(t *testing.T) {
+	kind, xid, err := ParseWorkload("deployment/shop:cart")
+	utils.Ok(t, err)
+	utils.Equals(t, "deployment", kind)
+	utils.Equals(t, "shop:cart", xid)
+
+	for _, invalid := range []string{"shop:cart", "pod/shop:cart", "deployment/cart", "deployment/:cart"} {
+		_, _, err = ParseWorkload(invalid)
+		utils.Assert(t, err != nil, "expected invalid workload: %s", invalid)
+	}
+}
+
+func TestNewCostSnapshot(t *testing.T) {
+	pod := snapshotPod{
+		ResourceCost: ResourceCost{Xid: "shop:cart-7c9b-x2z", CPU: 0.5, Memory: 1, CPUCost: 0.012, MemoryCost: 0.01},
+		Namespace:    &ResourceCost{Xid: "shop"},
+		Labels:       []*models.Label{{ID: dgraph.ID{UID: "0x2a"}, Key: "team", Value: "a"}},
+		Replicaset:   &ownedObject{Xid: "shop:cart-7c9b", Deployment: &ResourceCost{Xid: "shop:cart"}},
+	}
+	snapshot := newCostSnapshot(pod)
+	utils.Equals(t, "shop:cart-7c9b-x2z", snapshot.Pod)
+	utils.Equals(t, "shop", snapshot.Namespace)
+	utils.Equals(t, "deployment/shop:cart", snapshot.Workload)
+	utils.Equals(t, []*models.Label{{ID: dgraph.ID{UID: "0x2a"}}}, snapshot.Labels)
+	utils.Equals(t, 0.022, snapshot.TotalCost)
+
+	pod.Replicaset.Deployment = nil
+	utils.Equals(t, "replicaset/shop:cart-7c9b", newCostSnapshot(pod).Workload)
+	pod.Replicaset = nil
+	utils.Equals(t, "", newCostSnapshot(pod).Workload)
+}
+
+func TestTrendPeriodStart(t *testing.T) {
+	// monday 2018-11-05
+	at := time.Date(2018, 11, 8, 15, 30, 0, 0, time.Local)
+	utils.Equals(t, time.Date(2018, 11, 8, 0, 0, 0, 0, time.Local), trendPeriodStart(at, Daily))
+	utils.Equals(t, time.Date(2018, 11, 5, 0, 0, 0, 0, time.Local), trendPeriodStart(at, Weekly))
+	utils.Equals(t, time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local), trendPeriodStart(at, Monthly))
+	sunday := time.Date(2018, 11, 11, 23, 0, 0, 0, time.Local)
+	utils.Equals(t, time.Date(2018, 11, 5, 0, 0, 0, 0, time.Local), trendPeriodStart(sunday, Weekly))
+}
+
+func TestTrendPoints(t *testing.T) {
+	snapshot := func(start time.Time, cost float64) models.CostSnapshot {
+		return models.CostSnapshot{StartTime: start.Format(time.RFC3339), CPUHours: 1, CPUCost: cost, TotalCost: cost}
+	}
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(2018, 11, 4, 0, 0, 0, 0, time.Local)
+	snapshots := []models.CostSnapshot{
+		snapshot(from.Add(time.Hour), 0.1),
+		snapshot(from.Add(2*time.Hour), 0.2),
+		snapshot(from.AddDate(0, 0, 2).Add(5*time.Hour), 0.4),
+	}
+
+	points := trendPoints(snapshots, Daily, from, to)
+	utils.Equals(t, 3, len(points))
+	utils.Equals(t, from.Format(time.RFC3339), points[0].Start)
+	utils.Equals(t, 2.0, float64(points[0].CPU))
+	utils.Equals(t, 0.3, points[0].TotalCost)
+	// the days without snapshots cost nothing
+	utils.Equals(t, 0.0, points[1].TotalCost)
+	utils.Equals(t, 0.4, points[2].TotalCost)
+
+	monthly := trendPoints(snapshots, Monthly, from, to)
+	utils.Equals(t, 1, len(monthly))
+	utils.Equals(t, 0.7, monthly[0].TotalCost)
+}
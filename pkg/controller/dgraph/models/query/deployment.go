@@ -21,17 +21,19 @@ import (
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrieveDeploymentHierarchy returns hierarchy for a given deployment
 func RetrieveDeploymentHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for deployment, empty name is given")
 		return JSONDataWrapper{}
 	}
-	query := `query {
-		parent(func: has(isDeployment)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isDeployment)) {
 			name
 			type
 			children: ~deployment @filter(has(isReplicaset)) {
@@ -40,18 +42,19 @@ func RetrieveDeploymentHierarchy(name string) JSONDataWrapper {
 			}
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrieveDeploymentMetrics returns metrics for a given deployment
 func RetrieveDeploymentMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for deployment, empty name is given")
 		return JSONDataWrapper{}
 	}
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		dep as var(func: has(isDeployment)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		dep as var(func: ` + builder.Eq("name", name) + `) @filter(has(isDeployment)) {
 			~deployment @filter(has(isReplicaset)) {
 				~replicaset @filter(has(isPod)) {
 					replicasetPodCpu as cpuRequest
@@ -64,9 +67,9 @@ func RetrieveDeploymentMetrics(name string) JSONDataWrapper {
 					replicasetPodIsTerminated as count(endTime)
 					replicasetPodSecondsSinceEnd as math(cond(replicasetPodIsTerminated == 0, 0.0, since(replicasetPodET)))
 					replicasetPodDurationInHours as math((replicasetPodSecondsSinceStart - replicasetPodSecondsSinceEnd) / 3600)
-					replicasetPodCpuCost as math(replicasetPodCpu * replicasetPodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					replicasetPodMemoryCost as math(replicasetPodMemory * replicasetPodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-					replicasetPvcStorageCost as math(replicasetPvcStorage * replicasetPodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					replicasetPodCpuCost as math(replicasetPodCpu * replicasetPodDurationInHours * ` + cpuCostPerCPUPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
+					replicasetPodMemoryCost as math(replicasetPodMemory * replicasetPodDurationInHours * ` + memCostPerGBPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
+					replicasetPvcStorageCost as math(replicasetPvcStorage * replicasetPodDurationInHours * ` + storageCostPerGBPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
 				}
 				deploymentReplicasetCpu as sum(val(replicasetPodCpu))
 				deploymentReplicasetMemory as sum(val(replicasetPodMemory))
@@ -104,5 +107,5 @@ func RetrieveDeploymentMetrics(name string) JSONDataWrapper {
 			storageCost: val(deploymentStorageCost)
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
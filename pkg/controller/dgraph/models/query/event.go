@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// RetrieveClusterEvents returns the persisted kubernetes events which occurred in the time window [from, to),
+// ordered by their first occurrence. Events can be filtered by reason and by namespace (empty for all).
+func RetrieveClusterEvents(reason, namespace string, from, to time.Time) ([]models.ClusterEvent, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := clusterEventsQuery(builder, reason, namespace, from, to)
+
+	type root struct {
+		Events []models.ClusterEvent `json:"events"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Events, nil
+}
+
+// clusterEventsQuery returns the query of the events with the reason (every reason if All) of the namespace (every
+// namespace if All) which occurred in the time window [from, to)
+func clusterEventsQuery(builder *dgraph.QueryBuilder, reason, namespace string, from, to time.Time) string {
+	filter := `ge(endTime, ` + builder.Time(from) + `) AND lt(startTime, ` + builder.Time(to) + `)`
+	if reason != All {
+		filter += ` AND ` + builder.Eq("reason", reason)
+	}
+
+	selector := `events as var(func: has(isClusterEvent)) @filter(` + filter + `)`
+	if namespace != All {
+		selector = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			events as ~namespace @filter(has(isClusterEvent) AND ` + filter + `)
+		}`
+	}
+	return `{
+		` + selector + `
+		events(func: uid(events), orderasc: startTime) {
+			xid
+			name
+			reason
+			message
+			severity
+			count
+			startTime
+			endTime
+			namespace {
+				xid
+			}
+			involvedPod {
+				xid
+			}
+			involvedNode {
+				xid
+			}
+		}
+	}`
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestClusterEventsQuery(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	builder := dgraph.NewQueryBuilder()
+	query, variables := builder.Build(clusterEventsQuery(builder, All, All, from, from.AddDate(0, 0, 1)))
+	utils.Assert(t, strings.Contains(query, "events as var(func: has(isClusterEvent)) @filter(ge(endTime, $v0) AND lt(startTime, $v1))"),
+		"events overlapping the window are selected: %s", query)
+	utils.Equals(t, map[string]string{"$v0": "2018-11-01T00:00:00Z", "$v1": "2018-11-02T00:00:00Z"}, variables)
+	utils.Assert(t, strings.Contains(query, "events(func: uid(events), orderasc: startTime)"), "events are ordered: %s", query)
+
+	// the reason and the namespace are query variables
+	builder = dgraph.NewQueryBuilder()
+	query, variables = builder.Build(clusterEventsQuery(builder, models.Evicted, `shop") { uid }`, from, from.AddDate(0, 0, 1)))
+	utils.Assert(t, strings.Contains(query, "AND eq(reason, $v2)"), "events are filtered by reason: %s", query)
+	utils.Assert(t, strings.Contains(query, "var(func: eq(xid, $v3)) @filter(has(isNamespace))"), "events are filtered by namespace: %s", query)
+	utils.Assert(t, strings.Contains(query, "events as ~namespace @filter(has(isClusterEvent) AND ge(endTime, $v0)"),
+		"events of the namespace are selected: %s", query)
+	utils.Equals(t, models.Evicted, variables["$v2"])
+	utils.Equals(t, `shop") { uid }`, variables["$v3"])
+	utils.Assert(t, !strings.Contains(query, "shop"), "namespace embedded in the query: %s", query)
+}
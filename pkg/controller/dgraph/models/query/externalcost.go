@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// ExternalLineItem is the line item of the external costs attributed to a namespace
+const ExternalLineItem = "external"
+
+// RetrieveExternalCosts returns the external costs attributed to the given namespace or group along with
+// their month to date cost. If both namespace and group are empty then all external costs are returned.
+func RetrieveExternalCosts(namespace, group string) ([]models.ExternalCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	var selector string
+	if namespace != All {
+		selector = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			items as ~namespace @filter(has(isExternalCost))
+		}`
+	} else if group != All {
+		selector = `var(func: ` + builder.Eq("xid", group) + `) @filter(has(isPurserGroup)) {
+			items as ~group @filter(has(isExternalCost))
+		}`
+	} else {
+		selector = `items as var(func: has(isExternalCost))`
+	}
+
+	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
+	q := `{
+		` + selector + `
+		externalCosts(func: uid(items)) {
+			name
+			xid
+			type
+			category
+			monthlyCost: monthlyCost as monthlyCost
+			startTime
+			endTime
+			namespace {
+				xid
+			}
+			group {
+				xid
+			}
+			st as startTime
+			stSeconds as math(since(st))
+			secondsSinceStart as math(cond(stSeconds > ` + secondsSinceMonthStart + `, ` + secondsSinceMonthStart + `, stSeconds))
+			et as endTime
+			isTerminated as count(endTime)
+			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
+			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
+			cost: math(monthlyCost * durationInHours / ` + hoursInMonth + `)
+		}
+	}`
+
+	type root struct {
+		ExternalCosts []models.ExternalCost `json:"externalCosts"`
+	}
+	newRoot := root{}
+	err := builder.Execute(q, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.ExternalCosts, nil
+}
+
+// RetrieveExternalCostsInWindow returns the external costs active in the time window [from, to) with their cost
+// prorated to the hours of the window they were active in
+func RetrieveExternalCostsInWindow(from, to time.Time) ([]models.ExternalCost, error) {
+	externalCosts, err := RetrieveExternalCosts(All, All)
+	if err != nil {
+		return nil, err
+	}
+	inWindow := []models.ExternalCost{}
+	for _, externalCost := range externalCosts {
+		hours := ExternalCostHours(externalCost, from, to)
+		if hours <= 0 {
+			continue
+		}
+		externalCost.Cost = ProrateExternalCost(externalCost, hours)
+		inWindow = append(inWindow, externalCost)
+	}
+	return inWindow, nil
+}
+
+// ExternalCostHours returns the hours of the time window [from, to) during which the external cost was active
+func ExternalCostHours(externalCost models.ExternalCost, from, to time.Time) float64 {
+	start, err := time.Parse(time.RFC3339, externalCost.StartTime)
+	if err != nil {
+		return 0
+	}
+	end := to
+	if externalCost.EndTime != "" {
+		if end, err = time.Parse(time.RFC3339, externalCost.EndTime); err != nil {
+			return 0
+		}
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return end.Sub(start).Hours()
+}
+
+// ProrateExternalCost returns the cost of the external cost over the hours, prorated from its monthly cost
+func ProrateExternalCost(externalCost models.ExternalCost, hours float64) float64 {
+	return externalCost.MonthlyCost * hours / HoursInMonth
+}
+
+// AddExternalCosts adds the external costs attributed to namespaces to the total cost of their namespace as the
+// external line item, a namespace without cost in the window is added. The external costs of the groups are left to
+// their invoices.
+func AddExternalCosts(costs []ResourceCost, externalCosts []models.ExternalCost) []ResourceCost {
+	for _, externalCost := range externalCosts {
+		if externalCost.Namespace == nil || externalCost.Namespace.Xid == "" {
+			continue
+		}
+		costs = addLineItem(costs, externalCost.Namespace.Xid, LineItem{Category: ExternalLineItem,
+			Description: "spend outside of the cluster attributed to the namespace", Cost: externalCost.Cost})
+	}
+	return costs
+}
+
+// addLineItem adds the line item to the total cost of the namespace, merged with its line item of the same category
+func addLineItem(costs []ResourceCost, namespace string, item LineItem) []ResourceCost {
+	i := 0
+	for i < len(costs) && costs[i].Xid != namespace {
+		i++
+	}
+	if i == len(costs) {
+		costs = append(costs, ResourceCost{Xid: namespace, Name: namespace})
+	}
+	cost := &costs[i]
+	cost.TotalCost += item.Cost
+	for j := range cost.LineItems {
+		if cost.LineItems[j].Category == item.Category {
+			cost.LineItems[j].Quantity += item.Quantity
+			cost.LineItems[j].Cost += item.Cost
+			return costs
+		}
+	}
+	cost.LineItems = append(cost.LineItems, item)
+	return costs
+}
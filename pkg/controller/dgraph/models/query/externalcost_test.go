@@ -0,0 +1,77 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestExternalCostHours(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 11, 11, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		startTime string
+		endTime   string
+		hours     float64
+	}{
+		{"active during the window", "2018-10-01T00:00:00Z", "", 240},
+		{"started in the window", "2018-11-06T00:00:00Z", "", 120},
+		{"ended in the window", "2018-10-01T00:00:00Z", "2018-11-02T12:00:00Z", 36},
+		{"started and ended in the window", "2018-11-02T00:00:00Z", "2018-11-03T00:00:00Z", 24},
+		{"ended before the window", "2018-10-01T00:00:00Z", "2018-10-15T00:00:00Z", -408},
+		{"invalid start time", "yesterday", "", 0},
+	}
+	for _, test := range tests {
+		hours := ExternalCostHours(models.ExternalCost{StartTime: test.startTime, EndTime: test.endTime}, from, to)
+		utils.Assert(t, hours == test.hours, "%s: expected %f hours, got %f", test.name, test.hours, hours)
+	}
+	utils.Equals(t, 50.0, ProrateExternalCost(models.ExternalCost{MonthlyCost: 730}, 50))
+}
+
+func TestAddExternalAndNetworkCosts(t *testing.T) {
+	costs := []ResourceCost{{Xid: "shop", CPUCost: 1, TotalCost: 1, LineItems: []LineItem{{Category: ComputeLineItem, Cost: 1}}}}
+	externalCosts := []models.ExternalCost{
+		{ID: dgraph.ID{Xid: "shop-db"}, Cost: 0.5, Namespace: &models.Namespace{ID: dgraph.ID{Xid: "shop"}}},
+		{ID: dgraph.ID{Xid: "shop-cdn"}, Cost: 0.25, Namespace: &models.Namespace{ID: dgraph.ID{Xid: "shop"}}},
+		{ID: dgraph.ID{Xid: "search-db"}, Cost: 2, Namespace: &models.Namespace{ID: dgraph.ID{Xid: "search"}}},
+		{ID: dgraph.ID{Xid: "team-tool"}, Cost: 3, Group: &models.GroupCRD{ID: dgraph.ID{Xid: "team-a"}}},
+	}
+	costs = AddExternalCosts(costs, externalCosts)
+	costs = AddNetworkCosts(costs, []PodTraffic{
+		{Pod: "web-1", Namespace: "shop", BytesCrossZone: bytesInGB, Cost: 0.01},
+		{Pod: "web-2", Namespace: "shop", BytesInternet: 2 * bytesInGB, Cost: 0.18},
+		{Pod: "idle", Namespace: "ci"},
+	})
+
+	utils.Equals(t, 2, len(costs))
+	shop := costs[0]
+	utils.Equals(t, 3, len(shop.LineItems))
+	utils.Equals(t, LineItem{Category: ExternalLineItem, Description: "spend outside of the cluster attributed to the namespace",
+		Cost: 0.75}, shop.LineItems[1])
+	utils.Equals(t, NetworkLineItem, shop.LineItems[2].Category)
+	utils.Equals(t, 3.0, shop.LineItems[2].Quantity)
+	utils.Assert(t, shop.TotalCost > 1.9399 && shop.TotalCost < 1.9401, "total cost of shop: %f", shop.TotalCost)
+	// the external costs of a namespace without pods are charged to it
+	utils.Equals(t, ResourceCost{Xid: "search", Name: "search", TotalCost: 2, LineItems: []LineItem{{Category: ExternalLineItem,
+		Description: "spend outside of the cluster attributed to the namespace", Cost: 2}}}, costs[1])
+}
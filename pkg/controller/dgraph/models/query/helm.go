@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Helm cost groupings
+const (
+	Release = "release"
+	Chart   = "chart"
+)
+
+// HelmCost is the usage (in unit hours) and cost of a helm release or of a chart in a time window.
+// Revisions are the charts (name-version) deployed over the window, in the order they were first seen.
+type HelmCost struct {
+	Xid         string         `json:"xid"`
+	Release     string         `json:"release,omitempty"`
+	Namespace   string         `json:"namespace,omitempty"`
+	Chart       string         `json:"chart,omitempty"`
+	Revisions   []HelmRevision `json:"revisions"`
+	CPUCost     float64        `json:"cpuCost"`
+	MemoryCost  float64        `json:"memoryCost"`
+	StorageCost float64        `json:"storageCost"`
+	GPUCost     float64        `json:"gpuCost"`
+	TotalCost   float64        `json:"totalCost"`
+
+	LineItems []LineItem `json:"lineItems"`
+}
+
+// HelmRevision is the cost of the pods deployed with a chart version. LastSeen is empty while pods of the
+// chart version are running.
+type HelmRevision struct {
+	Chart     string  `json:"chart"`
+	FirstSeen string  `json:"firstSeen"`
+	LastSeen  string  `json:"lastSeen,omitempty"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+// helmPod is the cost of a pod of a helm release in the window
+type helmPod struct {
+	HelmChart   string `json:"helmChart"`
+	StartTime   string `json:"startTime"`
+	EndTime     string `json:"endTime"`
+	HelmRelease struct {
+		Xid              string `json:"xid"`
+		ReleaseName      string `json:"releaseName"`
+		ReleaseNamespace string `json:"releaseNamespace"`
+	} `json:"helmRelease"`
+	CPUCost     float64 `json:"cpuCost"`
+	MemoryCost  float64 `json:"memoryCost"`
+	StorageCost float64 `json:"storageCost"`
+	GPUCost     float64 `json:"gpuCost"`
+}
+
+// RetrieveHelmCostsInWindow returns the cost of every helm release (or of every chart if groupBy is Chart)
+// for the time window [from, to), ordered by total cost, highest first.
+func RetrieveHelmCostsInWindow(groupBy string, from, to time.Time) ([]HelmCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		var(func: has(isHelmRelease)) {
+			helmPods as ~helmRelease @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+		}
+
+		pods(func: uid(helmPods)) {
+			helmChart
+			startTime
+			endTime
+			helmRelease {
+				xid
+				releaseName
+				releaseNamespace
+			}
+			cpuCost: val(podCpuCost)
+			memoryCost: val(podMemCost)
+			storageCost: val(podStorageCost)
+			gpuCost: val(podGpuCost)
+		}
+	}`
+
+	type root struct {
+		Pods []helmPod `json:"pods"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return aggregateHelmCosts(newRoot.Pods, groupBy), nil
+}
+
+// aggregateHelmCosts sums the cost of the pods by release or by chart name (chart without version)
+func aggregateHelmCosts(pods []helmPod, groupBy string) []HelmCost {
+	costs := map[string]*HelmCost{}
+	revisions := map[string]map[string]*HelmRevision{}
+	for _, pod := range pods {
+		key := pod.HelmRelease.Xid
+		if groupBy == Chart {
+			key = chartName(pod.HelmChart)
+		}
+		cost, isPresent := costs[key]
+		if !isPresent {
+			cost = &HelmCost{Xid: key}
+			if groupBy == Chart {
+				cost.Chart = key
+			} else {
+				cost.Release = pod.HelmRelease.ReleaseName
+				cost.Namespace = pod.HelmRelease.ReleaseNamespace
+			}
+			costs[key] = cost
+			revisions[key] = map[string]*HelmRevision{}
+		}
+		podCost := pod.CPUCost + pod.MemoryCost + pod.StorageCost + pod.GPUCost
+		cost.CPUCost += pod.CPUCost
+		cost.MemoryCost += pod.MemoryCost
+		cost.StorageCost += pod.StorageCost
+		cost.GPUCost += pod.GPUCost
+		cost.TotalCost += podCost
+
+		revision, isPresent := revisions[key][pod.HelmChart]
+		if !isPresent {
+			revision = &HelmRevision{Chart: pod.HelmChart, FirstSeen: pod.StartTime, LastSeen: pod.EndTime}
+			revisions[key][pod.HelmChart] = revision
+		}
+		if pod.StartTime < revision.FirstSeen {
+			revision.FirstSeen = pod.StartTime
+		}
+		if revision.LastSeen != "" && (pod.EndTime == "" || pod.EndTime > revision.LastSeen) {
+			revision.LastSeen = pod.EndTime
+		}
+		revision.TotalCost += podCost
+	}
+
+	result := []HelmCost{}
+	for key, cost := range costs {
+		cost.Revisions = []HelmRevision{}
+		for _, revision := range revisions[key] {
+			cost.Revisions = append(cost.Revisions, *revision)
+		}
+		sort.Slice(cost.Revisions, func(i, j int) bool {
+			return cost.Revisions[i].FirstSeen < cost.Revisions[j].FirstSeen
+		})
+		cost.LineItems = podLineItems(cost.CPUCost, cost.MemoryCost, cost.GPUCost, 0, cost.StorageCost)
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalCost == result[j].TotalCost {
+			return result[i].Xid < result[j].Xid
+		}
+		return result[i].TotalCost > result[j].TotalCost
+	})
+	return result
+}
+
+// chartName returns the name of the chart from name-version (ex: nginx-ingress-1.2.3-rc.1 gives nginx-ingress).
+// The version starts at the first dash followed by a digit.
+func chartName(chart string) string {
+	for i := 1; i < len(chart)-1; i++ {
+		if chart[i] == '-' && chart[i+1] >= '0' && chart[i+1] <= '9' {
+			return chart[:i]
+		}
+	}
+	return chart
+}
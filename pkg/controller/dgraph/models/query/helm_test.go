@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func newHelmPod(release, chart, startTime, endTime string, cost float64) helmPod {
+	pod := helmPod{HelmChart: chart, StartTime: startTime, EndTime: endTime, CPUCost: cost}
+	pod.HelmRelease.Xid = "default:" + release
+	pod.HelmRelease.ReleaseName = release
+	pod.HelmRelease.ReleaseNamespace = "default"
+	return pod
+}
+
+func TestAggregateHelmCosts(t *testing.T) {
+	pods := []helmPod{
+		newHelmPod("web", "nginx-1.0.0", "2018-11-01T00:00:00Z", "2018-11-10T00:00:00Z", 1),
+		newHelmPod("web", "nginx-1.1.0", "2018-11-10T00:00:00Z", "", 2),
+		newHelmPod("web", "nginx-1.1.0", "2018-11-12T00:00:00Z", "2018-11-14T00:00:00Z", 1),
+		newHelmPod("api", "nginx-1.1.0", "2018-11-05T00:00:00Z", "2018-11-06T00:00:00Z", 3),
+	}
+
+	releases := aggregateHelmCosts(pods, Release)
+	utils.Equals(t, 2, len(releases))
+	utils.Equals(t, "default:web", releases[0].Xid)
+	utils.Equals(t, 4.0, releases[0].TotalCost)
+	utils.Equals(t, 2, len(releases[0].Revisions))
+	utils.Equals(t, "nginx-1.0.0", releases[0].Revisions[0].Chart)
+	utils.Equals(t, "nginx-1.1.0", releases[0].Revisions[1].Chart)
+	utils.Equals(t, "", releases[0].Revisions[1].LastSeen)
+
+	charts := aggregateHelmCosts(pods, Chart)
+	utils.Equals(t, 1, len(charts))
+	utils.Equals(t, "nginx", charts[0].Chart)
+	utils.Equals(t, 7.0, charts[0].TotalCost)
+}
+
+func TestChartName(t *testing.T) {
+	utils.Equals(t, "nginx-ingress", chartName("nginx-ingress-1.2.3"))
+	utils.Equals(t, "app", chartName("app-1.2.3-rc-1"))
+	utils.Equals(t, "no-version", chartName("no-version"))
+}
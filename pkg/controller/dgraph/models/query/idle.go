@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// IdleLineItem is the line item of the share of the idle node capacity charged to a namespace for its burst usage
+const IdleLineItem = "idle"
+
+// idlePod is a pod of a node in a time window with its charged cpu and memory costs, its requests, limits and
+// average usage
+type idlePod struct {
+	Namespace     *ResourceCost `json:"namespace"`
+	Hours         float64       `json:"hours"`
+	CPUCost       float64       `json:"cpuCost"`
+	MemoryCost    float64       `json:"memoryCost"`
+	CPURequest    float64       `json:"cpuRequest"`
+	CPULimit      float64       `json:"cpuLimit"`
+	CPUUsage      float64       `json:"cpuUsage"`
+	MemoryRequest float64       `json:"memoryRequest"`
+	MemoryLimit   float64       `json:"memoryLimit"`
+	MemoryUsage   float64       `json:"memoryUsage"`
+}
+
+// idleNode is a node alive in a time window with its pods
+type idleNode struct {
+	NodeCostRate
+	NodePool  string    `json:"nodePool"`
+	StartTime string    `json:"startTime"`
+	EndTime   string    `json:"endTime"`
+	Pods      []idlePod `json:"~node"`
+}
+
+// DistributeIdleCosts charges the cost of the capacity of the nodes not charged to their pods in the window
+// [from, to) to the namespaces of the burstable pods which used more than their requests, in proportion to the cost
+// of their burst usage, for the node pools whose idle cost is split by burst. Nothing is added when no pool is.
+func DistributeIdleCosts(costs []ResourceCost, from, to time.Time) error {
+	if !allocation.IsIdleSplit() {
+		return nil
+	}
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		nodes(func: has(isNode)) @filter(` + podsInWindowFilter(builder, from, to) + `) {
+			xid
+			nodePool
+			startTime
+			endTime
+			capacityType
+			cpuCapacity
+			memoryCapacity
+			pricePerHour
+			~node @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+				namespace {
+					xid
+				}
+				hours: val(durationInHours)
+				cpuCost: val(podCpuCost)
+				memoryCost: val(podMemCost)
+				cpuRequest
+				cpuLimit
+				cpuUsage
+				memoryRequest
+				memoryLimit
+				memoryUsage
+			}
+		}
+	}`
+	type root struct {
+		Nodes []idleNode `json:"nodes"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return err
+	}
+	AddIdleShares(costs, idleShares(newRoot.Nodes, from, to, pricing.GetCatalog()))
+	return nil
+}
+
+// idleShares returns the idle cost of the nodes in the window charged to every namespace. The idle cost of a pool is
+// the cost of its nodes during their life in the window less the cpu and memory costs of their pods.
+func idleShares(nodes []idleNode, from, to time.Time, catalog pricing.Catalog) map[string]float64 {
+	idle := map[string]float64{}
+	usages := map[string][]allocation.BurstUsage{}
+	for _, node := range nodes {
+		if allocation.IdleCostOf(node.NodePool) != allocation.IdleByBurst {
+			continue
+		}
+		idle[node.NodePool] += nodeCostPerHour(node.NodeCostRate) * lifeHoursInWindow(node.StartTime, node.EndTime, from, to)
+		for _, pod := range node.Pods {
+			idle[node.NodePool] -= pod.CPUCost + pod.MemoryCost
+			if pod.Namespace == nil {
+				continue
+			}
+			weight := allocation.BurstWeight(pod.CPURequest, pod.CPULimit, pod.CPUUsage, pod.MemoryRequest, pod.MemoryLimit,
+				pod.MemoryUsage, catalog.CPU, catalog.Memory) * pod.Hours
+			usages[node.NodePool] = append(usages[node.NodePool], allocation.BurstUsage{Owner: pod.Namespace.Xid, Weight: weight})
+		}
+	}
+	shares := map[string]float64{}
+	for pool, cost := range idle {
+		for namespace, share := range allocation.SplitIdleCost(cost, usages[pool]) {
+			shares[namespace] += share
+		}
+	}
+	return shares
+}
+
+// lifeHoursInWindow returns the hours of the life [start, end) of a resource inside the window, an empty end is
+// after the window
+func lifeHoursInWindow(start, end string, from, to time.Time) float64 {
+	if started, err := time.Parse(time.RFC3339, start); err == nil && started.After(from) {
+		from = started
+	}
+	if ended, err := time.Parse(time.RFC3339, end); err == nil && ended.Before(to) {
+		to = ended
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from).Hours()
+}
+
+// AddIdleShares adds the shares of the idle cost to the namespace costs as their idle line item
+func AddIdleShares(costs []ResourceCost, shares map[string]float64) {
+	for i := range costs {
+		share := shares[costs[i].Xid]
+		costs[i].LineItems = append(costs[i].LineItems, LineItem{Category: IdleLineItem, Description: "idle node capacity shared by burst usage", Cost: share})
+		costs[i].TotalCost += share
+	}
+}
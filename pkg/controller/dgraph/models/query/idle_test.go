@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIdleShares(t *testing.T) {
+	defer allocation.Setup(allocation.Settings{})
+	allocation.Setup(allocation.Settings{NodePools: []allocation.NodePoolSettings{{NodePool: "batch", IdleCost: allocation.IdleByBurst}}})
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	catalog := pricing.Catalog{CPU: 0.5, Memory: 0.25}
+	nodes := []idleNode{
+		// alive for the last 12 hours of the window at 2 per hour, its pods are charged 10
+		{NodeCostRate: NodeCostRate{PricePerHour: 2}, NodePool: "batch", StartTime: "2018-11-01T12:00:00Z", Pods: []idlePod{
+			{Namespace: &ResourceCost{Xid: "web"}, Hours: 12, CPUCost: 4, MemoryCost: 2, CPURequest: 1, CPUUsage: 2},
+			{Namespace: &ResourceCost{Xid: "jobs"}, Hours: 6, CPUCost: 3, MemoryCost: 1, MemoryRequest: 2, MemoryLimit: 8, MemoryUsage: 6},
+			{Namespace: &ResourceCost{Xid: "api"}, Hours: 12, CPURequest: 1, CPULimit: 1, CPUUsage: 2},
+		}},
+		{NodeCostRate: NodeCostRate{PricePerHour: 2}, NodePool: "web", StartTime: "2018-10-01T00:00:00Z", Pods: []idlePod{
+			{Namespace: &ResourceCost{Xid: "web"}, Hours: 24, CPURequest: 1, CPUUsage: 2},
+		}},
+	}
+
+	// web burst 1 cpu for 12 hours (6), jobs 4 GB for 6 hours (6), api cannot burst its cpu
+	shares := idleShares(nodes, from, to, catalog)
+	utils.Equals(t, map[string]float64{"web": 7, "jobs": 7}, shares)
+
+	costs := []ResourceCost{{Xid: "web", TotalCost: 10}, {Xid: "api", TotalCost: 1}}
+	AddIdleShares(costs, shares)
+	utils.Equals(t, 17.0, costs[0].TotalCost)
+	utils.Equals(t, LineItem{Category: IdleLineItem, Description: "idle node capacity shared by burst usage", Cost: 7}, costs[0].LineItems[0])
+	utils.Equals(t, 1.0, costs[1].TotalCost)
+}
+
+func TestLifeHoursInWindow(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	utils.Equals(t, 24.0, lifeHoursInWindow("2018-10-01T00:00:00Z", "", from, to))
+	utils.Equals(t, 6.0, lifeHoursInWindow("2018-11-01T12:00:00Z", "2018-11-01T18:00:00Z", from, to))
+	utils.Equals(t, 0.0, lifeHoursInWindow("2018-11-03T00:00:00Z", "", from, to))
+}
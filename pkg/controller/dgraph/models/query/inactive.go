@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// InactiveDeployment is a running deployment whose pods used almost no cpu and received no interactions from other
+// pods for the inactivity period, with the cost of its pods over that period
+type InactiveDeployment struct {
+	Xid                  string  `json:"xid"`
+	Namespace            string  `json:"namespace"`
+	Name                 string  `json:"name"`
+	Replicas             int     `json:"replicas"`
+	ActivityTrackedSince string  `json:"activityTrackedSince"`
+	LastActiveTime       string  `json:"lastActiveTime,omitempty"`
+	TotalCost            float64 `json:"totalCost"`
+}
+
+// RetrieveInactiveDeployments returns the deployments having running pods which were not active in the last days:
+// their cpu usage is sampled since then and stayed below the activity threshold, and no pod calls them.
+// They are ordered by the cost of the period, highest first.
+func RetrieveInactiveDeployments(days int, now time.Time) ([]InactiveDeployment, error) {
+	since := now.AddDate(0, 0, -days)
+	builder := dgraph.NewReplicaQueryBuilder()
+	sinceValue := builder.Time(since)
+	query := `{
+		deps as var(func: has(isDeployment)) @filter(NOT has(endTime) AND le(activityTrackedSince, ` + sinceValue + `) AND (NOT has(lastActiveTime) OR lt(lastActiveTime, ` + sinceValue + `))) {
+			~deployment @filter(has(isReplicaset)) {
+				` + workloadPodsCost(builder, "~replicaset", since, now) + `
+				replicasetCost as sum(val(podCost))
+			}
+			workloadCost as sum(val(replicasetCost))
+		}
+
+		deployments(func: uid(deps)) {
+			xid
+			activityTrackedSince
+			lastActiveTime
+			totalCost: val(workloadCost)
+			replicasets: ~deployment @filter(has(isReplicaset)) {
+				pods: ~replicaset @filter(has(isPod) AND NOT has(endTime)) {
+					inbound: ~pod (first: 1) @filter(has(isPod)) {
+						uid
+					}
+				}
+			}
+		}
+	}`
+
+	type pod struct {
+		Inbound []struct{} `json:"inbound"`
+	}
+	type deployment struct {
+		InactiveDeployment
+		Replicasets []struct {
+			Pods []pod `json:"pods"`
+		} `json:"replicasets"`
+	}
+	type root struct {
+		Deployments []deployment `json:"deployments"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+
+	inactive := []InactiveDeployment{}
+	for _, d := range newRoot.Deployments {
+		called := false
+		result := d.InactiveDeployment
+		for _, rs := range d.Replicasets {
+			for _, p := range rs.Pods {
+				result.Replicas++
+				called = called || len(p.Inbound) > 0
+			}
+		}
+		if result.Replicas == 0 || called {
+			continue
+		}
+		parts := strings.SplitN(result.Xid, ":", 2)
+		if len(parts) == 2 {
+			result.Namespace, result.Name = parts[0], parts[1]
+		}
+		inactive = append(inactive, result)
+	}
+	sort.SliceStable(inactive, func(i, j int) bool {
+		return inactive[i].TotalCost > inactive[j].TotalCost
+	})
+	return inactive, nil
+}
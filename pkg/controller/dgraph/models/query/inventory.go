@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Inventory structure gives the cluster resources which were alive at a point in time
+type Inventory struct {
+	Time           string               `json:"time"`
+	Nodes          int                  `json:"nodes"`
+	CPUCapacity    float64              `json:"cpuCapacity"`
+	MemoryCapacity float64              `json:"memoryCapacity"`
+	GPUCapacity    float64              `json:"gpuCapacity"`
+	Namespaces     []NamespaceInventory `json:"namespaces"`
+}
+
+// NamespaceInventory structure gives the resources of a namespace which were alive at a point in time
+type NamespaceInventory struct {
+	Name            string  `json:"name"`
+	Pods            int     `json:"pods"`
+	CPURequest      float64 `json:"cpuRequest"`
+	MemoryRequest   float64 `json:"memoryRequest"`
+	GPURequest      float64 `json:"gpuRequest"`
+	Pvcs            int     `json:"pvcs"`
+	StorageCapacity float64 `json:"storageCapacity"`
+	Services        int     `json:"services"`
+}
+
+// RetrieveInventory reconstructs the cluster inventory as of the given time from the start and end times
+// of nodes, pods, pvcs and services.
+func RetrieveInventory(at time.Time) (Inventory, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	alive := aliveAtFilter(builder, at)
+	query := `{
+		nodes as var(func: has(isNode)) @filter(` + alive + `) {
+			nodeCpu as cpuCapacity
+			nodeMem as memoryCapacity
+			nodeGpu as gpuCapacity
+		}
+		ns as var(func: has(isNamespace)) @filter(` + alive + `) {
+			alivePods: ~namespace @filter(has(isPod) AND ` + alive + `) {
+				podCpu as cpuRequest
+				podMem as memoryRequest
+				podGpu as gpuRequest
+			}
+			alivePvcs: ~namespace @filter(has(isPersistentVolumeClaim) AND ` + alive + `) {
+				pvcStorage as storageCapacity
+			}
+			namespacePods as count(~namespace @filter(has(isPod) AND ` + alive + `))
+			namespacePvcs as count(~namespace @filter(has(isPersistentVolumeClaim) AND ` + alive + `))
+			namespaceServices as count(~namespace @filter(has(isService) AND ` + alive + `))
+			namespaceCpu as sum(val(podCpu))
+			namespaceMem as sum(val(podMem))
+			namespaceGpu as sum(val(podGpu))
+			namespaceStorage as sum(val(pvcStorage))
+		}
+
+		nodeCount(func: uid(nodes)) {
+			count: count(uid)
+		}
+		nodeCapacity() {
+			cpuCapacity: sum(val(nodeCpu))
+			memoryCapacity: sum(val(nodeMem))
+			gpuCapacity: sum(val(nodeGpu))
+		}
+		namespaces(func: uid(ns), orderasc: name) {
+			name: xid
+			pods: val(namespacePods)
+			cpuRequest: val(namespaceCpu)
+			memoryRequest: val(namespaceMem)
+			gpuRequest: val(namespaceGpu)
+			pvcs: val(namespacePvcs)
+			storageCapacity: val(namespaceStorage)
+			services: val(namespaceServices)
+		}
+	}`
+
+	newRoot := inventoryResponse{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return Inventory{Time: utils.ConverTimeToRFC3339(at)}, err
+	}
+	return newRoot.inventory(at), nil
+}
+
+// inventoryResponse is the response of the inventory query
+type inventoryResponse struct {
+	NodeCount []struct {
+		Count int `json:"count"`
+	} `json:"nodeCount"`
+	NodeCapacity []struct {
+		CPUCapacity    float64 `json:"cpuCapacity"`
+		MemoryCapacity float64 `json:"memoryCapacity"`
+		GPUCapacity    float64 `json:"gpuCapacity"`
+	} `json:"nodeCapacity"`
+	Namespaces []NamespaceInventory `json:"namespaces"`
+}
+
+// inventory returns the inventory of the response at the given time
+func (r inventoryResponse) inventory(at time.Time) Inventory {
+	inventory := Inventory{Time: utils.ConverTimeToRFC3339(at), Namespaces: r.Namespaces}
+	if len(r.NodeCount) > 0 {
+		inventory.Nodes = r.NodeCount[0].Count
+	}
+	for _, capacity := range r.NodeCapacity {
+		inventory.CPUCapacity += capacity.CPUCapacity
+		inventory.MemoryCapacity += capacity.MemoryCapacity
+		inventory.GPUCapacity += capacity.GPUCapacity
+	}
+	return inventory
+}
+
+// aliveAtFilter selects the resources which were started and not yet terminated at the given time.
+// Resources without a start time (ex: namespaces created from a pod event) are considered alive since ever.
+func aliveAtFilter(builder *dgraph.QueryBuilder, at time.Time) string {
+	atVariable := builder.Time(at)
+	return `(NOT has(startTime) OR le(startTime, ` + atVariable + `)) AND (NOT has(endTime) OR gt(endTime, ` + atVariable + `))`
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAliveAtFilter(t *testing.T) {
+	at := time.Date(2018, 11, 5, 10, 0, 0, 0, time.UTC)
+	builder := dgraph.NewQueryBuilder()
+	filter := aliveAtFilter(builder, at)
+	// resources terminated at the time are not alive, resources started at the time are
+	utils.Equals(t, "(NOT has(startTime) OR le(startTime, $v0)) AND (NOT has(endTime) OR gt(endTime, $v0))", filter)
+	_, variables := builder.Build("{}")
+	utils.Equals(t, map[string]string{"$v0": "2018-11-05T10:00:00Z"}, variables)
+}
+
+func TestInventoryResponse(t *testing.T) {
+	response := `{
+		"nodeCount": [{"count": 3}],
+		"nodeCapacity": [{"cpuCapacity": 8, "memoryCapacity": 32}, {"cpuCapacity": 4, "memoryCapacity": 16, "gpuCapacity": 1}],
+		"namespaces": [{"name": "shop", "pods": 2, "cpuRequest": 1.5, "pvcs": 1, "storageCapacity": 10, "services": 1}]
+	}`
+	newRoot := inventoryResponse{}
+	utils.Ok(t, json.Unmarshal([]byte(response), &newRoot))
+	inventory := newRoot.inventory(time.Date(2018, 11, 5, 10, 0, 0, 0, time.UTC))
+	utils.Equals(t, "2018-11-05T10:00:00Z", inventory.Time)
+	utils.Equals(t, 3, inventory.Nodes)
+	utils.Equals(t, 12.0, inventory.CPUCapacity)
+	utils.Equals(t, 48.0, inventory.MemoryCapacity)
+	utils.Equals(t, 1.0, inventory.GPUCapacity)
+	utils.Equals(t, []NamespaceInventory{{Name: "shop", Pods: 2, CPURequest: 1.5, Pvcs: 1, StorageCapacity: 10, Services: 1}},
+		inventory.Namespaces)
+
+	// a cluster without nodes at the time
+	empty := inventoryResponse{}.inventory(time.Date(2018, 11, 5, 10, 0, 0, 0, time.UTC))
+	utils.Equals(t, 0, empty.Nodes)
+	utils.Equals(t, 0, len(empty.Namespaces))
+}
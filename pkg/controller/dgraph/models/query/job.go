@@ -21,17 +21,19 @@ import (
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrieveJobHierarchy returns hierarchy for a given daemonset
 func RetrieveJobHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for job, empty name is given")
 		return JSONDataWrapper{}
 	}
-	query := `query {
-		parent(func: has(isJob)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isJob)) {
 			name
 			type
 			children: ~job @filter(has(isPod)) {
@@ -40,18 +42,19 @@ func RetrieveJobHierarchy(name string) JSONDataWrapper {
 			}
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrieveJobMetrics returns metrics for a given daemonset
 func RetrieveJobMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for job, empty name is given")
 		return JSONDataWrapper{}
 	}
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		parent(func: has(isJob)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isJob)) {
 			name
 			type
 			children: ~job @filter(has(isPod)) {
@@ -67,9 +70,9 @@ func RetrieveJobMetrics(name string) JSONDataWrapper {
 				isTerminated as count(endTime)
 				secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 				durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-				cpuCost: podCpuCost as math(podCpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-				memoryCost: podMemCost as math(podMemory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-				storageCost: pvcStorageCost as math(pvcStorage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+				cpuCost: podCpuCost as math(podCpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+				memoryCost: podMemCost as math(podMemory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+				storageCost: pvcStorageCost as math(pvcStorage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 			}
 			cpu: sum(val(podCpu))
 			memory: sum(val(podMemory))
@@ -79,5 +82,5 @@ func RetrieveJobMetrics(name string) JSONDataWrapper {
 			storageCost: sum(val(pvcStorageCost))
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
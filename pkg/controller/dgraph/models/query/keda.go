@@ -0,0 +1,208 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// baselineReplicas is the minimum number of replicas a workload would run without scaling to zero
+const baselineReplicas = 1
+
+// ScaledObject is a KEDA ScaledObject with the workload it scales
+type ScaledObject struct {
+	Namespace   string   `json:"namespace"`
+	Name        string   `json:"name"`
+	TargetKind  string   `json:"targetKind"`
+	TargetName  string   `json:"targetName"`
+	MinReplicas int      `json:"minReplicas"`
+	MaxReplicas int      `json:"maxReplicas"`
+	Triggers    []string `json:"triggers,omitempty"`
+}
+
+// ScaleToZeroSavings is the cost of the workload of a ScaledObject in a time window and the cost saved during the
+// hours it ran no replica, compared to a baseline of one replica priced at the average cost of a replica hour
+type ScaleToZeroSavings struct {
+	ScaledObject
+	ReplicaHours       float64 `json:"replicaHours"`
+	ZeroReplicaHours   float64 `json:"zeroReplicaHours"`
+	Cost               float64 `json:"cost"`
+	CostPerReplicaHour float64 `json:"costPerReplicaHour"`
+	Savings            float64 `json:"savings"`
+}
+
+// KEDAReport gives the savings of the KEDA ScaledObjects in a time window and the deployments which are candidates
+// for KEDA adoption: they are not scaled by KEDA and were idle during the last day, Candidates give their cost of
+// the last day.
+type KEDAReport struct {
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	ScaledObjects []ScaleToZeroSavings `json:"scaledObjects"`
+	TotalSavings  float64              `json:"totalSavings"`
+	Candidates    []InactiveDeployment `json:"candidates"`
+}
+
+// podInterval is the time a pod ran in a window with its cost
+type podInterval struct {
+	start, end time.Time
+	cost       float64
+}
+
+// RetrieveKEDAReport returns the scale to zero savings of the given ScaledObjects for the time window [from, to)
+// and the candidates for KEDA adoption
+func RetrieveKEDAReport(objects []ScaledObject, from, to time.Time) (KEDAReport, error) {
+	report := KEDAReport{From: from.Format(DateFormat), To: to.Format(DateFormat), ScaledObjects: []ScaleToZeroSavings{}}
+	now := time.Now()
+	if to.After(now) {
+		to = now
+	}
+	targets := map[string]bool{}
+	for _, object := range objects {
+		targets[object.TargetKind+"/"+object.Namespace+":"+object.TargetName] = true
+		pods, err := retrieveTargetPods(object, from, to)
+		if err != nil {
+			return report, err
+		}
+		savings := scaleToZeroSavings(object, pods, from, to)
+		report.TotalSavings += savings.Savings
+		report.ScaledObjects = append(report.ScaledObjects, savings)
+	}
+	sort.SliceStable(report.ScaledObjects, func(i, j int) bool {
+		return report.ScaledObjects[i].Savings > report.ScaledObjects[j].Savings
+	})
+
+	idle, err := RetrieveInactiveDeployments(1, now)
+	if err != nil {
+		return report, err
+	}
+	report.Candidates = []InactiveDeployment{}
+	for _, deployment := range idle {
+		if !targets[DeploymentKind+"/"+deployment.Xid] {
+			report.Candidates = append(report.Candidates, deployment)
+		}
+	}
+	return report, nil
+}
+
+// retrieveTargetPods returns the time and cost in the window of the pods of the deployment or statefulset scaled
+// by the ScaledObject
+func retrieveTargetPods(object ScaledObject, from, to time.Time) ([]podInterval, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	target := `~deployment @filter(has(isReplicaset)) {
+				p as ~replicaset @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `)
+			}`
+	marker := "isDeployment"
+	if object.TargetKind == StatefulSetKind {
+		target = `p as ~statefulset @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `)`
+		marker = "isStatefulset"
+	}
+	query := `{
+		var(func: ` + builder.Eq("xid", object.Namespace+":"+object.TargetName) + `) @filter(has(` + marker + `)) {
+			` + target + `
+		}
+
+		pods(func: uid(p)) {
+			` + podCostInWindow(from, to) + `
+			cost: math(podCpuCost + podMemCost + podStorageCost + podGpuCost)
+		}
+	}`
+
+	type pod struct {
+		StartTime string  `json:"startTime"`
+		EndTime   string  `json:"endTime"`
+		Cost      float64 `json:"cost"`
+	}
+	type root struct {
+		Pods []pod `json:"pods"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	intervals := []podInterval{}
+	for _, p := range newRoot.Pods {
+		start, err := time.Parse(time.RFC3339, p.StartTime)
+		if err != nil {
+			continue
+		}
+		end := to
+		if endTime, err := time.Parse(time.RFC3339, p.EndTime); err == nil {
+			end = endTime
+		}
+		intervals = append(intervals, podInterval{start: start, end: end, cost: p.Cost})
+	}
+	return intervals, nil
+}
+
+// scaleToZeroSavings computes the replica hours and the hours without replica of the pods in the window [from, to).
+// Only ScaledObjects scaling to zero save cost.
+func scaleToZeroSavings(object ScaledObject, pods []podInterval, from, to time.Time) ScaleToZeroSavings {
+	savings := ScaleToZeroSavings{ScaledObject: object}
+	if !to.After(from) {
+		return savings
+	}
+	var clipped []podInterval
+	for _, pod := range pods {
+		start, end := pod.start, pod.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		savings.Cost += pod.cost
+		if end.After(start) {
+			savings.ReplicaHours += end.Sub(start).Hours()
+			clipped = append(clipped, podInterval{start: start, end: end})
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool {
+		return clipped[i].start.Before(clipped[j].start)
+	})
+
+	// hours of the window covered by at least one replica
+	covered := 0.0
+	var current *podInterval
+	for i := range clipped {
+		if current != nil && !clipped[i].start.After(current.end) {
+			if clipped[i].end.After(current.end) {
+				current.end = clipped[i].end
+			}
+			continue
+		}
+		if current != nil {
+			covered += current.end.Sub(current.start).Hours()
+		}
+		current = &clipped[i]
+	}
+	if current != nil {
+		covered += current.end.Sub(current.start).Hours()
+	}
+
+	savings.ZeroReplicaHours = to.Sub(from).Hours() - covered
+	if savings.ReplicaHours > 0 {
+		savings.CostPerReplicaHour = savings.Cost / savings.ReplicaHours
+	}
+	if object.MinReplicas == 0 {
+		savings.Savings = savings.ZeroReplicaHours * baselineReplicas * savings.CostPerReplicaHour
+	}
+	return savings
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestScaleToZeroSavings(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	pods := []podInterval{
+		// started before the window, overlaps the second pod
+		{start: from.Add(-2 * time.Hour), end: from.Add(4 * time.Hour), cost: 4},
+		{start: from.Add(2 * time.Hour), end: from.Add(6 * time.Hour), cost: 4},
+		{start: from.Add(20 * time.Hour), end: to.Add(time.Hour), cost: 4},
+	}
+
+	savings := scaleToZeroSavings(ScaledObject{Name: "worker"}, pods, from, to)
+	utils.Equals(t, 12.0, savings.ReplicaHours)
+	utils.Equals(t, 14.0, savings.ZeroReplicaHours)
+	utils.Equals(t, 1.0, savings.CostPerReplicaHour)
+	utils.Equals(t, 14.0, savings.Savings)
+
+	savings = scaleToZeroSavings(ScaledObject{Name: "worker", MinReplicas: 1}, pods, from, to)
+	utils.Equals(t, 0.0, savings.Savings)
+}
@@ -17,9 +17,11 @@
 
 package query
 
+import "github.com/vmware/purser/pkg/controller/dgraph"
+
 // createFilterFromListOfLabels will return a filter logic like
-// (eq(key, "k1") AND eq(value, "v1")) OR (eq(key, "k1") AND eq(value, "v1")) OR (eq(key, "k1") AND eq(value, "v1"))
-func createFilterFromListOfLabels(labels map[string]string) string {
+// (eq(key, $v0) AND eq(value, $v1)) OR (eq(key, $v2) AND eq(value, $v3)) with the keys and values as query variables
+func createFilterFromListOfLabels(builder *dgraph.QueryBuilder, labels map[string]string) string {
 	separator := " OR "
 	var filter string
 	isFirst := true
@@ -29,12 +31,12 @@ func createFilterFromListOfLabels(labels map[string]string) string {
 		} else {
 			isFirst = false
 		}
-		filter += createFilterFromLabel(key, value)
+		filter += createFilterFromLabel(builder, key, value)
 	}
 	return filter
 }
 
-// createFilterFromLabel takes key: k1, value: v1 and returns (eq(key, "k1") AND eq(value, "v1"))
-func createFilterFromLabel(key, value string) string {
-	return `(eq(key, "` + key + `") AND eq(value, "` + value + `"))`
+// createFilterFromLabel takes key: k1, value: v1 and returns (eq(key, $v0) AND eq(value, $v1)) where $v0 is k1 and $v1 is v1
+func createFilterFromLabel(builder *dgraph.QueryBuilder, key, value string) string {
+	return `(` + builder.Eq("key", key) + ` AND ` + builder.Eq("value", value) + `)`
 }
@@ -18,28 +18,37 @@
 package query
 
 import (
-	"github.com/vmware/purser/test/utils"
 	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
 )
 
 // TestCreateFilterForLabel ...
 func TestCreateFilterFromLabel(t *testing.T) {
-	got := createFilterFromLabel("k1", "v1")
-	expected := `(eq(key, "k1") AND eq(value, "v1"))`
+	builder := dgraph.NewQueryBuilder()
+	got := createFilterFromLabel(builder, "k1", "v1")
+	expected := `(eq(key, $v0) AND eq(value, $v1))`
 	utils.Equals(t, expected, got)
+
+	_, variables := builder.Build("{}")
+	utils.Equals(t, map[string]string{"$v0": "k1", "$v1": "v1"}, variables)
 }
 
 // TestCreateFilterFromListOfLabels ...
 func TestCreateFilterFromListOfLabels(t *testing.T) {
 	labels := make(map[string]string)
 	labels["k1"] = "v1"
-	got := createFilterFromListOfLabels(labels)
-	expected := `(eq(key, "k1") AND eq(value, "v1"))`
+	got := createFilterFromListOfLabels(dgraph.NewQueryBuilder(), labels)
+	expected := `(eq(key, $v0) AND eq(value, $v1))`
 	utils.Equals(t, expected, got)
 
 	labels["k2"] = "v2"
-	got2 := createFilterFromListOfLabels(labels)
-	expected1 := `(eq(key, "k2") AND eq(value, "v2")) OR (eq(key, "k1") AND eq(value, "v1"))`
-	expected2 := `(eq(key, "k1") AND eq(value, "v1")) OR (eq(key, "k2") AND eq(value, "v2"))`
-	utils.Assert(t, (got2 == expected1) || (got2 == expected2), "label filter didn't match")
+	builder := dgraph.NewQueryBuilder()
+	got2 := createFilterFromListOfLabels(builder, labels)
+	expected2 := `(eq(key, $v0) AND eq(value, $v1)) OR (eq(key, $v2) AND eq(value, $v3))`
+	utils.Equals(t, expected2, got2)
+
+	_, variables := builder.Build("{}")
+	utils.Assert(t, (variables["$v0"] == "k1" && variables["$v1"] == "v1") || (variables["$v0"] == "k2" && variables["$v1"] == "v2"), "label filter variables didn't match")
 }
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Cost line item categories
+const (
+	ComputeLineItem          = "compute"
+	PersistentVolumeLineItem = "persistentVolumes"
+	SnapshotLineItem         = "snapshots"
+	LoadBalancerLineItem     = "loadBalancers"
+)
+
+// LineItem is the cost of a category of resources of a scope (namespace, pod, group, application...) in a time
+// window. Every scope endpoint reports its cost with the same categories, the total cost is the sum of them.
+type LineItem struct {
+	Category    string  `json:"category"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity,omitempty"`
+	Unit        string  `json:"unit,omitempty"`
+	Cost        float64 `json:"cost"`
+}
+
+// NamespaceResources is the usage (in unit hours) and cost of the resources of a namespace which are not
+// charged through its pods
+type NamespaceResources struct {
+	ClaimStorage     float64 `json:"claimStorage"`
+	ClaimCost        float64 `json:"claimCost"`
+	SnapshotStorage  float64 `json:"snapshotStorage"`
+	SnapshotCost     float64 `json:"snapshotCost"`
+	LoadBalancers    float64 `json:"loadBalancers"`
+	LoadBalancerCost float64 `json:"loadBalancerCost"`
+}
+
+// podLineItems returns the line items of a scope made of pods: compute (cpu, memory and gpus requested by the
+// pods) and the persistent volumes attached to the pods. Snapshots and load balancers are not owned by pods,
+// so their line items are zero.
+func podLineItems(cpuCost, memoryCost, gpuCost float64, storage utils.GBHours, storageCost float64) []LineItem {
+	return []LineItem{
+		{Category: ComputeLineItem, Description: "cpu, memory and gpus requested by pods", Cost: cpuCost + memoryCost + gpuCost},
+		{Category: PersistentVolumeLineItem, Description: "persistent volumes attached to pods", Quantity: float64(storage), Unit: "GB hours", Cost: storageCost},
+		{Category: SnapshotLineItem, Description: "volume snapshots", Unit: "GB hours"},
+		{Category: LoadBalancerLineItem, Description: "load balancer services", Unit: "hours"},
+	}
+}
+
+// NamespaceLineItems returns the line items of a namespace. Persistent volumes are charged for the whole life of
+// their claims, including the time they are not mounted by any pod, so the storage of the pods is not added.
+func NamespaceLineItems(cost ResourceCost, resources NamespaceResources) []LineItem {
+	return []LineItem{
+		{Category: ComputeLineItem, Description: "cpu, memory and gpus requested by pods", Cost: cost.CPUCost + cost.MemoryCost + cost.GPUCost},
+		{Category: PersistentVolumeLineItem, Description: "persistent volume claims", Quantity: resources.ClaimStorage, Unit: "GB hours", Cost: resources.ClaimCost},
+		{Category: SnapshotLineItem, Description: "volume snapshots", Quantity: resources.SnapshotStorage, Unit: "GB hours", Cost: resources.SnapshotCost},
+		{Category: LoadBalancerLineItem, Description: "load balancer services", Quantity: resources.LoadBalancers, Unit: "hours", Cost: resources.LoadBalancerCost},
+	}
+}
+
+func lineItemsTotal(items []LineItem) float64 {
+	total := 0.0
+	for _, item := range items {
+		total += item.Cost
+	}
+	return total
+}
+
+// namespaceResourcesInWindow defines the claimStorage, claimCost, snapshotStorage, snapshotCost, loadBalancers and
+// loadBalancerCost variables of the namespaces in the uid variable nsVar for the time window [from, to)
+func namespaceResourcesInWindow(builder *dgraph.QueryBuilder, nsVar string, from, to time.Time) string {
+	return `var(func: uid(` + nsVar + `)) {
+			claims: ~namespace @filter(has(isPersistentVolumeClaim) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + lifetimeInWindow("claim", from, to) + `
+				claimCapacity as storageCapacity
+				claimGBHours as math(claimCapacity * claimHours)
+				claimGBCost as math(claimCapacity * claimHours * ` + storageCostPerGBPerHour("claimSecondsSinceStart", "claimSecondsSinceEnd") + `)
+			}
+			snapshots: ~namespace @filter(has(isVolumeSnapshot) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + lifetimeInWindow("snapshot", from, to) + `
+				snapshotSize as restoreSize
+				snapshotGBHours as math(snapshotSize * snapshotHours)
+				snapshotGBCost as math(snapshotSize * snapshotHours * ` + snapshotCostPerGBPerHour("snapshotSecondsSinceStart", "snapshotSecondsSinceEnd") + `)
+			}
+			loadBalancers: ~namespace @filter(has(isService) AND ` + builder.Eq("serviceType", "LoadBalancer") + ` AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + lifetimeInWindow("lb", from, to) + `
+				lbCost as math(lbHours * ` + loadBalancerCostPerHour("lbSecondsSinceStart", "lbSecondsSinceEnd") + `)
+			}
+			claimStorage as sum(val(claimGBHours))
+			claimCost as sum(val(claimGBCost))
+			snapshotStorage as sum(val(snapshotGBHours))
+			snapshotCost as sum(val(snapshotGBCost))
+			loadBalancers as sum(val(lbHours))
+			loadBalancerCost as sum(val(lbCost))
+		}`
+}
+
+// namespaceResourcesFields returns the fields of the variables defined by namespaceResourcesInWindow
+func namespaceResourcesFields() string {
+	return `claimStorage: val(claimStorage)
+			claimCost: val(claimCost)
+			snapshotStorage: val(snapshotStorage)
+			snapshotCost: val(snapshotCost)
+			loadBalancers: val(loadBalancers)
+			loadBalancerCost: val(loadBalancerCost)`
+}
+
+// lifetimeInWindow defines the <prefix>SecondsSinceStart, <prefix>SecondsSinceEnd and <prefix>Hours variables of a
+// resource having startTime and endTime for the part of its life inside the time window [from, to)
+func lifetimeInWindow(prefix string, from, to time.Time) string {
+	secondsSinceFrom := fmt.Sprintf("%f", utils.GetSecondsSince(from))
+	secondsSinceTo := fmt.Sprintf("%f", utils.GetSecondsSince(to))
+	return prefix + `ST as startTime
+				` + prefix + `STSeconds as math(since(` + prefix + `ST))
+				` + prefix + `SecondsSinceStart as math(cond(` + prefix + `STSeconds > ` + secondsSinceFrom + `, ` + secondsSinceFrom + `, ` + prefix + `STSeconds))
+				` + prefix + `ET as endTime
+				` + prefix + `IsTerminated as count(endTime)
+				` + prefix + `ETSeconds as math(cond(` + prefix + `IsTerminated == 0, 0.0, since(` + prefix + `ET)))
+				` + prefix + `SecondsSinceEnd as math(cond(` + prefix + `ETSeconds < ` + secondsSinceTo + `, ` + secondsSinceTo + `, ` + prefix + `ETSeconds))
+				` + prefix + `Hours as math(cond(` + prefix + `SecondsSinceStart > ` + prefix + `SecondsSinceEnd, (` + prefix + `SecondsSinceStart - ` + prefix + `SecondsSinceEnd) / 3600, 0.0))`
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestNamespaceLineItems(t *testing.T) {
+	cost := ResourceCost{CPUCost: 1, MemoryCost: 2, GPUCost: 3, Storage: 10, StorageCost: 0.5}
+	resources := NamespaceResources{ClaimStorage: 20, ClaimCost: 1, SnapshotStorage: 5, SnapshotCost: 0.25, LoadBalancers: 4, LoadBalancerCost: 0.1}
+
+	items := NamespaceLineItems(cost, resources)
+	utils.Equals(t, 4, len(items))
+	utils.Equals(t, ComputeLineItem, items[0].Category)
+	utils.Equals(t, 6.0, items[0].Cost)
+	// storage of the pods is part of the claims, it is not charged twice
+	utils.Equals(t, 20.0, items[1].Quantity)
+	utils.Equals(t, 7.35, lineItemsTotal(items))
+}
+
+func TestPodLineItems(t *testing.T) {
+	items := podLineItems(1, 2, 0, 10, 0.5)
+	utils.Equals(t, 3.5, lineItemsTotal(items))
+	utils.Equals(t, PersistentVolumeLineItem, items[1].Category)
+	utils.Equals(t, 10.0, items[1].Quantity)
+	utils.Equals(t, 0.0, items[3].Cost)
+}
@@ -22,17 +22,19 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrieveNamespaceHierarchy returns hierarchy for a given namespace
 func RetrieveNamespaceHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		return RetrieveClusterHierarchy(Logical)
 	}
 
-	query := `query {
-		parent(func: has(isNamespace)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isNamespace)) {
 			name
 			type
 			children: ~namespace @filter(has(isDeployment) OR has(isStatefulset) OR has(isJob) OR has(isDaemonset) OR (has(isReplicaset) AND (NOT has(deployment)))) {
@@ -41,18 +43,19 @@ func RetrieveNamespaceHierarchy(name string) JSONDataWrapper {
 			}
         }
     }`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrieveNamespaceMetrics returns metrics for a given namespace
 func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		return RetrieveClusterHierarchy(Logical)
 	}
 
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		ns as var(func: has(isNamespace)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		ns as var(func: ` + builder.Eq("name", name) + `) @filter(has(isNamespace)) {
 			childs as ~namespace @filter(has(isDeployment) OR has(isStatefulset) OR has(isJob) OR has(isDaemonset) OR (has(isReplicaset) AND (NOT has(deployment)))) {
 				name
 				type
@@ -72,9 +75,9 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 						replicasetPodIsTerminated as count(endTime)
 						replicasetPodSecondsSinceEnd as math(cond(replicasetPodIsTerminated == 0, 0.0, since(replicasetPodET)))
 						replicasetPodDurationInHours as math((replicasetPodSecondsSinceStart - replicasetPodSecondsSinceEnd) / 3600)
-						replicasetPodCpuCost as math(replicasetPodCpu * replicasetPodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-						replicasetPodMemoryCost as math(replicasetPodMemory * replicasetPodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-						replicasetPvcStorageCost as math(replicasetPvcStorage * replicasetPodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+						replicasetPodCpuCost as math(replicasetPodCpu * replicasetPodDurationInHours * ` + cpuCostPerCPUPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
+						replicasetPodMemoryCost as math(replicasetPodMemory * replicasetPodDurationInHours * ` + memCostPerGBPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
+						replicasetPvcStorageCost as math(replicasetPvcStorage * replicasetPodDurationInHours * ` + storageCostPerGBPerHour("replicasetPodSecondsSinceStart", "replicasetPodSecondsSinceEnd") + `)
 			        }
 					deploymentReplicasetCpu as sum(val(replicasetPodCpu))
 			        deploymentReplicasetMemory as sum(val(replicasetPodMemory))
@@ -96,9 +99,9 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 					statefulsetPodIsTerminated as count(endTime)
 					statefulsetPodSecondsSinceEnd as math(cond(statefulsetPodIsTerminated == 0, 0.0, since(statefulsetPodET)))
 					statefulsetPodDurationInHours as math((statefulsetPodSecondsSinceStart - statefulsetPodSecondsSinceEnd) / 3600)
-					statefulsetPodCpuCost as math(statefulsetPodCpu * statefulsetPodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					statefulsetPodMemoryCost as math(statefulsetPodMemory * statefulsetPodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-					statefulsetPvcStorageCost as math(statefulsetPvcStorage * statefulsetPodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					statefulsetPodCpuCost as math(statefulsetPodCpu * statefulsetPodDurationInHours * ` + cpuCostPerCPUPerHour("statefulsetPodSecondsSinceStart", "statefulsetPodSecondsSinceEnd") + `)
+					statefulsetPodMemoryCost as math(statefulsetPodMemory * statefulsetPodDurationInHours * ` + memCostPerGBPerHour("statefulsetPodSecondsSinceStart", "statefulsetPodSecondsSinceEnd") + `)
+					statefulsetPvcStorageCost as math(statefulsetPvcStorage * statefulsetPodDurationInHours * ` + storageCostPerGBPerHour("statefulsetPodSecondsSinceStart", "statefulsetPodSecondsSinceEnd") + `)
                 }
 				~job @filter(has(isPod)) {
                     name
@@ -113,9 +116,9 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 					jobPodIsTerminated as count(endTime)
 					jobPodSecondsSinceEnd as math(cond(jobPodIsTerminated == 0, 0.0, since(jobPodET)))
 					jobPodDurationInHours as math((jobPodSecondsSinceStart - jobPodSecondsSinceEnd) / 3600)
-					jobPodCpuCost as math(jobPodCpu * jobPodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					jobPodMemoryCost as math(jobPodMemory * jobPodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-					jobPvcStorageCost as math(jobPvcStorage * jobPodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					jobPodCpuCost as math(jobPodCpu * jobPodDurationInHours * ` + cpuCostPerCPUPerHour("jobPodSecondsSinceStart", "jobPodSecondsSinceEnd") + `)
+					jobPodMemoryCost as math(jobPodMemory * jobPodDurationInHours * ` + memCostPerGBPerHour("jobPodSecondsSinceStart", "jobPodSecondsSinceEnd") + `)
+					jobPvcStorageCost as math(jobPvcStorage * jobPodDurationInHours * ` + storageCostPerGBPerHour("jobPodSecondsSinceStart", "jobPodSecondsSinceEnd") + `)
                 }
 				~daemonset @filter(has(isPod)) {
                     name
@@ -130,9 +133,9 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 					daemonsetPodIsTerminated as count(endTime)
 					daemonsetPodSecondsSinceEnd as math(cond(daemonsetPodIsTerminated == 0, 0.0, since(daemonsetPodET)))
 					daemonsetPodDurationInHours as math((daemonsetPodSecondsSinceStart - daemonsetPodSecondsSinceEnd) / 3600)
-					daemonsetPodCpuCost as math(daemonsetPodCpu * daemonsetPodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					daemonsetPodMemoryCost as math(daemonsetPodMemory * daemonsetPodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-					daemonsetPvcStorageCost as math(daemonsetPvcStorage * daemonsetPodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					daemonsetPodCpuCost as math(daemonsetPodCpu * daemonsetPodDurationInHours * ` + cpuCostPerCPUPerHour("daemonsetPodSecondsSinceStart", "daemonsetPodSecondsSinceEnd") + `)
+					daemonsetPodMemoryCost as math(daemonsetPodMemory * daemonsetPodDurationInHours * ` + memCostPerGBPerHour("daemonsetPodSecondsSinceStart", "daemonsetPodSecondsSinceEnd") + `)
+					daemonsetPvcStorageCost as math(daemonsetPvcStorage * daemonsetPodDurationInHours * ` + storageCostPerGBPerHour("daemonsetPodSecondsSinceStart", "daemonsetPodSecondsSinceEnd") + `)
                 }
 				~replicaset @filter(has(isPod)) {
                     name
@@ -147,9 +150,9 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 					replicasetSimplePodIsTerminated as count(endTime)
 					replicasetSimplePodSecondsSinceEnd as math(cond(replicasetSimplePodIsTerminated == 0, 0.0, since(replicasetSimplePodET)))
 					replicasetSimplePodDurationInHours as math((replicasetSimplePodSecondsSinceStart - replicasetSimplePodSecondsSinceEnd) / 3600)
-					replicasetSimplePodCpuCost as math(replicasetSimplePodCpu * replicasetSimplePodDurationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-					replicasetSimplePodMemoryCost as math(replicasetSimplePodMemory * replicasetSimplePodDurationInHours * ` + defaultMemCostPerGBPerHour + `)
-					replicasetSimplePvcStorageCost as math(replicasetSimplePvcStorage * replicasetSimplePodDurationInHours * ` + defaultStorageCostPerGBPerHour + `)
+					replicasetSimplePodCpuCost as math(replicasetSimplePodCpu * replicasetSimplePodDurationInHours * ` + cpuCostPerCPUPerHour("replicasetSimplePodSecondsSinceStart", "replicasetSimplePodSecondsSinceEnd") + `)
+					replicasetSimplePodMemoryCost as math(replicasetSimplePodMemory * replicasetSimplePodDurationInHours * ` + memCostPerGBPerHour("replicasetSimplePodSecondsSinceStart", "replicasetSimplePodSecondsSinceEnd") + `)
+					replicasetSimplePvcStorageCost as math(replicasetSimplePvcStorage * replicasetSimplePodDurationInHours * ` + storageCostPerGBPerHour("replicasetSimplePodSecondsSinceStart", "replicasetSimplePodSecondsSinceEnd") + `)
                 }
 				sumReplicasetSimplePodCpu as sum(val(replicasetSimplePodCpu))
 				sumDaemonsetPodCpu as sum(val(daemonsetPodCpu))
@@ -222,13 +225,13 @@ func RetrieveNamespaceMetrics(name string) JSONDataWrapper {
 			storageCost: val(namespaceStorageCost)
         }
     }`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // getJSONDataFromQuery executes query and wraps the data in a desired structure(JSONDataWrapper)
-func getJSONDataFromQuery(query string) JSONDataWrapper {
+func getJSONDataFromQuery(builder *dgraph.QueryBuilder, query string) JSONDataWrapper {
 	parentRoot := ParentWrapper{}
-	err := dgraph.ExecuteQuery(query, &parentRoot)
+	err := builder.Execute(query, &parentRoot)
 	if err != nil || len(parentRoot.Parent) == 0 {
 		logrus.Errorf("Unable to execute query, err: (%v), length of output: (%d)", err, len(parentRoot.Parent))
 		return JSONDataWrapper{}
@@ -244,7 +247,34 @@ func getJSONDataFromQuery(query string) JSONDataWrapper {
 			CPUCost:     parentRoot.Parent[0].CPUCost,
 			MemoryCost:  parentRoot.Parent[0].MemoryCost,
 			StorageCost: parentRoot.Parent[0].StorageCost,
+			GPU:         parentRoot.Parent[0].GPU,
+			GPUCost:     parentRoot.Parent[0].GPUCost,
+
+			CPUOverhead:        parentRoot.Parent[0].CPUOverhead,
+			MemoryOverhead:     parentRoot.Parent[0].MemoryOverhead,
+			CPUOverheadCost:    parentRoot.Parent[0].CPUOverheadCost,
+			MemoryOverheadCost: parentRoot.Parent[0].MemoryOverheadCost,
 		},
 	}
 	return root
 }
+
+// RetrieveNamespaces returns the xids of all namespaces, including deleted ones, ordered by xid
+func RetrieveNamespaces() ([]models.Namespace, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		namespaces(func: has(isNamespace), orderasc: xid) {
+			xid
+		}
+	}`
+
+	type root struct {
+		Namespaces []models.Namespace `json:"namespaces"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Namespaces, nil
+}
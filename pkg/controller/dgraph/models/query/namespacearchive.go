@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// RetrieveNamespaceArchives returns the archived costs of namespaces deleted in the time window [from, to),
+// latest deletion first. If name is All, archives of every namespace are returned.
+func RetrieveNamespaceArchives(name string, from, to time.Time) ([]models.NamespaceArchive, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := namespaceArchivesQuery(builder, name, from, to)
+
+	type root struct {
+		Archives []models.NamespaceArchive `json:"archives"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Archives, nil
+}
+
+// namespaceArchivesQuery returns the query of the archives of the namespaces deleted in [from, to)
+func namespaceArchivesQuery(builder *dgraph.QueryBuilder, name string, from, to time.Time) string {
+	filter := `ge(lifetimeEnd, ` + builder.Time(from) + `) AND lt(lifetimeEnd, ` + builder.Time(to) + `)`
+	if name != All {
+		filter += ` AND ` + builder.Eq("namespaceName", name)
+	}
+	return `{
+		archives(func: has(isNamespaceArchive), orderdesc: lifetimeEnd) @filter(` + filter + `) {
+			xid
+			namespaceName
+			lifetimeStart
+			lifetimeEnd
+			cpuHours
+			memoryGBHours
+			storageGBHours
+			cpuCost
+			memoryCost
+			storageCost
+			gpuHours
+			gpuCost
+			totalCost
+			priceVersion
+			archivedAt
+		}
+	}`
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestNamespaceArchivesQuery(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	builder := dgraph.NewQueryBuilder()
+	query, variables := builder.Build(namespaceArchivesQuery(builder, "shop", from, from.AddDate(0, 1, 0)))
+	utils.Assert(t, strings.Contains(query, "@filter(ge(lifetimeEnd, $v0) AND lt(lifetimeEnd, $v1) AND eq(namespaceName, $v2))"),
+		"archives deleted in the window are selected: %s", query)
+	utils.Equals(t, map[string]string{"$v0": "2018-11-01T00:00:00Z", "$v1": "2018-12-01T00:00:00Z", "$v2": "shop"}, variables)
+
+	builder = dgraph.NewQueryBuilder()
+	query, _ = builder.Build(namespaceArchivesQuery(builder, All, from, from.AddDate(0, 1, 0)))
+	utils.Assert(t, !strings.Contains(query, "namespaceName, $"), "archives of all namespaces are filtered: %s", query)
+
+	// every stored field of the archive is queried back
+	archive := reflect.TypeOf(models.NamespaceArchive{})
+	for i := 0; i < archive.NumField(); i++ {
+		predicate := strings.Split(archive.Field(i).Tag.Get("json"), ",")[0]
+		if predicate == "" || predicate == models.IsNamespaceArchive || predicate == "type" {
+			continue
+		}
+		utils.Assert(t, strings.Contains(query, "\t"+predicate+"\n"), "archived %s is not queried: %s", predicate, query)
+	}
+	utils.Assert(t, strings.Contains(query, "\txid\n"), "xid is not queried: %s", query)
+}
@@ -0,0 +1,272 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	networking_v1 "k8s.io/api/networking/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// namespaceNameLabel is set on every namespace by kubernetes, it selects a namespace by name in a policy peer
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// podInstanceLabels are set by the controllers of workloads and differ between the pods of a workload (or between
+// its revisions), so they are left out of the selector of the workload
+var podInstanceLabels = map[string]bool{
+	"pod-template-hash":                  true,
+	"controller-revision-hash":           true,
+	"pod-template-generation":            true,
+	"statefulset.kubernetes.io/pod-name": true,
+	"controller-uid":                     true,
+}
+
+// NetworkPolicySummary is a network policy of the cluster
+type NetworkPolicySummary struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	PodSelector string `json:"podSelector"`
+	PolicyTypes string `json:"policyTypes,omitempty"`
+	StartTime   string `json:"startTime"`
+}
+
+// NetworkPolicyList is a list of network policy manifests which can be applied with kubectl
+type NetworkPolicyList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	Items            []networking_v1.NetworkPolicy `json:"items"`
+}
+
+// policyPod is a running pod with the pods observed sending traffic to it
+type policyPod struct {
+	Xid       string `json:"xid"`
+	Namespace struct {
+		Xid string `json:"xid"`
+	} `json:"namespace"`
+	Labels  []models.Label `json:"label"`
+	Inbound []policyPod    `json:"inbound"`
+}
+
+// RetrieveNetworkPolicies returns the network policies of the namespace (all namespaces if it is empty)
+func RetrieveNetworkPolicies(namespace string) ([]NetworkPolicySummary, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	policiesVar := `policies as var(func: has(isNetworkPolicy)) @filter(NOT has(endTime))`
+	if namespace != All {
+		policiesVar = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			policies as ~namespace @filter(has(isNetworkPolicy) AND NOT has(endTime))
+		}`
+	}
+	query := `{
+		` + policiesVar + `
+		policies(func: uid(policies), orderasc: xid) {
+			xid
+			podSelector
+			policyTypes
+			startTime
+		}
+	}`
+
+	type root struct {
+		Policies []struct {
+			NetworkPolicySummary
+			Xid string `json:"xid"`
+		} `json:"policies"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	policies := []NetworkPolicySummary{}
+	for _, policy := range newRoot.Policies {
+		summary := policy.NetworkPolicySummary
+		if parts := strings.SplitN(policy.Xid, ":", 2); len(parts) == 2 {
+			summary.Namespace, summary.Name = parts[0], parts[1]
+		}
+		policies = append(policies, summary)
+	}
+	return policies, nil
+}
+
+// SuggestNetworkPolicies returns least privilege ingress policies for the workloads of the namespace (all namespaces
+// if it is empty), allowing traffic only from the pods observed interacting with them. Workloads without observed
+// inbound traffic get a policy denying all ingress, so the suggestions must be reviewed before they are applied.
+func SuggestNetworkPolicies(namespace string) (NetworkPolicyList, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	podsVar := `pods as var(func: has(isPod)) @filter(NOT has(endTime) AND NOT has(isSynthetic))`
+	if namespace != All {
+		podsVar = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			pods as ~namespace @filter(has(isPod) AND NOT has(endTime) AND NOT has(isSynthetic))
+		}`
+	}
+	query := `{
+		` + podsVar + `
+		pods(func: uid(pods)) {
+			xid
+			namespace {
+				xid
+			}
+			label {
+				key
+				value
+			}
+			inbound: ~pod @filter(has(isPod) AND NOT has(endTime)) {
+				xid
+				namespace {
+					xid
+				}
+				label {
+					key
+					value
+				}
+			}
+		}
+	}`
+
+	type root struct {
+		Pods []policyPod `json:"pods"`
+	}
+	list := NetworkPolicyList{TypeMeta: meta_v1.TypeMeta{APIVersion: "v1", Kind: "List"}}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return list, err
+	}
+	list.Items = suggestPolicies(newRoot.Pods)
+	return list, nil
+}
+
+// suggestPolicies groups the pods by namespace and workload labels and returns an ingress policy for every group
+// allowing the workloads which sent traffic to any pod of the group. Pods without workload labels can't be selected,
+// so they get no policy, and as peers they stand for all the pods of their namespace.
+func suggestPolicies(pods []policyPod) []networking_v1.NetworkPolicy {
+	type group struct {
+		namespace string
+		selector  map[string]string
+		peers     map[string]networking_v1.NetworkPolicyPeer
+	}
+	groups := map[string]*group{}
+	for _, pod := range pods {
+		selector := workloadLabels(pod.Labels)
+		if len(selector) == 0 {
+			continue
+		}
+		key := pod.Namespace.Xid + "/" + selectorString(selector)
+		if groups[key] == nil {
+			groups[key] = &group{namespace: pod.Namespace.Xid, selector: selector, peers: map[string]networking_v1.NetworkPolicyPeer{}}
+		}
+		for _, source := range pod.Inbound {
+			sourceLabels := workloadLabels(source.Labels)
+			peer := networking_v1.NetworkPolicyPeer{PodSelector: &meta_v1.LabelSelector{MatchLabels: sourceLabels}}
+			if source.Namespace.Xid != pod.Namespace.Xid {
+				peer.NamespaceSelector = &meta_v1.LabelSelector{MatchLabels: map[string]string{namespaceNameLabel: source.Namespace.Xid}}
+			}
+			groups[key].peers[source.Namespace.Xid+"/"+selectorString(sourceLabels)] = peer
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := map[string]bool{}
+	policies := []networking_v1.NetworkPolicy{}
+	for _, key := range keys {
+		g := groups[key]
+		name := policyName(g.selector)
+		for i := 2; names[g.namespace+"/"+name]; i++ {
+			name = policyName(g.selector) + "-" + strconv.Itoa(i)
+		}
+		names[g.namespace+"/"+name] = true
+
+		policy := networking_v1.NetworkPolicy{
+			TypeMeta:   meta_v1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: g.namespace},
+			Spec: networking_v1.NetworkPolicySpec{
+				PodSelector: meta_v1.LabelSelector{MatchLabels: g.selector},
+				PolicyTypes: []networking_v1.PolicyType{networking_v1.PolicyTypeIngress},
+				Ingress:     []networking_v1.NetworkPolicyIngressRule{},
+			},
+		}
+		if len(g.peers) > 0 {
+			peerKeys := make([]string, 0, len(g.peers))
+			for peerKey := range g.peers {
+				peerKeys = append(peerKeys, peerKey)
+			}
+			sort.Strings(peerKeys)
+			rule := networking_v1.NetworkPolicyIngressRule{}
+			for _, peerKey := range peerKeys {
+				rule.From = append(rule.From, g.peers[peerKey])
+			}
+			policy.Spec.Ingress = append(policy.Spec.Ingress, rule)
+		}
+		policies = append(policies, policy)
+	}
+	return policies
+}
+
+// workloadLabels returns the labels of a pod shared by all the pods of its workload
+func workloadLabels(podLabels []models.Label) map[string]string {
+	selector := map[string]string{}
+	for _, label := range podLabels {
+		if !podInstanceLabels[label.Key] {
+			selector[label.Key] = label.Value
+		}
+	}
+	return selector
+}
+
+// selectorString returns the labels in the form of a selector, sorted by key
+func selectorString(selector map[string]string) string {
+	pairs := make([]string, 0, len(selector))
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// policyName names the policy of a workload after its app label, or after a hash of its labels
+func policyName(selector map[string]string) string {
+	name := selector["app.kubernetes.io/name"]
+	if name == "" {
+		name = selector["app"]
+	}
+	name = strings.Trim(strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' {
+			return r
+		}
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name), "-")
+	if name == "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(selectorString(selector)))
+		name = strconv.FormatUint(uint64(hash.Sum32()), 16)
+	}
+	if len(name) > 40 {
+		name = strings.TrimRight(name[:40], "-")
+	}
+	return "purser-allow-" + name
+}
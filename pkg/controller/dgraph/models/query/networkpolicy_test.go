@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newPolicyPod(namespace string, labels map[string]string, inbound ...policyPod) policyPod {
+	pod := policyPod{Inbound: inbound}
+	pod.Namespace.Xid = namespace
+	for key, value := range labels {
+		pod.Labels = append(pod.Labels, models.Label{Key: key, Value: value})
+	}
+	return pod
+}
+
+func TestSuggestPolicies(t *testing.T) {
+	frontend := newPolicyPod("shop", map[string]string{"app": "frontend", "pod-template-hash": "5d8f"})
+	monitoring := newPolicyPod("monitoring", map[string]string{"app": "prometheus"})
+	unlabeled := newPolicyPod("shop", nil)
+	pods := []policyPod{
+		newPolicyPod("shop", map[string]string{"app": "Cart_API", "pod-template-hash": "7c9b"}, frontend, monitoring),
+		newPolicyPod("shop", map[string]string{"app": "Cart_API", "pod-template-hash": "7c9b"}, frontend, unlabeled),
+		frontend,
+		unlabeled,
+	}
+	policies := suggestPolicies(pods)
+
+	utils.Equals(t, 2, len(policies))
+	cart := policies[0]
+	utils.Equals(t, "purser-allow-cart-api", cart.Name)
+	utils.Equals(t, "shop", cart.Namespace)
+	utils.Equals(t, map[string]string{"app": "Cart_API"}, cart.Spec.PodSelector.MatchLabels)
+	utils.Equals(t, 1, len(cart.Spec.Ingress))
+	peers := cart.Spec.Ingress[0].From
+	utils.Equals(t, 3, len(peers))
+	// peers are sorted by namespace: pods of other namespaces are selected along with their namespace
+	utils.Equals(t, map[string]string{namespaceNameLabel: "monitoring"}, peers[0].NamespaceSelector.MatchLabels)
+	utils.Equals(t, map[string]string{"app": "prometheus"}, peers[0].PodSelector.MatchLabels)
+	utils.Assert(t, peers[1].NamespaceSelector == nil && len(peers[1].PodSelector.MatchLabels) == 0, "expected unlabeled peer to select the pods of its namespace, got: %v", peers[1])
+	utils.Equals(t, map[string]string{"app": "frontend"}, peers[2].PodSelector.MatchLabels)
+
+	// no inbound traffic was observed, all ingress is denied
+	utils.Equals(t, "purser-allow-frontend", policies[1].Name)
+	utils.Equals(t, 0, len(policies[1].Spec.Ingress))
+}
+
+func TestPolicyName(t *testing.T) {
+	utils.Equals(t, "purser-allow-web", policyName(map[string]string{"app.kubernetes.io/name": "web", "app": "other"}))
+	utils.Assert(t, len(policyName(map[string]string{"tier": "db"})) > len("purser-allow-"), "expected policy named after a hash of the labels")
+}
@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+const bytesInGB = 1024 * 1024 * 1024
+
+// NetworkLineItem is the line item of the cost of the traffic sent by the pods of a namespace
+const NetworkLineItem = "network"
+
+// PodTraffic gives the bytes a pod sent (by where they went) and received in a window, with the cost of the bytes sent
+type PodTraffic struct {
+	Pod              string  `json:"pod"`
+	Namespace        string  `json:"namespace"`
+	BytesSameZone    float64 `json:"bytesSameZone"`
+	BytesCrossZone   float64 `json:"bytesCrossZone"`
+	BytesInterRegion float64 `json:"bytesInterRegion"`
+	BytesPrivate     float64 `json:"bytesPrivate"`
+	BytesInternet    float64 `json:"bytesInternet"`
+	BytesReceived    float64 `json:"bytesReceived"`
+	// BytesFromInternet are the bytes of BytesReceived which came from the internet
+	BytesFromInternet float64 `json:"bytesFromInternet"`
+	Cost              float64 `json:"cost"`
+}
+
+// NetworkTrafficReport gives the traffic of pods ingested from flow logs in a window and the network prices of the
+// cluster, the most expensive pods first
+type NetworkTrafficReport struct {
+	From   string                `json:"from"`
+	To     string                `json:"to"`
+	Prices pricing.NetworkPrices `json:"prices"`
+	Pods   []PodTraffic          `json:"pods"`
+}
+
+// RetrieveNetworkTraffic returns the traffic of the pods of the namespace (all namespaces if it is empty) in the
+// hours starting in the window [from, to)
+func RetrieveNetworkTraffic(namespace string, from, to time.Time) (NetworkTrafficReport, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	inWindow := `ge(startTime, ` + builder.Time(from) + `) AND lt(startTime, ` + builder.Time(to) + `)`
+	trafficVar := `traffic as var(func: has(isNetworkTraffic)) @filter(` + inWindow + `)`
+	if namespace != All {
+		trafficVar = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			traffic as ~namespace @filter(has(isNetworkTraffic) AND ` + inWindow + `)
+		}`
+	}
+	query := `{
+		` + trafficVar + `
+		traffic(func: uid(traffic)) {
+			trafficPod {
+				name
+				namespace {
+					xid
+				}
+			}
+			bytesSameZone
+			bytesCrossZone
+			bytesInterRegion
+			bytesPrivate
+			bytesInternet
+			bytesReceived
+			bytesFromInternet
+		}
+	}`
+
+	type root struct {
+		Traffic []struct {
+			PodTraffic
+			Pod struct {
+				Name      string `json:"name"`
+				Namespace struct {
+					Xid string `json:"xid"`
+				} `json:"namespace"`
+			} `json:"trafficPod"`
+		} `json:"traffic"`
+	}
+	report := NetworkTrafficReport{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339),
+		Prices: pricing.GetNetworkPrices(), Pods: []PodTraffic{}}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return report, err
+	}
+
+	indexes := map[string]int{}
+	for _, traffic := range newRoot.Traffic {
+		key := traffic.Pod.Namespace.Xid + ":" + traffic.Pod.Name
+		i, ok := indexes[key]
+		if !ok {
+			i = len(report.Pods)
+			indexes[key] = i
+			report.Pods = append(report.Pods, PodTraffic{Pod: traffic.Pod.Name, Namespace: traffic.Pod.Namespace.Xid})
+		}
+		pod := &report.Pods[i]
+		pod.BytesSameZone += traffic.BytesSameZone
+		pod.BytesCrossZone += traffic.BytesCrossZone
+		pod.BytesInterRegion += traffic.BytesInterRegion
+		pod.BytesPrivate += traffic.BytesPrivate
+		pod.BytesInternet += traffic.BytesInternet
+		pod.BytesReceived += traffic.BytesReceived
+		pod.BytesFromInternet += traffic.BytesFromInternet
+	}
+	for i := range report.Pods {
+		report.Pods[i].Cost = trafficCost(report.Pods[i], report.Prices)
+	}
+	sort.SliceStable(report.Pods, func(i, j int) bool {
+		return report.Pods[i].Cost > report.Pods[j].Cost
+	})
+	return report, nil
+}
+
+// trafficCost prices the bytes sent by the pod by where they went. Traffic within a zone and to the other private
+// addresses of the network, which are not known to be in another zone, is free. Traffic with the internet goes
+// through the NAT gateway when it has a price.
+func trafficCost(traffic PodTraffic, prices pricing.NetworkPrices) float64 {
+	return traffic.BytesCrossZone/bytesInGB*prices.InterZone +
+		traffic.BytesInterRegion/bytesInGB*prices.InterRegion +
+		traffic.BytesInternet/bytesInGB*prices.InternetEgress +
+		natCost(traffic, prices)
+}
+
+// natCost is the NAT gateway processing charge of the traffic of the pod with the internet, in both directions
+func natCost(traffic PodTraffic, prices pricing.NetworkPrices) float64 {
+	return (traffic.BytesInternet + traffic.BytesFromInternet) / bytesInGB * prices.NATGateway
+}
+
+// maxNATTopPods is the number of pods listed with the NAT gateway charges of a namespace
+const maxNATTopPods = 5
+
+// PodNAT gives the bytes of a pod processed by the NAT gateway and their charge
+type PodNAT struct {
+	Pod   string  `json:"pod"`
+	Bytes float64 `json:"bytes"`
+	Cost  float64 `json:"cost"`
+}
+
+// NamespaceNAT gives the NAT gateway processing charges attributed to a namespace, with its share of the charges
+// of the cluster and the pods generating most of them
+type NamespaceNAT struct {
+	Namespace string   `json:"namespace"`
+	Bytes     float64  `json:"bytes"`
+	Cost      float64  `json:"cost"`
+	Share     float64  `json:"share"`
+	TopPods   []PodNAT `json:"topPods"`
+}
+
+// NATReport gives the NAT gateway processing charges of the cluster in a window attributed to the namespaces by the
+// volume of their traffic with the internet, the top contributors first
+type NATReport struct {
+	From       string         `json:"from"`
+	To         string         `json:"to"`
+	CostPerGB  float64        `json:"natGatewayCostPerGB"`
+	Bytes      float64        `json:"bytes"`
+	Cost       float64        `json:"cost"`
+	Namespaces []NamespaceNAT `json:"namespaces"`
+}
+
+// RetrieveNATAttribution returns the NAT gateway processing charges of the namespaces in the window [from, to)
+func RetrieveNATAttribution(from, to time.Time) (NATReport, error) {
+	traffic, err := RetrieveNetworkTraffic(All, from, to)
+	if err != nil {
+		return NATReport{From: traffic.From, To: traffic.To, Namespaces: []NamespaceNAT{}}, err
+	}
+	report := attributeNAT(traffic.Pods, traffic.Prices)
+	report.From, report.To = traffic.From, traffic.To
+	return report, nil
+}
+
+// attributeNAT splits the NAT gateway processing charges of the traffic of the pods by namespace
+func attributeNAT(pods []PodTraffic, prices pricing.NetworkPrices) NATReport {
+	report := NATReport{CostPerGB: prices.NATGateway, Namespaces: []NamespaceNAT{}}
+	indexes := map[string]int{}
+	for _, pod := range pods {
+		bytes := pod.BytesInternet + pod.BytesFromInternet
+		if bytes == 0 {
+			continue
+		}
+		i, ok := indexes[pod.Namespace]
+		if !ok {
+			i = len(report.Namespaces)
+			indexes[pod.Namespace] = i
+			report.Namespaces = append(report.Namespaces, NamespaceNAT{Namespace: pod.Namespace, TopPods: []PodNAT{}})
+		}
+		cost := natCost(pod, prices)
+		namespace := &report.Namespaces[i]
+		namespace.Bytes += bytes
+		namespace.Cost += cost
+		namespace.TopPods = append(namespace.TopPods, PodNAT{Pod: pod.Pod, Bytes: bytes, Cost: cost})
+		report.Bytes += bytes
+		report.Cost += cost
+	}
+
+	for i := range report.Namespaces {
+		namespace := &report.Namespaces[i]
+		namespace.Share = namespace.Bytes / report.Bytes
+		sort.SliceStable(namespace.TopPods, func(a, b int) bool {
+			return namespace.TopPods[a].Bytes > namespace.TopPods[b].Bytes
+		})
+		if len(namespace.TopPods) > maxNATTopPods {
+			namespace.TopPods = namespace.TopPods[:maxNATTopPods]
+		}
+	}
+	sort.SliceStable(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Bytes > report.Namespaces[j].Bytes
+	})
+	return report
+}
+
+// AddNetworkCosts adds the cost of the traffic of the pods, with their NAT gateway charges, to the total cost of their
+// namespace as the network line item, a namespace without cost in the window is added
+func AddNetworkCosts(costs []ResourceCost, pods []PodTraffic) []ResourceCost {
+	for _, pod := range pods {
+		if pod.Cost == 0 || pod.Namespace == "" {
+			continue
+		}
+		sent := (pod.BytesCrossZone + pod.BytesInterRegion + pod.BytesInternet) / bytesInGB
+		costs = addLineItem(costs, pod.Namespace, LineItem{Category: NetworkLineItem,
+			Description: "traffic sent across zones, regions and to the internet", Quantity: sent, Unit: "GB", Cost: pod.Cost})
+	}
+	return costs
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestTrafficCost(t *testing.T) {
+	prices := pricing.NetworkPrices{InternetEgress: 0.09, InterRegion: 0.02, InterZone: 0.01, NATGateway: 0.045}
+	traffic := PodTraffic{
+		BytesSameZone:    100 * bytesInGB,
+		BytesCrossZone:   10 * bytesInGB,
+		BytesInterRegion: 5 * bytesInGB,
+		BytesPrivate:     50 * bytesInGB,
+		BytesInternet:    2 * bytesInGB,
+		BytesReceived:    80 * bytesInGB,
+	}
+	// 10*0.01 + 5*0.02 + 2*(0.09+0.045)
+	cost := trafficCost(traffic, prices)
+	utils.Assert(t, math.Abs(cost-0.47) < 1e-9, "expected traffic cost 0.47, got %v", cost)
+	utils.Equals(t, 0.0, trafficCost(PodTraffic{BytesSameZone: bytesInGB, BytesReceived: bytesInGB}, prices))
+}
+
+func TestAttributeNAT(t *testing.T) {
+	prices := pricing.NetworkPrices{NATGateway: 0.045}
+	pods := []PodTraffic{
+		{Pod: "web", Namespace: "shop", BytesInternet: bytesInGB, BytesFromInternet: 3 * bytesInGB},
+		{Pod: "crawler", Namespace: "data", BytesInternet: 30 * bytesInGB, BytesFromInternet: 6 * bytesInGB},
+		{Pod: "cart", Namespace: "shop", BytesInternet: 8 * bytesInGB},
+		{Pod: "db", Namespace: "shop", BytesCrossZone: 50 * bytesInGB},
+	}
+	report := attributeNAT(pods, prices)
+
+	utils.Equals(t, 48.0*bytesInGB, report.Bytes)
+	utils.Equals(t, 2, len(report.Namespaces))
+	utils.Equals(t, "data", report.Namespaces[0].Namespace)
+	utils.Equals(t, 0.75, report.Namespaces[0].Share)
+	shop := report.Namespaces[1]
+	utils.Equals(t, 12.0*bytesInGB, shop.Bytes)
+	utils.Equals(t, []string{"cart", "web"}, []string{shop.TopPods[0].Pod, shop.TopPods[1].Pod})
+	utils.Assert(t, math.Abs(shop.Cost-12*0.045) < 1e-9, "expected nat cost of shop 0.54, got %v", shop.Cost)
+}
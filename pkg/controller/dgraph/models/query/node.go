@@ -21,18 +21,20 @@ import (
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrieveNodeHierarchy returns hierarchy for a given node
 func RetrieveNodeHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for node, empty name is given")
 		return JSONDataWrapper{}
 	}
 
-	query := `query {
-		parent(func: has(isNode)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isNode)) {
 			name
 			type
 			children: ~node @filter(has(isPod)) {
@@ -41,19 +43,20 @@ func RetrieveNodeHierarchy(name string) JSONDataWrapper {
 			}
         }
     }`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrieveNodeMetrics returns metrics for a given node
 func RetrieveNodeMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for node, empty name is given")
 		return JSONDataWrapper{}
 	}
 
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		parent(func: has(isNode)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isNode)) {
 			name
 			type
 			children: ~node @filter(has(isPod)) {
@@ -69,9 +72,9 @@ func RetrieveNodeMetrics(name string) JSONDataWrapper {
 				isTerminatedChild as count(endTime)
 				secondsSinceEndChild as math(cond(isTerminatedChild == 0, 0.0, since(etChild)))
 				durationInHoursChild as math((secondsSinceStartChild - secondsSinceEndChild) / 3600)
-				cpuCost: math(podCpu * durationInHoursChild * ` + defaultCPUCostPerCPUPerHour + `)
-				memoryCost: math(podMemory * durationInHoursChild * ` + defaultMemCostPerGBPerHour + `)
-				storageCost: math(pvcStorage * durationInHoursChild * ` + defaultStorageCostPerGBPerHour + `)
+				cpuCost: math(podCpu * durationInHoursChild * ` + cpuCostPerCPUPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
+				memoryCost: math(podMemory * durationInHoursChild * ` + memCostPerGBPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
+				storageCost: math(pvcStorage * durationInHoursChild * ` + storageCostPerGBPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
 			}
 			cpu: cpu as cpuCapacity
 			memory: memory as memoryCapacity
@@ -83,10 +86,10 @@ func RetrieveNodeMetrics(name string) JSONDataWrapper {
 			isTerminated as count(endTime)
 			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-			cpuCost: math(cpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-			memoryCost: math(memory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-			storageCost: math(storage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+			cpuCost: math(cpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			memoryCost: math(memory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			storageCost: math(storage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
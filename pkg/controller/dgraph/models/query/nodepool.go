@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// NodePoolCost is the cost in a time window of the pods while they were placed on the nodes of a pool in a zone.
+// Nodes without a well known pool or zone label have an empty pool or zone.
+type NodePoolCost struct {
+	NodePool    string  `json:"nodePool"`
+	Zone        string  `json:"zone"`
+	PodHours    float64 `json:"podHours"`
+	CPUCost     float64 `json:"cpuCost"`
+	MemoryCost  float64 `json:"memoryCost"`
+	StorageCost float64 `json:"storageCost"`
+	GPUCost     float64 `json:"gpuCost"`
+	TotalCost   float64 `json:"totalCost"`
+}
+
+// placementShare is the share of the life of a pod in a time window spent on the nodes of a pool in a zone
+type placementShare struct {
+	nodePool, zone string
+	share          float64
+}
+
+// RetrieveNodePoolCostsInWindow returns the cost of the pods by node pool and zone for the time window [from, to).
+// The cost of a pod is split between the pools of its placements in proportion to the time spent in each of them,
+// the time not covered by a placement (before the placement history was recorded) is charged to the pool of its node.
+func RetrieveNodePoolCostsInWindow(from, to time.Time) ([]NodePoolCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pods(func: has(isPod)) @filter(` + podsInWindowFilter(builder, from, to) + `) {
+			` + podCostInWindow(from, to) + `
+			hours: val(durationInHours)
+			cpuCost: val(podCpuCost)
+			memoryCost: val(podMemCost)
+			storageCost: val(podStorageCost)
+			gpuCost: val(podGpuCost)
+			node {
+				nodePool
+				zone
+			}
+			placement @filter(` + podsInWindowFilter(builder, from, to) + `) {
+				startTime
+				endTime
+				nodePool
+				zone
+			}
+		}
+	}`
+
+	type pod struct {
+		StartTime   string                 `json:"startTime"`
+		EndTime     string                 `json:"endTime"`
+		Hours       float64                `json:"hours"`
+		CPUCost     float64                `json:"cpuCost"`
+		MemoryCost  float64                `json:"memoryCost"`
+		StorageCost float64                `json:"storageCost"`
+		GPUCost     float64                `json:"gpuCost"`
+		Node        *models.Node           `json:"node"`
+		Placements  []*models.PodPlacement `json:"placement"`
+	}
+	type root struct {
+		Pods []pod `json:"pods"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+
+	costs := map[string]*NodePoolCost{}
+	result := []NodePoolCost{}
+	for _, p := range newRoot.Pods {
+		start, err := time.Parse(time.RFC3339, p.StartTime)
+		if err != nil {
+			continue
+		}
+		end := to
+		if endTime, err := time.Parse(time.RFC3339, p.EndTime); err == nil && endTime.Before(to) {
+			end = endTime
+		}
+		if start.Before(from) {
+			start = from
+		}
+		fallback := placementShare{}
+		if p.Node != nil {
+			fallback.nodePool, fallback.zone = p.Node.NodePool, p.Node.Zone
+		}
+		for _, share := range placementShares(p.Placements, fallback, start, end) {
+			key := share.nodePool + "/" + share.zone
+			cost, isPresent := costs[key]
+			if !isPresent {
+				cost = &NodePoolCost{NodePool: share.nodePool, Zone: share.zone}
+				costs[key] = cost
+			}
+			cost.PodHours += p.Hours * share.share
+			cost.CPUCost += p.CPUCost * share.share
+			cost.MemoryCost += p.MemoryCost * share.share
+			cost.StorageCost += p.StorageCost * share.share
+			cost.GPUCost += p.GPUCost * share.share
+			cost.TotalCost += (p.CPUCost + p.MemoryCost + p.StorageCost + p.GPUCost) * share.share
+		}
+	}
+
+	for _, cost := range costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalCost != result[j].TotalCost {
+			return result[i].TotalCost > result[j].TotalCost
+		}
+		return result[i].NodePool+"/"+result[i].Zone < result[j].NodePool+"/"+result[j].Zone
+	})
+	return result, nil
+}
+
+// placementShares returns the shares of the life [start, end) of a pod spent in the pools of its placements, the
+// remaining share goes to the fallback pool
+func placementShares(placements []*models.PodPlacement, fallback placementShare, start, end time.Time) []placementShare {
+	life := end.Sub(start)
+	if life <= 0 {
+		return []placementShare{{nodePool: fallback.nodePool, zone: fallback.zone, share: 1}}
+	}
+	var shares []placementShare
+	remaining := 1.0
+	for _, placement := range placements {
+		placementStart, err := time.Parse(time.RFC3339, placement.StartTime)
+		if err != nil {
+			continue
+		}
+		placementEnd := end
+		if placementEndTime, err := time.Parse(time.RFC3339, placement.EndTime); err == nil && placementEndTime.Before(end) {
+			placementEnd = placementEndTime
+		}
+		if placementStart.Before(start) {
+			placementStart = start
+		}
+		if !placementEnd.After(placementStart) {
+			continue
+		}
+		share := float64(placementEnd.Sub(placementStart)) / float64(life)
+		shares = append(shares, placementShare{nodePool: placement.NodePool, zone: placement.Zone, share: share})
+		remaining -= share
+	}
+	if remaining > 1e-9 {
+		shares = append(shares, placementShare{nodePool: fallback.nodePool, zone: fallback.zone, share: remaining})
+	}
+	return shares
+}
+
+// RetrievePodPlacements returns the placement history of the pod with the given xid, oldest first
+func RetrievePodPlacements(xid string) ([]models.PodPlacement, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pods(func: ` + builder.Eq("xid", xid) + `) @filter(has(isPod)) {
+			placement(orderasc: startTime) {
+				startTime
+				endTime
+				nodePool
+				zone
+				instanceType
+				node {
+					xid
+				}
+			}
+		}
+	}`
+
+	type root struct {
+		Pods []models.Pod `json:"pods"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	placements := []models.PodPlacement{}
+	for _, pod := range newRoot.Pods {
+		for _, placement := range pod.Placements {
+			placements = append(placements, *placement)
+		}
+	}
+	return placements, nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPlacementShares(t *testing.T) {
+	start := time.Date(2018, 11, 5, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Hour)
+	placements := []*models.PodPlacement{
+		// recorded after the first 2 hours of the pod, drained after 4 hours on pool a
+		{StartTime: start.Add(2 * time.Hour).Format(time.RFC3339), EndTime: start.Add(6 * time.Hour).Format(time.RFC3339), NodePool: "a", Zone: "z1"},
+		{StartTime: start.Add(6 * time.Hour).Format(time.RFC3339), NodePool: "b", Zone: "z2"},
+	}
+
+	shares := placementShares(placements, placementShare{nodePool: "b", zone: "z2"}, start, end)
+	utils.Equals(t, []placementShare{
+		{nodePool: "a", zone: "z1", share: 0.4},
+		{nodePool: "b", zone: "z2", share: 0.4},
+		{nodePool: "b", zone: "z2", share: 0.2},
+	}, roundShares(shares))
+
+	shares = placementShares(nil, placementShare{nodePool: "a"}, start, end)
+	utils.Equals(t, []placementShare{{nodePool: "a", share: 1}}, shares)
+}
+
+func roundShares(shares []placementShare) []placementShare {
+	for i := range shares {
+		shares[i].share = float64(int(shares[i].share*1000+0.5)) / 1000
+	}
+	return shares
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// RetrieveNodePressures returns the intervals during which nodes were under memory or disk pressure overlapping
+// the time window [from, to), ordered by their start. Intervals still open have no end time.
+func RetrieveNodePressures(from, to time.Time) ([]models.NodePressure, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pressures(func: has(isNodePressure), orderasc: startTime) @filter(lt(startTime, ` + builder.Time(to) + `) AND (NOT has(endTime) OR ge(endTime, ` + builder.Time(from) + `))) {
+			xid
+			condition
+			startTime
+			endTime
+			node {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Pressures []models.NodePressure `json:"pressures"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Pressures, nil
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// A namespace has runaway object growth if it gained at least runawayMinObjectsPerDay objects per day and
+// runawayGrowthRatio times the objects it had at the start of the window
+const (
+	runawayMinObjectsPerDay = 100
+	runawayGrowthRatio      = 0.5
+)
+
+// ObjectCountSample gives the number of config maps, secrets and custom resources of a namespace at a time, with
+// the size of their manifests (an estimate of their etcd footprint) in bytes
+type ObjectCountSample struct {
+	Time            string `json:"time"`
+	ConfigMaps      int    `json:"configMaps"`
+	Secrets         int    `json:"secrets"`
+	CustomResources int    `json:"customResources"`
+	ObjectBytes     int    `json:"objectBytes"`
+}
+
+// NamespaceObjects gives the object counts of a namespace over a window. Growth is the change of the total number
+// of objects between the first and the last sample.
+type NamespaceObjects struct {
+	Namespace   string              `json:"namespace"`
+	Objects     int                 `json:"objects"`
+	ObjectBytes int                 `json:"objectBytes"`
+	Growth      int                 `json:"growth"`
+	Runaway     bool                `json:"runaway"`
+	Samples     []ObjectCountSample `json:"samples"`
+}
+
+// ObjectGrowthReport gives the object counts of namespaces in a window, the fastest growing namespaces first
+type ObjectGrowthReport struct {
+	From       string             `json:"from"`
+	To         string             `json:"to"`
+	Runaway    int                `json:"runaway"`
+	Namespaces []NamespaceObjects `json:"namespaces"`
+}
+
+// RetrieveObjectGrowth returns the object counts sampled in the window [from, to) of the namespace (all namespaces
+// if it is empty) and flags the namespaces whose objects grow out of control
+func RetrieveObjectGrowth(namespace string, from, to time.Time) (ObjectGrowthReport, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	inWindow := `ge(sampleTime, ` + builder.Time(from) + `) AND lt(sampleTime, ` + builder.Time(to) + `)`
+	countsVar := `counts as var(func: has(isObjectCount)) @filter(` + inWindow + `)`
+	if namespace != All {
+		countsVar = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			counts as ~namespace @filter(has(isObjectCount) AND ` + inWindow + `)
+		}`
+	}
+	query := `{
+		` + countsVar + `
+		counts(func: uid(counts), orderasc: sampleTime) {
+			time: sampleTime
+			configMaps
+			secrets
+			customResources
+			objectBytes
+			namespace {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Counts []struct {
+			ObjectCountSample
+			Namespace struct {
+				Xid string `json:"xid"`
+			} `json:"namespace"`
+		} `json:"counts"`
+	}
+	report := ObjectGrowthReport{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), Namespaces: []NamespaceObjects{}}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return report, err
+	}
+
+	indexes := map[string]int{}
+	for _, count := range newRoot.Counts {
+		i, ok := indexes[count.Namespace.Xid]
+		if !ok {
+			i = len(report.Namespaces)
+			indexes[count.Namespace.Xid] = i
+			report.Namespaces = append(report.Namespaces, NamespaceObjects{Namespace: count.Namespace.Xid})
+		}
+		report.Namespaces[i].Samples = append(report.Namespaces[i].Samples, count.ObjectCountSample)
+	}
+	for i := range report.Namespaces {
+		objects := &report.Namespaces[i]
+		first, last := objects.Samples[0], objects.Samples[len(objects.Samples)-1]
+		objects.Objects = sampleTotal(last)
+		objects.ObjectBytes = last.ObjectBytes
+		objects.Growth = objects.Objects - sampleTotal(first)
+		objects.Runaway = isRunawayGrowth(sampleTotal(first), objects.Objects, sampleSpan(first, last))
+		if objects.Runaway {
+			report.Runaway++
+		}
+	}
+	sort.SliceStable(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Growth > report.Namespaces[j].Growth
+	})
+	return report, nil
+}
+
+func sampleTotal(sample ObjectCountSample) int {
+	return sample.ConfigMaps + sample.Secrets + sample.CustomResources
+}
+
+func sampleSpan(first, last ObjectCountSample) time.Duration {
+	start, err := time.Parse(time.RFC3339, first.Time)
+	if err != nil {
+		return 0
+	}
+	end, err := time.Parse(time.RFC3339, last.Time)
+	if err != nil {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// isRunawayGrowth returns true if the number of objects grew from first to last faster than runawayMinObjectsPerDay
+// and by more than runawayGrowthRatio. Spans shorter than an hour are too short to tell a trend from a rollout.
+func isRunawayGrowth(first, last int, span time.Duration) bool {
+	if span < time.Hour || last <= first {
+		return false
+	}
+	growth := float64(last - first)
+	perDay := growth / span.Hours() * 24
+	return perDay >= runawayMinObjectsPerDay && growth >= runawayGrowthRatio*float64(first)
+}
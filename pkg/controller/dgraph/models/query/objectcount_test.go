@@ -0,0 +1,43 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIsRunawayGrowth(t *testing.T) {
+	day := 24 * time.Hour
+	utils.Assert(t, isRunawayGrowth(200, 1000, 2*day), "expected 400 objects per day growth to be runaway")
+	// fast but small relative to the namespace
+	utils.Assert(t, !isRunawayGrowth(10000, 10400, 2*day), "expected 4% growth not to be runaway")
+	// large relative growth of a small namespace
+	utils.Assert(t, !isRunawayGrowth(10, 60, day), "expected 50 objects per day not to be runaway")
+	utils.Assert(t, !isRunawayGrowth(1000, 200, day), "expected shrinking namespace not to be runaway")
+	utils.Assert(t, !isRunawayGrowth(0, 500, time.Minute), "expected a single rollout not to be runaway")
+}
+
+func TestSampleSpan(t *testing.T) {
+	first := ObjectCountSample{Time: "2024-03-01T00:00:00Z"}
+	last := ObjectCountSample{Time: "2024-03-02T06:00:00Z"}
+	utils.Equals(t, 30*time.Hour, sampleSpan(first, last))
+	utils.Equals(t, time.Duration(0), sampleSpan(ObjectCountSample{}, last))
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// OperatorCost is the usage (in unit hours) and cost in a time window of the pods run by an operator installed
+// by OLM: the operator deployments and the workloads created for its custom resources.
+type OperatorCost struct {
+	Xid          string          `json:"xid"`
+	CSVName      string          `json:"csvName"`
+	DisplayName  string          `json:"displayName,omitempty"`
+	Version      string          `json:"version,omitempty"`
+	Package      string          `json:"package,omitempty"`
+	Channel      string          `json:"channel,omitempty"`
+	Subscription string          `json:"subscription,omitempty"`
+	Namespace    string          `json:"operatorNamespace"`
+	Namespaces   []string        `json:"namespaces"`
+	CPU          utils.CoreHours `json:"cpu"`
+	Memory       utils.GBHours   `json:"memory"`
+	Storage      utils.GBHours   `json:"storage"`
+	GPU          float64         `json:"gpu"`
+	CPUCost      float64         `json:"cpuCost"`
+	MemoryCost   float64         `json:"memoryCost"`
+	StorageCost  float64         `json:"storageCost"`
+	GPUCost      float64         `json:"gpuCost"`
+	TotalCost    float64         `json:"totalCost"`
+
+	LineItems []LineItem `json:"lineItems"`
+}
+
+// RetrieveOperatorCostsInWindow returns the usage and cost of every operator for the time window [from, to),
+// ordered by total cost, highest first.
+func RetrieveOperatorCostsInWindow(from, to time.Time) ([]OperatorCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		ops as var(func: has(isOperator)) {
+			~operator @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+			opCpu as sum(val(podCpuHours))
+			opMem as sum(val(podMemHours))
+			opStorage as sum(val(podStorageHours))
+			opGpu as sum(val(podGpuHours))
+			opCpuCost as sum(val(podCpuCost))
+			opMemCost as sum(val(podMemCost))
+			opStorageCost as sum(val(podStorageCost))
+			opGpuCost as sum(val(podGpuCost))
+		}
+
+		operators(func: uid(ops)) {
+			xid
+			csvName
+			displayName
+			version
+			package
+			channel
+			subscription
+			operatorNamespace
+			cpu: val(opCpu)
+			memory: val(opMem)
+			storage: val(opStorage)
+			gpu: val(opGpu)
+			cpuCost: val(opCpuCost)
+			memoryCost: val(opMemCost)
+			storageCost: val(opStorageCost)
+			gpuCost: val(opGpuCost)
+			pods: ~operator @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				namespace {
+					xid
+				}
+			}
+		}
+	}`
+
+	type pod struct {
+		Namespace struct {
+			Xid string `json:"xid"`
+		} `json:"namespace"`
+	}
+	type operator struct {
+		OperatorCost
+		Pods []pod `json:"pods"`
+	}
+	type root struct {
+		Operators []operator `json:"operators"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	costs := []OperatorCost{}
+	for _, op := range newRoot.Operators {
+		cost := op.OperatorCost
+		namespaces := map[string]bool{}
+		for _, p := range op.Pods {
+			namespaces[p.Namespace.Xid] = true
+		}
+		cost.Namespaces = sortedKeys(namespaces)
+		cost.LineItems = podLineItems(cost.CPUCost, cost.MemoryCost, cost.GPUCost, cost.Storage, cost.StorageCost)
+		cost.TotalCost = lineItemsTotal(cost.LineItems)
+		costs = append(costs, cost)
+	}
+	sort.SliceStable(costs, func(i, j int) bool {
+		return costs[i].TotalCost > costs[j].TotalCost
+	})
+	return costs, nil
+}
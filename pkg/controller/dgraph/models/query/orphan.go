@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// OrphanPod is a pod alive in a time window whose cost can not be attributed: it has no recognized owner
+// (replicaset, statefulset, daemonset or job), no team label, no label of a purser group and no cost center.
+type OrphanPod struct {
+	Xid         string            `json:"xid"`
+	Namespace   string            `json:"namespace"`
+	StartTime   string            `json:"startTime"`
+	EndTime     string            `json:"endTime,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	CPUCost     float64           `json:"cpuCost"`
+	MemoryCost  float64           `json:"memoryCost"`
+	StorageCost float64           `json:"storageCost"`
+	GPUCost     float64           `json:"gpuCost"`
+	TotalCost   float64           `json:"totalCost"`
+}
+
+// OrphanReport lists the orphan pods of a time window, highest cost first, with their total cost
+type OrphanReport struct {
+	From      string      `json:"from"`
+	To        string      `json:"to"`
+	Pods      []OrphanPod `json:"pods"`
+	TotalCost float64     `json:"totalCost"`
+}
+
+// orphanCandidate is a pod without owner and cost center, its labels decide whether it is an orphan
+type orphanCandidate struct {
+	OrphanPod
+	Namespace struct {
+		Xid string `json:"xid"`
+	} `json:"namespace"`
+	Labels []models.Label `json:"label"`
+}
+
+// RetrieveOrphanPodsInWindow returns the orphan pods alive in the time window [from, to) with their cost
+// for the part of their life inside the window.
+func RetrieveOrphanPodsInWindow(from, to time.Time) (OrphanReport, error) {
+	report := OrphanReport{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), Pods: []OrphanPod{}}
+	groups, err := RetrieveGroupsWithLabels()
+	if err != nil {
+		return report, err
+	}
+
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		candidates as var(func: le(startTime, ` + builder.Time(to) + `)) @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `
+			AND NOT has(replicaset) AND NOT has(statefulset) AND NOT has(daemonset) AND NOT has(job) AND NOT has(costCenter)) {
+			` + podCostInWindow(from, to) + `
+		}
+
+		pods(func: uid(candidates)) {
+			xid
+			startTime
+			endTime
+			namespace {
+				xid
+			}
+			label {
+				key
+				value
+			}
+			cpuCost: val(podCpuCost)
+			memoryCost: val(podMemCost)
+			storageCost: val(podStorageCost)
+			gpuCost: val(podGpuCost)
+		}
+	}`
+
+	type root struct {
+		Pods []orphanCandidate `json:"pods"`
+	}
+	newRoot := root{}
+	if err = builder.Execute(query, &newRoot); err != nil {
+		return report, err
+	}
+
+	for _, candidate := range newRoot.Pods {
+		if isAttributed(candidate.Labels, models.GetAttributionSettings().TeamLabels, groups) {
+			continue
+		}
+		pod := candidate.OrphanPod
+		pod.Namespace = candidate.Namespace.Xid
+		pod.Labels = map[string]string{}
+		for _, label := range candidate.Labels {
+			pod.Labels[label.Key] = label.Value
+		}
+		pod.TotalCost = pod.CPUCost + pod.MemoryCost + pod.StorageCost + pod.GPUCost
+		report.Pods = append(report.Pods, pod)
+		report.TotalCost += pod.TotalCost
+	}
+	sort.SliceStable(report.Pods, func(i, j int) bool {
+		return report.Pods[i].TotalCost > report.Pods[j].TotalCost
+	})
+	return report, nil
+}
+
+// isAttributed returns true if the labels contain a team label or any label of a group
+func isAttributed(labels []models.Label, teamLabels []string, groups []models.GroupCRD) bool {
+	for _, label := range labels {
+		for _, teamLabel := range teamLabels {
+			if label.Key == teamLabel && label.Value != "" {
+				return true
+			}
+		}
+		for _, group := range groups {
+			if value, isPresent := group.GetLabelsMap()[label.Key]; isPresent && value == label.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIsAttributed(t *testing.T) {
+	groups := []models.GroupCRD{{Labels: []*models.Label{{Key: "app", Value: "billing"}}}}
+	teamLabels := []string{"team"}
+
+	utils.Assert(t, isAttributed([]models.Label{{Key: "team", Value: "payments"}}, teamLabels, groups), "team label attributes the pod")
+	utils.Assert(t, isAttributed([]models.Label{{Key: "app", Value: "billing"}}, teamLabels, groups), "group label attributes the pod")
+	utils.Assert(t, !isAttributed([]models.Label{{Key: "app", Value: "debug"}}, teamLabels, groups), "other labels do not attribute the pod")
+	utils.Assert(t, !isAttributed(nil, teamLabels, groups), "pod without labels is not attributed")
+}
@@ -28,27 +28,36 @@ import (
 
 // RetrievePodsInteractions returns inbound and outbound interactions of a pod
 func RetrievePodsInteractions(name string, isOrphan bool) []byte {
+	builder := dgraph.NewReplicaQueryBuilder()
 	var query string
 	if name == All {
 		if isOrphan {
-			query = `query {
+			query = `{
 				pods(func: has(isPod)) {
 					name
 					outbound: pod {
 						name
 					}
+					outboundServices: externalService {
+						name
+						externalName
+					}
 					inbound: ~pod @filter(has(isPod)) {
 						name
 					}
 				}
 			}`
 		} else {
-			query = `query {
-				pods(func: has(isPod)) @filter(has(pod)) {
+			query = `{
+				pods(func: has(isPod)) @filter(has(pod) OR has(externalService)) {
 					name
 					outbound: pod {
 						name
 					}
+					outboundServices: externalService {
+						name
+						externalName
+					}
 					inbound: ~pod @filter(has(isPod)) {
 						name
 					}
@@ -56,12 +65,16 @@ func RetrievePodsInteractions(name string, isOrphan bool) []byte {
 			}`
 		}
 	} else {
-		query = `query {
-			pods(func: has(isPod)) @filter(eq(name, "` + name + `")) {
+		query = `{
+			pods(func: ` + builder.Eq("name", name) + `) @filter(has(isPod)) {
 				name
 				outbound: pod {
 					name
 				}
+				outboundServices: externalService {
+					name
+					externalName
+				}
 				inbound: ~pod @filter(has(isPod)) {
 					name
 				}
@@ -69,7 +82,7 @@ func RetrievePodsInteractions(name string, isOrphan bool) []byte {
 		}`
 	}
 
-	result, err := dgraph.ExecuteQueryRaw(query)
+	result, err := builder.ExecuteRaw(query)
 	if err != nil {
 		logrus.Errorf("Error while retrieving query for pods interactions. Name: (%v), isOrphan: (%v), error: (%v)", name, isOrphan, err)
 		return nil
@@ -79,12 +92,13 @@ func RetrievePodsInteractions(name string, isOrphan bool) []byte {
 
 // RetrievePodHierarchy returns hierarchy for a given pod
 func RetrievePodHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for pod, empty name is given")
 		return JSONDataWrapper{}
 	}
-	query := `query {
-		parent(func: has(isPod)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isPod)) {
 			name
 			type
 			children: ~pod @filter(has(isContainer)) {
@@ -93,18 +107,19 @@ func RetrievePodHierarchy(name string) JSONDataWrapper {
 			}
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrievePodMetrics returns metrics for a given pod
 func RetrievePodMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for pod, empty name is given")
 		return JSONDataWrapper{}
 	}
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		parent(func: has(isPod)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isPod)) {
 			name
 			type
 			children: ~pod @filter(has(isContainer)) {
@@ -119,8 +134,10 @@ func RetrievePodMetrics(name string) JSONDataWrapper {
 				durationInHoursChild as math((secondsSinceStartChild - secondsSinceEndChild) / 3600)
 				cpu: cpu as cpuRequest
 				memory: memory as memoryRequest
-				cpuCost: math(cpu * durationInHoursChild * ` + defaultCPUCostPerCPUPerHour + `)
-				memoryCost: math(memory * durationInHoursChild * ` + defaultMemCostPerGBPerHour + `)
+				cpuCost: math(cpu * durationInHoursChild * ` + cpuCostPerCPUPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
+				memoryCost: math(memory * durationInHoursChild * ` + memCostPerGBPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
+				gpu: gpu as gpuRequest
+				gpuCost: math(gpu * durationInHoursChild * ` + gpuCostPerGPUPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
 			}
 			cpu: podCpu as cpuRequest
 			memory: podMemory as memoryRequest
@@ -132,17 +149,25 @@ func RetrievePodMetrics(name string) JSONDataWrapper {
 			isTerminated as count(endTime)
 			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-			cpuCost: math(podCpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-			memoryCost: math(podMemory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-			storageCost: math(pvcStorage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+			nodePremium: podNodePremium as nodePremium
+			cpuCost: math(podCpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
+			memoryCost: math(podMemory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
+			storageCost: math(pvcStorage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			gpu: podGpu as gpuRequest
+			gpuCost: math(podGpu * durationInHours * ` + gpuCostPerGPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			cpuOverhead: podCpuOverhead as cpuOverhead
+			memoryOverhead: podMemoryOverhead as memoryOverhead
+			cpuOverheadCost: math(podCpuOverhead * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
+			memoryOverheadCost: math(podMemoryOverhead * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
 		}
 	}`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrievePodsInteractionsForAllLivePodsWithCount returns all pods in the dgraph
 func RetrievePodsInteractionsForAllLivePodsWithCount() ([]models.Pod, error) {
-	q := `query {
+	builder := dgraph.NewReplicaQueryBuilder()
+	q := `{
 		pods(func: has(isPod)) @filter((NOT has(endTime))) {
 			name
 			pod {
@@ -159,7 +184,7 @@ func RetrievePodsInteractionsForAllLivePodsWithCount() ([]models.Pod, error) {
 		Pods []models.Pod `json:"pods"`
 	}
 	newRoot := root{}
-	err := dgraph.ExecuteQuery(q, &newRoot)
+	err := builder.Execute(q, &newRoot)
 	if err != nil {
 		return nil, err
 	}
@@ -168,9 +193,10 @@ func RetrievePodsInteractionsForAllLivePodsWithCount() ([]models.Pod, error) {
 
 // RetrievePodsByLabelsFilter returns pods satisfying the filter conditions for labels (OR logic only)
 func RetrievePodsByLabelsFilter(labels map[string]string) ([]models.Pod, error) {
-	labelFilter := createFilterFromListOfLabels(labels)
+	builder := dgraph.NewReplicaQueryBuilder()
+	labelFilter := createFilterFromListOfLabels(builder, labels)
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	q := `query {
+	q := `{
 		var(func: has(isLabel)) @filter(` + labelFilter + `) {
             podUIDs as ~label @filter(has(isPod)) {
 				name
@@ -190,16 +216,16 @@ func RetrievePodsByLabelsFilter(labels map[string]string) ([]models.Pod, error)
 			isTerminated as count(endTime)
 			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-			cpuCost: math(podCpu * durationInHours * ` + defaultCPUCostPerCPUPerHour + `)
-			memoryCost: math(podMemory * durationInHours * ` + defaultMemCostPerGBPerHour + `)
-			storageCost: math(pvcStorage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+			cpuCost: math(podCpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			memoryCost: math(podMemory * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			storageCost: math(pvcStorage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
 		}
 	}`
 	type root struct {
 		Pods []models.Pod `json:"pods"`
 	}
 	newRoot := root{}
-	err := dgraph.ExecuteQuery(q, &newRoot)
+	err := builder.Execute(q, &newRoot)
 	if err != nil {
 		return nil, err
 	}
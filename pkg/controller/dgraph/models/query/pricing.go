@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// cpuCostPerCPUPerHour returns the cpu price expression for a resource which was active between the given
+// seconds since start and seconds since end dgraph variables.
+func cpuCostPerCPUPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.CPU
+	})
+}
+
+// memCostPerGBPerHour returns the memory price expression for a resource which was active between the given
+// seconds since start and seconds since end dgraph variables.
+func memCostPerGBPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.Memory
+	})
+}
+
+// storageCostPerGBPerHour returns the storage price expression for a resource which was active between the given
+// seconds since start and seconds since end dgraph variables.
+func storageCostPerGBPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.Storage
+	})
+}
+
+// gpuCostPerGPUPerHour returns the gpu price expression for a resource which was active between the given
+// seconds since start and seconds since end dgraph variables.
+func gpuCostPerGPUPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.GPU
+	})
+}
+
+// snapshotCostPerGBPerHour returns the volume snapshot price expression for a snapshot which existed between
+// the given seconds since start and seconds since end dgraph variables.
+func snapshotCostPerGBPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.Snapshot
+	})
+}
+
+// loadBalancerCostPerHour returns the load balancer price expression for a service which existed between
+// the given seconds since start and seconds since end dgraph variables.
+func loadBalancerCostPerHour(secondsSinceStart, secondsSinceEnd string) string {
+	return priceExpression(secondsSinceStart, secondsSinceEnd, func(period pricing.PricePeriod) float64 {
+		return period.LoadBalancer
+	})
+}
+
+// nodePremiumFactor returns the factor of the cpu and memory costs of a pod for the premium of the on-demand price of
+// its node over the list price, given the dgraph variable of the nodePremium of the pod. Pods without premium are
+// charged the list price.
+func nodePremiumFactor(nodePremium string) string {
+	return "(1.0 + " + nodePremium + ")"
+}
+
+// priceExpression gives the average price over the active duration of a resource in which every price
+// period is weighted by the time the resource was active in that period. So cost is computed using
+// the price in effect during each time slice instead of the latest price.
+func priceExpression(secondsSinceStart, secondsSinceEnd string, price func(pricing.PricePeriod) float64) string {
+	return periodsPriceExpression(pricing.GetPriceHistory(), secondsSinceStart, secondsSinceEnd, price)
+}
+
+// periodsPriceExpression gives the average price over the active duration of a resource with the given price periods
+func periodsPriceExpression(periods []pricing.PricePeriod, secondsSinceStart, secondsSinceEnd string,
+	price func(pricing.PricePeriod) float64) string {
+	if len(periods) == 1 {
+		return formatPrice(price(periods[0]))
+	}
+
+	var weightedPrices []string
+	for i, period := range periods {
+		periodStart := secondsSinceStart
+		if i > 0 {
+			periodStart = "min(" + secondsSinceStart + ", " + secondsSinceTime(period.EffectiveFrom) + ")"
+		}
+		periodEnd := secondsSinceEnd
+		if i < len(periods)-1 {
+			periodEnd = "max(" + secondsSinceEnd + ", " + secondsSinceTime(periods[i+1].EffectiveFrom) + ")"
+		}
+		weightedPrices = append(weightedPrices, formatPrice(price(period))+" * max("+periodStart+" - "+periodEnd+", 0.0)")
+	}
+	return "((" + strings.Join(weightedPrices, " + ") + ") / max(" + secondsSinceStart + " - " + secondsSinceEnd + ", 1.0))"
+}
+
+func secondsSinceTime(rfc3339Time string) string {
+	t, err := time.Parse(time.RFC3339, rfc3339Time)
+	if err != nil {
+		return "0.0"
+	}
+	return fmt.Sprintf("%f", utils.GetSecondsSince(t))
+}
+
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
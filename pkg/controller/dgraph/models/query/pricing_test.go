@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+// evaluatePriceExpression evaluates the dgraph math expression with the seconds since start and end of a resource
+func evaluatePriceExpression(t *testing.T, expression string, secondsSinceStart, secondsSinceEnd float64) float64 {
+	parsed, err := parser.ParseExpr(expression)
+	utils.Ok(t, err)
+	var evaluate func(ast.Expr) float64
+	evaluate = func(expr ast.Expr) float64 {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			value, err := strconv.ParseFloat(e.Value, 64)
+			utils.Ok(t, err)
+			return value
+		case *ast.Ident:
+			if e.Name == "start" {
+				return secondsSinceStart
+			}
+			return secondsSinceEnd
+		case *ast.ParenExpr:
+			return evaluate(e.X)
+		case *ast.BinaryExpr:
+			x, y := evaluate(e.X), evaluate(e.Y)
+			switch e.Op {
+			case token.ADD:
+				return x + y
+			case token.SUB:
+				return x - y
+			case token.MUL:
+				return x * y
+			case token.QUO:
+				return x / y
+			}
+		case *ast.CallExpr:
+			x, y := evaluate(e.Args[0]), evaluate(e.Args[1])
+			if e.Fun.(*ast.Ident).Name == "min" {
+				return math.Min(x, y)
+			}
+			return math.Max(x, y)
+		}
+		t.Fatalf("unexpected expression: %s", expression)
+		return 0
+	}
+	return evaluate(parsed)
+}
+
+func TestPeriodsPriceExpression(t *testing.T) {
+	hoursAgo := func(hours int) string {
+		return time.Now().Add(-time.Duration(hours) * time.Hour).UTC().Format(time.RFC3339)
+	}
+	periods := []pricing.PricePeriod{{CPU: 0.03}, {EffectiveFrom: hoursAgo(10), CPU: 0.06}, {EffectiveFrom: hoursAgo(4), CPU: 0.09}}
+	cpuPrice := func(period pricing.PricePeriod) float64 {
+		return period.CPU
+	}
+	tests := []struct {
+		name          string
+		periods       []pricing.PricePeriod
+		startHours    float64
+		endHours      float64
+		expectedPrice float64
+	}{
+		{"single period", periods[:1], 20, 0, 0.03},
+		{"before the price change", periods[:2], 20, 12, 0.03},
+		{"after the price change", periods[:2], 8, 2, 0.06},
+		{"spanning a price change", periods[:2], 12, 6, (2*0.03 + 4*0.06) / 6},
+		{"spanning two price changes", periods, 12, 2, (2*0.03 + 6*0.06 + 2*0.09) / 10},
+		{"running pod", periods, 6, 0, (2*0.06 + 4*0.09) / 6},
+	}
+	for _, test := range tests {
+		expression := periodsPriceExpression(test.periods, "start", "end", cpuPrice)
+		price := evaluatePriceExpression(t, expression, test.startHours*3600, test.endHours*3600)
+		utils.Assert(t, price > test.expectedPrice-1e-4 && price < test.expectedPrice+1e-4,
+			"%s: expected price %f, got %f from %s", test.name, test.expectedPrice, price, expression)
+	}
+}
@@ -21,18 +21,20 @@ import (
 	"fmt"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // RetrievePVHierarchy returns hierarchy for a given pv
 func RetrievePVHierarchy(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for PV, empty name is given")
 		return JSONDataWrapper{}
 	}
 
-	query := `query {
-		parent(func: has(isPersistentVolume)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isPersistentVolume)) {
 			name
 			type
 			children: ~pv @filter(has(isPersistentVolumeClaim)) {
@@ -41,19 +43,20 @@ func RetrievePVHierarchy(name string) JSONDataWrapper {
 			}
         }
     }`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
 
 // RetrievePVMetrics returns metrics for a given pv
 func RetrievePVMetrics(name string) JSONDataWrapper {
+	builder := dgraph.NewReplicaQueryBuilder()
 	if name == All {
 		logrus.Errorf("wrong type of query for PV, empty name is given")
 		return JSONDataWrapper{}
 	}
 
 	secondsSinceMonthStart := fmt.Sprintf("%f", utils.GetSecondsSince(utils.GetCurrentMonthStartTime()))
-	query := `query {
-		parent(func: has(isPersistentVolume)) @filter(eq(name, "` + name + `")) {
+	query := `{
+		parent(func: ` + builder.Eq("name", name) + `) @filter(has(isPersistentVolume)) {
 			name
 			type
 			children: ~pv @filter(has(isPersistentVolumeClaim)) {
@@ -67,7 +70,7 @@ func RetrievePVMetrics(name string) JSONDataWrapper {
 				isTerminatedChild as count(endTime)
 				secondsSinceEndChild as math(cond(isTerminatedChild == 0, 0.0, since(etChild)))
 				durationInHoursChild as math((secondsSinceStartChild - secondsSinceEndChild) / 3600)
-				storageCost: math(pvcStorage * durationInHoursChild * ` + defaultMemCostPerGBPerHour + `)
+				storageCost: math(pvcStorage * durationInHoursChild * ` + memCostPerGBPerHour("secondsSinceStartChild", "secondsSinceEndChild") + `)
 			}
 			storage: storage as storageCapacity
 			st as startTime
@@ -77,8 +80,8 @@ func RetrievePVMetrics(name string) JSONDataWrapper {
 			isTerminated as count(endTime)
 			secondsSinceEnd as math(cond(isTerminated == 0, 0.0, since(et)))
 			durationInHours as math((secondsSinceStart - secondsSinceEnd) / 3600)
-			storageCost: math(storage * durationInHours * ` + defaultStorageCostPerGBPerHour + `)
+			storageCost: math(storage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
         }
     }`
-	return getJSONDataFromQuery(query)
+	return getJSONDataFromQuery(builder, query)
 }
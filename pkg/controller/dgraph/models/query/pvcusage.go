@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// PVCStorageUsage gives the provisioned and the used storage (in GB) of a pvc with its storage cost in a window.
+// The wasted cost is the part of the cost paid for the storage which is not used on average.
+type PVCStorageUsage struct {
+	Namespace          string  `json:"namespace"`
+	Name               string  `json:"name"`
+	StorageCapacity    float64 `json:"storageCapacity"`
+	StorageUsed        float64 `json:"storageUsed"`
+	AverageStorageUsed float64 `json:"averageStorageUsed"`
+	Utilization        float64 `json:"utilization"`
+	UsageTime          string  `json:"usageTime,omitempty"`
+	StorageCost        float64 `json:"storageCost"`
+	WastedStorageCost  float64 `json:"wastedStorageCost"`
+}
+
+// StorageUsageReport gives the storage usage of the pvcs in a window, the most wasteful pvcs first. Pvcs whose usage
+// was never reported by the kubelet (ex: not mounted) have no utilization and count as unsampled.
+type StorageUsageReport struct {
+	From              string            `json:"from"`
+	To                string            `json:"to"`
+	StorageCapacity   float64           `json:"storageCapacity"`
+	StorageUsed       float64           `json:"storageUsed"`
+	StorageCost       float64           `json:"storageCost"`
+	WastedStorageCost float64           `json:"wastedStorageCost"`
+	Unsampled         int               `json:"unsampled"`
+	Pvcs              []PVCStorageUsage `json:"pvcs"`
+}
+
+// RetrievePVCStorageUsage returns the storage usage and the wasted storage cost of the pvcs of the namespace
+// (all namespaces if it is empty) which were alive in the window [from, to)
+func RetrievePVCStorageUsage(namespace string, from, to time.Time) (StorageUsageReport, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	inWindow := podsInWindowFilter(builder, from, to)
+	claimsVar := `claims as var(func: has(isPersistentVolumeClaim)) @filter(` + inWindow + `)`
+	if namespace != All {
+		claimsVar = `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			claims as ~namespace @filter(has(isPersistentVolumeClaim) AND ` + inWindow + `)
+		}`
+	}
+	query := `{
+		` + claimsVar + `
+		var(func: uid(claims)) {
+			` + lifetimeInWindow("claim", from, to) + `
+			claimCapacity as storageCapacity
+			claimCost as math(claimCapacity * claimHours * ` + storageCostPerGBPerHour("claimSecondsSinceStart", "claimSecondsSinceEnd") + `)
+		}
+		pvcs(func: uid(claims)) {
+			xid
+			storageCapacity
+			storageUsed
+			averageStorageUsed
+			storageUsageSamples
+			usageTime: storageUsageTime
+			storageCost: val(claimCost)
+		}
+	}`
+
+	type root struct {
+		Pvcs []struct {
+			Xid                 string  `json:"xid"`
+			StorageCapacity     float64 `json:"storageCapacity"`
+			StorageUsed         float64 `json:"storageUsed"`
+			AverageStorageUsed  float64 `json:"averageStorageUsed"`
+			StorageUsageSamples int     `json:"storageUsageSamples"`
+			UsageTime           string  `json:"usageTime"`
+			StorageCost         float64 `json:"storageCost"`
+		} `json:"pvcs"`
+	}
+	report := StorageUsageReport{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), Pvcs: []PVCStorageUsage{}}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return report, err
+	}
+
+	for _, pvc := range newRoot.Pvcs {
+		usage := PVCStorageUsage{
+			Name:               pvc.Xid,
+			StorageCapacity:    pvc.StorageCapacity,
+			StorageUsed:        pvc.StorageUsed,
+			AverageStorageUsed: pvc.AverageStorageUsed,
+			UsageTime:          pvc.UsageTime,
+			StorageCost:        pvc.StorageCost,
+		}
+		if parts := strings.SplitN(pvc.Xid, ":", 2); len(parts) == 2 {
+			usage.Namespace, usage.Name = parts[0], parts[1]
+		}
+		if pvc.StorageUsageSamples > 0 {
+			usage.Utilization, usage.WastedStorageCost = storageWaste(pvc.StorageCapacity, pvc.AverageStorageUsed, pvc.StorageCost)
+			report.StorageUsed += pvc.StorageUsed
+		} else {
+			report.Unsampled++
+		}
+		report.StorageCapacity += pvc.StorageCapacity
+		report.StorageCost += pvc.StorageCost
+		report.WastedStorageCost += usage.WastedStorageCost
+		report.Pvcs = append(report.Pvcs, usage)
+	}
+	sort.SliceStable(report.Pvcs, func(i, j int) bool {
+		return report.Pvcs[i].WastedStorageCost > report.Pvcs[j].WastedStorageCost
+	})
+	return report, nil
+}
+
+// storageWaste returns the utilization of the provisioned storage and the cost of its unused part. Usage above the
+// capacity (ex: filesystem overhead of a resized volume) counts as full utilization.
+func storageWaste(capacity, used, cost float64) (float64, float64) {
+	if capacity <= 0 {
+		return 0, 0
+	}
+	utilization := used / capacity
+	if utilization > 1 {
+		utilization = 1
+	}
+	return utilization, cost * (1 - utilization)
+}
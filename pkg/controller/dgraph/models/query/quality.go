@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// Confidence levels of a cost
+const (
+	HighConfidence   = "high"
+	MediumConfidence = "medium"
+	LowConfidence    = "low"
+)
+
+// estimatedShareForLowConfidence is the share of estimated pods above which the confidence of a cost is low
+const estimatedShareForLowConfidence = 0.1
+
+// DataQuality tells how much of the cost of a scope is estimated instead of measured. Pods whose usage was never
+// sampled from the metrics api are charged with their requests, pods without owner can not be attributed
+// to a workload and pods on nodes whose instance type is not in the pricing catalog are charged with unit prices.
+// Costs are Estimated in blackbox mode, when no metrics source is available to measure the usage of the pods.
+type DataQuality struct {
+	MetricsMode         string   `json:"metricsMode"`
+	Estimated           bool     `json:"estimated,omitempty"`
+	Confidence          string   `json:"confidence"`
+	Pods                int      `json:"pods"`
+	PodsWithoutSamples  int      `json:"podsWithoutSamples"`
+	PodsWithoutOwner    int      `json:"podsWithoutOwner"`
+	PodsOnUnpricedNodes int      `json:"podsOnUnpricedNodes"`
+	UnpricedNodes       []string `json:"unpricedNodes,omitempty"`
+	DefaultPrices       bool     `json:"defaultPrices,omitempty"`
+}
+
+// podQuality is the data available for a pod alive in the window
+type podQuality struct {
+	Xid       string `json:"xid"`
+	Namespace struct {
+		Xid string `json:"xid"`
+	} `json:"namespace"`
+	Node *struct {
+		Xid          string `json:"xid"`
+		InstanceType string `json:"instanceType"`
+	} `json:"node"`
+	Replicasets  int `json:"replicasets"`
+	Statefulsets int `json:"statefulsets"`
+	Daemonsets   int `json:"daemonsets"`
+	Jobs         int `json:"jobs"`
+	UsageSamples int `json:"usageSamples"`
+}
+
+// AddNamespaceQuality sets the data quality of the given namespace costs for the time window [from, to)
+func AddNamespaceQuality(costs []ResourceCost, from, to time.Time) error {
+	pods, err := retrievePodQuality(from, to)
+	if err != nil {
+		return err
+	}
+	byNamespace := map[string][]podQuality{}
+	for _, pod := range pods {
+		byNamespace[pod.Namespace.Xid] = append(byNamespace[pod.Namespace.Xid], pod)
+	}
+	catalog := pricing.GetCatalog()
+	for i := range costs {
+		quality := computeDataQuality(byNamespace[costs[i].Xid], catalog, pricing.IsDefault(), models.IsBlackbox())
+		costs[i].Quality = &quality
+	}
+	return nil
+}
+
+// AddPodQuality sets the data quality of the given pod costs for the time window [from, to)
+func AddPodQuality(costs []ResourceCost, from, to time.Time) error {
+	pods, err := retrievePodQuality(from, to)
+	if err != nil {
+		return err
+	}
+	byXid := map[string]podQuality{}
+	for _, pod := range pods {
+		byXid[pod.Xid] = pod
+	}
+	catalog := pricing.GetCatalog()
+	for i := range costs {
+		var podsOfCost []podQuality
+		if pod, isPresent := byXid[costs[i].Xid]; isPresent {
+			podsOfCost = append(podsOfCost, pod)
+		}
+		quality := computeDataQuality(podsOfCost, catalog, pricing.IsDefault(), models.IsBlackbox())
+		costs[i].Quality = &quality
+	}
+	return nil
+}
+
+func computeDataQuality(pods []podQuality, catalog pricing.Catalog, defaultPrices, blackbox bool) DataQuality {
+	priced := map[string]bool{}
+	for _, instanceType := range catalog.InstanceTypes {
+		priced[instanceType.Name] = true
+	}
+	quality := DataQuality{MetricsMode: models.MetricsModeMeasured, Pods: len(pods), DefaultPrices: defaultPrices}
+	if blackbox {
+		quality.MetricsMode, quality.Estimated = models.MetricsModeBlackbox, true
+	}
+	unpricedNodes := map[string]bool{}
+	estimated := 0
+	for _, pod := range pods {
+		isEstimated := false
+		if pod.UsageSamples == 0 {
+			quality.PodsWithoutSamples++
+			isEstimated = true
+		}
+		if pod.Replicasets+pod.Statefulsets+pod.Daemonsets+pod.Jobs == 0 {
+			quality.PodsWithoutOwner++
+		}
+		// catalogs without instance types price every node with unit prices, nodes are not flagged then
+		if len(priced) > 0 && pod.Node != nil && !priced[pod.Node.InstanceType] {
+			quality.PodsOnUnpricedNodes++
+			unpricedNodes[pod.Node.Xid] = true
+			isEstimated = true
+		}
+		if isEstimated {
+			estimated++
+		}
+	}
+	quality.UnpricedNodes = sortedKeys(unpricedNodes)
+	if len(quality.UnpricedNodes) == 0 {
+		quality.UnpricedNodes = nil
+	}
+
+	switch {
+	case len(pods) > 0 && float64(estimated)/float64(len(pods)) > estimatedShareForLowConfidence:
+		quality.Confidence = LowConfidence
+	case estimated > 0 || quality.PodsWithoutOwner > 0 || defaultPrices || blackbox:
+		quality.Confidence = MediumConfidence
+	default:
+		quality.Confidence = HighConfidence
+	}
+	return quality
+}
+
+func retrievePodQuality(from, to time.Time) ([]podQuality, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		pods(func: le(startTime, ` + builder.Time(to) + `)) @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+			xid
+			namespace {
+				xid
+			}
+			node {
+				xid
+				instanceType
+			}
+			replicasets: count(replicaset)
+			statefulsets: count(statefulset)
+			daemonsets: count(daemonset)
+			jobs: count(job)
+			usageSamples
+		}
+	}`
+
+	type root struct {
+		Pods []podQuality `json:"pods"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Pods, nil
+}
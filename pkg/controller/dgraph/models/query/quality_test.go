@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestComputeDataQuality(t *testing.T) {
+	var pods []podQuality
+	err := json.Unmarshal([]byte(`[
+		{"xid": "default:web-1", "node": {"xid": "node-1", "instanceType": "m5.large"}, "replicasets": 1, "usageSamples": 3},
+		{"xid": "default:web-2", "node": {"xid": "node-2", "instanceType": "custom"}, "replicasets": 1, "usageSamples": 1},
+		{"xid": "default:debug", "node": {"xid": "node-1", "instanceType": "m5.large"}, "usageSamples": 0}
+	]`), &pods)
+	utils.Ok(t, err)
+	catalog := pricing.Catalog{InstanceTypes: []pricing.InstanceType{{Name: "m5.large"}}}
+
+	quality := computeDataQuality(pods, catalog, false, false)
+	utils.Equals(t, 3, quality.Pods)
+	utils.Equals(t, 1, quality.PodsWithoutSamples)
+	utils.Equals(t, 1, quality.PodsWithoutOwner)
+	utils.Equals(t, 1, quality.PodsOnUnpricedNodes)
+	utils.Equals(t, []string{"node-2"}, quality.UnpricedNodes)
+	utils.Equals(t, LowConfidence, quality.Confidence)
+
+	quality = computeDataQuality(pods[:1], catalog, false, false)
+	utils.Equals(t, HighConfidence, quality.Confidence)
+	utils.Equals(t, models.MetricsModeMeasured, quality.MetricsMode)
+
+	// costs of a blackbox cluster are estimated from requests
+	quality = computeDataQuality(pods[:1], catalog, false, true)
+	utils.Equals(t, MediumConfidence, quality.Confidence)
+	utils.Assert(t, quality.Estimated, "expected costs to be estimated in blackbox mode")
+
+	// without instance types in the catalog nodes are not flagged
+	quality = computeDataQuality(pods[1:2], pricing.Catalog{}, true, false)
+	utils.Equals(t, 0, quality.PodsOnUnpricedNodes)
+	utils.Equals(t, MediumConfidence, quality.Confidence)
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// CostRate is the cost per hour of the resources allocated to a scope right now. CPU is in cpus, memory,
+// storage and snapshots are in GB and gpu is in gpus (or gpu fractions).
+type CostRate struct {
+	Xid                     string      `json:"xid,omitempty"`
+	CPU                     utils.Cores `json:"cpu"`
+	Memory                  utils.GB    `json:"memory"`
+	Storage                 utils.GB    `json:"storage"`
+	GPU                     float64     `json:"gpu"`
+	Snapshots               utils.GB    `json:"snapshots,omitempty"`
+	LoadBalancers           int         `json:"loadBalancers,omitempty"`
+	CPUCostPerHour          float64     `json:"cpuCostPerHour"`
+	MemoryCostPerHour       float64     `json:"memoryCostPerHour"`
+	StorageCostPerHour      float64     `json:"storageCostPerHour"`
+	GPUCostPerHour          float64     `json:"gpuCostPerHour"`
+	SnapshotCostPerHour     float64     `json:"snapshotCostPerHour,omitempty"`
+	LoadBalancerCostPerHour float64     `json:"loadBalancerCostPerHour,omitempty"`
+	CostPerHour             float64     `json:"costPerHour"`
+
+	// average cpu (cpus) and memory (GB) usage of the pods sampled from the metrics api, pods without samples are
+	// not counted
+	CPUUsage    utils.Cores `json:"cpuUsage,omitempty"`
+	MemoryUsage utils.GB    `json:"memoryUsage,omitempty"`
+}
+
+// NodeCostRate is the cost per hour of a node running right now with its capacity and the requests of its pods.
+// Nodes without an on-demand or spot price cost the list price of their capacity.
+type NodeCostRate struct {
+	Xid            string      `json:"xid"`
+	CapacityType   string      `json:"capacityType,omitempty"`
+	CPUCapacity    utils.Cores `json:"cpuCapacity"`
+	MemoryCapacity utils.GB    `json:"memoryCapacity"`
+	CPU            utils.Cores `json:"cpu"`
+	Memory         utils.GB    `json:"memory"`
+	PricePerHour   float64     `json:"pricePerHour,omitempty"`
+	CostPerHour    float64     `json:"costPerHour"`
+}
+
+// CostRates is the burn rate of the cluster and of its namespaces or pods at a point in time, priced with the
+// catalog in effect at that time
+type CostRates struct {
+	Time         string     `json:"time"`
+	PriceVersion string     `json:"priceVersion"`
+	Cluster      CostRate   `json:"cluster"`
+	Scopes       []CostRate `json:"scopes"`
+}
+
+// RetrieveCostRates returns the cost per hour of the resources allocated right now to the cluster and to every
+// namespace (scope: namespace) or to the `limit` pods with the highest rate (scope: pod). Namespaces are charged
+// for their claims, snapshots and load balancers, pods for the volumes attached to them. The rate of the cluster
+// is the sum of the namespaces for both scopes.
+func RetrieveCostRates(scope string, limit int) (CostRates, error) {
+	now := time.Now()
+	catalog := pricing.GetCatalog()
+	rates := CostRates{Time: utils.ConverTimeToRFC3339(now), PriceVersion: catalog.Version}
+
+	namespaces, err := retrieveNamespaceAllocations(now)
+	if err != nil {
+		return rates, err
+	}
+	for i := range namespaces {
+		namespaces[i].price(catalog)
+		rates.Cluster.add(namespaces[i])
+	}
+	rates.Scopes = namespaces
+	if scope != Namespace {
+		if rates.Scopes, err = retrievePodAllocations(now); err != nil {
+			return rates, err
+		}
+		for i := range rates.Scopes {
+			rates.Scopes[i].price(catalog)
+		}
+	}
+
+	sort.SliceStable(rates.Scopes, func(i, j int) bool {
+		return rates.Scopes[i].CostPerHour > rates.Scopes[j].CostPerHour
+	})
+	if scope != Namespace && len(rates.Scopes) > limit {
+		rates.Scopes = rates.Scopes[:limit]
+	}
+	return rates, nil
+}
+
+func (rate *CostRate) price(catalog pricing.Catalog) {
+	rate.CPUCostPerHour = rate.CPU.CostPerHour(catalog.CPU)
+	rate.MemoryCostPerHour = rate.Memory.CostPerHour(catalog.Memory)
+	rate.StorageCostPerHour = rate.Storage.CostPerHour(catalog.Storage)
+	rate.GPUCostPerHour = rate.GPU * catalog.GPU
+	rate.SnapshotCostPerHour = rate.Snapshots.CostPerHour(catalog.Snapshot)
+	rate.LoadBalancerCostPerHour = float64(rate.LoadBalancers) * catalog.LoadBalancer
+	rate.CostPerHour = rate.CPUCostPerHour + rate.MemoryCostPerHour + rate.StorageCostPerHour + rate.GPUCostPerHour +
+		rate.SnapshotCostPerHour + rate.LoadBalancerCostPerHour
+}
+
+func (rate *CostRate) add(other CostRate) {
+	rate.CPU += other.CPU
+	rate.Memory += other.Memory
+	rate.Storage += other.Storage
+	rate.GPU += other.GPU
+	rate.Snapshots += other.Snapshots
+	rate.LoadBalancers += other.LoadBalancers
+	rate.CPUCostPerHour += other.CPUCostPerHour
+	rate.MemoryCostPerHour += other.MemoryCostPerHour
+	rate.StorageCostPerHour += other.StorageCostPerHour
+	rate.GPUCostPerHour += other.GPUCostPerHour
+	rate.SnapshotCostPerHour += other.SnapshotCostPerHour
+	rate.LoadBalancerCostPerHour += other.LoadBalancerCostPerHour
+	rate.CostPerHour += other.CostPerHour
+	rate.CPUUsage += other.CPUUsage
+	rate.MemoryUsage += other.MemoryUsage
+}
+
+// RetrieveDeploymentCostRates returns the cost per hour of the requests of the pods of every deployment running
+// right now, with their average usage
+func RetrieveDeploymentCostRates() ([]CostRate, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	alive := aliveAtFilter(builder, time.Now())
+	query := `{
+		deployments as var(func: has(isDeployment)) @filter(` + alive + `) {
+			~deployment @filter(has(isReplicaset)) {
+				~replicaset @filter(has(isPod) AND ` + alive + `) {
+					podCpu as cpuRequest
+					podMem as memoryRequest
+					podStorage as storageRequest
+					podGpu as gpuRequest
+					podCpuUsage as cpuUsage
+					podMemUsage as memoryUsage
+				}
+				replicasetCpu as sum(val(podCpu))
+				replicasetMem as sum(val(podMem))
+				replicasetStorage as sum(val(podStorage))
+				replicasetGpu as sum(val(podGpu))
+				replicasetCpuUsage as sum(val(podCpuUsage))
+				replicasetMemUsage as sum(val(podMemUsage))
+			}
+			deploymentCpu as sum(val(replicasetCpu))
+			deploymentMem as sum(val(replicasetMem))
+			deploymentStorage as sum(val(replicasetStorage))
+			deploymentGpu as sum(val(replicasetGpu))
+			deploymentCpuUsage as sum(val(replicasetCpuUsage))
+			deploymentMemUsage as sum(val(replicasetMemUsage))
+		}
+
+		scopes(func: uid(deployments)) {
+			xid
+			cpu: val(deploymentCpu)
+			memory: val(deploymentMem)
+			storage: val(deploymentStorage)
+			gpu: val(deploymentGpu)
+			cpuUsage: val(deploymentCpuUsage)
+			memoryUsage: val(deploymentMemUsage)
+		}
+	}`
+	rates, err := executeAllocationQuery(builder, query)
+	if err != nil {
+		return nil, err
+	}
+	catalog := pricing.GetCatalog()
+	for i := range rates {
+		rates[i].price(catalog)
+	}
+	return rates, nil
+}
+
+// RetrieveNodeCostRates returns the cost per hour of the nodes running right now
+func RetrieveNodeCostRates() ([]NodeCostRate, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	alive := aliveAtFilter(builder, time.Now())
+	query := `{
+		nodes as var(func: has(isNode)) @filter(` + alive + `) {
+			~node @filter(has(isPod) AND ` + alive + `) {
+				podCpu as cpuRequest
+				podMem as memoryRequest
+			}
+			nodeCpu as sum(val(podCpu))
+			nodeMem as sum(val(podMem))
+		}
+
+		nodes(func: uid(nodes)) {
+			xid
+			capacityType
+			cpuCapacity
+			memoryCapacity
+			pricePerHour
+			cpu: val(nodeCpu)
+			memory: val(nodeMem)
+		}
+	}`
+	type root struct {
+		Nodes []NodeCostRate `json:"nodes"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	for i, node := range newRoot.Nodes {
+		newRoot.Nodes[i].CostPerHour = nodeCostPerHour(node)
+	}
+	return newRoot.Nodes, nil
+}
+
+// nodeCostPerHour returns the price of the node, or the list price of its capacity (with the spot discount for spot
+// nodes) when it has none
+func nodeCostPerHour(node NodeCostRate) float64 {
+	if node.PricePerHour > 0 {
+		return node.PricePerHour
+	}
+	if node.CapacityType == pricing.SpotCapacity {
+		return pricing.SpotPrice(0, node.CPUCapacity, node.MemoryCapacity)
+	}
+	return pricing.GetCatalog().ListPrice(node.CPUCapacity, node.MemoryCapacity)
+}
+
+func retrieveNamespaceAllocations(at time.Time) ([]CostRate, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	alive := aliveAtFilter(builder, at)
+	query := `{
+		ns as var(func: has(isNamespace)) @filter(` + alive + `) {
+			alivePods: ~namespace @filter(has(isPod) AND ` + alive + `) {
+				podCpu as cpuRequest
+				podMem as memoryRequest
+				podGpu as gpuRequest
+				podCpuUsage as cpuUsage
+				podMemUsage as memoryUsage
+			}
+			aliveClaims: ~namespace @filter(has(isPersistentVolumeClaim) AND ` + alive + `) {
+				claimStorage as storageCapacity
+			}
+			aliveSnapshots: ~namespace @filter(has(isVolumeSnapshot) AND ` + alive + `) {
+				snapshotStorage as restoreSize
+			}
+			namespaceLoadBalancers as count(~namespace @filter(has(isService) AND ` + builder.Eq("serviceType", "LoadBalancer") + ` AND ` + alive + `))
+			namespaceCpu as sum(val(podCpu))
+			namespaceMem as sum(val(podMem))
+			namespaceGpu as sum(val(podGpu))
+			namespaceCpuUsage as sum(val(podCpuUsage))
+			namespaceMemUsage as sum(val(podMemUsage))
+			namespaceStorage as sum(val(claimStorage))
+			namespaceSnapshots as sum(val(snapshotStorage))
+		}
+
+		scopes(func: uid(ns)) {
+			xid
+			cpu: val(namespaceCpu)
+			memory: val(namespaceMem)
+			storage: val(namespaceStorage)
+			gpu: val(namespaceGpu)
+			snapshots: val(namespaceSnapshots)
+			loadBalancers: val(namespaceLoadBalancers)
+			cpuUsage: val(namespaceCpuUsage)
+			memoryUsage: val(namespaceMemUsage)
+		}
+	}`
+	return executeAllocationQuery(builder, query)
+}
+
+func retrievePodAllocations(at time.Time) ([]CostRate, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		scopes(func: has(isPod)) @filter(` + aliveAtFilter(builder, at) + `) {
+			xid
+			cpu: cpuRequest
+			memory: memoryRequest
+			storage: storageRequest
+			gpu: gpuRequest
+			cpuUsage
+			memoryUsage
+		}
+	}`
+	return executeAllocationQuery(builder, query)
+}
+
+func executeAllocationQuery(builder *dgraph.QueryBuilder, query string) ([]CostRate, error) {
+	type root struct {
+		Scopes []CostRate `json:"scopes"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Scopes, nil
+}
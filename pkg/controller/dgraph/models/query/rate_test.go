@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestCostRatePrice(t *testing.T) {
+	catalog := pricing.Catalog{CPU: 0.5, Memory: 0.25, Storage: 0.01, GPU: 2, Snapshot: 0.001, LoadBalancer: 0.05}
+	rate := CostRate{Xid: "default", CPU: 2, Memory: 4, Storage: 100, GPU: 0.5, Snapshots: 1000, LoadBalancers: 2}
+	rate.price(catalog)
+	utils.Equals(t, 1.0, rate.CPUCostPerHour)
+	utils.Equals(t, 1.0, rate.GPUCostPerHour)
+	utils.Equals(t, 0.1, rate.LoadBalancerCostPerHour)
+	utils.Equals(t, 5.1, rate.CostPerHour)
+
+	cluster := CostRate{}
+	cluster.add(rate)
+	cluster.add(rate)
+	utils.Equals(t, 4, cluster.LoadBalancers)
+	utils.Equals(t, 10.2, cluster.CostPerHour)
+}
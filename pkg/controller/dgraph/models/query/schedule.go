@@ -0,0 +1,171 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// PodRun is the time a pod ran in a time window with its requests (memory in GB) and costs
+type PodRun struct {
+	Start         time.Time
+	End           time.Time
+	CPURequest    float64
+	MemoryRequest float64
+	CPUCost       float64
+	MemoryCost    float64
+	Cost          float64
+}
+
+// WorkloadPods is a deployment or statefulset with the pods it ran in a time window
+type WorkloadPods struct {
+	Kind string
+	Xid  string
+	Pods []PodRun
+}
+
+// RetrieveWorkloadPods returns the deployments and statefulsets which still exist in the namespace, or which have a
+// pod with any of the labels when labels are given, with the pods they ran in the time window [from, to)
+func RetrieveWorkloadPods(namespace string, labels map[string]string, from, to time.Time) ([]WorkloadPods, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	deployments, err := retrieveWorkloadPods(builder, DeploymentKind,
+		workloadScope(builder, namespace, labels, "isDeployment", `replicaset { w as deployment @filter(NOT has(endTime)) }`), from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	builder = dgraph.NewReplicaQueryBuilder()
+	statefulsets, err := retrieveWorkloadPods(builder, StatefulSetKind,
+		workloadScope(builder, namespace, labels, "isStatefulset", `w as statefulset @filter(NOT has(endTime))`), from, to)
+	if err != nil {
+		return nil, err
+	}
+	return append(deployments, statefulsets...), nil
+}
+
+// RetrieveWorkloadPodsByXid returns the pods which the deployment or statefulset with the given xid ran in the time
+// window [from, to), nil if there is no such workload
+func RetrieveWorkloadPodsByXid(kind, xid string, from, to time.Time) (*WorkloadPods, error) {
+	marker := "isDeployment"
+	if kind == StatefulSetKind {
+		marker = "isStatefulset"
+	}
+	builder := dgraph.NewReplicaQueryBuilder()
+	scope := `w as var(func: ` + builder.Eq("xid", xid) + `) @filter(has(` + marker + `))`
+	workloads, err := retrieveWorkloadPods(builder, kind, scope, from, to)
+	if err != nil || len(workloads) == 0 {
+		return nil, err
+	}
+	return &workloads[0], nil
+}
+
+// workloadScope returns the blocks which define w, the workloads of the namespace or the workloads reached by
+// podEdges from the pods having any of the labels
+func workloadScope(builder *dgraph.QueryBuilder, namespace string, labels map[string]string, marker, podEdges string) string {
+	if len(labels) > 0 {
+		return `var(func: has(isLabel)) @filter(` + createFilterFromListOfLabels(builder, labels) + `) {
+			~label @filter(has(isPod)) {
+				` + podEdges + `
+			}
+		}`
+	}
+	return `var(func: ` + builder.Eq("xid", namespace) + `) @filter(has(isNamespace)) {
+			w as ~namespace @filter(has(` + marker + `) AND NOT has(endTime))
+		}`
+}
+
+// workloadPodRuns returns the block of the pods of the workloads of the kind with their start time, end time,
+// requests and costs
+func workloadPodRuns(builder *dgraph.QueryBuilder, kind string, from, to time.Time) string {
+	pods := ` @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+					` + podCostInWindow(from, to) + `
+					cpuCost: math(podCpuCost)
+					memoryCost: math(podMemCost)
+					cost: math(podCpuCost + podMemCost + podStorageCost + podGpuCost)
+				}`
+	if kind == StatefulSetKind {
+		return `pods: ~statefulset` + pods
+	}
+	return `~deployment @filter(has(isReplicaset)) {
+				pods: ~replicaset` + pods + `
+			}`
+}
+
+func retrieveWorkloadPods(builder *dgraph.QueryBuilder, kind, scope string, from, to time.Time) ([]WorkloadPods, error) {
+	pods := workloadPodRuns(builder, kind, from, to)
+	query := `{
+		` + scope + `
+
+		workloads(func: uid(w)) {
+			xid
+			` + pods + `
+		}
+	}`
+
+	type pod struct {
+		StartTime     string  `json:"startTime"`
+		EndTime       string  `json:"endTime"`
+		CPURequest    float64 `json:"cpuRequest"`
+		MemoryRequest float64 `json:"memoryRequest"`
+		CPUCost       float64 `json:"cpuCost"`
+		MemoryCost    float64 `json:"memoryCost"`
+		Cost          float64 `json:"cost"`
+	}
+	type workload struct {
+		Xid         string `json:"xid"`
+		Pods        []pod  `json:"pods"`
+		Replicasets []struct {
+			Pods []pod `json:"pods"`
+		} `json:"~deployment"`
+	}
+	type root struct {
+		Workloads []workload `json:"workloads"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+
+	workloads := []WorkloadPods{}
+	for _, w := range newRoot.Workloads {
+		pods := w.Pods
+		for _, rs := range w.Replicasets {
+			pods = append(pods, rs.Pods...)
+		}
+		result := WorkloadPods{Kind: kind, Xid: w.Xid}
+		for _, p := range pods {
+			start, err := time.Parse(time.RFC3339, p.StartTime)
+			if err != nil {
+				continue
+			}
+			end := to
+			if endTime, err := time.Parse(time.RFC3339, p.EndTime); err == nil && endTime.Before(to) {
+				end = endTime
+			}
+			if start.Before(from) {
+				start = from
+			}
+			result.Pods = append(result.Pods, PodRun{Start: start, End: end, CPURequest: p.CPURequest,
+				MemoryRequest: p.MemoryRequest, CPUCost: p.CPUCost, MemoryCost: p.MemoryCost, Cost: p.Cost})
+		}
+		workloads = append(workloads, result)
+	}
+	return workloads, nil
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// number of nodes of each type whose predicates are listed in the schema description
+const schemaSampleSize = 20
+
+// nodeTypes are the types of the nodes stored by purser with the predicate marking the nodes of each type
+var nodeTypes = map[string]string{
+	"application":           models.IsApplication,
+	"clusterEvent":          models.IsClusterEvent,
+	"container":             models.IsContainer,
+	"costCenter":            models.IsCostCenter,
+	"costSnapshot":          models.IsCostSnapshot,
+	"costSummary":           models.IsCostSummary,
+	"dailySeal":             models.IsDailySeal,
+	"daemonset":             models.IsDaemonset,
+	"deployment":            models.IsDeployment,
+	"environment":           models.IsEnvironment,
+	"externalCost":          models.IsExternalCost,
+	"helmRelease":           models.IsHelmRelease,
+	"job":                   models.IsJob,
+	"label":                 models.Islabel,
+	"metricSample":          models.IsMetricSample,
+	"namespace":             models.IsNamespace,
+	"namespaceArchive":      models.IsNamespaceArchive,
+	"networkPolicy":         models.IsNetworkPolicy,
+	"networkTraffic":        models.IsNetworkTraffic,
+	"node":                  models.IsNode,
+	"nodePressure":          models.IsNodePressure,
+	"nodeVersion":           models.IsNodeVersion,
+	"objectCount":           models.IsObjectCount,
+	"operator":              models.IsOperator,
+	"persistentVolume":      models.IsPersistentVolume,
+	"persistentVolumeClaim": models.IsPersistentVolumeClaim,
+	"pod":                   models.IsPod,
+	"podPlacement":          models.IsPodPlacement,
+	"proc":                  models.IsProc,
+	"purserGroup":           models.IsPurserGroup,
+	"replicaset":            models.IsReplicaset,
+	"service":               models.IsService,
+	"statefulset":           models.IsStatefulset,
+	"subscriber":            models.IsSubscriber,
+	"volumeSnapshot":        models.IsVolumeSnapshot,
+	"workloadInteraction":   models.IsWorkloadInteraction,
+}
+
+// NodeMarkers returns the predicates marking the types of the nodes stored by purser
+func NodeMarkers() []string {
+	markers := make([]string, 0, len(nodeTypes))
+	for _, marker := range nodeTypes {
+		markers = append(markers, marker)
+	}
+	sort.Strings(markers)
+	return markers
+}
+
+// GraphSchema describes the schema of the graph for the integrations which adapt to its changes
+type GraphSchema struct {
+	// Version is the version of the latest migration applied to Dgraph
+	Version int `json:"version"`
+	// LatestVersion is the version of the latest migration known by the controller
+	LatestVersion int                      `json:"latestVersion"`
+	Types         []NodeType               `json:"types"`
+	Predicates    []dgraph.PredicateSchema `json:"predicates"`
+}
+
+// NodeType is a type of node with the number of nodes of this type and the predicates found on a sample of them
+type NodeType struct {
+	Name       string   `json:"name"`
+	Marker     string   `json:"marker"`
+	Nodes      int      `json:"nodes"`
+	Predicates []string `json:"predicates"`
+}
+
+// RetrieveGraphSchema returns the description of the current schema of the graph
+func RetrieveGraphSchema() (*GraphSchema, error) {
+	predicates, err := dgraph.RetrievePredicates()
+	if err != nil {
+		return nil, err
+	}
+	types, err := retrieveNodeTypes()
+	if err != nil {
+		return nil, err
+	}
+	version, latestVersion := dgraph.SchemaVersions()
+	return &GraphSchema{
+		Version:       version,
+		LatestVersion: latestVersion,
+		Types:         types,
+		Predicates:    predicates,
+	}, nil
+}
+
+func retrieveNodeTypes() ([]NodeType, error) {
+	names := make([]string, 0, len(nodeTypes))
+	for name := range nodeTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	q := "{"
+	for i, name := range names {
+		q += fmt.Sprintf(`
+		count%d(func: has(%s)) {
+			count(uid)
+		}
+		sample%d(func: has(%s), first: %d) {
+			_predicate_
+		}`, i, nodeTypes[name], i, nodeTypes[name], schemaSampleSize)
+	}
+	q += "\n\t}"
+
+	type block []struct {
+		Count      int      `json:"count"`
+		Predicates []string `json:"_predicate_"`
+	}
+	root := map[string]block{}
+	if err := dgraph.ExecuteReplicaQuery(q, &root); err != nil {
+		return nil, err
+	}
+
+	types := make([]NodeType, 0, len(names))
+	for i, name := range names {
+		nodeType := NodeType{Name: name, Marker: nodeTypes[name]}
+		for _, result := range root[fmt.Sprintf("count%d", i)] {
+			nodeType.Nodes = result.Count
+		}
+		seen := map[string]bool{}
+		for _, node := range root[fmt.Sprintf("sample%d", i)] {
+			for _, predicate := range node.Predicates {
+				seen[predicate] = true
+			}
+		}
+		nodeType.Predicates = sortedKeys(seen)
+		types = append(types, nodeType)
+	}
+	return types, nil
+}
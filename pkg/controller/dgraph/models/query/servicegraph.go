@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// DependencyNode is a service or workload of the dependency graph
+type DependencyNode struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// DependencyEdge is the traffic from a service or workload to another one, Count is the number of interactions of
+// their pods since the first time they were seen interacting
+type DependencyEdge struct {
+	Source      string  `json:"source"`
+	Destination string  `json:"destination"`
+	Count       float64 `json:"count"`
+	FirstSeen   string  `json:"firstSeen"`
+	LastSeen    string  `json:"lastSeen"`
+}
+
+// ServiceDependencyGraph gives the services and workloads which interacted and the traffic between them
+type ServiceDependencyGraph struct {
+	Nodes []DependencyNode `json:"nodes"`
+	Edges []DependencyEdge `json:"edges"`
+}
+
+// RetrieveServiceDependencyGraph returns the graph of the interactions of the services and workloads of the namespace
+// (all namespaces if it is empty), with the services and workloads of other namespaces calling them or called by them
+func RetrieveServiceDependencyGraph(namespace string) (ServiceDependencyGraph, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	filter := `has(isWorkloadInteraction)`
+	if namespace != All {
+		filter = `has(isWorkloadInteraction) AND (` + builder.Eq("sourceNamespace", namespace) + ` OR ` +
+			builder.Eq("destinationNamespace", namespace) + `)`
+	}
+	query := `{
+		interactions(func: has(isWorkloadInteraction)) @filter(` + filter + `) {
+			sourceWorkload
+			sourceKind
+			destinationWorkload
+			destinationKind
+			interactionCount
+			firstSeen
+			lastSeen
+		}
+	}`
+
+	type root struct {
+		Interactions []models.WorkloadInteraction `json:"interactions"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return ServiceDependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}, err
+	}
+	return dependencyGraph(newRoot.Interactions), nil
+}
+
+// dependencyGraph returns the nodes of the interactions ordered by id and their edges, the busiest first
+func dependencyGraph(interactions []models.WorkloadInteraction) ServiceDependencyGraph {
+	graph := ServiceDependencyGraph{Nodes: []DependencyNode{}, Edges: []DependencyEdge{}}
+	nodes := map[string]DependencyNode{}
+	for _, interaction := range interactions {
+		source := dependencyNode(interaction.SourceKind, interaction.Source)
+		destination := dependencyNode(interaction.DestinationKind, interaction.Destination)
+		nodes[source.ID], nodes[destination.ID] = source, destination
+		graph.Edges = append(graph.Edges, DependencyEdge{
+			Source:      source.ID,
+			Destination: destination.ID,
+			Count:       interaction.Count,
+			FirstSeen:   interaction.FirstSeen,
+			LastSeen:    interaction.LastSeen,
+		})
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Count != graph.Edges[j].Count {
+			return graph.Edges[i].Count > graph.Edges[j].Count
+		}
+		if graph.Edges[i].Source != graph.Edges[j].Source {
+			return graph.Edges[i].Source < graph.Edges[j].Source
+		}
+		return graph.Edges[i].Destination < graph.Edges[j].Destination
+	})
+	return graph
+}
+
+// dependencyNode returns the node of the workload of the kind with xid namespace:name, its id is kind/namespace:name
+func dependencyNode(kind, xid string) DependencyNode {
+	node := DependencyNode{ID: kind + "/" + xid, Kind: kind, Name: xid}
+	if parts := strings.SplitN(xid, ":", 2); len(parts) == 2 {
+		node.Namespace, node.Name = parts[0], parts[1]
+	}
+	return node
+}
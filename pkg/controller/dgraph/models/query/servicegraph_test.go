@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	interactions := []models.WorkloadInteraction{
+		{Source: "shop:frontend", SourceKind: "service", Destination: "shop:cart", DestinationKind: "service", Count: 12},
+		{Source: "shop:frontend", SourceKind: "service", Destination: "payments:ledger", DestinationKind: "statefulset", Count: 40,
+			FirstSeen: "2019-01-01T00:00:00Z", LastSeen: "2019-01-02T00:00:00Z"},
+		{Source: "shop:cart", SourceKind: "service", Destination: "shop:redis", DestinationKind: "service", Count: 12},
+	}
+	graph := dependencyGraph(interactions)
+
+	utils.Equals(t, 4, len(graph.Nodes))
+	utils.Equals(t, DependencyNode{ID: "service/shop:cart", Kind: "service", Namespace: "shop", Name: "cart"}, graph.Nodes[0])
+	utils.Equals(t, "statefulset/payments:ledger", graph.Nodes[3].ID)
+	utils.Equals(t, "payments", graph.Nodes[3].Namespace)
+
+	// busiest edges first, ties ordered by source
+	utils.Equals(t, 3, len(graph.Edges))
+	utils.Equals(t, DependencyEdge{Source: "service/shop:frontend", Destination: "statefulset/payments:ledger", Count: 40,
+		FirstSeen: "2019-01-01T00:00:00Z", LastSeen: "2019-01-02T00:00:00Z"}, graph.Edges[0])
+	utils.Equals(t, "service/shop:cart", graph.Edges[1].Source)
+	utils.Equals(t, "service/shop:frontend", graph.Edges[2].Source)
+}
+
+func TestDependencyGraphEmpty(t *testing.T) {
+	graph := dependencyGraph(nil)
+	utils.Equals(t, 0, len(graph.Nodes))
+	utils.Assert(t, graph.Nodes != nil && graph.Edges != nil, "expected empty lists, not null")
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// window is the time window [from, to)
+type window struct {
+	from time.Time
+	to   time.Time
+}
+
+// RetrieveNamespaceCosts returns the costs of the namespace (every namespace if name is All) in the time window
+// [from, to) with the same line items as RetrieveNamespaceCostsInWindow. The whole days of the window which are
+// sealed are read from their daily cost summaries, only the partial days at the ends of the window and the days
+// not summarized yet are computed on demand.
+func RetrieveNamespaceCosts(name string, from, to time.Time) ([]ResourceCost, error) {
+	return retrieveSummarizedCosts(models.NamespaceOwner, name, from, to, func(from, to time.Time) ([]ResourceCost, error) {
+		return RetrieveNamespaceCostsInWindow(name, from, to)
+	})
+}
+
+// RetrieveGroupCost returns the cost of the pods of the group, having any of its labels, in the time window
+// [from, to) like RetrieveLabelsCostInWindow. The sealed whole days of the window are read from the daily cost
+// summaries of the group.
+func RetrieveGroupCost(group string, labels map[string]string, from, to time.Time) (ResourceCost, error) {
+	costs, err := retrieveSummarizedCosts(models.GroupOwner, group, from, to, func(from, to time.Time) ([]ResourceCost, error) {
+		cost, err := RetrieveLabelsCostInWindow(labels, from, to)
+		cost.Xid = group
+		return []ResourceCost{cost}, err
+	})
+	if err != nil || len(costs) == 0 {
+		return ResourceCost{Xid: group}, err
+	}
+	return costs[0], nil
+}
+
+// retrieveSummarizedCosts adds up the summaries of the sealed whole days of the window [from, to) and the costs
+// computed on demand for the rest of it. Namespace summaries computed before their claims, snapshots and load
+// balancers were recorded are not used, nor are the sealed days missing the summary of a single owner.
+func retrieveSummarizedCosts(ownerType, owner string, from, to time.Time,
+	computeOnDemand func(from, to time.Time) ([]ResourceCost, error)) ([]ResourceCost, error) {
+	firstDay, lastDay := trendPeriodStart(from, Daily), trendPeriodStart(to, Daily).AddDate(0, 0, 1)
+	seals, err := RetrieveDailySeals(firstDay, lastDay)
+	if err != nil {
+		return nil, err
+	}
+	sealed := map[int64]bool{}
+	for _, seal := range seals {
+		if day, err := time.Parse(time.RFC3339, seal.Date); err == nil {
+			sealed[day.Unix()] = true
+		}
+	}
+	var summaries []models.CostSummary
+	if len(sealed) > 0 {
+		if summaries, err = RetrieveCostSummaries(ownerType, owner, firstDay, lastDay); err != nil {
+			return nil, err
+		}
+	}
+	summariesByDay := map[int64][]models.CostSummary{}
+	for _, summary := range summaries {
+		day, err := time.Parse(time.RFC3339, summary.Date)
+		if err != nil {
+			continue
+		}
+		if summaryOwner(ownerType, summary) == "" || (ownerType == models.NamespaceOwner && !summary.HasLineItems) {
+			sealed[day.Unix()] = false
+		}
+		summariesByDay[day.Unix()] = append(summariesByDay[day.Unix()], summary)
+	}
+
+	summarizedDays, onDemand := splitWindow(from, to, func(day time.Time) bool {
+		// a group created after the day was sealed has no summary of the day but the cost of its labels
+		return sealed[day.Unix()] && (owner == All || len(summariesByDay[day.Unix()]) > 0)
+	})
+	costs := map[string]*ResourceCost{}
+	for _, day := range summarizedDays {
+		for _, summary := range summariesByDay[day.Unix()] {
+			addCost(costs, summaryCost(ownerType, summary))
+		}
+	}
+	for _, w := range onDemand {
+		computed, err := computeOnDemand(w.from, w.to)
+		if err != nil {
+			return nil, err
+		}
+		for _, cost := range computed {
+			addCost(costs, cost)
+		}
+	}
+
+	total := make([]ResourceCost, 0, len(costs))
+	for _, cost := range costs {
+		total = append(total, *cost)
+	}
+	sort.Slice(total, func(i, j int) bool {
+		return total[i].Xid < total[j].Xid
+	})
+	return total, nil
+}
+
+// splitWindow splits the time window [from, to) into the whole days which are summarized and the windows to compute
+// on demand: the partial days at the ends of the window and the days which are not summarized. Adjacent windows to
+// compute on demand are merged.
+func splitWindow(from, to time.Time, isSummarized func(day time.Time) bool) ([]time.Time, []window) {
+	var summarizedDays []time.Time
+	var onDemand []window
+	for start := from; start.Before(to); {
+		day := trendPeriodStart(start, Daily)
+		next := day.AddDate(0, 0, 1)
+		if start.Equal(day) && !next.After(to) && isSummarized(day) {
+			summarizedDays = append(summarizedDays, day)
+			start = next
+			continue
+		}
+		if next.After(to) {
+			next = to
+		}
+		if last := len(onDemand) - 1; last >= 0 && onDemand[last].to.Equal(start) {
+			onDemand[last].to = next
+		} else {
+			onDemand = append(onDemand, window{from: start, to: next})
+		}
+		start = next
+	}
+	return summarizedDays, onDemand
+}
+
+// summaryOwner returns the xid of the namespace or group of the summary
+func summaryOwner(ownerType string, summary models.CostSummary) string {
+	if ownerType == models.GroupOwner {
+		if summary.Group != nil {
+			return summary.Group.Xid
+		}
+	} else if summary.Namespace != nil {
+		return summary.Namespace.Xid
+	}
+	return ""
+}
+
+// summaryCost returns the cost of the namespace or group for the day of the summary with the line items of
+// RetrieveNamespaceCostsInWindow or RetrieveLabelsCostInWindow
+func summaryCost(ownerType string, summary models.CostSummary) ResourceCost {
+	cost := ResourceCost{Xid: summaryOwner(ownerType, summary), CPU: summary.CPUHours, Memory: summary.MemoryGBHours,
+		Storage: summary.StorageGBHours, CPUCost: summary.CPUCost, MemoryCost: summary.MemoryCost,
+		StorageCost: summary.StorageCost, GPU: summary.GPUHours, GPUCost: summary.GPUCost}
+	if ownerType == models.GroupOwner {
+		if summary.Group != nil {
+			cost.Name = summary.Group.Name
+		}
+		cost.LineItems = podLineItems(cost.CPUCost, cost.MemoryCost, cost.GPUCost, cost.Storage, cost.StorageCost)
+	} else {
+		if summary.Namespace != nil {
+			cost.Name = summary.Namespace.Name
+		}
+		cost.LineItems = NamespaceLineItems(cost, NamespaceResources{ClaimStorage: summary.ClaimGBHours,
+			ClaimCost: summary.ClaimCost, SnapshotStorage: summary.SnapshotGBHours, SnapshotCost: summary.SnapshotCost,
+			LoadBalancers: summary.LoadBalancerHours, LoadBalancerCost: summary.LoadBalancerCost})
+	}
+	cost.TotalCost = lineItemsTotal(cost.LineItems)
+	return cost
+}
+
+// addCost adds the usage, costs and line items of the cost to the cost of the same xid
+func addCost(costs map[string]*ResourceCost, cost ResourceCost) {
+	total, isPresent := costs[cost.Xid]
+	if !isPresent {
+		cost.LineItems = append([]LineItem(nil), cost.LineItems...)
+		costs[cost.Xid] = &cost
+		return
+	}
+	if total.Name == "" {
+		total.Name = cost.Name
+	}
+	total.CPU += cost.CPU
+	total.Memory += cost.Memory
+	total.Storage += cost.Storage
+	total.CPUCost += cost.CPUCost
+	total.MemoryCost += cost.MemoryCost
+	total.StorageCost += cost.StorageCost
+	total.GPU += cost.GPU
+	total.GPUCost += cost.GPUCost
+	for _, item := range cost.LineItems {
+		isAdded := false
+		for i := range total.LineItems {
+			if total.LineItems[i].Category == item.Category {
+				total.LineItems[i].Quantity += item.Quantity
+				total.LineItems[i].Cost += item.Cost
+				isAdded = true
+				break
+			}
+		}
+		if !isAdded {
+			total.LineItems = append(total.LineItems, item)
+		}
+	}
+	total.TotalCost = lineItemsTotal(total.LineItems)
+}
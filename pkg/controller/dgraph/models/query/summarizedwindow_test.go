@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestSplitWindow(t *testing.T) {
+	day := func(d, h int) time.Time {
+		return time.Date(2018, 11, d, h, 0, 0, 0, time.Local)
+	}
+	sealed := map[int]bool{2: true, 3: true, 5: true}
+	isSummarized := func(d time.Time) bool {
+		return sealed[d.Day()]
+	}
+	tests := []struct {
+		name       string
+		from, to   time.Time
+		summarized []time.Time
+		onDemand   []window
+	}{
+		{"whole sealed days", day(2, 0), day(4, 0), []time.Time{day(2, 0), day(3, 0)}, nil},
+		{"partial days at the ends", day(1, 12), day(4, 6), []time.Time{day(2, 0), day(3, 0)},
+			[]window{{day(1, 12), day(2, 0)}, {day(4, 0), day(4, 6)}}},
+		{"days not sealed", day(3, 0), day(7, 0), []time.Time{day(3, 0), day(5, 0)},
+			[]window{{day(4, 0), day(5, 0)}, {day(6, 0), day(7, 0)}}},
+		{"partial sealed day", day(2, 6), day(2, 18), nil, []window{{day(2, 6), day(2, 18)}}},
+		{"adjacent days not sealed are merged", day(6, 0), day(8, 12), nil, []window{{day(6, 0), day(8, 12)}}},
+		{"empty window", day(2, 0), day(2, 0), nil, nil},
+	}
+	for _, test := range tests {
+		summarized, onDemand := splitWindow(test.from, test.to, isSummarized)
+		utils.Assert(t, len(summarized) == len(test.summarized), "%s: summarized days %v", test.name, summarized)
+		for i := range summarized {
+			utils.Assert(t, summarized[i].Equal(test.summarized[i]), "%s: summarized days %v", test.name, summarized)
+		}
+		utils.Assert(t, len(onDemand) == len(test.onDemand), "%s: on demand windows %v", test.name, onDemand)
+		for i := range onDemand {
+			utils.Assert(t, onDemand[i].from.Equal(test.onDemand[i].from) && onDemand[i].to.Equal(test.onDemand[i].to),
+				"%s: on demand windows %v", test.name, onDemand)
+		}
+	}
+}
+
+func TestSummaryCostAddedToOnDemandCost(t *testing.T) {
+	summary := models.CostSummary{Namespace: &models.Namespace{ID: dgraph.ID{Xid: "shop"}, Name: "shop"},
+		CPUHours: 24, CPUCost: 0.6, MemoryGBHours: 48, MemoryCost: 0.24, StorageGBHours: 240, StorageCost: 0.1,
+		ClaimGBHours: 480, ClaimCost: 0.2, SnapshotGBHours: 240, SnapshotCost: 0.05, LoadBalancerHours: 24,
+		LoadBalancerCost: 0.6, HasLineItems: true}
+	cost := summaryCost(models.NamespaceOwner, summary)
+	utils.Equals(t, "shop", cost.Xid)
+	// the storage of the pods is charged through the claims of the namespace
+	utils.Assert(t, cost.TotalCost > 1.689 && cost.TotalCost < 1.691, "total cost of the day: %f", cost.TotalCost)
+
+	partialDay := ResourceCost{Xid: "shop", CPU: 12, CPUCost: 0.3}
+	partialDay.LineItems = NamespaceLineItems(partialDay, NamespaceResources{ClaimStorage: 240, ClaimCost: 0.1})
+	costs := map[string]*ResourceCost{}
+	addCost(costs, cost)
+	addCost(costs, partialDay)
+	addCost(costs, ResourceCost{Xid: "ci", CPU: 1})
+
+	total := costs["shop"]
+	utils.Equals(t, 2, len(costs))
+	utils.Equals(t, "shop", total.Name)
+	utils.Equals(t, 36.0, float64(total.CPU))
+	utils.Equals(t, 4, len(total.LineItems))
+	utils.Equals(t, PersistentVolumeLineItem, total.LineItems[1].Category)
+	utils.Equals(t, 720.0, total.LineItems[1].Quantity)
+	utils.Assert(t, total.TotalCost > 2.089 && total.TotalCost < 2.091, "total cost of the window: %f", total.TotalCost)
+	// the line items of the summary are not modified
+	utils.Equals(t, 480.0, cost.LineItems[1].Quantity)
+
+	group := summaryCost(models.GroupOwner, models.CostSummary{Group: &models.GroupCRD{ID: dgraph.ID{Xid: "team-a"}},
+		CPUCost: 0.6, StorageGBHours: 240, StorageCost: 0.1})
+	utils.Equals(t, "team-a", group.Xid)
+	utils.Equals(t, 240.0, group.LineItems[1].Quantity)
+	utils.Assert(t, group.TotalCost > 0.699 && group.TotalCost < 0.701, "total cost of the group: %f", group.TotalCost)
+}
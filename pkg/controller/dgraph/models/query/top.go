@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// RetrieveTopNamespaces returns the `limit` namespaces with the highest cost in the time window [from, to).
+// Ordering and pagination are done by dgraph on the total cost of the pods, claims, snapshots and load balancers of
+// the namespaces so only the top namespaces are fetched.
+func RetrieveTopNamespaces(limit int, from, to time.Time) ([]ResourceCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	type namespace struct {
+		ResourceCost
+		NamespaceResources
+	}
+	type root struct {
+		Top []namespace `json:"top"`
+	}
+	newRoot := root{}
+	err := builder.Execute(topNamespacesQuery(builder, limit, from, to), &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	top := []ResourceCost{}
+	for _, ns := range newRoot.Top {
+		cost := ns.ResourceCost
+		cost.LineItems = NamespaceLineItems(cost, ns.NamespaceResources)
+		cost.TotalCost = lineItemsTotal(cost.LineItems)
+		top = append(top, cost)
+	}
+	return top, nil
+}
+
+// topNamespacesQuery returns the query of the `limit` namespaces with the highest cost in the time window [from, to).
+// The costs of the claims, snapshots and load balancers are in the total cost ordering the namespaces, a namespace
+// whose cost is mostly storage or load balancers is in the top even if its pods cost little.
+func topNamespacesQuery(builder *dgraph.QueryBuilder, limit int, from, to time.Time) string {
+	return `{
+		ns as var(func: has(isNamespace)) {
+			~namespace @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+			namespaceCpu as sum(val(podCpuHours))
+			namespaceMem as sum(val(podMemHours))
+			namespaceStorage as sum(val(podStorageHours))
+			namespaceCpuCost as sum(val(podCpuCost))
+			namespaceMemCost as sum(val(podMemCost))
+			namespaceStorageCost as sum(val(podStorageCost))
+			namespaceGpu as sum(val(podGpuHours))
+			namespaceGpuCost as sum(val(podGpuCost))
+		}
+		` + namespaceResourcesInWindow(builder, "ns", from, to) + `
+		var(func: uid(ns)) {
+			namespaceTotalCost as math(namespaceCpuCost + namespaceMemCost + namespaceGpuCost + claimCost + snapshotCost + loadBalancerCost)
+		}
+		topNs as var(func: uid(ns), orderdesc: val(namespaceTotalCost), first: ` + builder.Int(limit) + `) {
+			uid
+		}
+
+		top(func: uid(topNs), orderdesc: val(namespaceTotalCost)) {
+			xid
+			name
+			cpu: val(namespaceCpu)
+			memory: val(namespaceMem)
+			storage: val(namespaceStorage)
+			cpuCost: val(namespaceCpuCost)
+			memoryCost: val(namespaceMemCost)
+			storageCost: val(namespaceStorageCost)
+			gpu: val(namespaceGpu)
+			gpuCost: val(namespaceGpuCost)
+			` + namespaceResourcesFields() + `
+		}
+	}`
+}
+
+// RetrieveTopPods returns the `limit` pods with the highest cost in the time window [from, to).
+// Ordering and pagination are done by dgraph so only the top pods are fetched.
+func RetrieveTopPods(limit int, from, to time.Time) ([]ResourceCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	type root struct {
+		Top []ResourceCost `json:"top"`
+	}
+	newRoot := root{}
+	err := builder.Execute(topPodsQuery(builder, limit, from, to), &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	for i, pod := range newRoot.Top {
+		newRoot.Top[i].LineItems = podLineItems(pod.CPUCost, pod.MemoryCost, pod.GPUCost, pod.Storage, pod.StorageCost)
+	}
+	return newRoot.Top, nil
+}
+
+// topPodsQuery returns the query of the `limit` pods with the highest cost in the time window [from, to)
+func topPodsQuery(builder *dgraph.QueryBuilder, limit int, from, to time.Time) string {
+	return `{
+		pods as var(func: le(startTime, ` + builder.Time(to) + `)) @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+			` + podCostInWindow(from, to) + `
+			podTotalCost as math(podCpuCost + podMemCost + podStorageCost + podGpuCost)
+		}
+
+		top(func: uid(pods), orderdesc: val(podTotalCost), first: ` + builder.Int(limit) + `) {
+			xid
+			name
+			cpu: val(podCpuHours)
+			memory: val(podMemHours)
+			storage: val(podStorageHours)
+			cpuCost: val(podCpuCost)
+			memoryCost: val(podMemCost)
+			storageCost: val(podStorageCost)
+			gpu: val(podGpuHours)
+			gpuCost: val(podGpuCost)
+			totalCost: val(podTotalCost)
+		}
+	}`
+}
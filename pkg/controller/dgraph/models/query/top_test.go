@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestTopNamespacesQuery(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	query := topNamespacesQuery(dgraph.NewQueryBuilder(), 5, from, from.AddDate(0, 1, 0))
+
+	// a namespace whose cost is its claims, snapshots or load balancers must be ordered by that cost
+	total := regexp.MustCompile(`namespaceTotalCost as math\(([^)]*)\)`).FindStringSubmatch(query)
+	utils.Assert(t, total != nil, "total cost of the namespaces is computed")
+	for _, cost := range []string{"namespaceCpuCost", "namespaceMemCost", "namespaceGpuCost", "claimCost", "snapshotCost", "loadBalancerCost"} {
+		utils.Assert(t, strings.Contains(total[1], cost), "total cost includes "+cost)
+	}
+	utils.Assert(t, strings.Index(query, "claimCost as sum") < strings.Index(query, "orderdesc: val(namespaceTotalCost), first:"),
+		"claim costs are computed before the namespaces are ordered")
+}
+
+func TestTopPodsQuery(t *testing.T) {
+	from := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	builder := dgraph.NewQueryBuilder()
+	query, variables := builder.Build(topPodsQuery(builder, 5, from, from.AddDate(0, 1, 0)))
+
+	// the pods are ordered and limited by dgraph, the limit is a variable of the query
+	page := regexp.MustCompile(`top\(func: uid\(pods\), orderdesc: val\(podTotalCost\), first: (\$v\d+)\)`).FindStringSubmatch(query)
+	utils.Assert(t, page != nil, "top pods are ordered by total cost and limited: %s", query)
+	utils.Equals(t, "5", variables[page[1]])
+	total := regexp.MustCompile(`podTotalCost as math\(([^)]*)\)`).FindStringSubmatch(query)
+	utils.Assert(t, total != nil, "total cost of the pods is computed")
+	for _, cost := range []string{"podCpuCost", "podMemCost", "podStorageCost", "podGpuCost"} {
+		utils.Assert(t, strings.Contains(total[1], cost), "total cost includes "+cost)
+	}
+	utils.Assert(t, strings.Contains(query, "le(startTime, $v0)"), "pods started after the window are not fetched")
+	utils.Equals(t, from.AddDate(0, 1, 0).Format(time.RFC3339), variables["$v0"])
+}
@@ -17,24 +17,104 @@
 
 package query
 
+import "github.com/vmware/purser/pkg/controller/utils"
+
 // Constants used in query parameters
 const (
-	All      = ""
-	Name     = "name"
-	Orphan   = "orphan"
-	View     = "view"
-	Physical = "physical"
-	Logical  = "logical"
-	False    = "false"
+	All         = ""
+	Name        = "name"
+	Orphan      = "orphan"
+	View        = "view"
+	Physical    = "physical"
+	Logical     = "logical"
+	Environment = "environment"
+	Application = "application"
+	False       = "false"
+
+	Namespace = "namespace"
+	Group     = "group"
+
+	From       = "from"
+	To         = "to"
+	ID         = "id"
+	DateFormat = "2006-01-02"
+	Time       = "time"
+
+	Month       = "month"
+	MonthFormat = "2006-01"
+	Format      = "format"
+	HTML        = "html"
+	YAML        = "yaml"
+	Push        = "push"
+
+	Reason    = "reason"
+	Amendment = "amendment"
+
+	GroupBy  = "groupBy"
+	Instance = "instance"
+	PartOf   = "partOf"
+	Label    = "label"
+
+	Type         = "type"
+	Limit        = "limit"
+	DefaultLimit = 10
+	MaxLimit     = 1000
+	After        = "after"
+
+	Overhead   = "overhead"
+	Distribute = "distribute"
+
+	Component   = "component"
+	Version     = "version"
+	Days        = "days"
+	DefaultDays = 7
+	MaxDays     = 90
+
+	Xid   = "xid"
+	Since = "since"
+
+	Period   = "period"
+	Workload = "workload"
+	Value    = "value"
 )
 
 // Cost constants
 const (
-	defaultCPUCostPerCPUPerHour    = "0.024"
-	defaultMemCostPerGBPerHour     = "0.01"
-	defaultStorageCostPerGBPerHour = "0.00013888888"
+	hoursInMonth = "730"
+	// HoursInMonth is the number of hours of the month over which monthly costs are prorated
+	HoursInMonth = 730.0
 )
 
+// ResourceCost structure gives the resource usage (in unit hours) and cost of a resource in a time window
+type ResourceCost struct {
+	Xid         string          `json:"xid,omitempty"`
+	Name        string          `json:"name,omitempty"`
+	CPU         utils.CoreHours `json:"cpu,omitempty"`
+	Memory      utils.GBHours   `json:"memory,omitempty"`
+	Storage     utils.GBHours   `json:"storage,omitempty"`
+	CPUCost     float64         `json:"cpuCost,omitempty"`
+	MemoryCost  float64         `json:"memoryCost,omitempty"`
+	StorageCost float64         `json:"storageCost,omitempty"`
+	GPU         float64         `json:"gpu,omitempty"`
+	GPUCost     float64         `json:"gpuCost,omitempty"`
+	TotalCost   float64         `json:"totalCost,omitempty"`
+
+	LineItems []LineItem   `json:"lineItems,omitempty"`
+	Quality   *DataQuality `json:"quality,omitempty"`
+
+	// set when the cluster overhead is distributed: ClusterOverhead for the overhead namespaces, OverheadCost is the
+	// part of the cost of the namespace spent on node agents and SharedCost its share of the cluster overhead
+	ClusterOverhead bool    `json:"clusterOverhead,omitempty"`
+	OverheadCost    float64 `json:"overheadCost,omitempty"`
+	SharedCost      float64 `json:"sharedCost,omitempty"`
+
+	// set in chargeback outputs when the namespace has a markup, the costs above are the raw costs
+	MarkupPercent float64 `json:"markupPercent,omitempty"`
+	MarkedUpCost  float64 `json:"markedUpCost,omitempty"`
+
+	Units *Units `json:"units,omitempty"`
+}
+
 // Children structure
 type Children struct {
 	Name        string  `json:"name,omitempty"`
@@ -45,6 +125,8 @@ type Children struct {
 	CPUCost     float64 `json:"cpuCost,omitempty"`
 	MemoryCost  float64 `json:"memoryCost,omitempty"`
 	StorageCost float64 `json:"storageCost,omitempty"`
+	GPU         float64 `json:"gpu,omitempty"`
+	GPUCost     float64 `json:"gpuCost,omitempty"`
 }
 
 // Parent structure
@@ -58,6 +140,14 @@ type Parent struct {
 	CPUCost     float64    `json:"cpuCost,omitempty"`
 	MemoryCost  float64    `json:"memoryCost,omitempty"`
 	StorageCost float64    `json:"storageCost,omitempty"`
+	GPU         float64    `json:"gpu,omitempty"`
+	GPUCost     float64    `json:"gpuCost,omitempty"`
+
+	// pod overhead, included in cpu/memory and their cost
+	CPUOverhead        float64 `json:"cpuOverhead,omitempty"`
+	MemoryOverhead     float64 `json:"memoryOverhead,omitempty"`
+	CPUOverheadCost    float64 `json:"cpuOverheadCost,omitempty"`
+	MemoryOverheadCost float64 `json:"memoryOverheadCost,omitempty"`
 }
 
 // ParentWrapper structure
@@ -72,6 +162,14 @@ type ParentWrapper struct {
 	CPUCost     float64    `json:"cpuCost,omitempty"`
 	MemoryCost  float64    `json:"memoryCost,omitempty"`
 	StorageCost float64    `json:"storageCost,omitempty"`
+	GPU         float64    `json:"gpu,omitempty"`
+	GPUCost     float64    `json:"gpuCost,omitempty"`
+
+	// pod overhead, included in cpu/memory and their cost
+	CPUOverhead        float64 `json:"cpuOverhead,omitempty"`
+	MemoryOverhead     float64 `json:"memoryOverhead,omitempty"`
+	CPUOverheadCost    float64 `json:"cpuOverheadCost,omitempty"`
+	MemoryOverheadCost float64 `json:"memoryOverheadCost,omitempty"`
 }
 
 // JSONDataWrapper structure
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import "github.com/vmware/purser/pkg/controller/utils"
+
+// Units of the usage of a ResourceCost
+type Units struct {
+	CPU     string `json:"cpu"`
+	Memory  string `json:"memory"`
+	Storage string `json:"storage"`
+	GPU     string `json:"gpu"`
+}
+
+// ResourceUnits are the units of the usage of every ResourceCost, costs are in the currency of the prices
+var ResourceUnits = Units{
+	CPU:     "cpu hours",
+	Memory:  "GB hours",
+	Storage: "GB hours",
+	GPU:     "gpu hours",
+}
+
+// RoundCosts rounds the usage and costs of the given costs (and their line items) to the api precision and sets
+// their units. It must be called only on the response, totals are computed with the exact values.
+func RoundCosts(costs []ResourceCost) {
+	for i := range costs {
+		roundCost(&costs[i])
+	}
+}
+
+func roundCost(cost *ResourceCost) {
+	cost.CPU = cost.CPU.Round()
+	cost.Memory = cost.Memory.Round()
+	cost.Storage = cost.Storage.Round()
+	cost.GPU = utils.Round(cost.GPU, utils.QuantityPrecision)
+	cost.CPUCost = utils.Round(cost.CPUCost, utils.CostPrecision)
+	cost.MemoryCost = utils.Round(cost.MemoryCost, utils.CostPrecision)
+	cost.StorageCost = utils.Round(cost.StorageCost, utils.CostPrecision)
+	cost.GPUCost = utils.Round(cost.GPUCost, utils.CostPrecision)
+	cost.TotalCost = utils.Round(cost.TotalCost, utils.CostPrecision)
+	cost.OverheadCost = utils.Round(cost.OverheadCost, utils.CostPrecision)
+	cost.SharedCost = utils.Round(cost.SharedCost, utils.CostPrecision)
+	cost.MarkedUpCost = utils.Round(cost.MarkedUpCost, utils.CostPrecision)
+	for i := range cost.LineItems {
+		cost.LineItems[i].Quantity = utils.Round(cost.LineItems[i].Quantity, utils.QuantityPrecision)
+		cost.LineItems[i].Cost = utils.Round(cost.LineItems[i].Cost, utils.CostPrecision)
+	}
+	units := ResourceUnits
+	cost.Units = &units
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestRoundCosts(t *testing.T) {
+	costs := []ResourceCost{{
+		Name:      "default",
+		CPU:       0.1 + 0.2,
+		CPUCost:   1.0000004,
+		TotalCost: 1.0000006,
+		LineItems: []LineItem{{Category: "compute", Quantity: 2.00000049, Cost: 0.0000001}},
+	}}
+	RoundCosts(costs)
+	utils.Equals(t, 0.3, float64(costs[0].CPU))
+	utils.Equals(t, 1.0, costs[0].CPUCost)
+	utils.Equals(t, 1.000001, costs[0].TotalCost)
+	utils.Equals(t, LineItem{Category: "compute", Quantity: 2}, costs[0].LineItems[0])
+	utils.Equals(t, &ResourceUnits, costs[0].Units)
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Components of the nodes whose upgrades are reported
+const (
+	KubeletComponent = "kubelet"
+	OSComponent      = "os"
+)
+
+// UpgradeRollout is the time during which the nodes of the cluster moved to a kubelet minor version (ex: v1.28) or
+// an OS image. It starts when the first node ran the version and ends when all nodes run it. Versions which were
+// running when the history started have a rollout starting at the creation of the oldest node.
+type UpgradeRollout struct {
+	Component string `json:"component"`
+	Version   string `json:"version"`
+	Start     string `json:"start"`
+	End       string `json:"end,omitempty"`
+	Complete  bool   `json:"complete"`
+	Nodes     int    `json:"nodes"`
+}
+
+// UpgradePeriod gives the cost and the efficiency of the cluster in a period before or after an upgrade.
+// Efficiencies are the share of the node capacity requested by the pods.
+type UpgradePeriod struct {
+	From             string  `json:"from"`
+	To               string  `json:"to"`
+	Days             float64 `json:"days"`
+	Cost             float64 `json:"cost"`
+	DailyCost        float64 `json:"dailyCost"`
+	AverageNodes     float64 `json:"averageNodes"`
+	CPUEfficiency    float64 `json:"cpuEfficiency"`
+	MemoryEfficiency float64 `json:"memoryEfficiency"`
+}
+
+// UpgradeImpact compares the cost and efficiency of the cluster during the days before the rollout of a version
+// with the days after it (or since its start while it is not complete)
+type UpgradeImpact struct {
+	UpgradeRollout
+	Before                 UpgradePeriod `json:"before"`
+	After                  UpgradePeriod `json:"after"`
+	DailyCostChange        float64       `json:"dailyCostChange"`
+	DailyCostChangePercent float64       `json:"dailyCostChangePercent"`
+	CPUEfficiencyChange    float64       `json:"cpuEfficiencyChange"`
+	MemoryEfficiencyChange float64       `json:"memoryEfficiencyChange"`
+}
+
+// versionInterval is the time during which a node ran a version, open intervals end now
+type versionInterval struct {
+	node       string
+	version    string
+	start, end time.Time
+}
+
+// RetrieveUpgradeRollouts returns the rollouts of every kubelet minor version and OS image seen on the nodes,
+// oldest first
+func RetrieveUpgradeRollouts(now time.Time) ([]UpgradeRollout, error) {
+	versions, err := retrieveNodeVersions()
+	if err != nil {
+		return nil, err
+	}
+	rollouts := []UpgradeRollout{}
+	for _, component := range []string{KubeletComponent, OSComponent} {
+		intervals := versionIntervals(versions, component, now)
+		seen := map[string]bool{}
+		for _, interval := range intervals {
+			if seen[interval.version] {
+				continue
+			}
+			seen[interval.version] = true
+			rollouts = append(rollouts, upgradeRollout(component, interval.version, intervals, now))
+		}
+	}
+	sort.SliceStable(rollouts, func(i, j int) bool {
+		return rollouts[i].Start < rollouts[j].Start
+	})
+	return rollouts, nil
+}
+
+// RetrieveUpgradeImpact returns the cost and efficiency changes of the cluster after the rollout of the version of
+// the component, comparing the given number of days before and after the rollout
+func RetrieveUpgradeImpact(component, version string, days int, now time.Time) (UpgradeImpact, error) {
+	impact := UpgradeImpact{}
+	versions, err := retrieveNodeVersions()
+	if err != nil {
+		return impact, err
+	}
+	if component == KubeletComponent {
+		version = kubeletMinorVersion(version)
+	}
+	impact.UpgradeRollout = upgradeRollout(component, version, versionIntervals(versions, component, now), now)
+	if impact.Nodes == 0 {
+		return impact, fmt.Errorf("no node ran %s version: %s", component, version)
+	}
+
+	start, _ := time.Parse(time.RFC3339, impact.Start)
+	afterStart := start
+	if impact.Complete {
+		afterStart, _ = time.Parse(time.RFC3339, impact.End)
+	}
+	afterEnd := afterStart.AddDate(0, 0, days)
+	if afterEnd.After(now) {
+		afterEnd = now
+	}
+	if impact.Before, err = retrieveUpgradePeriod(start.AddDate(0, 0, -days), start); err != nil {
+		return impact, err
+	}
+	if impact.After, err = retrieveUpgradePeriod(afterStart, afterEnd); err != nil {
+		return impact, err
+	}
+	impact.DailyCostChange = impact.After.DailyCost - impact.Before.DailyCost
+	if impact.Before.DailyCost > 0 {
+		impact.DailyCostChangePercent = 100 * impact.DailyCostChange / impact.Before.DailyCost
+	}
+	impact.CPUEfficiencyChange = impact.After.CPUEfficiency - impact.Before.CPUEfficiency
+	impact.MemoryEfficiencyChange = impact.After.MemoryEfficiency - impact.Before.MemoryEfficiency
+	return impact, nil
+}
+
+func retrieveNodeVersions() ([]models.NodeVersion, error) {
+	query := `{
+		versions(func: has(isNodeVersion), orderasc: startTime) {
+			kubeletVersion
+			osImage
+			startTime
+			endTime
+			node {
+				xid
+			}
+		}
+	}`
+
+	type root struct {
+		Versions []models.NodeVersion `json:"versions"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteReplicaQuery(query, &newRoot); err != nil {
+		return nil, err
+	}
+	return newRoot.Versions, nil
+}
+
+// versionIntervals returns the intervals of the versions of the component, kubelet versions are reduced to their
+// minor version
+func versionIntervals(versions []models.NodeVersion, component string, now time.Time) []versionInterval {
+	intervals := []versionInterval{}
+	for _, v := range versions {
+		start, err := time.Parse(time.RFC3339, v.StartTime)
+		if err != nil {
+			continue
+		}
+		end := now
+		if endTime, err := time.Parse(time.RFC3339, v.EndTime); err == nil {
+			end = endTime
+		}
+		interval := versionInterval{version: v.OSImage, start: start, end: end}
+		if component == KubeletComponent {
+			interval.version = kubeletMinorVersion(v.KubeletVersion)
+		}
+		if v.Node != nil {
+			interval.node = v.Node.Xid
+		}
+		intervals = append(intervals, interval)
+	}
+	return intervals
+}
+
+// kubeletMinorVersion returns the minor version (ex: v1.28) of a kubelet version (ex: v1.28.3-gke.100 or 1.28)
+func kubeletMinorVersion(version string) string {
+	version = "v" + strings.TrimPrefix(version, "v")
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + strings.SplitN(parts[1], "-", 2)[0]
+}
+
+// upgradeRollout finds when the version was first run and the first time after which all the nodes alive ran it
+func upgradeRollout(component, version string, intervals []versionInterval, now time.Time) UpgradeRollout {
+	rollout := UpgradeRollout{Component: component, Version: version}
+	var start time.Time
+	nodes := map[string]bool{}
+	for _, interval := range intervals {
+		if interval.version != version {
+			continue
+		}
+		nodes[interval.node] = true
+		if start.IsZero() || interval.start.Before(start) {
+			start = interval.start
+		}
+	}
+	rollout.Nodes = len(nodes)
+	if rollout.Nodes == 0 {
+		return rollout
+	}
+	rollout.Start = start.Format(time.RFC3339)
+
+	candidates := []time.Time{start}
+	for _, interval := range intervals {
+		for _, t := range []time.Time{interval.start, interval.end} {
+			if t.After(start) && t.Before(now) {
+				candidates = append(candidates, t)
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+	for _, t := range candidates {
+		alive, upgraded := 0, 0
+		for _, interval := range intervals {
+			if interval.start.After(t) || !interval.end.After(t) {
+				continue
+			}
+			alive++
+			if interval.version == version {
+				upgraded++
+			}
+		}
+		if alive > 0 && alive == upgraded {
+			rollout.End, rollout.Complete = t.Format(time.RFC3339), true
+			break
+		}
+	}
+	return rollout
+}
+
+// retrieveUpgradePeriod returns the cost of the pods and the efficiency of the nodes in the time window [from, to)
+func retrieveUpgradePeriod(from, to time.Time) (UpgradePeriod, error) {
+	period := UpgradePeriod{From: from.Format(time.RFC3339), To: to.Format(time.RFC3339), Days: to.Sub(from).Hours() / 24}
+	if period.Days <= 0 {
+		return period, nil
+	}
+	costs, err := RetrieveNamespaceCosts(All, from, to)
+	if err != nil {
+		return period, err
+	}
+	var cpuHours utils.CoreHours
+	var memoryHours utils.GBHours
+	for _, cost := range costs {
+		period.Cost += cost.TotalCost
+		cpuHours += cost.CPU
+		memoryHours += cost.Memory
+	}
+	period.DailyCost = period.Cost / period.Days
+
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		nodes(func: has(isNode)) @filter(` + podsInWindowFilter(builder, from, to) + `) {
+			startTime
+			endTime
+			cpuCapacity
+			memoryCapacity
+		}
+	}`
+	type root struct {
+		Nodes []models.Node `json:"nodes"`
+	}
+	newRoot := root{}
+	if err = builder.Execute(query, &newRoot); err != nil {
+		return period, err
+	}
+	nodeHours := 0.0
+	var cpuCapacityHours utils.CoreHours
+	var memoryCapacityHours utils.GBHours
+	for _, node := range newRoot.Nodes {
+		start, err := time.Parse(time.RFC3339, node.StartTime)
+		if err != nil {
+			continue
+		}
+		end := to
+		if endTime, err := time.Parse(time.RFC3339, node.EndTime); err == nil && endTime.Before(to) {
+			end = endTime
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if hours := end.Sub(start).Hours(); hours > 0 {
+			nodeHours += hours
+			cpuCapacityHours += node.CPUCapity.Over(hours)
+			memoryCapacityHours += node.MemoryCapacity.Over(hours)
+		}
+	}
+	period.AverageNodes = nodeHours / to.Sub(from).Hours()
+	if cpuCapacityHours > 0 {
+		period.CPUEfficiency = float64(cpuHours / cpuCapacityHours)
+	}
+	if memoryCapacityHours > 0 {
+		period.MemoryEfficiency = float64(memoryHours / memoryCapacityHours)
+	}
+	return period, nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestKubeletMinorVersion(t *testing.T) {
+	utils.Equals(t, "v1.28", kubeletMinorVersion("v1.28.3-gke.100"))
+	utils.Equals(t, "v1.28", kubeletMinorVersion("1.28"))
+	utils.Equals(t, "v1.9", kubeletMinorVersion("v1.9-beta.0"))
+}
+
+func TestUpgradeRollout(t *testing.T) {
+	day := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC)
+	now := day.AddDate(0, 0, 20)
+	intervals := []versionInterval{
+		// n1 is upgraded in place, n2 is replaced by n3
+		{node: "n1", version: "v1.27", start: day, end: day.AddDate(0, 0, 10)},
+		{node: "n1", version: "v1.28", start: day.AddDate(0, 0, 10), end: now},
+		{node: "n2", version: "v1.27", start: day, end: day.AddDate(0, 0, 12)},
+		{node: "n3", version: "v1.28", start: day.AddDate(0, 0, 11), end: now},
+	}
+
+	rollout := upgradeRollout(KubeletComponent, "v1.28", intervals, now)
+	utils.Equals(t, UpgradeRollout{Component: KubeletComponent, Version: "v1.28", Start: "2018-11-11T00:00:00Z",
+		End: "2018-11-13T00:00:00Z", Complete: true, Nodes: 2}, rollout)
+
+	intervals[2].end = now
+	rollout = upgradeRollout(KubeletComponent, "v1.28", intervals, now)
+	utils.Assert(t, !rollout.Complete && rollout.End == "", "rollout is not complete while n2 runs v1.27")
+
+	rollout = upgradeRollout(KubeletComponent, "v1.29", intervals, now)
+	utils.Equals(t, 0, rollout.Nodes)
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// Suggestions for the usage patterns of workloads
+const (
+	ShutdownAtNight    = "shutdown-at-night"
+	ShutdownOnWeekends = "shutdown-on-weekends"
+	ScheduleOffPeak    = "schedule-off-peak"
+)
+
+const (
+	hoursPerWeek = 7 * 24
+	// minDailyIdleHours is the number of hours a workload must be idle every day to suggest shutting it down at night
+	minDailyIdleHours = 4
+	// offPeakRatio is the ratio of the peak to the average usage above which a workload is suggested to run off-peak
+	offPeakRatio = 3.0
+)
+
+// UsagePattern of a deployment: its average cpu usage (in cores) by day of week (Sunday first) and hour of day, the
+// hours and days it is idle and the suggested schedules with the savings they would give in 30 days.
+// Suggestions are only made once every hour of the week has been sampled.
+type UsagePattern struct {
+	Xid              string         `json:"xid"`
+	Namespace        string         `json:"namespace"`
+	Name             string         `json:"name"`
+	Heatmap          [7][24]float64 `json:"heatmap"`
+	Complete         bool           `json:"complete"`
+	PeakDay          string         `json:"peakDay"`
+	PeakHour         int            `json:"peakHour"`
+	PeakToAverage    float64        `json:"peakToAverage"`
+	IdleHours        []int          `json:"idleHours,omitempty"`
+	IdleDays         []string       `json:"idleDays,omitempty"`
+	Suggestions      []string       `json:"suggestions,omitempty"`
+	WeeklyCost       float64        `json:"weeklyCost"`
+	EstimatedSavings float64        `json:"estimatedSavings"`
+}
+
+// RetrieveUsagePatterns returns the usage patterns of the running deployments whose cpu usage is sampled, ordered by
+// estimated savings and weekly cost. A deployment is idle in an hour when its pods used less than threshold cores.
+func RetrieveUsagePatterns(threshold float64, now time.Time) ([]UsagePattern, error) {
+	since := now.AddDate(0, 0, -7)
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		deps as var(func: has(isDeployment)) @filter(NOT has(endTime) AND has(usageHeatmap)) {
+			~deployment @filter(has(isReplicaset)) {
+				` + workloadPodsCost(builder, "~replicaset", since, now) + `
+				replicasetCost as sum(val(podCost))
+			}
+			workloadCost as sum(val(replicasetCost))
+		}
+
+		deployments(func: uid(deps)) {
+			xid
+			usageHeatmap
+			weeklyCost: val(workloadCost)
+		}
+	}`
+
+	type root struct {
+		Deployments []struct {
+			Xid          string  `json:"xid"`
+			UsageHeatmap string  `json:"usageHeatmap"`
+			WeeklyCost   float64 `json:"weeklyCost"`
+		} `json:"deployments"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+
+	patterns := []UsagePattern{}
+	for _, d := range newRoot.Deployments {
+		heatmap, err := models.DecodeUsageHeatmap(d.UsageHeatmap)
+		if err != nil {
+			return nil, err
+		}
+		pattern := UsagePattern{Xid: d.Xid, WeeklyCost: d.WeeklyCost}
+		parts := strings.SplitN(d.Xid, ":", 2)
+		if len(parts) == 2 {
+			pattern.Namespace, pattern.Name = parts[0], parts[1]
+		}
+		analyzeUsagePattern(&pattern, heatmap, threshold)
+		patterns = append(patterns, pattern)
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].EstimatedSavings != patterns[j].EstimatedSavings {
+			return patterns[i].EstimatedSavings > patterns[j].EstimatedSavings
+		}
+		return patterns[i].WeeklyCost > patterns[j].WeeklyCost
+	})
+	return patterns, nil
+}
+
+// analyzeUsagePattern fills the peak, idle hours and days, suggestions and estimated savings of the pattern from its
+// heatmap. The savings are the cost of the hours a suggested shutdown would cover, at the average hourly cost of the
+// last week.
+func analyzeUsagePattern(pattern *UsagePattern, heatmap models.UsageHeatmap, threshold float64) {
+	pattern.Heatmap = heatmap.CPU
+	pattern.Complete = heatmap.IsComplete()
+
+	total, peak := 0.0, -1.0
+	var idle [7][24]bool
+	for day := range heatmap.CPU {
+		for hour, cpu := range heatmap.CPU[day] {
+			total += cpu
+			if cpu > peak {
+				peak = cpu
+				pattern.PeakDay, pattern.PeakHour = time.Weekday(day).String(), hour
+			}
+			idle[day][hour] = heatmap.Samples[day][hour] > 0 && cpu < threshold
+		}
+	}
+	if total > 0 {
+		pattern.PeakToAverage = peak / (total / hoursPerWeek)
+	}
+	if !pattern.Complete {
+		return
+	}
+
+	var idleDays [7]bool
+	for day := range idle {
+		idleDays[day] = true
+		for hour := range idle[day] {
+			idleDays[day] = idleDays[day] && idle[day][hour]
+		}
+		if idleDays[day] {
+			pattern.IdleDays = append(pattern.IdleDays, time.Weekday(day).String())
+		}
+	}
+	var idleHours [24]bool
+	for hour := range idleHours {
+		idleHours[hour] = true
+		for day := range idle {
+			idleHours[hour] = idleHours[hour] && idle[day][hour]
+		}
+		if idleHours[hour] {
+			pattern.IdleHours = append(pattern.IdleHours, hour)
+		}
+	}
+
+	atNight := len(pattern.IdleHours) >= minDailyIdleHours && len(pattern.IdleHours) < 24
+	onWeekends := idleDays[time.Saturday] && idleDays[time.Sunday]
+	shutdownHours := 0
+	for day := range idle {
+		for hour := range idle[day] {
+			if (atNight && idleHours[hour]) || (onWeekends && idleDays[day]) {
+				shutdownHours++
+			}
+		}
+	}
+	if atNight {
+		pattern.Suggestions = append(pattern.Suggestions, ShutdownAtNight)
+	}
+	if onWeekends {
+		pattern.Suggestions = append(pattern.Suggestions, ShutdownOnWeekends)
+	}
+	if !atNight && !onWeekends && pattern.PeakToAverage >= offPeakRatio {
+		pattern.Suggestions = append(pattern.Suggestions, ScheduleOffPeak)
+	}
+	pattern.EstimatedSavings = pattern.WeeklyCost / hoursPerWeek * float64(shutdownHours) * 30 / 7
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAnalyzeUsagePattern(t *testing.T) {
+	heatmap := models.UsageHeatmap{}
+	for day := range heatmap.CPU {
+		for hour := range heatmap.CPU[day] {
+			heatmap.Samples[day][hour] = 4
+			// busy from 8h to 20h on weekdays
+			if day != int(time.Saturday) && day != int(time.Sunday) && hour >= 8 && hour < 20 {
+				heatmap.CPU[day][hour] = 0.5
+			}
+		}
+	}
+
+	pattern := UsagePattern{WeeklyCost: 16.8}
+	analyzeUsagePattern(&pattern, heatmap, 0.01)
+	utils.Equals(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 20, 21, 22, 23}, pattern.IdleHours)
+	utils.Equals(t, []string{"Sunday", "Saturday"}, pattern.IdleDays)
+	utils.Equals(t, []string{ShutdownAtNight, ShutdownOnWeekends}, pattern.Suggestions)
+	// 12 idle hours on 5 weekdays and 48 weekend hours at 0.1 per hour during 30 days
+	utils.Assert(t, pattern.EstimatedSavings > 46.28 && pattern.EstimatedSavings < 46.29, "savings: %v", pattern.EstimatedSavings)
+
+	heatmap.Samples[time.Monday][3] = 0
+	pattern = UsagePattern{WeeklyCost: 16.8}
+	analyzeUsagePattern(&pattern, heatmap, 0.01)
+	utils.Assert(t, !pattern.Complete && len(pattern.Suggestions) == 0, "no suggestion before every hour is sampled")
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// RetrieveNamespaceCostsInWindow returns cpu, memory and storage usage (in unit hours) and cost of pods
+// in the given namespace (every namespace if name is empty) for the time window [from, to).
+// Only the part of a pod's life inside the window is charged. The line items of a namespace also charge its
+// persistent volume claims, volume snapshots and load balancer services.
+func RetrieveNamespaceCostsInWindow(name string, from, to time.Time) ([]ResourceCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	namespaceSelector := `has(isNamespace)`
+	if name != All {
+		namespaceSelector = builder.Eq("xid", name) + `) @filter(has(isNamespace)`
+	}
+	query := `{
+		ns as var(func: ` + namespaceSelector + `) {
+			~namespace @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+				` + podCostInWindow(from, to) + `
+			}
+			namespaceCpu as sum(val(podCpuHours))
+			namespaceMem as sum(val(podMemHours))
+			namespaceStorage as sum(val(podStorageHours))
+			namespaceCpuCost as sum(val(podCpuCost))
+			namespaceMemCost as sum(val(podMemCost))
+			namespaceStorageCost as sum(val(podStorageCost))
+			namespaceGpu as sum(val(podGpuHours))
+			namespaceGpuCost as sum(val(podGpuCost))
+		}
+		` + namespaceResourcesInWindow(builder, "ns", from, to) + `
+
+		namespaces(func: uid(ns)) {
+			xid
+			name
+			cpu: val(namespaceCpu)
+			memory: val(namespaceMem)
+			storage: val(namespaceStorage)
+			cpuCost: val(namespaceCpuCost)
+			memoryCost: val(namespaceMemCost)
+			storageCost: val(namespaceStorageCost)
+			gpu: val(namespaceGpu)
+			gpuCost: val(namespaceGpuCost)
+			` + namespaceResourcesFields() + `
+		}
+	}`
+
+	type namespace struct {
+		ResourceCost
+		NamespaceResources
+	}
+	type root struct {
+		Namespaces []namespace `json:"namespaces"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	costs := []ResourceCost{}
+	for _, ns := range newRoot.Namespaces {
+		cost := ns.ResourceCost
+		cost.LineItems = NamespaceLineItems(cost, ns.NamespaceResources)
+		cost.TotalCost = lineItemsTotal(cost.LineItems)
+		costs = append(costs, cost)
+	}
+	return costs, nil
+}
+
+// RetrieveLabelsCostInWindow returns the total usage (in unit hours) and cost of pods having any of
+// the given labels for the time window [from, to).
+func RetrieveLabelsCostInWindow(labels map[string]string, from, to time.Time) (ResourceCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	if len(labels) == 0 {
+		return ResourceCost{}, nil
+	}
+	query := `{
+		var(func: has(isLabel)) @filter(` + createFilterFromListOfLabels(builder, labels) + `) {
+			podUIDs as ~label @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `)
+		}
+		var(func: uid(podUIDs)) {
+			` + podCostInWindow(from, to) + `
+		}
+		total() {
+			cpu: sum(val(podCpuHours))
+			memory: sum(val(podMemHours))
+			storage: sum(val(podStorageHours))
+			cpuCost: sum(val(podCpuCost))
+			memoryCost: sum(val(podMemCost))
+			storageCost: sum(val(podStorageCost))
+			gpu: sum(val(podGpuHours))
+			gpuCost: sum(val(podGpuCost))
+		}
+	}`
+
+	type root struct {
+		Total []ResourceCost `json:"total"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return ResourceCost{}, err
+	}
+	// dgraph returns every aggregate at query root as a separate object
+	total := ResourceCost{}
+	for _, aggregate := range newRoot.Total {
+		total.CPU += aggregate.CPU
+		total.Memory += aggregate.Memory
+		total.Storage += aggregate.Storage
+		total.CPUCost += aggregate.CPUCost
+		total.MemoryCost += aggregate.MemoryCost
+		total.StorageCost += aggregate.StorageCost
+		total.GPU += aggregate.GPU
+		total.GPUCost += aggregate.GPUCost
+	}
+	total.LineItems = podLineItems(total.CPUCost, total.MemoryCost, total.GPUCost, total.Storage, total.StorageCost)
+	total.TotalCost = lineItemsTotal(total.LineItems)
+	return total, nil
+}
+
+// RetrieveGroupsWithLabels returns all groups along with their labels
+func RetrieveGroupsWithLabels() ([]models.GroupCRD, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	query := `{
+		groups(func: has(isPurserGroup)) {
+			uid
+			xid
+			name
+			label {
+				key
+				value
+			}
+		}
+	}`
+
+	type root struct {
+		Groups []models.GroupCRD `json:"groups"`
+	}
+	newRoot := root{}
+	err := builder.Execute(query, &newRoot)
+	if err != nil {
+		return nil, err
+	}
+	return newRoot.Groups, nil
+}
+
+// podsInWindowFilter selects pods which were alive at some point in the time window [from, to)
+func podsInWindowFilter(builder *dgraph.QueryBuilder, from, to time.Time) string {
+	return `le(startTime, ` + builder.Time(to) + `) AND (NOT has(endTime) OR ge(endTime, ` + builder.Time(from) + `))`
+}
+
+// podCostInWindow defines the usage (podCpuHours, podMemHours, podStorageHours, podGpuHours) and cost (podCpuCost,
+// podMemCost, podStorageCost, podGpuCost) variables of a pod for the part of its life inside the time window [from, to).
+// The cpus and memory of the pod are allocated by the allocation policy from its requests and average usage, a pod
+// whose usage was never sampled is taken to use its requests.
+func podCostInWindow(from, to time.Time) string {
+	secondsSinceFrom := fmt.Sprintf("%f", utils.GetSecondsSince(from))
+	secondsSinceTo := fmt.Sprintf("%f", utils.GetSecondsSince(to))
+	_, policy := allocation.GetPolicy()
+	return `podCpuRequest as cpuRequest
+			podMemRequest as memoryRequest
+			podCpuSampled as cpuUsage
+			podMemSampled as memoryUsage
+			isUsageSampled as count(usageSamples)
+			podCpuUsage as math(cond(isUsageSampled == 0, podCpuRequest, podCpuSampled))
+			podMemUsage as math(cond(isUsageSampled == 0, podMemRequest, podMemSampled))
+			podCpu as math(` + policy.Expression("podCpuRequest", "podCpuUsage") + `)
+			podMem as math(` + policy.Expression("podMemRequest", "podMemUsage") + `)
+			podStorage as storageRequest
+			podGpu as gpuRequest
+			podNodePremium as nodePremium
+			st as startTime
+			stSeconds as math(since(st))
+			secondsSinceStart as math(cond(stSeconds > ` + secondsSinceFrom + `, ` + secondsSinceFrom + `, stSeconds))
+			et as endTime
+			isTerminated as count(endTime)
+			etSeconds as math(cond(isTerminated == 0, 0.0, since(et)))
+			secondsSinceEnd as math(cond(etSeconds < ` + secondsSinceTo + `, ` + secondsSinceTo + `, etSeconds))
+			durationInHours as math(cond(secondsSinceStart > secondsSinceEnd, (secondsSinceStart - secondsSinceEnd) / 3600, 0.0))
+			podCpuHours as math(podCpu * durationInHours)
+			podMemHours as math(podMem * durationInHours)
+			podStorageHours as math(podStorage * durationInHours)
+			podGpuHours as math(podGpu * durationInHours)
+			podCpuCost as math(podCpu * durationInHours * ` + cpuCostPerCPUPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
+			podMemCost as math(podMem * durationInHours * ` + memCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + ` * ` + nodePremiumFactor("podNodePremium") + `)
+			podStorageCost as math(podStorage * durationInHours * ` + storageCostPerGBPerHour("secondsSinceStart", "secondsSinceEnd") + `)
+			podGpuCost as math(podGpu * durationInHours * ` + gpuCostPerGPUPerHour("secondsSinceStart", "secondsSinceEnd") + `)`
+}
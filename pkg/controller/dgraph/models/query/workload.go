@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package query
+
+import (
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Kinds of workloads whose cost is retrieved by RetrieveWorkloadCostsInWindow
+const (
+	DeploymentKind  = "Deployment"
+	StatefulSetKind = "StatefulSet"
+)
+
+// WorkloadCost is the cost in a time window of the pods of a deployment or statefulset
+type WorkloadCost struct {
+	Kind      string  `json:"kind"`
+	Xid       string  `json:"xid"`
+	TotalCost float64 `json:"totalCost"`
+}
+
+// RetrieveWorkloadCostsInWindow returns the cost of the pods of every deployment and statefulset which still exists,
+// for the time window [from, to). Deployments are charged for the pods of all their replicasets.
+func RetrieveWorkloadCostsInWindow(from, to time.Time) ([]WorkloadCost, error) {
+	builder := dgraph.NewReplicaQueryBuilder()
+	deployments, err := retrieveWorkloadCosts(builder, DeploymentKind, `has(isDeployment)`, `~deployment @filter(has(isReplicaset)) {
+				`+workloadPodsCost(builder, "~replicaset", from, to)+`
+				replicasetCost as sum(val(podCost))
+			}
+			workloadCost as sum(val(replicasetCost))`)
+	if err != nil {
+		return nil, err
+	}
+
+	builder = dgraph.NewReplicaQueryBuilder()
+	statefulsets, err := retrieveWorkloadCosts(builder, StatefulSetKind, `has(isStatefulset)`,
+		workloadPodsCost(builder, "~statefulset", from, to)+`
+			workloadCost as sum(val(podCost))`)
+	if err != nil {
+		return nil, err
+	}
+	return append(deployments, statefulsets...), nil
+}
+
+// workloadPodsCost returns the block of the pods reached by the edge which defines podCost, the cost of every pod
+func workloadPodsCost(builder *dgraph.QueryBuilder, edge string, from, to time.Time) string {
+	return edge + ` @filter(has(isPod) AND ` + podsInWindowFilter(builder, from, to) + `) {
+					` + podCostInWindow(from, to) + `
+					podCost as math(podCpuCost + podMemCost + podStorageCost + podGpuCost)
+				}`
+}
+
+// retrieveWorkloadCosts returns the cost of the workloads matching the function which still exist,
+// the body must define workloadCost for every workload
+func retrieveWorkloadCosts(builder *dgraph.QueryBuilder, kind, function, body string) ([]WorkloadCost, error) {
+	query := `{
+		wl as var(func: ` + function + `) @filter(NOT has(endTime)) {
+			` + body + `
+		}
+
+		workloads(func: uid(wl)) {
+			xid
+			totalCost: val(workloadCost)
+		}
+	}`
+
+	type root struct {
+		Workloads []WorkloadCost `json:"workloads"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	for i := range newRoot.Workloads {
+		newRoot.Workloads[i].Kind = kind
+	}
+	return newRoot.Workloads, nil
+}
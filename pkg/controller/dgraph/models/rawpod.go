@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RawPod is the part of a pod which is newer than the vendored kubernetes api: the pod overhead of its runtime
+// class, its ephemeral containers (kubectl debug) and the IPs of dual-stack pods. It is decoded from the raw pod
+// returned by the api server.
+type RawPod struct {
+	Metadata meta_v1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Overhead            api_v1.ResourceList  `json:"overhead,omitempty"`
+		EphemeralContainers []EphemeralContainer `json:"ephemeralContainers"`
+	} `json:"spec"`
+	Status struct {
+		EphemeralContainerStatuses []api_v1.ContainerStatus `json:"ephemeralContainerStatuses"`
+		PodIPs                     []PodIP                  `json:"podIPs"`
+	} `json:"status"`
+}
+
+// PodIP is an address of a pod, dual-stack pods have an IPv4 and an IPv6 address
+type PodIP struct {
+	IP string `json:"ip"`
+}
+
+// StorePodOverhead adds the pod overhead (resources used by the sandbox of the pod, ex: kata containers) to the
+// resources allocated to the pod. The overhead is fixed at admission, so it is added only once.
+func StorePodOverhead(pod RawPod) error {
+	overhead := pod.Spec.Overhead
+	if len(overhead) == 0 {
+		return nil
+	}
+	xid := pod.Metadata.Namespace + ":" + pod.Metadata.Name
+	stored, err := getPodAllocation(xid)
+	if err != nil {
+		return err
+	}
+	if stored.CPUOverhead != 0 || stored.MemoryOverhead != 0 {
+		return nil
+	}
+
+	cpuOverhead := utils.CPUCores(*overhead.Cpu())
+	memoryOverhead := utils.MemoryGB(*overhead.Memory())
+	p := Pod{
+		ID:             dgraph.ID{UID: stored.UID, Xid: xid},
+		CPUOverhead:    cpuOverhead,
+		MemoryOverhead: memoryOverhead,
+		CPURequest:     stored.CPURequest,
+		MemoryRequest:  stored.MemoryRequest,
+		CPULimit:       stored.CPULimit,
+		MemoryLimit:    stored.MemoryLimit,
+	}
+	addOverhead(&p, cpuOverhead, memoryOverhead)
+	_, err = dgraph.MutateNode(p, dgraph.UPDATE)
+	if err == nil {
+		log.Debugf("overhead of pod: (%s) added, cpu: %f, memory: %f GB", xid, cpuOverhead, memoryOverhead)
+	}
+	return err
+}
+
+// getPodAllocation returns the stored requests, limits and overhead of the pod
+func getPodAllocation(xid string) (Pod, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		pod(func: ` + builder.Eq("xid", xid) + `) @filter(has(isPod)) {
+			uid
+			cpuRequest
+			cpuLimit
+			memoryRequest
+			memoryLimit
+			cpuOverhead
+			memoryOverhead
+		}
+	}`
+	type root struct {
+		Pod []Pod `json:"pod"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return Pod{}, err
+	}
+	if len(newRoot.Pod) == 0 {
+		return Pod{}, fmt.Errorf("pod: %s is not persisted yet", xid)
+	}
+	return newRoot.Pod[0], nil
+}
+
+// addPodOverhead adds the stored overhead of the pod to the requests and limits of its containers
+func addPodOverhead(pod *Pod, xid string) {
+	stored, err := getPodAllocation(xid)
+	if err != nil || (stored.CPUOverhead == 0 && stored.MemoryOverhead == 0) {
+		return
+	}
+	addOverhead(pod, stored.CPUOverhead, stored.MemoryOverhead)
+}
+
+// addOverhead adds the overhead to the requests of the pod and to its limits if they are set
+func addOverhead(pod *Pod, cpuOverhead utils.Cores, memoryOverhead utils.GB) {
+	pod.CPURequest += cpuOverhead
+	pod.MemoryRequest += memoryOverhead
+	if pod.CPULimit != 0 {
+		pod.CPULimit += cpuOverhead
+	}
+	if pod.MemoryLimit != 0 {
+		pod.MemoryLimit += memoryOverhead
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestDecodeRawPod ...
+func TestDecodeRawPod(t *testing.T) {
+	data := []byte(`{
+		"metadata": {"name": "web-1", "namespace": "shop"},
+		"spec": {
+			"runtimeClassName": "kata",
+			"overhead": {"cpu": "250m", "memory": "512Mi"},
+			"ephemeralContainers": [{"name": "debugger", "image": "busybox", "targetContainerName": "web"}]
+		},
+		"status": {
+			"ephemeralContainerStatuses": [{"name": "debugger", "state": {"running": {"startedAt": "2018-11-01T10:00:00Z"}}}],
+			"podIPs": [{"ip": "10.0.0.12"}, {"ip": "fd00::12"}]
+		}
+	}`)
+	pod := RawPod{}
+	utils.Ok(t, json.Unmarshal(data, &pod))
+	utils.Equals(t, "shop", pod.Metadata.Namespace)
+	utils.Equals(t, int64(250), pod.Spec.Overhead.Cpu().MilliValue())
+	utils.Equals(t, int64(512*1024*1024), pod.Spec.Overhead.Memory().Value())
+	utils.Equals(t, []EphemeralContainer{{Name: "debugger", Image: "busybox", TargetContainerName: "web"}},
+		pod.Spec.EphemeralContainers)
+	utils.Equals(t, 1, len(pod.Status.EphemeralContainerStatuses))
+	utils.Equals(t, []PodIP{{IP: "10.0.0.12"}, {IP: "fd00::12"}}, pod.Status.PodIPs)
+}
+
+// TestAddOverhead ...
+func TestAddOverhead(t *testing.T) {
+	pod := Pod{CPURequest: 1, CPULimit: 2, MemoryRequest: 2}
+	addOverhead(&pod, 0.25, 0.5)
+	utils.Equals(t, 1.25, float64(pod.CPURequest))
+	utils.Equals(t, 2.25, float64(pod.CPULimit))
+	utils.Equals(t, 2.5, float64(pod.MemoryRequest))
+	// an unlimited pod stays unlimited
+	utils.Equals(t, 0.0, float64(pod.MemoryLimit))
+}
+
+// TestGetEphemeralContainerLifetime ...
+func TestGetEphemeralContainerLifetime(t *testing.T) {
+	started := time.Date(2018, 11, 1, 10, 0, 0, 0, time.UTC)
+	finished := started.Add(15 * time.Minute)
+
+	start, end := getEphemeralContainerLifetime(api_v1.ContainerState{Running: &api_v1.ContainerStateRunning{
+		StartedAt: meta_v1.NewTime(started)}})
+	utils.Assert(t, start.Equal(started) && end.IsZero(), "running session: %v - %v", start, end)
+
+	start, end = getEphemeralContainerLifetime(api_v1.ContainerState{Terminated: &api_v1.ContainerStateTerminated{
+		StartedAt: meta_v1.NewTime(started), FinishedAt: meta_v1.NewTime(finished)}})
+	utils.Assert(t, start.Equal(started) && end.Equal(finished), "terminated session: %v - %v", start, end)
+
+	start, _ = getEphemeralContainerLifetime(api_v1.ContainerState{Waiting: &api_v1.ContainerStateWaiting{}})
+	utils.Assert(t, start.IsZero(), "waiting container has started at %v", start)
+}
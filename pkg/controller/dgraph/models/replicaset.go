@@ -116,7 +116,7 @@ func CreateOrGetReplicasetByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
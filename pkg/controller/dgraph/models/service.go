@@ -43,6 +43,13 @@ type Service struct {
 	Interacts []*Service `json:"interacts,omitempty"`
 	Namespace *Namespace `json:"namespace,omitempty"`
 	Type      string     `json:"type,omitempty"`
+
+	// ServiceType is the kubernetes type of the service (ClusterIP, NodePort, LoadBalancer or ExternalName)
+	ServiceType string `json:"serviceType,omitempty"`
+	// ExternalName is the hostname an ExternalName service is an alias of
+	ExternalName string `json:"externalName,omitempty"`
+	// Count is the number of interactions of a pod with the service, when the service has no pods
+	Count float64 `json:"externalService|count,omitempty"`
 }
 
 func newService(svc api_v1.Service) (*api.Assigned, error) {
@@ -52,6 +59,9 @@ func newService(svc api_v1.Service) (*api.Assigned, error) {
 		Type:      "service",
 		ID:        dgraph.ID{Xid: svc.Namespace + ":" + svc.Name},
 		StartTime: svc.GetCreationTimestamp().Time.Format(time.RFC3339),
+
+		ServiceType:  string(svc.Spec.Type),
+		ExternalName: svc.Spec.ExternalName,
 	}
 	namespaceUID := CreateOrGetNamespaceByID(svc.Namespace)
 	if namespaceUID != "" {
@@ -72,6 +82,16 @@ func StoreService(service api_v1.Service) error {
 		}
 		log.Infof("Service with xid: (%s) persisted in dgraph", xid)
 		uid = assigned.Uids["blank-0"]
+	} else {
+		// the type of a service can be changed, a load balancer is charged only while the service has that type
+		updatedService := Service{
+			ID:           dgraph.ID{Xid: xid, UID: uid},
+			ServiceType:  string(service.Spec.Type),
+			ExternalName: service.Spec.ExternalName,
+		}
+		if _, err := dgraph.MutateNode(updatedService, dgraph.UPDATE); err != nil {
+			return err
+		}
 	}
 
 	svcDeletionTimestamp := service.GetDeletionTimestamp()
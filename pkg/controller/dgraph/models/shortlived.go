@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	shortLivedPodsBucketFormat = "2006010215"
+
+	// minSyntheticSpanInHours is one second
+	minSyntheticSpanInHours = 1.0 / 3600
+)
+
+// StoreShortLivedPod aggregates a terminated short lived pod into the synthetic pod of its namespace for the
+// hour in which it terminated. The synthetic pod spans from the earliest start to the latest end of the
+// aggregated pods and its requests are set such that its cost equals the total cost of the aggregated pods.
+func StoreShortLivedPod(k8sPod api_v1.Pod, endTime time.Time) error {
+	startTime := k8sPod.GetCreationTimestamp().Time
+	metrics := getPodRequests(k8sPod)
+
+	xid := k8sPod.Namespace + ":short-lived-pods-" + endTime.Format(shortLivedPodsBucketFormat)
+	synthetic, err := getSyntheticPod(xid)
+	if err != nil {
+		return err
+	}
+	if synthetic.UID == "" {
+		synthetic = Pod{
+			ID:          dgraph.ID{Xid: xid},
+			Name:        "pod-short-lived-pods-" + endTime.Format(shortLivedPodsBucketFormat),
+			IsPod:       true,
+			IsSynthetic: true,
+			Type:        "pod",
+			StartTime:   startTime.Format(time.RFC3339),
+			EndTime:     endTime.Format(time.RFC3339),
+		}
+		namespaceUID := CreateOrGetNamespaceByID(k8sPod.Namespace)
+		if namespaceUID != "" {
+			synthetic.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: k8sPod.Namespace}}
+		}
+	}
+
+	aggregateShortLivedPod(&synthetic, metrics, startTime, endTime)
+
+	_, err = dgraph.MutateNode(synthetic, dgraph.CREATE)
+	if err == nil {
+		log.Debugf("short lived pod: (%s:%s) aggregated into: (%s)", k8sPod.Namespace, k8sPod.Name, xid)
+	}
+	return err
+}
+
+// aggregateShortLivedPod adds the usage of the short lived pod with the given requests, living from startTime to
+// endTime, to the synthetic pod and spreads the aggregated usage over the span of the synthetic pod
+func aggregateShortLivedPod(synthetic *Pod, metrics Metrics, startTime, endTime time.Time) {
+	durationInHours := endTime.Sub(startTime).Hours()
+	if syntheticStart, err := time.Parse(time.RFC3339, synthetic.StartTime); err == nil && startTime.After(syntheticStart) {
+		startTime = syntheticStart
+	}
+	if syntheticEnd, err := time.Parse(time.RFC3339, synthetic.EndTime); err == nil && endTime.Before(syntheticEnd) {
+		endTime = syntheticEnd
+	}
+	synthetic.StartTime = startTime.Format(time.RFC3339)
+	synthetic.EndTime = endTime.Format(time.RFC3339)
+	synthetic.SyntheticPodCount++
+	synthetic.CPUHours += metrics.CPURequest.Over(durationInHours)
+	synthetic.MemoryGBHours += metrics.MemoryRequest.Over(durationInHours)
+	synthetic.GPUHours += metrics.GPURequest * durationInHours
+
+	// requests are spread over the span of the synthetic pod so that cost queries charge the aggregated usage
+	spanInHours := endTime.Sub(startTime).Hours()
+	if spanInHours < minSyntheticSpanInHours {
+		spanInHours = minSyntheticSpanInHours
+	}
+	synthetic.CPURequest = synthetic.CPUHours.Average(spanInHours)
+	synthetic.MemoryRequest = synthetic.MemoryGBHours.Average(spanInHours)
+	synthetic.GPURequest = synthetic.GPUHours / spanInHours
+}
+
+func getSyntheticPod(xid string) (Pod, error) {
+	uid := dgraph.GetUID(xid, IsPod)
+	if uid == "" {
+		return Pod{}, nil
+	}
+	q := `query {
+		pods(func: uid(` + uid + `)) {
+			uid
+			xid
+			startTime
+			endTime
+			syntheticPodCount
+			cpuHours
+			memoryGBHours
+			gpuHours
+		}
+	}`
+
+	type root struct {
+		Pods []Pod `json:"pods"`
+	}
+	newRoot := root{}
+	err := dgraph.ExecuteQuery(q, &newRoot)
+	if err != nil || len(newRoot.Pods) == 0 {
+		return Pod{}, err
+	}
+	return newRoot.Pods[0], nil
+}
+
+// getPodRequests returns the sum of requests and limits of all containers in the pod
+func getPodRequests(k8sPod api_v1.Pod) Metrics {
+	cpuRequest := &resource.Quantity{}
+	memoryRequest := &resource.Quantity{}
+	cpuLimit := &resource.Quantity{}
+	memoryLimit := &resource.Quantity{}
+	gpus := 0.0
+	for _, c := range k8sPod.Spec.Containers {
+		utils.AddResourceAToResourceB(c.Resources.Requests.Cpu(), cpuRequest)
+		utils.AddResourceAToResourceB(c.Resources.Requests.Memory(), memoryRequest)
+		utils.AddResourceAToResourceB(c.Resources.Limits.Cpu(), cpuLimit)
+		utils.AddResourceAToResourceB(c.Resources.Limits.Memory(), memoryLimit)
+		gpus += getContainerGPUs(c, k8sPod.Annotations)
+	}
+	return Metrics{
+		CPURequest:    utils.CPUCores(*cpuRequest),
+		CPULimit:      utils.CPUCores(*cpuLimit),
+		MemoryRequest: utils.MemoryGB(*memoryRequest),
+		MemoryLimit:   utils.MemoryGB(*memoryLimit),
+		GPURequest:    gpus,
+	}
+}
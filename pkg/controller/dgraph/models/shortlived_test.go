@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAggregateShortLivedPod(t *testing.T) {
+	at := func(minute, second int) time.Time {
+		return time.Date(2018, 11, 2, 10, minute, second, 0, time.UTC)
+	}
+	synthetic := Pod{StartTime: at(10, 0).Format(time.RFC3339), EndTime: at(11, 0).Format(time.RFC3339)}
+	// 2 cpus and 4 GB during 1 minute, then 1 cpu, 1 GB and 1 gpu during 2 minutes overlapping the first pod
+	aggregateShortLivedPod(&synthetic, Metrics{CPURequest: 2, MemoryRequest: 4}, at(10, 0), at(11, 0))
+	aggregateShortLivedPod(&synthetic, Metrics{CPURequest: 1, MemoryRequest: 1, GPURequest: 1}, at(10, 30), at(12, 30))
+
+	utils.Equals(t, 2, synthetic.SyntheticPodCount)
+	utils.Equals(t, at(10, 0).Format(time.RFC3339), synthetic.StartTime)
+	utils.Equals(t, at(12, 30).Format(time.RFC3339), synthetic.EndTime)
+	isClose := func(expected, actual float64) bool {
+		return math.Abs(expected-actual) < 1e-9
+	}
+	utils.Assert(t, isClose(4.0/60, synthetic.CPUHours), "cpu hours: %f", synthetic.CPUHours)
+	utils.Assert(t, isClose(6.0/60, synthetic.MemoryGBHours), "memory GB hours: %f", synthetic.MemoryGBHours)
+	utils.Assert(t, isClose(2.0/60, synthetic.GPUHours), "gpu hours: %f", synthetic.GPUHours)
+	// the requests over the 2.5 minutes span charge the usage of the aggregated pods
+	utils.Assert(t, isClose(4.0/2.5, synthetic.CPURequest), "cpu request: %f", synthetic.CPURequest)
+	utils.Assert(t, isClose(6.0/2.5, synthetic.MemoryRequest), "memory request: %f", synthetic.MemoryRequest)
+	utils.Assert(t, isClose(2.0/2.5, synthetic.GPURequest), "gpu request: %f", synthetic.GPURequest)
+
+	// pods living less than a second are spread over one second
+	instant := Pod{StartTime: at(20, 0).Format(time.RFC3339), EndTime: at(20, 0).Format(time.RFC3339)}
+	aggregateShortLivedPod(&instant, Metrics{CPURequest: 1}, at(20, 0), at(20, 0).Add(500*time.Millisecond))
+	utils.Equals(t, 1, instant.SyntheticPodCount)
+	utils.Assert(t, isClose(0.5, instant.CPURequest), "cpu request: %f", instant.CPURequest)
+}
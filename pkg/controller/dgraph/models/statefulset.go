@@ -33,13 +33,14 @@ const (
 // Statefulset schema in dgraph
 type Statefulset struct {
 	dgraph.ID
-	IsStatefulset bool       `json:"isStatefulset,omitempty"`
-	Name          string     `json:"name,omitempty"`
-	StartTime     string     `json:"startTime,omitempty"`
-	EndTime       string     `json:"endTime,omitempty"`
-	Namespace     *Namespace `json:"namespace,omitempty"`
-	Pods          []*Pod     `json:"pods,omitempty"`
-	Type          string     `json:"type,omitempty"`
+	IsStatefulset bool         `json:"isStatefulset,omitempty"`
+	Name          string       `json:"name,omitempty"`
+	StartTime     string       `json:"startTime,omitempty"`
+	EndTime       string       `json:"endTime,omitempty"`
+	Namespace     *Namespace   `json:"namespace,omitempty"`
+	Pods          []*Pod       `json:"pods,omitempty"`
+	Type          string       `json:"type,omitempty"`
+	Environment   *Environment `json:"environment,omitempty"`
 }
 
 func createStatefulsetObject(statefulset apps_v1beta1.StatefulSet) Statefulset {
@@ -54,6 +55,7 @@ func createStatefulsetObject(statefulset apps_v1beta1.StatefulSet) Statefulset {
 	if namespaceUID != "" {
 		newStatefulset.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: statefulset.Namespace}}
 	}
+	newStatefulset.Environment = workloadEnvironment(statefulset.Namespace, namespaceUID, statefulset.Spec.Template.Labels)
 	statefulsetDeletionTimestamp := statefulset.GetDeletionTimestamp()
 	if !statefulsetDeletionTimestamp.IsZero() {
 		newStatefulset.EndTime = statefulsetDeletionTimestamp.Time.Format(time.RFC3339)
@@ -96,7 +98,7 @@ func CreateOrGetStatefulsetByID(xid string) string {
 	}
 	assigned, err := dgraph.MutateNode(d, dgraph.CREATE)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return ""
 	}
 	return assigned.Uids["blank-0"]
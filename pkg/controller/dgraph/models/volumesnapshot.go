@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package models
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Dgraph Model Constants
+const (
+	IsVolumeSnapshot = "isVolumeSnapshot"
+)
+
+// VolumeSnapshot schema in dgraph. RestoreSize is the size (in GB) of a volume restored from the snapshot.
+type VolumeSnapshot struct {
+	dgraph.ID
+	IsVolumeSnapshot bool       `json:"isVolumeSnapshot,omitempty"`
+	Name             string     `json:"name,omitempty"`
+	StartTime        string     `json:"startTime,omitempty"`
+	EndTime          string     `json:"endTime,omitempty"`
+	Namespace        *Namespace `json:"namespace,omitempty"`
+	Type             string     `json:"type,omitempty"`
+	RestoreSize      utils.GB   `json:"restoreSize,omitempty"`
+	SourceClaim      string     `json:"sourcePvc,omitempty"`
+}
+
+// RawVolumeSnapshot is a VolumeSnapshot (snapshot.storage.k8s.io) as returned by the api server.
+// The vendored client has no type for it.
+type RawVolumeSnapshot struct {
+	Metadata meta_v1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Source struct {
+			PersistentVolumeClaimName string `json:"persistentVolumeClaimName"`
+		} `json:"source"`
+	} `json:"spec"`
+	Status struct {
+		RestoreSize *resource.Quantity `json:"restoreSize,omitempty"`
+	} `json:"status"`
+}
+
+// StoreVolumeSnapshot creates the snapshot in the Dgraph or updates it if already present.
+// Snapshots which are not ready yet have no restore size and are stored once they are ready.
+func StoreVolumeSnapshot(snapshot RawVolumeSnapshot) error {
+	if snapshot.Status.RestoreSize == nil {
+		return nil
+	}
+	xid := snapshot.Metadata.Namespace + ":" + snapshot.Metadata.Name
+	uid := dgraph.GetUID(xid, IsVolumeSnapshot)
+
+	newSnapshot := VolumeSnapshot{
+		ID:               dgraph.ID{Xid: xid, UID: uid},
+		IsVolumeSnapshot: true,
+		Name:             "volumesnapshot-" + snapshot.Metadata.Name,
+		Type:             "volumesnapshot",
+		StartTime:        snapshot.Metadata.CreationTimestamp.Time.Format(time.RFC3339),
+		RestoreSize:      utils.MemoryGB(*snapshot.Status.RestoreSize),
+		SourceClaim:      snapshot.Spec.Source.PersistentVolumeClaimName,
+	}
+	if snapshot.Metadata.DeletionTimestamp != nil {
+		newSnapshot.EndTime = snapshot.Metadata.DeletionTimestamp.Time.Format(time.RFC3339)
+	}
+	if uid == "" {
+		namespaceUID := CreateOrGetNamespaceByID(snapshot.Metadata.Namespace)
+		if namespaceUID != "" {
+			newSnapshot.Namespace = &Namespace{ID: dgraph.ID{UID: namespaceUID, Xid: snapshot.Metadata.Namespace}}
+		}
+	}
+
+	assigned, err := dgraph.MutateNode(newSnapshot, dgraph.CREATE)
+	if err != nil {
+		return err
+	}
+	if uid == "" {
+		log.Infof("VolumeSnapshot with xid: (%s) persisted, uid: (%s)", xid, assigned.Uids["blank-0"])
+	}
+	return nil
+}
+
+// CloseDeletedVolumeSnapshots sets the end time of the snapshots which are open in the Dgraph but no longer
+// present in the cluster. Only snapshots of namespaces for which owns returns true are closed.
+// It returns the number of snapshots closed.
+func CloseDeletedVolumeSnapshots(present map[string]bool, owns func(namespace string) bool, endTime time.Time) (int, error) {
+	query := `{
+		snapshots(func: has(isVolumeSnapshot)) @filter(NOT has(endTime)) {
+			uid
+			xid
+			namespace {
+				xid
+			}
+		}
+	}`
+	type root struct {
+		Snapshots []VolumeSnapshot `json:"snapshots"`
+	}
+	newRoot := root{}
+	if err := dgraph.ExecuteQuery(query, &newRoot); err != nil {
+		return 0, err
+	}
+
+	closed := []VolumeSnapshot{}
+	for _, snapshot := range newRoot.Snapshots {
+		if present[snapshot.Xid] || snapshot.Namespace == nil || !owns(snapshot.Namespace.Xid) {
+			continue
+		}
+		closed = append(closed, VolumeSnapshot{
+			ID:      dgraph.ID{UID: snapshot.UID, Xid: snapshot.Xid},
+			EndTime: endTime.Format(time.RFC3339),
+		})
+	}
+	if len(closed) == 0 {
+		return 0, nil
+	}
+	if _, err := dgraph.MutateNode(closed, dgraph.UPDATE); err != nil {
+		return 0, err
+	}
+	return len(closed), nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+)
+
+// Dgraph Model Constants
+const (
+	IsWorkloadInteraction = "isWorkloadInteraction"
+)
+
+// WorkloadInteraction schema in dgraph, it is the traffic between the owners (services or workloads) of interacting
+// pods. Pods come and go while their owners stay, so the counts of the pod interactions are summed in it for good.
+type WorkloadInteraction struct {
+	dgraph.ID
+	IsWorkloadInteraction bool   `json:"isWorkloadInteraction,omitempty"`
+	Source                string `json:"sourceWorkload,omitempty"`
+	SourceKind            string `json:"sourceKind,omitempty"`
+	SourceNamespace       string `json:"sourceNamespace,omitempty"`
+	Destination           string `json:"destinationWorkload,omitempty"`
+	DestinationKind       string `json:"destinationKind,omitempty"`
+	DestinationNamespace  string `json:"destinationNamespace,omitempty"`
+	// Count is the number of interactions of the pods of the source with the pods of the destination
+	Count     float64 `json:"interactionCount"`
+	FirstSeen string  `json:"firstSeen,omitempty"`
+	LastSeen  string  `json:"lastSeen,omitempty"`
+}
+
+// WorkloadInteractionXID returns the xid of the interaction between the source and destination workloads
+func WorkloadInteractionXID(sourceKind, source, destinationKind, destination string) string {
+	return fmt.Sprintf("interaction:%s/%s->%s/%s", sourceKind, source, destinationKind, destination)
+}
+
+// AddWorkloadInteraction adds the count of the interaction to the count stored for its source and destination,
+// seen at the given time
+func AddWorkloadInteraction(interaction WorkloadInteraction, seen time.Time) error {
+	xid := WorkloadInteractionXID(interaction.SourceKind, interaction.Source, interaction.DestinationKind, interaction.Destination)
+	stored, err := retrieveWorkloadInteraction(xid)
+	if err != nil {
+		return err
+	}
+	interaction.FirstSeen = seen.Format(time.RFC3339)
+	if stored != nil {
+		interaction.UID = stored.UID
+		interaction.Count += stored.Count
+		if stored.FirstSeen != "" {
+			interaction.FirstSeen = stored.FirstSeen
+		}
+	}
+	interaction.Xid = xid
+	interaction.IsWorkloadInteraction = true
+	interaction.LastSeen = seen.Format(time.RFC3339)
+	_, err = dgraph.MutateNode(interaction, dgraph.CREATE)
+	return err
+}
+
+func retrieveWorkloadInteraction(xid string) (*WorkloadInteraction, error) {
+	builder := dgraph.NewQueryBuilder()
+	query := `{
+		interaction(func: ` + builder.Eq("xid", xid) + `) @filter(has(isWorkloadInteraction)) {
+			uid
+			interactionCount
+			firstSeen
+		}
+	}`
+
+	type root struct {
+		Interaction []WorkloadInteraction `json:"interaction"`
+	}
+	newRoot := root{}
+	if err := builder.Execute(query, &newRoot); err != nil {
+		return nil, err
+	}
+	if len(newRoot.Interaction) == 0 {
+		return nil, nil
+	}
+	return &newRoot.Interaction[0], nil
+}
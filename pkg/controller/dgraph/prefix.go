@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dgraph-io/dgo"
+)
+
+// predicatePrefix isolates the predicates of purser in a Dgraph shared with other applications: every predicate is
+// stored as <prefix>.<predicate>. The prefix is added to the schema, the mutations and the queries and removed
+// from the query results, so that the callers keep using the plain predicate names. Empty means no prefix.
+var predicatePrefix string
+
+var prefixRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// queryKeywords are the identifiers of GraphQL+- queries which are not predicates: functions, pagination and
+// ordering arguments, connectives, types of the query variables and the special predicates. Block names, aliases
+// and query variables are prefixed like the predicates, which keeps them consistent within a query.
+var queryKeywords = map[string]bool{
+	"query": true, "func": true, "uid": true, "uid_in": true, "eq": true, "le": true, "lt": true, "ge": true,
+	"gt": true, "has": true, "allofterms": true, "anyofterms": true, "alloftext": true, "anyoftext": true,
+	"regexp": true, "match": true, "between": true, "near": true, "within": true, "contains": true,
+	"intersects": true, "val": true, "count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"math": true, "since": true, "exp": true, "ln": true, "pow": true, "logbase": true, "sqrt": true,
+	"floor": true, "ceil": true, "cond": true, "orderasc": true, "orderdesc": true, "first": true,
+	"offset": true, "after": true, "and": true, "or": true, "not": true, "AND": true, "OR": true, "NOT": true,
+	"as": true, "var": true, "expand": true, "_all_": true, "_predicate_": true, "_uid_": true, "schema": true,
+	"string": true, "int": true, "float": true, "bool": true, "datetime": true, "dateTime": true,
+	"true": true, "false": true,
+}
+
+// SetPredicatePrefix sets the prefix of the predicates of purser (ex: purser), it must be set before the schema
+// is created
+func SetPredicatePrefix(prefix string) error {
+	if prefix != "" && !prefixRegex.MatchString(prefix) {
+		return fmt.Errorf("invalid dgraph predicate prefix: %q, it must be alphanumeric", prefix)
+	}
+	predicatePrefix = prefix
+	return nil
+}
+
+// queryJSON runs the query in the transaction, with the predicates prefixed, and returns the json result
+func queryJSON(ctx context.Context, txn *dgo.Txn, query string, variables map[string]string) ([]byte, error) {
+	resp, err := txn.QueryWithVars(ctx, prefixQuery(query), variables)
+	if err != nil {
+		return nil, err
+	}
+	return unprefixResult(resp.Json)
+}
+
+func prefixed(predicate string) string {
+	return predicatePrefix + "." + predicate
+}
+
+// prefixQuery adds the prefix to the identifiers of the query which are not keywords. Strings, numbers (ex: uids),
+// query variables ($v0) and directives (@filter) are left as they are.
+func prefixQuery(query string) string {
+	if predicatePrefix == "" {
+		return query
+	}
+	var b strings.Builder
+	b.Grow(len(query) + len(query)/4)
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '"':
+			end := i + 1
+			for end < len(query) && query[end] != '"' {
+				if query[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			b.WriteString(query[i:end])
+			i = end
+		case isIdentifierStart(c) || isDigit(c):
+			end := i + 1
+			for end < len(query) && (isIdentifierStart(query[end]) || isDigit(query[end]) || query[end] == '.') {
+				end++
+			}
+			token := query[i:end]
+			previous := byte(0)
+			if i > 0 {
+				previous = query[i-1]
+			}
+			if isDigit(c) || previous == '$' || previous == '@' || queryKeywords[token] {
+				b.WriteString(token)
+			} else {
+				b.WriteString(prefixed(token))
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// prefixSchema adds the prefix to the predicates of a schema having one predicate per line
+func prefixSchema(schema string) string {
+	if predicatePrefix == "" {
+		return schema
+	}
+	lines := strings.Split(schema, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		lines[i] = strings.Replace(line, trimmed, prefixed(trimmed), 1)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// prefixMutation adds the prefix to the keys of the json encoding of a mutation, except uid
+func prefixMutation(encoded []byte) ([]byte, error) {
+	if predicatePrefix == "" {
+		return encoded, nil
+	}
+	return renameJSONKeys(encoded, func(key string) string {
+		if key == "uid" {
+			return key
+		}
+		return prefixed(key)
+	})
+}
+
+// unprefixResult removes the prefix from the keys of a query result, reverse edges (~prefix.pod) and aggregations
+// (count(prefix.pods)) included, and from the predicate names listed by _predicate_
+func unprefixResult(encoded []byte) ([]byte, error) {
+	if predicatePrefix == "" || len(encoded) == 0 {
+		return encoded, nil
+	}
+	return renameJSONKeys(encoded, func(key string) string {
+		return unprefixed(key)
+	})
+}
+
+func unprefixed(key string) string {
+	search := predicatePrefix + "."
+	var b strings.Builder
+	for i := 0; i < len(key); {
+		if strings.HasPrefix(key[i:], search) && (i == 0 || !isIdentifierStart(key[i-1]) && !isDigit(key[i-1])) {
+			i += len(search)
+			continue
+		}
+		b.WriteByte(key[i])
+		i++
+	}
+	return b.String()
+}
+
+func renameJSONKeys(encoded []byte, rename func(key string) string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	// numbers are kept as they are encoded so that their precision is not changed
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("unable to rename predicates of json: %v", err)
+	}
+	return json.Marshal(renameKeys(value, rename))
+}
+
+func renameKeys(value interface{}, rename func(key string) string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if names, isList := item.([]interface{}); isList && key == "_predicate_" {
+				// the predicates of the node are the values of _predicate_
+				for i, name := range names {
+					if predicate, isString := name.(string); isString {
+						names[i] = rename(predicate)
+					}
+				}
+				renamed[key] = names
+				continue
+			}
+			renamed[rename(key)] = renameKeys(item, rename)
+		}
+		return renamed
+	case []interface{}:
+		for i, item := range v {
+			v[i] = renameKeys(item, rename)
+		}
+		return v
+	}
+	return value
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPrefixQuery(t *testing.T) {
+	utils.Ok(t, SetPredicatePrefix("purser"))
+	defer func() { predicatePrefix = "" }()
+
+	query := `query q($v0: string) {
+		pods as var(func: eq(xid, $v0)) @filter(has(isPod) AND le(startTime, "2018-01-01T00:00:00Z")) {
+			~pod { c as cpuCost }
+		}
+		pods(func: uid(pods, 0x1f), orderasc: name, first: 10) {
+			uid
+			name
+			cost: sum(val(c))
+			count(containers)
+		}
+	}`
+	expected := `query purser.q($v0: string) {
+		purser.pods as var(func: eq(purser.xid, $v0)) @filter(has(purser.isPod) AND le(purser.startTime, "2018-01-01T00:00:00Z")) {
+			~purser.pod { purser.c as purser.cpuCost }
+		}
+		purser.pods(func: uid(purser.pods, 0x1f), orderasc: purser.name, first: 10) {
+			uid
+			purser.name
+			purser.cost: sum(val(purser.c))
+			count(purser.containers)
+		}
+	}`
+	utils.Equals(t, expected, prefixQuery(query))
+	utils.Equals(t, "\n\t\t\tpurser.xid: string @index(hash) .\n", prefixSchema("\n\t\t\txid: string @index(hash) .\n"))
+}
+
+func TestPrefixJSON(t *testing.T) {
+	utils.Ok(t, SetPredicatePrefix("purser"))
+	defer func() { predicatePrefix = "" }()
+
+	mutation, err := prefixMutation([]byte(`[{"uid":"0x1","cpuCost":0.1000000000000000055,"labels":[{"uid":"0x2","key":"app"}]}]`))
+	utils.Ok(t, err)
+	utils.Equals(t, `[{"purser.cpuCost":0.1000000000000000055,"purser.labels":[{"purser.key":"app","uid":"0x2"}],"uid":"0x1"}]`, string(mutation))
+
+	result, err := unprefixResult([]byte(`{"purser.pods":[{"uid":"0x1","~purser.pod":[{"uid":"0x3"}],"count(purser.containers)":2}]}`))
+	utils.Ok(t, err)
+	utils.Equals(t, `{"pods":[{"count(containers)":2,"uid":"0x1","~pod":[{"uid":"0x3"}]}]}`, string(result))
+
+	result, err = unprefixResult([]byte(`{"purser.resources":[{"uid":"0x1","_predicate_":["purser.xid","purser.isPod"]}]}`))
+	utils.Ok(t, err)
+	utils.Equals(t, `{"resources":[{"_predicate_":["xid","isPod"],"uid":"0x1"}]}`, string(result))
+
+	utils.Assert(t, SetPredicatePrefix("purser.db") != nil, "prefix with a dot must be rejected")
+}
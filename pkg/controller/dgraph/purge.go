@@ -25,15 +25,21 @@ import (
 
 type resource struct {
 	ID
+	Predicates []string `json:"_predicate_,omitempty"`
 }
 
 // RemoveResourcesInactiveInCurrentMonth deletes all resources which have their deletion time stamp before
-// the start of current month.
+// the start of current month. In soft delete mode the resources are archived instead and the archived resources
+// are deleted once their grace period is over.
 func RemoveResourcesInactiveInCurrentMonth() {
 	err := removeOldDeletedResources()
 	if err != nil {
 		log.Println(err)
 	}
+	err = purgeExpiredArchives()
+	if err != nil {
+		log.Println(err)
+	}
 }
 
 func removeOldDeletedResources() error {
@@ -46,14 +52,24 @@ func removeOldDeletedResources() error {
 		return nil
 	}
 
-	_, err = MutateNode(uids, DELETE)
+	if isSoftDelete() {
+		return archiveResources(uids)
+	}
+	// only the uid is given so that all predicates of the nodes are deleted
+	nodes := make([]ID, len(uids))
+	for i, r := range uids {
+		nodes[i] = ID{UID: r.UID}
+	}
+	_, err = MutateNode(nodes, DELETE)
 	return err
 }
 
 func retrieveResourcesWithEndTimeBeforeCurrentMonthStart() ([]resource, error) {
 	q := `query {
-		resources(func: le(endTime, "` + utils.ConverTimeToRFC3339(utils.GetCurrentMonthStartTime()) + `")) {
+		resources(func: le(endTime, "` + utils.ConverTimeToRFC3339(utils.GetCurrentMonthStartTime()) + `")) @filter(NOT has(archivedAt) AND NOT has(retained)) {
 			uid
+			xid
+			_predicate_
 		}
 	}`
 
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GraphQL+- types of query variables
+const (
+	stringVariable = "string"
+	intVariable    = "int"
+)
+
+// QueryBuilder builds a GraphQL+- query whose values are passed as typed query variables instead of
+// being concatenated into the query text. A name or label given by the user can't change the structure
+// of the query this way.
+type QueryBuilder struct {
+	declarations []string
+	variables    map[string]string
+	// onReplica runs the query on a read replica
+	onReplica bool
+}
+
+// NewQueryBuilder returns a query builder without variables
+func NewQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{variables: make(map[string]string)}
+}
+
+// NewReplicaQueryBuilder returns a query builder whose queries are run on the read replicas, it is used by the
+// analytical queries of the api
+func NewReplicaQueryBuilder() *QueryBuilder {
+	return &QueryBuilder{variables: make(map[string]string), onReplica: true}
+}
+
+// String adds a string variable and returns its name to be used in the query
+func (b *QueryBuilder) String(value string) string {
+	return b.add(stringVariable, value)
+}
+
+// Int adds an int variable and returns its name to be used in the query
+func (b *QueryBuilder) Int(value int) string {
+	return b.add(intVariable, strconv.Itoa(value))
+}
+
+// Time adds a variable holding the time in RFC3339 format, it can be compared with datetime predicates
+func (b *QueryBuilder) Time(value time.Time) string {
+	return b.add(stringVariable, value.Format(time.RFC3339))
+}
+
+// Eq returns the function eq(predicate, $var) where $var is a new variable holding value
+func (b *QueryBuilder) Eq(predicate, value string) string {
+	return "eq(" + predicate + ", " + b.String(value) + ")"
+}
+
+func (b *QueryBuilder) add(variableType, value string) string {
+	name := "$v" + strconv.Itoa(len(b.declarations))
+	b.declarations = append(b.declarations, name+": "+variableType)
+	b.variables[name] = value
+	return name
+}
+
+// Build returns the query having the given body (the blocks enclosed in braces) with the declaration
+// of the variables added so far, along with their values.
+func (b *QueryBuilder) Build(body string) (string, map[string]string) {
+	if len(b.declarations) == 0 {
+		return "query " + body, nil
+	}
+	return "query q(" + strings.Join(b.declarations, ", ") + ") " + body, b.variables
+}
+
+// Execute builds the query having the given body, runs it and writes the result into root
+func (b *QueryBuilder) Execute(body string, root interface{}) error {
+	if !b.onReplica {
+		query, variables := b.Build(body)
+		return ExecuteQueryWithVars(query, variables, root)
+	}
+	respJSON, err := b.ExecuteRaw(body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respJSON, root)
+}
+
+// ExecuteRaw builds the query having the given body, runs it and returns the json result
+func (b *QueryBuilder) ExecuteRaw(body string) ([]byte, error) {
+	query, variables := b.Build(body)
+	if b.onReplica {
+		return executeReplicaQueryRaw(baseContext, query, variables)
+	}
+	return ExecuteQueryRawWithVars(query, variables)
+}
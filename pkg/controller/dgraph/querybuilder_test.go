@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	builder := NewQueryBuilder()
+	query, variables := builder.Build(`{ all(func: has(isPod)) { name } }`)
+	utils.Equals(t, `query { all(func: has(isPod)) { name } }`, query)
+	utils.Assert(t, variables == nil, "expected no variables")
+
+	name := `pod") { uid } injected(func: has(isNode)`
+	at := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	body := `{
+		pods(func: ` + builder.Eq("name", name) + `, first: ` + builder.Int(10) + `) @filter(le(startTime, ` + builder.Time(at) + `)) {
+			name
+		}
+	}`
+	query, variables = builder.Build(body)
+	utils.Equals(t, `query q($v0: string, $v1: int, $v2: string) {
+		pods(func: eq(name, $v0), first: $v1) @filter(le(startTime, $v2)) {
+			name
+		}
+	}`, query)
+	utils.Equals(t, map[string]string{"$v0": name, "$v1": "10", "$v2": "2018-05-01T10:00:00Z"}, variables)
+}
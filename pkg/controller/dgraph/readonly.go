@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// MaxReadOnlyQueryLength is the max number of characters of a query given to ExecuteReadOnlyQuery
+const MaxReadOnlyQueryLength = 10000
+
+// keywords which are not allowed in read only queries: mutations and the traversals whose cost is unbounded
+var forbiddenQueryKeywords = map[string]bool{
+	"mutation": true,
+	"upsert":   true,
+	"set":      true,
+	"delete":   true,
+	"recurse":  true,
+	"shortest": true,
+}
+
+var (
+	quotedString    = regexp.MustCompile(`"(\\.|[^"\\])*"`)
+	queryIdentifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_.]*`)
+)
+
+// ValidateReadOnlyQuery returns an error if the query is not a read only query block that can be run
+// without risk against the graph.
+func ValidateReadOnlyQuery(query string) error {
+	trimmed := strings.TrimSpace(query)
+	if trimmed == "" {
+		return fmt.Errorf("query is empty")
+	}
+	if len(trimmed) > MaxReadOnlyQueryLength {
+		return fmt.Errorf("query is longer than %d characters", MaxReadOnlyQueryLength)
+	}
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "query") {
+		return fmt.Errorf("query must be a query block starting with '{' or 'query'")
+	}
+	// values of the query (ex: eq(name, "delete")) can't be keywords
+	withoutValues := quotedString.ReplaceAllString(trimmed, `""`)
+	for _, identifier := range queryIdentifier.FindAllString(withoutValues, -1) {
+		if forbiddenQueryKeywords[strings.ToLower(identifier)] {
+			return fmt.Errorf("%s is not allowed in read only queries", identifier)
+		}
+	}
+	return nil
+}
+
+// ExecuteReadOnlyQuery runs the query in a read only transaction of a read replica and returns the json result.
+// The query is aborted if it doesn't complete within the timeout.
+func ExecuteReadOnlyQuery(query string, variables map[string]string, timeout time.Duration) ([]byte, error) {
+	if err := ValidateReadOnlyQuery(query); err != nil {
+		return nil, err
+	}
+	log.Debugf("read only query: (%v), variables: (%v)", query, variables)
+	ctx, cancel := context.WithTimeout(baseContext, timeout)
+	defer cancel()
+
+	return executeReplicaQueryRaw(ctx, query, variables)
+}
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestValidateReadOnlyQuery(t *testing.T) {
+	utils.Ok(t, ValidateReadOnlyQuery(`{ pods(func: eq(name, "delete")) { name cpuRequest } }`))
+	utils.Ok(t, ValidateReadOnlyQuery(`query q($name: string) { pods(func: eq(name, $name)) { uid } }`))
+
+	for _, query := range []string{
+		"",
+		`mutation { set { _:x <name> "x" . } }`,
+		`{ set { _:x <name> "x" . } }`,
+		`{ path as shortest(from: 0x1, to: 0x2) { pod } }`,
+		`{ pods(func: has(isPod)) @recurse(depth: 5) { name } }`,
+		`schema {}`,
+		`{ pods(func: has(isPod)) { name } }` + strings.Repeat(" ", MaxReadOnlyQueryLength) + "x",
+	} {
+		utils.Assert(t, ValidateReadOnlyQuery(query) != nil, "expected query %q to be rejected", query)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"google.golang.org/grpc"
+)
+
+// Read replicas (dgraph alphas) serving the analytical queries of the api, so that the dashboards do not slow down
+// the ingestion. The writes and the queries of the ingestion (ex: GetUID) always go to the primary endpoint.
+var (
+	replicaClients     []*dgo.Dgraph
+	replicaConnections []*grpc.ClientConn
+	nextReplica        uint32
+)
+
+// OpenReplicas connects to the read replicas given as host:port, analytical queries are sent to the primary endpoint
+// when no replica is given
+func OpenReplicas(urls []string) {
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" {
+			continue
+		}
+		conn, err := grpc.Dial(url, grpc.WithInsecure())
+		if err != nil {
+			log.Errorf("error while opening connection to Dgraph read replica %s: %v", url, err)
+			continue
+		}
+		replicaConnections = append(replicaConnections, conn)
+		replicaClients = append(replicaClients, dgo.NewDgraphClient(api.NewDgraphClient(conn)))
+		log.Infof("analytical queries are routed to Dgraph read replica %s", url)
+	}
+}
+
+// closeReplicas terminates the connections to the read replicas
+func closeReplicas() {
+	for _, conn := range replicaConnections {
+		if err := conn.Close(); err != nil {
+			log.Errorf("error closing connection to Dgraph read replica: %v", err)
+		}
+	}
+}
+
+// ExecuteReplicaQuery runs the query on a read replica and writes result into root
+func ExecuteReplicaQuery(query string, root interface{}) error {
+	respJSON, err := executeReplicaQueryRaw(baseContext, query, nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(respJSON, root)
+}
+
+// executeReplicaQueryRaw runs the query in a read only transaction of the next read replica (round robin). The
+// query is run again on the primary endpoint if the replica fails, so that a replica down does not break the api.
+func executeReplicaQueryRaw(ctx context.Context, query string, variables map[string]string) ([]byte, error) {
+	if len(replicaClients) == 0 {
+		return queryJSON(ctx, client.NewReadOnlyTxn(), query, variables)
+	}
+
+	log.Debugf("replica query: (%v), variables: (%v)", query, variables)
+	replica := replicaClients[atomic.AddUint32(&nextReplica, 1)%uint32(len(replicaClients))]
+	resp, err := queryJSON(ctx, replica.NewReadOnlyTxn(), query, variables)
+	if err == nil || ctx.Err() != nil {
+		return resp, err
+	}
+	log.Warnf("query failed on Dgraph read replica, running it on the primary endpoint: %v", err)
+	return queryJSON(ctx, client.NewReadOnlyTxn(), query, variables)
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/vmware/purser/test/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// routingDgraph is a dgraph server recording the queries and mutations it serves in the log shared by the servers
+type routingDgraph struct {
+	api.DgraphClient
+	name        string
+	log         *[]string
+	unavailable bool
+}
+
+func (d *routingDgraph) Query(ctx context.Context, req *api.Request, opts ...grpc.CallOption) (*api.Response, error) {
+	*d.log = append(*d.log, d.name+" query")
+	if d.unavailable {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	return &api.Response{Json: []byte(`{}`)}, nil
+}
+
+func (d *routingDgraph) Mutate(ctx context.Context, mu *api.Mutation, opts ...grpc.CallOption) (*api.Assigned, error) {
+	*d.log = append(*d.log, d.name+" mutate")
+	return &api.Assigned{}, nil
+}
+
+// routeToServers connects the primary endpoint and two read replicas to recording servers, it returns the log of
+// the calls and a function restoring the clients
+func routeToServers() (*[]string, *routingDgraph, func()) {
+	dgraphClient, replicas, next := client, replicaClients, nextReplica
+	calls := []string{}
+	failing := &routingDgraph{name: "replica-2", log: &calls}
+	client = dgo.NewDgraphClient(&routingDgraph{name: "primary", log: &calls})
+	replicaClients = []*dgo.Dgraph{
+		dgo.NewDgraphClient(&routingDgraph{name: "replica-1", log: &calls}),
+		dgo.NewDgraphClient(failing),
+	}
+	nextReplica = 1
+	return &calls, failing, func() {
+		client, replicaClients, nextReplica = dgraphClient, replicas, next
+	}
+}
+
+func TestReadsRoutedToReplicas(t *testing.T) {
+	calls, _, restore := routeToServers()
+	defer restore()
+
+	var root map[string]interface{}
+	builder := NewReplicaQueryBuilder()
+	utils.Ok(t, builder.Execute(`{ pods(func: eq(xid, `+builder.String("shop:web")+`)) { uid } }`, &root))
+	_, err := NewReplicaQueryBuilder().ExecuteRaw(`{ pods(func: has(isPod)) { uid } }`)
+	utils.Ok(t, err)
+	_, err = ExecuteReadOnlyQuery(`{ pods(func: has(isPod)) { uid } }`, nil, time.Second)
+	utils.Ok(t, err)
+	utils.Ok(t, ExecuteReplicaQuery(`{ pods(func: has(isPod)) { uid } }`, &root))
+
+	// the queries are sent round robin to the replicas, the primary endpoint serves none of them
+	utils.Equals(t, []string{"replica-1 query", "replica-2 query", "replica-1 query", "replica-2 query"}, *calls)
+}
+
+func TestWritesAndAdminQueriesRoutedToPrimary(t *testing.T) {
+	calls, _, restore := routeToServers()
+	defer restore()
+
+	_, err := MutateNode(map[string]string{"uid": "0x1", "name": "pod-web"}, UPDATE)
+	utils.Ok(t, err)
+	// the restore of archived resources checks the nodes written just before, the replicas may lag behind
+	_, err = findLiveCounterparts([]ArchivedResource{{ID: ID{Xid: "shop:web"}, Marker: "isDeployment"}})
+	utils.Ok(t, err)
+	var root map[string]interface{}
+	builder := NewQueryBuilder()
+	utils.Ok(t, builder.Execute(`{ pods(func: eq(xid, `+builder.String("shop:web")+`)) { uid } }`, &root))
+	utils.Ok(t, ExecuteQuery(`{ pods(func: has(isPod)) { uid } }`, &root))
+
+	utils.Equals(t, []string{"primary mutate", "primary query", "primary query", "primary query"}, *calls)
+}
+
+func TestReplicaFailureRunsQueryOnPrimary(t *testing.T) {
+	calls, failing, restore := routeToServers()
+	defer restore()
+	failing.unavailable = true
+	nextReplica = 0
+
+	_, err := NewReplicaQueryBuilder().ExecuteRaw(`{ pods(func: has(isPod)) { uid } }`)
+	utils.Ok(t, err)
+	_, err = NewReplicaQueryBuilder().ExecuteRaw(`{ pods(func: has(isPod)) { uid } }`)
+	utils.Ok(t, err)
+	utils.Equals(t, []string{"replica-2 query", "primary query", "replica-1 query"}, *calls)
+}
+
+func TestReadsRoutedToPrimaryWithoutReplicas(t *testing.T) {
+	calls, _, restore := routeToServers()
+	defer restore()
+	replicaClients = nil
+
+	_, err := NewReplicaQueryBuilder().ExecuteRaw(`{ pods(func: has(isPod)) { uid } }`)
+	utils.Ok(t, err)
+	utils.Equals(t, []string{"primary query"}, *calls)
+}
@@ -0,0 +1,276 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/vmware/purser/pkg/controller/buffering"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultRetryAttempts  = 5
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	replayInterval        = 5 * time.Second
+	reachabilityQuery     = `{ reachable(func: uid(0x1)) { uid } }`
+)
+
+// RetrySettings of the writes to dgraph. A write failing because dgraph is unreachable is retried Attempts times
+// with a backoff doubling from InitialBackoff (ex: 100ms) up to MaxBackoff (ex: 5s). It is then buffered in memory,
+// with the writes following it, until dgraph is reachable again. At most QueueSize writes are buffered (4999 at most).
+type RetrySettings struct {
+	Attempts       int    `json:"attempts,omitempty"`
+	InitialBackoff string `json:"initialBackoff,omitempty"`
+	MaxBackoff     string `json:"maxBackoff,omitempty"`
+	QueueSize      int    `json:"queueSize,omitempty"`
+}
+
+// ErrWriteBuffered is returned by the writes buffered while dgraph is unreachable, they are applied once it is back
+var ErrWriteBuffered = errors.New("dgraph is unreachable, the write is buffered")
+
+// ErrUnreachable is returned by the creations of nodes failing while dgraph is unreachable. They are not buffered:
+// the lookups of the nodes fail too, so every event of an object would buffer another node for it. The objects are
+// stored again by the reconnect handlers.
+var ErrUnreachable = errors.New("dgraph is unreachable, the node is not created")
+
+var (
+	retryMu        sync.Mutex
+	retryAttempts  = defaultRetryAttempts
+	initialBackoff = defaultInitialBackoff
+	maxBackoff     = defaultMaxBackoff
+	retryQueue     = &buffering.RingBuffer{Size: buffering.BufferSize, Mutex: &sync.Mutex{}}
+	// offline is set while writes are buffered, the new writes are then buffered after them to keep their order
+	offline           bool
+	droppedWrites     int
+	reconnectHandlers []func()
+)
+
+// SetupRetries sets the retries and the buffering of the writes, zero values keep the defaults
+func SetupRetries(settings RetrySettings) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	if settings.Attempts > 0 {
+		retryAttempts = settings.Attempts
+	}
+	initialBackoff = parseBackoff(settings.InitialBackoff, initialBackoff)
+	maxBackoff = parseBackoff(settings.MaxBackoff, maxBackoff)
+	if settings.QueueSize > 0 {
+		// the ring buffer keeps one slot free
+		size := uint32(settings.QueueSize) + 1
+		if size > buffering.BufferSize {
+			size = buffering.BufferSize
+		}
+		retryQueue = &buffering.RingBuffer{Size: size, Mutex: &sync.Mutex{}}
+	}
+}
+
+func parseBackoff(value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	backoff, err := time.ParseDuration(value)
+	if err != nil || backoff <= 0 {
+		log.Errorf("invalid dgraph retry backoff: (%s), using: %v", value, defaultValue)
+		return defaultValue
+	}
+	return backoff
+}
+
+// OnReconnect registers a handler called once the writes buffered while dgraph was unreachable are applied, to
+// store again the objects whose events were processed meanwhile: their queries failed and their writes may have been
+// dropped when the buffer was full
+func OnReconnect(handler func()) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	reconnectHandlers = append(reconnectHandlers, handler)
+}
+
+// BufferedWrites returns the number of writes waiting for dgraph to be reachable again
+func BufferedWrites() int {
+	return int(retryQueue.Len())
+}
+
+// mutate applies the mutation with retries, it is buffered when dgraph stays unreachable or while older writes are
+func mutate(mu *api.Mutation) (*api.Assigned, error) {
+	return mutateWithRetries(mu, true)
+}
+
+// createNodes applies the mutation creating nodes with retries, it fails with ErrUnreachable when dgraph stays
+// unreachable or while older writes are buffered
+func createNodes(mu *api.Mutation) (*api.Assigned, error) {
+	return mutateWithRetries(mu, false)
+}
+
+func mutateWithRetries(mu *api.Mutation, isBuffered bool) (*api.Assigned, error) {
+	retryMu.Lock()
+	isOffline, attempts, initial, max := offline, retryAttempts, initialBackoff, maxBackoff
+	retryMu.Unlock()
+	if isOffline {
+		return nil, unreachable(mu, isBuffered)
+	}
+
+	var assigned *api.Assigned
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-baseContext.Done():
+				return nil, err
+			case <-time.After(backoff(attempt-1, initial, max)):
+			}
+		}
+		start := time.Now()
+		assigned, err = client.NewTxn().Mutate(baseContext, mu)
+		recordWrite(start, err)
+		if err == nil || !isUnavailable(err) {
+			return assigned, err
+		}
+	}
+	log.Warnf("dgraph is unreachable after %d attempts, error: %v", attempts, err)
+	return nil, unreachable(mu, isBuffered)
+}
+
+// unreachable buffers the mutation, or fails it if it is not buffered. Either way the reconnect handlers are called
+// once dgraph is reachable again.
+func unreachable(mu *api.Mutation, isBuffered bool) error {
+	if isBuffered {
+		return bufferWrite(mu)
+	}
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	offline = true
+	return ErrUnreachable
+}
+
+// backoff returns the wait before the retry following the attempt (from 0): the initial backoff doubled at every
+// attempt up to the max backoff
+func backoff(attempt int, initial, max time.Duration) time.Duration {
+	wait := initial
+	for i := 0; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		return max
+	}
+	return wait
+}
+
+// isUnavailable tells whether the write failed because dgraph is unreachable or overloaded rather than because of
+// the mutation itself, writes aborted by the shutdown are not retried
+func isUnavailable(err error) bool {
+	if baseContext.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// bufferWrite queues the mutation to be applied once dgraph is reachable again, it is dropped when the queue is full
+func bufferWrite(mu *api.Mutation) error {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	offline = true
+	if !retryQueue.Put(mu) {
+		droppedWrites++
+		return fmt.Errorf("dgraph is unreachable and the write buffer is full, %d writes dropped", droppedWrites)
+	}
+	return ErrWriteBuffered
+}
+
+// RunRetries applies the buffered writes every replay interval until ctx is done, the writes left are then tried
+// once more
+func RunRetries(ctx context.Context) {
+	ticker := time.NewTicker(replayInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			replayWrites()
+			if left := BufferedWrites(); left > 0 {
+				log.Errorf("%d buffered dgraph writes are lost on shutdown", left)
+			}
+			return
+		case <-ticker.C:
+			replayWrites()
+		}
+	}
+}
+
+// replayWrites applies the buffered writes in order until dgraph fails again. Once every write is applied and dgraph
+// answers, the reconnect handlers are called.
+func replayWrites() {
+	replayed := 0
+	for {
+		retryMu.Lock()
+		items, count := retryQueue.ReadN(1)
+		if count == 0 && offline && replayed == 0 {
+			retryMu.Unlock()
+			if !isReachable() {
+				return
+			}
+			retryMu.Lock()
+			items, count = retryQueue.ReadN(1)
+		}
+		if count == 0 {
+			wasOffline, dropped, handlers := offline, droppedWrites, reconnectHandlers
+			offline, droppedWrites = false, 0
+			retryMu.Unlock()
+			if wasOffline {
+				log.Infof("dgraph is reachable again, %d buffered writes applied, %d dropped", replayed, dropped)
+				for _, handler := range handlers {
+					handler()
+				}
+			}
+			return
+		}
+		retryMu.Unlock()
+
+		mu := (*items[0]).(*api.Mutation)
+		start := time.Now()
+		_, err := client.NewTxn().Mutate(baseContext, mu)
+		recordWrite(start, err)
+		if err != nil && isUnavailable(err) {
+			log.Debugf("dgraph is still unreachable, %d writes buffered, error: %v", BufferedWrites(), err)
+			return
+		}
+		if err != nil {
+			log.Errorf("unable to apply buffered dgraph write, error: %v", err)
+		}
+		retryQueue.RemoveN(1)
+		replayed++
+	}
+}
+
+// isReachable tells whether dgraph answers a query, when only creations failed no buffered write tells it
+func isReachable() bool {
+	_, err := client.NewTxn().Query(baseContext, reachabilityQuery)
+	return err == nil || !isUnavailable(err)
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/dgo"
+	"github.com/dgraph-io/dgo/protos/api"
+	"github.com/vmware/purser/test/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempt, expected := range []time.Duration{100, 200, 400, 500, 500} {
+		utils.Equals(t, expected*time.Millisecond, backoff(attempt, 100*time.Millisecond, 500*time.Millisecond))
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	utils.Assert(t, isUnavailable(status.Error(codes.Unavailable, "connection refused")), "unreachable dgraph is retried")
+	utils.Assert(t, !isUnavailable(status.Error(codes.Unknown, "invalid mutation")), "invalid mutation is not retried")
+	utils.Assert(t, !isUnavailable(fmt.Errorf("not a grpc error")), "other errors are not retried")
+}
+
+func TestBufferWrite(t *testing.T) {
+	queue := retryQueue
+	defer func() {
+		retryQueue, offline, droppedWrites = queue, false, 0
+	}()
+	SetupRetries(RetrySettings{QueueSize: 2})
+	first, second := &api.Mutation{SetJson: []byte("1")}, &api.Mutation{SetJson: []byte("2")}
+
+	utils.Equals(t, ErrWriteBuffered, bufferWrite(first))
+	// the writes following a buffered write are buffered after it
+	_, err := mutate(second)
+	utils.Equals(t, ErrWriteBuffered, err)
+	utils.Assert(t, bufferWrite(&api.Mutation{}) != ErrWriteBuffered, "write is dropped when the buffer is full")
+	utils.Equals(t, 2, BufferedWrites())
+	utils.Equals(t, 1, droppedWrites)
+
+	items, _ := retryQueue.ReadN(2)
+	utils.Equals(t, first, (*items[0]).(*api.Mutation))
+	utils.Equals(t, second, (*items[1]).(*api.Mutation))
+}
+
+func TestCreateNodesNotBuffered(t *testing.T) {
+	queue := retryQueue
+	defer func() {
+		retryQueue, offline, droppedWrites = queue, false, 0
+	}()
+	SetupRetries(RetrySettings{QueueSize: 2})
+	utils.Equals(t, ErrWriteBuffered, bufferWrite(&api.Mutation{SetJson: []byte("1")}))
+
+	// the lookup of the node failed too, a buffered creation would be replayed once per event of the object
+	_, err := createNodes(&api.Mutation{SetJson: []byte(`{"xid":"default:web"}`)})
+	utils.Equals(t, ErrUnreachable, err)
+	utils.Equals(t, 1, BufferedWrites())
+}
+
+// recordingDgraph is a dgraph server applying every mutation, the other calls are not served
+type recordingDgraph struct {
+	api.DgraphClient
+	setJSON []string
+}
+
+func (d *recordingDgraph) Mutate(ctx context.Context, mu *api.Mutation, opts ...grpc.CallOption) (*api.Assigned, error) {
+	d.setJSON = append(d.setJSON, string(mu.SetJson))
+	return &api.Assigned{}, nil
+}
+
+func TestBufferedMutationsReplayed(t *testing.T) {
+	queue, dgraphClient := retryQueue, client
+	defer func() {
+		retryQueue, offline, droppedWrites, client = queue, false, 0, dgraphClient
+	}()
+	SetupRetries(RetrySettings{QueueSize: 2})
+	offline = true
+
+	// the pooled encoding buffer of the first node is reused by the second one
+	_, err := MutateNode(map[string]string{"uid": "0x1", "name": "pod-web"}, UPDATE)
+	utils.Equals(t, ErrWriteBuffered, err)
+	_, err = MutateNode(map[string]string{"uid": "0x2", "name": "pod-db"}, UPDATE)
+	utils.Equals(t, ErrWriteBuffered, err)
+
+	server := &recordingDgraph{}
+	client = dgo.NewDgraphClient(server)
+	replayWrites()
+	utils.Equals(t, []string{`{"name":"pod-web","uid":"0x1"}`, `{"name":"pod-db","uid":"0x2"}`}, server.setJSON)
+	utils.Equals(t, 0, BufferedWrites())
+	utils.Assert(t, !offline, "dgraph is reachable once the buffered writes are applied")
+}
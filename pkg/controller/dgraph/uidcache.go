@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/memory"
+)
+
+// maxCachedUIDs bounds the uid cache, it is cleared when it is full
+const maxCachedUIDs = 200000
+
+// UIDCacheStats are the counters of the uid cache since the start of the controller
+type UIDCacheStats struct {
+	Entries       int `json:"entries"`
+	Hits          int `json:"hits"`
+	Misses        int `json:"misses"`
+	Invalidations int `json:"invalidations"`
+	Clears        int `json:"clears"`
+}
+
+var (
+	uidCacheMu sync.Mutex
+	// uidCache maps the node type and xid of a node to its uid, xidKeys maps it back for the invalidation on delete
+	uidCache      = map[string]string{}
+	xidKeys       = map[string]string{}
+	uidCacheStats UIDCacheStats
+)
+
+func init() {
+	memory.OnPressure(func(aggressive bool) {
+		if aggressive {
+			ClearUIDCache()
+		}
+	})
+}
+
+func uidCacheKey(xid, nodeType string) string {
+	return nodeType + "/" + xid
+}
+
+// cachedUID returns the uid of the node if it is cached
+func cachedUID(xid, nodeType string) (string, bool) {
+	uidCacheMu.Lock()
+	defer uidCacheMu.Unlock()
+
+	uid, isCached := uidCache[uidCacheKey(xid, nodeType)]
+	if isCached {
+		uidCacheStats.Hits++
+	} else {
+		uidCacheStats.Misses++
+	}
+	return uid, isCached
+}
+
+// cacheUID stores the uid of the node, nodes not found are not cached as they may be created by the caller
+func cacheUID(xid, nodeType, uid string) {
+	if uid == "" {
+		return
+	}
+	uidCacheMu.Lock()
+	defer uidCacheMu.Unlock()
+
+	if len(uidCache) >= maxCachedUIDs {
+		clearUIDCacheLocked()
+	}
+	key := uidCacheKey(xid, nodeType)
+	uidCache[key] = uid
+	xidKeys[uid] = key
+}
+
+// forgetDeletedUIDs removes from the cache the nodes of a delete mutation (a node or a list of nodes). Nodes whose
+// predicates only are deleted are removed too, their type may be one of the deleted predicates.
+func forgetDeletedUIDs(encoded []byte) {
+	var nodes []ID
+	encoded = bytes.TrimSpace(encoded)
+	if len(encoded) > 0 && encoded[0] == '[' {
+		if err := json.Unmarshal(encoded, &nodes); err != nil {
+			log.Debugf("unable to read uids of deleted nodes, clearing uid cache: %v", err)
+			ClearUIDCache()
+			return
+		}
+	} else {
+		var node ID
+		if err := json.Unmarshal(encoded, &node); err != nil {
+			log.Debugf("unable to read uid of deleted node, clearing uid cache: %v", err)
+			ClearUIDCache()
+			return
+		}
+		nodes = append(nodes, node)
+	}
+
+	uidCacheMu.Lock()
+	defer uidCacheMu.Unlock()
+	for _, node := range nodes {
+		if key, isCached := xidKeys[node.UID]; isCached {
+			delete(uidCache, key)
+			delete(xidKeys, node.UID)
+			uidCacheStats.Invalidations++
+		}
+	}
+}
+
+// ClearUIDCache removes all cached uids
+func ClearUIDCache() {
+	uidCacheMu.Lock()
+	defer uidCacheMu.Unlock()
+	clearUIDCacheLocked()
+}
+
+func clearUIDCacheLocked() {
+	uidCache = map[string]string{}
+	xidKeys = map[string]string{}
+	uidCacheStats.Clears++
+}
+
+// GetUIDCacheStats returns the counters of the uid cache, for debugging
+func GetUIDCacheStats() UIDCacheStats {
+	uidCacheMu.Lock()
+	defer uidCacheMu.Unlock()
+
+	stats := uidCacheStats
+	stats.Entries = len(uidCache)
+	return stats
+}
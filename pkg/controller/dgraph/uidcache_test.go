@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestUIDCache(t *testing.T) {
+	ClearUIDCache()
+	cacheUID("default:pod-1", "isPod", "0x1")
+	cacheUID("default:pod-2", "isPod", "0x2")
+	cacheUID("default:pod-3", "isPod", "")
+
+	uid, isCached := cachedUID("default:pod-1", "isPod")
+	utils.Assert(t, isCached, "pod-1 must be cached")
+	utils.Equals(t, "0x1", uid)
+	_, isCached = cachedUID("default:pod-1", "isContainer")
+	utils.Assert(t, !isCached, "uids must be cached per node type")
+	_, isCached = cachedUID("default:pod-3", "isPod")
+	utils.Assert(t, !isCached, "nodes not found must not be cached")
+
+	forgetDeletedUIDs([]byte(`[{"uid":"0x1"},{"uid":"0x9"}]`))
+	_, isCached = cachedUID("default:pod-1", "isPod")
+	utils.Assert(t, !isCached, "deleted pod-1 must not be cached")
+	forgetDeletedUIDs([]byte(`{"uid":"0x2","isPod":null}`))
+	_, isCached = cachedUID("default:pod-2", "isPod")
+	utils.Assert(t, !isCached, "pod-2 with deleted predicates must not be cached")
+
+	stats := GetUIDCacheStats()
+	utils.Equals(t, 0, stats.Entries)
+	utils.Equals(t, 1, stats.Hits)
+	utils.Equals(t, 4, stats.Misses)
+	utils.Equals(t, 2, stats.Invalidations)
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dgraph
+
+import (
+	"sync"
+	"time"
+)
+
+// writeLatencyBuckets are the upper bounds (in seconds) of the buckets of the write latency histogram
+var writeLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// WriteStats are the counters of the mutations written to dgraph since the start of the controller. BucketCounts
+// are the cumulative counts of the writes whose latency is at most the upper bound of the bucket of same index.
+type WriteStats struct {
+	Writes         int       `json:"writes"`
+	Errors         int       `json:"errors"`
+	LatencySeconds float64   `json:"latencySeconds"`
+	Buckets        []float64 `json:"buckets"`
+	BucketCounts   []int     `json:"bucketCounts"`
+}
+
+var (
+	writeStatsMu sync.Mutex
+	writeStats   = WriteStats{Buckets: writeLatencyBuckets, BucketCounts: make([]int, len(writeLatencyBuckets))}
+)
+
+// recordWrite counts a mutation which started at start and failed with err, if not nil
+func recordWrite(start time.Time, err error) {
+	latency := time.Since(start).Seconds()
+	writeStatsMu.Lock()
+	defer writeStatsMu.Unlock()
+	writeStats.Writes++
+	if err != nil {
+		writeStats.Errors++
+	}
+	writeStats.LatencySeconds += latency
+	for i, bound := range writeStats.Buckets {
+		if latency <= bound {
+			writeStats.BucketCounts[i]++
+		}
+	}
+}
+
+// GetWriteStats returns the counters of the writes to dgraph
+func GetWriteStats() WriteStats {
+	writeStatsMu.Lock()
+	defer writeStatsMu.Unlock()
+	stats := writeStats
+	stats.BucketCounts = append([]int(nil), writeStats.BucketCounts...)
+	return stats
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hubble
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// The messages below are the subset of the fields of the Hubble observer API (cilium/api/v1/observer and flow)
+// which are read by purser, with the field numbers of the upstream protos. Other fields are skipped when decoding.
+
+// Verdicts of flows
+const (
+	verdictForwarded = 1
+	verdictDropped   = 2
+)
+
+// Traffic directions of flows, relative to the endpoint where the flow was observed
+const (
+	trafficDirectionIngress = 1
+	trafficDirectionEgress  = 2
+)
+
+// getFlowsRequest is observer.GetFlowsRequest
+type getFlowsRequest struct {
+	Follow bool                 `protobuf:"varint,3,opt,name=follow"`
+	Since  *timestamp.Timestamp `protobuf:"bytes,7,opt,name=since"`
+}
+
+func (m *getFlowsRequest) Reset()         { *m = getFlowsRequest{} }
+func (m *getFlowsRequest) String() string { return proto.CompactTextString(m) }
+func (*getFlowsRequest) ProtoMessage()    {}
+
+// getFlowsResponse is observer.GetFlowsResponse, responses with node status or lost events have no flow
+type getFlowsResponse struct {
+	Flow *flow `protobuf:"bytes,1,opt,name=flow"`
+}
+
+func (m *getFlowsResponse) Reset()         { *m = getFlowsResponse{} }
+func (m *getFlowsResponse) String() string { return proto.CompactTextString(m) }
+func (*getFlowsResponse) ProtoMessage()    {}
+
+// flow is flow.Flow
+type flow struct {
+	Verdict            int32      `protobuf:"varint,2,opt,name=verdict"`
+	IP                 *ip        `protobuf:"bytes,5,opt,name=IP"`
+	Source             *endpoint  `protobuf:"bytes,8,opt,name=source"`
+	Destination        *endpoint  `protobuf:"bytes,9,opt,name=destination"`
+	DestinationService *service   `protobuf:"bytes,21,opt,name=destination_service"`
+	TrafficDirection   int32      `protobuf:"varint,22,opt,name=traffic_direction"`
+	IsReply            *boolValue `protobuf:"bytes,26,opt,name=is_reply"`
+}
+
+func (m *flow) Reset()         { *m = flow{} }
+func (m *flow) String() string { return proto.CompactTextString(m) }
+func (*flow) ProtoMessage()    {}
+
+// endpoint is flow.Endpoint, the pod of a flow resolved by Hubble from the security identity and the address
+type endpoint struct {
+	Identity  uint32 `protobuf:"varint,2,opt,name=identity"`
+	Namespace string `protobuf:"bytes,3,opt,name=namespace"`
+	PodName   string `protobuf:"bytes,5,opt,name=pod_name"`
+}
+
+func (m *endpoint) Reset()         { *m = endpoint{} }
+func (m *endpoint) String() string { return proto.CompactTextString(m) }
+func (*endpoint) ProtoMessage()    {}
+
+// ip is flow.IP
+type ip struct {
+	Source      string `protobuf:"bytes,1,opt,name=source"`
+	Destination string `protobuf:"bytes,2,opt,name=destination"`
+}
+
+func (m *ip) Reset()         { *m = ip{} }
+func (m *ip) String() string { return proto.CompactTextString(m) }
+func (*ip) ProtoMessage()    {}
+
+// service is flow.Service
+type service struct {
+	Name      string `protobuf:"bytes,1,opt,name=name"`
+	Namespace string `protobuf:"bytes,2,opt,name=namespace"`
+}
+
+func (m *service) Reset()         { *m = service{} }
+func (m *service) String() string { return proto.CompactTextString(m) }
+func (*service) ProtoMessage()    {}
+
+// boolValue is google.protobuf.BoolValue
+type boolValue struct {
+	Value bool `protobuf:"varint,1,opt,name=value"`
+}
+
+func (m *boolValue) Reset()         { *m = boolValue{} }
+func (m *boolValue) String() string { return proto.CompactTextString(m) }
+func (*boolValue) ProtoMessage()    {}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hubble
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/grpc"
+
+	"github.com/vmware/purser/pkg/controller/discovery/linker"
+)
+
+const (
+	getFlowsMethod = "/observer.Observer/GetFlows"
+	// reconnectInterval is the wait before the flows are followed again once the stream broke
+	reconnectInterval = 30 * time.Second
+)
+
+// Settings of the Hubble integration. When the address of Hubble Relay (ex: hubble-relay.kube-system.svc:80) is set,
+// the interactions of pods are read from the flows observed by Cilium instead of the purser capture inside pods.
+type Settings struct {
+	Address string `json:"address,omitempty"`
+}
+
+var (
+	mu       sync.Mutex
+	settings Settings
+	// interactions observed since the last discovery
+	interactions = newInteractions()
+	dropped      float64
+)
+
+func newInteractions() *linker.InteractionsWrapper {
+	return &linker.InteractionsWrapper{
+		PodInteractions:        make(map[string](map[string]float64)),
+		PodServiceInteractions: make(map[string](map[string]float64)),
+	}
+}
+
+// Setup sets the address of Hubble Relay
+func Setup(s Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	settings = s
+}
+
+// Enabled returns true if the interactions of pods are read from Hubble
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return settings.Address != ""
+}
+
+// Watch follows the flows of Hubble Relay until ctx is done and counts the interactions of pods. The stream is
+// opened again after reconnectInterval when it breaks, flows of the interruption are missed.
+func Watch(ctx context.Context) {
+	if !Enabled() {
+		return
+	}
+	mu.Lock()
+	address := settings.Address
+	mu.Unlock()
+
+	for {
+		if err := follow(ctx, address); err != nil && ctx.Err() == nil {
+			log.Errorf("unable to follow hubble flows from: (%s), error: %v", address, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reconnectInterval):
+		}
+	}
+}
+
+func follow(ctx context.Context, address string) error {
+	conn, err := grpc.DialContext(ctx, address, grpc.WithInsecure())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, getFlowsMethod)
+	if err != nil {
+		return err
+	}
+	if err = stream.SendMsg(&getFlowsRequest{Follow: true, Since: ptypes.TimestampNow()}); err != nil {
+		return err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return err
+	}
+	log.Infof("following hubble flows from: (%s)", address)
+	for {
+		response := &getFlowsResponse{}
+		if err = stream.RecvMsg(response); err != nil {
+			return err
+		}
+		if response.Flow != nil {
+			record(response.Flow)
+		}
+	}
+}
+
+// record counts a flow as an interaction of its source pod. A connection between pods is observed at both ends, so
+// only the egress side is counted, and replies are left out so that a connection is counted once. The destination
+// is the pod which served the flow or the service when Hubble resolved no pod behind it.
+func record(f *flow) {
+	if f.TrafficDirection != trafficDirectionEgress || (f.IsReply != nil && f.IsReply.Value) {
+		return
+	}
+	source := podXID(f.Source)
+	if source == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if f.Verdict == verdictDropped {
+		dropped++
+		return
+	}
+	if f.Verdict != verdictForwarded {
+		return
+	}
+
+	if destination := podXID(f.Destination); destination != "" {
+		if _, ok := interactions.PodInteractions[source]; !ok {
+			interactions.PodInteractions[source] = make(map[string]float64)
+		}
+		interactions.PodInteractions[source][destination]++
+		return
+	}
+	if f.DestinationService != nil && f.DestinationService.Name != "" {
+		if _, ok := interactions.PodServiceInteractions[source]; !ok {
+			interactions.PodServiceInteractions[source] = make(map[string]float64)
+		}
+		interactions.PodServiceInteractions[source][f.DestinationService.Namespace+linker.KeySpliter+f.DestinationService.Name]++
+	}
+}
+
+// podXID returns the xid of the pod of the endpoint, empty for the endpoints of reserved identities (host, world,
+// remote nodes) which have no pod
+func podXID(e *endpoint) string {
+	if e == nil || e.PodName == "" || e.Namespace == "" {
+		return ""
+	}
+	return e.Namespace + linker.KeySpliter + e.PodName
+}
+
+// Drain returns the interactions of pods observed since the last call
+func Drain() *linker.InteractionsWrapper {
+	mu.Lock()
+	defer mu.Unlock()
+	drained := interactions
+	if dropped > 0 {
+		log.Infof("hubble flows dropped by network policies since the last discovery: %v", dropped)
+	}
+	interactions = newInteractions()
+	dropped = 0
+	return drained
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package hubble
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/timestamp"
+
+	"github.com/vmware/purser/pkg/controller/discovery/linker"
+	"github.com/vmware/purser/test/utils"
+)
+
+// testdata/flows.json holds flows recorded with hubble observe --output json. The recorded messages below have the
+// fields of the upstream protos found in the recording, most of which are not read by purser, so that the
+// recording is encoded as Hubble Relay sends it.
+
+// enumValues are the numbers of the names of the enum values found in the recording
+var enumValues = map[string]int32{
+	"FORWARDED": verdictForwarded, "DROPPED": verdictDropped,
+	"INGRESS": trafficDirectionIngress, "EGRESS": trafficDirectionEgress,
+	"IPv4": 1, "IPv6": 2,
+	"L3_L4": 1, "L7": 2,
+	"POLICY_DENIED":      133,
+	"HUBBLE_RING_BUFFER": 3,
+}
+
+type recordedResponse struct {
+	Flow       *recordedFlow        `protobuf:"bytes,1,opt,name=flow" json:"flow"`
+	LostEvents *recordedLostEvent   `protobuf:"bytes,3,opt,name=lost_events" json:"lost_events"`
+	NodeName   string               `protobuf:"bytes,1000,opt,name=node_name" json:"node_name"`
+	Time       *timestamp.Timestamp `protobuf:"bytes,1001,opt,name=time" json:"-"`
+	TimeText   string               `json:"time"`
+}
+
+func (m *recordedResponse) Reset()         { *m = recordedResponse{} }
+func (m *recordedResponse) String() string { return proto.CompactTextString(m) }
+func (*recordedResponse) ProtoMessage()    {}
+
+type recordedFlow struct {
+	Time                 *timestamp.Timestamp `protobuf:"bytes,1,opt,name=time" json:"-"`
+	TimeText             string               `json:"time"`
+	Verdict              int32                `protobuf:"varint,2,opt,name=verdict" json:"-"`
+	VerdictName          string               `json:"verdict"`
+	DropReason           uint32               `protobuf:"varint,3,opt,name=drop_reason" json:"drop_reason"`
+	IP                   *recordedIP          `protobuf:"bytes,5,opt,name=IP" json:"IP"`
+	L4                   *recordedLayer4      `protobuf:"bytes,6,opt,name=l4" json:"l4"`
+	Source               *recordedEndpoint    `protobuf:"bytes,8,opt,name=source" json:"source"`
+	Destination          *recordedEndpoint    `protobuf:"bytes,9,opt,name=destination" json:"destination"`
+	Type                 int32                `protobuf:"varint,10,opt,name=Type" json:"-"`
+	TypeName             string               `json:"Type"`
+	NodeName             string               `protobuf:"bytes,11,opt,name=node_name" json:"node_name"`
+	DestinationNames     []string             `protobuf:"bytes,14,rep,name=destination_names" json:"destination_names"`
+	DestinationService   *service             `protobuf:"bytes,21,opt,name=destination_service" json:"destination_service"`
+	TrafficDirection     int32                `protobuf:"varint,22,opt,name=traffic_direction" json:"-"`
+	TrafficDirectionName string               `json:"traffic_direction"`
+	DropReasonDesc       int32                `protobuf:"varint,25,opt,name=drop_reason_desc" json:"-"`
+	DropReasonDescName   string               `json:"drop_reason_desc"`
+	IsReply              *boolValue           `protobuf:"bytes,26,opt,name=is_reply" json:"-"`
+	Reply                *bool                `json:"is_reply"`
+	Summary              string               `protobuf:"bytes,100000,opt,name=Summary" json:"Summary"`
+}
+
+func (m *recordedFlow) Reset()         { *m = recordedFlow{} }
+func (m *recordedFlow) String() string { return proto.CompactTextString(m) }
+func (*recordedFlow) ProtoMessage()    {}
+
+type recordedIP struct {
+	Source        string `protobuf:"bytes,1,opt,name=source" json:"source"`
+	Destination   string `protobuf:"bytes,2,opt,name=destination" json:"destination"`
+	IPVersion     int32  `protobuf:"varint,3,opt,name=ipVersion" json:"-"`
+	IPVersionName string `json:"ipVersion"`
+}
+
+func (m *recordedIP) Reset()         { *m = recordedIP{} }
+func (m *recordedIP) String() string { return proto.CompactTextString(m) }
+func (*recordedIP) ProtoMessage()    {}
+
+type recordedLayer4 struct {
+	TCP *recordedTCP `protobuf:"bytes,1,opt,name=TCP" json:"TCP"`
+}
+
+func (m *recordedLayer4) Reset()         { *m = recordedLayer4{} }
+func (m *recordedLayer4) String() string { return proto.CompactTextString(m) }
+func (*recordedLayer4) ProtoMessage()    {}
+
+type recordedTCP struct {
+	SourcePort      uint32            `protobuf:"varint,1,opt,name=source_port" json:"source_port"`
+	DestinationPort uint32            `protobuf:"varint,2,opt,name=destination_port" json:"destination_port"`
+	Flags           *recordedTCPFlags `protobuf:"bytes,3,opt,name=flags" json:"flags"`
+}
+
+func (m *recordedTCP) Reset()         { *m = recordedTCP{} }
+func (m *recordedTCP) String() string { return proto.CompactTextString(m) }
+func (*recordedTCP) ProtoMessage()    {}
+
+type recordedTCPFlags struct {
+	SYN bool `protobuf:"varint,2,opt,name=SYN" json:"SYN"`
+	ACK bool `protobuf:"varint,5,opt,name=ACK" json:"ACK"`
+}
+
+func (m *recordedTCPFlags) Reset()         { *m = recordedTCPFlags{} }
+func (m *recordedTCPFlags) String() string { return proto.CompactTextString(m) }
+func (*recordedTCPFlags) ProtoMessage()    {}
+
+type recordedEndpoint struct {
+	ID        uint32   `protobuf:"varint,1,opt,name=ID" json:"ID"`
+	Identity  uint32   `protobuf:"varint,2,opt,name=identity" json:"identity"`
+	Namespace string   `protobuf:"bytes,3,opt,name=namespace" json:"namespace"`
+	Labels    []string `protobuf:"bytes,4,rep,name=labels" json:"labels"`
+	PodName   string   `protobuf:"bytes,5,opt,name=pod_name" json:"pod_name"`
+}
+
+func (m *recordedEndpoint) Reset()         { *m = recordedEndpoint{} }
+func (m *recordedEndpoint) String() string { return proto.CompactTextString(m) }
+func (*recordedEndpoint) ProtoMessage()    {}
+
+type recordedLostEvent struct {
+	Source        int32  `protobuf:"varint,1,opt,name=source" json:"-"`
+	SourceName    string `json:"source"`
+	NumEventsLost uint64 `protobuf:"varint,2,opt,name=num_events_lost" json:"num_events_lost"`
+}
+
+func (m *recordedLostEvent) Reset()         { *m = recordedLostEvent{} }
+func (m *recordedLostEvent) String() string { return proto.CompactTextString(m) }
+func (*recordedLostEvent) ProtoMessage()    {}
+
+func recordedTime(t *testing.T, text string) *timestamp.Timestamp {
+	parsed, err := time.Parse(time.RFC3339Nano, text)
+	utils.Ok(t, err)
+	ts, err := ptypes.TimestampProto(parsed)
+	utils.Ok(t, err)
+	return ts
+}
+
+// encode sets the fields which are numbers in the protos from their names in the recording
+func (m *recordedResponse) encode(t *testing.T) []byte {
+	m.Time = recordedTime(t, m.TimeText)
+	if m.LostEvents != nil {
+		m.LostEvents.Source = enumValues[m.LostEvents.SourceName]
+	}
+	if f := m.Flow; f != nil {
+		f.Time = recordedTime(t, f.TimeText)
+		f.Verdict = enumValues[f.VerdictName]
+		f.Type = enumValues[f.TypeName]
+		f.TrafficDirection = enumValues[f.TrafficDirectionName]
+		f.DropReasonDesc = enumValues[f.DropReasonDescName]
+		f.IP.IPVersion = enumValues[f.IP.IPVersionName]
+		if f.Reply != nil {
+			f.IsReply = &boolValue{Value: *f.Reply}
+		}
+	}
+	data, err := proto.Marshal(m)
+	utils.Ok(t, err)
+	return data
+}
+
+// recordedResponses decodes the recorded flows as they are received from Hubble Relay
+func recordedResponses(t *testing.T) []*getFlowsResponse {
+	file, err := os.Open("testdata/flows.json")
+	utils.Ok(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var responses []*getFlowsResponse
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		recorded := &recordedResponse{}
+		utils.Ok(t, json.Unmarshal(scanner.Bytes(), recorded))
+		response := &getFlowsResponse{}
+		utils.Ok(t, proto.Unmarshal(recorded.encode(t), response))
+		responses = append(responses, response)
+	}
+	utils.Ok(t, scanner.Err())
+	return responses
+}
+
+func TestDecodeRecordedFlows(t *testing.T) {
+	responses := recordedResponses(t)
+	utils.Equals(t, 9, len(responses))
+
+	utils.Equals(t, &flow{
+		Verdict:            verdictForwarded,
+		IP:                 &ip{Source: "10.0.1.15", Destination: "10.0.2.20"},
+		Source:             &endpoint{Identity: 20113, Namespace: "shop", PodName: "cart-7c9b-x2z"},
+		Destination:        &endpoint{Identity: 31977, Namespace: "shop", PodName: "payments-5d8f-k4q"},
+		DestinationService: &service{Name: "payments", Namespace: "shop"},
+		TrafficDirection:   trafficDirectionEgress,
+		IsReply:            &boolValue{},
+	}, responses[0].Flow)
+	utils.Equals(t, &endpoint{Identity: 2}, responses[3].Flow.Destination)
+	utils.Equals(t, int32(verdictDropped), responses[5].Flow.Verdict)
+	// the dropped flow has no is_reply
+	utils.Assert(t, responses[5].Flow.IsReply == nil, "expected no is_reply on a dropped flow")
+	// lost events have no flow
+	utils.Assert(t, responses[7].Flow == nil, "expected no flow in a lost events response")
+}
+
+func TestRecordRecordedFlows(t *testing.T) {
+	Drain()
+	defer Drain()
+
+	for _, response := range recordedResponses(t) {
+		if response.Flow != nil {
+			record(response.Flow)
+		}
+	}
+	utils.Equals(t, 1.0, dropped)
+
+	// the ingress side and the reply of the connections are not counted, neither are the connections from the
+	// node and to the world, and the pod behind a service wins over the service
+	drained := Drain()
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:cart-7c9b-x2z": {"shop:payments-5d8f-k4q": 2},
+	}, drained.PodInteractions)
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:cart-7c9b-x2z": {"shop:db": 1},
+	}, drained.PodServiceInteractions)
+
+	utils.Equals(t, 0.0, dropped)
+	utils.Equals(t, &linker.InteractionsWrapper{
+		PodInteractions:        map[string](map[string]float64){},
+		PodServiceInteractions: map[string](map[string]float64){},
+	}, Drain())
+}
@@ -18,27 +18,34 @@
 package linker
 
 import (
-	"strings"
+	"net"
+	"strconv"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/utils"
 )
 
 // InteractionsWrapper ...
 type InteractionsWrapper struct {
 	PodInteractions             map[string](map[string]float64)
+	PodServiceInteractions      map[string](map[string]float64)
 	ProcessToPodInteraction     map[string](map[string]bool)
 	ContainerProcessInteraction map[string][]string
 }
 
-// podIPTable: maps pod name with pod IP address
+// podIPTable: maps pod IP addresses (IPv4 and IPv6 of dual-stack pods) with pod name
+// hostPortTable: maps node IP and port with the name of the hostNetwork or hostPort pod listening on it
+// hostNetworkPods: ports of the hostNetwork pods, which share the IPs of their node
 // podToPodTable: maps src pod to the interacting dest pod along with the interaction frequency count.
 var (
-	podIPTable    = make(map[string]string)
-	podToPodTable = make(map[string](map[string]float64))
+	podIPTable      = make(map[string]string)
+	hostPortTable   = make(map[string]string)
+	hostNetworkPods = make(map[string][]int)
+	podToPodTable   = make(map[string](map[string]float64))
 )
 
 var (
@@ -50,12 +57,94 @@ const (
 	KeySpliter = ":"
 )
 
-// PopulatePodIPTable populates the podIP<->podName map
+// PopulatePodIPTable populates the podIP<->podName map. The IP of a hostNetwork pod is the IP of its node, shared
+// with the other hostNetwork pods of the node, so these pods are mapped by node IP and port instead. So are the host
+// ports of the other pods, which are forwarded to the pod from the node IP.
 func PopulatePodIPTable(pods *corev1.PodList) {
 	for _, pod := range pods.Items {
-		podIP := pod.Status.PodIP
-		podIPTable[podIP] = pod.Namespace + KeySpliter + pod.Name
+		podXID := pod.Namespace + KeySpliter + pod.Name
+		if pod.Spec.HostNetwork {
+			ports := hostNetworkPorts(pod)
+			hostNetworkPods[podXID] = ports
+			for _, port := range ports {
+				addHostPort(pod.Status.HostIP, port, podXID)
+			}
+			continue
+		}
+		addPodIP(pod.Status.PodIP, podXID)
+		for _, port := range hostPorts(pod) {
+			addHostPort(pod.Status.HostIP, port, podXID)
+		}
+	}
+}
+
+// PopulateDualStackPodIPTable adds all the addresses of dual-stack pods to the podIP<->podName map. The vendored
+// kubernetes api only knows the primary pod IP, so they are read from the raw pods.
+func PopulateDualStackPodIPTable(pods []models.RawPod) {
+	for _, pod := range pods {
+		podXID := pod.Metadata.Namespace + KeySpliter + pod.Metadata.Name
+		ports, isHostNetwork := hostNetworkPods[podXID]
+		for _, podIP := range pod.Status.PodIPs {
+			if !isHostNetwork {
+				addPodIP(podIP.IP, podXID)
+				continue
+			}
+			for _, port := range ports {
+				addHostPort(podIP.IP, port, podXID)
+			}
+		}
+	}
+}
+
+// hostNetworkPorts returns the tcp ports of the containers of a hostNetwork pod, they are opened on the node
+func hostNetworkPorts(pod corev1.Pod) []int {
+	var ports []int
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Protocol == "" || port.Protocol == corev1.ProtocolTCP {
+				ports = append(ports, int(port.ContainerPort))
+			}
+		}
+	}
+	return ports
+}
+
+// hostPorts returns the tcp host ports of the containers of the pod
+func hostPorts(pod corev1.Pod) []int {
+	var ports []int
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.HostPort != 0 && (port.Protocol == "" || port.Protocol == corev1.ProtocolTCP) {
+				ports = append(ports, int(port.HostPort))
+			}
+		}
+	}
+	return ports
+}
+
+// addPodIP stores the IP in its canonical form, which is the form of the IPs read from the tcp tables
+func addPodIP(podIP, podXID string) {
+	ip := net.ParseIP(podIP)
+	if ip == nil {
+		return
+	}
+	podIPTable[ip.String()] = podXID
+}
+
+func addHostPort(hostIP string, port int, podXID string) {
+	ip := net.ParseIP(hostIP)
+	if ip == nil {
+		return
 	}
+	hostPortTable[net.JoinHostPort(ip.String(), strconv.Itoa(port))] = podXID
+}
+
+// podOfAddress returns the name of the pod having the IP, or listening on the port of a node IP
+func podOfAddress(ip string, port int) string {
+	if podXID, ok := hostPortTable[net.JoinHostPort(ip, strconv.Itoa(port))]; ok {
+		return podXID
+	}
+	return podIPTable[ip]
 }
 
 // GenerateAndStorePodInteractions generates source to destination Pod mapping and stores it in Dgraph.
@@ -73,36 +162,69 @@ func GenerateAndStorePodInteractions() {
 			log.Errorf("failed to store pod interaction in Dgraph %v", err)
 		}
 	}
+	for srcPodName, communication := range podToExternalSvcTable {
+		dstServices := []string{}
+		counts := []float64{}
+		for dstServiceName, count := range communication {
+			dstServices = append(dstServices, dstServiceName)
+			counts = append(counts, count)
+		}
+		err := models.StorePodServiceInteractions(srcPodName, dstServices, counts)
+		if err != nil {
+			log.Errorf("failed to store pod service interaction in Dgraph %v", err)
+		}
+	}
 	log.Info("Finished storing pod interactions.")
 }
 
 // PopulateMappingTables updates PodToPodTable
-func PopulateMappingTables(tcpDump []string, pod corev1.Pod, process Process, containerName string, interactions *InteractionsWrapper) {
+func PopulateMappingTables(tcpDump []utils.TCPConnection, pod corev1.Pod, process Process, containerName string, interactions *InteractionsWrapper) {
 	podXID := pod.Namespace + KeySpliter + pod.Name
 	containerXID := podXID + KeySpliter + containerName
 	procXID := containerXID + KeySpliter + process.ID + KeySpliter + process.Name
 	populateContainerProcessTable(containerXID, procXID, interactions)
-	for _, address := range tcpDump {
-		address := strings.Split(address, KeySpliter)
-		srcIP, dstIP := address[0], address[2]
-		srcName, dstName := podIPTable[srcIP], podIPTable[dstIP]
-		updatePodInteractions(srcName, dstName, interactions)
-		updatePodProcessInteractions(procXID, dstName, interactions)
+	for _, connection := range tcpDump {
+		srcName, dstName := podOfAddress(connection.LocalIP, connection.LocalPort), podOfAddress(connection.RemoteIP, connection.RemotePort)
+		if dstName != "" {
+			updatePodInteractions(srcName, dstName, 1, interactions)
+			updatePodProcessInteractions(procXID, dstName, interactions)
+			continue
+		}
+
+		serviceXID := serviceOfAddress(connection.RemoteIP, connection.RemotePort)
+		if serviceXID == "" {
+			continue
+		}
+		backingPods := servicePodsTable[serviceXID]
+		if len(backingPods) == 0 {
+			updatePodServiceInteractions(srcName, serviceXID, interactions)
+			continue
+		}
+		// the pod which served the connection is not known, so the interaction is shared by the pods of the service
+		for _, podXID := range backingPods {
+			updatePodInteractions(srcName, podXID, 1/float64(len(backingPods)), interactions)
+			updatePodProcessInteractions(procXID, podXID, interactions)
+		}
 	}
 }
 
-func updatePodInteractions(srcName, dstName string, interactions *InteractionsWrapper) {
+func updatePodInteractions(srcName, dstName string, count float64, interactions *InteractionsWrapper) {
 	if dstName != "" && srcName != "" {
 		log.Debugf("pod interactions srcName: (%s), dstName: (%s)", srcName, dstName)
 		if _, ok := interactions.PodInteractions[srcName]; !ok {
 			interactions.PodInteractions[srcName] = make(map[string]float64)
 		}
+		interactions.PodInteractions[srcName][dstName] += count
+	}
+}
 
-		if _, isPresent := interactions.PodInteractions[srcName][dstName]; !isPresent {
-			interactions.PodInteractions[srcName][dstName] = 1
-		} else {
-			interactions.PodInteractions[srcName][dstName]++
+func updatePodServiceInteractions(srcName, serviceXID string, interactions *InteractionsWrapper) {
+	if srcName != "" {
+		log.Debugf("pod service interactions srcName: (%s), service: (%s)", srcName, serviceXID)
+		if _, ok := interactions.PodServiceInteractions[srcName]; !ok {
+			interactions.PodServiceInteractions[srcName] = make(map[string]float64)
 		}
+		interactions.PodServiceInteractions[srcName][serviceXID]++
 	}
 }
 
@@ -124,3 +246,17 @@ func UpdatePodToPodTable(podInteractions map[string](map[string]float64)) {
 	}
 	mu.Unlock()
 }
+
+// UpdatePodToServiceTable adds the interactions of pods with services without pods
+func UpdatePodToServiceTable(podServiceInteractions map[string](map[string]float64)) {
+	mu.Lock()
+	for srcPod, interaction := range podServiceInteractions {
+		if _, ok := podToExternalSvcTable[srcPod]; !ok {
+			podToExternalSvcTable[srcPod] = make(map[string]float64)
+		}
+		for service, count := range interaction {
+			podToExternalSvcTable[srcPod][service] += count
+		}
+	}
+	mu.Unlock()
+}
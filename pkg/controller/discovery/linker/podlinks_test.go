@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package linker
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	controllerutils "github.com/vmware/purser/pkg/controller/utils"
+	"github.com/vmware/purser/test/utils"
+)
+
+// resetAddressTables empties the tables which resolve addresses to pods and services
+func resetAddressTables() {
+	podIPTable = make(map[string]string)
+	hostPortTable = make(map[string]string)
+	hostNetworkPods = make(map[string][]int)
+	serviceAddressTable = make(map[string]string)
+	externalServiceTable = make(map[string]string)
+	servicePodsTable = make(map[string][]string)
+}
+
+func newInteractions() *InteractionsWrapper {
+	return &InteractionsWrapper{
+		PodInteractions:             make(map[string](map[string]float64)),
+		PodServiceInteractions:      make(map[string](map[string]float64)),
+		ProcessToPodInteraction:     make(map[string](map[string]bool)),
+		ContainerProcessInteraction: make(map[string][]string),
+	}
+}
+
+func newNetworkPod(name, podIP, hostIP string, hostNetwork bool, ports ...corev1.ContainerPort) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+		Spec: corev1.PodSpec{
+			HostNetwork: hostNetwork,
+			Containers:  []corev1.Container{{Name: name, Ports: ports}},
+		},
+		Status: corev1.PodStatus{PodIP: podIP, HostIP: hostIP},
+	}
+}
+
+// newNodePods returns two hostNetwork pods sharing the IP of their node, a pod with a host port on the same node
+// and a pod of the pod network
+func newNodePods() *corev1.PodList {
+	return &corev1.PodList{Items: []corev1.Pod{
+		newNetworkPod("node-exporter", "10.0.0.1", "10.0.0.1", true, corev1.ContainerPort{ContainerPort: 9100}),
+		newNetworkPod("kube-proxy", "10.0.0.1", "10.0.0.1", true,
+			corev1.ContainerPort{ContainerPort: 10256, Protocol: corev1.ProtocolTCP},
+			corev1.ContainerPort{ContainerPort: 53, Protocol: corev1.ProtocolUDP}),
+		newNetworkPod("ingress", "10.1.0.5", "10.0.0.1", false, corev1.ContainerPort{ContainerPort: 8443, HostPort: 443}),
+		newNetworkPod("prometheus", "10.1.0.6", "10.0.0.1", false, corev1.ContainerPort{ContainerPort: 9090}),
+	}}
+}
+
+func TestPopulatePodIPTable(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+
+	PopulatePodIPTable(newNodePods())
+	utils.Equals(t, map[string]string{"10.1.0.5": "shop:ingress", "10.1.0.6": "shop:prometheus"}, podIPTable)
+	utils.Equals(t, map[string]string{
+		"10.0.0.1:9100":  "shop:node-exporter",
+		"10.0.0.1:10256": "shop:kube-proxy",
+		"10.0.0.1:443":   "shop:ingress",
+	}, hostPortTable)
+}
+
+func TestPodOfAddress(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+
+	PopulatePodIPTable(newNodePods())
+	utils.Equals(t, "shop:node-exporter", podOfAddress("10.0.0.1", 9100))
+	utils.Equals(t, "shop:kube-proxy", podOfAddress("10.0.0.1", 10256))
+	utils.Equals(t, "shop:ingress", podOfAddress("10.0.0.1", 443))
+	utils.Equals(t, "shop:ingress", podOfAddress("10.1.0.5", 8443))
+	// the node IP alone doesn't identify any of the hostNetwork pods of the node
+	utils.Equals(t, "", podOfAddress("10.0.0.1", 22))
+	utils.Equals(t, "", podOfAddress("10.0.0.1", 53))
+}
+
+func TestPopulateDualStackHostNetworkPods(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+
+	PopulatePodIPTable(newNodePods())
+	exporter, prometheus := models.RawPod{}, models.RawPod{}
+	exporter.Metadata = metav1.ObjectMeta{Namespace: "shop", Name: "node-exporter"}
+	exporter.Status.PodIPs = []models.PodIP{{IP: "10.0.0.1"}, {IP: "fd00::1"}}
+	prometheus.Metadata = metav1.ObjectMeta{Namespace: "shop", Name: "prometheus"}
+	prometheus.Status.PodIPs = []models.PodIP{{IP: "10.1.0.6"}, {IP: "fd00:0:0:1::6"}}
+	PopulateDualStackPodIPTable([]models.RawPod{exporter, prometheus})
+
+	utils.Equals(t, "shop:node-exporter", podOfAddress("fd00::1", 9100))
+	utils.Equals(t, "", podOfAddress("fd00::1", 10256))
+	utils.Equals(t, "shop:prometheus", podOfAddress("fd00:0:0:1::6", 40000))
+}
+
+func TestPopulateMappingTablesWithHostNetworkPods(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+
+	pods := newNodePods()
+	PopulatePodIPTable(pods)
+	interactions := newInteractions()
+	tcpDump := []controllerutils.TCPConnection{
+		{LocalIP: "10.1.0.6", LocalPort: 41000, RemoteIP: "10.0.0.1", RemotePort: 9100},
+		{LocalIP: "10.1.0.6", LocalPort: 41001, RemoteIP: "10.0.0.1", RemotePort: 10256},
+		{LocalIP: "10.1.0.6", LocalPort: 41002, RemoteIP: "10.0.0.1", RemotePort: 443},
+		{LocalIP: "10.1.0.6", LocalPort: 41003, RemoteIP: "10.0.0.1", RemotePort: 22},
+	}
+	PopulateMappingTables(tcpDump, pods.Items[3], Process{ID: "1", Name: "prometheus"}, "prometheus", interactions)
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:prometheus": {"shop:node-exporter": 1, "shop:kube-proxy": 1, "shop:ingress": 1},
+	}, interactions.PodInteractions)
+
+	// a connection accepted by a hostNetwork pod is attributed to the pod listening on the port, an outgoing
+	// connection from an ephemeral port of the node is not attributed to any of the pods of the node
+	interactions = newInteractions()
+	tcpDump = []controllerutils.TCPConnection{
+		{LocalIP: "10.0.0.1", LocalPort: 9100, RemoteIP: "10.1.0.6", RemotePort: 41000},
+		{LocalIP: "10.0.0.1", LocalPort: 52000, RemoteIP: "10.1.0.6", RemotePort: 9090},
+	}
+	PopulateMappingTables(tcpDump, pods.Items[0], Process{ID: "1", Name: "node_exporter"}, "node-exporter", interactions)
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:node-exporter": {"shop:prometheus": 1},
+	}, interactions.PodInteractions)
+}
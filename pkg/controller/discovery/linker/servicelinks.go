@@ -18,19 +18,137 @@
 package linker
 
 import (
+	"net"
+	"strconv"
 	"sync"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/vmware/purser/pkg/controller/dgraph/models"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
+// serviceAddressTable: maps cluster IP, load balancer IP and node IP with port to the service name
+// externalServiceTable: maps IPs the external name of an ExternalName service resolves to with the service name
+// servicePodsTable: maps service name to the names of the pods selected by the service
+// podToExternalSvcTable: maps src pod to the interacting services which have no pods, with the interaction count
 var (
-	podToSvcTable = make(map[string][]string)
-	serviceMu     sync.Mutex
+	podToSvcTable         = make(map[string][]string)
+	serviceAddressTable   = make(map[string]string)
+	externalServiceTable  = make(map[string]string)
+	servicePodsTable      = make(map[string][]string)
+	podToExternalSvcTable = make(map[string](map[string]float64))
+	serviceMu             sync.Mutex
+	// lookupHost resolves the external names of the ExternalName services
+	lookupHost = net.LookupHost
 )
 
+// PopulateServiceAddressTable maps the addresses of the services to the services, so that connections to cluster
+// IPs, node ports, load balancers and external names are resolved to the pods of the service, or to the service
+// itself when it has no pods.
+func PopulateServiceAddressTable(services *corev1.ServiceList, nodes *corev1.NodeList, pods *corev1.PodList) {
+	if services == nil {
+		return
+	}
+	addresses := make(map[string]string)
+	external := make(map[string]string)
+	servicePods := make(map[string][]string)
+	nodeIPs := nodeAddresses(nodes)
+	for _, svc := range services.Items {
+		serviceXID := svc.Namespace + KeySpliter + svc.Name
+		if svc.Spec.Type == corev1.ServiceTypeExternalName {
+			for _, ip := range resolveExternalName(svc.Spec.ExternalName) {
+				external[ip] = serviceXID
+			}
+			continue
+		}
+		servicePods[serviceXID] = selectPods(svc, pods)
+		for _, port := range svc.Spec.Ports {
+			if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+				continue
+			}
+			addServiceAddress(addresses, svc.Spec.ClusterIP, int(port.Port), serviceXID)
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				addServiceAddress(addresses, ingress.IP, int(port.Port), serviceXID)
+			}
+			if port.NodePort == 0 {
+				continue
+			}
+			for _, nodeIP := range nodeIPs {
+				addServiceAddress(addresses, nodeIP, int(port.NodePort), serviceXID)
+			}
+		}
+	}
+	serviceAddressTable, externalServiceTable, servicePodsTable = addresses, external, servicePods
+}
+
+// serviceOfAddress returns the name of the service listening on the IP and port
+func serviceOfAddress(ip string, port int) string {
+	if serviceXID, ok := serviceAddressTable[net.JoinHostPort(ip, strconv.Itoa(port))]; ok {
+		return serviceXID
+	}
+	return externalServiceTable[ip]
+}
+
+func addServiceAddress(addresses map[string]string, address string, port int, serviceXID string) {
+	// headless services have no cluster IP (None)
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return
+	}
+	addresses[net.JoinHostPort(ip.String(), strconv.Itoa(port))] = serviceXID
+}
+
+func nodeAddresses(nodes *corev1.NodeList) []string {
+	var ips []string
+	if nodes == nil {
+		return ips
+	}
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if ip := net.ParseIP(address.Address); ip != nil {
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+	return ips
+}
+
+// resolveExternalName returns the IPs of the external name, as resolved by the controller
+func resolveExternalName(externalName string) []string {
+	if ip := net.ParseIP(externalName); ip != nil {
+		return []string{ip.String()}
+	}
+	addresses, err := lookupHost(externalName)
+	if err != nil {
+		log.Debugf("unable to resolve external name: %s, error: %v", externalName, err)
+		return nil
+	}
+	var ips []string
+	for _, address := range addresses {
+		if ip := net.ParseIP(address); ip != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
+
+// selectPods returns the names of the pods of the namespace of the service matching its selector
+func selectPods(svc corev1.Service, pods *corev1.PodList) []string {
+	if len(svc.Spec.Selector) == 0 || pods == nil {
+		return nil
+	}
+	selector := labels.SelectorFromSet(labels.Set(svc.Spec.Selector))
+	var podsXIDs []string
+	for _, pod := range pods.Items {
+		if pod.Namespace == svc.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+			podsXIDs = append(podsXIDs, pod.Namespace+KeySpliter+pod.Name)
+		}
+	}
+	return podsXIDs
+}
+
 // PopulatePodToServiceTable populates the pod<->service map
 func PopulatePodToServiceTable(svc corev1.Service, pods *corev1.PodList) {
 	var podsXIDsInService []string
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package linker
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controllerutils "github.com/vmware/purser/pkg/controller/utils"
+	"github.com/vmware/purser/test/utils"
+)
+
+func newService(name string, spec corev1.ServiceSpec, lbIPs ...string) corev1.Service {
+	svc := corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name}, Spec: spec}
+	for _, ip := range lbIPs {
+		svc.Status.LoadBalancer.Ingress = append(svc.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: ip})
+	}
+	return svc
+}
+
+func stubLookupHost() func() {
+	original := lookupHost
+	lookupHost = func(host string) ([]string, error) {
+		if host == "payments.example.com" {
+			return []string{"198.51.100.1", "2001:db8:0::1"}, nil
+		}
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+	return func() { lookupHost = original }
+}
+
+// populateServices maps a node port service backed by two pods, a load balancer without pods, ExternalName
+// services and a headless service
+func populateServices() *corev1.PodList {
+	pods := newNodePods()
+	for i, name := range []string{"web-1", "web-2"} {
+		pod := newNetworkPod(name, fmt.Sprintf("10.1.1.%d", i+1), "10.0.0.2", false, corev1.ContainerPort{ContainerPort: 8080})
+		pod.Labels = map[string]string{"app": "web"}
+		pods.Items = append(pods.Items, pod)
+	}
+	services := &corev1.ServiceList{Items: []corev1.Service{
+		newService("web", corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeNodePort,
+			ClusterIP: "10.96.0.10",
+			Selector:  map[string]string{"app": "web"},
+			Ports: []corev1.ServicePort{
+				{Port: 80, NodePort: 30080},
+				{Port: 53, NodePort: 30053, Protocol: corev1.ProtocolUDP},
+			},
+		}),
+		newService("lb", corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeLoadBalancer,
+			ClusterIP: "10.96.0.11",
+			Selector:  map[string]string{"app": "lb"},
+			Ports:     []corev1.ServicePort{{Port: 443, NodePort: 30443}},
+		}, "203.0.113.7"),
+		newService("payments", corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "payments.example.com"}),
+		newService("db", corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "192.0.2.5"}),
+		newService("unknown", corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "unknown.example.com"}),
+		newService("headless", corev1.ServiceSpec{ClusterIP: "None", Ports: []corev1.ServicePort{{Port: 5432}}}),
+	}}
+	nodes := &corev1.NodeList{Items: []corev1.Node{
+		{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: "10.0.0.1"}, {Address: "node-1"}}}},
+		{Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Address: "10.0.0.2"}}}},
+	}}
+	PopulatePodIPTable(pods)
+	PopulateServiceAddressTable(services, nodes, pods)
+	return pods
+}
+
+func TestPopulateServiceAddressTable(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+	defer stubLookupHost()()
+
+	populateServices()
+	utils.Equals(t, map[string]string{
+		"10.96.0.10:80":   "shop:web",
+		"10.0.0.1:30080":  "shop:web",
+		"10.0.0.2:30080":  "shop:web",
+		"10.96.0.11:443":  "shop:lb",
+		"203.0.113.7:443": "shop:lb",
+		"10.0.0.1:30443":  "shop:lb",
+		"10.0.0.2:30443":  "shop:lb",
+	}, serviceAddressTable)
+	utils.Equals(t, map[string]string{
+		"198.51.100.1": "shop:payments",
+		"2001:db8::1":  "shop:payments",
+		"192.0.2.5":    "shop:db",
+	}, externalServiceTable)
+	utils.Equals(t, []string{"shop:web-1", "shop:web-2"}, servicePodsTable["shop:web"])
+	utils.Equals(t, 0, len(servicePodsTable["shop:lb"]))
+}
+
+func TestServiceOfAddress(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+	defer stubLookupHost()()
+
+	populateServices()
+	utils.Equals(t, "shop:web", serviceOfAddress("10.0.0.2", 30080))
+	utils.Equals(t, "", serviceOfAddress("10.0.0.2", 30053))
+	utils.Equals(t, "", serviceOfAddress("10.0.0.2", 80))
+	// any port of the IPs of an external name belongs to the service
+	utils.Equals(t, "shop:payments", serviceOfAddress("198.51.100.1", 443))
+	utils.Equals(t, "shop:payments", serviceOfAddress("2001:db8::1", 8443))
+	utils.Equals(t, "shop:db", serviceOfAddress("192.0.2.5", 5432))
+	utils.Equals(t, "", serviceOfAddress("192.0.2.6", 5432))
+}
+
+func TestPopulateMappingTablesWithServices(t *testing.T) {
+	resetAddressTables()
+	defer resetAddressTables()
+	defer stubLookupHost()()
+
+	pods := populateServices()
+	interactions := newInteractions()
+	tcpDump := []controllerutils.TCPConnection{
+		{LocalIP: "10.1.0.6", LocalPort: 41000, RemoteIP: "10.0.0.1", RemotePort: 30080},
+		{LocalIP: "10.1.0.6", LocalPort: 41001, RemoteIP: "10.96.0.10", RemotePort: 80},
+		{LocalIP: "10.1.0.6", LocalPort: 41002, RemoteIP: "203.0.113.7", RemotePort: 443},
+		{LocalIP: "10.1.0.6", LocalPort: 41003, RemoteIP: "198.51.100.1", RemotePort: 443},
+		{LocalIP: "10.1.0.6", LocalPort: 41004, RemoteIP: "192.0.2.5", RemotePort: 5432},
+		{LocalIP: "10.1.0.6", LocalPort: 41005, RemoteIP: "192.0.2.6", RemotePort: 5432},
+	}
+	PopulateMappingTables(tcpDump, pods.Items[3], Process{ID: "1", Name: "prometheus"}, "prometheus", interactions)
+
+	// the connections to the node port and to the cluster IP are shared by the pods of the service
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:prometheus": {"shop:web-1": 1, "shop:web-2": 1},
+	}, interactions.PodInteractions)
+	utils.Equals(t, map[string](map[string]float64){
+		"shop:prometheus": {"shop:lb": 1, "shop:payments": 1, "shop:db": 1},
+	}, interactions.PodServiceInteractions)
+	utils.Equals(t, map[string]bool{"shop:web-1": true, "shop:web-2": true}, interactions.ProcessToPodInteraction["shop:prometheus:prometheus:1:prometheus"])
+}
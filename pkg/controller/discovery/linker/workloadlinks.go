@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package linker
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// Kinds of the owners the pod interactions are rolled up to
+const (
+	ServiceOwner     = "service"
+	DeploymentOwner  = "deployment"
+	StatefulsetOwner = "statefulset"
+	DaemonsetOwner   = "daemonset"
+	JobOwner         = "job"
+)
+
+// workload is the owner of a pod, a service selecting it or else the workload which created it
+type workload struct {
+	kind string
+	xid  string
+}
+
+// workloadEdge is the interaction of the pods of a source workload with the pods (or the service) of a destination
+type workloadEdge struct {
+	source      workload
+	destination workload
+}
+
+// podOwnerTable: maps pod name with its owner, the pods which are gone are kept until their interactions are rolled up
+// aggregatedPodTable: the counts of podToPodTable which were already rolled up to the owners
+// aggregatedExternalSvcTable: the counts of podToExternalSvcTable which were already rolled up to the owners
+var (
+	podOwnerTable              = make(map[string]workload)
+	aggregatedPodTable         = make(map[string](map[string]float64))
+	aggregatedExternalSvcTable = make(map[string](map[string]float64))
+)
+
+// PopulatePodOwnerTable maps the pods with their owner. A pod selected by services is owned by the first of them
+// (by name), otherwise by the deployment, statefulset, daemonset or job which created it. Bare pods have no owner.
+// It must be called after PopulateServiceAddressTable.
+func PopulatePodOwnerTable(pods *corev1.PodList) {
+	if pods == nil {
+		return
+	}
+	podServices := make(map[string]string)
+	for serviceXID, podXIDs := range servicePodsTable {
+		for _, podXID := range podXIDs {
+			if current, ok := podServices[podXID]; !ok || serviceXID < current {
+				podServices[podXID] = serviceXID
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, pod := range pods.Items {
+		podXID := pod.Namespace + KeySpliter + pod.Name
+		if serviceXID, ok := podServices[podXID]; ok {
+			podOwnerTable[podXID] = workload{kind: ServiceOwner, xid: serviceXID}
+			continue
+		}
+		if owner, ok := podWorkload(pod); ok {
+			podOwnerTable[podXID] = owner
+		}
+	}
+}
+
+// podWorkload returns the workload which created the pod. The deployment of a pod is the owner of its replicaset,
+// whose name is the name of the deployment followed by the pod template hash.
+func podWorkload(pod corev1.Pod) (workload, bool) {
+	for _, owner := range pod.GetObjectMeta().GetOwnerReferences() {
+		ownerXID := pod.Namespace + KeySpliter + owner.Name
+		switch owner.Kind {
+		case "ReplicaSet":
+			hash := pod.Labels["pod-template-hash"]
+			if hash == "" || !strings.HasSuffix(owner.Name, "-"+hash) {
+				continue
+			}
+			return workload{kind: DeploymentOwner, xid: strings.TrimSuffix(ownerXID, "-"+hash)}, true
+		case "Deployment":
+			return workload{kind: DeploymentOwner, xid: ownerXID}, true
+		case "StatefulSet":
+			return workload{kind: StatefulsetOwner, xid: ownerXID}, true
+		case "DaemonSet":
+			return workload{kind: DaemonsetOwner, xid: ownerXID}, true
+		case "Job":
+			return workload{kind: JobOwner, xid: ownerXID}, true
+		}
+	}
+	return workload{}, false
+}
+
+// GenerateAndStoreWorkloadInteractions rolls the pod interactions counted since the last run up to the owners of the
+// pods and adds them to the interactions of the owners stored in Dgraph.
+func GenerateAndStoreWorkloadInteractions() {
+	log.Info("Storing Workload Interactions ....")
+	mu.Lock()
+	edges := rollUpInteractions(podToPodTable, aggregatedPodTable, podOwnerTable, podOwner)
+	for edge, count := range rollUpInteractions(podToExternalSvcTable, aggregatedExternalSvcTable, podOwnerTable, externalServiceOwner) {
+		edges[edge] += count
+	}
+	pruneOwners(podOwnerTable, podToPodTable, podToExternalSvcTable)
+	mu.Unlock()
+
+	now := time.Now()
+	for _, edge := range sortedEdges(edges) {
+		interaction := models.WorkloadInteraction{
+			Source:               edge.source.xid,
+			SourceKind:           edge.source.kind,
+			SourceNamespace:      namespaceOf(edge.source.xid),
+			Destination:          edge.destination.xid,
+			DestinationKind:      edge.destination.kind,
+			DestinationNamespace: namespaceOf(edge.destination.xid),
+			Count:                edges[edge],
+		}
+		if err := models.AddWorkloadInteraction(interaction, now); err != nil {
+			log.Errorf("failed to store workload interaction in Dgraph %v", err)
+		}
+	}
+	log.Info("Finished storing workload interactions.")
+}
+
+// rollUpInteractions sums the counts of the interactions which are not in aggregated yet by owner of their source
+// pod and owner of their destination, and records them as aggregated. The interactions of an owner with itself and
+// of pods without owner are left out.
+func rollUpInteractions(interactions, aggregated map[string](map[string]float64), owners map[string]workload,
+	destinationOwner func(string, map[string]workload) (workload, bool)) map[workloadEdge]float64 {
+	edges := make(map[workloadEdge]float64)
+	for srcName, communication := range interactions {
+		if _, ok := aggregated[srcName]; !ok {
+			aggregated[srcName] = make(map[string]float64)
+		}
+		source, hasSource := owners[srcName]
+		for dstName, count := range communication {
+			delta := count - aggregated[srcName][dstName]
+			aggregated[srcName][dstName] = count
+			if delta <= 0 || !hasSource {
+				continue
+			}
+			destination, hasDestination := destinationOwner(dstName, owners)
+			if !hasDestination || destination == source {
+				continue
+			}
+			edges[workloadEdge{source: source, destination: destination}] += delta
+		}
+	}
+	return edges
+}
+
+func podOwner(podXID string, owners map[string]workload) (workload, bool) {
+	owner, ok := owners[podXID]
+	return owner, ok
+}
+
+// externalServiceOwner returns the service without pods itself
+func externalServiceOwner(serviceXID string, _ map[string]workload) (workload, bool) {
+	return workload{kind: ServiceOwner, xid: serviceXID}, true
+}
+
+// pruneOwners forgets the owners of the pods which are neither source nor destination of an interaction
+func pruneOwners(owners map[string]workload, tables ...map[string](map[string]float64)) {
+	interacting := make(map[string]bool)
+	for _, table := range tables {
+		for srcName, communication := range table {
+			interacting[srcName] = true
+			for dstName := range communication {
+				interacting[dstName] = true
+			}
+		}
+	}
+	for podXID := range owners {
+		if !interacting[podXID] {
+			delete(owners, podXID)
+		}
+	}
+}
+
+// sortedEdges returns the edges ordered by source and destination, so they are stored in a stable order
+func sortedEdges(edges map[workloadEdge]float64) []workloadEdge {
+	sorted := make([]workloadEdge, 0, len(edges))
+	for edge := range edges {
+		sorted = append(sorted, edge)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].source != sorted[j].source {
+			return sorted[i].source.kind+sorted[i].source.xid < sorted[j].source.kind+sorted[j].source.xid
+		}
+		return sorted[i].destination.kind+sorted[i].destination.xid < sorted[j].destination.kind+sorted[j].destination.xid
+	})
+	return sorted
+}
+
+func namespaceOf(xid string) string {
+	return strings.SplitN(xid, KeySpliter, 2)[0]
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package linker
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func newOwnedPod(name, hash string, owners ...metav1.OwnerReference) corev1.Pod {
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, OwnerReferences: owners}}
+	if hash != "" {
+		pod.Labels = map[string]string{"pod-template-hash": hash}
+	}
+	return pod
+}
+
+func TestPodWorkload(t *testing.T) {
+	pod := newOwnedPod("cart-7c9b-x2z", "7c9b", metav1.OwnerReference{Kind: "ReplicaSet", Name: "cart-7c9b"})
+	owner, ok := podWorkload(pod)
+	utils.Assert(t, ok, "expected pod of a deployment to have an owner")
+	utils.Equals(t, workload{kind: DeploymentOwner, xid: "shop:cart"}, owner)
+
+	owner, ok = podWorkload(newOwnedPod("redis-0", "", metav1.OwnerReference{Kind: "StatefulSet", Name: "redis"}))
+	utils.Assert(t, ok, "expected pod of a statefulset to have an owner")
+	utils.Equals(t, workload{kind: StatefulsetOwner, xid: "shop:redis"}, owner)
+
+	// a replicaset created by hand is not a workload purser rolls up to
+	_, ok = podWorkload(newOwnedPod("batch-abc", "", metav1.OwnerReference{Kind: "ReplicaSet", Name: "batch"}))
+	utils.Assert(t, !ok, "expected replicaset without pod template hash to be skipped")
+	_, ok = podWorkload(newOwnedPod("debug", ""))
+	utils.Assert(t, !ok, "expected bare pod to have no owner")
+}
+
+func TestRollUpInteractions(t *testing.T) {
+	frontend := workload{kind: ServiceOwner, xid: "shop:frontend"}
+	cart := workload{kind: ServiceOwner, xid: "shop:cart"}
+	owners := map[string]workload{
+		"shop:frontend-1": frontend,
+		"shop:frontend-2": frontend,
+		"shop:cart-1":     cart,
+	}
+	interactions := map[string](map[string]float64){
+		"shop:frontend-1": {"shop:cart-1": 3, "shop:frontend-2": 5, "shop:debug": 1},
+		"shop:frontend-2": {"shop:cart-1": 2},
+		"shop:debug":      {"shop:cart-1": 7},
+	}
+	aggregated := make(map[string](map[string]float64))
+
+	edges := rollUpInteractions(interactions, aggregated, owners, podOwner)
+	// the interactions of a service with itself and of pods without owner are left out
+	utils.Equals(t, map[workloadEdge]float64{{source: frontend, destination: cart}: 5}, edges)
+
+	// the counts are cumulative, only what was added since the last roll up is counted again
+	interactions["shop:frontend-1"]["shop:cart-1"] = 4
+	edges = rollUpInteractions(interactions, aggregated, owners, podOwner)
+	utils.Equals(t, map[workloadEdge]float64{{source: frontend, destination: cart}: 1}, edges)
+
+	edges = rollUpInteractions(interactions, aggregated, owners, podOwner)
+	utils.Equals(t, 0, len(edges))
+}
+
+func TestRollUpExternalServiceInteractions(t *testing.T) {
+	frontend := workload{kind: DeploymentOwner, xid: "shop:frontend"}
+	owners := map[string]workload{"shop:frontend-1": frontend}
+	interactions := map[string](map[string]float64){"shop:frontend-1": {"shop:payments-api": 2}}
+
+	edges := rollUpInteractions(interactions, make(map[string](map[string]float64)), owners, externalServiceOwner)
+	utils.Equals(t, map[workloadEdge]float64{{source: frontend, destination: workload{kind: ServiceOwner, xid: "shop:payments-api"}}: 2}, edges)
+}
+
+func TestPruneOwners(t *testing.T) {
+	owners := map[string]workload{
+		"shop:frontend-1": {kind: ServiceOwner, xid: "shop:frontend"},
+		"shop:cart-1":     {kind: ServiceOwner, xid: "shop:cart"},
+		"shop:idle-1":     {kind: JobOwner, xid: "shop:idle"},
+	}
+	pruneOwners(owners, map[string](map[string]float64){"shop:frontend-1": {"shop:cart-1": 1}})
+	utils.Equals(t, 2, len(owners))
+	_, ok := owners["shop:idle-1"]
+	utils.Assert(t, !ok, "expected owner of pod without interactions to be pruned")
+}
@@ -18,12 +18,15 @@
 package processor
 
 import (
+	"encoding/json"
+
 	log "github.com/Sirupsen/logrus"
 
 	groupsv1 "github.com/vmware/purser/pkg/apis/groups/v1"
 	subscriberv1 "github.com/vmware/purser/pkg/apis/subscriber/v1"
 	groups "github.com/vmware/purser/pkg/client/clientset/typed/groups/v1"
 	subscriber "github.com/vmware/purser/pkg/client/clientset/typed/subscriber/v1"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,6 +42,33 @@ func RetrievePodList(client *kubernetes.Clientset, options metav1.ListOptions) *
 	return pods
 }
 
+// RetrieveRawPodList returns the raw pods of all namespaces, they hold the fields unknown to the vendored
+// kubernetes api.
+func RetrieveRawPodList(client *kubernetes.Clientset) []models.RawPod {
+	data, err := client.CoreV1().RESTClient().Get().Resource("pods").DoRaw()
+	if err != nil {
+		log.Errorf("failed to retrieve raw pods: %v", err)
+		return nil
+	}
+	type podList struct {
+		Items []models.RawPod `json:"items"`
+	}
+	pods := podList{}
+	if err = json.Unmarshal(data, &pods); err != nil {
+		log.Errorf("failed to decode raw pods: %v", err)
+	}
+	return pods.Items
+}
+
+// RetrieveNodeList returns list of nodes.
+func RetrieveNodeList(client *kubernetes.Clientset, options metav1.ListOptions) *corev1.NodeList {
+	nodes, err := client.CoreV1().Nodes().List(options)
+	if err != nil {
+		log.Errorf("failed to retrieve nodes: %v", err)
+	}
+	return nodes
+}
+
 // RetrieveServiceList returns list of services in the given namespace.
 func RetrieveServiceList(client *kubernetes.Clientset, options metav1.ListOptions) *corev1.ServiceList {
 	services, err := client.CoreV1().Services(metav1.NamespaceAll).List(options)
@@ -34,6 +34,7 @@ import (
 func processContainerDetails(conf controller.Config, pod corev1.Pod, containers []corev1.Container) linker.InteractionsWrapper {
 	interactions := linker.InteractionsWrapper{
 		PodInteractions:             make(map[string](map[string]float64)),
+		PodServiceInteractions:      make(map[string](map[string]float64)),
 		ProcessToPodInteraction:     make(map[string](map[string]bool)),
 		ContainerProcessInteraction: make(map[string][]string),
 	}
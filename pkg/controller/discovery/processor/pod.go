@@ -23,6 +23,7 @@ import (
 	"github.com/vmware/purser/pkg/controller"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/discovery/hubble"
 	"github.com/vmware/purser/pkg/controller/discovery/linker"
 
 	corev1 "k8s.io/api/core/v1"
@@ -35,13 +36,26 @@ var wg sync.WaitGroup
 
 // ProcessPodInteractions fetches details of all the running processes in each container of
 // each pod in a given namespace and generates a 1:1 mapping between the communicating pods.
+// With Hubble the interactions are the flows observed by Cilium since the last run instead.
 func ProcessPodInteractions(conf controller.Config) {
 	k8sPods := RetrievePodList(conf.Kubeclient, metav1.ListOptions{})
 
 	linker.PopulatePodIPTable(k8sPods)
-	processPodDetails(conf, k8sPods)
+	linker.PopulateDualStackPodIPTable(RetrieveRawPodList(conf.Kubeclient))
+	services := RetrieveServiceList(conf.Kubeclient, metav1.ListOptions{})
+	nodes := RetrieveNodeList(conf.Kubeclient, metav1.ListOptions{})
+	linker.PopulateServiceAddressTable(services, nodes, k8sPods)
+	linker.PopulatePodOwnerTable(k8sPods)
+	if hubble.Enabled() {
+		interactions := hubble.Drain()
+		linker.UpdatePodToPodTable(interactions.PodInteractions)
+		linker.UpdatePodToServiceTable(interactions.PodServiceInteractions)
+	} else {
+		processPodDetails(conf, k8sPods)
+	}
 
 	linker.GenerateAndStorePodInteractions()
+	linker.GenerateAndStoreWorkloadInteractions()
 	log.Infof("Successfully generated Pod To Pod mapping.")
 }
 
@@ -73,6 +87,7 @@ func processPodDetails(conf controller.Config, pods *corev1.PodList) {
 				containers := pod.Spec.Containers
 				interactions := processContainerDetails(conf, pod, containers)
 				linker.UpdatePodToPodTable(interactions.PodInteractions)
+				linker.UpdatePodToServiceTable(interactions.PodServiceInteractions)
 				linker.StoreProcessInteractions(interactions.ContainerProcessInteraction, interactions.ProcessToPodInteraction,
 					pod.GetCreationTimestamp().Time)
 				log.Debugf("Finished processing Pod: (%s), (%d/%d)", pod.Name, index+1, podsCount)
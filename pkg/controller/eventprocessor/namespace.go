@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package eventprocessor
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/aggregation"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	archiveNamespace         = aggregation.FinalizeNamespace
+	patchNamespaceFinalizers = controller.PatchNamespaceFinalizers
+
+	// releasedNamespaces are the uids of the namespaces archived before their finalizer was removed, their delete
+	// event does not archive them again
+	releasedNamespaces = map[string]bool{}
+)
+
+// processNamespace archives the cost of the namespaces being deleted. With the namespace finalizer, the deletion
+// waits until the archive is written: the finalizer is added to the namespaces and removed only once their cost is
+// archived, a failed archive being retried on the next update of the namespace. Without it, namespaces are archived
+// when their delete event is processed.
+func processNamespace(ns api_v1.Namespace, eventType string, captureTime meta_v1.Time) {
+	switch {
+	case eventType == controller.Delete:
+		if releasedNamespaces[string(ns.UID)] {
+			delete(releasedNamespaces, string(ns.UID))
+			return
+		}
+		if err := archiveNamespace(ns.Name, ns.GetCreationTimestamp().Time, namespaceEndTime(ns, captureTime)); err != nil {
+			log.Errorf("Error while finalizing namespace %s: %v", ns.Name, err)
+		}
+	case ns.DeletionTimestamp != nil:
+		if !controller.HasNamespaceFinalizer(ns) {
+			return
+		}
+		if err := releaseNamespace(ns); err != nil {
+			log.Errorf("Error while finalizing namespace %s, its deletion waits: %v", ns.Name, err)
+		}
+	case controller.IsNamespaceFinalizerEnabled() && !controller.HasNamespaceFinalizer(ns):
+		if err := patchNamespaceFinalizers(ns, append(ns.Finalizers, controller.NamespaceFinalizer)); err != nil {
+			log.Errorf("Error while adding the finalizer of namespace %s: %v", ns.Name, err)
+		}
+	}
+}
+
+// releaseNamespace archives the cost of the namespace being deleted and then removes its finalizer. The finalizer
+// is kept if the archive is not written.
+func releaseNamespace(ns api_v1.Namespace) error {
+	if err := archiveNamespace(ns.Name, ns.GetCreationTimestamp().Time, ns.DeletionTimestamp.Time); err != nil {
+		return err
+	}
+	var finalizers []string
+	for _, finalizer := range ns.Finalizers {
+		if finalizer != controller.NamespaceFinalizer {
+			finalizers = append(finalizers, finalizer)
+		}
+	}
+	if err := patchNamespaceFinalizers(ns, finalizers); err != nil {
+		return err
+	}
+	releasedNamespaces[string(ns.UID)] = true
+	log.Infof("namespace: (%s) is archived, its deletion continues", ns.Name)
+	return nil
+}
+
+// namespaceEndTime returns the deletion time of the namespace, or the time its delete event was captured
+func namespaceEndTime(ns api_v1.Namespace, captureTime meta_v1.Time) time.Time {
+	if deletionTimestamp := ns.GetDeletionTimestamp(); !deletionTimestamp.IsZero() {
+		return deletionTimestamp.Time
+	}
+	return captureTime.Time
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package eventprocessor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// namespaceCalls records the archives and the finalizer patches of the namespaces, in order
+type namespaceCalls struct {
+	calls      []string
+	finalizers [][]string
+	archiveErr error
+	end        time.Time
+}
+
+func stubNamespaceFinalizer(calls *namespaceCalls) func() {
+	archive, patch := archiveNamespace, patchNamespaceFinalizers
+	archiveNamespace = func(name string, start, end time.Time) error {
+		calls.calls = append(calls.calls, "archive "+name)
+		calls.end = end
+		return calls.archiveErr
+	}
+	patchNamespaceFinalizers = func(ns api_v1.Namespace, finalizers []string) error {
+		calls.calls = append(calls.calls, "patch "+ns.Name)
+		calls.finalizers = append(calls.finalizers, finalizers)
+		return nil
+	}
+	return func() {
+		archiveNamespace, patchNamespaceFinalizers = archive, patch
+		releasedNamespaces = map[string]bool{}
+		controller.SetupNamespaceFinalizer(controller.NamespaceFinalizerSettings{})
+	}
+}
+
+func newTerminatingNamespace(deletion time.Time, finalizers ...string) api_v1.Namespace {
+	deletionTimestamp := meta_v1.NewTime(deletion)
+	return api_v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "shop", UID: types.UID("uid-shop"),
+		DeletionTimestamp: &deletionTimestamp, Finalizers: finalizers}}
+}
+
+func TestFinalizerRemovedAfterArchive(t *testing.T) {
+	calls := &namespaceCalls{}
+	defer stubNamespaceFinalizer(calls)()
+	deletion := time.Date(2018, 11, 15, 12, 0, 0, 0, time.UTC)
+	ns := newTerminatingNamespace(deletion, "example.com/backup", controller.NamespaceFinalizer)
+
+	// the archive is not written: the finalizer is kept and the deletion waits
+	calls.archiveErr = errors.New("dgraph is unreachable")
+	processNamespace(ns, controller.Create, meta_v1.Now())
+	utils.Equals(t, []string{"archive shop"}, calls.calls)
+	utils.Assert(t, !releasedNamespaces["uid-shop"], "namespace released without archive")
+
+	// the next update archives the namespace, then removes the finalizer only
+	calls.calls, calls.archiveErr = nil, nil
+	processNamespace(ns, controller.Create, meta_v1.Now())
+	utils.Equals(t, []string{"archive shop", "patch shop"}, calls.calls)
+	utils.Equals(t, [][]string{{"example.com/backup"}}, calls.finalizers)
+	utils.Equals(t, deletion, calls.end)
+
+	// the delete event of the released namespace does not archive it again
+	calls.calls = nil
+	processNamespace(newTerminatingNamespace(deletion, "example.com/backup"), controller.Delete, meta_v1.Now())
+	utils.Equals(t, 0, len(calls.calls))
+	utils.Equals(t, 0, len(releasedNamespaces))
+}
+
+func TestFinalizerAdded(t *testing.T) {
+	calls := &namespaceCalls{}
+	defer stubNamespaceFinalizer(calls)()
+	ns := api_v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "shop", Finalizers: []string{"example.com/backup"}}}
+
+	processNamespace(ns, controller.Create, meta_v1.Now())
+	utils.Equals(t, 0, len(calls.calls))
+
+	controller.SetupNamespaceFinalizer(controller.NamespaceFinalizerSettings{Enabled: true})
+	processNamespace(ns, controller.Create, meta_v1.Now())
+	utils.Equals(t, []string{"patch shop"}, calls.calls)
+	utils.Equals(t, [][]string{{"example.com/backup", controller.NamespaceFinalizer}}, calls.finalizers)
+
+	// namespaces with the finalizer or being deleted are not patched
+	calls.calls = nil
+	ns.Finalizers = append(ns.Finalizers, controller.NamespaceFinalizer)
+	processNamespace(ns, controller.Create, meta_v1.Now())
+	processNamespace(newTerminatingNamespace(time.Now()), controller.Create, meta_v1.Now())
+	utils.Equals(t, 0, len(calls.calls))
+}
+
+func TestDeletedNamespaceWithoutFinalizer(t *testing.T) {
+	calls := &namespaceCalls{}
+	defer stubNamespaceFinalizer(calls)()
+
+	// the deletion time is the end of the namespace, else the capture time of the event
+	deletion := time.Date(2018, 11, 15, 12, 0, 0, 0, time.UTC)
+	processNamespace(newTerminatingNamespace(deletion), controller.Delete, meta_v1.Now())
+	utils.Equals(t, []string{"archive shop"}, calls.calls)
+	utils.Equals(t, deletion, calls.end)
+
+	captured := meta_v1.NewTime(time.Date(2018, 11, 16, 0, 0, 0, 0, time.UTC))
+	processNamespace(api_v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "shop"}}, controller.Delete, captured)
+	utils.Equals(t, captured.Time, calls.end)
+}
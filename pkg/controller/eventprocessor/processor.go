@@ -18,6 +18,7 @@
 package eventprocessor
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	groups_v1 "github.com/vmware/purser/pkg/apis/groups/v1"
 	subcriber_v1 "github.com/vmware/purser/pkg/apis/subscriber/v1"
 	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/dgraph"
 	"github.com/vmware/purser/pkg/controller/dgraph/models"
 	"github.com/vmware/purser/pkg/controller/discovery/processor"
 
@@ -36,32 +38,74 @@ import (
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// ProcessEvents processes the event and notifies the subscribers.
-func ProcessEvents(conf *controller.Config) {
+// ProcessEvents processes the event and notifies the subscribers. Once ctx is done, the events left in the buffer
+// and the pending pods are persisted before it returns.
+func ProcessEvents(ctx context.Context, conf *controller.Config) {
+	for {
+		processBufferedEvents(conf)
+		flushPendingPods(maxPodLifetime)
+		storeUnstoredPods()
+
+		select {
+		case <-ctx.Done():
+			processBufferedEvents(conf)
+			flushPendingPods(0)
+			log.Info("event processing stopped, buffered events are persisted")
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+func processBufferedEvents(conf *controller.Config) {
+	conf.RingBuffer.PrintDetails()
 
 	for {
-		conf.RingBuffer.PrintDetails()
+		data, size := conf.RingBuffer.ReadN(ReadSize)
 
-		for {
-			data, size := conf.RingBuffer.ReadN(ReadSize)
+		if size == 0 {
+			log.Debug("No new events to process.")
+			break
+		}
 
-			if size == 0 {
-				log.Debug("No new events to process.")
-				break
-			}
+		PersistPayloads(data)
 
-			PersistPayloads(data)
+		subscribers := processor.RetrieveSubscriberList(conf.Subscriberclient, meta_v1.ListOptions{})
+		notifySubscribers(data, subscribers)
 
-			subscribers := processor.RetrieveSubscriberList(conf.Subscriberclient, meta_v1.ListOptions{})
-			notifySubscribers(data, subscribers)
+		groups := processor.RetrieveGroupList(conf.Groupcrdclient, meta_v1.ListOptions{})
+		updateCustomGroups(data, groups)
 
-			groups := processor.RetrieveGroupList(conf.Groupcrdclient, meta_v1.ListOptions{})
-			updateCustomGroups(data, groups)
+		conf.RingBuffer.RemoveN(size)
+		conf.RingBuffer.PrintDetails()
+	}
+}
 
-			conf.RingBuffer.RemoveN(size)
-			conf.RingBuffer.PrintDetails()
+// unstoredPods are the pods, by xid, which could not be created while dgraph was unreachable. The resync following
+// the reconnection only sees the pods which still exist, so they are stored again once dgraph is back.
+var unstoredPods = map[string]api_v1.Pod{}
+
+// trackUnstoredPod keeps the pod to store it again if dgraph was unreachable, a pod stored since is forgotten
+func trackUnstoredPod(pod api_v1.Pod, err error) {
+	xid := pod.Namespace + ":" + pod.Name
+	if err == dgraph.ErrUnreachable {
+		unstoredPods[xid] = pod
+	} else if err == nil {
+		delete(unstoredPods, xid)
+	}
+}
+
+// storeUnstoredPods stores the pods which could not be created, until dgraph is unreachable again
+func storeUnstoredPods() {
+	for xid, pod := range unstoredPods {
+		err := models.StorePod(pod)
+		if err == dgraph.ErrUnreachable {
+			return
+		}
+		delete(unstoredPods, xid)
+		if err != nil {
+			log.Errorf("Error while persisting pod %v", err)
 		}
-		time.Sleep(10 * time.Second)
 	}
 }
 
@@ -76,7 +120,8 @@ func PersistPayloads(payloads []*interface{}) {
 			if err != nil {
 				log.Errorf("Error un marshalling payload " + payload.Data)
 			}
-			err = models.StorePod(pod)
+			err = persistPod(pod, payload)
+			trackUnstoredPod(pod, err)
 			if err != nil {
 				log.Errorf("Error while persisting pod %v", err)
 			}
@@ -110,6 +155,9 @@ func PersistPayloads(payloads []*interface{}) {
 			if err != nil {
 				log.Errorf("Error while persisting namespace %v", err)
 			}
+			if ns.Name != "" {
+				processNamespace(ns, payload.EventType, payload.CaptureTime)
+			}
 		} else if payload.ResourceType == "Deployment" {
 			deployment := apps_v1beta1.Deployment{}
 			err := json.Unmarshal([]byte(payload.Data), &deployment)
@@ -180,6 +228,16 @@ func PersistPayloads(payloads []*interface{}) {
 			if err != nil {
 				log.Errorf("Error while persisting job %v", err)
 			}
+		} else if payload.ResourceType == "Event" {
+			event := api_v1.Event{}
+			err := json.Unmarshal([]byte(payload.Data), &event)
+			if err != nil {
+				log.Errorf("Error un marshalling payload " + payload.Data)
+			}
+			_, err = models.StoreClusterEvent(event)
+			if err != nil {
+				log.Errorf("Error while persisting event %v", err)
+			}
 		} else if payload.ResourceType == "Group" {
 			groupCRD := groups_v1.Group{}
 			err := json.Unmarshal([]byte(payload.Data), &groupCRD)
@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package eventprocessor
+
+import (
+	"hash/fnv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// ShortLivedPodSettings configures the handling of pods living less than MaxLifetime (ex: 2m).
+// Such pods are aggregated into per namespace synthetic pods except a SampleRate (0 to 1) fraction of them
+// which are stored as regular pods. Short lived pod handling is disabled if MaxLifetime is empty.
+type ShortLivedPodSettings struct {
+	MaxLifetime string  `json:"maxLifetime,omitempty"`
+	SampleRate  float64 `json:"sampleRate,omitempty"`
+}
+
+const sampleBuckets = 10000
+
+var (
+	maxPodLifetime time.Duration
+	podSampleRate  float64
+
+	// pendingPods are the pods younger than maxPodLifetime which are not yet persisted
+	pendingPods = map[string]api_v1.Pod{}
+)
+
+// SetupShortLivedPods sets the max lifetime and sample rate of short lived pods
+func SetupShortLivedPods(settings ShortLivedPodSettings) {
+	if settings.MaxLifetime == "" {
+		return
+	}
+	lifetime, err := time.ParseDuration(settings.MaxLifetime)
+	if err != nil {
+		log.Errorf("invalid max lifetime of short lived pods: (%s), short lived pods will be stored as regular pods", settings.MaxLifetime)
+		return
+	}
+	maxPodLifetime = lifetime
+	podSampleRate = settings.SampleRate
+}
+
+// persistPod stores the pod in dgraph. If short lived pod handling is enabled, new pods are held back until
+// they outlive the max lifetime. Pods terminating before that are aggregated or sampled.
+func persistPod(pod api_v1.Pod, payload *controller.Payload) error {
+	if maxPodLifetime == 0 {
+		return models.StorePod(pod)
+	}
+
+	xid := pod.Namespace + ":" + pod.Name
+	_, isPending := pendingPods[xid]
+	if !isPending && dgraph.GetUID(xid, models.IsPod) != "" {
+		return models.StorePod(pod)
+	}
+
+	startTime := pod.GetCreationTimestamp().Time
+	if payload.EventType != controller.Delete {
+		if time.Since(startTime) >= maxPodLifetime {
+			delete(pendingPods, xid)
+			return models.StorePod(pod)
+		}
+		pendingPods[xid] = pod
+		return nil
+	}
+
+	delete(pendingPods, xid)
+	endTime := payload.CaptureTime.Time
+	if deletionTimestamp := pod.GetDeletionTimestamp(); !deletionTimestamp.IsZero() {
+		endTime = deletionTimestamp.Time
+	} else {
+		pod.SetDeletionTimestamp(&payload.CaptureTime)
+	}
+	if endTime.Sub(startTime) >= maxPodLifetime || isSampled(xid) {
+		return models.StorePod(pod)
+	}
+	return models.StoreShortLivedPod(pod, endTime)
+}
+
+// flushPendingPods stores the pending pods which are older than minAge, it is the max lifetime except
+// on shutdown where all pending pods are stored
+func flushPendingPods(minAge time.Duration) {
+	for xid, pod := range pendingPods {
+		if time.Since(pod.GetCreationTimestamp().Time) < minAge {
+			continue
+		}
+		delete(pendingPods, xid)
+		err := models.StorePod(pod)
+		trackUnstoredPod(pod, err)
+		if err != nil {
+			log.Errorf("Error while persisting pod %v", err)
+		}
+	}
+}
+
+// isSampled deterministically selects podSampleRate fraction of pods
+func isSampled(xid string) bool {
+	if podSampleRate <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	_, err := h.Write([]byte(xid))
+	if err != nil {
+		return false
+	}
+	return float64(h.Sum32()%sampleBuckets) < podSampleRate*sampleBuckets
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package eventprocessor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller"
+	"github.com/vmware/purser/test/utils"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetupShortLivedPods(t *testing.T) {
+	defer func() { maxPodLifetime, podSampleRate = 0, 0 }()
+
+	SetupShortLivedPods(ShortLivedPodSettings{MaxLifetime: "2 minutes", SampleRate: 0.1})
+	utils.Equals(t, time.Duration(0), maxPodLifetime)
+
+	SetupShortLivedPods(ShortLivedPodSettings{MaxLifetime: "2m", SampleRate: 0.1})
+	utils.Equals(t, 2*time.Minute, maxPodLifetime)
+	utils.Equals(t, 0.1, podSampleRate)
+}
+
+func TestIsSampled(t *testing.T) {
+	defer func() { podSampleRate = 0 }()
+
+	count := func() int {
+		sampled := 0
+		for i := 0; i < 1000; i++ {
+			if isSampled(fmt.Sprintf("ci:build-%d", i)) {
+				sampled++
+			}
+		}
+		return sampled
+	}
+	podSampleRate = 0
+	utils.Equals(t, 0, count())
+	podSampleRate = 1
+	utils.Equals(t, 1000, count())
+	podSampleRate = 0.2
+	sampled := count()
+	utils.Assert(t, sampled > 150 && sampled < 250, "sampled pods at rate 0.2: %d", sampled)
+	// the same pods are sampled on every event
+	utils.Equals(t, sampled, count())
+}
+
+func TestPendingPodHeldBack(t *testing.T) {
+	maxPodLifetime = 2 * time.Minute
+	defer func() {
+		maxPodLifetime = 0
+		pendingPods = map[string]api_v1.Pod{}
+	}()
+
+	pod := api_v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Namespace: "ci", Name: "build-1",
+		CreationTimestamp: meta_v1.NewTime(time.Now().Add(-time.Minute))}}
+	pendingPods["ci:build-1"] = pod
+	pod.Labels = map[string]string{"app": "build"}
+	payload := &controller.Payload{EventType: controller.Update, CaptureTime: meta_v1.Now()}
+	utils.Ok(t, persistPod(pod, payload))
+	// the update of a pod younger than the max lifetime replaces the pending pod and is not stored
+	utils.Equals(t, 1, len(pendingPods))
+	utils.Equals(t, "build", pendingPods["ci:build-1"].Labels["app"])
+}
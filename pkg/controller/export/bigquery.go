@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	bigQueryAPI      = "https://bigquery.googleapis.com/bigquery/v2"
+	gceTokenEndpoint = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// bigQueryExporter streams rows into a day partitioned BigQuery table using the REST API
+type bigQueryExporter struct {
+	settings BigQuerySettings
+}
+
+type bigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+type bigQueryTable struct {
+	TableReference struct {
+		ProjectID string `json:"projectId"`
+		DatasetID string `json:"datasetId"`
+		TableID   string `json:"tableId"`
+	} `json:"tableReference"`
+	Schema struct {
+		Fields []bigQueryField `json:"fields"`
+	} `json:"schema"`
+	TimePartitioning *bigQueryPartitioning `json:"timePartitioning,omitempty"`
+}
+
+type bigQueryPartitioning struct {
+	Type  string `json:"type"`
+	Field string `json:"field"`
+}
+
+// Name returns the exporter name
+func (e *bigQueryExporter) Name() string {
+	return "bigquery"
+}
+
+// EnsureSchema creates the table partitioned by date if it is missing, otherwise appends the missing columns
+func (e *bigQueryExporter) EnsureSchema() error {
+	headers, err := e.headers()
+	if err != nil {
+		return err
+	}
+
+	table := bigQueryTable{}
+	status, err := doJSON(http.MethodGet, e.tableURL(), headers, nil, &table)
+	if status == http.StatusNotFound {
+		table.TableReference.ProjectID = e.settings.Project
+		table.TableReference.DatasetID = e.settings.Dataset
+		table.TableReference.TableID = e.settings.Table
+		table.TimePartitioning = &bigQueryPartitioning{Type: "DAY", Field: "date"}
+		table.Schema.Fields = appendMissingFields(nil)
+		_, err = doJSON(http.MethodPost, e.datasetURL()+"/tables", headers, table, nil)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	fields := appendMissingFields(table.Schema.Fields)
+	if len(fields) == len(table.Schema.Fields) {
+		return nil
+	}
+	patch := map[string]interface{}{"schema": map[string]interface{}{"fields": fields}}
+	_, err = doJSON(http.MethodPatch, e.tableURL(), headers, patch, nil)
+	return err
+}
+
+// Export streams the rows into the table. Insert ids make retries of the same day idempotent on a best effort basis.
+func (e *bigQueryExporter) Export(day string, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	headers, err := e.headers()
+	if err != nil {
+		return err
+	}
+
+	type insertRow struct {
+		InsertID string `json:"insertId"`
+		JSON     Row    `json:"json"`
+	}
+	request := struct {
+		Rows []insertRow `json:"rows"`
+	}{}
+	for _, row := range rows {
+		request.Rows = append(request.Rows, insertRow{
+			InsertID: strings.Join([]string{row.Cluster, day, row.OwnerType, row.Owner}, "-"),
+			JSON:     row,
+		})
+	}
+	response := struct {
+		InsertErrors []interface{} `json:"insertErrors"`
+	}{}
+	if _, err = doJSON(http.MethodPost, e.tableURL()+"/insertAll", headers, request, &response); err != nil {
+		return err
+	}
+	if len(response.InsertErrors) > 0 {
+		return fmt.Errorf("%d rows were not inserted: %v", len(response.InsertErrors), response.InsertErrors)
+	}
+	return nil
+}
+
+func (e *bigQueryExporter) datasetURL() string {
+	return bigQueryAPI + "/projects/" + e.settings.Project + "/datasets/" + e.settings.Dataset
+}
+
+func (e *bigQueryExporter) tableURL() string {
+	return e.datasetURL() + "/tables/" + e.settings.Table
+}
+
+func (e *bigQueryExporter) headers() (map[string]string, error) {
+	token, err := googleToken(e.settings.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"Authorization": "Bearer " + token}, nil
+}
+
+// appendMissingFields returns the given fields followed by the columns which are not among them
+func appendMissingFields(fields []bigQueryField) []bigQueryField {
+	existing := make(map[string]bool)
+	for _, field := range fields {
+		existing[field.Name] = true
+	}
+	for _, c := range columns {
+		if !existing[c.name] {
+			fields = append(fields, bigQueryField{Name: c.name, Type: c.bigQueryType, Mode: "NULLABLE"})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// encodeRows returns the rows in the given format along with the content type of the format
+func encodeRows(format string, rows []Row) ([]byte, string, error) {
+	switch format {
+	case CSV:
+		data, err := encodeCSV(rows)
+		return data, "text/csv", err
+	case JSONLines:
+		data, err := encodeJSONLines(rows)
+		return data, "application/x-ndjson", err
+	case Parquet:
+		records := make([][]interface{}, len(rows))
+		for i, row := range rows {
+			records[i] = row.values()
+		}
+		data, err := encodeParquet(parquetColumns(), records)
+		return data, "application/vnd.apache.parquet", err
+	}
+	return nil, "", fmt.Errorf("unknown export format: %s", format)
+}
+
+func encodeCSV(rows []Row) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		values := row.values()
+		record := make([]string, len(values))
+		for i, value := range values {
+			if number, isNumber := value.(float64); isNumber {
+				record[i] = strconv.FormatFloat(number, 'f', -1, 64)
+			} else {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func encodeJSONLines(rows []Row) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+const (
+	dateFormat  = "2006-01-02"
+	httpTimeout = 60 * time.Second
+)
+
+var (
+	mu          sync.Mutex
+	cluster     string
+	exporters   []Exporter
+	schemaReady = make(map[string]bool)
+)
+
+// Setup creates the exporters which are configured in the settings
+func Setup(settings Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	cluster = settings.Cluster
+	setReportRetention(settings.ReportRetention)
+	exporters = nil
+	if settings.BigQuery != nil {
+		exporters = append(exporters, &bigQueryExporter{settings: *settings.BigQuery})
+	}
+	if settings.Snowflake != nil {
+		exporters = append(exporters, &snowflakeExporter{settings: *settings.Snowflake})
+	}
+	for _, sinkSettings := range settings.Sinks {
+		sink, err := newObjectSink(sinkSettings)
+		if err != nil {
+			log.Errorf("object store sink is disabled: %v", err)
+			continue
+		}
+		exporters = append(exporters, sink)
+	}
+}
+
+// RunDailyExport pushes the cost allocation of the previous day to the configured warehouses and object stores.
+// It is scheduled to run every night after the daily costs are computed.
+func RunDailyExport() {
+	yesterday := time.Now().AddDate(0, 0, -1)
+	day := time.Date(yesterday.Year(), yesterday.Month(), yesterday.Day(), 0, 0, 0, 0, yesterday.Location())
+	if err := ExportDay(day); err != nil {
+		log.Errorf("unable to export cost allocation of day: (%s), error: (%v)", day.Format(dateFormat), err)
+	}
+}
+
+// ExportDay pushes the daily cost summaries of the given day to every configured warehouse.
+// The table schema is checked before the first export of every exporter.
+func ExportDay(day time.Time) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(exporters) == 0 {
+		return nil
+	}
+
+	rows, err := rowsOfDay(day)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, exporter := range exporters {
+		if err := exportRows(exporter, day.Format(dateFormat), rows); err != nil {
+			log.Errorf("%s export failed: %v", exporter.Name(), err)
+			failed = append(failed, exporter.Name())
+			continue
+		}
+		log.Infof("exported %d cost allocation rows of day: (%s) to %s", len(rows), day.Format(dateFormat), exporter.Name())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("export to %s failed", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// rowsOfDay returns the cost allocation rows built from the daily cost summaries of the given day
+func rowsOfDay(day time.Time) ([]Row, error) {
+	summaries, err := query.RetrieveAllCostSummaries(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]Row, 0, len(summaries))
+	for _, summary := range summaries {
+		rows = append(rows, newRow(summary))
+	}
+	return rows, nil
+}
+
+func exportRows(exporter Exporter, day string, rows []Row) error {
+	if !schemaReady[exporter.Name()] {
+		if err := exporter.EnsureSchema(); err != nil {
+			return err
+		}
+		schemaReady[exporter.Name()] = true
+	}
+	return exporter.Export(day, rows)
+}
+
+func newRow(summary models.CostSummary) Row {
+	row := Row{
+		Cluster:        cluster,
+		CPUHours:       float64(summary.CPUHours),
+		MemoryGBHours:  float64(summary.MemoryGBHours),
+		StorageGBHours: float64(summary.StorageGBHours),
+		CPUCost:        summary.CPUCost,
+		MemoryCost:     summary.MemoryCost,
+		StorageCost:    summary.StorageCost,
+		TotalCost:      summary.TotalCost,
+		PriceVersion:   summary.PriceVersion,
+	}
+	if date, err := time.Parse(time.RFC3339, summary.Date); err == nil {
+		row.Date = date.Format(dateFormat)
+	}
+	if summary.Group != nil {
+		row.OwnerType, row.Owner = models.GroupOwner, summary.Group.Xid
+	} else if summary.Namespace != nil {
+		row.OwnerType, row.Owner = models.NamespaceOwner, summary.Namespace.Xid
+	}
+	return row
+}
+
+// doJSON sends the request body in json format and decodes the json response into out if it is not nil.
+// It returns the response status code.
+func doJSON(method, url string, headers map[string]string, body, out interface{}) (int, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+	jsonHeaders := map[string]string{"Content-Type": "application/json", "Accept": "application/json"}
+	for key, value := range headers {
+		jsonHeaders[key] = value
+	}
+
+	response, status, err := doRequest(method, url, jsonHeaders, data)
+	if err != nil {
+		return status, err
+	}
+	if out != nil && len(response) > 0 {
+		return status, json.Unmarshal(response, out)
+	}
+	return status, nil
+}
+
+// doRequest sends the request and returns the response body and status code. Error statuses are returned as errors.
+func doRequest(method, url string, headers map[string]string, body []byte) ([]byte, int, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return data, resp.StatusCode, fmt.Errorf("request to %s failed with status: %s, response: %s", url, resp.Status, string(data))
+	}
+	return data, resp.StatusCode, nil
+}
+
+// googleToken returns the access token from the token file if given, otherwise it is requested from the
+// GCE metadata server (workload identity)
+func googleToken(tokenFile string) (string, error) {
+	if tokenFile != "" {
+		return readToken(tokenFile)
+	}
+	response := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	_, err := doJSON(http.MethodGet, gceTokenEndpoint, map[string]string{"Metadata-Flavor": "Google"}, nil, &response)
+	return response.AccessToken, err
+}
+
+func readToken(tokenFile string) (string, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+type recordingExporter struct {
+	name        string
+	schemaErr   error
+	schemaCalls int
+	exported    map[string][]Row
+}
+
+func (e *recordingExporter) Name() string {
+	return e.name
+}
+
+func (e *recordingExporter) EnsureSchema() error {
+	e.schemaCalls++
+	return e.schemaErr
+}
+
+func (e *recordingExporter) Export(day string, rows []Row) error {
+	e.exported[day] = rows
+	return nil
+}
+
+func TestExportRowsEnsuresSchemaOnce(t *testing.T) {
+	exporter := &recordingExporter{name: "recording", exported: map[string][]Row{}}
+	rows := []Row{{Date: "2018-11-01", Owner: "shop"}}
+	utils.Ok(t, exportRows(exporter, "2018-11-01", rows))
+	utils.Ok(t, exportRows(exporter, "2018-11-02", rows))
+	utils.Equals(t, 1, exporter.schemaCalls)
+	utils.Equals(t, 2, len(exporter.exported))
+
+	failing := &recordingExporter{name: "failing", schemaErr: errors.New("no dataset"), exported: map[string][]Row{}}
+	utils.Assert(t, exportRows(failing, "2018-11-01", rows) != nil, "export without a schema succeeded")
+	utils.Equals(t, 0, len(failing.exported))
+	// the schema is checked again on the next export
+	failing.schemaErr = nil
+	utils.Ok(t, exportRows(failing, "2018-11-01", rows))
+	utils.Equals(t, 2, failing.schemaCalls)
+}
+
+func TestNewRow(t *testing.T) {
+	cluster = "prod"
+	defer func() {
+		cluster = ""
+	}()
+	row := newRow(models.CostSummary{Date: "2018-11-01T00:00:00Z", Namespace: &models.Namespace{ID: dgraph.ID{Xid: "shop"}},
+		CPUHours: 24, CPUCost: 0.6, TotalCost: 0.9, PriceVersion: "v2"})
+	utils.Equals(t, Row{Date: "2018-11-01", Cluster: "prod", OwnerType: models.NamespaceOwner, Owner: "shop", CPUHours: 24,
+		CPUCost: 0.6, TotalCost: 0.9, PriceVersion: "v2"}, row)
+
+	row = newRow(models.CostSummary{Date: "2018-11-01T00:00:00Z", Group: &models.GroupCRD{ID: dgraph.ID{Xid: "team-a"}}})
+	utils.Equals(t, models.GroupOwner, row.OwnerType)
+	utils.Equals(t, "team-a", row.Owner)
+
+	// the values of a row follow the columns of the table
+	utils.Equals(t, len(columns), len(row.values()))
+}
+
+func TestAppendMissingFields(t *testing.T) {
+	fields := appendMissingFields(nil)
+	utils.Equals(t, len(columns), len(fields))
+	utils.Equals(t, bigQueryField{Name: "date", Type: "DATE", Mode: "NULLABLE"}, fields[0])
+
+	existing := []bigQueryField{{Name: "date", Type: "DATE", Mode: "REQUIRED"}, {Name: "legacy", Type: "STRING"}}
+	fields = appendMissingFields(existing)
+	utils.Equals(t, len(columns)+1, len(fields))
+	utils.Equals(t, existing[0], fields[0])
+	utils.Equals(t, existing[1], fields[1])
+	utils.Equals(t, "cluster", fields[2].Name)
+
+	utils.Equals(t, len(fields), len(appendMissingFields(fields)))
+}
+
+func TestNewSnowflakeBinding(t *testing.T) {
+	utils.Equals(t, snowflakeBinding{Type: "REAL", Value: "0.125"}, newSnowflakeBinding(0.125))
+	utils.Equals(t, snowflakeBinding{Type: "TEXT", Value: "2018-11-01"}, newSnowflakeBinding("2018-11-01"))
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		request := map[string]string{}
+		if err := json.Unmarshal(body, &request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"echo": "` + request["value"] + `", "contentType": "` + r.Header.Get("Content-Type") + `"}`))
+	}))
+	defer server.Close()
+
+	response := map[string]string{}
+	status, err := doJSON(http.MethodPost, server.URL, map[string]string{"Authorization": "Bearer token"},
+		map[string]string{"value": "rows"}, &response)
+	utils.Ok(t, err)
+	utils.Equals(t, http.StatusOK, status)
+	utils.Equals(t, map[string]string{"echo": "rows", "contentType": "application/json"}, response)
+
+	status, err = doJSON(http.MethodPost, server.URL, nil, map[string]string{"value": "rows"}, nil)
+	utils.Assert(t, err != nil, "unauthorized request succeeded")
+	utils.Equals(t, http.StatusUnauthorized, status)
+}
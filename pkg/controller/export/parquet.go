@@ -0,0 +1,312 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Parquet physical types, converted types and enums (parquet-format/src/main/thrift/parquet.thrift)
+const (
+	parquetInt32     = 1
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8 = 0
+	parquetDate = 6
+
+	parquetRequired     = 0
+	parquetDataPage     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetGzip         = 2
+	parquetFileVersion  = 1
+	parquetMagic        = "PAR1"
+	parquetCreatedBy    = "purser"
+	secondsInDay        = 24 * 60 * 60
+	parquetDateEncoding = "2006-01-02"
+)
+
+// parquetColumn is a required column of a parquet file
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+}
+
+// parquetColumns returns the parquet schema of the allocation columns
+func parquetColumns() []parquetColumn {
+	schema := make([]parquetColumn, len(columns))
+	for i, c := range columns {
+		switch c.bigQueryType {
+		case "DATE":
+			schema[i] = parquetColumn{name: c.name, physicalType: parquetInt32, convertedType: parquetDate}
+		case "FLOAT64":
+			schema[i] = parquetColumn{name: c.name, physicalType: parquetDouble, convertedType: -1}
+		default:
+			schema[i] = parquetColumn{name: c.name, physicalType: parquetByteArray, convertedType: parquetUTF8}
+		}
+	}
+	return schema
+}
+
+// encodeParquet writes the records as a parquet file with a single row group. Every column chunk is one
+// gzip compressed data page in plain encoding. Values of DATE columns are given as yyyy-mm-dd strings.
+func encodeParquet(schema []parquetColumn, records [][]interface{}) ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	chunks := make([]parquetChunk, len(schema))
+	var totalSize int64
+	for i, column := range schema {
+		values, err := plainEncode(column, records, i)
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := gzipCompress(values)
+		if err != nil {
+			return nil, err
+		}
+
+		header := newThriftWriter()
+		header.i32Field(1, parquetDataPage)
+		header.i32Field(2, int32(len(values)))
+		header.i32Field(3, int32(len(compressed)))
+		header.structField(5, func() {
+			header.i32Field(1, int32(len(records)))
+			header.i32Field(2, parquetPlain)
+			header.i32Field(3, parquetRLE)
+			header.i32Field(4, parquetRLE)
+		})
+		header.stop()
+
+		chunks[i] = parquetChunk{
+			offset:           int64(file.Len()),
+			uncompressedSize: int64(header.buffer.Len() + len(values)),
+			compressedSize:   int64(header.buffer.Len() + len(compressed)),
+		}
+		totalSize += chunks[i].uncompressedSize
+		file.Write(header.buffer.Bytes())
+		file.Write(compressed)
+	}
+
+	metadata := encodeFileMetadata(schema, chunks, int64(len(records)), totalSize)
+	file.Write(metadata)
+	if err := binary.Write(&file, binary.LittleEndian, uint32(len(metadata))); err != nil {
+		return nil, err
+	}
+	file.WriteString(parquetMagic)
+	return file.Bytes(), nil
+}
+
+// parquetChunk is the position and size of a column chunk in the file
+type parquetChunk struct {
+	offset           int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+func encodeFileMetadata(schema []parquetColumn, chunks []parquetChunk, numRows, totalSize int64) []byte {
+	w := newThriftWriter()
+	w.i32Field(1, parquetFileVersion)
+	w.listField(2, thriftStruct, len(schema)+1)
+	w.listStruct(func() {
+		w.stringField(4, "schema")
+		w.i32Field(5, int32(len(schema)))
+	})
+	for _, column := range schema {
+		w.listStruct(func() {
+			w.i32Field(1, column.physicalType)
+			w.i32Field(3, parquetRequired)
+			w.stringField(4, column.name)
+			if column.convertedType >= 0 {
+				w.i32Field(6, column.convertedType)
+			}
+		})
+	}
+	w.i64Field(3, numRows)
+	w.listField(4, thriftStruct, 1)
+	w.listStruct(func() {
+		w.listField(1, thriftStruct, len(schema))
+		for i, column := range schema {
+			chunk := chunks[i]
+			w.listStruct(func() {
+				w.i64Field(2, chunk.offset)
+				w.structField(3, func() {
+					w.i32Field(1, column.physicalType)
+					w.listField(2, thriftI32, 2)
+					w.i32(parquetPlain)
+					w.i32(parquetRLE)
+					w.listField(3, thriftBinary, 1)
+					w.binary(column.name)
+					w.i32Field(4, parquetGzip)
+					w.i64Field(5, numRows)
+					w.i64Field(6, chunk.uncompressedSize)
+					w.i64Field(7, chunk.compressedSize)
+					w.i64Field(9, chunk.offset)
+				})
+			})
+		}
+		w.i64Field(2, totalSize)
+		w.i64Field(3, numRows)
+	})
+	w.stringField(6, parquetCreatedBy)
+	w.stop()
+	return w.buffer.Bytes()
+}
+
+// plainEncode returns the values of the column at the given index of every record in plain encoding
+func plainEncode(column parquetColumn, records [][]interface{}, index int) ([]byte, error) {
+	var values bytes.Buffer
+	for _, record := range records {
+		value := record[index]
+		var err error
+		switch column.physicalType {
+		case parquetDouble:
+			number, isNumber := value.(float64)
+			if !isNumber {
+				return nil, fmt.Errorf("value of column: %s is not a number: %v", column.name, value)
+			}
+			err = binary.Write(&values, binary.LittleEndian, math.Float64bits(number))
+		case parquetInt32:
+			date, parseErr := time.Parse(parquetDateEncoding, fmt.Sprint(value))
+			if parseErr != nil {
+				return nil, fmt.Errorf("value of column: %s is not a date: %v", column.name, value)
+			}
+			err = binary.Write(&values, binary.LittleEndian, int32(date.Unix()/secondsInDay))
+		default:
+			text := fmt.Sprint(value)
+			err = binary.Write(&values, binary.LittleEndian, uint32(len(text)))
+			values.WriteString(text)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values.Bytes(), nil
+}
+
+func gzipCompress(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes structs with the thrift compact protocol, which is used by parquet metadata
+type thriftWriter struct {
+	buffer    bytes.Buffer
+	lastField []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastField: []int16{0}}
+}
+
+func (w *thriftWriter) fieldHeader(id int16, fieldType byte) {
+	last := &w.lastField[len(w.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buffer.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		w.buffer.WriteByte(fieldType)
+		w.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) i32Field(id int16, value int32) {
+	w.fieldHeader(id, thriftI32)
+	w.i32(value)
+}
+
+func (w *thriftWriter) i64Field(id int16, value int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag(value))
+}
+
+func (w *thriftWriter) stringField(id int16, value string) {
+	w.fieldHeader(id, thriftBinary)
+	w.binary(value)
+}
+
+// structField writes a struct field whose fields are written by fields
+func (w *thriftWriter) structField(id int16, fields func()) {
+	w.fieldHeader(id, thriftStruct)
+	w.listStruct(fields)
+}
+
+// listField writes the header of a list field, elements must follow
+func (w *thriftWriter) listField(id int16, elementType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buffer.WriteByte(byte(size)<<4 | elementType)
+	} else {
+		w.buffer.WriteByte(0xf0 | elementType)
+		w.varint(uint64(size))
+	}
+}
+
+// listStruct writes a struct without field header, as list elements are written
+func (w *thriftWriter) listStruct(fields func()) {
+	w.lastField = append(w.lastField, 0)
+	fields()
+	w.stop()
+	w.lastField = w.lastField[:len(w.lastField)-1]
+}
+
+func (w *thriftWriter) stop() {
+	w.buffer.WriteByte(0)
+}
+
+func (w *thriftWriter) i32(value int32) {
+	w.varint(zigzag(int64(value)))
+}
+
+func (w *thriftWriter) binary(value string) {
+	w.varint(uint64(len(value)))
+	w.buffer.WriteString(value)
+}
+
+func (w *thriftWriter) varint(value uint64) {
+	var encoded [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(encoded[:], value)
+	w.buffer.Write(encoded[:n])
+}
+
+func zigzag(value int64) uint64 {
+	return uint64((value << 1) ^ (value >> 63))
+}
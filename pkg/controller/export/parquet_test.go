@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// thriftReader reads the structs written by thriftWriter, fields are keyed by id. Integers are read as int64,
+// binaries as strings, lists as []interface{} and structs as map[int16]interface{}.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() uint64 {
+	value, n := binary.Uvarint(r.data[r.pos:])
+	r.pos += n
+	return value
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	var last int16
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(unzigzag(r.varint()))
+		}
+		fields[id] = r.readValue(header & 0x0f)
+		last = id
+	}
+}
+
+func (r *thriftReader) readValue(valueType byte) interface{} {
+	switch valueType {
+	case thriftI32, thriftI64:
+		return unzigzag(r.varint())
+	case thriftBinary:
+		size := int(r.varint())
+		r.pos += size
+		return string(r.data[r.pos-size : r.pos])
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		elements := make([]interface{}, size)
+		for i := range elements {
+			elements[i] = r.readValue(header & 0x0f)
+		}
+		return elements
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected thrift type")
+}
+
+func unzigzag(value uint64) int64 {
+	return int64(value>>1) ^ -int64(value&1)
+}
+
+func TestThriftFieldHeaders(t *testing.T) {
+	w := newThriftWriter()
+	w.i32Field(1, -3)
+	// a field id jumping by more than 15 is written with its id
+	w.i64Field(20, 1<<40)
+	w.listField(21, thriftI32, 16)
+	for i := 0; i < 16; i++ {
+		w.i32(int32(i))
+	}
+	w.structField(22, func() {
+		w.stringField(2, "nested")
+	})
+	w.stop()
+
+	r := &thriftReader{data: w.buffer.Bytes()}
+	fields := r.readStruct()
+	utils.Equals(t, len(w.buffer.Bytes()), r.pos)
+	utils.Equals(t, int64(-3), fields[1])
+	utils.Equals(t, int64(1<<40), fields[20])
+	utils.Equals(t, 16, len(fields[21].([]interface{})))
+	utils.Equals(t, int64(15), fields[21].([]interface{})[15])
+	utils.Equals(t, "nested", fields[22].(map[int16]interface{})[2])
+}
+
+func TestEncodeParquetRoundTrip(t *testing.T) {
+	rows := []Row{
+		{Date: "2018-11-02", Cluster: "prod", OwnerType: "namespace", Owner: "shop", CPUHours: 24, MemoryGBHours: 48.5,
+			StorageGBHours: 240, CPUCost: 0.6, MemoryCost: 0.24, StorageCost: 0.1, TotalCost: 0.94, PriceVersion: "v1"},
+		{Date: "1970-01-01", Cluster: "prod", OwnerType: "group", Owner: "team-ä", CPUHours: 0.25,
+			TotalCost: -1.5, PriceVersion: ""},
+	}
+	data, contentType, err := encodeRows(Parquet, rows)
+	utils.Ok(t, err)
+	utils.Equals(t, "application/vnd.apache.parquet", contentType)
+
+	// PAR1, column chunks, file metadata, length of the metadata, PAR1
+	utils.Equals(t, parquetMagic, string(data[:4]))
+	utils.Equals(t, parquetMagic, string(data[len(data)-4:]))
+	metadataSize := int(binary.LittleEndian.Uint32(data[len(data)-8 : len(data)-4]))
+	metadataStart := len(data) - 8 - metadataSize
+	r := &thriftReader{data: data[:len(data)-8], pos: metadataStart}
+	metadata := r.readStruct()
+	utils.Equals(t, len(data)-8, r.pos)
+
+	utils.Equals(t, int64(parquetFileVersion), metadata[1])
+	utils.Equals(t, int64(len(rows)), metadata[3])
+	utils.Equals(t, parquetCreatedBy, metadata[6])
+	schema := metadata[2].([]interface{})
+	utils.Equals(t, len(columns)+1, len(schema))
+	root := schema[0].(map[int16]interface{})
+	utils.Equals(t, "schema", root[4])
+	utils.Equals(t, int64(len(columns)), root[5])
+	rowGroups := metadata[4].([]interface{})
+	utils.Equals(t, 1, len(rowGroups))
+	rowGroup := rowGroups[0].(map[int16]interface{})
+	utils.Equals(t, int64(len(rows)), rowGroup[3])
+	chunks := rowGroup[1].([]interface{})
+	utils.Equals(t, len(columns), len(chunks))
+
+	var totalSize int64
+	nextOffset := int64(len(parquetMagic))
+	for i, c := range columns {
+		element := schema[i+1].(map[int16]interface{})
+		utils.Equals(t, c.name, element[4])
+		utils.Equals(t, int64(parquetRequired), element[3])
+		physicalType := element[1].(int64)
+		switch c.bigQueryType {
+		case "DATE":
+			utils.Equals(t, int64(parquetInt32), physicalType)
+			utils.Equals(t, int64(parquetDate), element[6])
+		case "FLOAT64":
+			utils.Equals(t, int64(parquetDouble), physicalType)
+			_, hasConvertedType := element[6]
+			utils.Assert(t, !hasConvertedType, "converted type of column %s: %v", c.name, element[6])
+		default:
+			utils.Equals(t, int64(parquetByteArray), physicalType)
+			utils.Equals(t, int64(parquetUTF8), element[6])
+		}
+
+		chunk := chunks[i].(map[int16]interface{})
+		meta := chunk[3].(map[int16]interface{})
+		utils.Equals(t, physicalType, meta[1])
+		utils.Equals(t, []interface{}{c.name}, meta[3])
+		utils.Equals(t, int64(parquetGzip), meta[4])
+		utils.Equals(t, int64(len(rows)), meta[5])
+		offset := meta[9].(int64)
+		utils.Equals(t, offset, chunk[2])
+		// the column chunks follow each other from the magic to the metadata
+		utils.Equals(t, nextOffset, offset)
+		nextOffset = offset + meta[7].(int64)
+		totalSize += meta[6].(int64)
+
+		page := &thriftReader{data: data, pos: int(offset)}
+		header := page.readStruct()
+		utils.Equals(t, int64(parquetDataPage), header[1])
+		dataPage := header[5].(map[int16]interface{})
+		utils.Equals(t, int64(len(rows)), dataPage[1])
+		utils.Equals(t, int64(parquetPlain), dataPage[2])
+		compressedSize := int(header[3].(int64))
+		utils.Equals(t, nextOffset, int64(page.pos+compressedSize))
+		reader, err := gzip.NewReader(bytes.NewReader(data[page.pos : page.pos+compressedSize]))
+		utils.Ok(t, err)
+		values, err := ioutil.ReadAll(reader)
+		utils.Ok(t, err)
+		utils.Equals(t, header[2], int64(len(values)))
+		utils.Equals(t, meta[6], int64(page.pos)-offset+int64(len(values)))
+
+		for _, row := range rows {
+			expected := row.values()[i]
+			switch physicalType {
+			case parquetInt32:
+				days := int32(binary.LittleEndian.Uint32(values))
+				values = values[4:]
+				utils.Equals(t, expected, time.Unix(int64(days)*secondsInDay, 0).UTC().Format(parquetDateEncoding))
+			case parquetDouble:
+				utils.Equals(t, expected, math.Float64frombits(binary.LittleEndian.Uint64(values)))
+				values = values[8:]
+			default:
+				size := binary.LittleEndian.Uint32(values)
+				utils.Equals(t, expected, string(values[4:4+size]))
+				values = values[4+size:]
+			}
+		}
+		utils.Equals(t, 0, len(values))
+	}
+	utils.Equals(t, int64(metadataStart), nextOffset)
+	utils.Equals(t, totalSize, rowGroup[2])
+}
+
+func TestEncodeParquetInvalidValues(t *testing.T) {
+	schema := parquetColumns()
+	_, err := encodeParquet(schema, [][]interface{}{Row{Date: "02/11/2018"}.values()})
+	utils.Assert(t, err != nil, "date not in yyyy-mm-dd is encoded")
+
+	record := Row{Date: "2018-11-02"}.values()
+	record[4] = "24"
+	_, err = encodeParquet(schema, [][]interface{}{record})
+	utils.Assert(t, err != nil, "string value of a double column is encoded")
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/aggregation"
+)
+
+// Report job states
+const (
+	ReportRunning   = "running"
+	ReportCompleted = "completed"
+	ReportFailed    = "failed"
+	ReportCancelled = "cancelled"
+)
+
+const (
+	defaultReportRetention = 24 * time.Hour
+	maxRunningReports      = 2
+	// maxReportJobs and maxReportArtifactBytes bound the memory held by the jobs and their artifacts
+	maxReportJobs          = 10
+	maxReportArtifactBytes = 32 << 20
+)
+
+// ReportJob builds the cost allocation report of a window of days in the background. The report is kept in memory
+// to be downloaded until the job expires and it is also pushed to the object store sinks if asked. Reports larger
+// than maxReportArtifactBytes are only pushed to the sinks.
+//
+// Jobs live in the memory of the controller which runs them: they are lost on restart and, with several replicas
+// behind a service, a job can only be polled and downloaded from the replica which started it.
+type ReportJob struct {
+	ID            string   `json:"id"`
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Format        string   `json:"format"`
+	Push          bool     `json:"push"`
+	TotalDays     int      `json:"totalDays"`
+	CompletedDays int      `json:"completedDays"`
+	Rows          int      `json:"rows"`
+	Size          int      `json:"size"`
+	PushedTo      []string `json:"pushedTo,omitempty"`
+	Status        string   `json:"status"`
+	Error         string   `json:"error,omitempty"`
+	StartTime     string   `json:"startTime"`
+	EndTime       string   `json:"endTime,omitempty"`
+	ExpiryTime    string   `json:"expiryTime,omitempty"`
+}
+
+// Artifact is the content of a completed report
+type Artifact struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+type reportJob struct {
+	ReportJob
+	artifact *Artifact
+	cancel   context.CancelFunc
+	expiry   time.Time
+}
+
+var (
+	reportsMu       sync.Mutex
+	reports         = map[string]*reportJob{}
+	reportRetention = defaultReportRetention
+	reportRows      = rowsOfDay
+)
+
+func setReportRetention(retention string) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	reportRetention = defaultReportRetention
+	if retention == "" {
+		return
+	}
+	duration, err := time.ParseDuration(retention)
+	if err != nil || duration <= 0 {
+		log.Errorf("invalid report retention: %q, using %v", retention, defaultReportRetention)
+		return
+	}
+	reportRetention = duration
+}
+
+// StartReport starts a job building the cost allocation report of all days from `from` to `to` (both inclusive)
+// in the given format. At most maxRunningReports jobs run at a time and maxReportJobs jobs are kept.
+func StartReport(from, to time.Time, format string, push bool) (ReportJob, error) {
+	from, to = aggregation.GetDayStart(from), aggregation.GetDayStart(to)
+	if to.Before(from) {
+		return ReportJob{}, fmt.Errorf("invalid window, from: %s is after to: %s", from.Format(dateFormat), to.Format(dateFormat))
+	}
+	if format == "" {
+		format = CSV
+	}
+	if format != CSV && format != JSONLines && format != Parquet {
+		return ReportJob{}, fmt.Errorf("unknown report format: %s", format)
+	}
+
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	running := 0
+	for _, job := range reports {
+		if job.Status == ReportRunning {
+			running++
+		}
+	}
+	if running >= maxRunningReports {
+		return ReportJob{}, fmt.Errorf("%d report jobs are already running, retry later", running)
+	}
+	pruneReports(time.Now())
+	if len(reports) >= maxReportJobs {
+		return ReportJob{}, fmt.Errorf("%d report jobs are kept, delete the finished ones or retry later", len(reports))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &reportJob{
+		ReportJob: ReportJob{
+			ID:        fmt.Sprintf("report-%d", time.Now().UnixNano()),
+			From:      from.Format(dateFormat),
+			To:        to.Format(dateFormat),
+			Format:    format,
+			Push:      push,
+			TotalDays: int((to.Sub(from).Hours()+12)/24) + 1,
+			Status:    ReportRunning,
+			StartTime: time.Now().Format(time.RFC3339),
+		},
+		cancel: cancel,
+	}
+	reports[job.ID] = job
+	go runReport(ctx, job, from, to, reportRows)
+	return job.ReportJob, nil
+}
+
+// GetReport returns the report job with the given id
+func GetReport(id string) (ReportJob, bool) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	job, isPresent := reports[id]
+	if !isPresent {
+		return ReportJob{}, false
+	}
+	return job.ReportJob, true
+}
+
+// GetReports returns all report jobs sorted by start time
+func GetReports() []ReportJob {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	allJobs := []ReportJob{}
+	for _, job := range reports {
+		allJobs = append(allJobs, job.ReportJob)
+	}
+	sort.Slice(allJobs, func(i, j int) bool {
+		return allJobs[i].StartTime < allJobs[j].StartTime
+	})
+	return allJobs
+}
+
+// GetReportArtifact returns the artifact of the completed report job with the given id
+func GetReportArtifact(id string) (Artifact, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	job, isPresent := reports[id]
+	if !isPresent {
+		return Artifact{}, fmt.Errorf("report job: %s not found", id)
+	}
+	if job.artifact == nil {
+		return Artifact{}, fmt.Errorf("report job: %s is %s, it has no artifact", id, job.Status)
+	}
+	return *job.artifact, nil
+}
+
+// CancelReport cancels the report job with the given id if it is running, finished jobs are deleted along with
+// their artifact
+func CancelReport(id string) (ReportJob, error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	job, isPresent := reports[id]
+	if !isPresent {
+		return ReportJob{}, fmt.Errorf("report job: %s not found", id)
+	}
+	if job.Status != ReportRunning {
+		delete(reports, id)
+		return job.ReportJob, nil
+	}
+	job.cancel()
+	finishReport(job, ReportCancelled, nil)
+	return job.ReportJob, nil
+}
+
+// PruneReports deletes the finished report jobs which expired
+func PruneReports() {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	pruneReports(time.Now())
+}
+
+// pruneReports deletes the finished report jobs expired at now, reportsMu must be held
+func pruneReports(now time.Time) {
+	for id, job := range reports {
+		if job.Status != ReportRunning && now.After(job.expiry) {
+			log.Debugf("report job: (%s) expired", id)
+			delete(reports, id)
+		}
+	}
+}
+
+func runReport(ctx context.Context, job *reportJob, from, to time.Time, rowsOf func(time.Time) ([]Row, error)) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("report job: (%s) crashed: %v\n%s", job.ID, r, debug.Stack())
+			endReport(job, ReportFailed, nil, fmt.Errorf("report job crashed: %v", r))
+		}
+	}()
+	log.Infof("report job: (%s) started for window: (%s, %s)", job.ID, job.From, job.To)
+	var rows []Row
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if ctx.Err() != nil {
+			log.Infof("report job: (%s) cancelled", job.ID)
+			return
+		}
+		dayRows, err := rowsOf(day)
+		if err != nil {
+			log.Errorf("report job: (%s) failed for day: (%s), error: (%v)", job.ID, day.Format(dateFormat), err)
+			endReport(job, ReportFailed, nil, err)
+			return
+		}
+		rows = append(rows, dayRows...)
+		reportsMu.Lock()
+		job.CompletedDays++
+		job.Rows = len(rows)
+		reportsMu.Unlock()
+	}
+
+	data, contentType, err := encodeRows(job.Format, rows)
+	if err != nil {
+		endReport(job, ReportFailed, nil, err)
+		return
+	}
+	if len(data) > maxReportArtifactBytes && !job.Push {
+		endReport(job, ReportFailed, nil, fmt.Errorf("report of %d bytes exceeds the limit of %d bytes, push it to "+
+			"the object store sinks or narrow the window", len(data), maxReportArtifactBytes))
+		return
+	}
+	artifact := &Artifact{
+		Name:        fmt.Sprintf("cost-allocation-%s-%s.%s", job.From, job.To, job.Format),
+		ContentType: contentType,
+		Data:        data,
+	}
+	// an artifact too large to be kept in memory is only in the object store sinks
+	kept := artifact
+	if len(data) > maxReportArtifactBytes {
+		kept = nil
+	}
+	if job.Push {
+		pushed, err := pushReport(job.ID, artifact)
+		reportsMu.Lock()
+		job.PushedTo = pushed
+		reportsMu.Unlock()
+		if err != nil {
+			endReport(job, ReportFailed, kept, err)
+			return
+		}
+	}
+	log.Infof("report job: (%s) completed with %d rows", job.ID, len(rows))
+	reportsMu.Lock()
+	job.Size = len(data)
+	reportsMu.Unlock()
+	endReport(job, ReportCompleted, kept, nil)
+}
+
+// pushReport writes the artifact to <prefix>/reports/<job id>/<artifact name> of every object store sink and
+// returns the sinks it was written to
+func pushReport(id string, artifact *Artifact) ([]string, error) {
+	mu.Lock()
+	sinks := []*objectSink{}
+	for _, exporter := range exporters {
+		if sink, isSink := exporter.(*objectSink); isSink {
+			sinks = append(sinks, sink)
+		}
+	}
+	mu.Unlock()
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no object store sink is configured")
+	}
+
+	pushed := []string{}
+	for _, sink := range sinks {
+		key := path.Join(sink.settings.Prefix, "reports", id, artifact.Name)
+		if err := sink.uploader.put(key, artifact.ContentType, artifact.Data); err != nil {
+			return pushed, fmt.Errorf("push to %s failed: %v", sink.Name(), err)
+		}
+		pushed = append(pushed, sink.Name())
+	}
+	return pushed, nil
+}
+
+// endReport finishes the job unless it was cancelled meanwhile
+func endReport(job *reportJob, status string, artifact *Artifact, err error) {
+	reportsMu.Lock()
+	defer reportsMu.Unlock()
+	if job.Status != ReportRunning {
+		return
+	}
+	job.artifact = artifact
+	finishReport(job, status, err)
+}
+
+// finishReport sets the final status of the job, reportsMu must be held
+func finishReport(job *reportJob, status string, err error) {
+	job.Status = status
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if job.artifact != nil {
+		job.Size = len(job.artifact.Data)
+	}
+	now := time.Now()
+	job.expiry = now.Add(reportRetention)
+	job.EndTime = now.Format(time.RFC3339)
+	job.ExpiryTime = job.expiry.Format(time.RFC3339)
+}
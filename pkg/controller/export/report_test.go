@@ -0,0 +1,196 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package export
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// stubReportRows replaces the rows of the days read by the report jobs until the returned func is called
+func stubReportRows(rows func(day time.Time) ([]Row, error)) func() {
+	reportsMu.Lock()
+	reportRows = rows
+	reportsMu.Unlock()
+	return func() {
+		reportsMu.Lock()
+		reportRows = rowsOfDay
+		reports = map[string]*reportJob{}
+		reportRetention = defaultReportRetention
+		reportsMu.Unlock()
+	}
+}
+
+func waitForReport(t *testing.T, id string) ReportJob {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, isPresent := GetReport(id)
+		utils.Assert(t, isPresent, "report job: %s not found", id)
+		if job.Status != ReportRunning {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("report job: %s is still running", id)
+	return ReportJob{}
+}
+
+func TestReportLifecycle(t *testing.T) {
+	defer stubReportRows(func(day time.Time) ([]Row, error) {
+		return []Row{{Date: day.Format(dateFormat), Owner: "shop", CPUCost: 0.5}}, nil
+	})()
+
+	from := time.Date(2018, 11, 1, 10, 0, 0, 0, time.Local)
+	started, err := StartReport(from, from.AddDate(0, 0, 2), "", false)
+	utils.Ok(t, err)
+	utils.Equals(t, ReportRunning, started.Status)
+	utils.Equals(t, 3, started.TotalDays)
+	utils.Equals(t, CSV, started.Format)
+
+	job := waitForReport(t, started.ID)
+	utils.Equals(t, ReportCompleted, job.Status)
+	utils.Equals(t, 3, job.CompletedDays)
+	utils.Equals(t, 3, job.Rows)
+	utils.Assert(t, job.ExpiryTime != "", "completed job has no expiry time")
+
+	artifact, err := GetReportArtifact(started.ID)
+	utils.Ok(t, err)
+	utils.Equals(t, "cost-allocation-2018-11-01-2018-11-03.csv", artifact.Name)
+	utils.Equals(t, "text/csv", artifact.ContentType)
+	utils.Equals(t, job.Size, len(artifact.Data))
+	utils.Assert(t, strings.Contains(string(artifact.Data), "2018-11-03"), "report misses the last day:\n%s", artifact.Data)
+	utils.Equals(t, 1, len(GetReports()))
+
+	// deleting a finished job drops it along with its artifact
+	_, err = CancelReport(started.ID)
+	utils.Ok(t, err)
+	_, isPresent := GetReport(started.ID)
+	utils.Assert(t, !isPresent, "deleted job is still listed")
+}
+
+func TestStartReportValidation(t *testing.T) {
+	day := time.Date(2018, 11, 2, 0, 0, 0, 0, time.Local)
+	_, err := StartReport(day, day.AddDate(0, 0, -1), CSV, false)
+	utils.Assert(t, err != nil, "report of an inverted window started")
+	_, err = StartReport(day, day, "xlsx", false)
+	utils.Assert(t, err != nil, "report of an unknown format started")
+}
+
+func TestReportFailures(t *testing.T) {
+	day := time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		rows  func(day time.Time) ([]Row, error)
+		error string
+	}{
+		{"query error", func(time.Time) ([]Row, error) { return nil, errors.New("dgraph is unreachable") },
+			"dgraph is unreachable"},
+		{"panic", func(time.Time) ([]Row, error) { panic("nil summary") }, "report job crashed: nil summary"},
+	}
+	for _, test := range tests {
+		restore := stubReportRows(test.rows)
+		started, err := StartReport(day, day, JSONLines, false)
+		utils.Ok(t, err)
+		job := waitForReport(t, started.ID)
+		utils.Assert(t, job.Status == ReportFailed, "%s: job is %s", test.name, job.Status)
+		utils.Assert(t, job.Error == test.error, "%s: error %q, want %q", test.name, job.Error, test.error)
+		_, err = GetReportArtifact(started.ID)
+		utils.Assert(t, err != nil, "%s: failed job has an artifact", test.name)
+		restore()
+	}
+}
+
+func TestCancelRunningReport(t *testing.T) {
+	release := make(chan struct{})
+	defer stubReportRows(func(day time.Time) ([]Row, error) {
+		<-release
+		return []Row{{Date: day.Format(dateFormat)}}, nil
+	})()
+
+	day := time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local)
+	started, err := StartReport(day, day.AddDate(0, 0, 5), CSV, false)
+	utils.Ok(t, err)
+	cancelled, err := CancelReport(started.ID)
+	utils.Ok(t, err)
+	utils.Equals(t, ReportCancelled, cancelled.Status)
+	close(release)
+
+	// the job stops at the next day and keeps its cancelled status
+	time.Sleep(20 * time.Millisecond)
+	job, isPresent := GetReport(started.ID)
+	utils.Assert(t, isPresent, "cancelled job is not kept until it expires")
+	utils.Equals(t, ReportCancelled, job.Status)
+	_, err = GetReportArtifact(started.ID)
+	utils.Assert(t, err != nil, "cancelled job has an artifact")
+}
+
+func TestReportExpiry(t *testing.T) {
+	release := make(chan struct{})
+	defer stubReportRows(func(day time.Time) ([]Row, error) {
+		if day.Day() == 2 {
+			<-release
+		}
+		return []Row{{Date: day.Format(dateFormat)}}, nil
+	})()
+	defer close(release)
+	setReportRetention("1h")
+
+	day := time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local)
+	finished, err := StartReport(day, day, CSV, false)
+	utils.Ok(t, err)
+	waitForReport(t, finished.ID)
+	running, err := StartReport(day.AddDate(0, 0, 1), day.AddDate(0, 0, 1), CSV, false)
+	utils.Ok(t, err)
+
+	reportsMu.Lock()
+	pruneReports(time.Now().Add(30 * time.Minute))
+	utils.Equals(t, 2, len(reports))
+	pruneReports(time.Now().Add(2 * time.Hour))
+	_, finishedKept := reports[finished.ID]
+	_, runningKept := reports[running.ID]
+	reportsMu.Unlock()
+	utils.Assert(t, !finishedKept, "expired job is kept")
+	utils.Assert(t, runningKept, "running job was pruned")
+}
+
+func TestReportJobsAreCapped(t *testing.T) {
+	defer stubReportRows(func(day time.Time) ([]Row, error) { return nil, nil })()
+
+	day := time.Date(2018, 11, 1, 0, 0, 0, 0, time.Local)
+	reportsMu.Lock()
+	for i := 0; i < maxReportJobs; i++ {
+		id := "report-" + string(rune('a'+i))
+		reports[id] = &reportJob{ReportJob: ReportJob{ID: id, Status: ReportCompleted}, expiry: time.Now().Add(time.Hour)}
+	}
+	reportsMu.Unlock()
+	_, err := StartReport(day, day, CSV, false)
+	utils.Assert(t, err != nil, "report started beyond %d kept jobs", maxReportJobs)
+
+	// expired jobs make room for new ones
+	reportsMu.Lock()
+	reports["report-a"].expiry = time.Now().Add(-time.Minute)
+	reportsMu.Unlock()
+	started, err := StartReport(day, day, CSV, false)
+	utils.Ok(t, err)
+	waitForReport(t, started.ID)
+	_, isPresent := GetReport("report-a")
+	utils.Assert(t, !isPresent, "expired job is kept")
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	awsDateFormat     = "20060102"
+	awsDateTimeFormat = "20060102T150405Z"
+	defaultS3Region   = "us-east-1"
+)
+
+// s3Uploader writes objects with the S3 REST API, requests are signed with AWS signature version 4
+type s3Uploader struct {
+	settings SinkSettings
+}
+
+func (u *s3Uploader) put(key, contentType string, data []byte) error {
+	_, err := s3Request(u.settings, http.MethodPut, key, nil, contentType, data)
+	return err
+}
+
+// s3ListResult is the response of the ListObjectsV2 request
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListS3Objects returns the keys of the objects of the bucket with the prefix which sort after startAfter, in
+// ascending order. Credentials are read from the environment as for the s3 sinks.
+func ListS3Objects(settings SinkSettings, prefix, startAfter string) ([]string, error) {
+	var keys []string
+	params := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if startAfter != "" {
+		params.Set("start-after", startAfter)
+	}
+	for {
+		data, err := s3Request(settings, http.MethodGet, "", params, "", nil)
+		if err != nil {
+			return nil, err
+		}
+		result := s3ListResult{}
+		if err = xml.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		for _, content := range result.Contents {
+			keys = append(keys, content.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		params.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// GetS3Object returns the content of the object of the bucket. Credentials are read from the environment as for the
+// s3 sinks.
+func GetS3Object(settings SinkSettings, key string) ([]byte, error) {
+	return s3Request(settings, http.MethodGet, key, nil, "", nil)
+}
+
+// s3Request sends a request for the object with the key (the bucket if it is empty) signed with the credentials of
+// the environment and returns the response body
+func s3Request(settings SinkSettings, method, key string, params url.Values, contentType string, data []byte) ([]byte, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("aws credentials are not set")
+	}
+	region := settings.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	// virtual hosted style for AWS, path style for S3 compatible endpoints
+	objectURL := "https://" + settings.Bucket + ".s3." + region + ".amazonaws.com/" + uriEncode(key, false)
+	if settings.Endpoint != "" {
+		objectURL = strings.TrimSuffix(settings.Endpoint, "/") + "/" + settings.Bucket + "/" + uriEncode(key, false)
+	}
+	canonicalQuery := canonicalQueryString(params)
+	if canonicalQuery != "" {
+		objectURL += "?" + canonicalQuery
+	}
+	parsedURL, err := url.Parse(objectURL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	payloadHash := sha256Hex(data)
+	headers := map[string]string{
+		"host":                 parsedURL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format(awsDateTimeFormat),
+	}
+	if contentType != "" {
+		headers["content-type"] = contentType
+	}
+	if sessionToken := os.Getenv("AWS_SESSION_TOKEN"); sessionToken != "" {
+		headers["x-amz-security-token"] = sessionToken
+	}
+	headers["Authorization"] = signV4(method, parsedURL.EscapedPath(), canonicalQuery, headers, payloadHash, region, accessKey, secretKey, now)
+	delete(headers, "host")
+
+	response, _, err := doRequest(method, objectURL, headers, data)
+	return response, err
+}
+
+// canonicalQueryString returns the query parameters sorted by name with names and values percent-encoded, as
+// signed by AWS signature version 4
+func canonicalQueryString(params url.Values) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range params[name] {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// signV4 returns the authorization header of a request signed with AWS signature version 4. All given headers are
+// signed, canonicalQuery is the canonical query string of the request.
+func signV4(method, escapedPath, canonicalQuery string, headers map[string]string, payloadHash, region, accessKey, secretKey string, now time.Time) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{method, escapedPath, canonicalQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := now.Format(awsDateFormat) + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", now.Format(awsDateTimeFormat), scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), now.Format(awsDateFormat))
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	return "AWS4-HMAC-SHA256 Credential=" + accessKey + "/" + scope + ", SignedHeaders=" + signedHeaders + ", Signature=" + signature
+}
+
+// uriEncode percent-encodes (RFC 3986) every byte except unreserved characters, slashes are kept unless encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			encoded.WriteString(fmt.Sprintf("%%%02X", b))
+		}
+	}
+	return encoded.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
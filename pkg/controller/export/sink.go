@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// uploader writes an object to an object store
+type uploader interface {
+	put(key, contentType string, data []byte) error
+}
+
+// objectSink writes the rows of a day as an object partitioned by date
+type objectSink struct {
+	settings SinkSettings
+	uploader uploader
+}
+
+func newObjectSink(settings SinkSettings) (*objectSink, error) {
+	if settings.Format == "" {
+		settings.Format = CSV
+	}
+	sink := &objectSink{settings: settings}
+	switch settings.Type {
+	case S3:
+		sink.uploader = &s3Uploader{settings: settings}
+	case GCS:
+		sink.uploader = &gcsUploader{settings: settings}
+	case Azure:
+		sink.uploader = &azureUploader{settings: settings}
+	default:
+		return nil, fmt.Errorf("unknown object store type: %s", settings.Type)
+	}
+	return sink, nil
+}
+
+// Name returns the sink name
+func (s *objectSink) Name() string {
+	return s.settings.Type + "://" + s.settings.Bucket + "/" + s.settings.Prefix
+}
+
+// EnsureSchema does nothing, objects carry their own columns
+func (s *objectSink) EnsureSchema() error {
+	return nil
+}
+
+// Export overwrites the object of the day with the given rows
+func (s *objectSink) Export(day string, rows []Row) error {
+	data, contentType, err := encodeRows(s.settings.Format, rows)
+	if err != nil {
+		return err
+	}
+	name := cluster
+	if name == "" {
+		name = "cost-allocation"
+	}
+	key := path.Join(s.settings.Prefix, "date="+day, name+"."+s.settings.Format)
+	return s.uploader.put(key, contentType, data)
+}
+
+// gcsUploader writes objects with the GCS JSON API media upload
+type gcsUploader struct {
+	settings SinkSettings
+}
+
+func (u *gcsUploader) put(key, contentType string, data []byte) error {
+	token, err := googleToken(u.settings.TokenFile)
+	if err != nil {
+		return err
+	}
+	objectURL := "https://storage.googleapis.com/upload/storage/v1/b/" + u.settings.Bucket +
+		"/o?uploadType=media&name=" + url.QueryEscape(key)
+	headers := map[string]string{"Authorization": "Bearer " + token, "Content-Type": contentType}
+	_, _, err = doRequest(http.MethodPost, objectURL, headers, data)
+	return err
+}
+
+// azureUploader writes block blobs authorized by a SAS token
+type azureUploader struct {
+	settings SinkSettings
+}
+
+func (u *azureUploader) put(key, contentType string, data []byte) error {
+	sasToken, err := readToken(u.settings.TokenFile)
+	if err != nil {
+		return err
+	}
+	blobURL := "https://" + u.settings.Account + ".blob.core.windows.net/" + u.settings.Bucket + "/" +
+		uriEncode(key, false) + "?" + strings.TrimPrefix(sasToken, "?")
+	headers := map[string]string{"x-ms-blob-type": "BlockBlob", "Content-Type": contentType}
+	_, _, err = doRequest(http.MethodPut, blobURL, headers, data)
+	return err
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// snowflakeBatchSize is the number of rows inserted by a statement, it keeps the bindings of a statement small
+const snowflakeBatchSize = 500
+
+// snowflakeExporter writes rows into a Snowflake table using the SQL API
+type snowflakeExporter struct {
+	settings SnowflakeSettings
+}
+
+type snowflakeBinding struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type snowflakeStatement struct {
+	Statement string                      `json:"statement"`
+	Warehouse string                      `json:"warehouse,omitempty"`
+	Database  string                      `json:"database"`
+	Schema    string                      `json:"schema"`
+	Role      string                      `json:"role,omitempty"`
+	Bindings  map[string]snowflakeBinding `json:"bindings,omitempty"`
+}
+
+// Name returns the exporter name
+func (e *snowflakeExporter) Name() string {
+	return "snowflake"
+}
+
+// EnsureSchema creates the table if it is missing and adds the columns it is missing
+func (e *snowflakeExporter) EnsureSchema() error {
+	definitions := make([]string, len(columns))
+	for i, c := range columns {
+		definitions[i] = c.name + " " + c.snowflakeType
+	}
+	err := e.execute("CREATE TABLE IF NOT EXISTS "+e.settings.Table+" ("+strings.Join(definitions, ", ")+")", nil)
+	if err != nil {
+		return err
+	}
+	for _, definition := range definitions {
+		err = e.execute("ALTER TABLE "+e.settings.Table+" ADD COLUMN IF NOT EXISTS "+definition, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export replaces the rows of the cluster for the given day with the new rows
+func (e *snowflakeExporter) Export(day string, rows []Row) error {
+	err := e.execute("DELETE FROM "+e.settings.Table+" WHERE date = ? AND cluster = ?", []interface{}{day, cluster})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.name
+		placeholders[i] = "?"
+	}
+	rowPlaceholder := "(" + strings.Join(placeholders, ", ") + ")"
+	for start := 0; start < len(rows); start += snowflakeBatchSize {
+		end := start + snowflakeBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		var values []interface{}
+		rowPlaceholders := make([]string, 0, end-start)
+		for _, row := range rows[start:end] {
+			values = append(values, row.values()...)
+			rowPlaceholders = append(rowPlaceholders, rowPlaceholder)
+		}
+		statement := "INSERT INTO " + e.settings.Table + " (" + strings.Join(names, ", ") + ") VALUES " + strings.Join(rowPlaceholders, ", ")
+		if err = e.execute(statement, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *snowflakeExporter) execute(statement string, values []interface{}) error {
+	token, err := readToken(e.settings.TokenFile)
+	if err != nil {
+		return err
+	}
+	request := snowflakeStatement{
+		Statement: statement,
+		Warehouse: e.settings.Warehouse,
+		Database:  e.settings.Database,
+		Schema:    e.settings.Schema,
+		Role:      e.settings.Role,
+		Bindings:  make(map[string]snowflakeBinding),
+	}
+	for i, value := range values {
+		request.Bindings[strconv.Itoa(i+1)] = newSnowflakeBinding(value)
+	}
+	headers := map[string]string{
+		"Authorization":                        "Bearer " + token,
+		"X-Snowflake-Authorization-Token-Type": "OAUTH",
+	}
+	url := "https://" + e.settings.Account + ".snowflakecomputing.com/api/v2/statements"
+	_, err = doJSON(http.MethodPost, url, headers, request, nil)
+	return err
+}
+
+func newSnowflakeBinding(value interface{}) snowflakeBinding {
+	switch v := value.(type) {
+	case float64:
+		return snowflakeBinding{Type: "REAL", Value: strconv.FormatFloat(v, 'f', -1, 64)}
+	default:
+		return snowflakeBinding{Type: "TEXT", Value: fmt.Sprint(v)}
+	}
+}
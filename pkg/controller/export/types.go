@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package export
+
+// Row is the cost allocation of a namespace or group for a day. It is the unit of data pushed to warehouses.
+type Row struct {
+	Date           string  `json:"date"`
+	Cluster        string  `json:"cluster"`
+	OwnerType      string  `json:"owner_type"`
+	Owner          string  `json:"owner"`
+	CPUHours       float64 `json:"cpu_hours"`
+	MemoryGBHours  float64 `json:"memory_gb_hours"`
+	StorageGBHours float64 `json:"storage_gb_hours"`
+	CPUCost        float64 `json:"cpu_cost"`
+	MemoryCost     float64 `json:"memory_cost"`
+	StorageCost    float64 `json:"storage_cost"`
+	TotalCost      float64 `json:"total_cost"`
+	PriceVersion   string  `json:"price_version"`
+}
+
+// Exporter pushes allocation rows to a warehouse table.
+type Exporter interface {
+	Name() string
+	// EnsureSchema creates the table if it is missing and adds the columns it is missing
+	EnsureSchema() error
+	// Export writes the rows of a day, replacing the rows previously exported for that day if the warehouse allows it
+	Export(day string, rows []Row) error
+}
+
+// Settings of the warehouse exporters. Exporters without settings are disabled.
+type Settings struct {
+	Cluster   string             `json:"cluster,omitempty"`
+	BigQuery  *BigQuerySettings  `json:"bigQuery,omitempty"`
+	Snowflake *SnowflakeSettings `json:"snowflake,omitempty"`
+	Sinks     []SinkSettings     `json:"sinks,omitempty"`
+	// ReportRetention is how long the finished report jobs and their artifacts are kept (ex: 12h), 24h by default.
+	ReportRetention string `json:"reportRetention,omitempty"`
+}
+
+// BigQuerySettings locate the BigQuery table. The access token is read from TokenFile if given,
+// otherwise it is requested from the GCE metadata server (workload identity).
+type BigQuerySettings struct {
+	Project   string `json:"project"`
+	Dataset   string `json:"dataset"`
+	Table     string `json:"table"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// SnowflakeSettings locate the Snowflake table. TokenFile holds an OAuth access token for the SQL API.
+type SnowflakeSettings struct {
+	Account   string `json:"account"`
+	Warehouse string `json:"warehouse,omitempty"`
+	Database  string `json:"database"`
+	Schema    string `json:"schema"`
+	Table     string `json:"table"`
+	Role      string `json:"role,omitempty"`
+	TokenFile string `json:"tokenFile"`
+}
+
+// Object store types
+const (
+	S3    = "s3"
+	GCS   = "gcs"
+	Azure = "azure"
+)
+
+// Output formats of object store sinks
+const (
+	CSV       = "csv"
+	JSONLines = "jsonl"
+	Parquet   = "parquet"
+)
+
+// SinkSettings of an object store sink. Rows of a day are written to <prefix>/date=<yyyy-mm-dd>/<cluster>.<format>.
+// S3 credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment
+// variables, Endpoint is used for S3 compatible stores. GCS token is read from TokenFile or from the GCE metadata
+// server. Azure SAS token is read from TokenFile and Bucket is the blob container of the storage Account.
+type SinkSettings struct {
+	Type      string `json:"type"`
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+	Account   string `json:"account,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// column of the allocation table, types are given for every warehouse
+type column struct {
+	name          string
+	bigQueryType  string
+	snowflakeType string
+}
+
+// columns of the allocation table in the order of Row fields. New columns must be appended.
+var columns = []column{
+	{name: "date", bigQueryType: "DATE", snowflakeType: "DATE"},
+	{name: "cluster", bigQueryType: "STRING", snowflakeType: "VARCHAR"},
+	{name: "owner_type", bigQueryType: "STRING", snowflakeType: "VARCHAR"},
+	{name: "owner", bigQueryType: "STRING", snowflakeType: "VARCHAR"},
+	{name: "cpu_hours", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "memory_gb_hours", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "storage_gb_hours", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "cpu_cost", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "memory_cost", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "storage_cost", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "total_cost", bigQueryType: "FLOAT64", snowflakeType: "FLOAT"},
+	{name: "price_version", bigQueryType: "STRING", snowflakeType: "VARCHAR"},
+}
+
+// values returns the values of the row in the order of columns
+func (row Row) values() []interface{} {
+	return []interface{}{row.Date, row.Cluster, row.OwnerType, row.Owner, row.CPUHours, row.MemoryGBHours,
+		row.StorageGBHours, row.CPUCost, row.MemoryCost, row.StorageCost, row.TotalCost, row.PriceVersion}
+}
@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Allocate computes the namespace costs of the cluster in its window the way the namespace costs api does: only the
+// part of the life of a resource inside the window is charged at the prices in effect during that part, the cpu and
+// memory allocated to pods by the allocation policy are charged with the premium (or spot discount) of their node, the
+// idle capacity of the node pools split by burst usage is shared among their burstable pods, the upfront costs are
+// amortized and the markups applied.
+func Allocate(cluster Cluster) (Allocation, error) {
+	from, err := time.Parse(time.RFC3339, cluster.From)
+	if err != nil {
+		return Allocation{}, fmt.Errorf("invalid from time of cluster: (%s), error: (%v)", cluster.Name, err)
+	}
+	to, err := time.Parse(time.RFC3339, cluster.To)
+	if err != nil {
+		return Allocation{}, fmt.Errorf("invalid to time of cluster: (%s), error: (%v)", cluster.Name, err)
+	}
+	a := allocator{from: from, to: to, periods: pricing.GetPriceHistory(), namespaces: map[string]*namespaceCost{},
+		pools: map[string]*nodePool{}}
+	_, policy := allocation.GetPolicy()
+	catalog := pricing.GetCatalog()
+
+	premiums := map[string]float64{}
+	burstPools := map[string]*nodePool{}
+	for _, node := range cluster.Nodes {
+		price := node.PricePerHour
+		if node.CapacityType == pricing.SpotCapacity {
+			price = pricing.SpotPrice(price, node.CPU, node.Memory)
+		}
+		premiums[node.Name] = pricing.PremiumOverList(price, node.CPU, node.Memory)
+		if allocation.IdleCostOf(node.NodePool) == allocation.IdleByBurst {
+			burstPools[node.Name] = a.nodePool(node, price)
+		}
+	}
+	for _, pod := range cluster.Pods {
+		lifetime, err := a.lifetime(pod.Start, pod.End)
+		if err != nil {
+			return Allocation{}, fmt.Errorf("invalid lifetime of pod: (%s/%s), error: (%v)", pod.Namespace, pod.Name, err)
+		}
+		ns := a.namespace(pod.Namespace)
+		hours := lifetime.hours()
+		premium := 1 + premiums[pod.Node]
+		cpu, memory := allocatePod(policy, pod)
+		ns.cost.CPU += cpu.Over(hours)
+		ns.cost.Memory += memory.Over(hours)
+		ns.cost.Storage += pod.Storage.Over(hours)
+		ns.cost.GPU += pod.GPU * hours
+		ns.cost.CPUCost += float64(cpu) * a.priceHours(lifetime, cpuPrice) * premium
+		ns.cost.MemoryCost += float64(memory) * a.priceHours(lifetime, memoryPrice) * premium
+		ns.cost.StorageCost += float64(pod.Storage) * a.priceHours(lifetime, storagePrice)
+		ns.cost.GPUCost += pod.GPU * a.priceHours(lifetime, gpuPrice)
+		if pool, isSplit := burstPools[pod.Node]; isSplit {
+			pool.idle -= (float64(cpu)*a.priceHours(lifetime, cpuPrice) + float64(memory)*a.priceHours(lifetime, memoryPrice)) *
+				premium
+			weight := allocation.BurstWeight(float64(pod.CPU), float64(pod.CPULimit), float64(pod.CPUUsage),
+				float64(pod.Memory), float64(pod.MemoryLimit), float64(pod.MemoryUsage), catalog.CPU, catalog.Memory) * hours
+			pool.usages = append(pool.usages, allocation.BurstUsage{Owner: pod.Namespace, Weight: weight})
+		}
+	}
+	for _, claim := range cluster.Claims {
+		lifetime, err := a.lifetime(claim.Start, claim.End)
+		if err != nil {
+			return Allocation{}, fmt.Errorf("invalid lifetime of claim in namespace: (%s), error: (%v)", claim.Namespace, err)
+		}
+		ns := a.namespace(claim.Namespace)
+		ns.resources.ClaimStorage += float64(claim.Storage.Over(lifetime.hours()))
+		ns.resources.ClaimCost += float64(claim.Storage) * a.priceHours(lifetime, storagePrice)
+	}
+	for _, snapshot := range cluster.Snapshots {
+		lifetime, err := a.lifetime(snapshot.Start, snapshot.End)
+		if err != nil {
+			return Allocation{}, fmt.Errorf("invalid lifetime of snapshot in namespace: (%s), error: (%v)", snapshot.Namespace, err)
+		}
+		ns := a.namespace(snapshot.Namespace)
+		ns.resources.SnapshotStorage += float64(snapshot.Storage.Over(lifetime.hours()))
+		ns.resources.SnapshotCost += float64(snapshot.Storage) * a.priceHours(lifetime, snapshotPrice)
+	}
+	for _, lb := range cluster.LoadBalancers {
+		lifetime, err := a.lifetime(lb.Start, lb.End)
+		if err != nil {
+			return Allocation{}, fmt.Errorf("invalid lifetime of load balancer in namespace: (%s), error: (%v)", lb.Namespace, err)
+		}
+		ns := a.namespace(lb.Namespace)
+		ns.resources.LoadBalancers += lifetime.hours()
+		ns.resources.LoadBalancerCost += a.priceHours(lifetime, loadBalancerPrice)
+	}
+
+	costs := []query.ResourceCost{}
+	for _, ns := range a.namespaces {
+		cost := ns.cost
+		cost.LineItems = query.NamespaceLineItems(cost, ns.resources)
+		for _, item := range cost.LineItems {
+			cost.TotalCost += item.Cost
+		}
+		costs = append(costs, cost)
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].Xid < costs[j].Xid
+	})
+	if allocation.IsIdleSplit() {
+		query.AddIdleShares(costs, a.idleShares())
+	}
+	query.DistributeAmortizedCosts(costs, from, to)
+	invoice.MarkUpNamespaceCosts(costs)
+	query.RoundCosts(costs)
+	return Allocation{Cluster: cluster.Name, From: cluster.From, To: cluster.To, Namespaces: costs}, nil
+}
+
+// allocatePod returns the cpus and memory charged to the pod by the policy, a pod whose usage was never sampled is
+// taken to use its requests
+func allocatePod(policy allocation.Policy, pod Pod) (utils.Cores, utils.GB) {
+	cpuUsage, memoryUsage := pod.CPU, pod.Memory
+	if pod.CPUUsage != 0 || pod.MemoryUsage != 0 {
+		cpuUsage, memoryUsage = pod.CPUUsage, pod.MemoryUsage
+	}
+	return utils.Cores(policy.Allocate(float64(pod.CPU), float64(cpuUsage))),
+		utils.GB(policy.Allocate(float64(pod.Memory), float64(memoryUsage)))
+}
+
+// nodePool is the idle cost of the nodes of a pool split by burst usage and the burst usage of their pods
+type nodePool struct {
+	idle   float64
+	usages []allocation.BurstUsage
+}
+
+// nodePool returns the pool of the node, its idle cost starts at the cost of the node in the window: its price or
+// its list price when it has none
+func (a *allocator) nodePool(node Node, price float64) *nodePool {
+	pool, ok := a.pools[node.NodePool]
+	if !ok {
+		pool = &nodePool{}
+		a.pools[node.NodePool] = pool
+	}
+	window := interval{start: a.from, end: a.to}
+	if price > 0 {
+		pool.idle += price * window.hours()
+	} else {
+		pool.idle += float64(node.CPU)*a.priceHours(window, cpuPrice) + float64(node.Memory)*a.priceHours(window, memoryPrice)
+	}
+	return pool
+}
+
+// idleShares returns the shares of the idle cost of the pools of every namespace
+func (a *allocator) idleShares() map[string]float64 {
+	shares := map[string]float64{}
+	for _, pool := range a.pools {
+		for namespace, share := range allocation.SplitIdleCost(pool.idle, pool.usages) {
+			shares[namespace] += share
+		}
+	}
+	return shares
+}
+
+type namespaceCost struct {
+	cost      query.ResourceCost
+	resources query.NamespaceResources
+}
+
+type allocator struct {
+	from, to   time.Time
+	periods    []pricing.PricePeriod
+	namespaces map[string]*namespaceCost
+	pools      map[string]*nodePool
+}
+
+// interval is the part of the life of a resource inside the window
+type interval struct {
+	start, end time.Time
+}
+
+func (i interval) hours() float64 {
+	if !i.end.After(i.start) {
+		return 0
+	}
+	return i.end.Sub(i.start).Hours()
+}
+
+func (a *allocator) namespace(name string) *namespaceCost {
+	ns, ok := a.namespaces[name]
+	if !ok {
+		ns = &namespaceCost{cost: query.ResourceCost{Xid: name, Name: "namespace-" + name}}
+		a.namespaces[name] = ns
+	}
+	return ns
+}
+
+// lifetime clips the life [start, end) of a resource to the window, an empty end is the end of the window
+func (a *allocator) lifetime(start, end string) (interval, error) {
+	i := interval{start: a.from, end: a.to}
+	started, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return i, err
+	}
+	if started.After(i.start) {
+		i.start = started
+	}
+	if end != "" {
+		ended, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			return i, err
+		}
+		if ended.Before(i.end) {
+			i.end = ended
+		}
+	}
+	return i, nil
+}
+
+// priceHours returns the sum over the price periods of the price in effect times the hours of the interval in the
+// period, the first period is in effect before its effective from time and the last one until the end of time
+func (a *allocator) priceHours(i interval, price func(pricing.PricePeriod) float64) float64 {
+	total := 0.0
+	for p, period := range a.periods {
+		slice := i
+		if p > 0 {
+			if effectiveFrom, err := time.Parse(time.RFC3339, period.EffectiveFrom); err == nil && effectiveFrom.After(slice.start) {
+				slice.start = effectiveFrom
+			}
+		}
+		if p < len(a.periods)-1 {
+			if nextFrom, err := time.Parse(time.RFC3339, a.periods[p+1].EffectiveFrom); err == nil && nextFrom.Before(slice.end) {
+				slice.end = nextFrom
+			}
+		}
+		total += price(period) * slice.hours()
+	}
+	return total
+}
+
+func cpuPrice(period pricing.PricePeriod) float64 {
+	return period.CPU
+}
+
+func memoryPrice(period pricing.PricePeriod) float64 {
+	return period.Memory
+}
+
+func storagePrice(period pricing.PricePeriod) float64 {
+	return period.Storage
+}
+
+func gpuPrice(period pricing.PricePeriod) float64 {
+	return period.GPU
+}
+
+func snapshotPrice(period pricing.PricePeriod) float64 {
+	return period.Snapshot
+}
+
+func loadBalancerPrice(period pricing.PricePeriod) float64 {
+	return period.LoadBalancer
+}
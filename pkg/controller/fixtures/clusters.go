@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	windowStart = "2018-11-01T00:00:00Z"
+	windowEnd   = "2018-11-02T00:00:00Z"
+)
+
+// Clusters returns the canned cluster states, each one covers a feature of the cost model. Their window is the day
+// of 2018-11-01.
+func Clusters() []Cluster {
+	return []Cluster{
+		{
+			Name:        "single-node",
+			Description: "pods of two namespaces on a node charged at the catalog prices, one of them running for a part of the window",
+			From:        windowStart,
+			To:          windowEnd,
+			Nodes:       []Node{{Name: "node-1", CPU: 4, Memory: 16}},
+			Pods: []Pod{
+				{Namespace: "default", Name: "web", Node: "node-1", CPU: 1, Memory: 2, Start: windowStart},
+				{Namespace: "default", Name: "batch", Node: "node-1", CPU: 0.5, Memory: 1, Start: "2018-11-01T06:00:00Z", End: "2018-11-01T12:00:00Z"},
+				{Namespace: "kube-system", Name: "coredns", Node: "node-1", CPU: 0.1, Memory: 0.07, Start: "2018-10-15T00:00:00Z"},
+			},
+		},
+		{
+			Name:        "on-demand-nodes",
+			Description: "pods charged with the premium of the on-demand price of their node over the list price, and a pod requesting a gpu",
+			From:        windowStart,
+			To:          windowEnd,
+			Nodes: []Node{
+				{Name: "m5-large", CPU: 2, Memory: 8, PricePerHour: 0.192},
+				{Name: "t3-medium", CPU: 2, Memory: 4, PricePerHour: 0.0792},
+				{Name: "gpu-1", CPU: 8, Memory: 61},
+			},
+			Pods: []Pod{
+				{Namespace: "web", Name: "frontend", Node: "m5-large", CPU: 1, Memory: 4, Start: windowStart},
+				{Namespace: "web", Name: "cache", Node: "t3-medium", CPU: 0.5, Memory: 1, Start: windowStart},
+				{Namespace: "ml", Name: "train", Node: "gpu-1", CPU: 4, Memory: 16, GPU: 1, Start: windowStart, End: "2018-11-01T10:00:00Z"},
+			},
+		},
+		{
+			Name:        "spot-nodes",
+			Description: "pods on spot nodes charged with the spot discount off the on-demand price of their node, or off the list price",
+			From:        windowStart,
+			To:          windowEnd,
+			Nodes: []Node{
+				{Name: "m5-large-spot", CPU: 2, Memory: 8, PricePerHour: 0.096, CapacityType: "spot"},
+				{Name: "preemptible-1", CPU: 4, Memory: 16, CapacityType: "spot"},
+				{Name: "m5-large", CPU: 2, Memory: 8, PricePerHour: 0.096, CapacityType: "on-demand"},
+			},
+			Pods: []Pod{
+				{Namespace: "batch", Name: "worker-1", Node: "m5-large-spot", CPU: 2, Memory: 8, Start: windowStart},
+				{Namespace: "batch", Name: "worker-2", Node: "preemptible-1", CPU: 2, Memory: 8, Start: windowStart},
+				{Namespace: "web", Name: "frontend", Node: "m5-large", CPU: 1, Memory: 4, Start: windowStart},
+			},
+		},
+		{
+			Name:        "namespace-resources",
+			Description: "persistent volume claims, volume snapshots and load balancers charged to their namespace for their whole life in the window",
+			From:        windowStart,
+			To:          windowEnd,
+			Nodes:       []Node{{Name: "node-1", CPU: 8, Memory: 32}},
+			Pods: []Pod{
+				{Namespace: "data", Name: "postgres", Node: "node-1", CPU: 2, Memory: 4, Storage: 100, Start: windowStart},
+				{Namespace: "frontend", Name: "web", Node: "node-1", CPU: 1, Memory: 1, Start: "2018-11-01T12:00:00Z"},
+			},
+			Claims: []Volume{
+				{Namespace: "data", Storage: 100, Start: "2018-10-01T00:00:00Z"},
+				{Namespace: "data", Storage: 50, Start: "2018-10-01T00:00:00Z", End: "2018-11-01T12:00:00Z"},
+			},
+			Snapshots: []Volume{
+				{Namespace: "data", Storage: 100, Start: "2018-11-01T18:00:00Z"},
+			},
+			LoadBalancers: []LoadBalancer{
+				{Namespace: "data", Start: "2018-10-01T00:00:00Z"},
+				{Namespace: "frontend", Start: "2018-11-01T12:00:00Z"},
+			},
+		},
+		{
+			Name:        "terminated-pods",
+			Description: "pods which started before or ended inside the window are charged only for their life inside the window",
+			From:        windowStart,
+			To:          windowEnd,
+			Nodes:       []Node{{Name: "node-1", CPU: 4, Memory: 16}},
+			Pods: []Pod{
+				{Namespace: "jobs", Name: "nightly", Node: "node-1", CPU: 2, Memory: 8, Start: "2018-10-31T22:00:00Z", End: "2018-11-01T03:00:00Z"},
+				{Namespace: "jobs", Name: "report", Node: "node-1", CPU: 1, Memory: 2, Start: "2018-11-01T23:30:00Z", End: "2018-11-02T01:00:00Z"},
+				{Namespace: "retired", Name: "legacy", Node: "node-1", CPU: 1, Memory: 1, Start: "2018-10-01T00:00:00Z", End: "2018-10-31T00:00:00Z"},
+			},
+		},
+	}
+}
+
+// LoadClusters reads the cluster states in json format from the files *.json of dir, so that clusters modelled after a
+// deployment can be verified with the canned ones
+func LoadClusters(dir string) ([]Cluster, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	clusters := []Cluster{}
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		cluster := Cluster{}
+		if err = json.Unmarshal(data, &cluster); err != nil {
+			return nil, fmt.Errorf("invalid cluster state: (%s), error: (%v)", path, err)
+		}
+		if cluster.Name == "" {
+			cluster.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
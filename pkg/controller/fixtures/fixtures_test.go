@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/allocation"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+// update rewrites the golden files with the allocations: go test ./pkg/controller/fixtures -update
+var update = flag.Bool("update", false, "update the expected allocations in testdata")
+
+// TestGoldenAllocations checks the allocations of the canned clusters with the default prices and no policy settings
+func TestGoldenAllocations(t *testing.T) {
+	mismatches, err := Verify(Clusters(), "testdata", *update, Allocate)
+	utils.Ok(t, err)
+	utils.Equals(t, []Mismatch{}, mismatches)
+}
+
+func TestAllocateOnDemandNodes(t *testing.T) {
+	allocated, err := Allocate(Clusters()[1])
+	utils.Ok(t, err)
+	utils.Equals(t, 2, len(allocated.Namespaces))
+
+	// frontend runs on a node 50% over its list price, cache on a node 10% under its list price
+	web := allocated.Namespaces[1]
+	utils.Equals(t, "web", web.Xid)
+	utils.Equals(t, 36.0, float64(web.CPU))
+	utils.Equals(t, 1*24*0.024*1.5+0.5*24*0.024*0.9, web.CPUCost)
+}
+
+func TestAllocateByPolicy(t *testing.T) {
+	cluster := Cluster{
+		Name:  "usage",
+		From:  windowStart,
+		To:    windowEnd,
+		Nodes: []Node{{Name: "node-1", CPU: 4, Memory: 16}},
+		Pods: []Pod{
+			{Namespace: "idle", Name: "web", Node: "node-1", CPU: 1, Memory: 4, CPUUsage: 0.25, MemoryUsage: 5, Start: windowStart},
+			{Namespace: "unsampled", Name: "job", Node: "node-1", CPU: 1, Memory: 1, Start: windowStart},
+		},
+	}
+	defer allocation.Setup(allocation.Settings{})
+	for policy, expected := range map[string][2]float64{
+		allocation.ByRequest:       {24, 96},
+		allocation.ByUsage:         {6, 120},
+		allocation.ByMaxRequestUse: {24, 120},
+	} {
+		allocation.Setup(allocation.Settings{Policy: policy})
+		allocated, err := Allocate(cluster)
+		utils.Ok(t, err)
+		utils.Equals(t, "idle", allocated.Namespaces[0].Xid)
+		utils.Equals(t, expected[0], float64(allocated.Namespaces[0].CPU))
+		utils.Equals(t, expected[1], float64(allocated.Namespaces[0].Memory))
+		// pods without usage samples are charged by request
+		utils.Equals(t, 24.0, float64(allocated.Namespaces[1].CPU))
+	}
+}
+
+func TestCompareAllocations(t *testing.T) {
+	expected, err := Allocate(Clusters()[0])
+	utils.Ok(t, err)
+	actual, err := Allocate(Clusters()[0])
+	utils.Ok(t, err)
+	utils.Equals(t, []Mismatch{}, compareAllocations(expected, actual))
+
+	actual.Namespaces[0].TotalCost++
+	actual.Namespaces[1].CPUCost++
+	mismatches := compareAllocations(expected, actual)
+	utils.Equals(t, 2, len(mismatches))
+	utils.Equals(t, "default", mismatches[0].Namespace)
+	utils.Equals(t, "kube-system", mismatches[1].Namespace)
+}
+
+func TestLoadClusters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purser-fixtures")
+	utils.Ok(t, err)
+	defer func() {
+		utils.Ok(t, os.RemoveAll(dir))
+	}()
+	state := `{"from": "2018-11-01T00:00:00Z", "to": "2018-11-02T00:00:00Z",
+		"pods": [{"namespace": "default", "name": "web", "cpu": 1, "start": "2018-11-01T00:00:00Z"}]}`
+	utils.Ok(t, ioutil.WriteFile(filepath.Join(dir, "prod.json"), []byte(state), 0644))
+
+	clusters, err := LoadClusters(dir)
+	utils.Ok(t, err)
+	utils.Equals(t, 1, len(clusters))
+	utils.Equals(t, "prod", clusters[0].Name)
+	utils.Equals(t, 1.0, float64(clusters[0].Pods[0].CPU))
+}
+
+func TestAllocateIdleByBurst(t *testing.T) {
+	cluster := Cluster{
+		Name: "burst",
+		From: windowStart,
+		To:   windowEnd,
+		Nodes: []Node{
+			{Name: "node-1", CPU: 4, Memory: 16, NodePool: "batch"},
+			{Name: "node-2", CPU: 4, Memory: 16, NodePool: "web"},
+		},
+		Pods: []Pod{
+			{Namespace: "web", Name: "web", Node: "node-1", CPU: 1, Memory: 4, CPUUsage: 2, MemoryUsage: 4, Start: windowStart},
+			{Namespace: "api", Name: "api", Node: "node-1", CPU: 1, Memory: 4, CPULimit: 1, CPUUsage: 3, MemoryUsage: 4, Start: windowStart},
+			{Namespace: "jobs", Name: "job", Node: "node-1", CPU: 1, Memory: 4, MemoryLimit: 8, CPUUsage: 1, MemoryUsage: 6.4, Start: windowStart},
+			{Namespace: "web", Name: "cache", Node: "node-2", CPU: 1, Memory: 4, CPUUsage: 4, MemoryUsage: 4, Start: windowStart},
+		},
+	}
+	defer allocation.Setup(allocation.Settings{})
+	allocation.Setup(allocation.Settings{NodePools: []allocation.NodePoolSettings{{NodePool: "batch", IdleCost: allocation.IdleByBurst}}})
+	allocated, err := Allocate(cluster)
+	utils.Ok(t, err)
+
+	// 1 cpu and 4 GB of node-1 are idle for a day: (0.024 + 4 * 0.01) * 24 = 1.536, split evenly between the burst of
+	// web (1 cpu) and jobs (2.4 GB), api cannot burst its cpu, the idle capacity of node-2 is left to the cluster
+	for i, expected := range []float64{0, 0.768, 0.768} {
+		utils.Equals(t, expected, idleCost(allocated.Namespaces[i]))
+	}
+}
+
+func idleCost(cost query.ResourceCost) float64 {
+	for _, item := range cost.LineItems {
+		if item.Category == query.IdleLineItem {
+			return item.Cost
+		}
+	}
+	return -1
+}
+
+func TestClusterPrefix(t *testing.T) {
+	utils.Equals(t, "verify1541030400_on_demand_nodes", clusterPrefix("verify1541030400", "on-demand-nodes"))
+}
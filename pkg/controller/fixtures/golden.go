@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// Mismatch is a difference between the allocation of a cluster and its expected allocation
+type Mismatch struct {
+	Cluster   string `json:"cluster"`
+	Namespace string `json:"namespace,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (m Mismatch) String() string {
+	if m.Namespace == "" {
+		return m.Cluster + ": " + m.Message
+	}
+	return m.Cluster + "/" + m.Namespace + ": " + m.Message
+}
+
+// Verify allocates the clusters with allocate (Allocate or a StoredAllocator) and compares their allocations with the
+// expected ones in the files <cluster name>.json of dir. With update, the files are (re)written with the allocations
+// instead, so that the expected outputs of a known good configuration can be recorded before an upgrade.
+func Verify(clusters []Cluster, dir string, update bool, allocate func(Cluster) (Allocation, error)) ([]Mismatch, error) {
+	mismatches := []Mismatch{}
+	for _, cluster := range clusters {
+		allocation, err := allocate(cluster)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dir, cluster.Name+".json")
+		if update {
+			if err = writeAllocation(path, allocation); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		expected, err := readAllocation(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				mismatches = append(mismatches, Mismatch{Cluster: cluster.Name, Message: "no expected allocation in " + path})
+				continue
+			}
+			return nil, err
+		}
+		mismatches = append(mismatches, compareAllocations(expected, allocation)...)
+	}
+	return mismatches, nil
+}
+
+func compareAllocations(expected, actual Allocation) []Mismatch {
+	mismatches := []Mismatch{}
+	if expected.From != actual.From || expected.To != actual.To {
+		mismatches = append(mismatches, Mismatch{Cluster: actual.Cluster,
+			Message: fmt.Sprintf("window is [%s, %s), expected [%s, %s)", actual.From, actual.To, expected.From, expected.To)})
+	}
+	expectedCosts := map[string]query.ResourceCost{}
+	for _, cost := range expected.Namespaces {
+		expectedCosts[cost.Xid] = cost
+	}
+	for _, cost := range actual.Namespaces {
+		want, ok := expectedCosts[cost.Xid]
+		delete(expectedCosts, cost.Xid)
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Cluster: actual.Cluster, Namespace: cost.Xid, Message: "namespace is not expected"})
+		case cost.TotalCost != want.TotalCost:
+			mismatches = append(mismatches, Mismatch{Cluster: actual.Cluster, Namespace: cost.Xid,
+				Message: fmt.Sprintf("total cost is %f, expected %f", cost.TotalCost, want.TotalCost)})
+		case !reflect.DeepEqual(cost, want):
+			mismatches = append(mismatches, Mismatch{Cluster: actual.Cluster, Namespace: cost.Xid,
+				Message: "total cost is as expected but its breakdown differs"})
+		}
+	}
+	for _, cost := range expected.Namespaces {
+		if _, missing := expectedCosts[cost.Xid]; missing {
+			mismatches = append(mismatches, Mismatch{Cluster: actual.Cluster, Namespace: cost.Xid, Message: "expected namespace is missing"})
+		}
+	}
+	return mismatches
+}
+
+func readAllocation(path string) (Allocation, error) {
+	allocation := Allocation{}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return allocation, err
+	}
+	if err = json.Unmarshal(data, &allocation); err != nil {
+		return allocation, fmt.Errorf("invalid expected allocation: (%s), error: (%v)", path, err)
+	}
+	return allocation, nil
+}
+
+func writeAllocation(path string, allocation Allocation) error {
+	data, err := json.MarshalIndent(allocation, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
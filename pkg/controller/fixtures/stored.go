@@ -0,0 +1,216 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package fixtures
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/invoice"
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+var prefixInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// StoredAllocator returns an allocator storing every cluster in the Dgraph the connection is open to and allocating
+// its costs with the queries of the namespace costs api, so that the queries are verified and not only the cost model
+// of Allocate. The clusters are stored under their own predicate prefix (run followed by the cluster name), a scratch
+// Dgraph should be used as they are not deleted.
+func StoredAllocator(run string) func(Cluster) (Allocation, error) {
+	return func(cluster Cluster) (Allocation, error) {
+		if err := dgraph.SetPredicatePrefix(clusterPrefix(run, cluster.Name)); err != nil {
+			return Allocation{}, err
+		}
+		// the uids of the objects of the previous cluster are cached with the same xids
+		dgraph.ClearUIDCache()
+		if err := dgraph.CreateSchema(); err != nil {
+			return Allocation{}, fmt.Errorf("unable to create schema of cluster: (%s), error: (%v)", cluster.Name, err)
+		}
+		if err := Store(cluster); err != nil {
+			return Allocation{}, fmt.Errorf("unable to store cluster: (%s), error: (%v)", cluster.Name, err)
+		}
+		return AllocateStored(cluster)
+	}
+}
+
+// clusterPrefix returns the predicate prefix of the cluster, the characters of its name not allowed in a prefix are
+// replaced with _
+func clusterPrefix(run, name string) string {
+	return prefixInvalidChars.ReplaceAllString(run+"_"+name, "_")
+}
+
+// AllocateStored computes the namespace costs of the cluster stored in Dgraph in its window the way the namespace
+// costs api does, the namespaces of Dgraph which are not in the cluster are allocated too
+func AllocateStored(cluster Cluster) (Allocation, error) {
+	from, err := time.Parse(time.RFC3339, cluster.From)
+	if err != nil {
+		return Allocation{}, fmt.Errorf("invalid from time of cluster: (%s), error: (%v)", cluster.Name, err)
+	}
+	to, err := time.Parse(time.RFC3339, cluster.To)
+	if err != nil {
+		return Allocation{}, fmt.Errorf("invalid to time of cluster: (%s), error: (%v)", cluster.Name, err)
+	}
+	costs, err := query.RetrieveNamespaceCosts(query.All, from, to)
+	if err != nil {
+		return Allocation{}, err
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].Xid < costs[j].Xid
+	})
+	if err = query.DistributeIdleCosts(costs, from, to); err != nil {
+		return Allocation{}, err
+	}
+	query.DistributeAmortizedCosts(costs, from, to)
+	invoice.MarkUpNamespaceCosts(costs)
+	query.RoundCosts(costs)
+	return Allocation{Cluster: cluster.Name, From: cluster.From, To: cluster.To, Namespaces: costs}, nil
+}
+
+// storedPod is a pod with the average usage recorded from the metrics api
+type storedPod struct {
+	models.Pod
+	CPUUsage     utils.Cores `json:"cpuUsage,omitempty"`
+	MemoryUsage  utils.GB    `json:"memoryUsage,omitempty"`
+	UsageSamples int         `json:"usageSamples,omitempty"`
+}
+
+// Store stores the namespaces, nodes, pods, claims, snapshots and load balancers of the cluster in Dgraph with the
+// predicates written by the controller. The nodes live during the whole window.
+func Store(cluster Cluster) error {
+	s := store{namespaces: map[string]string{}, nodes: map[string]string{}, premiums: map[string]float64{}}
+	for _, node := range cluster.Nodes {
+		if err := s.storeNode(node, cluster.From); err != nil {
+			return err
+		}
+	}
+	for _, pod := range cluster.Pods {
+		if err := s.storePod(pod); err != nil {
+			return err
+		}
+	}
+	for i, claim := range cluster.Claims {
+		namespace, err := s.namespace(claim.Namespace)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("claim-%d", i)
+		err = s.create(models.PersistentVolumeClaim{ID: dgraph.ID{Xid: claim.Namespace + ":" + name}, IsPersistentVolumeClaim: true,
+			Name: name, Type: "pvc", StartTime: claim.Start, EndTime: claim.End, Namespace: namespace, StorageCapacity: claim.Storage})
+		if err != nil {
+			return err
+		}
+	}
+	for i, snapshot := range cluster.Snapshots {
+		namespace, err := s.namespace(snapshot.Namespace)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("snapshot-%d", i)
+		err = s.create(models.VolumeSnapshot{ID: dgraph.ID{Xid: snapshot.Namespace + ":" + name}, IsVolumeSnapshot: true,
+			Name: name, Type: "volumesnapshot", StartTime: snapshot.Start, EndTime: snapshot.End, Namespace: namespace,
+			RestoreSize: snapshot.Storage})
+		if err != nil {
+			return err
+		}
+	}
+	for i, lb := range cluster.LoadBalancers {
+		namespace, err := s.namespace(lb.Namespace)
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("lb-%d", i)
+		err = s.create(models.Service{ID: dgraph.ID{Xid: lb.Namespace + ":" + name}, IsService: true, Name: name,
+			Type: "service", ServiceType: "LoadBalancer", StartTime: lb.Start, EndTime: lb.End, Namespace: namespace})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type store struct {
+	namespaces map[string]string
+	nodes      map[string]string
+	premiums   map[string]float64
+}
+
+// storeNode stores the node with its on-demand or spot price, and the premium of the price over its list price
+func (s *store) storeNode(node Node, start string) error {
+	price := node.PricePerHour
+	if node.CapacityType == pricing.SpotCapacity {
+		price = pricing.SpotPrice(price, node.CPU, node.Memory)
+	}
+	capacityType := node.CapacityType
+	if capacityType == "" {
+		capacityType = pricing.OnDemandCapacity
+	}
+	premium := pricing.PremiumOverList(price, node.CPU, node.Memory)
+	assigned, err := dgraph.MutateNode(models.Node{ID: dgraph.ID{Xid: node.Name}, IsNode: true, Name: "node-" + node.Name,
+		Type: "node", StartTime: start, CPUCapity: node.CPU, MemoryCapacity: node.Memory, NodePool: node.NodePool,
+		CapacityType: capacityType, PricePerHour: price, NodePremium: premium}, dgraph.CREATE)
+	if err != nil {
+		return err
+	}
+	s.nodes[node.Name] = assigned.Uids["blank-0"]
+	s.premiums[node.Name] = premium
+	return nil
+}
+
+func (s *store) storePod(pod Pod) error {
+	namespace, err := s.namespace(pod.Namespace)
+	if err != nil {
+		return err
+	}
+	stored := storedPod{Pod: models.Pod{ID: dgraph.ID{Xid: pod.Namespace + ":" + pod.Name}, IsPod: true, Name: pod.Name,
+		Type: "pod", StartTime: pod.Start, EndTime: pod.End, Namespace: namespace, CPURequest: pod.CPU,
+		CPULimit: pod.CPULimit, MemoryRequest: pod.Memory, MemoryLimit: pod.MemoryLimit, GPURequest: pod.GPU,
+		StorageRequest: pod.Storage, NodePremium: s.premiums[pod.Node]}}
+	if uid, isStored := s.nodes[pod.Node]; isStored {
+		stored.Node = &models.Node{ID: dgraph.ID{UID: uid, Xid: pod.Node}}
+	}
+	if pod.CPUUsage != 0 || pod.MemoryUsage != 0 {
+		stored.CPUUsage, stored.MemoryUsage, stored.UsageSamples = pod.CPUUsage, pod.MemoryUsage, 1
+	}
+	return s.create(stored)
+}
+
+// namespace returns a reference to the namespace, it is stored the first time
+func (s *store) namespace(name string) (*models.Namespace, error) {
+	uid, isStored := s.namespaces[name]
+	if !isStored {
+		assigned, err := dgraph.MutateNode(models.Namespace{ID: dgraph.ID{Xid: name}, IsNamespace: true,
+			Name: "namespace-" + name, Type: "namespace"}, dgraph.CREATE)
+		if err != nil {
+			return nil, err
+		}
+		uid = assigned.Uids["blank-0"]
+		s.namespaces[name] = uid
+	}
+	return &models.Namespace{ID: dgraph.ID{UID: uid, Xid: name}}, nil
+}
+
+func (s *store) create(node interface{}) error {
+	_, err := dgraph.MutateNode(node, dgraph.CREATE)
+	return err
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package fixtures holds canned cluster states and allocates their costs with the pricing and policy settings in
+// effect, either with a model of the cost queries without Dgraph (Allocate) or by storing them in a scratch Dgraph and
+// running the queries of the api (StoredAllocator). The allocations are compared with expected outputs (golden files)
+// so that a change of the cost model, of the queries, of the pricing or of the policy settings is noticed before it
+// reaches the invoices.
+package fixtures
+
+import (
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Cluster is a canned cluster state: the nodes, pods and namespace resources which existed in the window [From, To).
+// Times are in RFC3339 format, resources without End are still running at the end of the window.
+type Cluster struct {
+	Name          string         `json:"name"`
+	Description   string         `json:"description"`
+	From          string         `json:"from"`
+	To            string         `json:"to"`
+	Nodes         []Node         `json:"nodes,omitempty"`
+	Pods          []Pod          `json:"pods,omitempty"`
+	Claims        []Volume       `json:"claims,omitempty"`
+	Snapshots     []Volume       `json:"snapshots,omitempty"`
+	LoadBalancers []LoadBalancer `json:"loadBalancers,omitempty"`
+}
+
+// Node is a node of a canned cluster. CPU is in cpus and Memory in GB. PricePerHour is its on-demand price, 0 means
+// its cpus and memory are charged at the catalog prices. A node of spot CapacityType is charged with the spot discount.
+type Node struct {
+	Name         string      `json:"name"`
+	CPU          utils.Cores `json:"cpu"`
+	Memory       utils.GB    `json:"memory"`
+	PricePerHour float64     `json:"pricePerHour,omitempty"`
+	CapacityType string      `json:"capacityType,omitempty"`
+	NodePool     string      `json:"nodePool,omitempty"`
+}
+
+// Pod is a pod of a canned cluster with its requests, CPU is in cpus, Memory and Storage are in GB
+type Pod struct {
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Node      string      `json:"node,omitempty"`
+	CPU       utils.Cores `json:"cpu,omitempty"`
+	Memory    utils.GB    `json:"memory,omitempty"`
+	GPU       float64     `json:"gpu,omitempty"`
+	Storage   utils.GB    `json:"storage,omitempty"`
+	Start     string      `json:"start"`
+	End       string      `json:"end,omitempty"`
+
+	// average usage of the pod for the usage based allocation policies, 0 means never sampled
+	CPUUsage    utils.Cores `json:"cpuUsage,omitempty"`
+	MemoryUsage utils.GB    `json:"memoryUsage,omitempty"`
+
+	// limits of the pod, 0 means unset, the pods of a pool whose idle cost is split by burst use them
+	CPULimit    utils.Cores `json:"cpuLimit,omitempty"`
+	MemoryLimit utils.GB    `json:"memoryLimit,omitempty"`
+}
+
+// Volume is a persistent volume claim or a volume snapshot of a namespace, Storage is in GB
+type Volume struct {
+	Namespace string   `json:"namespace"`
+	Storage   utils.GB `json:"storage"`
+	Start     string   `json:"start"`
+	End       string   `json:"end,omitempty"`
+}
+
+// LoadBalancer is a service of type LoadBalancer of a namespace
+type LoadBalancer struct {
+	Namespace string `json:"namespace"`
+	Start     string `json:"start"`
+	End       string `json:"end,omitempty"`
+}
+
+// Allocation is the cost of the namespaces of a canned cluster in its window, rounded to the api precision
+type Allocation struct {
+	Cluster    string               `json:"cluster"`
+	From       string               `json:"from"`
+	To         string               `json:"to"`
+	Namespaces []query.ResourceCost `json:"namespaces"`
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flowlogs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/export"
+)
+
+const (
+	// source of the traffic ingested by this package
+	source = "vpcFlowLogs"
+	// maxFilesPerIngest bounds the work of a run, the files left are ingested by the next runs
+	maxFilesPerIngest = 200
+	providerAWS       = "aws"
+)
+
+// Settings of the ingestion of cloud VPC flow logs, an alternative source of the traffic of pods for network cost
+// when no capture agent is deployed in the cluster. Only AWS VPC flow logs delivered to S3 in the text format are
+// supported, credentials are read from the environment as for the s3 export sinks.
+type Settings struct {
+	Provider string `json:"provider,omitempty"`
+	Bucket   string `json:"bucket,omitempty"`
+	// Prefix of the flow log files of a region (ex: AWSLogs/123456789012/vpcflowlogs/us-east-1/)
+	Prefix   string `json:"prefix,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// RemoteRegionNetworks are the address ranges (ex: 10.20.0.0/16) of the networks of other regions reached over
+	// peering or transit gateways, the traffic to them is inter-region traffic
+	RemoteRegionNetworks []string `json:"remoteRegionNetworks,omitempty"`
+}
+
+var (
+	mu            sync.Mutex
+	settings      Settings
+	remoteRegions []*net.IPNet
+	kubeclient    kubernetes.Interface
+)
+
+// Setup sets the bucket of the flow logs and the client with which their addresses are mapped to pods and nodes
+func Setup(s Settings, client kubernetes.Interface) {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.Bucket != "" && s.Provider != "" && s.Provider != providerAWS {
+		log.Errorf("flow logs of provider: (%s) are not supported", s.Provider)
+		s.Bucket = ""
+	}
+	networks, err := parseNetworks(s.RemoteRegionNetworks)
+	if err != nil {
+		log.Errorf("invalid remote region networks of the flow logs settings, error: %v", err)
+	}
+	settings = s
+	remoteRegions = networks
+	kubeclient = client
+}
+
+// Ingest adds the traffic of the flow log files delivered since the last run to the traffic of the pods. Addresses
+// are mapped with the pods and nodes running at the time of the ingestion, so files are expected to be ingested
+// soon after their delivery. The first run starts with the files of the current day.
+func Ingest() {
+	mu.Lock()
+	s, remote, client := settings, remoteRegions, kubeclient
+	mu.Unlock()
+	if s.Bucket == "" || client == nil {
+		return
+	}
+
+	owners, err := clusterAddresses(client)
+	if err != nil {
+		log.Errorf("unable to map the addresses of the cluster, error: %v", err)
+		return
+	}
+	cursor, err := models.RetrieveFlowLogCursor(source)
+	if err != nil {
+		log.Errorf("unable to retrieve the flow logs cursor, error: %v", err)
+		return
+	}
+	if cursor == "" {
+		cursor = s.Prefix + time.Now().UTC().Format("2006/01/02/")
+	}
+	sink := export.SinkSettings{Bucket: s.Bucket, Region: s.Region, Endpoint: s.Endpoint}
+	keys, err := export.ListS3Objects(sink, s.Prefix, cursor)
+	if err != nil {
+		log.Errorf("unable to list the flow log files, error: %v", err)
+		return
+	}
+	if len(keys) > maxFilesPerIngest {
+		keys = keys[:maxFilesPerIngest]
+	}
+
+	t := newTally(owners, remote)
+	lastKey := ""
+	for _, key := range keys {
+		data, err := export.GetS3Object(sink, key)
+		if err != nil {
+			log.Errorf("unable to read the flow log file: (%s), error: %v", key, err)
+			break
+		}
+		reader, err := fileReader(key, data)
+		if err == nil {
+			err = t.add(reader)
+		}
+		if err != nil {
+			log.Errorf("skipping the flow log file: (%s), error: %v", key, err)
+		}
+		lastKey = key
+	}
+	if lastKey == "" {
+		return
+	}
+
+	for key, traffic := range t.traffic {
+		if err := models.AddNetworkTraffic(key.pod, time.Unix(key.hour, 0).UTC(), *traffic); err != nil {
+			log.Errorf("unable to store the network traffic of pod: (%s), error: %v", key.pod, err)
+		}
+	}
+	if err := models.StoreFlowLogCursor(source, lastKey); err != nil {
+		log.Errorf("unable to store the flow logs cursor, error: %v", err)
+	}
+	log.Infof("ingested %d flow log files, traffic of %d pod hours", len(keys), len(t.traffic))
+}
+
+// fileReader returns the reader of the records of the file, flow logs are delivered gzipped by default
+func fileReader(key string, data []byte) (io.Reader, error) {
+	if !strings.HasSuffix(key, ".gz") {
+		return bytes.NewReader(data), nil
+	}
+	return gzip.NewReader(bytes.NewReader(data))
+}
+
+// clusterAddresses maps the addresses of the nodes and of the pods which do not use the network of their node to
+// their owners
+func clusterAddresses(client kubernetes.Interface) (map[string]owner, error) {
+	nodes, err := client.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := client.CoreV1().Pods(meta_v1.NamespaceAll).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]owner)
+	zones := make(map[string]string, len(nodes.Items))
+	for _, node := range nodes.Items {
+		zone := models.NodeZone(node)
+		zones[node.Name] = zone
+		for _, address := range node.Status.Addresses {
+			if address.Type == api_v1.NodeInternalIP || address.Type == api_v1.NodeExternalIP {
+				owners[address.Address] = owner{zone: zone}
+			}
+		}
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.PodIP == "" || pod.Status.Phase != api_v1.PodRunning {
+			continue
+		}
+		owners[pod.Status.PodIP] = owner{pod: pod.Namespace + ":" + pod.Name, zone: zones[pod.Spec.NodeName]}
+	}
+	return owners, nil
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flowlogs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// Fields of the flow log records, files start with a header line naming the fields of their format
+const (
+	fieldSrcAddr       = "srcaddr"
+	fieldDstAddr       = "dstaddr"
+	fieldPktSrcAddr    = "pkt-srcaddr"
+	fieldPktDstAddr    = "pkt-dstaddr"
+	fieldSrcPort       = "srcport"
+	fieldDstPort       = "dstport"
+	fieldProtocol      = "protocol"
+	fieldBytes         = "bytes"
+	fieldStart         = "start"
+	fieldAction        = "action"
+	fieldLogStatus     = "log-status"
+	fieldFlowDirection = "flow-direction"
+)
+
+// privateNetworks are the address ranges which are not routed over the internet
+var privateNetworks, _ = parseNetworks([]string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"})
+
+// owner is the pod (empty for nodes) and the zone of an address of the cluster
+type owner struct {
+	pod  string
+	zone string
+}
+
+type trafficKey struct {
+	pod  string
+	hour int64
+}
+
+// tally sums the bytes of flow log records by pod and hour
+type tally struct {
+	owners        map[string]owner
+	remoteRegions []*net.IPNet
+	seen          map[string]bool
+	traffic       map[trafficKey]*models.NetworkTraffic
+}
+
+func newTally(owners map[string]owner, remoteRegions []*net.IPNet) *tally {
+	return &tally{
+		owners:        owners,
+		remoteRegions: remoteRegions,
+		seen:          make(map[string]bool),
+		traffic:       make(map[trafficKey]*models.NetworkTraffic),
+	}
+}
+
+// add tallies the records of a flow log file. With the default format a flow between two addresses of the VPC is
+// logged by both interfaces, such duplicates are counted once. Formats with flow-direction are counted exactly:
+// egress records give the bytes sent and ingress records the bytes received.
+func (t *tally) add(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+	header := strings.Fields(scanner.Text())
+	index := make(map[string]int, len(header))
+	for i, field := range header {
+		index[field] = i
+	}
+	for _, field := range []string{fieldSrcAddr, fieldDstAddr, fieldBytes, fieldStart} {
+		if _, isPresent := index[field]; !isPresent {
+			return fmt.Errorf("flow log format has no %s field", field)
+		}
+	}
+
+	for scanner.Scan() {
+		values := strings.Fields(scanner.Text())
+		if len(values) != len(header) {
+			continue
+		}
+		value := func(field string) string {
+			if i, isPresent := index[field]; isPresent {
+				return values[i]
+			}
+			return ""
+		}
+		if status := value(fieldLogStatus); status != "" && status != "OK" {
+			continue
+		}
+		if value(fieldAction) == "REJECT" {
+			continue
+		}
+		bytes, err := strconv.ParseFloat(value(fieldBytes), 64)
+		if err != nil {
+			continue
+		}
+		start, err := strconv.ParseInt(value(fieldStart), 10, 64)
+		if err != nil {
+			continue
+		}
+		src, dst := packetAddress(value(fieldPktSrcAddr), value(fieldSrcAddr)), packetAddress(value(fieldPktDstAddr), value(fieldDstAddr))
+
+		direction := value(fieldFlowDirection)
+		if direction == "" || direction == "-" {
+			key := strings.Join([]string{src, dst, value(fieldSrcPort), value(fieldDstPort), value(fieldProtocol), value(fieldStart)}, " ")
+			if t.seen[key] {
+				continue
+			}
+			t.seen[key] = true
+		}
+		hour := time.Unix(start, 0).UTC().Truncate(time.Hour).Unix()
+		if direction != "ingress" {
+			t.addSent(src, dst, hour, bytes)
+		}
+		if direction != "egress" {
+			t.addReceived(src, dst, hour, bytes)
+		}
+	}
+	return scanner.Err()
+}
+
+// addSent adds the bytes sent by the pod of the source address by where they went: the same or another zone of the
+// cluster, the networks of other regions, other private addresses (ex: managed databases of the VPC) or the internet
+func (t *tally) addSent(src, dst string, hour int64, bytes float64) {
+	sender, isPresent := t.owners[src]
+	if !isPresent || sender.pod == "" {
+		return
+	}
+	traffic := t.trafficOf(sender.pod, hour)
+	receiver, isPresent := t.owners[dst]
+	switch {
+	case isPresent && sender.zone != "" && receiver.zone != "" && sender.zone != receiver.zone:
+		traffic.BytesCrossZone += bytes
+	case isPresent:
+		traffic.BytesSameZone += bytes
+	case contains(t.remoteRegions, dst):
+		traffic.BytesInterRegion += bytes
+	case contains(privateNetworks, dst):
+		traffic.BytesPrivate += bytes
+	default:
+		traffic.BytesInternet += bytes
+	}
+}
+
+// addReceived adds the bytes received by the pod of the destination address, noting those which came from the
+// internet since NAT gateways charge for them too
+func (t *tally) addReceived(src, dst string, hour int64, bytes float64) {
+	receiver, isPresent := t.owners[dst]
+	if !isPresent || receiver.pod == "" {
+		return
+	}
+	traffic := t.trafficOf(receiver.pod, hour)
+	traffic.BytesReceived += bytes
+	if _, isPresent = t.owners[src]; !isPresent && !contains(t.remoteRegions, src) && !contains(privateNetworks, src) {
+		traffic.BytesFromInternet += bytes
+	}
+}
+
+func (t *tally) trafficOf(pod string, hour int64) *models.NetworkTraffic {
+	key := trafficKey{pod: pod, hour: hour}
+	traffic, isPresent := t.traffic[key]
+	if !isPresent {
+		traffic = &models.NetworkTraffic{Source: source}
+		t.traffic[key] = traffic
+	}
+	return traffic
+}
+
+// packetAddress returns the address of the packet if it is logged, it differs from the address of the interface
+// for the secondary addresses which pods get from the VPC CNI
+func packetAddress(packet, iface string) string {
+	if packet != "" && packet != "-" {
+		return packet
+	}
+	return iface
+}
+
+func contains(networks []*net.IPNet, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
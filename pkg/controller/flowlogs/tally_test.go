@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package flowlogs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+// 2024-03-01T10:00:00Z
+const hour = 1709287200
+
+func testOwners() map[string]owner {
+	return map[string]owner{
+		"10.0.1.10": {pod: "shop:web", zone: "us-east-1a"},
+		"10.0.1.11": {pod: "shop:cart", zone: "us-east-1a"},
+		"10.0.2.20": {pod: "shop:db", zone: "us-east-1b"},
+		"10.0.1.5":  {zone: "us-east-1a"},
+	}
+}
+
+func TestAddDefaultFormat(t *testing.T) {
+	logs := `version account-id interface-id srcaddr dstaddr srcport dstport protocol packets bytes start end action log-status
+2 123 eni-a 10.0.1.10 10.0.1.11 40000 8080 6 10 1000 1709287260 1709287320 ACCEPT OK
+2 123 eni-b 10.0.1.10 10.0.1.11 40000 8080 6 10 1000 1709287260 1709287320 ACCEPT OK
+2 123 eni-a 10.0.1.10 10.0.2.20 40001 5432 6 10 2000 1709287260 1709287320 ACCEPT OK
+2 123 eni-a 10.0.1.10 10.9.0.7 40002 443 6 10 300 1709287260 1709287320 ACCEPT OK
+2 123 eni-a 10.0.1.10 52.1.2.3 40003 443 6 10 400 1709287260 1709287320 ACCEPT OK
+2 123 eni-a 10.0.1.10 52.1.2.3 40004 443 6 10 500 1709287260 1709287320 REJECT OK
+2 123 eni-a - - - - - - - 1709287260 1709287320 - NODATA
+`
+	tally := newTally(testOwners(), nil)
+	utils.Ok(t, tally.add(strings.NewReader(logs)))
+
+	web := tally.traffic[trafficKey{pod: "shop:web", hour: hour}]
+	utils.Equals(t, &models.NetworkTraffic{Source: source, BytesSameZone: 1000, BytesCrossZone: 2000, BytesPrivate: 300,
+		BytesInternet: 400}, web)
+	utils.Equals(t, 1000.0, tally.traffic[trafficKey{pod: "shop:cart", hour: hour}].BytesReceived)
+	utils.Equals(t, 2000.0, tally.traffic[trafficKey{pod: "shop:db", hour: hour}].BytesReceived)
+	utils.Equals(t, 3, len(tally.traffic))
+}
+
+func TestAddFlowDirection(t *testing.T) {
+	logs := `version interface-id srcaddr dstaddr pkt-srcaddr pkt-dstaddr bytes start flow-direction
+5 eni-a 10.0.1.5 10.0.2.20 10.0.1.10 10.0.2.20 700 1709287260 egress
+5 eni-b 10.0.1.5 10.0.2.20 10.0.1.10 10.0.2.20 700 1709287260 ingress
+5 eni-a 52.1.2.3 10.0.1.5 52.1.2.3 10.0.1.10 900 1709287260 ingress
+`
+	tally := newTally(testOwners(), nil)
+	utils.Ok(t, tally.add(strings.NewReader(logs)))
+
+	web := tally.traffic[trafficKey{pod: "shop:web", hour: hour}]
+	utils.Equals(t, 700.0, web.BytesCrossZone)
+	utils.Equals(t, 900.0, web.BytesReceived)
+	utils.Equals(t, 900.0, web.BytesFromInternet)
+	db := tally.traffic[trafficKey{pod: "shop:db", hour: hour}]
+	utils.Equals(t, 700.0, db.BytesReceived)
+	utils.Equals(t, 0.0, db.BytesFromInternet)
+}
+
+func TestAddMissingField(t *testing.T) {
+	err := newTally(testOwners(), nil).add(strings.NewReader("version srcaddr dstaddr start\n"))
+	utils.Assert(t, err != nil, "expected an error for a format without bytes")
+}
+
+func TestAddRemoteRegion(t *testing.T) {
+	remote, err := parseNetworks([]string{"10.20.0.0/16"})
+	utils.Ok(t, err)
+	logs := `version srcaddr dstaddr bytes start
+2 10.0.1.10 10.20.3.4 600 1709287260
+2 10.0.1.10 10.9.0.7 300 1709287260
+`
+	tally := newTally(testOwners(), remote)
+	utils.Ok(t, tally.add(strings.NewReader(logs)))
+
+	web := tally.traffic[trafficKey{pod: "shop:web", hour: hour}]
+	utils.Equals(t, 600.0, web.BytesInterRegion)
+	utils.Equals(t, 300.0, web.BytesPrivate)
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grafana
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/aggregation"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// Search returns the targets of all namespaces and groups which contain the given text
+func Search(text string) ([]string, error) {
+	namespaces, err := query.RetrieveNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []string{}
+	for _, namespace := range namespaces {
+		targets = appendMatchingTargets(targets, models.NamespaceOwner+"/"+namespace.Xid, text)
+	}
+	for _, group := range groups {
+		targets = appendMatchingTargets(targets, models.GroupOwner+"/"+group.Xid, text)
+	}
+	return targets, nil
+}
+
+// Query returns the daily cost of every target in the time range. The result of a target is a Series
+// or a TableResult depending on the type of the target.
+func Query(request QueryRequest) ([]interface{}, error) {
+	if request.Range.From.IsZero() || request.Range.To.IsZero() {
+		return nil, fmt.Errorf("time range is not given")
+	}
+
+	results := []interface{}{}
+	for _, queryTarget := range request.Targets {
+		parsed, err := parseTarget(queryTarget.Target)
+		if err != nil {
+			return nil, err
+		}
+		dailyCosts, err := aggregation.RetrieveDailyCosts(parsed.ownerType, parsed.owner, request.Range.From.Local(), request.Range.To.Local())
+		if err != nil {
+			return nil, err
+		}
+		if queryTarget.Type == Table {
+			results = append(results, newTableResult(parsed.metric, dailyCosts))
+		} else {
+			results = append(results, newSeries(queryTarget.Target, parsed.metric, dailyCosts))
+		}
+	}
+	return results, nil
+}
+
+func appendMatchingTargets(targets []string, owner, text string) []string {
+	if !strings.Contains(owner, text) {
+		return targets
+	}
+	targets = append(targets, owner)
+	for _, metric := range []string{CPUCost, MemoryCost, StorageCost} {
+		targets = append(targets, owner+"/"+metric)
+	}
+	return targets
+}
+
+func parseTarget(text string) (target, error) {
+	parts := strings.Split(text, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return target{}, fmt.Errorf("invalid target: (%s), format is <namespace|group>/<name>[/<metric>]", text)
+	}
+	if parts[0] != models.NamespaceOwner && parts[0] != models.GroupOwner {
+		return target{}, fmt.Errorf("invalid target: (%s), unknown owner type: (%s)", text, parts[0])
+	}
+
+	parsed := target{ownerType: parts[0], owner: parts[1], metric: TotalCost}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case TotalCost, CPUCost, MemoryCost, StorageCost:
+			parsed.metric = parts[2]
+		default:
+			return target{}, fmt.Errorf("invalid target: (%s), unknown metric: (%s)", text, parts[2])
+		}
+	}
+	return parsed, nil
+}
+
+func newSeries(name, metric string, dailyCosts []models.CostSummary) Series {
+	series := Series{Target: name, Datapoints: [][2]float64{}}
+	for _, dailyCost := range dailyCosts {
+		day, err := time.Parse(time.RFC3339, dailyCost.Date)
+		if err != nil {
+			continue
+		}
+		series.Datapoints = append(series.Datapoints, [2]float64{metricValue(metric, dailyCost), float64(toMillis(day))})
+	}
+	return series
+}
+
+func newTableResult(metric string, dailyCosts []models.CostSummary) TableResult {
+	table := TableResult{
+		Type:    Table,
+		Columns: []Column{{Text: "Time", Type: "time"}, {Text: "Cost", Type: "number"}},
+		Rows:    [][]interface{}{},
+	}
+	for _, dailyCost := range dailyCosts {
+		day, err := time.Parse(time.RFC3339, dailyCost.Date)
+		if err != nil {
+			continue
+		}
+		table.Rows = append(table.Rows, []interface{}{toMillis(day), metricValue(metric, dailyCost)})
+	}
+	return table
+}
+
+func metricValue(metric string, dailyCost models.CostSummary) float64 {
+	switch metric {
+	case CPUCost:
+		return dailyCost.CPUCost
+	case MemoryCost:
+		return dailyCost.MemoryCost
+	case StorageCost:
+		return dailyCost.StorageCost
+	}
+	return dailyCost.TotalCost
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grafana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestParseTarget ...
+func TestParseTarget(t *testing.T) {
+	parsed, err := parseTarget("namespace/default")
+	utils.Ok(t, err)
+	utils.Equals(t, target{ownerType: models.NamespaceOwner, owner: "default", metric: TotalCost}, parsed)
+
+	parsed, err = parseTarget("group/payments/cpu")
+	utils.Ok(t, err)
+	utils.Equals(t, target{ownerType: models.GroupOwner, owner: "payments", metric: CPUCost}, parsed)
+
+	for _, invalid := range []string{"default", "namespace/", "pod/default", "namespace/default/network", "namespace/a/b/c"} {
+		_, err = parseTarget(invalid)
+		utils.Assert(t, err != nil, "invalid target: (%s) is accepted", invalid)
+	}
+}
+
+// TestNewSeries ...
+func TestNewSeries(t *testing.T) {
+	day := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	dailyCosts := []models.CostSummary{{Date: day.Format(time.RFC3339), CPUCost: 1.5, TotalCost: 2}}
+
+	series := newSeries("namespace/default/cpu", CPUCost, dailyCosts)
+	utils.Equals(t, [][2]float64{{1.5, float64(day.Unix() * 1000)}}, series.Datapoints)
+}
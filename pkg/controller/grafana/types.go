@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package grafana
+
+import "time"
+
+// Response types of a query target
+const (
+	TimeSeries = "timeserie"
+	Table      = "table"
+)
+
+// Cost metrics of a target, the total cost is used when the target has no metric
+const (
+	TotalCost   = "total"
+	CPUCost     = "cpu"
+	MemoryCost  = "memory"
+	StorageCost = "storage"
+)
+
+// SearchRequest is the body of a search request, Target is the text typed in the query editor
+type SearchRequest struct {
+	Target string `json:"target"`
+}
+
+// QueryRequest is the body of a query request of the simple json datasource
+type QueryRequest struct {
+	Range   Range    `json:"range"`
+	Targets []Target `json:"targets"`
+}
+
+// Range is the time range of the dashboard
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Target is a queried series. Target has the format <namespace|group>/<name>[/<total|cpu|memory|storage>].
+type Target struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// Series is the daily cost of a target, every datapoint is [cost, unix time in milliseconds]
+type Series struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// TableResult is the daily cost of a target in table format
+type TableResult struct {
+	Type    string          `json:"type"`
+	Columns []Column        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Column of a table result
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// target is a parsed query target
+type target struct {
+	ownerType string
+	owner     string
+	metric    string
+}
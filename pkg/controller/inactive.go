@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/pkg/controller/sharding"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+const (
+	podMetricsAPIPath          = "/apis/metrics.k8s.io/v1beta1/pods"
+	defaultInactiveDays        = 7
+	defaultActivityCPUCores    = 0.01
+	inactiveDeploymentReason   = "InactiveWorkload"
+	inactiveDeploymentsPerNote = 20
+)
+
+// InactiveWorkloadSettings of the detection of deployments which neither use cpu nor are called by other pods.
+// The cpu usage is sampled from the metrics api (metrics-server), deployments are not reported without it.
+type InactiveWorkloadSettings struct {
+	// Days without activity after which a deployment is reported, 7 by default
+	Days int `json:"days,omitempty"`
+	// CPUThreshold is the cpu usage (in cores) of a pod above which its deployment is active, 0.01 by default
+	CPUThreshold float64 `json:"cpuThreshold,omitempty"`
+	// Notify sends a daily notification listing the inactive deployments
+	Notify bool `json:"notify,omitempty"`
+	// Events creates a kubernetes event on every inactive deployment suggesting to scale it to zero
+	Events bool `json:"events,omitempty"`
+}
+
+var (
+	inactiveMu       sync.Mutex
+	inactiveSettings = InactiveWorkloadSettings{Days: defaultInactiveDays, CPUThreshold: defaultActivityCPUCores}
+)
+
+// SetupInactiveWorkloads sets the settings of the inactive workload detection, zero values keep the defaults
+func SetupInactiveWorkloads(settings InactiveWorkloadSettings) {
+	inactiveMu.Lock()
+	defer inactiveMu.Unlock()
+	if settings.Days <= 0 {
+		settings.Days = defaultInactiveDays
+	}
+	if settings.CPUThreshold <= 0 {
+		settings.CPUThreshold = defaultActivityCPUCores
+	}
+	inactiveSettings = settings
+}
+
+// GetInactiveDays returns the number of days without activity after which a deployment is inactive
+func GetInactiveDays() int {
+	inactiveMu.Lock()
+	defer inactiveMu.Unlock()
+	return inactiveSettings.Days
+}
+
+// GetActivityCPUThreshold returns the cpu usage (in cores) below which the pods of a workload are idle
+func GetActivityCPUThreshold() float64 {
+	inactiveMu.Lock()
+	defer inactiveMu.Unlock()
+	return inactiveSettings.CPUThreshold
+}
+
+type podMetrics struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Containers []struct {
+		Usage map[string]string `json:"usage"`
+	} `json:"containers"`
+}
+
+// SampleWorkloadActivity samples the cpu and memory usage of the pods from the metrics api, records the average usage
+// of the pods and the activity of the deployments of the namespaces processed by this controller replica. Clusters
+// without metrics api are skipped.
+func SampleWorkloadActivity() {
+	if Kubeclient == nil {
+		return
+	}
+	data, err := fetchPodMetrics()
+	if err != nil {
+		log.Debugf("skipping workload activity, unable to get pod metrics: (%v)", err)
+		return
+	}
+	type podMetricsList struct {
+		Items []podMetrics `json:"items"`
+	}
+	metrics := podMetricsList{}
+	if err = json.Unmarshal(data, &metrics); err != nil {
+		log.Errorf("unable to decode pod metrics, error: (%v)", err)
+		return
+	}
+
+	inactiveMu.Lock()
+	threshold := inactiveSettings.CPUThreshold
+	inactiveMu.Unlock()
+	podCPU := podCPUUsage(metrics.Items)
+	sampled, err := models.RecordPodUsage(podCPU, podMemoryUsage(metrics.Items), sharding.Owns)
+	if err != nil {
+		log.Errorf("unable to record usage of pods, error: (%v)", err)
+	}
+	log.Debugf("usage of %d pods is sampled", sampled)
+	active, err := models.RecordDeploymentActivity(podCPU, utils.Cores(threshold), sharding.Owns, time.Now())
+	if err != nil {
+		log.Errorf("unable to record activity of deployments, error: (%v)", err)
+		return
+	}
+	log.Debugf("%d deployments are active", active)
+}
+
+// podCPUUsage returns the cpu usage (in cores) of every pod keyed by pod xid
+func podCPUUsage(items []podMetrics) map[string]utils.Cores {
+	usage := map[string]utils.Cores{}
+	for _, item := range items {
+		var cpu utils.Cores
+		for _, container := range item.Containers {
+			quantity, err := resource.ParseQuantity(container.Usage["cpu"])
+			if err != nil {
+				continue
+			}
+			cpu += utils.CPUCores(quantity)
+		}
+		usage[item.Metadata.Namespace+":"+item.Metadata.Name] = cpu
+	}
+	return usage
+}
+
+// podMemoryUsage returns the memory usage (in GB) of every pod keyed by pod xid
+func podMemoryUsage(items []podMetrics) map[string]utils.GB {
+	usage := map[string]utils.GB{}
+	for _, item := range items {
+		var memory utils.GB
+		for _, container := range item.Containers {
+			quantity, err := resource.ParseQuantity(container.Usage["memory"])
+			if err != nil {
+				continue
+			}
+			memory += utils.MemoryGB(quantity)
+		}
+		usage[item.Metadata.Namespace+":"+item.Metadata.Name] = memory
+	}
+	return usage
+}
+
+// ReportInactiveDeployments notifies the deployments which were inactive during the configured number of days and
+// creates an event on each of them, depending on the settings. It is scheduled daily.
+func ReportInactiveDeployments() {
+	inactiveMu.Lock()
+	settings := inactiveSettings
+	inactiveMu.Unlock()
+	if !settings.Notify && !settings.Events {
+		return
+	}
+
+	inactive, err := query.RetrieveInactiveDeployments(settings.Days, time.Now())
+	if err != nil {
+		log.Errorf("unable to retrieve inactive deployments, error: (%v)", err)
+		return
+	}
+	if len(inactive) == 0 {
+		return
+	}
+	if settings.Events {
+		for _, deployment := range inactive {
+			if err = createInactiveEvent(deployment, settings.Days); err != nil {
+				log.Errorf("unable to create event for inactive deployment: (%s), error: (%v)", deployment.Xid, err)
+			}
+		}
+	}
+	if settings.Notify {
+		if err = notifier.Notify(inactiveNotification(inactive, settings.Days)); err != nil {
+			log.Errorf("unable to notify inactive deployments, error: %v", err)
+		}
+	}
+}
+
+func inactiveNotification(inactive []query.InactiveDeployment, days int) notifier.Notification {
+	total := 0.0
+	table := &notifier.Table{Headers: []string{"Deployment", "Replicas", fmt.Sprintf("Cost (%d days)", days)}}
+	for i, deployment := range inactive {
+		total += deployment.TotalCost
+		if i < inactiveDeploymentsPerNote {
+			table.Rows = append(table.Rows, []string{deployment.Xid, fmt.Sprintf("%d", deployment.Replicas), fmt.Sprintf("%.2f", deployment.TotalCost)})
+		}
+	}
+	return notifier.Notification{
+		Title: fmt.Sprintf("%d deployments were inactive for %d days", len(inactive), days),
+		Summary: fmt.Sprintf("These deployments used almost no cpu and received no calls from other pods, they cost %.2f "+
+			"in the last %d days. Consider scaling them to zero.", total, days),
+		Kind:     notifier.InactiveWorkloadKind,
+		Severity: notifier.Info,
+		DedupKey: "inactive-deployments-" + time.Now().Format(query.DateFormat),
+		Table:    table,
+	}
+}
+
+func createInactiveEvent(deployment query.InactiveDeployment, days int) error {
+	now := meta_v1.Now()
+	event := &api_v1.Event{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: deployment.Name + ".",
+			Namespace:    deployment.Namespace,
+		},
+		InvolvedObject: api_v1.ObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       query.DeploymentKind,
+			Name:       deployment.Name,
+			Namespace:  deployment.Namespace,
+		},
+		Reason: inactiveDeploymentReason,
+		Message: fmt.Sprintf("No cpu activity nor inbound calls for %d days, pods cost %.2f in this period. Consider scaling to zero.",
+			days, deployment.TotalCost),
+		Type:           api_v1.EventTypeNormal,
+		Source:         api_v1.EventSource{Component: "purser"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := Kubeclient.CoreV1().Events(deployment.Namespace).Create(event)
+	return err
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"html/template"
+	"io"
+	"strconv"
+)
+
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"money": formatAmount,
+	"markup": func(invoice Invoice) float64 {
+		return invoice.MarkedUpTotal - invoice.Total
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Group}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px; text-align: left; }
+td.number, th.number { text-align: right; }
+</style>
+</head>
+<body>
+<h1>Invoice: {{.Group}}</h1>
+<p>Period: {{.PeriodStart}} to {{.PeriodEnd}}<br>Generated at: {{.GeneratedAt}}</p>
+<table>
+<tr><th>Category</th><th>Description</th><th class="number">Quantity</th><th>Unit</th><th class="number">Amount</th></tr>
+{{range .LineItems}}<tr><td>{{.Category}}</td><td>{{.Description}}</td><td class="number">{{printf "%.2f" .Quantity}}</td><td>{{.Unit}}</td><td class="number">{{money .Amount}}</td></tr>
+{{end}}<tr><th colspan="4">Total</th><th class="number">{{money .Total}}</th></tr>
+{{if .MarkupPercent}}<tr><td colspan="4">Markup ({{.MarkupPercent}}%)</td><td class="number">{{money (markup .)}}</td></tr>
+<tr><th colspan="4">Total with markup</th><th class="number">{{money .MarkedUpTotal}}</th></tr>
+{{end}}</table>
+<h2>Rates</h2>
+<table>
+<tr><th>Effective from</th><th>Version</th><th class="number">CPU per hour</th><th class="number">Memory per GB hour</th><th class="number">Storage per GB hour</th></tr>
+{{range .Rates}}<tr><td>{{.EffectiveFrom}}</td><td>{{.Version}}</td><td class="number">{{.CPU}}</td><td class="number">{{.Memory}}</td><td class="number">{{.Storage}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RenderHTML writes the invoice as a printable html document
+func RenderHTML(w io.Writer, invoice Invoice) error {
+	return invoiceTemplate.Execute(w, invoice)
+}
+
+func formatAmount(amount float64) string {
+	return "$" + strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/notifier"
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+const (
+	monthFormat = "2006-01"
+)
+
+var (
+	mu        sync.RWMutex
+	outputDir string
+)
+
+// Setup configures the monthly invoice generation
+func Setup(settings Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	outputDir = settings.OutputDir
+	markups = settings.Markups
+	defaultMarkup = settings.DefaultMarkupPercent
+	terms = settings.Terms
+}
+
+// GetMonthStart returns the start of the month of the given time
+func GetMonthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// Generate returns the invoice of the group for the month starting at monthStart. The period of the
+// current month ends now. The billing terms of the group adjust the invoice after its markup.
+func Generate(group string, monthStart time.Time) (Invoice, error) {
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return Invoice{}, err
+	}
+	var labels map[string]string
+	for _, g := range groups {
+		if g.Xid == group {
+			labels = g.GetLabelsMap()
+		}
+	}
+	if labels == nil {
+		return Invoice{}, fmt.Errorf("group: %s is not persisted in dgraph", group)
+	}
+
+	from, to := monthStart, monthStart.AddDate(0, 1, 0)
+	if to.After(time.Now()) {
+		to = time.Now()
+	}
+	cost, err := query.RetrieveGroupCost(group, labels, from, to)
+	if err != nil {
+		return Invoice{}, err
+	}
+	externalCosts, err := query.RetrieveExternalCosts(query.All, group)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	invoice := Invoice{
+		Group:       group,
+		PeriodStart: from.Format(time.RFC3339),
+		PeriodEnd:   to.Format(time.RFC3339),
+		GeneratedAt: time.Now().Format(time.RFC3339),
+		LineItems: []LineItem{
+			{Category: Compute, Description: "cpu requested by pods", Quantity: float64(cost.CPU), Unit: "cpu hours", Amount: cost.CPUCost},
+			{Category: Memory, Description: "memory requested by pods", Quantity: float64(cost.Memory), Unit: "GB hours", Amount: cost.MemoryCost},
+			{Category: Storage, Description: "persistent volume claims", Quantity: float64(cost.Storage), Unit: "GB hours", Amount: cost.StorageCost},
+		},
+		Rates: ratesInPeriod(pricing.GetPriceHistory(), from, to),
+	}
+	if cost.GPU > 0 {
+		invoice.LineItems = append(invoice.LineItems, LineItem{Category: GPU, Description: "gpus (or gpu fractions) allocated to pods",
+			Quantity: cost.GPU, Unit: "gpu hours", Amount: cost.GPUCost})
+	}
+	for _, externalCost := range externalCosts {
+		hours := query.ExternalCostHours(externalCost, from, to)
+		if hours <= 0 {
+			continue
+		}
+		invoice.LineItems = append(invoice.LineItems, LineItem{
+			Category:    External,
+			Description: externalCost.Xid + " (" + externalCost.Category + ")",
+			Quantity:    hours,
+			Unit:        "hours",
+			Amount:      query.ProrateExternalCost(externalCost, hours),
+		})
+	}
+	groupTerms := GroupTerms(group)
+	if groupTerms.RoundUpHours {
+		roundUpHours(invoice.LineItems)
+	}
+	for _, item := range invoice.LineItems {
+		invoice.Total += item.Amount
+	}
+	if percent := GroupMarkup(group); percent != 0 {
+		for i := range invoice.LineItems {
+			invoice.LineItems[i].MarkedUpAmount = markUp(invoice.LineItems[i].Amount, percent)
+		}
+		invoice.MarkupPercent = percent
+		invoice.MarkedUpTotal = markUp(invoice.Total, percent)
+	}
+	applyTerms(&invoice, groupTerms)
+	return invoice, nil
+}
+
+// RunMonthlyInvoices generates the invoices of the previous month of every group, writes them to the output
+// directory if one is configured and sends a summary report with the configured notifiers.
+// It is scheduled to run on the first day of every month.
+func RunMonthlyInvoices() {
+	mu.RLock()
+	dir := outputDir
+	mu.RUnlock()
+
+	monthStart := GetMonthStart(time.Now()).AddDate(0, -1, 0)
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		log.Errorf("unable to generate invoices, error: %v", err)
+		return
+	}
+
+	report := &notifier.Table{Headers: []string{"Group", "Compute", "Memory", "Storage", "External", "Total", "Marked up total"}}
+	for _, group := range groups {
+		invoice, err := Generate(group.Xid, monthStart)
+		if err != nil {
+			log.Errorf("unable to generate invoice of group: (%s), error: %v", group.Xid, err)
+			continue
+		}
+		report.Rows = append(report.Rows, summaryRow(invoice))
+		if dir == "" {
+			continue
+		}
+		if err = writeInvoice(dir, invoice, monthStart); err != nil {
+			log.Errorf("unable to write invoice of group: (%s), error: %v", group.Xid, err)
+		}
+	}
+	log.Infof("invoices of %s generated for %d groups", monthStart.Format(monthFormat), len(groups))
+
+	err = notifier.Notify(notifier.Notification{
+		Title:    "Purser invoices for " + monthStart.Format(monthFormat),
+		Summary:  fmt.Sprintf("Invoices of %d groups are generated for %s.", len(report.Rows), monthStart.Format(monthFormat)),
+		Kind:     notifier.InvoiceKind,
+		Severity: notifier.Info,
+		DedupKey: "invoices-" + monthStart.Format(monthFormat),
+		Table:    report,
+	})
+	if err != nil {
+		log.Errorf("unable to send invoices report, error: %v", err)
+	}
+}
+
+func writeInvoice(dir string, invoice Invoice, monthStart time.Time) error {
+	file, err := os.Create(filepath.Join(dir, invoice.Group+"-"+monthStart.Format(monthFormat)+".html"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	return RenderHTML(file, invoice)
+}
+
+// summaryRow returns the amounts of the invoice per category as a report row
+func summaryRow(invoice Invoice) []string {
+	amounts := make(map[string]float64)
+	for _, item := range invoice.LineItems {
+		amounts[item.Category] += item.Amount
+	}
+	markedUpTotal := invoice.Total
+	if invoice.MarkupPercent != 0 {
+		markedUpTotal = invoice.MarkedUpTotal
+	}
+	return []string{invoice.Group, formatAmount(amounts[Compute]), formatAmount(amounts[Memory]),
+		formatAmount(amounts[Storage]), formatAmount(amounts[External]), formatAmount(invoice.Total), formatAmount(markedUpTotal)}
+}
+
+// ratesInPeriod returns the price periods which were in effect at some point in [from, to)
+func ratesInPeriod(periods []pricing.PricePeriod, from, to time.Time) []pricing.PricePeriod {
+	var rates []pricing.PricePeriod
+	for i, period := range periods {
+		effectiveFrom, err := time.Parse(time.RFC3339, period.EffectiveFrom)
+		if err != nil || !effectiveFrom.Before(to) {
+			continue
+		}
+		if i+1 < len(periods) {
+			nextEffectiveFrom, err := time.Parse(time.RFC3339, periods[i+1].EffectiveFrom)
+			if err == nil && !nextEffectiveFrom.After(from) {
+				continue
+			}
+		}
+		rates = append(rates, period)
+	}
+	return rates
+}
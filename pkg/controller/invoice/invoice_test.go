@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/pricing"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestGetMonthStart(t *testing.T) {
+	utils.Equals(t, time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC),
+		GetMonthStart(time.Date(2018, 11, 17, 13, 45, 0, 0, time.UTC)))
+}
+
+func TestRatesInPeriod(t *testing.T) {
+	periods := []pricing.PricePeriod{
+		{EffectiveFrom: "2018-09-01T00:00:00Z", Version: "v1"},
+		{EffectiveFrom: "2018-10-15T00:00:00Z", Version: "v2"},
+		{EffectiveFrom: "2018-11-10T00:00:00Z", Version: "v3"},
+		{EffectiveFrom: "2018-12-01T00:00:00Z", Version: "v4"},
+	}
+	from, to := time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)
+	rates := ratesInPeriod(periods, from, to)
+	// v1 was replaced before the month, v4 is effective from the end of the month
+	utils.Equals(t, 2, len(rates))
+	utils.Equals(t, "v2", rates[0].Version)
+	utils.Equals(t, "v3", rates[1].Version)
+
+	utils.Equals(t, 0, len(ratesInPeriod(nil, from, to)))
+}
+
+func TestSummaryRow(t *testing.T) {
+	invoice := Invoice{Group: "team-a", Total: 16.5, LineItems: []LineItem{
+		{Category: Compute, Amount: 10},
+		{Category: Memory, Amount: 4},
+		{Category: External, Amount: 1.5},
+		{Category: External, Amount: 1},
+	}}
+	utils.Equals(t, []string{"team-a", "$10.00", "$4.00", "$0.00", "$2.50", "$16.50", "$16.50"}, summaryRow(invoice))
+
+	invoice.MarkupPercent, invoice.MarkedUpTotal = 10, 18.15
+	utils.Equals(t, "$18.15", summaryRow(invoice)[6])
+}
+
+func TestRenderHTML(t *testing.T) {
+	invoice := Invoice{Group: "team-a", PeriodStart: "2018-11-01T00:00:00Z", PeriodEnd: "2018-12-01T00:00:00Z",
+		LineItems: []LineItem{{Category: Compute, Description: "cpu requested by pods", Quantity: 480, Unit: "cpu hours",
+			Amount: 12}},
+		Rates: []pricing.PricePeriod{{EffectiveFrom: "2018-09-01T00:00:00Z", Version: "v1", CPU: 0.025}},
+		Total: 12}
+	var buf bytes.Buffer
+	utils.Ok(t, RenderHTML(&buf, invoice))
+	html := buf.String()
+	for _, expected := range []string{"<title>Invoice team-a</title>", "<td>cpu requested by pods</td>",
+		`<td class="number">480.00</td>`, `<th class="number">$12.00</th>`, "<td>v1</td>"} {
+		utils.Assert(t, strings.Contains(html, expected), "%s not rendered in: %s", expected, html)
+	}
+	utils.Assert(t, !strings.Contains(html, "Total with markup"), "markup rendered without a markup: %s", html)
+
+	invoice.MarkupPercent, invoice.MarkedUpTotal = 25, 15
+	buf.Reset()
+	utils.Ok(t, RenderHTML(&buf, invoice))
+	html = buf.String()
+	utils.Assert(t, strings.Contains(html, "Markup (25%)") && strings.Contains(html, `<th class="number">$15.00</th>`),
+		"markup not rendered in: %s", html)
+	utils.Assert(t, strings.Contains(html, `<td class="number">$3.00</td>`), "markup amount not rendered in: %s", html)
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// Markup is the percentage added to the raw cost of a namespace or of a group when it is charged back, ex: 15 bills
+// 115 for a cost of 100. One of Namespace and Group is set.
+type Markup struct {
+	Namespace string  `json:"namespace,omitempty"`
+	Group     string  `json:"group,omitempty"`
+	Percent   float64 `json:"percent"`
+}
+
+var (
+	markups       []Markup
+	defaultMarkup float64
+)
+
+// NamespaceMarkup returns the markup percentage of the namespace, the default markup if it has none
+func NamespaceMarkup(namespace string) float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, m := range markups {
+		if m.Namespace != "" && m.Namespace == namespace {
+			return m.Percent
+		}
+	}
+	return defaultMarkup
+}
+
+// GroupMarkup returns the markup percentage of the group, the default markup if it has none
+func GroupMarkup(group string) float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, m := range markups {
+		if m.Group != "" && m.Group == group {
+			return m.Percent
+		}
+	}
+	return defaultMarkup
+}
+
+// MarkUpNamespaceCosts sets the markup percentage and the marked up cost of the namespace costs which have a markup,
+// their raw costs are left unchanged. The shared cluster overhead of a namespace is marked up with its total cost.
+func MarkUpNamespaceCosts(costs []query.ResourceCost) {
+	for i := range costs {
+		percent := NamespaceMarkup(costs[i].Xid)
+		if percent == 0 {
+			continue
+		}
+		costs[i].MarkupPercent = percent
+		costs[i].MarkedUpCost = markUp(costs[i].TotalCost+costs[i].SharedCost, percent)
+	}
+}
+
+func markUp(amount, percent float64) float64 {
+	return amount * (1 + percent/100)
+}
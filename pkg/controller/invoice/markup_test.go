@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestMarkups(t *testing.T) {
+	Setup(Settings{Markups: []Markup{{Namespace: "shop", Percent: 15}, {Group: "team-a", Percent: 20}},
+		DefaultMarkupPercent: 5})
+	defer Setup(Settings{})
+
+	utils.Equals(t, 15.0, NamespaceMarkup("shop"))
+	utils.Equals(t, 5.0, NamespaceMarkup("ci"))
+	// the markup of a group does not apply to a namespace of the same name
+	utils.Equals(t, 5.0, NamespaceMarkup("team-a"))
+	utils.Equals(t, 20.0, GroupMarkup("team-a"))
+	utils.Equals(t, 5.0, GroupMarkup("shop"))
+}
+
+func TestMarkUpNamespaceCosts(t *testing.T) {
+	Setup(Settings{Markups: []Markup{{Namespace: "shop", Percent: 15}, {Namespace: "ci", Percent: 0}}})
+	defer Setup(Settings{})
+
+	costs := []query.ResourceCost{{Xid: "shop", TotalCost: 80, SharedCost: 20}, {Xid: "ci", TotalCost: 10}}
+	MarkUpNamespaceCosts(costs)
+	utils.Equals(t, 15.0, costs[0].MarkupPercent)
+	utils.Assert(t, costs[0].MarkedUpCost > 114.999 && costs[0].MarkedUpCost < 115.001, "marked up cost: %f",
+		costs[0].MarkedUpCost)
+	// the raw costs are left unchanged
+	utils.Equals(t, 80.0, costs[0].TotalCost)
+	utils.Equals(t, 20.0, costs[0].SharedCost)
+	// a namespace without a markup is not marked up
+	utils.Equals(t, 0.0, costs[1].MarkupPercent)
+	utils.Equals(t, 0.0, costs[1].MarkedUpCost)
+}
+
+func TestMarkUp(t *testing.T) {
+	utils.Equals(t, 100.0, markUp(100, 0))
+	utils.Equals(t, 150.0, markUp(100, 50))
+	utils.Equals(t, -50.0, markUp(-100, -50))
+}
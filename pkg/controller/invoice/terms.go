@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"math"
+	"strings"
+)
+
+// BillingTerms of a group as written in its internal billing agreement. Quantities in hours are rounded up to whole
+// hours when RoundUpHours is set, their amount growing with them, then the charged total is raised to
+// MinimumMonthlyCharge and rounded up to a multiple of RoundUpAmount (ex: 1 to the nearest dollar) when they are set.
+type BillingTerms struct {
+	Group                string  `json:"group"`
+	MinimumMonthlyCharge float64 `json:"minimumMonthlyCharge,omitempty"`
+	RoundUpAmount        float64 `json:"roundUpAmount,omitempty"`
+	RoundUpHours         bool    `json:"roundUpHours,omitempty"`
+}
+
+// amountEpsilon is the amount below which an adjustment is a rounding error
+const amountEpsilon = 1e-9
+
+var terms []BillingTerms
+
+// GroupTerms returns the billing terms of the group, no minimum nor rounding if it has none
+func GroupTerms(group string) BillingTerms {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, t := range terms {
+		if t.Group == group {
+			return t
+		}
+	}
+	return BillingTerms{Group: group}
+}
+
+// roundUpHours rounds the quantities in hours of the line items up to whole hours, at the rate of the line item
+func roundUpHours(items []LineItem) {
+	for i, item := range items {
+		if item.Quantity <= 0 || !strings.HasSuffix(item.Unit, "hours") {
+			continue
+		}
+		rounded := math.Ceil(item.Quantity - amountEpsilon)
+		items[i].Amount = item.Amount * rounded / item.Quantity
+		items[i].Quantity = rounded
+	}
+}
+
+// applyTerms adds the line items raising the amount charged to the group (its marked up total when it has a markup)
+// to the minimum monthly charge and rounding it up. The adjustments are added to both totals and are not marked up.
+func applyTerms(invoice *Invoice, t BillingTerms) {
+	charged := invoice.Total
+	if invoice.MarkupPercent != 0 {
+		charged = invoice.MarkedUpTotal
+	}
+	if charged < t.MinimumMonthlyCharge {
+		addAdjustment(invoice, LineItem{Category: Minimum, Description: "minimum monthly charge", Amount: t.MinimumMonthlyCharge - charged})
+		charged = t.MinimumMonthlyCharge
+	}
+	if t.RoundUpAmount > 0 {
+		rounded := math.Ceil(charged/t.RoundUpAmount-amountEpsilon) * t.RoundUpAmount
+		if rounded-charged > amountEpsilon {
+			addAdjustment(invoice, LineItem{Category: Rounding, Description: "rounding of the charged amount", Amount: rounded - charged})
+		}
+	}
+}
+
+func addAdjustment(invoice *Invoice, item LineItem) {
+	invoice.Total += item.Amount
+	if invoice.MarkupPercent != 0 {
+		item.MarkedUpAmount = item.Amount
+		invoice.MarkedUpTotal += item.Amount
+	}
+	invoice.LineItems = append(invoice.LineItems, item)
+}
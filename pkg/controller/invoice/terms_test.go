@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestRoundUpHours(t *testing.T) {
+	items := []LineItem{
+		{Category: Compute, Quantity: 10.5, Unit: "cpu hours", Amount: 2.1},
+		{Category: External, Quantity: 3, Unit: "hours", Amount: 6},
+		{Category: GPU, Unit: "gpu hours"},
+	}
+	roundUpHours(items)
+	utils.Equals(t, []LineItem{
+		{Category: Compute, Quantity: 11, Unit: "cpu hours", Amount: 2.2},
+		{Category: External, Quantity: 3, Unit: "hours", Amount: 6},
+		{Category: GPU, Unit: "gpu hours"},
+	}, items)
+}
+
+func TestApplyTerms(t *testing.T) {
+	invoice := Invoice{LineItems: []LineItem{{Category: Compute, Amount: 12.5}}, Total: 12.5}
+	applyTerms(&invoice, BillingTerms{MinimumMonthlyCharge: 20, RoundUpAmount: 1})
+	utils.Equals(t, 20.0, invoice.Total)
+	utils.Equals(t, LineItem{Category: Minimum, Description: "minimum monthly charge", Amount: 7.5}, invoice.LineItems[1])
+	utils.Equals(t, 2, len(invoice.LineItems))
+
+	// the marked up total is rounded, the adjustment is not marked up
+	invoice = Invoice{LineItems: []LineItem{{Category: Compute, Amount: 40.16, MarkedUpAmount: 50.2}}, Total: 40.16,
+		MarkupPercent: 25, MarkedUpTotal: 50.2}
+	applyTerms(&invoice, BillingTerms{MinimumMonthlyCharge: 20, RoundUpAmount: 1})
+	utils.Equals(t, 2, len(invoice.LineItems))
+	rounding := invoice.LineItems[1]
+	utils.Equals(t, Rounding, rounding.Category)
+	utils.Equals(t, rounding.Amount, rounding.MarkedUpAmount)
+	utils.Equals(t, 51.0, invoice.MarkedUpTotal)
+	utils.Equals(t, 40.16+rounding.Amount, invoice.Total)
+}
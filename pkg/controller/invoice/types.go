@@ -0,0 +1,72 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package invoice
+
+import (
+	"github.com/vmware/purser/pkg/controller/pricing"
+)
+
+// Line item categories
+const (
+	Compute  = "compute"
+	Memory   = "memory"
+	Storage  = "storage"
+	GPU      = "gpu"
+	External = "external"
+
+	// adjustments of the billing terms of the group
+	Minimum  = "minimum"
+	Rounding = "rounding"
+)
+
+// Invoice is the cost of a group for a billing period [PeriodStart, PeriodEnd).
+type Invoice struct {
+	Group       string                `json:"group"`
+	PeriodStart string                `json:"periodStart"`
+	PeriodEnd   string                `json:"periodEnd"`
+	GeneratedAt string                `json:"generatedAt"`
+	LineItems   []LineItem            `json:"lineItems"`
+	Rates       []pricing.PricePeriod `json:"rates"`
+	Total       float64               `json:"total"`
+
+	// set when the group has a markup, the amounts above are the raw costs
+	MarkupPercent float64 `json:"markupPercent,omitempty"`
+	MarkedUpTotal float64 `json:"markedUpTotal,omitempty"`
+}
+
+// LineItem is a billed quantity of a resource, ex: 480 cpu hours of compute.
+type LineItem struct {
+	Category    string  `json:"category"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	Amount      float64 `json:"amount"`
+	// set when the group has a markup
+	MarkedUpAmount float64 `json:"markedUpAmount,omitempty"`
+}
+
+// Settings for the monthly invoice generation. Invoices of the previous month are written to OutputDir
+// on the first day of every month, nothing is written if OutputDir is empty. Markups of namespaces and groups are
+// added to their charged back costs, the others are marked up by DefaultMarkupPercent. The invoices of groups with
+// billing terms are adjusted to their minimum charge and rounding.
+type Settings struct {
+	OutputDir            string         `json:"outputDir,omitempty"`
+	Markups              []Markup       `json:"markups,omitempty"`
+	DefaultMarkupPercent float64        `json:"defaultMarkupPercent,omitempty"`
+	Terms                []BillingTerms `json:"terms,omitempty"`
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+const kedaScaledObjectsPath = "/apis/keda.sh/v1alpha1/scaledobjects"
+
+type scaledObject struct {
+	Metadata olmMetadata `json:"metadata"`
+	Spec     struct {
+		ScaleTargetRef struct {
+			Kind string `json:"kind"`
+			Name string `json:"name"`
+		} `json:"scaleTargetRef"`
+		MinReplicaCount *int `json:"minReplicaCount"`
+		MaxReplicaCount *int `json:"maxReplicaCount"`
+		Triggers        []struct {
+			Type string `json:"type"`
+		} `json:"triggers"`
+	} `json:"spec"`
+}
+
+// ListScaledObjects returns the KEDA ScaledObjects of the cluster. It returns an error if KEDA is not installed.
+func ListScaledObjects() ([]query.ScaledObject, error) {
+	if Kubeclient == nil {
+		return nil, fmt.Errorf("kubernetes client is not initialized")
+	}
+	data, err := Kubeclient.Discovery().RESTClient().Get().AbsPath(kedaScaledObjectsPath).DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var items []scaledObject
+	if err = decodeItems(data, &items); err != nil {
+		return nil, err
+	}
+	objects := make([]query.ScaledObject, 0, len(items))
+	for _, item := range items {
+		objects = append(objects, newScaledObject(item))
+	}
+	return objects, nil
+}
+
+// newScaledObject applies the defaults of KEDA: the target is a deployment, it scales between 0 and 100 replicas
+func newScaledObject(item scaledObject) query.ScaledObject {
+	object := query.ScaledObject{
+		Namespace:   item.Metadata.Namespace,
+		Name:        item.Metadata.Name,
+		TargetKind:  item.Spec.ScaleTargetRef.Kind,
+		TargetName:  item.Spec.ScaleTargetRef.Name,
+		MaxReplicas: 100,
+	}
+	if object.TargetKind == "" {
+		object.TargetKind = query.DeploymentKind
+	}
+	if item.Spec.MinReplicaCount != nil {
+		object.MinReplicas = *item.Spec.MinReplicaCount
+	}
+	if item.Spec.MaxReplicaCount != nil {
+		object.MaxReplicas = *item.Spec.MaxReplicaCount
+	}
+	for _, trigger := range item.Spec.Triggers {
+		object.Triggers = append(object.Triggers, trigger.Type)
+	}
+	return object
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Memory pressure levels, as share of the memory limit in use
+const (
+	highPressureRatio     = 0.7
+	criticalPressureRatio = 0.9
+)
+
+const (
+	checkInterval = 15 * time.Second
+	// payloadSize is the estimated size of a buffered event, most of it is the json of the kubernetes object
+	payloadSize = 8 * 1024
+	// bufferShare is the share of the memory limit which can be used by the event buffer
+	bufferShare     = 0.05
+	minBufferSize   = 100
+	bytesInMegaByte = 1024 * 1024
+)
+
+var (
+	mu       sync.RWMutex
+	limit    uint64
+	degraded bool
+	pruners  []func(aggressive bool)
+)
+
+// Setup sets the memory limit of the controller in MB, 0 means no limit
+func Setup(limitInMB uint64) {
+	mu.Lock()
+	defer mu.Unlock()
+	limit = limitInMB * bytesInMegaByte
+}
+
+// Start checks the memory in use periodically. Above 70% of the limit the caches are pruned, above 90% the
+// controller is degraded: caches are pruned aggressively and non essential work is skipped until the memory
+// in use is back below 70%.
+func Start(ctx context.Context) {
+	mu.RLock()
+	limited := limit > 0
+	mu.RUnlock()
+	if !limited {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkMemory()
+			}
+		}
+	}()
+}
+
+// OnPressure registers a function which releases cached objects under memory pressure. Aggressive is true if
+// the controller is degraded.
+func OnPressure(pruner func(aggressive bool)) {
+	mu.Lock()
+	defer mu.Unlock()
+	pruners = append(pruners, pruner)
+}
+
+// IsDegraded returns true if the memory in use is close to the limit and non essential work must be skipped
+func IsDegraded() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return degraded
+}
+
+// BufferSize returns the event buffer size fitting in the memory limit, at most maxSize
+func BufferSize(maxSize uint32) uint32 {
+	mu.RLock()
+	defer mu.RUnlock()
+	return bufferSize(limit, maxSize)
+}
+
+func bufferSize(limit uint64, maxSize uint32) uint32 {
+	if limit == 0 {
+		return maxSize
+	}
+	size := uint64(float64(limit) * bufferShare / payloadSize)
+	if size < minBufferSize {
+		size = minBufferSize
+	}
+	if size > uint64(maxSize) {
+		return maxSize
+	}
+	return uint32(size)
+}
+
+func checkMemory() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	mu.Lock()
+	ratio := float64(stats.HeapAlloc) / float64(limit)
+	wasDegraded := degraded
+	if ratio >= criticalPressureRatio {
+		degraded = true
+	} else if ratio < highPressureRatio {
+		degraded = false
+	}
+	isDegraded := degraded
+	registeredPruners := pruners
+	mu.Unlock()
+
+	if isDegraded != wasDegraded {
+		if isDegraded {
+			log.Warnf("controller memory in use: %d MB is close to the limit: %d MB, skipping non essential work",
+				stats.HeapAlloc/bytesInMegaByte, limit/bytesInMegaByte)
+		} else {
+			log.Infof("controller memory in use: %d MB is back under control", stats.HeapAlloc/bytesInMegaByte)
+		}
+	}
+	if ratio < highPressureRatio {
+		return
+	}
+	for _, prune := range registeredPruners {
+		prune(isDegraded)
+	}
+	debug.FreeOSMemory()
+}
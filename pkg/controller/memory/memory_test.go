@@ -0,0 +1,32 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package memory
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestBufferSize ...
+func TestBufferSize(t *testing.T) {
+	utils.Equals(t, uint32(5000), bufferSize(0, 5000))
+	utils.Equals(t, uint32(5000), bufferSize(4096*bytesInMegaByte, 5000))
+	utils.Equals(t, uint32(1638), bufferSize(256*bytesInMegaByte, 5000))
+	utils.Equals(t, uint32(minBufferSize), bufferSize(8*bytesInMegaByte, 5000))
+}
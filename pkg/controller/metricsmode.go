@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+// ProbeMetricsSource checks whether the metrics api serves the usage of pods and logs the metrics mode when it
+// changes. It runs on start, later probes are done by the activity sampling.
+func ProbeMetricsSource() {
+	if Kubeclient == nil {
+		return
+	}
+	_, _ = fetchPodMetrics()
+}
+
+// fetchPodMetrics returns the pod metrics served by the metrics api and records whether it is available
+func fetchPodMetrics() ([]byte, error) {
+	data, err := Kubeclient.Discovery().RESTClient().Get().AbsPath(podMetricsAPIPath).DoRaw()
+	if !models.SetMetricsAvailable(err == nil) {
+		return data, err
+	}
+	if err != nil {
+		log.Warnf("no metrics source is available, running in %s mode: costs are allocated from requests only and "+
+			"usage based reports are estimated, error: (%v)", models.MetricsModeBlackbox, err)
+	} else {
+		log.Infof("metrics api is available, running in %s mode", models.MetricsModeMeasured)
+	}
+	return data, err
+}
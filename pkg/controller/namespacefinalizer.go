@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// NamespaceFinalizer holds the deletion of a namespace until the cost of its lifetime is archived
+const NamespaceFinalizer = "purser.vmware.com/cost-archive"
+
+// NamespaceFinalizerSettings enable the finalizer which holds the deletion of namespaces until their cost is
+// archived, so that no cost is lost when the controller misses the deletion. It is disabled by default since it
+// needs the patch permission on namespaces and namespaces are not deleted while the controller is down.
+type NamespaceFinalizerSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+var namespaceFinalizerEnabled bool
+
+// SetupNamespaceFinalizer sets the settings of the namespace finalizer
+func SetupNamespaceFinalizer(settings NamespaceFinalizerSettings) {
+	namespaceFinalizerEnabled = settings.Enabled
+}
+
+// IsNamespaceFinalizerEnabled tells if the namespaces get the finalizer of purser
+func IsNamespaceFinalizerEnabled() bool {
+	return namespaceFinalizerEnabled
+}
+
+// HasNamespaceFinalizer tells if the deletion of the namespace waits for purser
+func HasNamespaceFinalizer(ns api_v1.Namespace) bool {
+	for _, finalizer := range ns.Finalizers {
+		if finalizer == NamespaceFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// PatchNamespaceFinalizers replaces the finalizers of the namespace. The patch carries the resource version of the
+// namespace so that it fails if the finalizers were changed since the namespace was read.
+func PatchNamespaceFinalizers(ns api_v1.Namespace, finalizers []string) error {
+	if Kubeclient == nil {
+		return errors.New("kubernetes client is not set")
+	}
+	if finalizers == nil {
+		finalizers = []string{}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"finalizers":      finalizers,
+			"resourceVersion": ns.ResourceVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = Kubeclient.CoreV1().Namespaces().Patch(ns.Name, types.MergePatchType, patch)
+	return err
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+// ScanNetworkPolicies stores the network policies of the namespaces processed by this controller replica and closes
+// the ones which were deleted since the previous scan
+func ScanNetworkPolicies() {
+	if Kubeclient == nil {
+		return
+	}
+	policies, err := Kubeclient.NetworkingV1().NetworkPolicies(meta_v1.NamespaceAll).List(meta_v1.ListOptions{})
+	if err != nil {
+		log.Errorf("unable to list network policies, error: (%v)", err)
+		return
+	}
+
+	scanTime := time.Now()
+	present := map[string]bool{}
+	for _, policy := range policies.Items {
+		present[policy.Namespace+":"+policy.Name] = true
+		if !sharding.Owns(policy.Namespace) {
+			continue
+		}
+		if err = models.StoreNetworkPolicy(policy); err != nil {
+			log.Errorf("unable to store network policy: (%s:%s), error: (%v)", policy.Namespace, policy.Name, err)
+		}
+	}
+	closed, err := models.CloseDeletedNetworkPolicies(present, sharding.Owns, scanTime)
+	if err != nil {
+		log.Errorf("unable to close deleted network policies, error: (%v)", err)
+	} else if closed > 0 {
+		log.Infof("closed %d deleted network policies", closed)
+	}
+}
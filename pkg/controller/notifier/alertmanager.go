@@ -0,0 +1,128 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	alertmanagerAlertsPath = "/api/v2/alerts"
+	defaultAlertName       = "PurserNotification"
+)
+
+// alert is an alert in the format of the Alertmanager API and of its webhook payload
+type alert struct {
+	Status      string            `json:"status,omitempty"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    string            `json:"startsAt"`
+	EndsAt      string            `json:"endsAt,omitempty"`
+}
+
+// webhookMessage is the payload Alertmanager posts to webhook receivers
+type webhookMessage struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []alert           `json:"alerts"`
+}
+
+// alertmanagerNotifier sends warning and critical notifications as Alertmanager alerts, so that routing,
+// grouping and silencing are done by the existing Alertmanager configuration
+type alertmanagerNotifier struct {
+	settings AlertmanagerSettings
+}
+
+// Name returns the notifier name
+func (a *alertmanagerNotifier) Name() string {
+	return "alertmanager"
+}
+
+// Notify pushes the notification to the Alertmanager or posts it to the webhook receiver
+func (a *alertmanagerNotifier) Notify(notification Notification) error {
+	if notification.Severity != Warning && notification.Severity != Critical {
+		return nil
+	}
+	headers := map[string]string{}
+	if a.settings.TokenFile != "" {
+		token, err := readFile(a.settings.TokenFile)
+		if err != nil {
+			return err
+		}
+		headers["Authorization"] = "Bearer " + token
+	}
+
+	firing := a.newAlert(notification, time.Now())
+	if a.settings.Mode == AlertmanagerWebhook {
+		firing.Status = "firing"
+		message := webhookMessage{
+			Version:           "4",
+			GroupKey:          "{}:{alertname=\"" + firing.Labels["alertname"] + "\"}",
+			Status:            "firing",
+			Receiver:          incidentSource,
+			GroupLabels:       map[string]string{"alertname": firing.Labels["alertname"]},
+			CommonLabels:      firing.Labels,
+			CommonAnnotations: firing.Annotations,
+			Alerts:            []alert{firing},
+		}
+		return postJSON(a.settings.URL, headers, message)
+	}
+	return postJSON(strings.TrimSuffix(a.settings.URL, "/")+alertmanagerAlertsPath, headers, []alert{firing})
+}
+
+// newAlert converts the notification to an alert. Repeated notifications of a condition have the same labels,
+// so Alertmanager treats them as the same alert.
+func (a *alertmanagerNotifier) newAlert(notification Notification, now time.Time) alert {
+	labels := map[string]string{}
+	for key, value := range a.settings.Labels {
+		labels[key] = value
+	}
+	labels["alertname"] = alertName(notification.Kind)
+	labels["severity"] = notification.Severity
+	if notification.Scope != "" {
+		labels["scope"] = notification.Scope
+	}
+	if notification.DedupKey != "" {
+		labels["dedup_key"] = notification.DedupKey
+	}
+
+	converted := alert{
+		Labels:      labels,
+		Annotations: map[string]string{"summary": notification.Title, "description": notification.Summary},
+		StartsAt:    now.UTC().Format(time.RFC3339),
+	}
+	if !notification.EndsAt.IsZero() {
+		converted.EndsAt = notification.EndsAt.UTC().Format(time.RFC3339)
+	}
+	return converted
+}
+
+// alertName returns the alert name of a notification kind, ex: PurserBudget
+func alertName(kind string) string {
+	if kind == "" {
+		return defaultAlertName
+	}
+	return "Purser" + strings.ToUpper(kind[:1]) + kind[1:]
+}
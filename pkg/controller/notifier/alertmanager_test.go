@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestAlertName(t *testing.T) {
+	utils.Equals(t, "PurserBudget", alertName(BudgetKind))
+	utils.Equals(t, "PurserInactiveWorkload", alertName(InactiveWorkloadKind))
+	utils.Equals(t, defaultAlertName, alertName(""))
+}
+
+func TestNewAlert(t *testing.T) {
+	a := &alertmanagerNotifier{settings: AlertmanagerSettings{Labels: map[string]string{"cluster": "prod"}}}
+	now := time.Date(2018, 11, 17, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	converted := a.newAlert(Notification{Title: "Budget of shop is 85% spent", Summary: "85 of 100 is spent.",
+		Kind: BudgetKind, Severity: Warning, Scope: "shop", DedupKey: "budget-namespace-shop-2018-11",
+		EndsAt: time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)}, now)
+	utils.Equals(t, alert{
+		Labels: map[string]string{"cluster": "prod", "alertname": "PurserBudget", "severity": Warning, "scope": "shop",
+			"dedup_key": "budget-namespace-shop-2018-11"},
+		Annotations: map[string]string{"summary": "Budget of shop is 85% spent", "description": "85 of 100 is spent."},
+		StartsAt:    "2018-11-17T11:00:00Z",
+		EndsAt:      "2018-12-01T00:00:00Z",
+	}, converted)
+	// the labels of the settings are not modified
+	utils.Equals(t, map[string]string{"cluster": "prod"}, a.settings.Labels)
+
+	converted = a.newAlert(Notification{Severity: Critical}, now)
+	utils.Equals(t, "", converted.EndsAt)
+	_, hasScope := converted.Labels["scope"]
+	utils.Assert(t, !hasScope, "alert without scope labeled with one: %v", converted.Labels)
+}
+
+func TestAlertmanagerNotify(t *testing.T) {
+	received := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		received[r.URL.Path] = body
+	}))
+	defer server.Close()
+
+	notification := Notification{Title: "Budget of shop exceeded", Kind: BudgetKind, Severity: Critical}
+	utils.Ok(t, (&alertmanagerNotifier{settings: AlertmanagerSettings{URL: server.URL + "/"}}).Notify(notification))
+	var alerts []alert
+	utils.Ok(t, json.Unmarshal(received[alertmanagerAlertsPath], &alerts))
+	utils.Equals(t, 1, len(alerts))
+	utils.Equals(t, "", alerts[0].Status)
+	utils.Equals(t, "PurserBudget", alerts[0].Labels["alertname"])
+
+	webhook := &alertmanagerNotifier{settings: AlertmanagerSettings{URL: server.URL + "/hooks/purser", Mode: AlertmanagerWebhook}}
+	utils.Ok(t, webhook.Notify(notification))
+	message := webhookMessage{}
+	utils.Ok(t, json.Unmarshal(received["/hooks/purser"], &message))
+	utils.Equals(t, "4", message.Version)
+	utils.Equals(t, `{}:{alertname="PurserBudget"}`, message.GroupKey)
+	utils.Equals(t, map[string]string{"alertname": "PurserBudget"}, message.GroupLabels)
+	utils.Equals(t, 1, len(message.Alerts))
+	utils.Equals(t, "firing", message.Alerts[0].Status)
+
+	// info notifications are not alerts
+	delete(received, alertmanagerAlertsPath)
+	utils.Ok(t, (&alertmanagerNotifier{settings: AlertmanagerSettings{URL: server.URL}}).Notify(Notification{Severity: Info}))
+	utils.Equals(t, 1, len(received))
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	startTLS    = "starttls"
+	implicitTLS = "tls"
+	noTLS       = "none"
+
+	defaultSMTPPort = 587
+)
+
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<h2>{{.Title}}</h2>
+<p>{{.Summary}}</p>
+{{with .Table}}<table style="border-collapse: collapse;">
+<tr>{{range .Headers}}<th style="border-bottom: 1px solid #999; padding: 4px 8px; text-align: left;">{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td style="border-bottom: 1px solid #ddd; padding: 4px 8px;">{{.}}</td>{{end}}</tr>
+{{end}}</table>{{end}}
+<p style="color: #777; font-size: small;">Sent by Purser</p>
+</body>
+</html>
+`))
+
+// emailNotifier sends notifications as html emails over SMTP
+type emailNotifier struct {
+	settings EmailSettings
+}
+
+func newEmailNotifier(settings EmailSettings) *emailNotifier {
+	if settings.Port == 0 {
+		settings.Port = defaultSMTPPort
+	}
+	if settings.TLS == "" {
+		settings.TLS = startTLS
+	}
+	return &emailNotifier{settings: settings}
+}
+
+// Name returns the notifier name
+func (e *emailNotifier) Name() string {
+	return "email"
+}
+
+// Notify sends the notification to all recipients
+func (e *emailNotifier) Notify(notification Notification) error {
+	message, err := e.message(notification)
+	if err != nil {
+		return err
+	}
+
+	client, err := e.connect()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	if e.settings.Username != "" {
+		password, err := ioutil.ReadFile(e.settings.PasswordFile)
+		if err != nil {
+			return err
+		}
+		auth := smtp.PlainAuth("", e.settings.Username, strings.TrimSpace(string(password)), e.settings.Host)
+		if err = client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(e.settings.From); err != nil {
+		return err
+	}
+	for _, to := range e.settings.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(message); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (e *emailNotifier) connect() (*smtp.Client, error) {
+	address := net.JoinHostPort(e.settings.Host, strconv.Itoa(e.settings.Port))
+	tlsConfig := &tls.Config{ServerName: e.settings.Host}
+
+	switch e.settings.TLS {
+	case implicitTLS:
+		conn, err := tls.Dial("tcp", address, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, e.settings.Host)
+	case startTLS:
+		client, err := smtp.Dial(address)
+		if err != nil {
+			return nil, err
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+		return client, nil
+	case noTLS:
+		return smtp.Dial(address)
+	}
+	return nil, fmt.Errorf("unknown smtp tls mode: %s", e.settings.TLS)
+}
+
+// message returns the email headers and html body of the notification
+func (e *emailNotifier) message(notification Notification) ([]byte, error) {
+	var message bytes.Buffer
+	subject := notification.Title
+	if notification.Severity == Warning || notification.Severity == Critical {
+		subject = "[" + strings.ToUpper(notification.Severity) + "] " + subject
+	}
+	headers := []string{
+		"From: " + e.settings.From,
+		"To: " + strings.Join(e.settings.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/html; charset=UTF-8",
+	}
+	message.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+	if err := emailTemplate.Execute(&message, notification); err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestNewEmailNotifier(t *testing.T) {
+	e := newEmailNotifier(EmailSettings{Host: "smtp.example.com"})
+	utils.Equals(t, defaultSMTPPort, e.settings.Port)
+	utils.Equals(t, startTLS, e.settings.TLS)
+
+	e = newEmailNotifier(EmailSettings{Host: "smtp.example.com", Port: 465, TLS: implicitTLS})
+	utils.Equals(t, 465, e.settings.Port)
+	utils.Equals(t, implicitTLS, e.settings.TLS)
+
+	e.settings.TLS = "ssl"
+	_, err := e.connect()
+	utils.Assert(t, err != nil, "connected with an unknown tls mode")
+}
+
+func TestEmailMessage(t *testing.T) {
+	e := newEmailNotifier(EmailSettings{From: "purser@example.com", To: []string{"a@example.com", "b@example.com"}})
+	message, err := e.message(Notification{Title: "Budget of shop", Summary: "90% of the budget is spent.",
+		Severity: Warning, Table: &Table{Headers: []string{"Namespace"}, Rows: [][]string{{"<shop>"}}}})
+	utils.Ok(t, err)
+	parts := strings.SplitN(string(message), "\r\n\r\n", 2)
+	utils.Equals(t, 2, len(parts))
+	headers := strings.Split(parts[0], "\r\n")
+	utils.Equals(t, "From: purser@example.com", headers[0])
+	utils.Equals(t, "To: a@example.com, b@example.com", headers[1])
+	utils.Equals(t, "Subject: [WARNING] Budget of shop", headers[2])
+	utils.Equals(t, "Content-Type: text/html; charset=UTF-8", headers[len(headers)-1])
+	utils.Assert(t, strings.Contains(parts[1], "<h2>Budget of shop</h2>"), "title not rendered in: %s", parts[1])
+	utils.Assert(t, strings.Contains(parts[1], "&lt;shop&gt;</td>"), "table not escaped in: %s", parts[1])
+
+	// info notifications keep their title, non ascii subjects are encoded
+	message, err = e.message(Notification{Title: "Coût", Severity: Info})
+	utils.Ok(t, err)
+	utils.Assert(t, strings.Contains(string(message), "Subject: =?utf-8?q?Co=C3=BBt?=\r\n"), "subject not encoded: %s", message)
+}
+
+// serveSMTP accepts a single smtp session on the listener and sends the commands and the data it receives
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		close(received)
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	var lines []string
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ready")
+	for inData := false; ; {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case inData:
+			if line == "." {
+				inData = false
+				reply("250 queued")
+			}
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250 localhost")
+		case line == "DATA":
+			inData = true
+			reply("354 end data with .")
+		case line == "QUIT":
+			reply("221 bye")
+			received <- lines
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+	received <- lines
+}
+
+func TestEmailNotify(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	utils.Ok(t, err)
+	defer func() {
+		_ = listener.Close()
+	}()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	e := newEmailNotifier(EmailSettings{Host: "127.0.0.1", Port: listener.Addr().(*net.TCPAddr).Port, TLS: noTLS, From: "purser@example.com",
+		To: []string{"a@example.com", "b@example.com"}})
+	utils.Ok(t, e.Notify(Notification{Title: "Purser invoices", Severity: Info}))
+
+	session := strings.Join(<-received, "\n")
+	for _, expected := range []string{"MAIL FROM:<purser@example.com>", "RCPT TO:<a@example.com>", "RCPT TO:<b@example.com>",
+		"Subject: Purser invoices", "<h2>Purser invoices</h2>", "QUIT"} {
+		utils.Assert(t, strings.Contains(session, expected), "%s not sent in session: %s", expected, session)
+	}
+}
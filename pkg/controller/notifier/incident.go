@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieAlertsURL  = "https://api.opsgenie.com/v2/alerts"
+	incidentSource     = "purser"
+	httpTimeout        = 30 * time.Second
+)
+
+// pagerDutyNotifier triggers PagerDuty incidents with the Events API v2
+type pagerDutyNotifier struct {
+	settings IncidentSettings
+}
+
+// Name returns the notifier name
+func (p *pagerDutyNotifier) Name() string {
+	return "pagerduty"
+}
+
+// Notify triggers an incident for critical notifications, the dedup key groups repeated triggers into one incident
+func (p *pagerDutyNotifier) Notify(notification Notification) error {
+	if notification.Severity != Critical {
+		return nil
+	}
+	routingKey, err := p.settings.key(notification.Scope)
+	if err != nil {
+		return err
+	}
+	event := map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    notification.DedupKey,
+		"payload": map[string]interface{}{
+			"summary":        notification.Title,
+			"source":         incidentSource,
+			"severity":       Critical,
+			"component":      notification.Scope,
+			"custom_details": details(notification),
+		},
+	}
+	return postJSON(pagerDutyEventsURL, nil, event)
+}
+
+// opsgenieNotifier creates Opsgenie alerts with the Alert API
+type opsgenieNotifier struct {
+	settings IncidentSettings
+}
+
+// Name returns the notifier name
+func (o *opsgenieNotifier) Name() string {
+	return "opsgenie"
+}
+
+// Notify creates a P1 alert for critical notifications, the dedup key is used as alias so that repeated
+// notifications of an open alert only increase its count
+func (o *opsgenieNotifier) Notify(notification Notification) error {
+	if notification.Severity != Critical {
+		return nil
+	}
+	apiKey, err := o.settings.key(notification.Scope)
+	if err != nil {
+		return err
+	}
+	alert := map[string]interface{}{
+		"message":     notification.Title,
+		"alias":       notification.DedupKey,
+		"description": notification.Summary,
+		"source":      incidentSource,
+		"priority":    "P1",
+		"details":     details(notification),
+	}
+	if notification.Scope != "" {
+		alert["tags"] = []string{notification.Scope}
+	}
+	return postJSON(opsgenieAlertsURL, map[string]string{"Authorization": "GenieKey " + apiKey}, alert)
+}
+
+// key returns the key of the team responsible for the scope, or the default key
+func (s IncidentSettings) key(scope string) (string, error) {
+	keyFile := s.KeyFile
+	if routeKeyFile, isRouted := s.Routes[scope]; isRouted {
+		keyFile = routeKeyFile
+	}
+	return readFile(keyFile)
+}
+
+// readFile returns the trimmed content of a secret file
+func readFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// details returns the summary and the rows of the summary table of the notification as key value pairs
+func details(notification Notification) map[string]string {
+	values := map[string]string{"summary": notification.Summary}
+	if notification.Table == nil {
+		return values
+	}
+	for _, row := range notification.Table.Rows {
+		if len(row) > 1 {
+			values[row[0]] = strings.Join(row[1:], ", ")
+		}
+	}
+	return values
+}
+
+func postJSON(url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	if resp.StatusCode >= http.StatusBadRequest {
+		response, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("request to %s failed with status: %s, response: %s", url, resp.Status, string(response))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestIncidentKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "incident")
+	utils.Ok(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	defaultKey, shopKey := filepath.Join(dir, "default"), filepath.Join(dir, "shop")
+	utils.Ok(t, ioutil.WriteFile(defaultKey, []byte("default-key\n"), 0600))
+	utils.Ok(t, ioutil.WriteFile(shopKey, []byte("shop-key"), 0600))
+
+	settings := IncidentSettings{KeyFile: defaultKey, Routes: map[string]string{"shop": shopKey}}
+	key, err := settings.key("shop")
+	utils.Ok(t, err)
+	utils.Equals(t, "shop-key", key)
+	key, err = settings.key("ci")
+	utils.Ok(t, err)
+	utils.Equals(t, "default-key", key)
+
+	settings.KeyFile = filepath.Join(dir, "missing")
+	_, err = settings.key("ci")
+	utils.Assert(t, err != nil, "key read from a missing file")
+}
+
+func TestIncidentsOnlyForCriticalNotifications(t *testing.T) {
+	// the key files are not read for notifications which do not create incidents
+	settings := IncidentSettings{KeyFile: "/nonexistent"}
+	warning := Notification{Title: "Budget of shop is 85% spent", Severity: Warning}
+	utils.Ok(t, (&pagerDutyNotifier{settings: settings}).Notify(warning))
+	utils.Ok(t, (&opsgenieNotifier{settings: settings}).Notify(warning))
+	utils.Assert(t, (&pagerDutyNotifier{settings: settings}).Notify(Notification{Severity: Critical}) != nil,
+		"incident triggered without a routing key")
+}
+
+func TestDetails(t *testing.T) {
+	utils.Equals(t, map[string]string{"summary": "over budget"}, details(Notification{Summary: "over budget"}))
+	notification := Notification{Summary: "over budget", Table: &Table{Headers: []string{"Namespace", "Cost", "Limit"},
+		Rows: [][]string{{"shop", "120", "100"}, {"ignored"}}}}
+	utils.Equals(t, map[string]string{"summary": "over budget", "shop": "120, 100"}, details(notification))
+}
+
+func TestPostJSON(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "GenieKey key" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	utils.Ok(t, postJSON(server.URL, map[string]string{"Authorization": "GenieKey key"}, map[string]string{"alias": "budget-shop"}))
+	utils.Equals(t, map[string]interface{}{"alias": "budget-shop"}, received)
+	utils.Assert(t, postJSON(server.URL, nil, map[string]string{}) != nil, "rejected request succeeded")
+}
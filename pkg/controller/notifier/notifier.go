@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	mu        sync.RWMutex
+	notifiers []Notifier
+)
+
+// Setup creates the notifiers which are configured in the settings
+func Setup(settings Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifiers = nil
+	if settings.Email != nil {
+		notifiers = append(notifiers, newEmailNotifier(*settings.Email))
+	}
+	if settings.PagerDuty != nil {
+		notifiers = append(notifiers, &pagerDutyNotifier{settings: *settings.PagerDuty})
+	}
+	if settings.Opsgenie != nil {
+		notifiers = append(notifiers, &opsgenieNotifier{settings: *settings.Opsgenie})
+	}
+	if settings.Alertmanager != nil {
+		notifiers = append(notifiers, &alertmanagerNotifier{settings: *settings.Alertmanager})
+	}
+}
+
+// AddNotifier registers an additional notifier
+func AddNotifier(n Notifier) {
+	mu.Lock()
+	defer mu.Unlock()
+	notifiers = append(notifiers, n)
+}
+
+// Notify delivers the notification with every configured notifier. Nothing is sent if no notifier is configured.
+func Notify(notification Notification) error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var failed []string
+	for _, n := range notifiers {
+		if err := n.Notify(notification); err != nil {
+			log.Errorf("unable to send notification: (%s) with %s, error: %v", notification.Title, n.Name(), err)
+			failed = append(failed, n.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("notification failed with %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+type recordingNotifier struct {
+	name     string
+	err      error
+	received []Notification
+}
+
+func (n *recordingNotifier) Name() string {
+	return n.name
+}
+
+func (n *recordingNotifier) Notify(notification Notification) error {
+	n.received = append(n.received, notification)
+	return n.err
+}
+
+func TestNotify(t *testing.T) {
+	defer Setup(Settings{})
+
+	Setup(Settings{Email: &EmailSettings{Host: "smtp.example.com"}, Alertmanager: &AlertmanagerSettings{URL: "http://am"}})
+	utils.Equals(t, 2, len(notifiers))
+	utils.Equals(t, "email", notifiers[0].Name())
+
+	Setup(Settings{})
+	utils.Ok(t, Notify(Notification{Title: "nobody is notified"}))
+
+	failing := &recordingNotifier{name: "failing", err: errors.New("unreachable")}
+	working := &recordingNotifier{name: "working"}
+	AddNotifier(failing)
+	AddNotifier(working)
+	err := Notify(Notification{Title: "Budget of shop"})
+	utils.Assert(t, err != nil && err.Error() == "notification failed with failing", "unexpected error: %v", err)
+	// a failing notifier does not keep the others from being notified
+	utils.Equals(t, []Notification{{Title: "Budget of shop"}}, working.received)
+	utils.Equals(t, 1, len(failing.received))
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notifier
+
+import "time"
+
+// Severities of notifications
+const (
+	Info     = "info"
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+// Kinds of notifications
+const (
+	BudgetKind           = "budget"
+	InvoiceKind          = "invoice"
+	InactiveWorkloadKind = "inactiveWorkload"
+)
+
+// Notification is a message about the cost of the cluster (ex: a scheduled report, a budget breach).
+// DedupKey identifies the condition being notified so that repeated notifications of the same condition
+// can be grouped by the receiving system. Scope is the namespace or group the notification is about.
+// EndsAt is the time until which the notified condition holds, zero if it is not known.
+type Notification struct {
+	Title    string    `json:"title"`
+	Summary  string    `json:"summary"`
+	Kind     string    `json:"kind,omitempty"`
+	Severity string    `json:"severity"`
+	Scope    string    `json:"scope,omitempty"`
+	DedupKey string    `json:"dedupKey,omitempty"`
+	EndsAt   time.Time `json:"endsAt,omitempty"`
+	Table    *Table    `json:"table,omitempty"`
+}
+
+// Table is a summary table embedded in a notification
+type Table struct {
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Notifier delivers notifications to a channel
+type Notifier interface {
+	Name() string
+	Notify(notification Notification) error
+}
+
+// Settings of the notifiers. Notifiers without settings are disabled.
+type Settings struct {
+	Email     *EmailSettings    `json:"email,omitempty"`
+	PagerDuty *IncidentSettings `json:"pagerDuty,omitempty"`
+	Opsgenie  *IncidentSettings `json:"opsgenie,omitempty"`
+
+	Alertmanager *AlertmanagerSettings `json:"alertmanager,omitempty"`
+}
+
+// EmailSettings of the SMTP notifier. TLS is one of starttls (default), tls (implicit TLS, usually port 465)
+// or none. The password is read from PasswordFile.
+type EmailSettings struct {
+	Host         string   `json:"host"`
+	Port         int      `json:"port,omitempty"`
+	Username     string   `json:"username,omitempty"`
+	PasswordFile string   `json:"passwordFile,omitempty"`
+	From         string   `json:"from"`
+	To           []string `json:"to"`
+	TLS          string   `json:"tls,omitempty"`
+}
+
+// IncidentSettings of an incident notifier (PagerDuty, Opsgenie). Only critical notifications create incidents.
+// Key is the PagerDuty integration (routing) key or the Opsgenie API key, read from KeyFile. Routes map the
+// scope of a notification (namespace or group) to the key file of the team responsible for it.
+type IncidentSettings struct {
+	KeyFile string            `json:"keyFile"`
+	Routes  map[string]string `json:"routes,omitempty"`
+}
+
+// Alertmanager delivery modes
+const (
+	AlertmanagerAPI     = "api"
+	AlertmanagerWebhook = "webhook"
+)
+
+// AlertmanagerSettings of the Alertmanager notifier. In api mode (default) warning and critical notifications
+// are pushed as alerts to the Alertmanager at URL, in webhook mode they are posted to URL in the Alertmanager
+// webhook format. Labels are added to every alert (ex: cluster). The optional bearer token is read from TokenFile.
+type AlertmanagerSettings struct {
+	URL       string            `json:"url"`
+	Mode      string            `json:"mode,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	TokenFile string            `json:"tokenFile,omitempty"`
+}
@@ -0,0 +1,169 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+// versions of the apiextensions.k8s.io api, newest first
+var crdAPIPaths = []string{
+	"/apis/apiextensions.k8s.io/v1/customresourcedefinitions",
+	"/apis/apiextensions.k8s.io/v1beta1/customresourcedefinitions",
+}
+
+// customResourceDefinition is the part of a crd needed to list its namespaced custom resources
+type customResourceDefinition struct {
+	Spec struct {
+		Group   string `json:"group"`
+		Scope   string `json:"scope"`
+		Version string `json:"version"`
+		Names   struct {
+			Plural string `json:"plural"`
+		} `json:"names"`
+		Versions []struct {
+			Name    string `json:"name"`
+			Storage bool   `json:"storage"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// ScanObjectCounts samples the number of config maps, secrets and custom resources of the namespaces processed by
+// this controller replica with the size of their manifests. They cost nothing directly but bloat the control plane.
+func ScanObjectCounts() {
+	if Kubeclient == nil {
+		return
+	}
+	counts := map[string]*models.ObjectCount{}
+	countObjects("/api/v1/configmaps", counts, func(c *models.ObjectCount) { c.ConfigMaps++ })
+	countObjects("/api/v1/secrets", counts, func(c *models.ObjectCount) { c.Secrets++ })
+	for _, path := range customResourcePaths() {
+		countObjects(path, counts, func(c *models.ObjectCount) { c.CustomResources++ })
+	}
+
+	scanTime := time.Now()
+	for namespace, count := range counts {
+		if !sharding.Owns(namespace) {
+			continue
+		}
+		if err := models.StoreObjectCount(namespace, *count, scanTime); err != nil {
+			log.Errorf("unable to store object counts of namespace: (%s), error: (%v)", namespace, err)
+		}
+	}
+	deleted, err := models.DeleteExpiredObjectCounts(sharding.Owns, scanTime)
+	if err != nil {
+		log.Errorf("unable to delete expired object counts, error: (%v)", err)
+	} else if deleted > 0 {
+		log.Infof("deleted %d expired object counts", deleted)
+	}
+}
+
+func countObjects(path string, counts map[string]*models.ObjectCount, add func(*models.ObjectCount)) {
+	data, err := Kubeclient.Discovery().RESTClient().Get().AbsPath(path).DoRaw()
+	if err != nil {
+		log.Debugf("skipping objects of %s, unable to list them: (%v)", path, err)
+		return
+	}
+	if err = tallyObjects(data, counts, add); err != nil {
+		log.Errorf("unable to decode objects of %s, error: (%v)", path, err)
+	}
+}
+
+// tallyObjects adds every namespaced object of the list to the counts of its namespace
+func tallyObjects(data []byte, counts map[string]*models.ObjectCount, add func(*models.ObjectCount)) error {
+	type objectList struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	list := objectList{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	for _, item := range list.Items {
+		object := struct {
+			Metadata struct {
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}{}
+		if err := json.Unmarshal(item, &object); err != nil {
+			return err
+		}
+		namespace := object.Metadata.Namespace
+		if namespace == "" {
+			continue
+		}
+		if counts[namespace] == nil {
+			counts[namespace] = &models.ObjectCount{}
+		}
+		add(counts[namespace])
+		counts[namespace].ObjectBytes += len(item)
+	}
+	return nil
+}
+
+// customResourcePaths returns the api paths listing the custom resources of every namespaced crd
+func customResourcePaths() []string {
+	var data []byte
+	var err error
+	for _, path := range crdAPIPaths {
+		if data, err = Kubeclient.Discovery().RESTClient().Get().AbsPath(path).DoRaw(); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Debugf("skipping custom resources, unable to list crds: (%v)", err)
+		return nil
+	}
+	paths, err := namespacedResourcePaths(data)
+	if err != nil {
+		log.Errorf("unable to decode crds, error: (%v)", err)
+	}
+	return paths
+}
+
+func namespacedResourcePaths(data []byte) ([]string, error) {
+	type crdList struct {
+		Items []customResourceDefinition `json:"items"`
+	}
+	crds := crdList{}
+	if err := json.Unmarshal(data, &crds); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, crd := range crds.Items {
+		if crd.Spec.Scope != "Namespaced" {
+			continue
+		}
+		version := crd.Spec.Version
+		for _, v := range crd.Spec.Versions {
+			if v.Storage {
+				version = v.Name
+			}
+		}
+		if version == "" {
+			continue
+		}
+		paths = append(paths, "/apis/"+crd.Spec.Group+"/"+version+"/"+crd.Spec.Names.Plural)
+	}
+	return paths, nil
+}
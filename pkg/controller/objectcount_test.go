@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestTallyObjects(t *testing.T) {
+	counts := map[string]*models.ObjectCount{}
+	configMaps := `{"items": [
+		{"metadata": {"name": "a", "namespace": "shop"}, "data": {"k": "v"}},
+		{"metadata": {"name": "b", "namespace": "shop"}},
+		{"metadata": {"name": "c", "namespace": "web"}}
+	]}`
+	utils.Ok(t, tallyObjects([]byte(configMaps), counts, func(c *models.ObjectCount) { c.ConfigMaps++ }))
+	utils.Ok(t, tallyObjects([]byte(`{"items": [{"metadata": {"name": "s", "namespace": "web"}}]}`), counts, func(c *models.ObjectCount) { c.Secrets++ }))
+
+	utils.Equals(t, 2, len(counts))
+	utils.Equals(t, 2, counts["shop"].ConfigMaps)
+	utils.Equals(t, 1, counts["web"].ConfigMaps)
+	utils.Equals(t, 1, counts["web"].Secrets)
+	// the footprint is the size of the manifests of the namespace
+	utils.Equals(t, len(`{"metadata": {"name": "c", "namespace": "web"}}`)+len(`{"metadata": {"name": "s", "namespace": "web"}}`), counts["web"].ObjectBytes)
+}
+
+func TestNamespacedResourcePaths(t *testing.T) {
+	crds := `{"items": [
+		{"spec": {"group": "etcd.database.coreos.com", "scope": "Namespaced", "version": "v1beta2", "names": {"plural": "etcdclusters"}}},
+		{"spec": {"group": "cert-manager.io", "scope": "Namespaced", "names": {"plural": "certificates"},
+			"versions": [{"name": "v1alpha2", "storage": false}, {"name": "v1", "storage": true}]}},
+		{"spec": {"group": "cert-manager.io", "scope": "Cluster", "names": {"plural": "clusterissuers"}, "versions": [{"name": "v1", "storage": true}]}}
+	]}`
+	paths, err := namespacedResourcePaths([]byte(crds))
+	utils.Ok(t, err)
+	utils.Equals(t, []string{"/apis/etcd.database.coreos.com/v1beta2/etcdclusters", "/apis/cert-manager.io/v1/certificates"}, paths)
+}
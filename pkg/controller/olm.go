@@ -0,0 +1,226 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+)
+
+const (
+	olmAPIPath         = "/apis/operators.coreos.com/v1alpha1/"
+	olmCopiedFromLabel = "olm.copiedFrom"
+)
+
+type ownerReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+type olmMetadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	Labels          map[string]string `json:"labels"`
+	OwnerReferences []ownerReference  `json:"ownerReferences"`
+}
+
+type clusterServiceVersion struct {
+	Metadata olmMetadata `json:"metadata"`
+	Spec     struct {
+		DisplayName string `json:"displayName"`
+		Version     string `json:"version"`
+		Install     struct {
+			Spec struct {
+				Deployments []struct {
+					Name string `json:"name"`
+				} `json:"deployments"`
+			} `json:"spec"`
+		} `json:"install"`
+		CustomResourceDefinitions struct {
+			Owned []struct {
+				Name string `json:"name"`
+				Kind string `json:"kind"`
+			} `json:"owned"`
+		} `json:"customresourcedefinitions"`
+	} `json:"spec"`
+}
+
+type subscription struct {
+	Metadata olmMetadata `json:"metadata"`
+	Spec     struct {
+		Package string `json:"name"`
+		Channel string `json:"channel"`
+	} `json:"spec"`
+	Status struct {
+		InstalledCSV string `json:"installedCSV"`
+	} `json:"status"`
+}
+
+type workloadObject struct {
+	kind     string
+	metadata olmMetadata
+}
+
+// SyncOperators stores the operators installed by the Operator Lifecycle Manager and attributes the pods of their
+// deployments, and of the workloads owned by their custom resources, to them. Clusters without OLM are skipped.
+func SyncOperators() {
+	if Kubeclient == nil {
+		return
+	}
+	csvs := []clusterServiceVersion{}
+	if err := listOLMResources("clusterserviceversions", &csvs); err != nil {
+		log.Debugf("skipping operator cost mapping, unable to list cluster service versions: (%v)", err)
+		return
+	}
+	subscriptions := []subscription{}
+	if err := listOLMResources("subscriptions", &subscriptions); err != nil {
+		log.Errorf("unable to list operator subscriptions, error: (%v)", err)
+	}
+	workloads, err := listWorkloadObjects()
+	if err != nil {
+		log.Errorf("unable to list workloads for operator cost mapping, error: (%v)", err)
+		return
+	}
+
+	for _, csv := range csvs {
+		// OLM copies a CSV to every namespace the operator watches, only the original one is installed
+		if _, ok := csv.Metadata.Labels[olmCopiedFromLabel]; ok {
+			continue
+		}
+		operator := models.Operator{
+			ID:          dgraph.ID{Xid: csv.Metadata.Namespace + ":" + csv.Metadata.Name},
+			CSVName:     csv.Metadata.Name,
+			DisplayName: csv.Spec.DisplayName,
+			Version:     csv.Spec.Version,
+			Namespace:   csv.Metadata.Namespace,
+		}
+		for _, sub := range subscriptions {
+			if sub.Metadata.Namespace == csv.Metadata.Namespace && sub.Status.InstalledCSV == csv.Metadata.Name {
+				operator.Subscription = sub.Metadata.Name
+				operator.Package = sub.Spec.Package
+				operator.Channel = sub.Spec.Channel
+				break
+			}
+		}
+		uid, err := models.StoreOperator(operator)
+		if err != nil {
+			log.Errorf("unable to store operator: (%s), error: (%v)", operator.Xid, err)
+			continue
+		}
+		linked, err := models.LinkOperatorPods(uid, operatorWorkloads(csv, workloads))
+		if err != nil {
+			log.Errorf("unable to link pods of operator: (%s), error: (%v)", operator.Xid, err)
+			continue
+		}
+		if linked > 0 {
+			log.Infof("linked %d pods to operator: (%s)", linked, operator.Xid)
+		}
+	}
+}
+
+// operatorWorkloads returns the deployments installed by the CSV and the workloads owned by its custom resources
+func operatorWorkloads(csv clusterServiceVersion, workloads []workloadObject) []models.Workload {
+	var result []models.Workload
+	for _, deployment := range csv.Spec.Install.Spec.Deployments {
+		result = append(result, models.Workload{
+			Kind: models.DeploymentWorkload,
+			Xid:  csv.Metadata.Namespace + ":" + deployment.Name,
+		})
+	}
+
+	owned := map[string]bool{}
+	for _, crd := range csv.Spec.CustomResourceDefinitions.Owned {
+		owned[crdGroup(crd.Name)+"/"+crd.Kind] = true
+	}
+	for _, workload := range workloads {
+		for _, owner := range workload.metadata.OwnerReferences {
+			if owned[apiGroup(owner.APIVersion)+"/"+owner.Kind] {
+				result = append(result, models.Workload{
+					Kind: workload.kind,
+					Xid:  workload.metadata.Namespace + ":" + workload.metadata.Name,
+				})
+				break
+			}
+		}
+	}
+	return result
+}
+
+// crdGroup returns the group of a CRD named <plural>.<group>
+func crdGroup(name string) string {
+	if i := strings.Index(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// apiGroup returns the group of an apiVersion <group>/<version>, the core group is empty
+func apiGroup(apiVersion string) string {
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		return apiVersion[:i]
+	}
+	return ""
+}
+
+func listOLMResources(resource string, items interface{}) error {
+	data, err := Kubeclient.Discovery().RESTClient().Get().AbsPath(olmAPIPath + resource).DoRaw()
+	if err != nil {
+		return err
+	}
+	return decodeItems(data, items)
+}
+
+func listWorkloadObjects() ([]workloadObject, error) {
+	var workloads []workloadObject
+	paths := []struct {
+		kind string
+		path string
+	}{
+		{models.DeploymentWorkload, "/apis/apps/v1beta1/deployments"},
+		{models.StatefulSetWorkload, "/apis/apps/v1beta1/statefulsets"},
+		{models.DaemonSetWorkload, "/apis/extensions/v1beta1/daemonsets"},
+	}
+	for _, p := range paths {
+		data, err := Kubeclient.Discovery().RESTClient().Get().AbsPath(p.path).DoRaw()
+		if err != nil {
+			return nil, err
+		}
+		objects := []struct {
+			Metadata olmMetadata `json:"metadata"`
+		}{}
+		if err = decodeItems(data, &objects); err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			workloads = append(workloads, workloadObject{kind: p.kind, metadata: object.Metadata})
+		}
+	}
+	return workloads, nil
+}
+
+func decodeItems(data []byte, items interface{}) error {
+	list := struct {
+		Items interface{} `json:"items"`
+	}{Items: items}
+	return json.Unmarshal(data, &list)
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestOperatorWorkloads(t *testing.T) {
+	csv := clusterServiceVersion{}
+	err := json.Unmarshal([]byte(`{
+		"metadata": {"name": "etcdoperator.v0.9.4", "namespace": "operators"},
+		"spec": {
+			"install": {"spec": {"deployments": [{"name": "etcd-operator"}]}},
+			"customresourcedefinitions": {"owned": [{"name": "etcdclusters.etcd.database.coreos.com", "kind": "EtcdCluster"}]}
+		}
+	}`), &csv)
+	utils.Ok(t, err)
+
+	owned := workloadObject{kind: models.StatefulSetWorkload, metadata: olmMetadata{
+		Name:            "orders",
+		Namespace:       "db",
+		OwnerReferences: []ownerReference{{APIVersion: "etcd.database.coreos.com/v1beta2", Kind: "EtcdCluster"}},
+	}}
+	other := workloadObject{kind: models.DeploymentWorkload, metadata: olmMetadata{
+		Name:            "web",
+		Namespace:       "db",
+		OwnerReferences: []ownerReference{{APIVersion: "other.example.com/v1", Kind: "EtcdCluster"}},
+	}}
+
+	expected := []models.Workload{
+		{Kind: models.DeploymentWorkload, Xid: "operators:etcd-operator"},
+		{Kind: models.StatefulSetWorkload, Xid: "db:orders"},
+	}
+	utils.Equals(t, expected, operatorWorkloads(csv, []workloadObject{owned, other}))
+}
+
+func TestAPIGroup(t *testing.T) {
+	utils.Equals(t, "apps", apiGroup("apps/v1"))
+	utils.Equals(t, "", apiGroup("v1"))
+	utils.Equals(t, "etcd.database.coreos.com", crdGroup("etcdclusters.etcd.database.coreos.com"))
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Resources to which an upfront cost can be charged
+const (
+	AmortizeCompute = "compute"
+	AmortizeCPU     = "cpu"
+	AmortizeMemory  = "memory"
+	AmortizeGPU     = "gpu"
+)
+
+// UpfrontCost is a payment made in advance for a term (reserved instances, savings plans, license fees), which is
+// amortized evenly over the hours of its term. Start is the first day of the term (format: 2006-01-02). Resource
+// tells whose cost the amortized part is allocated in proportion of: compute (default), cpu, memory or gpu.
+type UpfrontCost struct {
+	Name       string  `json:"name"`
+	Amount     float64 `json:"amount"`
+	Start      string  `json:"start"`
+	TermMonths int     `json:"termMonths"`
+	Resource   string  `json:"resource,omitempty"`
+
+	start, end time.Time
+}
+
+var upfrontCosts []UpfrontCost
+
+// GetUpfrontCosts returns the configured upfront costs
+func GetUpfrontCosts() []UpfrontCost {
+	mu.RLock()
+	defer mu.RUnlock()
+	return upfrontCosts
+}
+
+// End returns the end of the term of the upfront cost
+func (u UpfrontCost) End() time.Time {
+	return u.end
+}
+
+// HourlyCost returns the amortized part of the upfront cost for one hour of its term
+func (u UpfrontCost) HourlyCost() float64 {
+	return u.Amount / u.end.Sub(u.start).Hours()
+}
+
+// AmortizedCost returns the amortized part of the upfront cost for the part of its term inside the window [from, to)
+func (u UpfrontCost) AmortizedCost(from, to time.Time) float64 {
+	if from.Before(u.start) {
+		from = u.start
+	}
+	if to.After(u.end) {
+		to = u.end
+	}
+	if !from.Before(to) {
+		return 0
+	}
+	return u.HourlyCost() * to.Sub(from).Hours()
+}
+
+// parseUpfrontCosts returns the valid upfront costs of the settings, the invalid ones are logged and ignored
+func parseUpfrontCosts(configured []UpfrontCost) []UpfrontCost {
+	valid := []UpfrontCost{}
+	for _, u := range configured {
+		if err := u.parse(); err != nil {
+			log.Errorf("ignoring upfront cost %s: (%v)", u.Name, err)
+			continue
+		}
+		valid = append(valid, u)
+	}
+	return valid
+}
+
+func (u *UpfrontCost) parse() error {
+	start, err := time.ParseInLocation("2006-01-02", u.Start, time.Local)
+	if err != nil {
+		return err
+	}
+	if u.Amount < 0 || u.TermMonths <= 0 {
+		return fmt.Errorf("amount must not be negative and term must be at least one month")
+	}
+	switch u.Resource {
+	case "":
+		u.Resource = AmortizeCompute
+	case AmortizeCompute, AmortizeCPU, AmortizeMemory, AmortizeGPU:
+	default:
+		return fmt.Errorf("unknown resource: %s", u.Resource)
+	}
+	u.start, u.end = start, start.AddDate(0, u.TermMonths, 0)
+	return nil
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The AWS Pricing API is served from us-east-1 for all the regions
+const (
+	awsPricingEndpoint = "https://api.pricing.us-east-1.amazonaws.com/"
+	awsPricingRegion   = "us-east-1"
+	awsPricingService  = "pricing"
+)
+
+// awsPricer fetches the on-demand price of EC2 instances from the AWS Pricing API (GetProducts), and their spot price
+// from the spot price history of EC2 (DescribeSpotPriceHistory) in their region
+type awsPricer struct {
+	endpoint    string
+	ec2Endpoint string
+}
+
+// awsSpotPriceHistory is the response of DescribeSpotPriceHistory
+type awsSpotPriceHistory struct {
+	Items []struct {
+		AvailabilityZone string `xml:"availabilityZone"`
+		SpotPrice        string `xml:"spotPrice"`
+		Timestamp        string `xml:"timestamp"`
+	} `xml:"spotPriceHistorySet>item"`
+}
+
+type awsFilter struct {
+	Type  string `json:"Type"`
+	Field string `json:"Field"`
+	Value string `json:"Value"`
+}
+
+// awsProduct is the part of a price list item of GetProducts giving the on-demand price per hour
+type awsProduct struct {
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+// FetchInstancePrice returns the on-demand price per hour in USD of the shared tenancy instance type without
+// pre-installed software, or its spot price for a spot instance
+func (p *awsPricer) FetchInstancePrice(instance Instance) (float64, error) {
+	if instance.Spot {
+		return p.fetchSpotPrice(instance)
+	}
+	operatingSystem := "Linux"
+	if instance.OS == "windows" {
+		operatingSystem = "Windows"
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"ServiceCode": "AmazonEC2",
+		"Filters": []awsFilter{
+			{Type: "TERM_MATCH", Field: "instanceType", Value: instance.InstanceType},
+			{Type: "TERM_MATCH", Field: "regionCode", Value: instance.Region},
+			{Type: "TERM_MATCH", Field: "operatingSystem", Value: operatingSystem},
+			{Type: "TERM_MATCH", Field: "tenancy", Value: "Shared"},
+			{Type: "TERM_MATCH", Field: "preInstalledSw", Value: "NA"},
+			{Type: "TERM_MATCH", Field: "capacitystatus", Value: "Used"},
+			{Type: "TERM_MATCH", Field: "licenseModel", Value: "No License required"},
+		},
+		"FormatVersion": "aws_v1",
+		"MaxResults":    10,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	endpoint := p.endpoint
+	if endpoint == "" {
+		endpoint = awsPricingEndpoint
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AWSPriceListService.GetProducts")
+	if err = signAWSRequest(req, body, awsPricingRegion, awsPricingService, time.Now()); err != nil {
+		return 0, err
+	}
+
+	response := struct {
+		PriceList []string `json:"PriceList"`
+	}{}
+	if err = getJSON(req, &response); err != nil {
+		return 0, err
+	}
+	for _, item := range response.PriceList {
+		product := awsProduct{}
+		if err = json.Unmarshal([]byte(item), &product); err != nil {
+			return 0, err
+		}
+		for _, term := range product.Terms.OnDemand {
+			for _, dimension := range term.PriceDimensions {
+				if dimension.Unit != "Hrs" {
+					continue
+				}
+				price, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+				if err == nil && price > 0 {
+					return price, nil
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+// fetchSpotPrice returns the current spot price of the instance type in the zone of the instance, the average of the
+// zones of its region when its zone is unknown
+func (p *awsPricer) fetchSpotPrice(instance Instance) (float64, error) {
+	productDescription := "Linux/UNIX"
+	if instance.OS == "windows" {
+		productDescription = "Windows"
+	}
+	query := url.Values{
+		"Action":               {"DescribeSpotPriceHistory"},
+		"Version":              {"2016-11-15"},
+		"InstanceType.1":       {instance.InstanceType},
+		"ProductDescription.1": {productDescription},
+		// the latest price of every zone is the one in effect at the start time
+		"StartTime": {time.Now().UTC().Format(time.RFC3339)},
+	}
+	if instance.Zone != "" {
+		query.Set("AvailabilityZone", instance.Zone)
+	}
+	endpoint := p.ec2Endpoint
+	if endpoint == "" {
+		endpoint = "https://ec2." + instance.Region + ".amazonaws.com/"
+	}
+	// the query is encoded with sorted keys as required by the canonical request of the signature
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if err = signAWSRequest(req, nil, instance.Region, "ec2", time.Now()); err != nil {
+		return 0, err
+	}
+
+	history := awsSpotPriceHistory{}
+	if err = getXML(req, &history); err != nil {
+		return 0, err
+	}
+	latest := map[string]string{}
+	prices := map[string]float64{}
+	for _, item := range history.Items {
+		price, err := strconv.ParseFloat(item.SpotPrice, 64)
+		if err != nil || price <= 0 || item.Timestamp < latest[item.AvailabilityZone] {
+			continue
+		}
+		latest[item.AvailabilityZone] = item.Timestamp
+		prices[item.AvailabilityZone] = price
+	}
+	if len(prices) == 0 {
+		return 0, nil
+	}
+	total := 0.0
+	for _, price := range prices {
+		total += price
+	}
+	return total / float64(len(prices)), nil
+}
+
+// signAWSRequest signs the request to the service of the region with AWS signature version 4 and the credentials of
+// the environment
+func signAWSRequest(req *http.Request, body []byte, region, service string, now time.Time) error {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required by the AWS pricing apis")
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	// the headers are signed in alphabetical order, the optional ones only when they are set
+	var signedHeaders []string
+	canonicalHeaders := ""
+	for _, name := range []string{"content-type", "host", "x-amz-date", "x-amz-security-token", "x-amz-target"} {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		if value == "" {
+			continue
+		}
+		signedHeaders = append(signedHeaders, name)
+		canonicalHeaders += name + ":" + value + "\n"
+	}
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := req.Method + "\n" + path + "\n" + req.URL.RawQuery + "\n" + canonicalHeaders + "\n" +
+		strings.Join(signedHeaders, ";") + "\n" + sha256Hex(body)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+", SignedHeaders="+
+		strings.Join(signedHeaders, ";")+", Signature="+signature)
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
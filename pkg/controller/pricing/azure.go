@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const azureRetailPricesURL = "https://prices.azure.com/api/retail/prices"
+
+// azurePricer fetches the pay as you go price of virtual machines from the Azure Retail Prices API, which requires no
+// authentication
+type azurePricer struct {
+	endpoint string
+}
+
+type azurePrices struct {
+	Items []struct {
+		RetailPrice   float64 `json:"retailPrice"`
+		UnitOfMeasure string  `json:"unitOfMeasure"`
+		SkuName       string  `json:"skuName"`
+		ProductName   string  `json:"productName"`
+	} `json:"Items"`
+	NextPageLink string `json:"NextPageLink"`
+}
+
+// FetchInstancePrice returns the pay as you go price per hour in USD of the vm size (ex: Standard_D2s_v3), or its spot
+// price for a spot instance. Low priority vms are excluded.
+func (p *azurePricer) FetchInstancePrice(instance Instance) (float64, error) {
+	endpoint := p.endpoint
+	if endpoint == "" {
+		endpoint = azureRetailPricesURL
+	}
+	filter := "serviceName eq 'Virtual Machines' and priceType eq 'Consumption' and armRegionName eq '" +
+		instance.Region + "' and armSkuName eq '" + instance.InstanceType + "'"
+	next := endpoint + "?$filter=" + url.QueryEscape(filter)
+	for next != "" {
+		req, err := http.NewRequest(http.MethodGet, next, nil)
+		if err != nil {
+			return 0, err
+		}
+		prices := azurePrices{}
+		if err = getJSON(req, &prices); err != nil {
+			return 0, err
+		}
+		for _, item := range prices.Items {
+			if item.UnitOfMeasure != "1 Hour" || strings.Contains(item.SkuName, "Spot") != instance.Spot ||
+				strings.Contains(item.SkuName, "Low Priority") {
+				continue
+			}
+			if strings.Contains(item.ProductName, "Windows") == (instance.OS == "windows") {
+				return item.RetailPrice, nil
+			}
+		}
+		next = prices.NextPageLink
+	}
+	return 0, nil
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	defaultCacheFile    = "/tmp/purser-pricing-catalog.json"
+	defaultSyncInterval = "24h"
+	httpTimeout         = 30 * time.Second
+)
+
+var (
+	mu        sync.RWMutex
+	current   *Catalog
+	provider  Provider
+	cacheFile = defaultCacheFile
+)
+
+// httpProvider fetches the catalog in json format from an http endpoint.
+type httpProvider struct {
+	url string
+}
+
+// Name returns the provider name
+func (p *httpProvider) Name() string {
+	return "http"
+}
+
+// FetchCatalog downloads the catalog from the provider url
+func (p *httpProvider) FetchCatalog() (*Catalog, error) {
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Get(p.url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pricing catalog request failed with status: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	catalog := &Catalog{}
+	if err = json.Unmarshal(data, catalog); err != nil {
+		return nil, err
+	}
+	if catalog.Version == "" {
+		catalog.Version = fmt.Sprintf("%x", sha256.Sum256(data))[:12]
+	}
+	// catalogs without gpu, snapshot or load balancer price keep the default ones
+	applyDefaultPrices(catalog)
+	return catalog, nil
+}
+
+// Setup configures the catalog provider and cache file and loads the cached catalog if present.
+// It returns the interval at which the catalog should be refreshed.
+func Setup(settings Settings) string {
+	mu.Lock()
+	if settings.CacheFile != "" {
+		cacheFile = settings.CacheFile
+	}
+	if settings.CatalogURL != "" {
+		provider = &httpProvider{url: settings.CatalogURL}
+	}
+	networkPrices = settings.Network
+	upfrontCosts = parseUpfrontCosts(settings.Amortization)
+	discounts = parseDiscounts(settings.Discounts)
+	setupOnDemand(settings.OnDemand)
+	setupSpot(settings.Spot)
+	mu.Unlock()
+
+	loadCache()
+	if settings.SyncInterval == "" {
+		return defaultSyncInterval
+	}
+	return settings.SyncInterval
+}
+
+// SetProvider sets the provider from which the catalog is refreshed.
+func SetProvider(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	provider = p
+}
+
+// Sync refreshes the pricing catalog from the provider and persists it in the cache file, the price changes are
+// persisted in the history store too.
+// If the provider is unreachable the cached catalog continues to serve the prices.
+func Sync() {
+	mu.RLock()
+	p := provider
+	mu.RUnlock()
+	if p == nil {
+		log.Debug("no pricing provider is configured, skipping catalog sync")
+		return
+	}
+
+	catalog, err := p.FetchCatalog()
+	if err != nil {
+		log.Errorf("unable to fetch pricing catalog from provider: (%s), serving prices from cache, error: (%v)", p.Name(), err)
+		markOffline()
+		return
+	}
+	if catalog.Provider == "" {
+		catalog.Provider = p.Name()
+	}
+	syncTime := time.Now()
+	catalog.SyncTime = syncTime.Format(time.RFC3339)
+
+	mu.Lock()
+	current = catalog
+	added := recordPriceChange(catalog, syncTime)
+	mu.Unlock()
+	log.Infof("pricing catalog synced, provider: (%s), version: (%s)", catalog.Provider, catalog.Version)
+	storePricePeriods(added)
+	saveCache()
+}
+
+// GetCatalog returns the current pricing catalog, with the configured discounts applied to its list prices.
+// Default prices are returned if no catalog is available.
+func GetCatalog() Catalog {
+	mu.RLock()
+	defer mu.RUnlock()
+	catalog := listCatalog()
+	applyDiscounts(&catalog, discounts)
+	return catalog
+}
+
+// listCatalog returns a copy of the current catalog with its list prices. mu must be held by the caller.
+func listCatalog() Catalog {
+	if current == nil {
+		return Catalog{
+			Provider:     defaultProvider,
+			Version:      defaultProvider,
+			CPU:          DefaultCPUCostPerCPUPerHour,
+			Memory:       DefaultMemCostPerGBPerHour,
+			Storage:      DefaultStorageCostPerGBPerHour,
+			GPU:          DefaultGPUCostPerGPUPerHour,
+			Snapshot:     DefaultSnapshotCostPerGBPerHour,
+			LoadBalancer: DefaultLoadBalancerCostPerHour,
+		}
+	}
+	catalog := *current
+	catalog.InstanceTypes = append([]InstanceType(nil), current.InstanceTypes...)
+	return catalog
+}
+
+// IsDefault returns true if no catalog was synced or cached, so the built-in default prices are in use.
+func IsDefault() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current == nil
+}
+
+func applyDefaultPrices(catalog *Catalog) {
+	if catalog.GPU == 0 {
+		catalog.GPU = DefaultGPUCostPerGPUPerHour
+	}
+	if catalog.Snapshot == 0 {
+		catalog.Snapshot = DefaultSnapshotCostPerGBPerHour
+	}
+	if catalog.LoadBalancer == 0 {
+		catalog.LoadBalancer = DefaultLoadBalancerCostPerHour
+	}
+}
+
+func markOffline() {
+	mu.Lock()
+	defer mu.Unlock()
+	if current != nil {
+		current.Offline = true
+	}
+}
+
+// cache is the format of the cache file
+type cache struct {
+	Catalog *Catalog      `json:"catalog"`
+	History []PricePeriod `json:"history,omitempty"`
+}
+
+func loadCache() {
+	mu.RLock()
+	path := cacheFile
+	mu.RUnlock()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Debugf("no cached pricing catalog at: (%s)", path)
+		return
+	}
+	cached := cache{}
+	if err = json.Unmarshal(data, &cached); err != nil || cached.Catalog == nil {
+		log.Errorf("unable to parse cached pricing catalog: (%v)", err)
+		return
+	}
+	// catalogs cached before gpus, snapshots and load balancers were priced have no price for them
+	applyDefaultPrices(cached.Catalog)
+	for i := range cached.History {
+		if cached.History[i].GPU == 0 {
+			cached.History[i].GPU = DefaultGPUCostPerGPUPerHour
+		}
+		if cached.History[i].Snapshot == 0 {
+			cached.History[i].Snapshot = DefaultSnapshotCostPerGBPerHour
+		}
+		if cached.History[i].LoadBalancer == 0 {
+			cached.History[i].LoadBalancer = DefaultLoadBalancerCostPerHour
+		}
+	}
+	mu.Lock()
+	current = cached.Catalog
+	history = cached.History
+	mu.Unlock()
+	log.Infof("loaded cached pricing catalog, version: (%s), synced at: (%s)", cached.Catalog.Version, cached.Catalog.SyncTime)
+}
+
+func saveCache() {
+	mu.RLock()
+	path := cacheFile
+	data, err := json.Marshal(cache{Catalog: current, History: history})
+	mu.RUnlock()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		log.Errorf("unable to persist pricing catalog at: (%s), error: (%v)", path, err)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pricing
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+type staticProvider struct {
+	catalog *Catalog
+	err     error
+}
+
+func (p *staticProvider) Name() string {
+	return "static"
+}
+
+func (p *staticProvider) FetchCatalog() (*Catalog, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	catalog := *p.catalog
+	return &catalog, nil
+}
+
+func TestHTTPProviderFetchCatalog(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, err := w.Write([]byte(`{"cpuCostPerCPUPerHour": 0.03, "memCostPerGBPerHour": 0.004}`))
+		utils.Ok(t, err)
+	}))
+	defer server.Close()
+	p := &httpProvider{url: server.URL}
+
+	catalog, err := p.FetchCatalog()
+	utils.Ok(t, err)
+	utils.Equals(t, 0.03, catalog.CPU)
+	// catalogs without version are versioned by their content, and without gpu price keep the default one
+	utils.Equals(t, 12, len(catalog.Version))
+	utils.Equals(t, DefaultGPUCostPerGPUPerHour, catalog.GPU)
+	again, err := p.FetchCatalog()
+	utils.Ok(t, err)
+	utils.Equals(t, catalog.Version, again.Version)
+
+	status = http.StatusServiceUnavailable
+	_, err = p.FetchCatalog()
+	utils.Assert(t, err != nil, "catalog fetched from an unavailable provider")
+}
+
+func TestSyncServesCachedCatalogOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purser-pricing")
+	utils.Ok(t, err)
+	defer os.RemoveAll(dir)
+	defer func() {
+		current, provider, history, cacheFile = nil, nil, nil, defaultCacheFile
+	}()
+	cacheFile = filepath.Join(dir, "catalog.json")
+
+	// nothing is synced without provider
+	Sync()
+	utils.Assert(t, IsDefault(), "catalog synced without provider")
+
+	synced := &staticProvider{catalog: &Catalog{Version: "v1", CPU: 0.03, Memory: 0.004, Storage: 0.0001}}
+	SetProvider(synced)
+	Sync()
+	catalog := GetCatalog()
+	utils.Equals(t, "static", catalog.Provider)
+	utils.Equals(t, "v1", catalog.Version)
+	utils.Assert(t, !catalog.Offline && catalog.SyncTime != "", "synced catalog: %v", catalog)
+
+	// the catalog of the unreachable provider keeps serving its prices
+	SetProvider(&staticProvider{err: errors.New("unreachable")})
+	Sync()
+	catalog = GetCatalog()
+	utils.Equals(t, "v1", catalog.Version)
+	utils.Equals(t, 0.03, catalog.CPU)
+	utils.Assert(t, catalog.Offline, "catalog of the unreachable provider is not offline")
+
+	// the synced catalog and its history are loaded from the cache file after a restart
+	current, history = nil, nil
+	loadCache()
+	utils.Assert(t, !IsDefault(), "cached catalog is not loaded")
+	catalog = GetCatalog()
+	utils.Equals(t, "v1", catalog.Version)
+	utils.Equals(t, 0.03, catalog.CPU)
+	utils.Equals(t, DefaultLoadBalancerCostPerHour, catalog.LoadBalancer)
+	utils.Equals(t, 2, len(GetPriceHistory()))
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Services of the catalog prices which can be discounted
+const (
+	ComputeService      = "compute"
+	CPUService          = "cpu"
+	MemoryService       = "memory"
+	GPUService          = "gpu"
+	StorageService      = "storage"
+	SnapshotService     = "snapshot"
+	LoadBalancerService = "loadBalancer"
+	NetworkService      = "network"
+)
+
+// Discount is a negotiated percentage off the list prices of a provider. An empty region, service or family matches
+// all of them. Family is an instance type family (ex: m5 for m5.xlarge) and only discounts instance types, compute
+// discounts the cpu, memory and gpu prices as well as the instance types. When several discounts match a price, the
+// most specific one applies (family, then service, then region), discounts are not stacked.
+type Discount struct {
+	Provider string  `json:"provider"`
+	Region   string  `json:"region,omitempty"`
+	Service  string  `json:"service,omitempty"`
+	Family   string  `json:"family,omitempty"`
+	Percent  float64 `json:"percent"`
+}
+
+var discounts []Discount
+
+// parseDiscounts returns the valid discounts of the settings, the invalid ones are logged and ignored
+func parseDiscounts(configured []Discount) []Discount {
+	valid := []Discount{}
+	for _, d := range configured {
+		if d.Provider == "" || d.Percent < 0 || d.Percent > 100 {
+			log.Errorf("ignoring discount of provider: (%s), service: (%s), percent must be between 0 and 100", d.Provider, d.Service)
+			continue
+		}
+		valid = append(valid, d)
+	}
+	return valid
+}
+
+// discountFactor returns the part of the list price paid for a price of the service (and instance family for
+// instance types) given by the most specific matching discount, 1 if no discount matches
+func discountFactor(discounts []Discount, provider, region, service, family string) float64 {
+	factor, specificity := 1.0, -1
+	for _, d := range discounts {
+		if d.Provider != provider || (d.Region != "" && d.Region != region) || (d.Family != "" && d.Family != family) ||
+			(d.Service != "" && d.Service != service && !(d.Service == ComputeService && isComputeService(service))) {
+			continue
+		}
+		s := 0
+		if d.Family != "" {
+			s += 8
+		}
+		if d.Service == ComputeService {
+			s += 2
+		} else if d.Service != "" {
+			s += 4
+		}
+		if d.Region != "" {
+			s++
+		}
+		if s > specificity {
+			factor, specificity = 1-d.Percent/100, s
+		}
+	}
+	return factor
+}
+
+func isComputeService(service string) bool {
+	return service == CPUService || service == MemoryService || service == GPUService || service == ComputeService
+}
+
+// instanceFamily returns the family of an instance type, the part of its name before the size (ex: m5 for m5.xlarge)
+func instanceFamily(instanceType string) string {
+	return strings.SplitN(instanceType, ".", 2)[0]
+}
+
+// applyDiscounts discounts the prices of the catalog, whose instance types must not be shared with the cached catalog
+func applyDiscounts(catalog *Catalog, discounts []Discount) {
+	if len(discounts) == 0 {
+		return
+	}
+	factor := func(service string) float64 {
+		return discountFactor(discounts, catalog.Provider, catalog.Region, service, "")
+	}
+	catalog.CPU *= factor(CPUService)
+	catalog.Memory *= factor(MemoryService)
+	catalog.GPU *= factor(GPUService)
+	catalog.Storage *= factor(StorageService)
+	catalog.Snapshot *= factor(SnapshotService)
+	catalog.LoadBalancer *= factor(LoadBalancerService)
+	for i := range catalog.InstanceTypes {
+		family := instanceFamily(catalog.InstanceTypes[i].Name)
+		catalog.InstanceTypes[i].PricePerHour *= discountFactor(discounts, catalog.Provider, catalog.Region, ComputeService, family)
+	}
+	catalog.Discounted = true
+}
+
+// recordDiscountFactors records in the period the discount factors of the discounts of the provider and region
+func recordDiscountFactors(period *PricePeriod, discounts []Discount, provider, region string) {
+	factor := func(service string) float64 {
+		return discountFactor(discounts, provider, region, service, "")
+	}
+	period.CPUDiscount = factor(CPUService)
+	period.MemoryDiscount = factor(MemoryService)
+	period.GPUDiscount = factor(GPUService)
+	period.StorageDiscount = factor(StorageService)
+	period.SnapshotDiscount = factor(SnapshotService)
+	period.LoadBalancerDiscount = factor(LoadBalancerService)
+	period.DiscountsRecorded = true
+}
+
+// isSameDiscount returns true if the periods have the same discount factors, periods recorded before the discount
+// factors were recorded have the same discounts as any period
+func isSameDiscount(period, other PricePeriod) bool {
+	if !period.DiscountsRecorded || !other.DiscountsRecorded {
+		return true
+	}
+	return period.CPUDiscount == other.CPUDiscount && period.MemoryDiscount == other.MemoryDiscount &&
+		period.GPUDiscount == other.GPUDiscount && period.StorageDiscount == other.StorageDiscount &&
+		period.SnapshotDiscount == other.SnapshotDiscount && period.LoadBalancerDiscount == other.LoadBalancerDiscount
+}
+
+// applyPeriodDiscounts discounts the prices of a price period with the discount factors recorded in it, so that a
+// change of the discounts does not change the prices of the past periods
+func applyPeriodDiscounts(period *PricePeriod) {
+	period.CPU *= period.CPUDiscount
+	period.Memory *= period.MemoryDiscount
+	period.GPU *= period.GPUDiscount
+	period.Storage *= period.StorageDiscount
+	period.Snapshot *= period.SnapshotDiscount
+	period.LoadBalancer *= period.LoadBalancerDiscount
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestDiscountFactor(t *testing.T) {
+	configured := []Discount{
+		{Provider: AWS, Percent: 10},
+		{Provider: AWS, Region: "us-east-1", Percent: 15},
+		{Provider: AWS, Service: ComputeService, Percent: 20},
+		{Provider: AWS, Region: "us-east-1", Service: ComputeService, Percent: 25},
+		{Provider: AWS, Service: StorageService, Percent: 30},
+		{Provider: AWS, Region: "us-east-1", Service: StorageService, Percent: 35},
+		{Provider: AWS, Family: "m5", Percent: 40},
+		{Provider: GCP, Region: "us-central1", Service: CPUService, Percent: 50},
+	}
+	tests := []struct {
+		name     string
+		provider string
+		region   string
+		service  string
+		family   string
+		factor   float64
+	}{
+		{"provider", AWS, "eu-west-1", LoadBalancerService, "", 0.9},
+		{"region over provider", AWS, "us-east-1", LoadBalancerService, "", 0.85},
+		{"compute over region", AWS, "us-east-1", MemoryService, "", 0.75},
+		{"compute of any region", AWS, "eu-west-1", GPUService, "", 0.8},
+		{"service over compute", AWS, "us-east-1", StorageService, "", 0.65},
+		{"service over region", AWS, "eu-west-1", StorageService, "", 0.7},
+		{"family over service and region", AWS, "us-east-1", ComputeService, "m5", 0.6},
+		{"other family", AWS, "us-east-1", ComputeService, "c5", 0.75},
+		{"other service of the region", GCP, "us-central1", MemoryService, "", 1},
+		{"other region", GCP, "europe-west1", CPUService, "", 1},
+		{"no discount of the provider", Azure, "eastus", CPUService, "", 1},
+	}
+	for _, test := range tests {
+		factor := discountFactor(configured, test.provider, test.region, test.service, test.family)
+		utils.Assert(t, factor > test.factor-1e-9 && factor < test.factor+1e-9, "%s: expected factor %f, got %f",
+			test.name, test.factor, factor)
+	}
+}
+
+func TestPriceHistoryKeepsRecordedDiscounts(t *testing.T) {
+	defer func() {
+		history, discounts, current = nil, nil, nil
+	}()
+	catalog := Catalog{Provider: AWS, Region: "us-east-1", CPU: 0.04, Memory: 0.01}
+	current = &catalog
+	syncTime := time.Date(2018, 11, 20, 10, 0, 0, 0, time.UTC)
+	history = nil
+	discounts = []Discount{{Provider: AWS, Service: CPUService, Percent: 25}}
+	recordPriceChange(&catalog, syncTime)
+
+	// a new discount is recorded as a new period effective from the next sync
+	discounts = []Discount{{Provider: AWS, Service: CPUService, Percent: 50}}
+	added := recordPriceChange(&catalog, syncTime.Add(24*time.Hour))
+	utils.Equals(t, 1, len(added))
+	utils.Equals(t, "2018-11-21T10:00:00Z", added[0].EffectiveFrom)
+	utils.Equals(t, 0, len(recordPriceChange(&catalog, syncTime.Add(48*time.Hour))))
+
+	// periods recorded before the discount factors get the configured discounts
+	history = append([]PricePeriod{{CPU: 0.08}}, history...)
+	periods := GetPriceHistory()
+	utils.Equals(t, 4, len(periods))
+	expected := []float64{0.04, 0.75 * DefaultCPUCostPerCPUPerHour, 0.03, 0.02}
+	for i, period := range periods {
+		utils.Assert(t, period.CPU > expected[i]-1e-9 && period.CPU < expected[i]+1e-9, "period %d: expected cpu price %f, got %f",
+			i, expected[i], period.CPU)
+	}
+	utils.Equals(t, 0.01, periods[3].Memory)
+}
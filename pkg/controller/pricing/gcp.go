@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// gcpComputeSkusURL lists the skus of Compute Engine in the Cloud Billing Catalog API
+const gcpComputeSkusURL = "https://cloudbilling.googleapis.com/v1/services/6F81-5844-456A/skus"
+
+// gcpPricer fetches the on-demand and spot prices of Compute Engine machine types from the Cloud Billing Catalog API. Machine
+// types are priced per cpu and GB of memory of their family (ex: n1, e2), so the price is computed from the cpus and
+// memory of the node. The license of windows nodes is not included.
+type gcpPricer struct {
+	apiKey   string
+	endpoint string
+}
+
+type gcpSkus struct {
+	Skus []struct {
+		Description string `json:"description"`
+		Category    struct {
+			ResourceFamily string `json:"resourceFamily"`
+			ResourceGroup  string `json:"resourceGroup"`
+			UsageType      string `json:"usageType"`
+		} `json:"category"`
+		ServiceRegions []string `json:"serviceRegions"`
+		PricingInfo    []struct {
+			PricingExpression struct {
+				TieredRates []struct {
+					UnitPrice struct {
+						Units string `json:"units"`
+						Nanos int64  `json:"nanos"`
+					} `json:"unitPrice"`
+				} `json:"tieredRates"`
+			} `json:"pricingExpression"`
+		} `json:"pricingInfo"`
+	} `json:"skus"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// FetchInstancePrice returns the on-demand price per hour in USD of the cpus and memory of the machine type, or their
+// spot price for a spot (preemptible) instance
+func (p *gcpPricer) FetchInstancePrice(instance Instance) (float64, error) {
+	family := strings.ToUpper(strings.SplitN(instance.InstanceType, "-", 2)[0])
+	usageType := "OnDemand"
+	if instance.Spot {
+		usageType = "Preemptible"
+	}
+	endpoint := p.endpoint
+	if endpoint == "" {
+		endpoint = gcpComputeSkusURL
+	}
+
+	var cpuPrice, memoryPrice float64
+	pageToken := ""
+	for {
+		query := url.Values{"key": {p.apiKey}, "pageSize": {"5000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		req, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+		if err != nil {
+			return 0, err
+		}
+		skus := gcpSkus{}
+		if err = getJSON(req, &skus); err != nil {
+			return 0, err
+		}
+		for _, sku := range skus.Skus {
+			// spot and preemptible skus are described as the on-demand ones with a prefix
+			description := strings.TrimPrefix(strings.TrimPrefix(sku.Description, "Spot "), "Preemptible ")
+			if sku.Category.ResourceFamily != "Compute" || sku.Category.UsageType != usageType ||
+				!strings.HasPrefix(description, family+" ") || !isGCPStandardSku(description) ||
+				!containsString(sku.ServiceRegions, instance.Region) || len(sku.PricingInfo) == 0 {
+				continue
+			}
+			rates := sku.PricingInfo[0].PricingExpression.TieredRates
+			if len(rates) == 0 {
+				continue
+			}
+			price := gcpUnitPrice(rates[len(rates)-1].UnitPrice.Units, rates[len(rates)-1].UnitPrice.Nanos)
+			switch sku.Category.ResourceGroup {
+			case "CPU":
+				cpuPrice = price
+			case "RAM":
+				memoryPrice = price
+			}
+		}
+		if skus.NextPageToken == "" || (cpuPrice > 0 && memoryPrice > 0) {
+			break
+		}
+		pageToken = skus.NextPageToken
+	}
+	if cpuPrice == 0 || memoryPrice == 0 {
+		return 0, nil
+	}
+	return Catalog{CPU: cpuPrice, Memory: memoryPrice}.ListPrice(instance.CPU, instance.Memory), nil
+}
+
+// isGCPStandardSku excludes the custom, sole tenant and preemptible skus of a machine family
+func isGCPStandardSku(description string) bool {
+	for _, excluded := range []string{"Custom", "Sole Tenancy", "Preemptible", "Spot", "Commitment"} {
+		if strings.Contains(description, excluded) {
+			return false
+		}
+	}
+	return strings.Contains(description, "Instance Core") || strings.Contains(description, "Instance Ram")
+}
+
+func gcpUnitPrice(units string, nanos int64) float64 {
+	whole, err := strconv.ParseFloat(units, 64)
+	if err != nil {
+		whole = 0
+	}
+	return whole + float64(nanos)/1e9
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
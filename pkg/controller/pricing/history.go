@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"sort"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+var (
+	// history of price changes sorted by effective from time, guarded by mu
+	history      []PricePeriod
+	historyStore HistoryStore
+)
+
+// SetHistoryStore sets the store persisting the price history and loads the history from it. The stored history
+// replaces the one of the cache file, periods of the cache file missing from the store are stored.
+func SetHistoryStore(store HistoryStore) {
+	stored, err := store.LoadPriceHistory()
+	if err != nil {
+		log.Errorf("unable to load price history, error: (%v)", err)
+	}
+	mu.Lock()
+	historyStore = store
+	cached := history
+	if len(stored) > 0 {
+		history = stored
+	}
+	mu.Unlock()
+	if len(stored) == 0 {
+		storePricePeriods(cached)
+	}
+}
+
+// GetPriceHistory returns the price periods sorted by effective from time. The first period is in effect
+// for all the time before the second one. If no price change is recorded then the current catalog prices
+// are returned as the only period. The prices of every period are discounted with the discounts in effect when it
+// was recorded, the configured discounts apply to the periods recorded before the discounts were.
+func GetPriceHistory() []PricePeriod {
+	mu.RLock()
+	defer mu.RUnlock()
+	periods := make([]PricePeriod, len(history))
+	copy(periods, history)
+	catalog := listCatalog()
+	if len(periods) == 0 {
+		periods = []PricePeriod{newPricePeriod(&catalog, time.Time{})}
+	}
+	for i := range periods {
+		if !periods[i].DiscountsRecorded {
+			recordDiscountFactors(&periods[i], discounts, catalog.Provider, catalog.Region)
+		}
+		applyPeriodDiscounts(&periods[i])
+	}
+	return periods
+}
+
+// recordPriceChange adds a new price period if the prices in the catalog or the discount factors of its provider and
+// region differ from the latest period and returns the added periods. A change of the discounts alone is effective
+// from the sync time. mu must be held by the caller.
+func recordPriceChange(catalog *Catalog, syncTime time.Time) []PricePeriod {
+	effectiveFrom := syncTime
+	if catalog.EffectiveFrom != "" {
+		parsed, err := time.Parse(time.RFC3339, catalog.EffectiveFrom)
+		if err != nil {
+			log.Errorf("invalid effective from time: (%s) in pricing catalog, using sync time", catalog.EffectiveFrom)
+		} else {
+			effectiveFrom = parsed
+		}
+	}
+	period := newPricePeriod(catalog, effectiveFrom)
+	recordDiscountFactors(&period, discounts, catalog.Provider, catalog.Region)
+
+	var added []PricePeriod
+	if len(history) > 0 {
+		latest := history[len(history)-1]
+		isSamePrice := latest.CPU == catalog.CPU && latest.Memory == catalog.Memory && latest.Storage == catalog.Storage &&
+			latest.GPU == catalog.GPU && latest.Snapshot == catalog.Snapshot && latest.LoadBalancer == catalog.LoadBalancer
+		if isSamePrice && isSameDiscount(latest, period) {
+			return nil
+		}
+		if isSamePrice {
+			period.EffectiveFrom = syncTime.UTC().Format(time.RFC3339)
+		}
+	} else {
+		// prices before the first sync are the defaults
+		defaultCatalog := Catalog{CPU: DefaultCPUCostPerCPUPerHour, Memory: DefaultMemCostPerGBPerHour, Storage: DefaultStorageCostPerGBPerHour,
+			GPU: DefaultGPUCostPerGPUPerHour, Snapshot: DefaultSnapshotCostPerGBPerHour, LoadBalancer: DefaultLoadBalancerCostPerHour}
+		defaultPeriod := newPricePeriod(&defaultCatalog, time.Time{})
+		recordDiscountFactors(&defaultPeriod, discounts, catalog.Provider, catalog.Region)
+		added = append(added, defaultPeriod)
+	}
+	added = append(added, period)
+	history = append(history, added...)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].EffectiveFrom < history[j].EffectiveFrom
+	})
+	log.Infof("price change recorded, version: (%s), effective from: (%s)", catalog.Version, period.EffectiveFrom)
+	return added
+}
+
+// storePricePeriods persists the price periods in the history store if one is set
+func storePricePeriods(periods []PricePeriod) {
+	mu.RLock()
+	store := historyStore
+	mu.RUnlock()
+	if store == nil {
+		return
+	}
+	for _, period := range periods {
+		if err := store.StorePricePeriod(period); err != nil {
+			log.Errorf("unable to store price period effective from: (%s), error: (%v)", period.EffectiveFrom, err)
+		}
+	}
+}
+
+func newPricePeriod(catalog *Catalog, effectiveFrom time.Time) PricePeriod {
+	return PricePeriod{
+		EffectiveFrom: effectiveFrom.UTC().Format(time.RFC3339),
+		Version:       catalog.Version,
+		CPU:           catalog.CPU,
+		Memory:        catalog.Memory,
+		Storage:       catalog.Storage,
+		GPU:           catalog.GPU,
+		Snapshot:      catalog.Snapshot,
+		LoadBalancer:  catalog.LoadBalancer,
+	}
+}
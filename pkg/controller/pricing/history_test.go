@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pricing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+type memoryHistoryStore struct {
+	periods []PricePeriod
+}
+
+func (s *memoryHistoryStore) StorePricePeriod(period PricePeriod) error {
+	s.periods = append(s.periods, period)
+	return nil
+}
+
+func (s *memoryHistoryStore) LoadPriceHistory() ([]PricePeriod, error) {
+	return s.periods, nil
+}
+
+func TestRecordPriceChange(t *testing.T) {
+	syncTime := time.Date(2018, 11, 20, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		catalog       Catalog
+		added         int
+		effectiveFrom []string
+	}{
+		{"first sync adds the default prices before it", Catalog{Version: "v1", CPU: 0.03, Memory: 0.01},
+			2, []string{"0001-01-01T00:00:00Z", "2018-11-20T10:00:00Z"}},
+		{"same prices", Catalog{Version: "v2", CPU: 0.03, Memory: 0.01}, 0, nil},
+		{"price change effective earlier", Catalog{Version: "v3", CPU: 0.04, Memory: 0.01, EffectiveFrom: "2018-11-01T00:00:00Z"},
+			1, []string{"0001-01-01T00:00:00Z", "2018-11-01T00:00:00Z", "2018-11-20T10:00:00Z"}},
+		{"invalid effective from uses the sync time", Catalog{Version: "v4", CPU: 0.05, Memory: 0.01, EffectiveFrom: "soon"},
+			1, []string{"0001-01-01T00:00:00Z", "2018-11-01T00:00:00Z", "2018-11-20T10:00:00Z", "2018-11-20T10:00:00Z"}},
+	}
+	history = nil
+	defer func() {
+		history = nil
+	}()
+	for _, test := range tests {
+		added := recordPriceChange(&test.catalog, syncTime)
+		utils.Assert(t, len(added) == test.added, "%s: added periods %v", test.name, added)
+		if test.added == 0 {
+			continue
+		}
+		utils.Equals(t, test.catalog.Version, added[len(added)-1].Version)
+		utils.Equals(t, test.catalog.CPU, added[len(added)-1].CPU)
+		utils.Equals(t, len(test.effectiveFrom), len(history))
+		for i, period := range history {
+			utils.Assert(t, period.EffectiveFrom == test.effectiveFrom[i], "%s: history %v", test.name, history)
+		}
+	}
+	utils.Equals(t, DefaultCPUCostPerCPUPerHour, history[0].CPU)
+}
+
+func TestSetHistoryStore(t *testing.T) {
+	defer func() {
+		history, historyStore = nil, nil
+	}()
+	cached := []PricePeriod{{CPU: 0.02}, {EffectiveFrom: "2018-11-01T00:00:00Z", CPU: 0.03}}
+
+	// the history of the cache file is stored in an empty store
+	history = cached
+	empty := &memoryHistoryStore{}
+	SetHistoryStore(empty)
+	utils.Equals(t, cached, empty.periods)
+	utils.Equals(t, cached, history)
+
+	// the stored history replaces the one of the cache file
+	stored := &memoryHistoryStore{periods: []PricePeriod{{CPU: 0.02}, {EffectiveFrom: "2018-10-01T00:00:00Z", CPU: 0.04}}}
+	SetHistoryStore(stored)
+	utils.Equals(t, stored.periods, history)
+
+	// price changes are stored
+	added := recordPriceChange(&Catalog{Version: "v2", CPU: 0.05, EffectiveFrom: "2018-11-15T00:00:00Z"}, time.Now())
+	storePricePeriods(added)
+	utils.Equals(t, 3, len(stored.periods))
+	utils.Equals(t, 0.05, stored.periods[2].CPU)
+}
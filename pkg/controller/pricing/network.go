@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+// Default network prices which are used when no prices are configured for the provider and region of the catalog.
+// NOTE: network prices are per GB. NAT gateway processing is only charged when it is configured.
+const (
+	DefaultInternetEgressCostPerGB = 0.09
+	DefaultInterRegionCostPerGB    = 0.02
+	DefaultInterZoneCostPerGB      = 0.01
+)
+
+// NetworkPrices are the prices of the traffic leaving a zone, configured per provider and region. Prices with an
+// empty region apply to the regions of the provider which have no prices of their own. NAT gateway processing is
+// charged on top of internet egress, for clusters whose nodes reach the internet through a NAT gateway.
+// NOTE: network prices are per GB
+type NetworkPrices struct {
+	Provider       string  `json:"provider"`
+	Region         string  `json:"region,omitempty"`
+	InternetEgress float64 `json:"internetEgressCostPerGB"`
+	InterRegion    float64 `json:"interRegionCostPerGB"`
+	InterZone      float64 `json:"interZoneCostPerGB"`
+	NATGateway     float64 `json:"natGatewayCostPerGB"`
+}
+
+var networkPrices []NetworkPrices
+
+// GetNetworkPrices returns the network prices of the provider and region of the current catalog, with the network
+// discount applied
+func GetNetworkPrices() NetworkPrices {
+	catalog := GetCatalog()
+	mu.RLock()
+	defer mu.RUnlock()
+	prices := matchNetworkPrices(networkPrices, catalog.Provider, catalog.Region)
+	factor := discountFactor(discounts, catalog.Provider, catalog.Region, NetworkService, "")
+	prices.InternetEgress *= factor
+	prices.InterRegion *= factor
+	prices.InterZone *= factor
+	prices.NATGateway *= factor
+	return prices
+}
+
+// matchNetworkPrices returns the prices of the region, else the prices of the provider, else the default prices
+func matchNetworkPrices(prices []NetworkPrices, provider, region string) NetworkPrices {
+	match := NetworkPrices{
+		Provider:       provider,
+		Region:         region,
+		InternetEgress: DefaultInternetEgressCostPerGB,
+		InterRegion:    DefaultInterRegionCostPerGB,
+		InterZone:      DefaultInterZoneCostPerGB,
+	}
+	for _, p := range prices {
+		if p.Provider != provider {
+			continue
+		}
+		if p.Region == region {
+			return p
+		}
+		if p.Region == "" {
+			match = p
+			match.Region = region
+		}
+	}
+	return match
+}
@@ -0,0 +1,220 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Cloud providers whose pricing apis give the on-demand price of the nodes
+const (
+	AWS   = "aws"
+	GCP   = "gcp"
+	Azure = "azure"
+)
+
+const defaultOnDemandRefreshInterval = 7 * 24 * time.Hour
+
+// OnDemandSettings configures the on-demand prices of the nodes, fetched from the pricing api of their cloud provider
+// with their instance type, region and OS. Prices are cached in Dgraph and fetched again after the RefreshInterval
+// (default: 168h). AWS credentials are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+// environment variables, the GCP catalog api requires an api key.
+type OnDemandSettings struct {
+	Enabled         bool   `json:"enabled,omitempty"`
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+	GCPAPIKey       string `json:"gcpAPIKey,omitempty"`
+}
+
+// Instance is the node whose on-demand price is fetched. CPU is in cpus and Memory in GB, they price the instance
+// types priced per cpu and GB (GCP). OS is the kubernetes os of the node (linux, windows). With Spot, the current
+// spot price of the instance type is fetched instead, in the Zone of the node when the provider prices per zone (AWS).
+type Instance struct {
+	Provider     string
+	Region       string
+	Zone         string
+	InstanceType string
+	OS           string
+	CPU          utils.Cores
+	Memory       utils.GB
+	Spot         bool
+}
+
+// InstancePricer fetches the on-demand or spot price per hour of an instance from the pricing api of a cloud provider
+type InstancePricer interface {
+	FetchInstancePrice(instance Instance) (float64, error)
+}
+
+var (
+	onDemand        OnDemandSettings
+	refreshInterval = defaultOnDemandRefreshInterval
+	instancePricers = map[string]InstancePricer{}
+)
+
+// setupOnDemand registers the pricers of the cloud providers when on-demand pricing is enabled. mu must be held by
+// the caller.
+func setupOnDemand(settings OnDemandSettings) {
+	onDemand = settings
+	if !settings.Enabled {
+		return
+	}
+	if settings.RefreshInterval != "" {
+		interval, err := time.ParseDuration(settings.RefreshInterval)
+		if err != nil {
+			log.Errorf("invalid refresh interval of on-demand prices: (%s), using %v", settings.RefreshInterval, defaultOnDemandRefreshInterval)
+		} else {
+			refreshInterval = interval
+		}
+	}
+	registerInstancePricers()
+}
+
+// registerInstancePricers registers the pricers of the cloud providers, gcp requires the api key of the on-demand
+// settings. mu must be held by the caller.
+func registerInstancePricers() {
+	instancePricers[AWS] = &awsPricer{}
+	instancePricers[Azure] = &azurePricer{}
+	if onDemand.GCPAPIKey != "" {
+		instancePricers[GCP] = &gcpPricer{apiKey: onDemand.GCPAPIKey}
+	}
+}
+
+// SetInstancePricer sets the pricer of a cloud provider
+func SetInstancePricer(provider string, pricer InstancePricer) {
+	mu.Lock()
+	defer mu.Unlock()
+	instancePricers[provider] = pricer
+}
+
+// IsOnDemandEnabled returns true if the nodes are priced with their on-demand price
+func IsOnDemandEnabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return onDemand.Enabled
+}
+
+// OnDemandRefreshInterval returns the age after which a cached on-demand price is fetched again
+func OnDemandRefreshInterval() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return refreshInterval
+}
+
+// FetchInstancePrice returns the on-demand (or spot) price per hour of the instance from the pricing api of its provider
+func FetchInstancePrice(instance Instance) (float64, error) {
+	mu.RLock()
+	pricer, isSupported := instancePricers[instance.Provider]
+	mu.RUnlock()
+	if !isSupported {
+		return 0, fmt.Errorf("no instance pricing for provider: %q", instance.Provider)
+	}
+	if instance.Region == "" || instance.InstanceType == "" {
+		return 0, fmt.Errorf("region and instance type are required to fetch the price of an instance")
+	}
+	price, err := pricer.FetchInstancePrice(instance)
+	if err == nil && price <= 0 {
+		err = fmt.Errorf("no %s price for instance type: %s in region: %s", capacityType(instance.Spot), instance.InstanceType, instance.Region)
+	}
+	return price, err
+}
+
+// CloudProvider returns the cloud provider of a node from its provider id (ex: aws:///us-east-1a/i-0123), empty if
+// the node does not run on a supported provider
+func CloudProvider(providerID string) string {
+	switch {
+	case strings.HasPrefix(providerID, "aws://"):
+		return AWS
+	case strings.HasPrefix(providerID, "gce://"):
+		return GCP
+	case strings.HasPrefix(providerID, "azure://"):
+		return Azure
+	}
+	return ""
+}
+
+// ListPrice returns the price per hour of the given cpus and memory in the catalog
+func (catalog Catalog) ListPrice(cpu utils.Cores, memory utils.GB) float64 {
+	return cpu.CostPerHour(catalog.CPU) + memory.CostPerHour(catalog.Memory)
+}
+
+// PremiumOverList returns the premium (or discount when negative) of the on-demand price of a node over the list
+// price of its cpus and memory in the catalog, ex: 0.1 for a node 10% more expensive than its list price. The cpu
+// and memory costs of the pods of the node are multiplied by 1 + premium.
+func PremiumOverList(pricePerHour float64, cpu utils.Cores, memory utils.GB) float64 {
+	listPrice := GetCatalog().ListPrice(cpu, memory)
+	if pricePerHour <= 0 || listPrice <= 0 {
+		return 0
+	}
+	return pricePerHour/listPrice - 1
+}
+
+// capacityType returns the capacity type of an instance which is spot or not
+func capacityType(spot bool) string {
+	if spot {
+		return SpotCapacity
+	}
+	return OnDemandCapacity
+}
+
+// getJSON sends the request and decodes the json response into result
+func getJSON(req *http.Request, result interface{}) error {
+	data, err := getBody(req)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// getXML sends the request and decodes the xml response into result
+func getXML(req *http.Request, result interface{}) error {
+	data, err := getBody(req)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(data, result)
+}
+
+// getBody sends the request and returns the body of the response, an error if its status is not ok
+func getBody(req *http.Request) ([]byte, error) {
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pricing request failed with status: %s, %s", resp.Status, data)
+	}
+	return data, nil
+}
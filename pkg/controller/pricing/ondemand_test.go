@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestCloudProvider(t *testing.T) {
+	utils.Equals(t, AWS, CloudProvider("aws:///us-east-1a/i-0123456789abcdef0"))
+	utils.Equals(t, GCP, CloudProvider("gce://project/us-central1-a/node-1"))
+	utils.Equals(t, Azure, CloudProvider("azure:///subscriptions/s/resourceGroups/rg/providers/vm-1"))
+	utils.Equals(t, "", CloudProvider("kind://docker/kind/kind-control-plane"))
+}
+
+func TestPremiumOverList(t *testing.T) {
+	// 2 cpus and 8 GB are listed at 2 * 0.024 + 8 * 0.01 = 0.128 per hour
+	premium := PremiumOverList(0.192, 2, 8)
+	utils.Assert(t, premium > 0.4999 && premium < 0.5001, "node must be 50%% over its list price, got: %f", premium)
+	utils.Equals(t, 0.0, PremiumOverList(0, 2, 8))
+}
+
+func TestAzureInstancePrice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Assert(t, strings.Contains(r.URL.Query().Get("$filter"), "armSkuName eq 'Standard_D2s_v3'"), "vm size must be filtered")
+		fmt.Fprint(w, `{"Items": [
+			{"retailPrice": 0.0192, "unitOfMeasure": "1 Hour", "skuName": "D2s v3 Spot", "productName": "Virtual Machines DSv3 Series"},
+			{"retailPrice": 0.188, "unitOfMeasure": "1 Hour", "skuName": "D2s v3", "productName": "Virtual Machines DSv3 Series Windows"},
+			{"retailPrice": 0.096, "unitOfMeasure": "1 Hour", "skuName": "D2s v3", "productName": "Virtual Machines DSv3 Series"}
+		]}`)
+	}))
+	defer server.Close()
+
+	pricer := &azurePricer{endpoint: server.URL}
+	price, err := pricer.FetchInstancePrice(Instance{Provider: Azure, Region: "eastus", InstanceType: "Standard_D2s_v3", OS: "linux"})
+	utils.Ok(t, err)
+	utils.Equals(t, 0.096, price)
+	price, err = pricer.FetchInstancePrice(Instance{Provider: Azure, Region: "eastus", InstanceType: "Standard_D2s_v3", OS: "windows"})
+	utils.Ok(t, err)
+	utils.Equals(t, 0.188, price)
+	price, err = pricer.FetchInstancePrice(Instance{Provider: Azure, Region: "eastus", InstanceType: "Standard_D2s_v3", OS: "linux", Spot: true})
+	utils.Ok(t, err)
+	utils.Equals(t, 0.0192, price)
+}
+
+func TestGCPInstancePrice(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"skus": [
+			{"description": "N1 Predefined Instance Core running in Americas", "category": {"resourceFamily": "Compute", "resourceGroup": "CPU", "usageType": "OnDemand"},
+			 "serviceRegions": ["us-central1"], "pricingInfo": [{"pricingExpression": {"tieredRates": [{"unitPrice": {"units": "0", "nanos": 31611000}}]}}]},
+			{"description": "Preemptible N1 Predefined Instance Core running in Americas", "category": {"resourceFamily": "Compute", "resourceGroup": "CPU", "usageType": "Preemptible"},
+			 "serviceRegions": ["us-central1"], "pricingInfo": [{"pricingExpression": {"tieredRates": [{"unitPrice": {"units": "0", "nanos": 6655000}}]}}]},
+			{"description": "N1 Predefined Instance Ram running in Americas", "category": {"resourceFamily": "Compute", "resourceGroup": "RAM", "usageType": "OnDemand"},
+			 "serviceRegions": ["us-central1"], "pricingInfo": [{"pricingExpression": {"tieredRates": [{"unitPrice": {"units": "0", "nanos": 4237000}}]}}]},
+			{"description": "Preemptible N1 Predefined Instance Ram running in Americas", "category": {"resourceFamily": "Compute", "resourceGroup": "RAM", "usageType": "Preemptible"},
+			 "serviceRegions": ["us-central1"], "pricingInfo": [{"pricingExpression": {"tieredRates": [{"unitPrice": {"units": "0", "nanos": 892000}}]}}]}
+		]}`)
+	}))
+	defer server.Close()
+
+	pricer := &gcpPricer{apiKey: "key", endpoint: server.URL}
+	price, err := pricer.FetchInstancePrice(Instance{Provider: GCP, Region: "us-central1", InstanceType: "n1-standard-2", CPU: 2, Memory: 7.5})
+	utils.Ok(t, err)
+	utils.Assert(t, price > 0.0949 && price < 0.0951, "n1-standard-2 must be priced per cpu and GB, got: %f", price)
+	price, err = pricer.FetchInstancePrice(Instance{Provider: GCP, Region: "us-central1", InstanceType: "n1-standard-2", CPU: 2, Memory: 7.5, Spot: true})
+	utils.Ok(t, err)
+	utils.Assert(t, price > 0.0199 && price < 0.0201, "preemptible n1-standard-2 must be priced per cpu and GB, got: %f", price)
+}
+
+func TestAWSInstancePrice(t *testing.T) {
+	utils.Ok(t, os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE"))
+	utils.Ok(t, os.Setenv("AWS_SECRET_ACCESS_KEY", "secret"))
+	defer func() {
+		utils.Ok(t, os.Unsetenv("AWS_ACCESS_KEY_ID"))
+		utils.Ok(t, os.Unsetenv("AWS_SECRET_ACCESS_KEY"))
+	}()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Assert(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), "request must be signed")
+		fmt.Fprint(w, `{"PriceList": ["{\"terms\": {\"OnDemand\": {\"T1\": {\"priceDimensions\": {\"D1\": {\"unit\": \"Hrs\", \"pricePerUnit\": {\"USD\": \"0.0960000000\"}}}}}}}"]}`)
+	}))
+	defer server.Close()
+
+	pricer := &awsPricer{endpoint: server.URL + "/"}
+	price, err := pricer.FetchInstancePrice(Instance{Provider: AWS, Region: "us-east-1", InstanceType: "m5.large", OS: "linux"})
+	utils.Ok(t, err)
+	utils.Equals(t, 0.096, price)
+}
+
+func TestAWSSpotPrice(t *testing.T) {
+	utils.Ok(t, os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE"))
+	utils.Ok(t, os.Setenv("AWS_SECRET_ACCESS_KEY", "secret"))
+	defer func() {
+		utils.Ok(t, os.Unsetenv("AWS_ACCESS_KEY_ID"))
+		utils.Ok(t, os.Unsetenv("AWS_SECRET_ACCESS_KEY"))
+	}()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		utils.Equals(t, "DescribeSpotPriceHistory", r.URL.Query().Get("Action"))
+		utils.Assert(t, strings.Contains(r.Header.Get("Authorization"), "/us-east-1/ec2/aws4_request, SignedHeaders=host;x-amz-date,"), "request must be signed for ec2")
+		fmt.Fprint(w, `<DescribeSpotPriceHistoryResponse><spotPriceHistorySet>
+			<item><availabilityZone>us-east-1a</availabilityZone><spotPrice>0.030000</spotPrice><timestamp>2018-11-01T10:00:00.000Z</timestamp></item>
+			<item><availabilityZone>us-east-1a</availabilityZone><spotPrice>0.035000</spotPrice><timestamp>2018-11-01T09:00:00.000Z</timestamp></item>
+			<item><availabilityZone>us-east-1b</availabilityZone><spotPrice>0.040000</spotPrice><timestamp>2018-11-01T08:00:00.000Z</timestamp></item>
+		</spotPriceHistorySet></DescribeSpotPriceHistoryResponse>`)
+	}))
+	defer server.Close()
+
+	// the latest price of every zone is averaged
+	pricer := &awsPricer{ec2Endpoint: server.URL + "/"}
+	price, err := pricer.FetchInstancePrice(Instance{Provider: AWS, Region: "us-east-1", InstanceType: "m5.large", OS: "linux", Spot: true})
+	utils.Ok(t, err)
+	utils.Assert(t, price > 0.0349 && price < 0.0351, "spot price must be the average of the zones, got: %f", price)
+}
+
+func TestSpotPrice(t *testing.T) {
+	discount := 60.0
+	mu.Lock()
+	setupSpot(SpotSettings{DiscountPercent: &discount})
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		setupSpot(SpotSettings{})
+		mu.Unlock()
+	}()
+
+	utils.Assert(t, SpotPrice(0.096, 2, 8) > 0.03839 && SpotPrice(0.096, 2, 8) < 0.03841, "on-demand price must be discounted")
+	// 2 cpus and 8 GB are listed at 0.128 per hour
+	utils.Assert(t, SpotPrice(0, 2, 8) > 0.05119 && SpotPrice(0, 2, 8) < 0.05121, "list price must be discounted")
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// Capacity types of the nodes: spot covers the spot vms of AWS and Azure and the preemptible and spot vms of GCP
+const (
+	OnDemandCapacity = "on-demand"
+	SpotCapacity     = "spot"
+)
+
+const (
+	defaultSpotDiscountPercent = 70.0
+	defaultSpotRefreshInterval = time.Hour
+)
+
+// SpotSettings configures the price of the spot and preemptible nodes. They are charged DiscountPercent (default: 70)
+// less than their on-demand price, or than the list price of their cpus and memory when on-demand prices are
+// disabled. With LivePrices the current spot price of their instance type is fetched from their cloud provider
+// instead (the gcp api key of the on-demand settings is used), and fetched again after RefreshInterval (default: 1h).
+// The discount applies when the live price is not available.
+type SpotSettings struct {
+	DiscountPercent *float64 `json:"discountPercent,omitempty"`
+	LivePrices      bool     `json:"livePrices,omitempty"`
+	RefreshInterval string   `json:"refreshInterval,omitempty"`
+}
+
+var (
+	spotDiscountPercent = defaultSpotDiscountPercent
+	spotLivePrices      bool
+	spotRefreshInterval = defaultSpotRefreshInterval
+)
+
+// setupSpot sets the spot discount and registers the pricers of the cloud providers when live spot prices are
+// enabled. mu must be held by the caller, after setupOnDemand.
+func setupSpot(settings SpotSettings) {
+	spotDiscountPercent = defaultSpotDiscountPercent
+	if settings.DiscountPercent != nil {
+		if *settings.DiscountPercent < 0 || *settings.DiscountPercent > 100 {
+			log.Errorf("invalid spot discount: (%f), it must be between 0 and 100, using %f", *settings.DiscountPercent, defaultSpotDiscountPercent)
+		} else {
+			spotDiscountPercent = *settings.DiscountPercent
+		}
+	}
+	spotLivePrices = settings.LivePrices
+	spotRefreshInterval = defaultSpotRefreshInterval
+	if settings.RefreshInterval != "" {
+		interval, err := time.ParseDuration(settings.RefreshInterval)
+		if err != nil {
+			log.Errorf("invalid refresh interval of spot prices: (%s), using %v", settings.RefreshInterval, defaultSpotRefreshInterval)
+		} else {
+			spotRefreshInterval = interval
+		}
+	}
+	if spotLivePrices {
+		registerInstancePricers()
+	}
+}
+
+// IsSpotLivePricing returns true if the spot nodes are priced with the current spot price of their instance type
+func IsSpotLivePricing() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return spotLivePrices
+}
+
+// SpotRefreshInterval returns the age after which a cached spot price is fetched again
+func SpotRefreshInterval() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return spotRefreshInterval
+}
+
+// SpotPrice returns the price per hour of a spot node from its on-demand price with the spot discount. The list price
+// of its cpus and memory in the catalog is discounted when its on-demand price is unknown (0).
+func SpotPrice(onDemandPrice float64, cpu utils.Cores, memory utils.GB) float64 {
+	catalog := GetCatalog()
+	mu.RLock()
+	discount := spotDiscountPercent
+	mu.RUnlock()
+	if onDemandPrice <= 0 {
+		onDemandPrice = catalog.ListPrice(cpu, memory)
+	}
+	return onDemandPrice * (1 - discount/100)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pricing
+
+// Default prices which are used when no catalog is available.
+// NOTE: All prices are per unit resource per hour
+const (
+	DefaultCPUCostPerCPUPerHour    = 0.024
+	DefaultMemCostPerGBPerHour     = 0.01
+	DefaultStorageCostPerGBPerHour = 0.00013888888
+	DefaultGPUCostPerGPUPerHour    = 0.9
+	// 0.05 per GB month
+	DefaultSnapshotCostPerGBPerHour = 0.00006849315
+	DefaultLoadBalancerCostPerHour  = 0.025
+
+	defaultProvider = "default"
+)
+
+// Catalog is the pricing catalog of a cloud provider.
+// NOTE: All prices are per unit resource per hour
+type Catalog struct {
+	Provider      string         `json:"provider"`
+	Region        string         `json:"region,omitempty"`
+	Version       string         `json:"version"`
+	EffectiveFrom string         `json:"effectiveFrom,omitempty"`
+	SyncTime      string         `json:"syncTime,omitempty"`
+	Offline       bool           `json:"offline,omitempty"`
+	Discounted    bool           `json:"discounted,omitempty"`
+	CPU           float64        `json:"cpuCostPerCPUPerHour"`
+	Memory        float64        `json:"memCostPerGBPerHour"`
+	Storage       float64        `json:"storageCostPerGBPerHour"`
+	GPU           float64        `json:"gpuCostPerGPUPerHour"`
+	Snapshot      float64        `json:"snapshotCostPerGBPerHour"`
+	LoadBalancer  float64        `json:"loadBalancerCostPerHour"`
+	InstanceTypes []InstanceType `json:"instanceTypes,omitempty"`
+}
+
+// PricePeriod is the price in effect from EffectiveFrom until the EffectiveFrom of the next period.
+// NOTE: All prices are per unit resource per hour
+type PricePeriod struct {
+	EffectiveFrom string  `json:"effectiveFrom"`
+	Version       string  `json:"version,omitempty"`
+	CPU           float64 `json:"cpuCostPerCPUPerHour"`
+	Memory        float64 `json:"memCostPerGBPerHour"`
+	Storage       float64 `json:"storageCostPerGBPerHour"`
+	GPU           float64 `json:"gpuCostPerGPUPerHour"`
+	Snapshot      float64 `json:"snapshotCostPerGBPerHour"`
+	LoadBalancer  float64 `json:"loadBalancerCostPerHour"`
+	// Discount factors (the part of the list price paid) of the discounts in effect when the period was recorded
+	DiscountsRecorded    bool    `json:"discountsRecorded,omitempty"`
+	CPUDiscount          float64 `json:"cpuDiscountFactor"`
+	MemoryDiscount       float64 `json:"memDiscountFactor"`
+	StorageDiscount      float64 `json:"storageDiscountFactor"`
+	GPUDiscount          float64 `json:"gpuDiscountFactor"`
+	SnapshotDiscount     float64 `json:"snapshotDiscountFactor"`
+	LoadBalancerDiscount float64 `json:"loadBalancerDiscountFactor"`
+}
+
+// InstanceType is the price of a node instance type. Memory is in GB.
+// Architecture is the cpu architecture of the instance type (ex: amd64, arm64), empty means amd64.
+type InstanceType struct {
+	Name         string  `json:"name"`
+	CPU          float64 `json:"cpu"`
+	Memory       float64 `json:"memory"`
+	PricePerHour float64 `json:"pricePerHour"`
+	Architecture string  `json:"architecture,omitempty"`
+}
+
+// Provider fetches the latest pricing catalog from a cloud provider.
+type Provider interface {
+	Name() string
+	FetchCatalog() (*Catalog, error)
+}
+
+// HistoryStore persists the price history so that it survives the restarts of the controller.
+type HistoryStore interface {
+	StorePricePeriod(period PricePeriod) error
+	LoadPriceHistory() ([]PricePeriod, error)
+}
+
+// Settings for the pricing catalog sync job.
+type Settings struct {
+	CatalogURL   string `json:"catalogURL,omitempty"`
+	CacheFile    string `json:"cacheFile,omitempty"`
+	SyncInterval string `json:"syncInterval,omitempty"`
+	// Network prices per provider and region, the defaults apply to the others
+	Network []NetworkPrices `json:"network,omitempty"`
+	// Upfront payments amortized over their term in the namespace costs
+	Amortization []UpfrontCost `json:"amortization,omitempty"`
+	// Negotiated discounts off the list prices of the catalog
+	Discounts []Discount `json:"discounts,omitempty"`
+	// On-demand prices of the nodes fetched from the pricing apis of the cloud providers
+	OnDemand OnDemandSettings `json:"onDemand,omitempty"`
+	// Prices of the spot and preemptible nodes
+	Spot SpotSettings `json:"spot,omitempty"`
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Types of the metric families
+const (
+	gauge     = "gauge"
+	counter   = "counter"
+	histogram = "histogram"
+)
+
+// family is a metric with its samples, the name of a sample is the name of its family followed by its suffix
+// (_bucket, _sum and _count for histograms)
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+type sample struct {
+	suffix string
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// labelEscaper escapes the label values of the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// helpEscaper escapes the help text of the text exposition format
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// encode writes the families in the text exposition format, sorted by name
+func encode(w io.Writer, families []family) error {
+	sort.SliceStable(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	buf := bufio.NewWriter(w)
+	for _, f := range families {
+		buf.WriteString("# HELP " + f.name + " " + helpEscaper.Replace(f.help) + "\n")
+		buf.WriteString("# TYPE " + f.name + " " + f.typ + "\n")
+		for _, s := range f.samples {
+			buf.WriteString(f.name + s.suffix)
+			if len(s.labels) > 0 {
+				buf.WriteString("{")
+				for i, l := range s.labels {
+					if i > 0 {
+						buf.WriteString(",")
+					}
+					buf.WriteString(l.name + `="` + labelEscaper.Replace(l.value) + `"`)
+				}
+				buf.WriteString("}")
+			}
+			buf.WriteString(" " + formatValue(s.value) + "\n")
+		}
+	}
+	return buf.Flush()
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
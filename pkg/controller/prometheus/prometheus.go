@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package prometheus exposes the cost rates, the allocation and usage of the namespaces, deployments and nodes, and
+// the health of the controller in the Prometheus text exposition format.
+package prometheus
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// costTTL is the age after which the cost families are queried again, scrapes in between reuse them
+const costTTL = time.Minute
+
+var (
+	mu           sync.Mutex
+	costFamilies []family
+	costTime     time.Time
+	costErrors   int
+	queues       = map[string]func() int{}
+)
+
+// RegisterQueue exposes the depth of a queue of the controller as purser_queue_depth{queue="name"}
+func RegisterQueue(name string, depth func() int) {
+	mu.Lock()
+	defer mu.Unlock()
+	queues[name] = depth
+}
+
+// Write writes the metrics in the text exposition format
+func Write(w io.Writer) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if time.Since(costTime) > costTTL {
+		families, err := collectCosts()
+		if err != nil {
+			log.Errorf("unable to collect cost metrics: (%v)", err)
+			costErrors++
+		} else {
+			costFamilies, costTime = families, time.Now()
+		}
+	}
+	families := append([]family{}, costFamilies...)
+	families = append(families, healthFamilies()...)
+	return encode(w, families)
+}
+
+// collectCosts returns the families of the cost rates, allocation and usage of the cluster, namespaces, deployments
+// and nodes
+func collectCosts() ([]family, error) {
+	rates, err := query.RetrieveCostRates(query.Namespace, 0)
+	if err != nil {
+		return nil, err
+	}
+	deployments, err := query.RetrieveDeploymentCostRates()
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := query.RetrieveNodeCostRates()
+	if err != nil {
+		return nil, err
+	}
+
+	families := []family{{
+		name:    "purser_cluster_cost_per_hour",
+		help:    "Cost per hour of the resources allocated right now to the cluster.",
+		typ:     gauge,
+		samples: []sample{{value: rates.Cluster.CostPerHour}},
+	}}
+	namespaceLabels := func(rate query.CostRate) []label {
+		return []label{{"namespace", rate.Xid}}
+	}
+	families = append(families, scopeFamilies("namespace", rates.Scopes, namespaceLabels)...)
+	deploymentLabels := func(rate query.CostRate) []label {
+		parts := strings.SplitN(rate.Xid, ":", 2)
+		if len(parts) < 2 {
+			return []label{{"namespace", ""}, {"deployment", rate.Xid}}
+		}
+		return []label{{"namespace", parts[0]}, {"deployment", parts[1]}}
+	}
+	families = append(families, scopeFamilies("deployment", deployments, deploymentLabels)...)
+	families = append(families, nodeFamilies(nodes)...)
+	return families, nil
+}
+
+// scopeFamilies returns the cost rate, the cpu and memory allocated (requests) and used by the scopes
+func scopeFamilies(scope string, rates []query.CostRate, labels func(query.CostRate) []label) []family {
+	prefix := "purser_" + scope + "_"
+	families := []family{
+		{name: prefix + "cost_per_hour", help: "Cost per hour of the resources allocated right now to the " + scope + "."},
+		{name: prefix + "cpu_allocated_cores", help: "Cpus requested by the pods of the " + scope + "."},
+		{name: prefix + "cpu_usage_cores", help: "Average cpu usage of the pods of the " + scope + " sampled from the metrics api."},
+		{name: prefix + "memory_allocated_gb", help: "Memory (GB) requested by the pods of the " + scope + "."},
+		{name: prefix + "memory_usage_gb", help: "Average memory (GB) usage of the pods of the " + scope + " sampled from the metrics api."},
+	}
+	for _, rate := range rates {
+		l := labels(rate)
+		for i, value := range []float64{rate.CostPerHour, float64(rate.CPU), float64(rate.CPUUsage), float64(rate.Memory),
+			float64(rate.MemoryUsage)} {
+			families[i].samples = append(families[i].samples, sample{labels: l, value: value})
+		}
+	}
+	for i := range families {
+		families[i].typ = gauge
+	}
+	return families
+}
+
+// nodeFamilies returns the cost rate, the capacity and the allocated (requested) cpu and memory of the nodes
+func nodeFamilies(nodes []query.NodeCostRate) []family {
+	families := []family{
+		{name: "purser_node_cost_per_hour", help: "Cost per hour of the node."},
+		{name: "purser_node_cpu_capacity_cores", help: "Cpus of the node."},
+		{name: "purser_node_cpu_allocated_cores", help: "Cpus requested by the pods running on the node."},
+		{name: "purser_node_memory_capacity_gb", help: "Memory (GB) of the node."},
+		{name: "purser_node_memory_allocated_gb", help: "Memory (GB) requested by the pods running on the node."},
+	}
+	for _, node := range nodes {
+		l := []label{{"node", node.Xid}, {"capacity_type", node.CapacityType}}
+		for i, value := range []float64{node.CostPerHour, float64(node.CPUCapacity), float64(node.CPU),
+			float64(node.MemoryCapacity), float64(node.Memory)} {
+			families[i].samples = append(families[i].samples, sample{labels: l, value: value})
+		}
+	}
+	for i := range families {
+		families[i].typ = gauge
+	}
+	return families
+}
+
+// healthFamilies returns the families of the dgraph writes and of the queues of the controller. mu must be held.
+func healthFamilies() []family {
+	stats := dgraph.GetWriteStats()
+	latency := family{
+		name: "purser_dgraph_write_duration_seconds",
+		help: "Latency of the mutations written to dgraph.",
+		typ:  histogram,
+	}
+	for i, bound := range stats.Buckets {
+		latency.samples = append(latency.samples, sample{suffix: "_bucket", labels: []label{{"le", formatValue(bound)}}, value: float64(stats.BucketCounts[i])})
+	}
+	latency.samples = append(latency.samples,
+		sample{suffix: "_bucket", labels: []label{{"le", "+Inf"}}, value: float64(stats.Writes)},
+		sample{suffix: "_sum", value: stats.LatencySeconds},
+		sample{suffix: "_count", value: float64(stats.Writes)})
+
+	cache := dgraph.GetUIDCacheStats()
+	families := []family{
+		latency,
+		{name: "purser_dgraph_write_errors_total", help: "Mutations which dgraph failed to write.", typ: counter,
+			samples: []sample{{value: float64(stats.Errors)}}},
+		{name: "purser_dgraph_pending_mutations", help: "Nodes queued to be written to dgraph with the next batch.", typ: gauge,
+			samples: []sample{{value: float64(dgraph.PendingMutations())}}},
+		{name: "purser_uid_cache_entries", help: "Entries of the cache of the dgraph uids.", typ: gauge,
+			samples: []sample{{value: float64(cache.Entries)}}},
+		{name: "purser_uid_cache_misses_total", help: "Lookups of dgraph uids missing the cache.", typ: counter,
+			samples: []sample{{value: float64(cache.Misses)}}},
+		{name: "purser_cost_collection_errors_total", help: "Failed collections of the cost metrics.", typ: counter,
+			samples: []sample{{value: float64(costErrors)}}},
+		{name: "purser_cost_metrics_age_seconds", help: "Age of the cost metrics, they are collected at most every minute.", typ: gauge,
+			samples: []sample{{value: costAge()}}},
+	}
+
+	depth := family{name: "purser_queue_depth", help: "Items waiting in the queues of the controller.", typ: gauge}
+	names := []string{}
+	for name := range queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		depth.samples = append(depth.samples, sample{labels: []label{{"queue", name}}, value: float64(queues[name]())})
+	}
+	return append(families, depth)
+}
+
+// costAge returns the age in seconds of the cost families, -1 if they were never collected. mu must be held.
+func costAge() float64 {
+	if costTime.IsZero() {
+		return -1
+	}
+	return time.Since(costTime).Seconds()
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestEncode(t *testing.T) {
+	families := []family{
+		{name: "purser_queue_depth", help: "Items waiting.", typ: gauge, samples: []sample{
+			{labels: []label{{"queue", "pod \"events\"\n"}}, value: 3},
+		}},
+		{name: "purser_dgraph_write_duration_seconds", help: "Latency\\n.", typ: histogram, samples: []sample{
+			{suffix: "_bucket", labels: []label{{"le", "0.5"}}, value: 1},
+			{suffix: "_bucket", labels: []label{{"le", "+Inf"}}, value: 2},
+			{suffix: "_sum", value: 1.25},
+			{suffix: "_count", value: 2},
+		}},
+		{name: "purser_cost_metrics_age_seconds", help: "Age.", typ: gauge, samples: []sample{{value: math.Inf(1)}}},
+	}
+	var buf bytes.Buffer
+	utils.Ok(t, encode(&buf, families))
+	utils.Equals(t, `# HELP purser_cost_metrics_age_seconds Age.
+# TYPE purser_cost_metrics_age_seconds gauge
+purser_cost_metrics_age_seconds +Inf
+# HELP purser_dgraph_write_duration_seconds Latency\\n.
+# TYPE purser_dgraph_write_duration_seconds histogram
+purser_dgraph_write_duration_seconds_bucket{le="0.5"} 1
+purser_dgraph_write_duration_seconds_bucket{le="+Inf"} 2
+purser_dgraph_write_duration_seconds_sum 1.25
+purser_dgraph_write_duration_seconds_count 2
+# HELP purser_queue_depth Items waiting.
+# TYPE purser_queue_depth gauge
+purser_queue_depth{queue="pod \"events\"\n"} 3
+`, buf.String())
+}
+
+func TestScopeFamilies(t *testing.T) {
+	rates := []query.CostRate{{Xid: "shop:web", CPU: 2, CPUUsage: 0.5, Memory: 4, MemoryUsage: 3, CostPerHour: 0.25}}
+	families := scopeFamilies("deployment", rates, func(rate query.CostRate) []label {
+		return []label{{"deployment", rate.Xid}}
+	})
+	utils.Equals(t, 5, len(families))
+	utils.Equals(t, "purser_deployment_cpu_usage_cores", families[2].name)
+	utils.Equals(t, []sample{{labels: []label{{"deployment", "shop:web"}}, value: 0.5}}, families[2].samples)
+	utils.Equals(t, gauge, families[4].typ)
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	podPruneInterval = 10 * time.Minute
+	// completed pods stay in the cache for a while so that their queued events are processed with the object
+	completedPodRetention  = 10 * time.Minute
+	aggressivePodRetention = time.Minute
+)
+
+// prunePods removes the pods which completed (succeeded or failed) before the retention period from the
+// informer cache. Completed pods do not change anymore and their deletion is still delivered by the watch.
+func prunePods(informer cache.SharedIndexInformer, aggressive bool) {
+	retention := completedPodRetention
+	if aggressive {
+		retention = aggressivePodRetention
+	}
+	completedBefore := time.Now().Add(-retention)
+
+	store := informer.GetStore()
+	pruned := 0
+	for _, obj := range store.List() {
+		pod, isPod := obj.(*api_v1.Pod)
+		if !isPod || !isCompletedBefore(pod, completedBefore) {
+			continue
+		}
+		if err := store.Delete(pod); err != nil {
+			log.Errorf("unable to prune pod: (%s/%s) from cache, error: %v", pod.Namespace, pod.Name, err)
+			continue
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		log.Debugf("pruned %d completed pods from cache", pruned)
+	}
+}
+
+// isCompletedBefore returns true if the pod succeeded or failed and its last container terminated before t
+func isCompletedBefore(pod *api_v1.Pod, t time.Time) bool {
+	if pod.Status.Phase != api_v1.PodSucceeded && pod.Status.Phase != api_v1.PodFailed {
+		return false
+	}
+	completion := pod.CreationTimestamp.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.FinishedAt.After(completion) {
+			completion = status.State.Terminated.FinishedAt.Time
+		}
+	}
+	return completion.Before(t)
+}
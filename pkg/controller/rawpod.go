@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+// ScanRawPods stores the pod overhead and the ephemeral containers (debugging sessions) of the pods of the
+// namespaces processed by this controller replica. The pod informer drops both since the vendored kubernetes api
+// does not know them, so the pods are listed raw from the api server instead.
+func ScanRawPods() {
+	if Kubeclient == nil {
+		return
+	}
+	data, err := Kubeclient.CoreV1().RESTClient().Get().Resource("pods").DoRaw()
+	if err != nil {
+		log.Errorf("unable to list raw pods, error: (%v)", err)
+		return
+	}
+
+	type podList struct {
+		Items []models.RawPod `json:"items"`
+	}
+	pods := podList{}
+	if err = json.Unmarshal(data, &pods); err != nil {
+		log.Errorf("unable to decode raw pods, error: (%v)", err)
+		return
+	}
+	for _, pod := range pods.Items {
+		if !sharding.Owns(pod.Metadata.Namespace) {
+			continue
+		}
+		if err = models.StorePodOverhead(pod); err != nil {
+			log.Errorf("unable to store overhead of pod: (%s:%s), error: (%v)", pod.Metadata.Namespace, pod.Metadata.Name, err)
+		}
+		if err = models.StoreEphemeralContainers(pod); err != nil {
+			log.Errorf("unable to store ephemeral containers of pod: (%s:%s), error: (%v)", pod.Metadata.Namespace, pod.Metadata.Name, err)
+		}
+	}
+}
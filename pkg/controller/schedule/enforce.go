@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+// ScheduledReplicasAnnotation holds the replicas of a workload scaled to zero outside of the office hours, they are
+// restored at the start of the office hours
+const ScheduledReplicasAnnotation = "purser.vmware.com/scheduled-replicas"
+
+// workload is a deployment or statefulset in the scope of an enforced policy
+type workload struct {
+	kind        string
+	namespace   string
+	name        string
+	replicas    int32
+	annotations map[string]string
+}
+
+// Enforce scales the workloads of the enforced policies in the namespaces processed by this controller replica:
+// to zero outside of the office hours, keeping their replicas in an annotation, and back to these replicas once the
+// office hours start. Workloads scaled by hand to zero are left as they are.
+func Enforce() {
+	mu.Lock()
+	p, client := policies, kubeclient
+	mu.Unlock()
+	if client == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, policy := range p {
+		if !policy.Enforce || policy.validate() != nil {
+			continue
+		}
+		hours, err := parseOfficeHours(policy)
+		if err != nil {
+			continue
+		}
+		labels, err := groupLabels(policy.Group)
+		if err != nil {
+			log.Errorf("unable to enforce schedule policy: (%s), error: (%v)", policy.Name, err)
+			continue
+		}
+		workloads, err := listWorkloads(client, policy.Namespace, labels)
+		if err != nil {
+			log.Errorf("unable to list workloads of schedule policy: (%s), error: (%v)", policy.Name, err)
+			continue
+		}
+		isOfficeTime := hours.isOfficeTime(now)
+		for _, w := range workloads {
+			if !sharding.Owns(w.namespace) {
+				continue
+			}
+			if err = reconcileReplicas(client, w, isOfficeTime); err != nil {
+				log.Errorf("unable to scale %s: (%s:%s) of schedule policy: (%s), error: (%v)", w.kind, w.namespace, w.name, policy.Name, err)
+			}
+		}
+	}
+}
+
+// listWorkloads returns the deployments and statefulsets of the namespace, or those of all namespaces whose pod
+// template has any of the labels when labels are given
+func listWorkloads(client kubernetes.Interface, namespace string, labels map[string]string) ([]workload, error) {
+	if len(labels) > 0 {
+		namespace = meta_v1.NamespaceAll
+	}
+	var workloads []workload
+	deployments, err := client.AppsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		if len(labels) > 0 && !hasAnyLabel(d.Spec.Template.Labels, labels) {
+			continue
+		}
+		workloads = append(workloads, workload{kind: query.DeploymentKind, namespace: d.Namespace, name: d.Name,
+			replicas: replicasOf(d.Spec.Replicas), annotations: d.Annotations})
+	}
+	statefulsets, err := client.AppsV1beta1().StatefulSets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulsets.Items {
+		if len(labels) > 0 && !hasAnyLabel(s.Spec.Template.Labels, labels) {
+			continue
+		}
+		workloads = append(workloads, workload{kind: query.StatefulSetKind, namespace: s.Namespace, name: s.Name,
+			replicas: replicasOf(s.Spec.Replicas), annotations: s.Annotations})
+	}
+	return workloads, nil
+}
+
+// replicasOf returns the desired replicas of a workload, which default to one
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+func hasAnyLabel(workloadLabels, labels map[string]string) bool {
+	for key, value := range labels {
+		if workloadLabels[key] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileReplicas scales the workload down to zero outside of the office hours and restores its replicas during them
+func reconcileReplicas(client kubernetes.Interface, w workload, isOfficeTime bool) error {
+	scheduled, isScaledDown := w.annotations[ScheduledReplicasAnnotation]
+	if isOfficeTime && isScaledDown {
+		replicas, err := strconv.Atoi(scheduled)
+		if err != nil {
+			return err
+		}
+		log.Infof("scaling up %s: (%s:%s) to %d replicas for office hours", w.kind, w.namespace, w.name, replicas)
+		return patchReplicas(client, w, int32(replicas), nil)
+	}
+	if !isOfficeTime && !isScaledDown && w.replicas > 0 {
+		log.Infof("scaling down %s: (%s:%s) outside of office hours", w.kind, w.namespace, w.name)
+		replicas := strconv.Itoa(int(w.replicas))
+		return patchReplicas(client, w, 0, &replicas)
+	}
+	return nil
+}
+
+// patchReplicas sets the replicas of the workload and the scheduled replicas annotation, which is removed when nil
+func patchReplicas(client kubernetes.Interface, w workload, replicas int32, scheduled *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{
+				ScheduledReplicasAnnotation: scheduled,
+			},
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if w.kind == query.StatefulSetKind {
+		_, err = client.AppsV1beta1().StatefulSets(w.namespace).Patch(w.name, types.MergePatchType, patch)
+	} else {
+		_, err = client.AppsV1beta1().Deployments(w.namespace).Patch(w.name, types.MergePatchType, patch)
+	}
+	return err
+}
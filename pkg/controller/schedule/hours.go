@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const clockFormat = "15:04"
+
+// officeHours are the parsed days and hours of a policy
+type officeHours struct {
+	days [7]bool
+	// start and end are in minutes since midnight
+	start, end int
+	location   *time.Location
+}
+
+func parseOfficeHours(policy Policy) (officeHours, error) {
+	hours := officeHours{location: time.Local}
+	if policy.TimeZone != "" {
+		location, err := time.LoadLocation(policy.TimeZone)
+		if err != nil {
+			return hours, err
+		}
+		hours.location = location
+	}
+
+	start, err := time.Parse(clockFormat, policy.Start)
+	if err != nil {
+		return hours, fmt.Errorf("invalid start of office hours: %s", policy.Start)
+	}
+	end, err := time.Parse(clockFormat, policy.End)
+	if err != nil {
+		return hours, fmt.Errorf("invalid end of office hours: %s", policy.End)
+	}
+	hours.start, hours.end = start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if hours.end <= hours.start {
+		return hours, fmt.Errorf("office hours end (%s) before they start (%s)", policy.End, policy.Start)
+	}
+
+	days := policy.Days
+	if len(days) == 0 {
+		days = []string{"Monday", "Tuesday", "Wednesday", "Thursday", "Friday"}
+	}
+	for _, name := range days {
+		day, err := parseWeekday(name)
+		if err != nil {
+			return hours, err
+		}
+		hours.days[day] = true
+	}
+	return hours, nil
+}
+
+// parseWeekday parses the full or three letter name of a day of week, ignoring case
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := day.String()
+		if strings.EqualFold(name, full) || strings.EqualFold(name, full[:3]) {
+			return day, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("invalid day of week: %s", name)
+}
+
+// isOfficeTime tells whether t is within the office hours
+func (h officeHours) isOfficeTime(t time.Time) bool {
+	t = t.In(h.location)
+	minutes := t.Hour()*60 + t.Minute()
+	return h.days[t.Weekday()] && minutes >= h.start && minutes < h.end
+}
+
+// offHours returns the time of [from, to) outside of the office hours
+func (h officeHours) offHours(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	from, to = from.In(h.location), to.In(h.location)
+	office := time.Duration(0)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, h.location); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !h.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, h.start, 0, 0, h.location)
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, h.end, 0, 0, h.location)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			office += end.Sub(start)
+		}
+	}
+	return to.Sub(from) - office
+}
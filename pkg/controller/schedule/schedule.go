@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+// Policy is an office hours schedule of the deployments and statefulsets of a namespace or a group. Workloads are
+// expected to have no pod running outside of the office hours.
+type Policy struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	Group     string `json:"group,omitempty"`
+	// Days of the office hours (ex: Monday or Mon), Monday to Friday by default
+	Days []string `json:"days,omitempty"`
+	// Start and End of the office hours (ex: 08:00 and 19:00)
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// TimeZone of the office hours (ex: Europe/Paris), time zone of the controller by default
+	TimeZone string `json:"timeZone,omitempty"`
+	// Enforce scales the workloads to zero replicas at the end of the office hours and back at their start
+	Enforce bool `json:"enforce,omitempty"`
+}
+
+// WorkloadCompliance of a deployment or statefulset with the office hours of its policy in a time window
+type WorkloadCompliance struct {
+	Kind string `json:"kind"`
+	Xid  string `json:"xid"`
+	// OffHours of the window and OffHoursRunning, the off hours during which at least one pod ran
+	OffHours        float64 `json:"offHours"`
+	OffHoursRunning float64 `json:"offHoursRunning"`
+	// Compliance is the share of the off hours without any pod running
+	Compliance   float64 `json:"compliance"`
+	OffHoursCost float64 `json:"offHoursCost"`
+	// Savings is the cost of one replica during the off hours without any pod running
+	Savings float64 `json:"savings"`
+}
+
+// Report of the compliance of the workloads of a policy in a time window. OffHoursCost is the cost which would
+// be saved if every workload complied with the policy.
+type Report struct {
+	Policy       Policy               `json:"policy"`
+	From         string               `json:"from"`
+	To           string               `json:"to"`
+	Error        string               `json:"error,omitempty"`
+	Compliance   float64              `json:"compliance"`
+	OffHoursCost float64              `json:"offHoursCost"`
+	Savings      float64              `json:"savings"`
+	Workloads    []WorkloadCompliance `json:"workloads"`
+}
+
+var (
+	mu         sync.Mutex
+	policies   []Policy
+	kubeclient kubernetes.Interface
+)
+
+// Setup sets the office hours policies and the client with which enforced policies scale workloads
+func Setup(p []Policy, client kubernetes.Interface) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, policy := range p {
+		if err := policy.validate(); err != nil {
+			log.Errorf("invalid schedule policy: (%s), error: (%v)", policy.Name, err)
+		}
+	}
+	policies = p
+	kubeclient = client
+}
+
+// RetrieveReports returns the compliance of every policy in the time window [from, to)
+func RetrieveReports(from, to time.Time) []Report {
+	mu.Lock()
+	p := policies
+	mu.Unlock()
+
+	reports := []Report{}
+	for _, policy := range p {
+		report := Report{Policy: policy, From: from.Format(query.DateFormat), To: to.Format(query.DateFormat), Workloads: []WorkloadCompliance{}}
+		if err := fillReport(&report, from, to); err != nil {
+			report.Error = err.Error()
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// validate checks that the policy has a scope and valid office hours
+func (p Policy) validate() error {
+	if p.Namespace == "" && p.Group == "" {
+		return fmt.Errorf("policy has no namespace nor group")
+	}
+	_, err := parseOfficeHours(p)
+	return err
+}
+
+func fillReport(report *Report, from, to time.Time) error {
+	if err := report.Policy.validate(); err != nil {
+		return err
+	}
+	hours, err := parseOfficeHours(report.Policy)
+	if err != nil {
+		return err
+	}
+	labels, err := groupLabels(report.Policy.Group)
+	if err != nil {
+		return err
+	}
+	workloads, err := query.RetrieveWorkloadPods(report.Policy.Namespace, labels, from, to)
+	if err != nil {
+		return err
+	}
+
+	report.Compliance = 1
+	for _, workload := range workloads {
+		compliance := workloadCompliance(workload, hours, from, to)
+		report.OffHoursCost += compliance.OffHoursCost
+		report.Savings += compliance.Savings
+		report.Workloads = append(report.Workloads, compliance)
+	}
+	if len(report.Workloads) > 0 {
+		total := 0.0
+		for _, compliance := range report.Workloads {
+			total += compliance.Compliance
+		}
+		report.Compliance = total / float64(len(report.Workloads))
+	}
+	sort.SliceStable(report.Workloads, func(i, j int) bool {
+		return report.Workloads[i].OffHoursCost > report.Workloads[j].OffHoursCost
+	})
+	return nil
+}
+
+// groupLabels returns the labels of the group, no labels are returned for an empty group
+func groupLabels(group string) (map[string]string, error) {
+	if group == "" {
+		return nil, nil
+	}
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Xid == group {
+			return g.GetLabelsMap(), nil
+		}
+	}
+	return nil, fmt.Errorf("group not found: %s", group)
+}
+
+// workloadCompliance computes the time and cost of the pods of the workload outside of the office hours within the
+// window [from, to). Pods are already clipped to the window.
+func workloadCompliance(workload query.WorkloadPods, hours officeHours, from, to time.Time) WorkloadCompliance {
+	compliance := WorkloadCompliance{Kind: workload.Kind, Xid: workload.Xid, Compliance: 1}
+	compliance.OffHours = hours.offHours(from, to).Hours()
+
+	cost, podHours := 0.0, 0.0
+	runs := []query.PodRun{}
+	for _, pod := range workload.Pods {
+		cost += pod.Cost
+		duration := pod.End.Sub(pod.Start).Hours()
+		if duration <= 0 {
+			continue
+		}
+		podHours += duration
+		compliance.OffHoursCost += pod.Cost * hours.offHours(pod.Start, pod.End).Hours() / duration
+		runs = append(runs, pod)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+
+	// off hours covered by at least one pod
+	var current *query.PodRun
+	for i := range runs {
+		if current != nil && !runs[i].Start.After(current.End) {
+			if runs[i].End.After(current.End) {
+				current.End = runs[i].End
+			}
+			continue
+		}
+		if current != nil {
+			compliance.OffHoursRunning += hours.offHours(current.Start, current.End).Hours()
+		}
+		current = &runs[i]
+	}
+	if current != nil {
+		compliance.OffHoursRunning += hours.offHours(current.Start, current.End).Hours()
+	}
+
+	if compliance.OffHours > 0 {
+		compliance.Compliance = 1 - compliance.OffHoursRunning/compliance.OffHours
+	}
+	if podHours > 0 {
+		compliance.Savings = (compliance.OffHours - compliance.OffHoursRunning) * cost / podHours
+	}
+	return compliance
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestOfficeHours(t *testing.T) {
+	hours, err := parseOfficeHours(Policy{Start: "08:00", End: "18:00", TimeZone: "UTC"})
+	utils.Ok(t, err)
+
+	monday := time.Date(2018, 11, 5, 0, 0, 0, 0, time.UTC)
+	utils.Assert(t, hours.isOfficeTime(monday.Add(9*time.Hour)), "monday 9h is office time")
+	utils.Assert(t, !hours.isOfficeTime(monday.Add(18*time.Hour)), "office hours end at 18h")
+	utils.Assert(t, !hours.isOfficeTime(monday.AddDate(0, 0, 5).Add(9*time.Hour)), "saturday is off by default")
+
+	// 10 office hours on 5 days of the week
+	utils.Equals(t, 118*time.Hour, hours.offHours(monday, monday.AddDate(0, 0, 7)))
+	utils.Equals(t, 2*time.Hour, hours.offHours(monday.Add(6*time.Hour), monday.Add(12*time.Hour)))
+
+	_, err = parseOfficeHours(Policy{Start: "18:00", End: "08:00"})
+	utils.Assert(t, err != nil, "office hours must end after they start")
+	_, err = parseOfficeHours(Policy{Start: "08:00", End: "18:00", Days: []string{"mon", "Funday"}})
+	utils.Assert(t, err != nil, "invalid day is rejected")
+}
+
+func TestWorkloadCompliance(t *testing.T) {
+	hours, err := parseOfficeHours(Policy{Start: "08:00", End: "18:00", TimeZone: "UTC", Days: []string{"Mon"}})
+	utils.Ok(t, err)
+	from := time.Date(2018, 11, 5, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	workload := query.WorkloadPods{Kind: query.DeploymentKind, Xid: "shop:web", Pods: []query.PodRun{
+		// runs during office hours and 2 hours after, overlapping the second pod
+		{Start: from.Add(8 * time.Hour), End: from.Add(20 * time.Hour), Cost: 12},
+		{Start: from.Add(19 * time.Hour), End: from.Add(22 * time.Hour), Cost: 3},
+	}}
+
+	compliance := workloadCompliance(workload, hours, from, to)
+	utils.Equals(t, 14.0, compliance.OffHours)
+	utils.Equals(t, 4.0, compliance.OffHoursRunning)
+	utils.Equals(t, 5.0, compliance.OffHoursCost)
+	utils.Assert(t, compliance.Compliance > 0.71 && compliance.Compliance < 0.72, "compliance: %v", compliance.Compliance)
+	utils.Equals(t, 10.0, compliance.Savings)
+}
@@ -0,0 +1,284 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sharding
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Settings of controller sharding. When enabled, every controller replica holds a lease in LeaseNamespace and
+// processes the namespaces whose hash falls in its shard. LeaseDuration is a duration string (ex: 30s).
+type Settings struct {
+	Enabled        bool   `json:"enabled,omitempty"`
+	LeaseNamespace string `json:"leaseNamespace,omitempty"`
+	LeaseDuration  string `json:"leaseDuration,omitempty"`
+}
+
+// Leases are config maps with the fields of coordination.k8s.io leases as annotations, Lease objects
+// are not served by the kubernetes client version in use.
+const (
+	leaseLabel          = "purser.vmware.com/shard-lease"
+	holderAnnotation    = "purser.vmware.com/holder-identity"
+	renewTimeAnnotation = "purser.vmware.com/renew-time"
+	durationAnnotation  = "purser.vmware.com/lease-duration-seconds"
+	leasePrefix         = "purser-shard-"
+
+	defaultLeaseNamespace = "default"
+	defaultLeaseDuration  = 30 * time.Second
+	// expired leases are deleted by the first shard once they are expired for this many lease durations
+	staleLeaseDurations = 10
+)
+
+var (
+	mu            sync.RWMutex
+	enabled       bool
+	kubeclient    kubernetes.Interface
+	identity      string
+	namespace     string
+	leaseDuration time.Duration
+	members       []string
+	index         int
+	listeners     []func()
+)
+
+// Setup acquires the lease of this replica and computes the initial shard. The replica is identified by
+// its hostname, which is the pod name.
+func Setup(settings Settings, client kubernetes.Interface) {
+	if !settings.Enabled {
+		return
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Fatalf("unable to get replica identity for sharding, error: %v", err)
+	}
+
+	mu.Lock()
+	enabled = true
+	kubeclient = client
+	identity = hostname
+	namespace = settings.LeaseNamespace
+	if namespace == "" {
+		namespace = defaultLeaseNamespace
+	}
+	leaseDuration = defaultLeaseDuration
+	if settings.LeaseDuration != "" {
+		if leaseDuration, err = time.ParseDuration(settings.LeaseDuration); err != nil {
+			log.Fatalf("invalid sharding lease duration: (%s), error: %v", settings.LeaseDuration, err)
+		}
+	}
+	mu.Unlock()
+
+	syncShard()
+}
+
+// Start renews the lease of this replica and refreshes the shard periodically until ctx is done
+func Start(ctx context.Context) {
+	if !IsEnabled() {
+		return
+	}
+	go wait.Until(syncShard, leaseDuration/3, ctx.Done())
+}
+
+// Release deletes the lease of this replica on shutdown, so that the other replicas take over its namespaces
+// without waiting for the lease to expire
+func Release() {
+	if !IsEnabled() {
+		return
+	}
+	err := kubeclient.CoreV1().ConfigMaps(namespace).Delete(leasePrefix+identity, &meta_v1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		log.Errorf("unable to release shard lease of %s, error: %v", identity, err)
+	}
+}
+
+// IsEnabled returns true if the controller is sharded
+func IsEnabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return enabled
+}
+
+// Owns returns true if the objects of the namespace are processed by this replica
+func Owns(ns string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	if !enabled {
+		return true
+	}
+	return len(members) > 0 && shardOf(ns, len(members)) == index
+}
+
+// IsLeader returns true if this replica holds the first shard. The first shard also processes cluster scoped
+// objects and runs the cluster wide periodic jobs. It is always true if sharding is disabled.
+func IsLeader() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return !enabled || (len(members) > 0 && index == 0)
+}
+
+// OnRebalance registers a function which is called when the shard of this replica changes
+func OnRebalance(listener func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, listener)
+}
+
+func syncShard() {
+	if err := renewLease(); err != nil {
+		log.Errorf("unable to renew shard lease of %s, error: %v", identity, err)
+	}
+	leases, err := kubeclient.CoreV1().ConfigMaps(namespace).List(meta_v1.ListOptions{LabelSelector: leaseLabel + "=true"})
+	if err != nil {
+		log.Errorf("unable to list shard leases, error: %v", err)
+		return
+	}
+
+	now := time.Now()
+	live := liveMembers(leases.Items, now, 1)
+	if !containsString(live, identity) {
+		// this replica keeps its own shard if its lease could not be renewed
+		live = append(live, identity)
+		sort.Strings(live)
+	}
+
+	mu.Lock()
+	changed := !equalStrings(live, members)
+	members = live
+	index = sort.SearchStrings(live, identity)
+	rebalanceListeners := listeners
+	mu.Unlock()
+
+	if changed {
+		log.Infof("controller replica %s holds shard %d of %d", identity, index+1, len(live))
+		for _, listener := range rebalanceListeners {
+			listener()
+		}
+	}
+	if index == 0 {
+		deleteStaleLeases(leases.Items, now)
+	}
+}
+
+func renewLease() error {
+	name := leasePrefix + identity
+	annotations := map[string]string{
+		holderAnnotation:    identity,
+		renewTimeAnnotation: time.Now().Format(time.RFC3339),
+		durationAnnotation:  strconv.Itoa(int(leaseDuration.Seconds())),
+	}
+	lease, err := kubeclient.CoreV1().ConfigMaps(namespace).Get(name, meta_v1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = &api_v1.ConfigMap{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{leaseLabel: "true"},
+				Annotations: annotations,
+			},
+		}
+		_, err = kubeclient.CoreV1().ConfigMaps(namespace).Create(lease)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		lease.Annotations[key] = value
+	}
+	_, err = kubeclient.CoreV1().ConfigMaps(namespace).Update(lease)
+	return err
+}
+
+func deleteStaleLeases(leases []api_v1.ConfigMap, now time.Time) {
+	live := liveMembers(leases, now, staleLeaseDurations)
+	for _, lease := range leases {
+		holder := lease.Annotations[holderAnnotation]
+		if containsString(live, holder) || holder == identity {
+			continue
+		}
+		err := kubeclient.CoreV1().ConfigMaps(namespace).Delete(lease.Name, &meta_v1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			log.Errorf("unable to delete stale shard lease: (%s), error: %v", lease.Name, err)
+		}
+	}
+}
+
+// liveMembers returns the sorted holders of the leases which are renewed within `durations` lease durations
+func liveMembers(leases []api_v1.ConfigMap, now time.Time, durations int) []string {
+	live := []string{}
+	for _, lease := range leases {
+		holder := lease.Annotations[holderAnnotation]
+		renewTime, err := time.Parse(time.RFC3339, lease.Annotations[renewTimeAnnotation])
+		if holder == "" || err != nil {
+			continue
+		}
+		seconds, err := strconv.Atoi(lease.Annotations[durationAnnotation])
+		if err != nil {
+			continue
+		}
+		if renewTime.Add(time.Duration(durations*seconds) * time.Second).After(now) {
+			live = append(live, holder)
+		}
+	}
+	sort.Strings(live)
+	return live
+}
+
+// shardOf returns the shard of the namespace among `count` shards
+func shardOf(ns string, count int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(ns))
+	return int(hash.Sum32() % uint32(count))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sharding
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/test/utils"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestLiveMembers ...
+func TestLiveMembers(t *testing.T) {
+	now := time.Now()
+	leases := []api_v1.ConfigMap{
+		newLease("purser-controller-b", now.Add(-10*time.Second)),
+		newLease("purser-controller-a", now.Add(-20*time.Second)),
+		newLease("purser-controller-c", now.Add(-time.Minute)),
+	}
+	utils.Equals(t, []string{"purser-controller-a", "purser-controller-b"}, liveMembers(leases, now, 1))
+	utils.Equals(t, []string{"purser-controller-a", "purser-controller-b", "purser-controller-c"}, liveMembers(leases, now, 10))
+}
+
+// TestShardOf ...
+func TestShardOf(t *testing.T) {
+	for _, ns := range []string{"default", "kube-system", "payments", "checkout", "search", "monitoring"} {
+		shard := shardOf(ns, 3)
+		utils.Assert(t, shard >= 0 && shard < 3, "namespace: (%s) is out of shards, shard: %d", ns, shard)
+		utils.Equals(t, shard, shardOf(ns, 3))
+	}
+	utils.Equals(t, 0, shardOf("default", 1))
+}
+
+func newLease(holder string, renewTime time.Time) api_v1.ConfigMap {
+	return api_v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: leasePrefix + holder,
+			Annotations: map[string]string{
+				holderAnnotation:    holder,
+				renewTimeAnnotation: renewTime.Format(time.RFC3339),
+				durationAnnotation:  "30",
+			},
+		},
+	}
+}
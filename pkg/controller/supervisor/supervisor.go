@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	initialBackoff = time.Second
+	maxBackoff     = 5 * time.Minute
+	// the backoff is reset once a restarted subsystem runs this long without crashing
+	stableRun = 10 * time.Minute
+)
+
+// Status is the state and crash counter of a supervised subsystem or job
+type Status struct {
+	Name      string `json:"name"`
+	Running   bool   `json:"running"`
+	Crashes   int    `json:"crashes"`
+	LastCrash string `json:"lastCrash,omitempty"`
+	LastPanic string `json:"lastPanic,omitempty"`
+}
+
+var (
+	mu       sync.RWMutex
+	statuses = map[string]*Status{}
+)
+
+// Run runs the subsystem until it returns or ctx is done. A panic is recovered, logged with its stack trace and
+// the subsystem is restarted after a backoff which doubles with every crash, up to 5 minutes.
+func Run(ctx context.Context, name string, subsystem func(ctx context.Context)) {
+	backoff := initialBackoff
+	for {
+		started := time.Now()
+		setRunning(name, true)
+		crashed := runRecovered(name, func() { subsystem(ctx) })
+		setRunning(name, false)
+		if !crashed {
+			return
+		}
+
+		if time.Since(started) >= stableRun {
+			backoff = initialBackoff
+		}
+		log.Warnf("restarting %s in %v", name, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Recover wraps a periodic job so that a panic is recovered and counted, the job runs again on its next schedule
+func Recover(name string, job func()) func() {
+	return func() {
+		setRunning(name, true)
+		runRecovered(name, job)
+		setRunning(name, false)
+	}
+}
+
+// Statuses returns the status of all supervised subsystems and jobs ordered by name
+func Statuses() []Status {
+	mu.RLock()
+	defer mu.RUnlock()
+	result := []Status{}
+	for _, status := range statuses {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// runRecovered runs f and returns true if it panicked
+func runRecovered(name string, f func()) (crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			crashed = true
+			log.Errorf("%s crashed: %v\n%s", name, r, debug.Stack())
+			recordCrash(name, fmt.Sprint(r))
+		}
+	}()
+	f()
+	return false
+}
+
+func setRunning(name string, running bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	getStatus(name).Running = running
+}
+
+func recordCrash(name, panicValue string) {
+	mu.Lock()
+	defer mu.Unlock()
+	status := getStatus(name)
+	status.Crashes++
+	status.LastCrash = time.Now().Format(time.RFC3339)
+	status.LastPanic = panicValue
+}
+
+func getStatus(name string) *Status {
+	status, isPresent := statuses[name]
+	if !isPresent {
+		status = &Status{Name: name}
+		statuses[name] = status
+	}
+	return status
+}
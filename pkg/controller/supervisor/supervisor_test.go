@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package supervisor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+// TestRunRestartsAfterPanic ...
+func TestRunRestartsAfterPanic(t *testing.T) {
+	runs := 0
+	Run(context.Background(), "test-subsystem", func(ctx context.Context) {
+		runs++
+		if runs == 1 {
+			panic("watcher failed")
+		}
+	})
+	utils.Equals(t, 2, runs)
+
+	status := getStatus("test-subsystem")
+	utils.Equals(t, 1, status.Crashes)
+	utils.Equals(t, "watcher failed", status.LastPanic)
+	utils.Assert(t, !status.Running, "subsystem is running after it returned")
+}
+
+// TestRecover ...
+func TestRecover(t *testing.T) {
+	job := Recover("test-job", func() {
+		var values map[string]int
+		values["crash"] = 1
+	})
+	job()
+	job()
+	utils.Equals(t, 2, getStatus("test-job").Crashes)
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sustainability
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+var (
+	mu       sync.RWMutex
+	settings = Settings{PUE: DefaultPUE, GridIntensity: DefaultGridIntensity}
+)
+
+// Setup sets the power and carbon intensity factors, missing factors keep their default value
+func Setup(s Settings) {
+	if s.PUE == 0 {
+		s.PUE = DefaultPUE
+	}
+	if s.GridIntensity == 0 {
+		s.GridIntensity = DefaultGridIntensity
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	settings = s
+}
+
+// RetrieveFootprints returns the estimated energy and carbon footprint of every namespace (view namespace)
+// or group (view group) for the time window [from, to).
+func RetrieveFootprints(view string, from, to time.Time) ([]Footprint, error) {
+	nodes, err := query.RetrieveAliveNodes()
+	if err != nil {
+		return nil, err
+	}
+	factors := getClusterFactors(nodes)
+
+	var costs []query.ResourceCost
+	if view == query.Group {
+		costs, err = retrieveGroupCosts(from, to)
+	} else {
+		costs, err = query.RetrieveNamespaceCosts(query.All, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	footprints := make([]Footprint, len(costs))
+	for i, cost := range costs {
+		footprints[i] = factors.footprint(cost)
+	}
+	return footprints, nil
+}
+
+func retrieveGroupCosts(from, to time.Time) ([]query.ResourceCost, error) {
+	groups, err := query.RetrieveGroupsWithLabels()
+	if err != nil {
+		return nil, err
+	}
+	var costs []query.ResourceCost
+	for _, group := range groups {
+		cost, err := query.RetrieveGroupCost(group.Xid, group.GetLabelsMap(), from, to)
+		if err != nil {
+			return nil, err
+		}
+		costs = append(costs, cost)
+	}
+	return costs, nil
+}
+
+// getClusterFactors averages the power factors of the nodes' instance types weighted by their capacity and
+// the grid intensity of their regions weighted by their cpu capacity.
+func getClusterFactors(nodes []models.Node) clusterFactors {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	factors := clusterFactors{
+		cpuWatts:         DefaultCPUWatts,
+		memoryWattsPerGB: DefaultMemoryWattsPerGB,
+		gridIntensity:    settings.GridIntensity,
+	}
+	var totalCPU, totalMemory, cpuWatts, memoryWatts, intensity float64
+	for _, node := range nodes {
+		power, isKnown := settings.InstanceTypes[node.InstanceType]
+		if !isKnown {
+			power = PowerFactor{CPUWatts: DefaultCPUWatts, MemoryWattsPerGB: DefaultMemoryWattsPerGB}
+		}
+		gridIntensity, isKnown := settings.Regions[node.Region]
+		if !isKnown {
+			gridIntensity = settings.GridIntensity
+		}
+
+		cpu, memory := float64(node.CPUCapity), float64(node.MemoryCapacity)
+		totalCPU += cpu
+		totalMemory += memory
+		cpuWatts += cpu * power.CPUWatts
+		memoryWatts += memory * power.MemoryWattsPerGB
+		intensity += cpu * gridIntensity
+	}
+	if totalCPU > 0 {
+		factors.cpuWatts = cpuWatts / totalCPU
+		factors.gridIntensity = intensity / totalCPU
+	}
+	if totalMemory > 0 {
+		factors.memoryWattsPerGB = memoryWatts / totalMemory
+	}
+	factors.cpuWatts *= settings.PUE
+	factors.memoryWattsPerGB *= settings.PUE
+	return factors
+}
+
+func (factors clusterFactors) footprint(cost query.ResourceCost) Footprint {
+	cpuHours, memoryGBHours := float64(cost.CPU), float64(cost.Memory)
+	energy := (cpuHours*factors.cpuWatts + memoryGBHours*factors.memoryWattsPerGB) / 1000
+	return Footprint{
+		Name:          cost.Xid,
+		CPUHours:      cpuHours,
+		MemoryGBHours: memoryGBHours,
+		EnergyKWh:     energy,
+		CarbonKg:      energy * factors.gridIntensity / 1000,
+		Cost:          cost.CPUCost + cost.MemoryCost + cost.StorageCost + cost.GPUCost,
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package sustainability
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func isClose(expected, actual float64) bool {
+	return math.Abs(expected-actual) < 1e-9
+}
+
+func TestSetupDefaults(t *testing.T) {
+	defer Setup(Settings{})
+	Setup(Settings{Regions: map[string]float64{"eu-north-1": 50}})
+	utils.Equals(t, DefaultPUE, settings.PUE)
+	utils.Equals(t, float64(DefaultGridIntensity), settings.GridIntensity)
+
+	// the defaults are used for a cluster without nodes
+	factors := getClusterFactors(nil)
+	utils.Assert(t, isClose(DefaultCPUWatts*DefaultPUE, factors.cpuWatts), "cpu watts: %f", factors.cpuWatts)
+	utils.Assert(t, isClose(DefaultMemoryWattsPerGB*DefaultPUE, factors.memoryWattsPerGB), "memory watts: %f", factors.memoryWattsPerGB)
+	utils.Equals(t, float64(DefaultGridIntensity), factors.gridIntensity)
+}
+
+func TestFootprint(t *testing.T) {
+	defer Setup(Settings{})
+	Setup(Settings{PUE: 1.5, GridIntensity: 400, Regions: map[string]float64{"eu-north-1": 50},
+		InstanceTypes: map[string]PowerFactor{"m6g.large": {CPUWatts: 1, MemoryWattsPerGB: 0.2}}})
+	nodes := []models.Node{
+		{InstanceType: "m6g.large", Region: "eu-north-1", CPUCapity: 2, MemoryCapacity: 8},
+		// unknown instance types and regions have the default factors
+		{InstanceType: "n1-standard-2", Region: "us-east1", CPUCapity: 2, MemoryCapacity: 8},
+	}
+	factors := getClusterFactors(nodes)
+	utils.Assert(t, isClose((1+DefaultCPUWatts)/2*1.5, factors.cpuWatts), "cpu watts: %f", factors.cpuWatts)
+	utils.Assert(t, isClose((0.2+DefaultMemoryWattsPerGB)/2*1.5, factors.memoryWattsPerGB), "memory watts: %f", factors.memoryWattsPerGB)
+	utils.Assert(t, isClose(225, factors.gridIntensity), "grid intensity: %f", factors.gridIntensity)
+
+	footprint := factors.footprint(query.ResourceCost{Xid: "shop", CPU: 10, Memory: 20, CPUCost: 1, MemoryCost: 0.5,
+		StorageCost: 0.25, GPUCost: 2})
+	utils.Equals(t, "shop", footprint.Name)
+	utils.Equals(t, 10.0, footprint.CPUHours)
+	energy := (10*factors.cpuWatts + 20*factors.memoryWattsPerGB) / 1000
+	utils.Assert(t, isClose(energy, footprint.EnergyKWh), "energy: %f", footprint.EnergyKWh)
+	utils.Assert(t, isClose(energy*225/1000, footprint.CarbonKg), "carbon: %f", footprint.CarbonKg)
+	utils.Equals(t, 3.75, footprint.Cost)
+}
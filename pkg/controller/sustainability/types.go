@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package sustainability
+
+// Default power and carbon intensity factors. Power factors are the average of the min and max watts per vcpu
+// and the watts per GB of memory of public cloud servers, grid intensity is the world average.
+const (
+	DefaultCPUWatts         = 2.12
+	DefaultMemoryWattsPerGB = 0.392
+	DefaultPUE              = 1.135
+	DefaultGridIntensity    = 475
+)
+
+// Settings of the power and carbon intensity factors.
+// GridIntensity and Regions are in gCO2e per kWh, Regions override GridIntensity for nodes of the region.
+type Settings struct {
+	PUE           float64                `json:"pue,omitempty"`
+	GridIntensity float64                `json:"gridIntensity,omitempty"`
+	Regions       map[string]float64     `json:"regions,omitempty"`
+	InstanceTypes map[string]PowerFactor `json:"instanceTypes,omitempty"`
+}
+
+// PowerFactor is the average power drawn by a vcpu and by a GB of memory of an instance type, in watts.
+type PowerFactor struct {
+	CPUWatts         float64 `json:"cpuWatts"`
+	MemoryWattsPerGB float64 `json:"memoryWattsPerGB"`
+}
+
+// Footprint is the estimated energy (kWh) and carbon (kgCO2e) of a namespace or group in a time window
+// along with its usage (in unit hours) and cost.
+type Footprint struct {
+	Name          string  `json:"name"`
+	CPUHours      float64 `json:"cpuHours"`
+	MemoryGBHours float64 `json:"memoryGBHours"`
+	EnergyKWh     float64 `json:"energyKWh"`
+	CarbonKg      float64 `json:"carbonKg"`
+	Cost          float64 `json:"cost"`
+}
+
+// clusterFactors are the power and carbon intensity factors of the cluster, averaged over its nodes
+type clusterFactors struct {
+	cpuWatts         float64
+	memoryWattsPerGB float64
+	gridIntensity    float64
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+import (
+	"net/http"
+	"net/url"
+)
+
+const gitHubAPI = "https://api.github.com"
+
+// gitHubTracker files GitHub issues
+type gitHubTracker struct {
+	settings GitHubSettings
+}
+
+// Name returns the tracker name
+func (g *gitHubTracker) Name() string {
+	return "github"
+}
+
+// IsFiled searches the issues of the repository for the opportunity key
+func (g *gitHubTracker) IsFiled(opportunity Opportunity) (bool, error) {
+	headers, err := g.headers()
+	if err != nil {
+		return false, err
+	}
+	search := `repo:` + g.settings.Repository + ` is:issue in:body "purser-opportunity: ` + opportunity.Key + `"`
+	result := struct {
+		TotalCount int `json:"total_count"`
+	}{}
+	err = doJSON(http.MethodGet, gitHubAPI+"/search/issues?q="+url.QueryEscape(search), headers, nil, &result)
+	return result.TotalCount > 0, err
+}
+
+// File opens an issue assigned to the team owning the namespace of the opportunity
+func (g *gitHubTracker) File(opportunity Opportunity) error {
+	headers, err := g.headers()
+	if err != nil {
+		return err
+	}
+	issue := map[string]interface{}{
+		"title":  opportunity.Title,
+		"body":   description(opportunity),
+		"labels": append([]string{defaultLabel, opportunity.Kind}, g.settings.Labels...),
+	}
+	if assignees, isOwned := g.settings.Assignees[opportunity.Namespace]; isOwned {
+		issue["assignees"] = assignees
+	}
+	return doJSON(http.MethodPost, gitHubAPI+"/repos/"+g.settings.Repository+"/issues", headers, issue, nil)
+}
+
+func (g *gitHubTracker) headers() (map[string]string, error) {
+	token, err := readToken(g.settings.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"Authorization": "token " + token, "Accept": "application/vnd.github.v3+json"}, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const defaultJiraIssueType = "Task"
+
+// invalidLabelCharacters are replaced in the opportunity key to make it a valid Jira label
+var invalidLabelCharacters = regexp.MustCompile(`[^A-Za-z0-9_.:-]`)
+
+// jiraTracker files Jira issues, each issue is labeled with its opportunity key
+type jiraTracker struct {
+	settings JiraSettings
+}
+
+// Name returns the tracker name
+func (j *jiraTracker) Name() string {
+	return "jira"
+}
+
+// IsFiled searches the issues of the project for the label of the opportunity
+func (j *jiraTracker) IsFiled(opportunity Opportunity) (bool, error) {
+	headers, err := j.headers()
+	if err != nil {
+		return false, err
+	}
+	jql := `project = "` + j.settings.Project + `" AND labels = "` + opportunityLabel(opportunity) + `"`
+	result := struct {
+		Total int `json:"total"`
+	}{}
+	err = doJSON(http.MethodGet, j.apiURL()+"/search?maxResults=0&jql="+url.QueryEscape(jql), headers, nil, &result)
+	return result.Total > 0, err
+}
+
+// File opens an issue assigned to the owner of the namespace of the opportunity
+func (j *jiraTracker) File(opportunity Opportunity) error {
+	headers, err := j.headers()
+	if err != nil {
+		return err
+	}
+	issueType := j.settings.IssueType
+	if issueType == "" {
+		issueType = defaultJiraIssueType
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": j.settings.Project},
+		"summary":     opportunity.Title,
+		"description": description(opportunity),
+		"issuetype":   map[string]string{"name": issueType},
+		"labels":      []string{defaultLabel, opportunity.Kind, opportunityLabel(opportunity)},
+	}
+	if assignee, isOwned := j.settings.Assignees[opportunity.Namespace]; isOwned {
+		fields["assignee"] = map[string]string{"accountId": assignee}
+	}
+	return doJSON(http.MethodPost, j.apiURL()+"/issue", headers, map[string]interface{}{"fields": fields}, nil)
+}
+
+func (j *jiraTracker) apiURL() string {
+	return strings.TrimSuffix(j.settings.URL, "/") + "/rest/api/2"
+}
+
+func (j *jiraTracker) headers() (map[string]string, error) {
+	token, err := readToken(j.settings.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	credentials := base64.StdEncoding.EncodeToString([]byte(j.settings.User + ":" + token))
+	return map[string]string{"Authorization": "Basic " + credentials}, nil
+}
+
+func opportunityLabel(opportunity Opportunity) string {
+	return invalidLabelCharacters.ReplaceAllString(defaultLabel+"-"+opportunity.Key, "-")
+}
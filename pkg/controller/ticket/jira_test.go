@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestOpportunityLabel(t *testing.T) {
+	utils.Equals(t, "purser-idle-pvc-ci:data", opportunityLabel(Opportunity{Key: "idle-pvc-ci:data"}))
+	utils.Equals(t, "purser-arm-migration-shop:web-v2-eu", opportunityLabel(Opportunity{Key: "arm-migration-shop:web v2/eu"}))
+}
+
+func TestJiraTracker(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "jira")
+	utils.Ok(t, err)
+	defer func() {
+		_ = os.Remove(tokenFile.Name())
+	}()
+	_, err = tokenFile.WriteString("secret\n")
+	utils.Ok(t, err)
+	utils.Ok(t, tokenFile.Close())
+
+	var jql string
+	var created map[string]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, isBasic := r.BasicAuth()
+		if !isBasic || user != "bot@example.com" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/2/search":
+			jql = r.URL.Query().Get("jql")
+			_, _ = w.Write([]byte(`{"total": 0}`))
+		case "/rest/api/2/issue":
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"key": "COST-1"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracker := &jiraTracker{settings: JiraSettings{URL: server.URL + "/", Project: "COST", User: "bot@example.com",
+		TokenFile: tokenFile.Name(), Assignees: map[string]string{"ci": "5b10a2844c20165700ede21g"}}}
+	opportunity := Opportunity{Key: "idle-pvc-ci:data", Kind: IdlePvc, Namespace: "ci", Title: "Delete idle volume claim"}
+	isFiled, err := tracker.IsFiled(opportunity)
+	utils.Ok(t, err)
+	utils.Assert(t, !isFiled, "opportunity filed before it was created")
+	utils.Equals(t, `project = "COST" AND labels = "purser-idle-pvc-ci:data"`, jql)
+
+	utils.Ok(t, tracker.File(opportunity))
+	fields := created["fields"]
+	utils.Equals(t, "Delete idle volume claim", fields["summary"])
+	utils.Equals(t, map[string]interface{}{"name": defaultJiraIssueType}, fields["issuetype"])
+	utils.Equals(t, map[string]interface{}{"accountId": "5b10a2844c20165700ede21g"}, fields["assignee"])
+	utils.Equals(t, []interface{}{"purser", IdlePvc, "purser-idle-pvc-ci:data"}, fields["labels"])
+
+	tracker.settings.User = "someone@example.com"
+	_, err = tracker.IsFiled(opportunity)
+	utils.Assert(t, err != nil, "unauthorized search succeeded")
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/vmware/purser/pkg/controller/capacity"
+)
+
+const (
+	defaultLabel = "purser"
+	httpTimeout  = 30 * time.Second
+)
+
+var (
+	mu                sync.Mutex
+	trackers          []Tracker
+	minMonthlySavings float64
+	// filed caches the keys of the opportunities known to be filed, tracker search indexes may lag behind
+	filed = make(map[string]bool)
+)
+
+// Setup creates the trackers which are configured in the settings
+func Setup(settings Settings) {
+	mu.Lock()
+	defer mu.Unlock()
+	minMonthlySavings = settings.MinMonthlySavings
+	trackers = nil
+	if settings.GitHub != nil {
+		trackers = append(trackers, &gitHubTracker{settings: *settings.GitHub})
+	}
+	if settings.Jira != nil {
+		trackers = append(trackers, &jiraTracker{settings: *settings.Jira})
+	}
+}
+
+// FileSavingsTickets opens a ticket in every configured tracker for the savings opportunities which are
+// worth at least the minimum monthly savings and are not filed yet.
+func FileSavingsTickets() {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(trackers) == 0 {
+		return
+	}
+
+	savings, err := capacity.RetrieveSavings()
+	if err != nil {
+		log.Errorf("unable to retrieve savings opportunities, error: %v", err)
+		return
+	}
+	for _, opportunity := range getOpportunities(savings) {
+		if opportunity.MonthlySavings < minMonthlySavings {
+			continue
+		}
+		for _, tracker := range trackers {
+			if err := fileOnce(tracker, opportunity); err != nil {
+				log.Errorf("unable to file ticket: (%s) in %s, error: %v", opportunity.Key, tracker.Name(), err)
+			}
+		}
+	}
+}
+
+func fileOnce(tracker Tracker, opportunity Opportunity) error {
+	cacheKey := tracker.Name() + "/" + opportunity.Key
+	if filed[cacheKey] {
+		return nil
+	}
+	isFiled, err := tracker.IsFiled(opportunity)
+	if err != nil {
+		return err
+	}
+	if !isFiled {
+		if err = tracker.File(opportunity); err != nil {
+			return err
+		}
+		log.Infof("ticket filed in %s for savings opportunity: (%s)", tracker.Name(), opportunity.Key)
+	}
+	filed[cacheKey] = true
+	return nil
+}
+
+func getOpportunities(savings capacity.Savings) []Opportunity {
+	var opportunities []Opportunity
+	if savings.Arm != nil {
+		for _, workload := range savings.Arm.Workloads {
+			opportunities = append(opportunities, Opportunity{
+				Key:       ArmMigration + "-" + workload.Workload,
+				Kind:      ArmMigration,
+				Namespace: workload.Namespace,
+				Title:     fmt.Sprintf("Move %s to %s nodes to save %.2f per month", workload.Workload, savings.Arm.NodeType, workload.MonthlySavings),
+				Description: fmt.Sprintf("All images of %s (%s) have an arm64 variant. Running it on %s nodes would cost %.2f instead of %.2f per month.",
+					workload.Workload, strings.Join(workload.Images, ", "), savings.Arm.NodeType, workload.NewMonthlyCost, workload.MonthlyCost),
+				MonthlySavings: workload.MonthlySavings,
+			})
+		}
+	}
+	for _, pvc := range savings.IdlePvcs {
+		opportunities = append(opportunities, Opportunity{
+			Key:       IdlePvc + "-" + pvc.Namespace + ":" + pvc.Pvc,
+			Kind:      IdlePvc,
+			Namespace: pvc.Namespace,
+			Title:     fmt.Sprintf("Delete idle volume claim %s/%s to save %.2f per month", pvc.Namespace, pvc.Pvc, pvc.MonthlySavings),
+			Description: fmt.Sprintf("Persistent volume claim %s in namespace %s (%.0f GB) is not mounted by any pod.",
+				pvc.Pvc, pvc.Namespace, pvc.StorageCapacity),
+			MonthlySavings: pvc.MonthlySavings,
+		})
+	}
+	return opportunities
+}
+
+// description returns the ticket body, it carries the opportunity key which is used to find filed tickets
+func description(opportunity Opportunity) string {
+	return opportunity.Description + "\n\nEstimated monthly savings: " + fmt.Sprintf("%.2f", opportunity.MonthlySavings) +
+		"\n\npurser-opportunity: " + opportunity.Key
+}
+
+// doJSON sends the request body in json format and decodes the json response into out if it is not nil
+func doJSON(method, url string, headers map[string]string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("request to %s failed with status: %s, response: %s", url, resp.Status, string(response))
+	}
+	if out != nil {
+		return json.Unmarshal(response, out)
+	}
+	return nil
+}
+
+func readToken(tokenFile string) (string, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
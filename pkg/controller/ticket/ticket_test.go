@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/vmware/purser/pkg/controller/capacity"
+	"github.com/vmware/purser/test/utils"
+)
+
+type recordingTracker struct {
+	existing map[string]bool
+	searches int
+	filed    []Opportunity
+	fileErr  error
+}
+
+func (r *recordingTracker) Name() string {
+	return "recording"
+}
+
+func (r *recordingTracker) IsFiled(opportunity Opportunity) (bool, error) {
+	r.searches++
+	return r.existing[opportunity.Key], nil
+}
+
+func (r *recordingTracker) File(opportunity Opportunity) error {
+	if r.fileErr != nil {
+		return r.fileErr
+	}
+	r.filed = append(r.filed, opportunity)
+	return nil
+}
+
+func TestFileOnce(t *testing.T) {
+	defer func() {
+		filed = make(map[string]bool)
+	}()
+	tracker := &recordingTracker{existing: map[string]bool{"idle-pvc-shop:old": true}, fileErr: errors.New("unavailable")}
+	opportunity := Opportunity{Key: "idle-pvc-shop:data"}
+
+	// a failed filing is retried on the next run
+	utils.Assert(t, fileOnce(tracker, opportunity) != nil, "failed filing reported as filed")
+	tracker.fileErr = nil
+	utils.Ok(t, fileOnce(tracker, opportunity))
+	utils.Equals(t, []Opportunity{opportunity}, tracker.filed)
+
+	// the filed opportunity is not searched again
+	utils.Ok(t, fileOnce(tracker, opportunity))
+	utils.Equals(t, 2, tracker.searches)
+
+	// opportunities filed by a previous run are not filed again
+	utils.Ok(t, fileOnce(tracker, Opportunity{Key: "idle-pvc-shop:old"}))
+	utils.Equals(t, 1, len(tracker.filed))
+}
+
+func TestGetOpportunities(t *testing.T) {
+	opportunities := getOpportunities(capacity.Savings{
+		Arm: &capacity.ArmSavings{NodeType: "m6g.large", Workloads: []capacity.WorkloadSaving{{Workload: "shop:web",
+			Namespace: "shop", Images: []string{"nginx:1.15", "redis:5"}, MonthlyCost: 100, NewMonthlyCost: 64,
+			MonthlySavings: 36}}},
+		IdlePvcs: []capacity.IdlePvc{{Pvc: "data", Namespace: "ci", StorageCapacity: 50, MonthlySavings: 5}},
+	})
+	utils.Equals(t, 2, len(opportunities))
+	arm := opportunities[0]
+	utils.Equals(t, "arm-migration-shop:web", arm.Key)
+	utils.Equals(t, "shop", arm.Namespace)
+	utils.Equals(t, "Move shop:web to m6g.large nodes to save 36.00 per month", arm.Title)
+	utils.Assert(t, strings.Contains(arm.Description, "(nginx:1.15, redis:5)"), "images not described: %s", arm.Description)
+	utils.Equals(t, Opportunity{Key: "idle-pvc-ci:data", Kind: IdlePvc, Namespace: "ci",
+		Title:          "Delete idle volume claim ci/data to save 5.00 per month",
+		Description:    "Persistent volume claim data in namespace ci (50 GB) is not mounted by any pod.",
+		MonthlySavings: 5}, opportunities[1])
+
+	utils.Equals(t, 0, len(getOpportunities(capacity.Savings{})))
+}
+
+func TestDescription(t *testing.T) {
+	body := description(Opportunity{Key: "idle-pvc-ci:data", Description: "Claim is idle.", MonthlySavings: 5})
+	utils.Equals(t, "Claim is idle.\n\nEstimated monthly savings: 5.00\n\npurser-opportunity: idle-pvc-ci:data", body)
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ticket
+
+// Kinds of savings opportunities
+const (
+	ArmMigration = "arm-migration"
+	IdlePvc      = "idle-pvc"
+)
+
+// Opportunity is a savings recommendation which can be tracked as a ticket. Key identifies the
+// opportunity across runs so that it is filed only once, Namespace is the scope of the owning team.
+type Opportunity struct {
+	Key            string
+	Kind           string
+	Namespace      string
+	Title          string
+	Description    string
+	MonthlySavings float64
+}
+
+// Tracker files tickets in an issue tracker
+type Tracker interface {
+	Name() string
+	// IsFiled returns true if a ticket (open or closed) already exists for the opportunity
+	IsFiled(opportunity Opportunity) (bool, error)
+	File(opportunity Opportunity) error
+}
+
+// Settings of the ticket creation. Only opportunities saving at least MinMonthlySavings are filed.
+type Settings struct {
+	MinMonthlySavings float64         `json:"minMonthlySavings,omitempty"`
+	GitHub            *GitHubSettings `json:"github,omitempty"`
+	Jira              *JiraSettings   `json:"jira,omitempty"`
+}
+
+// GitHubSettings of the GitHub issue tracker. Repository is owner/name, the token is read from TokenFile.
+// Assignees map namespaces to the GitHub logins of their owning team.
+type GitHubSettings struct {
+	Repository string              `json:"repository"`
+	TokenFile  string              `json:"tokenFile"`
+	Labels     []string            `json:"labels,omitempty"`
+	Assignees  map[string][]string `json:"assignees,omitempty"`
+}
+
+// JiraSettings of the Jira issue tracker. The API token of User is read from TokenFile.
+// Assignees map namespaces to the Jira account id of their owning team's assignee.
+type JiraSettings struct {
+	URL       string            `json:"url"`
+	Project   string            `json:"project"`
+	IssueType string            `json:"issueType,omitempty"`
+	User      string            `json:"user"`
+	TokenFile string            `json:"tokenFile"`
+	Assignees map[string]string `json:"assignees,omitempty"`
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tsdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+)
+
+const (
+	defaultInterval = "5m"
+	measurement     = "purser_cost_rate"
+	httpTimeout     = 30 * time.Second
+)
+
+var (
+	mu      sync.Mutex
+	cluster string
+	writers []Writer
+)
+
+// tagEscaper escapes the characters which delimit tags in the line protocol
+var tagEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+// Setup creates the writers which are configured in the settings and returns the interval of the pushes.
+func Setup(settings Settings) string {
+	mu.Lock()
+	defer mu.Unlock()
+	cluster = settings.Cluster
+	writers = nil
+	if settings.InfluxDB != nil {
+		writers = append(writers, &influxDBWriter{settings: *settings.InfluxDB})
+	}
+	if settings.VictoriaMetrics != nil {
+		writers = append(writers, &victoriaMetricsWriter{settings: *settings.VictoriaMetrics})
+	}
+	if settings.Interval == "" {
+		return defaultInterval
+	}
+	return settings.Interval
+}
+
+// Push writes the current cost rate of the cluster and of every namespace to the configured databases.
+// It is scheduled to run on the interval given in the settings.
+func Push() {
+	mu.Lock()
+	defer mu.Unlock()
+	if len(writers) == 0 {
+		return
+	}
+
+	rates, err := query.RetrieveCostRates(query.Namespace, 0)
+	if err != nil {
+		log.Errorf("unable to retrieve cost rates to push: %v", err)
+		return
+	}
+	at, err := time.Parse(time.RFC3339, rates.Time)
+	if err != nil {
+		at = time.Now()
+	}
+	lines := encodeLines(rates, at)
+	for _, writer := range writers {
+		if err := writer.Write(lines); err != nil {
+			log.Errorf("push of cost rates to %s failed: %v", writer.Name(), err)
+			continue
+		}
+		log.Debugf("pushed cost rates of %d namespaces to %s", len(rates.Scopes), writer.Name())
+	}
+}
+
+// encodeLines returns the points of the cluster and namespace rates in line protocol with a precision in seconds
+func encodeLines(rates query.CostRates, at time.Time) []byte {
+	var buf bytes.Buffer
+	writeLine(&buf, rates.Cluster, "cluster", rates.PriceVersion, at)
+	for _, rate := range rates.Scopes {
+		writeLine(&buf, rate, "namespace", rates.PriceVersion, at)
+	}
+	return buf.Bytes()
+}
+
+func writeLine(buf *bytes.Buffer, rate query.CostRate, scope, priceVersion string, at time.Time) {
+	buf.WriteString(measurement)
+	if cluster != "" {
+		buf.WriteString(",cluster=" + tagEscaper.Replace(cluster))
+	}
+	buf.WriteString(",scope=" + scope)
+	if rate.Xid != "" {
+		buf.WriteString(",namespace=" + tagEscaper.Replace(rate.Xid))
+	}
+
+	fields := []struct {
+		name  string
+		value float64
+	}{
+		{"cpu", float64(rate.CPU)},
+		{"memory_gb", float64(rate.Memory)},
+		{"storage_gb", float64(rate.Storage)},
+		{"gpu", rate.GPU},
+		{"cpu_cost_per_hour", rate.CPUCostPerHour},
+		{"memory_cost_per_hour", rate.MemoryCostPerHour},
+		{"storage_cost_per_hour", rate.StorageCostPerHour},
+		{"gpu_cost_per_hour", rate.GPUCostPerHour},
+		{"snapshot_cost_per_hour", rate.SnapshotCostPerHour},
+		{"load_balancer_cost_per_hour", rate.LoadBalancerCostPerHour},
+		{"cost_per_hour", rate.CostPerHour},
+	}
+	for i, field := range fields {
+		separator := ","
+		if i == 0 {
+			separator = " "
+		}
+		buf.WriteString(separator + field.name + "=" + strconv.FormatFloat(field.value, 'f', -1, 64))
+	}
+	buf.WriteString(",price_version=" + strconv.Quote(priceVersion))
+	buf.WriteString(" " + strconv.FormatInt(at.Unix(), 10) + "\n")
+}
+
+// post sends the lines to the url and returns an error if the database doesn't accept them
+func post(url, authorization string, lines []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	client := http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			log.Error(closeErr)
+		}
+	}()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("write to %s failed with status: %s, response: %s", url, resp.Status, string(body))
+	}
+	return nil
+}
+
+func readToken(tokenFile string) (string, error) {
+	if tokenFile == "" {
+		return "", nil
+	}
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models/query"
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestEncodeLines(t *testing.T) {
+	cluster = "prod east"
+	defer func() { cluster = "" }()
+
+	rates := query.CostRates{
+		PriceVersion: "v2",
+		Cluster:      query.CostRate{CPU: 2, Memory: 4, CPUCostPerHour: 0.05, MemoryCostPerHour: 0.02, CostPerHour: 0.07},
+		Scopes: []query.CostRate{
+			{Xid: "team=a", CPU: 2, Memory: 4, CPUCostPerHour: 0.05, MemoryCostPerHour: 0.02, CostPerHour: 0.07},
+		},
+	}
+	at := time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC)
+	utils.Equals(t, `purser_cost_rate,cluster=prod\ east,scope=cluster cpu=2,memory_gb=4,storage_gb=0,gpu=0,`+
+		`cpu_cost_per_hour=0.05,memory_cost_per_hour=0.02,storage_cost_per_hour=0,gpu_cost_per_hour=0,`+
+		`snapshot_cost_per_hour=0,load_balancer_cost_per_hour=0,cost_per_hour=0.07,price_version="v2" 1525168800
+purser_cost_rate,cluster=prod\ east,scope=namespace,namespace=team\=a cpu=2,memory_gb=4,storage_gb=0,gpu=0,`+
+		`cpu_cost_per_hour=0.05,memory_cost_per_hour=0.02,storage_cost_per_hour=0,gpu_cost_per_hour=0,`+
+		`snapshot_cost_per_hour=0,load_balancer_cost_per_hour=0,cost_per_hour=0.07,price_version="v2" 1525168800
+`, string(encodeLines(rates, at)))
+}
+
+func TestInfluxDBWriteURL(t *testing.T) {
+	v2 := &influxDBWriter{settings: InfluxDBSettings{URL: "http://influx:8086/", Org: "acme", Bucket: "costs"}}
+	utils.Equals(t, "http://influx:8086/api/v2/write?bucket=costs&org=acme&precision=s", v2.writeURL())
+
+	v1 := &influxDBWriter{settings: InfluxDBSettings{URL: "http://influx:8086", Database: "purser"}}
+	utils.Equals(t, "http://influx:8086/write?db=purser&precision=s", v1.writeURL())
+}
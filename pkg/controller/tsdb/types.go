@@ -0,0 +1,50 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tsdb
+
+// Settings of the time series databases to which the cost rates are pushed. Databases without settings are disabled.
+type Settings struct {
+	Cluster string `json:"cluster,omitempty"`
+	// Interval between two pushes (ex: 1m), 5m by default
+	Interval        string                   `json:"interval,omitempty"`
+	InfluxDB        *InfluxDBSettings        `json:"influxDB,omitempty"`
+	VictoriaMetrics *VictoriaMetricsSettings `json:"victoriaMetrics,omitempty"`
+}
+
+// InfluxDBSettings locate the InfluxDB bucket. Org and Bucket are used with InfluxDB 2 (/api/v2/write), Database
+// with InfluxDB 1 (/write). TokenFile holds the API token (or username:password for InfluxDB 1).
+type InfluxDBSettings struct {
+	URL       string `json:"url"`
+	Org       string `json:"org,omitempty"`
+	Bucket    string `json:"bucket,omitempty"`
+	Database  string `json:"database,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// VictoriaMetricsSettings locate the VictoriaMetrics server (or vminsert URL of a cluster, ex:
+// http://vminsert:8480/insert/0/influx). TokenFile holds a bearer token if the server is behind an auth proxy.
+type VictoriaMetricsSettings struct {
+	URL       string `json:"url"`
+	TokenFile string `json:"tokenFile,omitempty"`
+}
+
+// Writer writes points in InfluxDB line protocol to a time series database.
+type Writer interface {
+	Name() string
+	Write(lines []byte) error
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tsdb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// influxDBWriter writes to the InfluxDB 2 write API when a bucket is given, to the InfluxDB 1 write API otherwise
+type influxDBWriter struct {
+	settings InfluxDBSettings
+}
+
+// Name returns the writer name
+func (w *influxDBWriter) Name() string {
+	return "influxdb " + w.settings.URL
+}
+
+// Write sends the lines with a precision in seconds
+func (w *influxDBWriter) Write(lines []byte) error {
+	token, err := readToken(w.settings.TokenFile)
+	if err != nil {
+		return err
+	}
+	authorization := ""
+	if token != "" {
+		authorization = "Token " + token
+	}
+	return post(w.writeURL(), authorization, lines)
+}
+
+func (w *influxDBWriter) writeURL() string {
+	params := url.Values{}
+	params.Set("precision", "s")
+	if w.settings.Bucket != "" {
+		params.Set("org", w.settings.Org)
+		params.Set("bucket", w.settings.Bucket)
+		return strings.TrimSuffix(w.settings.URL, "/") + "/api/v2/write?" + params.Encode()
+	}
+	params.Set("db", w.settings.Database)
+	return strings.TrimSuffix(w.settings.URL, "/") + "/write?" + params.Encode()
+}
+
+// victoriaMetricsWriter writes to the InfluxDB compatible write API of VictoriaMetrics
+type victoriaMetricsWriter struct {
+	settings VictoriaMetricsSettings
+}
+
+// Name returns the writer name
+func (w *victoriaMetricsWriter) Name() string {
+	return "victoriametrics " + w.settings.URL
+}
+
+// Write sends the lines with a precision in seconds
+func (w *victoriaMetricsWriter) Write(lines []byte) error {
+	token, err := readToken(w.settings.TokenFile)
+	if err != nil {
+		return err
+	}
+	authorization := ""
+	if token != "" {
+		authorization = "Bearer " + token
+	}
+	return post(strings.TrimSuffix(w.settings.URL, "/")+"/write?precision=s", authorization, lines)
+}
@@ -45,6 +45,7 @@ type Resource struct {
 	Job                   bool `json:"job"`
 	DaemonSet             bool `json:"daemonset"`
 	Namespace             bool `json:"namespace"`
+	Event                 bool `json:"event"`
 	Group                 bool `json:"groups.vmware.purser.com"`
 	Subscriber            bool `json:"subscribers.vmware.purser.com"`
 }
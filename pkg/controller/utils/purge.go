@@ -20,47 +20,65 @@ package utils
 import (
 	"encoding/hex"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 )
 
+// TCPConnection is a connection of a process read from /proc/<pid>/net/tcp or /proc/<pid>/net/tcp6. IPs are in
+// their canonical text form, dotted decimal for IPv4 and IPv4-mapped IPv6 addresses.
+type TCPConnection struct {
+	LocalIP    string
+	LocalPort  int
+	RemoteIP   string
+	RemotePort int
+}
+
 // PurgeTCPData handles IP conversion from Hex to Dec and cleans up data to contain only
 // inter pod address information.
-func PurgeTCPData(data string) []string {
-	var tcpDump []string
-
-	tcpDumpHex := getTCPDumpHexFromData(data)
-	for _, address := range tcpDumpHex {
-		localIP, localPort := hexToDecIP(address[6:14]), address[15:19]
-		remoteIP, remotePort := hexToDecIP(address[20:28]), address[29:33]
-
-		if isLocalHost(localIP, remoteIP) {
-			continue
-		}
-
-		addressMapping := localIP + ":" + localPort + ":" + remoteIP + ":" + remotePort
-		tcpDump = append(tcpDump, addressMapping)
-	}
-	return tcpDump
+func PurgeTCPData(data string) []TCPConnection {
+	return purgeTCPDump(data)
 }
 
-// PurgeTCP6Data handles IP conversion from Hex to Dec and cleans up data to contain only
+// PurgeTCP6Data handles IPv6 conversion from Hex and cleans up data to contain only
 // inter pod address information.
-func PurgeTCP6Data(data string) []string {
-	var tcpDump []string
+func PurgeTCP6Data(data string) []TCPConnection {
+	return purgeTCPDump(data)
+}
 
-	tcpDumpHex := getTCPDumpHexFromData(data)
-	for _, address := range tcpDumpHex {
-		localIP, localPort := hexToDecIP(address[30:38]), address[39:43]
-		remoteIP, remotePort := hexToDecIP(address[68:76]), address[77:81]
+// purgeTCPDump parses the lines of a tcp or tcp6 table, whose local and remote addresses are the second and
+// third fields in the form HEXIP:HEXPORT
+func purgeTCPDump(data string) []TCPConnection {
+	var tcpDump []TCPConnection
+
+	for _, line := range getTCPDumpHexFromData(data) {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		localIP, localPort, err := hexToAddress(fields[1])
+		if err != nil {
+			logrus.Warnf("failed to decode local address %q: %v", fields[1], err)
+			continue
+		}
+		remoteIP, remotePort, err := hexToAddress(fields[2])
+		if err != nil {
+			logrus.Warnf("failed to decode remote address %q: %v", fields[2], err)
+			continue
+		}
 
 		if isLocalHost(localIP, remoteIP) {
 			continue
 		}
 
-		addressMapping := localIP + ":" + localPort + ":" + remoteIP + ":" + remotePort
-		tcpDump = append(tcpDump, addressMapping)
+		tcpDump = append(tcpDump, TCPConnection{
+			LocalIP:    localIP.String(),
+			LocalPort:  localPort,
+			RemoteIP:   remoteIP.String(),
+			RemotePort: remotePort,
+		})
 	}
 	return tcpDump
 }
@@ -76,14 +94,42 @@ func getTCPDumpHexFromData(data string) []string {
 	return tcpDumpHex
 }
 
-func hexToDecIP(hexIP string) string {
+// hexToAddress decodes an address of the tcp table, ex: 0100007F:1F90 is 127.0.0.1:8080
+func hexToAddress(hexAddress string) (net.IP, int, error) {
+	parts := strings.Split(hexAddress, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("missing port in address: %s", hexAddress)
+	}
+	ip, err := hexToIP(parts[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+	return ip, int(port), nil
+}
+
+// hexToIP decodes an IPv4 (8 hex digits) or IPv6 (32 hex digits) address of the tcp table. The kernel prints the
+// address as 32 bit words in host (little endian) byte order, so the bytes of every word are reversed.
+func hexToIP(hexIP string) (net.IP, error) {
 	decBytes, err := hex.DecodeString(hexIP)
 	if err != nil {
-		logrus.Warnf("failed to decode string to hex %v", err)
+		return nil, err
+	}
+	if len(decBytes) != net.IPv4len && len(decBytes) != net.IPv6len {
+		return nil, fmt.Errorf("invalid length of address: %s", hexIP)
+	}
+	ip := make(net.IP, len(decBytes))
+	for word := 0; word < len(decBytes); word += 4 {
+		for i := 0; i < 4; i++ {
+			ip[word+i] = decBytes[word+3-i]
+		}
 	}
-	return fmt.Sprintf("%v.%v.%v.%v", decBytes[3], decBytes[2], decBytes[1], decBytes[0])
+	return ip, nil
 }
 
-func isLocalHost(localIP, remoteIP string) bool {
-	return strings.Compare(localIP, "0.0.0.0") == 0 || strings.Compare(localIP, "127.0.0.1") == 0 || strings.Compare(remoteIP, "0.0.0.0") == 0
+func isLocalHost(localIP, remoteIP net.IP) bool {
+	return localIP.IsUnspecified() || localIP.IsLoopback() || remoteIP.IsUnspecified()
 }
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPurgeTCPData(t *testing.T) {
+	tcp := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0500F40A:1F90 0900F40A:C350 01 00000000:00000000 00:00000000 00000000     0        0 12346 1 0000000000000000 20 4 30 10 -1
+`
+	utils.Equals(t, []TCPConnection{{LocalIP: "10.244.0.5", LocalPort: 8080, RemoteIP: "10.244.0.9", RemotePort: 50000}}, PurgeTCPData(tcp))
+}
+
+func TestPurgeTCP6Data(t *testing.T) {
+	tcp6 := `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000001000000:1F90 00000000000000000000000001000000:C350 01 00000000:00000000 00:00000000 00000000     0        0 1 1 0000000000000000 20 4 30 10 -1
+   1: 100000FD000044020000000005000000:1F90 100000FD000044020000000009000000:C350 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0000000000000000 20 4 30 10 -1
+   2: 0000000000000000FFFF00000500F40A:1F90 0000000000000000FFFF00000900F40A:C351 01 00000000:00000000 00:00000000 00000000     0        0 3 1 0000000000000000 20 4 30 10 -1
+`
+	expected := []TCPConnection{
+		{LocalIP: "fd00:10:244::5", LocalPort: 8080, RemoteIP: "fd00:10:244::9", RemotePort: 50000},
+		// IPv4 clients of a dual-stack socket
+		{LocalIP: "10.244.0.5", LocalPort: 8080, RemoteIP: "10.244.0.9", RemotePort: 50001},
+	}
+	utils.Equals(t, expected, PurgeTCP6Data(tcp6))
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"math"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// bytesInGB is the GB of purser prices and usage, 2^30 bytes
+const bytesInGB = 1024 * 1024 * 1024
+
+// Precision (in decimal places) of the quantities and costs exposed by the api. Costs are computed and summed with
+// the exact values, only the values of the responses are rounded.
+const (
+	QuantityPrecision = 6
+	CostPrecision     = 6
+)
+
+// Cores is an amount of cpu in cores
+type Cores float64
+
+// GB is an amount of memory or storage in GB of 2^30 bytes
+type GB float64
+
+// CoreHours is the usage of an amount of cpu over a period
+type CoreHours float64
+
+// GBHours is the usage of an amount of memory or storage over a period
+type GBHours float64
+
+// CPUCores converts a cpu quantity to cores from its integer millicores, so that ex: 100m is exactly 0.1 cores
+func CPUCores(quantity resource.Quantity) Cores {
+	return Cores(float64(quantity.MilliValue()) / 1000)
+}
+
+// MemoryGB converts a memory or storage quantity to GB of 2^30 bytes from its integer bytes (fractions of a byte are
+// rounded up)
+func MemoryGB(quantity resource.Quantity) GB {
+	return GB(float64(quantity.Value()) / bytesInGB)
+}
+
+// Quantity converts the cores back to a quantity of (rounded) millicores
+func (cores Cores) Quantity() resource.Quantity {
+	return *resource.NewMilliQuantity(int64(math.Round(float64(cores)*1000)), resource.DecimalSI)
+}
+
+// Quantity converts the GB back to a quantity of (rounded) bytes
+func (gb GB) Quantity() resource.Quantity {
+	return *resource.NewQuantity(int64(math.Round(float64(gb)*bytesInGB)), resource.BinarySI)
+}
+
+// Over returns the usage of the cores allocated for the given hours
+func (cores Cores) Over(hours float64) CoreHours {
+	return CoreHours(float64(cores) * hours)
+}
+
+// Over returns the usage of the GB allocated for the given hours
+func (gb GB) Over(hours float64) GBHours {
+	return GBHours(float64(gb) * hours)
+}
+
+// CostPerHour returns the cost per hour of the cores at the given price per cpu hour
+func (cores Cores) CostPerHour(pricePerCPUHour float64) float64 {
+	return cores.Over(1).Cost(pricePerCPUHour)
+}
+
+// CostPerHour returns the cost per hour of the GB at the given price per GB hour
+func (gb GB) CostPerHour(pricePerGBHour float64) float64 {
+	return gb.Over(1).Cost(pricePerGBHour)
+}
+
+// Average returns the cores allocated on average for the usage over the given hours
+func (usage CoreHours) Average(hours float64) Cores {
+	return Cores(float64(usage) / hours)
+}
+
+// Average returns the GB allocated on average for the usage over the given hours
+func (usage GBHours) Average(hours float64) GB {
+	return GB(float64(usage) / hours)
+}
+
+// Cost returns the cost of the usage at the given price per cpu hour
+func (usage CoreHours) Cost(pricePerCPUHour float64) float64 {
+	return float64(usage) * pricePerCPUHour
+}
+
+// Cost returns the cost of the usage at the given price per GB hour
+func (usage GBHours) Cost(pricePerGBHour float64) float64 {
+	return float64(usage) * pricePerGBHour
+}
+
+// Round rounds the cores to the api precision
+func (cores Cores) Round() Cores {
+	return Cores(Round(float64(cores), QuantityPrecision))
+}
+
+// Round rounds the GB to the api precision
+func (gb GB) Round() GB {
+	return GB(Round(float64(gb), QuantityPrecision))
+}
+
+// Round rounds the usage to the api precision
+func (usage CoreHours) Round() CoreHours {
+	return CoreHours(Round(float64(usage), QuantityPrecision))
+}
+
+// Round rounds the usage to the api precision
+func (usage GBHours) Round() GBHours {
+	return GBHours(Round(float64(usage), QuantityPrecision))
+}
+
+// Round rounds the value half away from zero to the given number of decimal places
+func Round(value float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(value*scale) / scale
+}
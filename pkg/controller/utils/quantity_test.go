@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestCPUCores(t *testing.T) {
+	utils.Equals(t, Cores(0.1), CPUCores(resource.MustParse("100m")))
+	utils.Equals(t, Cores(2), CPUCores(resource.MustParse("2")))
+	utils.Equals(t, Cores(0.25), CPUCores(resource.MustParse("250m")))
+	utils.Equals(t, int64(250), CPUCores(resource.MustParse("250m")).Quantity().MilliValue())
+}
+
+func TestMemoryGB(t *testing.T) {
+	utils.Equals(t, GB(1.5), MemoryGB(resource.MustParse("1536Mi")))
+	utils.Equals(t, GB(1), MemoryGB(resource.MustParse("1Gi")))
+	utils.Equals(t, 1.0, ConvertToFloat64GB(resource.NewQuantity(bytesInGB, resource.BinarySI)))
+	utils.Equals(t, int64(1536*1024*1024), MemoryGB(resource.MustParse("1536Mi")).Quantity().Value())
+}
+
+func TestUsageCost(t *testing.T) {
+	usage := CPUCores(resource.MustParse("100m")).Over(10)
+	utils.Equals(t, CoreHours(1), usage)
+	utils.Equals(t, 0.024, usage.Cost(0.024))
+	utils.Equals(t, Cores(0.5), usage.Average(2))
+	utils.Equals(t, 0.02, MemoryGB(resource.MustParse("2Gi")).CostPerHour(0.01))
+	utils.Equals(t, GBHours(0.333333), GBHours(1.0/3).Round())
+}
+
+func TestRound(t *testing.T) {
+	utils.Equals(t, 0.3, Round(0.1+0.2, CostPrecision))
+	utils.Equals(t, 1.234568, Round(1.2345675, QuantityPrecision))
+	utils.Equals(t, -2.5, Round(-2.45, 1))
+}
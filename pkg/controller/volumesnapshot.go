@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/sharding"
+)
+
+// versions of the snapshot.storage.k8s.io api, newest first
+var volumeSnapshotAPIPaths = []string{
+	"/apis/snapshot.storage.k8s.io/v1/volumesnapshots",
+	"/apis/snapshot.storage.k8s.io/v1beta1/volumesnapshots",
+}
+
+// ScanVolumeSnapshots stores the volume snapshots of the namespaces processed by this controller replica and
+// closes the ones which were deleted since the previous scan. Clusters without the snapshot api are skipped.
+func ScanVolumeSnapshots() {
+	if Kubeclient == nil {
+		return
+	}
+	var data []byte
+	var err error
+	for _, path := range volumeSnapshotAPIPaths {
+		if data, err = Kubeclient.Discovery().RESTClient().Get().AbsPath(path).DoRaw(); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		log.Debugf("skipping volume snapshots, unable to list them: (%v)", err)
+		return
+	}
+
+	type snapshotList struct {
+		Items []models.RawVolumeSnapshot `json:"items"`
+	}
+	snapshots := snapshotList{}
+	if err = json.Unmarshal(data, &snapshots); err != nil {
+		log.Errorf("unable to decode volume snapshots, error: (%v)", err)
+		return
+	}
+	scanTime := time.Now()
+	present := map[string]bool{}
+	for _, snapshot := range snapshots.Items {
+		present[snapshot.Metadata.Namespace+":"+snapshot.Metadata.Name] = true
+		if !sharding.Owns(snapshot.Metadata.Namespace) {
+			continue
+		}
+		if err = models.StoreVolumeSnapshot(snapshot); err != nil {
+			log.Errorf("unable to store volume snapshot: (%s:%s), error: (%v)", snapshot.Metadata.Namespace, snapshot.Metadata.Name, err)
+		}
+	}
+	closed, err := models.CloseDeletedVolumeSnapshots(present, sharding.Owns, scanTime)
+	if err != nil {
+		log.Errorf("unable to close deleted volume snapshots, error: (%v)", err)
+	} else if closed > 0 {
+		log.Infof("closed %d deleted volume snapshots", closed)
+	}
+}
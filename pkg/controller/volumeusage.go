@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/vmware/purser/pkg/controller/dgraph/models"
+	"github.com/vmware/purser/pkg/controller/sharding"
+	"github.com/vmware/purser/pkg/controller/utils"
+)
+
+// statsSummary is the part of the kubelet stats summary (/stats/summary) giving the usage of the pod volumes
+type statsSummary struct {
+	Pods []struct {
+		Volumes []struct {
+			UsedBytes *uint64 `json:"usedBytes"`
+			PVCRef    *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// ScanVolumeUsage records the storage used by the pvcs of the namespaces processed by this controller replica, as
+// reported by the kubelet of every node through the node proxy of the api server.
+func ScanVolumeUsage() {
+	if Kubeclient == nil {
+		return
+	}
+	nodes, err := Kubeclient.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	if err != nil {
+		log.Errorf("unable to list nodes for volume usage, error: (%v)", err)
+		return
+	}
+
+	scanTime := time.Now()
+	stored := 0
+	for _, node := range nodes.Items {
+		data, err := Kubeclient.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes/" + node.Name + "/proxy/stats/summary").DoRaw()
+		if err != nil {
+			log.Debugf("skipping volume usage of node: (%s), unable to get kubelet stats: (%v)", node.Name, err)
+			continue
+		}
+		usage, err := pvcUsageFromSummary(data)
+		if err != nil {
+			log.Errorf("unable to decode kubelet stats of node: (%s), error: (%v)", node.Name, err)
+			continue
+		}
+		for xid, used := range usage {
+			if !sharding.Owns(strings.SplitN(xid, ":", 2)[0]) {
+				continue
+			}
+			if err = models.StorePVCStorageUsage(xid, used, scanTime); err != nil {
+				log.Debugf("unable to store usage of pvc: (%s), error: (%v)", xid, err)
+				continue
+			}
+			stored++
+		}
+	}
+	log.Debugf("stored storage usage of %d pvcs", stored)
+}
+
+// pvcUsageFromSummary returns the storage used (in GB) by every pvc (namespace:name) mounted on the node. A pvc
+// mounted by several pods of the node is counted once.
+func pvcUsageFromSummary(data []byte) (map[string]utils.GB, error) {
+	summary := statsSummary{}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, err
+	}
+	usage := map[string]utils.GB{}
+	for _, pod := range summary.Pods {
+		for _, volume := range pod.Volumes {
+			if volume.PVCRef == nil || volume.UsedBytes == nil {
+				continue
+			}
+			xid := volume.PVCRef.Namespace + ":" + volume.PVCRef.Name
+			if used := utils.MemoryGB(*resource.NewQuantity(int64(*volume.UsedBytes), resource.BinarySI)); used > usage[xid] {
+				usage[xid] = used
+			}
+		}
+	}
+	return usage, nil
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/vmware/purser/test/utils"
+)
+
+func TestPVCUsageFromSummary(t *testing.T) {
+	summary := `{
+		"node": {"nodeName": "node-1"},
+		"pods": [
+			{"podRef": {"name": "db-0", "namespace": "shop"}, "volume": [
+				{"name": "data", "usedBytes": 53687091200, "capacityBytes": 1099511627776, "pvcRef": {"name": "data-db-0", "namespace": "shop"}},
+				{"name": "default-token", "usedBytes": 12288}
+			]},
+			{"podRef": {"name": "backup", "namespace": "shop"}, "volume": [
+				{"name": "data", "usedBytes": 53687091201, "pvcRef": {"name": "data-db-0", "namespace": "shop"}},
+				{"name": "scratch", "pvcRef": {"name": "scratch", "namespace": "shop"}}
+			]},
+			{"podRef": {"name": "web", "namespace": "shop"}}
+		]
+	}`
+	usage, err := pvcUsageFromSummary([]byte(summary))
+	utils.Ok(t, err)
+	// the pvc mounted by two pods is counted once and volumes without usage are skipped
+	utils.Equals(t, 1, len(usage))
+	utils.Assert(t, usage["shop:data-db-0"] > 49.99 && usage["shop:data-db-0"] < 50.01, "expected 50GB used, got: %v", usage["shop:data-db-0"])
+}
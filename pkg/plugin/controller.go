@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	controllerService     = "purser-db:3030"
+	controllerNamespace   = "default"
+	recomputePath         = "/admin/recompute"
+	chargebackPath        = "/costs/chargeback"
+	recomputePollInterval = 5 * time.Second
+	// adminTokenEnv is the environment variable holding the admin token required by the admin endpoints
+	adminTokenEnv = "PURSER_ADMIN_TOKEN"
+)
+
+// recomputeJob is the progress of a cost recomputation job in the controller
+type recomputeJob struct {
+	ID            string `json:"id"`
+	TotalDays     int    `json:"totalDays"`
+	CompletedDays int    `json:"completedDays"`
+	Status        string `json:"status"`
+	Error         string `json:"error,omitempty"`
+}
+
+// RecomputeCosts asks the purser controller to recompute the daily cost summaries for days from `from` to `to`
+// (format: 2006-01-02) and prints the progress until the recomputation finishes. The admin token of the controller
+// is read from the PURSER_ADMIN_TOKEN environment variable.
+func RecomputeCosts(from, to string) {
+	authorization := "Bearer " + os.Getenv(adminTokenEnv)
+	result, err := ClientSetInstance.CoreV1().RESTClient().Post().
+		Namespace(controllerNamespace).
+		Resource("services").
+		Name(controllerService).
+		SubResource("proxy").
+		Suffix(recomputePath).
+		Param("from", from).
+		Param("to", to).
+		SetHeader("Authorization", authorization).
+		DoRaw()
+	if err != nil {
+		fmt.Printf("Unable to start cost recomputation: %v\n", err)
+		return
+	}
+	job := recomputeJob{}
+	if err = json.Unmarshal(result, &job); err != nil {
+		fmt.Printf("Unable to read cost recomputation job: %v\n", err)
+		return
+	}
+
+	for job.Status == "running" {
+		fmt.Printf("Recomputing costs: %d/%d days completed\n", job.CompletedDays, job.TotalDays)
+		time.Sleep(recomputePollInterval)
+		result, err = ClientSetInstance.CoreV1().RESTClient().Get().
+			Namespace(controllerNamespace).
+			Resource("services").
+			Name(controllerService).
+			SubResource("proxy").
+			Suffix(recomputePath).
+			Param("id", job.ID).
+			SetHeader("Authorization", authorization).
+			DoRaw()
+		if err != nil {
+			fmt.Printf("Unable to get progress of cost recomputation: %v\n", err)
+			return
+		}
+		if err = json.Unmarshal(result, &job); err != nil {
+			fmt.Printf("Unable to read cost recomputation job: %v\n", err)
+			return
+		}
+	}
+	if job.Error != "" {
+		fmt.Printf("Cost recomputation %s: %s\n", job.Status, job.Error)
+		return
+	}
+	fmt.Printf("Cost recomputation %s: %d/%d days\n", job.Status, job.CompletedDays, job.TotalDays)
+}
+
+// GetChargeback prints the chargeback report of the purser controller for the window from `from` to `to` (format:
+// 2006-01-02) per namespace, or per value of the label key when it is given, in format json or csv.
+func GetChargeback(label, from, to, format string) {
+	params := map[string]string{"from": from, "to": to, "format": format, "groupBy": "namespace"}
+	if label != "" {
+		params["groupBy"] = "label"
+		params["label"] = label
+	}
+	result, err := ClientSetInstance.CoreV1().Services(controllerNamespace).
+		ProxyGet("http", "purser-db", "3030", chargebackPath, params).
+		DoRaw()
+	if err != nil {
+		fmt.Printf("Unable to get chargeback: %v\n", err)
+		return
+	}
+	fmt.Print(string(result))
+}
@@ -0,0 +1,94 @@
+// +build chaos
+
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaos
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Fault is a failure injected while the controller ingests events, it returns once the failure is over
+type Fault struct {
+	Name   string
+	Inject func() error
+}
+
+// RestartDgraph stops the Dgraph container for the downtime then starts it again, the data volume is kept
+func (h *Harness) RestartDgraph(downtime time.Duration) Fault {
+	return Fault{
+		Name: fmt.Sprintf("dgraph down for %v", downtime),
+		Inject: func() error {
+			return h.interrupt(h.dgraphContainer, "stop", "start", downtime)
+		},
+	}
+}
+
+// DisconnectAPIServer pauses the kind control plane container for the downtime, the watches of the controller are
+// broken and its informers have to relist
+func (h *Harness) DisconnectAPIServer(downtime time.Duration) Fault {
+	return Fault{
+		Name: fmt.Sprintf("api server unreachable for %v", downtime),
+		Inject: func() error {
+			return h.interrupt(h.apiServerContainer, "pause", "unpause", downtime)
+		},
+	}
+}
+
+// Inject runs the faults one after the other, every interval, until done is closed
+func (h *Harness) Inject(faults []Fault, interval time.Duration, done <-chan struct{}) {
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		case <-time.After(interval):
+		}
+		fault := faults[i%len(faults)]
+		h.t.Logf("injecting fault: %s", fault.Name)
+		if err := fault.Inject(); err != nil {
+			h.t.Errorf("unable to inject fault %s: %v", fault.Name, err)
+			return
+		}
+	}
+}
+
+func (h *Harness) interrupt(container, stop, start string, downtime time.Duration) error {
+	if err := docker(stop, container); err != nil {
+		return err
+	}
+	time.Sleep(downtime)
+	return docker(start, container)
+}
+
+func docker(args ...string) error {
+	output, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker %s: %v: %s", strings.Join(args, " "), err, output)
+	}
+	return nil
+}
+
+// isDone tells whether a create or a delete retried after a fault was already done by a previous attempt
+func isDone(err error) bool {
+	return errors.IsAlreadyExists(err) || errors.IsNotFound(err)
+}
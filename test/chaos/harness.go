@@ -0,0 +1,267 @@
+// +build chaos
+
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package chaos runs a purser controller against a kubernetes cluster (kind) and a Dgraph container, injects
+// failures while pods are created and deleted and checks that the controller stored every cost relevant event.
+// The harness is built with the chaos tag, build/chaos-test.sh sets up the cluster, the Dgraph and the controller.
+package chaos
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/vmware/purser/pkg/controller/dgraph"
+	"github.com/vmware/purser/pkg/utils"
+
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Environment variables of the harness, set by build/chaos-test.sh
+const (
+	kubeconfigEnv         = "KUBECONFIG"
+	dgraphEnv             = "PURSER_CHAOS_DGRAPH"
+	dgraphContainerEnv    = "PURSER_CHAOS_DGRAPH_CONTAINER"
+	apiServerContainerEnv = "PURSER_CHAOS_APISERVER_CONTAINER"
+	settleTimeoutEnv      = "PURSER_CHAOS_SETTLE_TIMEOUT"
+)
+
+const pollInterval = 5 * time.Second
+
+// Harness gives access to the cluster watched by the controller and to its Dgraph
+type Harness struct {
+	t                  *testing.T
+	kubeClient         kubernetes.Interface
+	dgraphContainer    string
+	apiServerContainer string
+	settleTimeout      time.Duration
+}
+
+// StoredPod is a pod as stored by the controller
+type StoredPod struct {
+	Xid       string `json:"xid"`
+	StartTime string `json:"startTime,omitempty"`
+	EndTime   string `json:"endTime,omitempty"`
+}
+
+// NewHarness connects to the cluster of KUBECONFIG and to the Dgraph of PURSER_CHAOS_DGRAPH, the test is skipped
+// when the harness environment is not set up
+func NewHarness(t *testing.T) *Harness {
+	kubeconfig := os.Getenv(kubeconfigEnv)
+	if kubeconfig == "" {
+		t.Skipf("%s is not set, run the chaos tests with build/chaos-test.sh", kubeconfigEnv)
+	}
+	config, err := utils.GetKubeconfig(kubeconfig)
+	if err != nil {
+		t.Fatalf("unable to read kubeconfig %s: %v", kubeconfig, err)
+	}
+	if err = dgraph.Open(getEnv(dgraphEnv, "localhost:9080")); err != nil {
+		t.Fatalf("unable to connect to Dgraph: %v", err)
+	}
+
+	settleTimeout, err := time.ParseDuration(getEnv(settleTimeoutEnv, "3m"))
+	if err != nil {
+		t.Fatalf("invalid %s: %v", settleTimeoutEnv, err)
+	}
+	return &Harness{
+		t:                  t,
+		kubeClient:         utils.GetKubeclient(config),
+		dgraphContainer:    getEnv(dgraphContainerEnv, "purser-chaos-dgraph"),
+		apiServerContainer: getEnv(apiServerContainerEnv, "purser-chaos-control-plane"),
+		settleTimeout:      settleTimeout,
+	}
+}
+
+// Close terminates the connection to Dgraph
+func (h *Harness) Close() {
+	dgraph.Close()
+}
+
+// CreateNamespace creates a namespace for the pods of a test, it is deleted with its pods at the end of the test
+func (h *Harness) CreateNamespace(prefix string) string {
+	name := fmt.Sprintf("%s-%d", prefix, time.Now().Unix())
+	namespace := &api_v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: name}}
+	if _, err := h.kubeClient.CoreV1().Namespaces().Create(namespace); err != nil {
+		h.t.Fatalf("unable to create namespace %s: %v", name, err)
+	}
+	return name
+}
+
+// DeleteNamespace deletes the namespace of a test
+func (h *Harness) DeleteNamespace(name string) {
+	if err := h.kubeClient.CoreV1().Namespaces().Delete(name, &meta_v1.DeleteOptions{}); err != nil {
+		h.t.Logf("unable to delete namespace %s: %v", name, err)
+	}
+}
+
+// CreatePod creates a pause pod requesting a little cpu and memory, the creation is retried while the api server is
+// unreachable. It returns the xid of the pod in Dgraph.
+func (h *Harness) CreatePod(namespace, name string) string {
+	pod := &api_v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "chaos"}},
+		Spec: api_v1.PodSpec{
+			Containers: []api_v1.Container{{
+				Name:  "pause",
+				Image: "k8s.gcr.io/pause:3.1",
+				Resources: api_v1.ResourceRequirements{
+					Requests: api_v1.ResourceList{
+						api_v1.ResourceCPU:    resource.MustParse("10m"),
+						api_v1.ResourceMemory: resource.MustParse("8Mi"),
+					},
+				},
+			}},
+		},
+	}
+	h.retry("create pod "+name, func() error {
+		_, err := h.kubeClient.CoreV1().Pods(namespace).Create(pod)
+		return err
+	})
+	return namespace + ":" + name
+}
+
+// DeletePod deletes the pod, the deletion is retried while the api server is unreachable
+func (h *Harness) DeletePod(namespace, name string) {
+	h.retry("delete pod "+name, func() error {
+		return h.kubeClient.CoreV1().Pods(namespace).Delete(name, &meta_v1.DeleteOptions{})
+	})
+}
+
+// retry calls action until it succeeds or the settle timeout is reached, the faults make the api server unreachable
+// for a while. Resources already created or deleted by a previous attempt are accepted.
+func (h *Harness) retry(description string, action func() error) {
+	deadline := time.Now().Add(h.settleTimeout)
+	for {
+		err := action()
+		if err == nil || isDone(err) {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("unable to %s: %v", description, err)
+		}
+		h.t.Logf("unable to %s, retrying: %v", description, err)
+		time.Sleep(pollInterval)
+	}
+}
+
+// StoredPods returns the pods of the namespace stored in Dgraph, by xid
+func (h *Harness) StoredPods(namespace string) (map[string]StoredPod, error) {
+	query := `query pods($namespace: string) {
+		namespaces(func: eq(xid, $namespace)) @filter(has(isNamespace)) {
+			pods: ~namespace @filter(has(isPod)) {
+				xid
+				startTime
+				endTime
+			}
+		}
+	}`
+	type root struct {
+		Namespaces []struct {
+			Pods []StoredPod `json:"pods"`
+		} `json:"namespaces"`
+	}
+	var result root
+	if err := dgraph.ExecuteQueryWithVars(query, map[string]string{"$namespace": namespace}, &result); err != nil {
+		return nil, err
+	}
+
+	stored := map[string]StoredPod{}
+	for _, ns := range result.Namespaces {
+		for _, pod := range ns.Pods {
+			stored[pod.Xid] = pod
+		}
+	}
+	return stored, nil
+}
+
+// PodNodes returns the number of pod nodes stored in Dgraph for each of the xids, an xid must have a single node
+func (h *Harness) PodNodes(xids []string) (map[string]int, error) {
+	// the xids are the names of the test pods, a json list is a list of the query language
+	encoded, err := json.Marshal(xids)
+	if err != nil {
+		return nil, err
+	}
+	query := `{
+		pods(func: eq(xid, ` + string(encoded) + `)) @filter(has(isPod)) {
+			xid
+		}
+	}`
+	type root struct {
+		Pods []StoredPod `json:"pods"`
+	}
+	var result root
+	if err = dgraph.ExecuteQuery(query, &result); err != nil {
+		return nil, err
+	}
+
+	nodes := map[string]int{}
+	for _, pod := range result.Pods {
+		nodes[pod.Xid]++
+	}
+	return nodes, nil
+}
+
+// WaitForPods polls Dgraph until every pod of the namespace is stored and check returns no problem, or fails the test
+// with the problems left at the end of the settle timeout
+func (h *Harness) WaitForPods(namespace string, xids []string, check func(pod StoredPod) string) {
+	deadline := time.Now().Add(h.settleTimeout)
+	for {
+		problems, err := h.podProblems(namespace, xids, check)
+		if err == nil && len(problems) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				h.t.Fatalf("unable to read pods from Dgraph: %v", err)
+			}
+			h.t.Fatalf("%d cost relevant events lost: %v", len(problems), problems)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func (h *Harness) podProblems(namespace string, xids []string, check func(pod StoredPod) string) ([]string, error) {
+	stored, err := h.StoredPods(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, xid := range xids {
+		pod, isStored := stored[xid]
+		if !isStored {
+			problems = append(problems, xid+": not stored")
+			continue
+		}
+		if problem := check(pod); problem != "" {
+			problems = append(problems, xid+": "+problem)
+		}
+	}
+	return problems, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
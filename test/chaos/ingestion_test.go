@@ -0,0 +1,89 @@
+// +build chaos
+
+/*
+ * Copyright (c) 2018 VMware Inc. All Rights Reserved.
+ * SPDX-License-Identifier: Apache-2.0
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chaos
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	podCount     = 30
+	podInterval  = 2 * time.Second
+	faultsPeriod = 15 * time.Second
+	downtime     = 10 * time.Second
+)
+
+// TestNoEventLostUnderFaults creates and deletes pods while Dgraph restarts and the api server is unreachable, every
+// pod must end up stored once with its start time and every deleted pod with its end time
+func TestNoEventLostUnderFaults(t *testing.T) {
+	h := NewHarness(t)
+	defer h.Close()
+	namespace := h.CreateNamespace("purser-chaos")
+	defer h.DeleteNamespace(namespace)
+
+	done := make(chan struct{})
+	var injecting sync.WaitGroup
+	injecting.Add(1)
+	go func() {
+		defer injecting.Done()
+		h.Inject([]Fault{h.RestartDgraph(downtime), h.DisconnectAPIServer(downtime)}, faultsPeriod, done)
+	}()
+
+	var created, deleted []string
+	for i := 0; i < podCount; i++ {
+		name := fmt.Sprintf("chaos-%d", i)
+		created = append(created, h.CreatePod(namespace, name))
+		// every third pod is deleted soon after its creation, its end time must not be lost either
+		if i%3 == 2 {
+			h.DeletePod(namespace, name)
+			deleted = append(deleted, namespace+":"+name)
+		}
+		time.Sleep(podInterval)
+	}
+	close(done)
+	injecting.Wait()
+
+	h.WaitForPods(namespace, created, func(pod StoredPod) string {
+		if pod.StartTime == "" {
+			return "start time lost"
+		}
+		return ""
+	})
+	h.WaitForPods(namespace, deleted, func(pod StoredPod) string {
+		if pod.EndTime == "" {
+			return "end time lost"
+		}
+		return ""
+	})
+
+	// a pod stored more than once is charged more than once
+	nodes, err := h.PodNodes(created)
+	if err != nil {
+		t.Fatalf("unable to read pods from Dgraph: %v", err)
+	}
+	for _, xid := range created {
+		if nodes[xid] != 1 {
+			t.Errorf("%s: stored in %d nodes", xid, nodes[xid])
+		}
+	}
+}